@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"shared/events"
+	"shared/flags"
+	"shared/tenant"
+)
+
+// ============================================================================
+// ANSWER CACHE
+// ============================================================================
+// Compliance FAQs get asked over and over in slightly different words. A
+// confident answer is cached under the query's embedding, tagged with the
+// collections and documents it drew on. A later query from the same tenant,
+// with the same synthesis model and context, whose cosine similarity is at
+// least AGENT_ANSWER_CACHE_SIMILARITY (default 0.97) gets that answer at once
+// with "cached": true. As with the plan cache, only queries without
+// conversation history use it. Entries live in process memory:
+//
+//	AGENT_ANSWER_CACHE_SIZE  answers kept, least recently used evicted first (default 1000)
+//	AGENT_ANSWER_CACHE_TTL   how long an answer is served (default 1h)
+//
+// Answers drawn from a collection are dropped when a document is ingested
+// into it (ingest.completed), and answers citing a document when it is
+// deleted (document.deleted). POST /admin/answer-cache/invalidate drops them
+// by hand. Turn the cache off with the agent_answer_cache flag.
+
+var answerCacheFlag = flags.Define("agent_answer_cache", true,
+	"Answer a query with the cached answer of a semantically similar earlier query")
+
+var (
+	answerCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_answer_cache_total",
+		Help: "Queries answered from the semantic answer cache (hit) or by the agent (miss).",
+	}, []string{"result"})
+
+	answerCacheInvalidations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agent_answer_cache_invalidated_total",
+		Help: "Cached answers dropped because their documents changed or on request.",
+	})
+)
+
+var (
+	AGENT_ANSWER_CACHE_SIMILARITY = envFloat("AGENT_ANSWER_CACHE_SIMILARITY", 0.97)
+
+	answers = newSemanticCache(envInt("AGENT_ANSWER_CACHE_SIZE", 1000), envDuration("AGENT_ANSWER_CACHE_TTL", time.Hour))
+)
+
+// answerCacheable reports whether req may be answered from, and its answer
+// stored in, the cache.
+func answerCacheable(ctx context.Context, req AgentRequest) bool {
+	return answerCacheFlag.Enabled() && recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID) == "" && len(userMemoryFrom(ctx)) == 0
+}
+
+// answerScope keys what besides the query an answer depends on.
+func answerScope(req AgentRequest) string {
+	encoded, _ := json.Marshal(req.Context)
+	schema, _ := json.Marshal(req.ResponseSchema)
+	return req.SynthesisModel + "\x00" + string(encoded) + "\x00" + string(schema) + "\x00" + req.Persona
+}
+
+// answerFromCache fills response from the cache when a similar query was
+// answered before and reports whether it did. The returned context carries
+// the query's embedding for the plan cache.
+func answerFromCache(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) (context.Context, bool) {
+	start := time.Now()
+	embedding, err := embedQuery(ctx, req.Query)
+	if err != nil {
+		log.Printf("Answer cache skipped: %v", err)
+		return ctx, false
+	}
+	ctx = withQueryEmbedding(ctx, req.Query, embedding)
+
+	var cached AgentResponse
+	similarTo, similarity := answers.Get(tenant.FromContext(ctx), answerScope(req), embedding, AGENT_ANSWER_CACHE_SIMILARITY, &cached)
+	if similarTo == "" {
+		answerCacheLookups.WithLabelValues(cacheMiss).Inc()
+		return ctx, false
+	}
+
+	log.Printf("♻️  Answering from cache: '%s' (similarity %.3f)", similarTo, similarity)
+	answerCacheLookups.WithLabelValues(cacheHit).Inc()
+	response.Answer = cached.Answer
+	response.Confidence = cached.Confidence
+	response.ToolsUsed = cached.ToolsUsed
+	response.Sources = cached.Sources
+	response.Citations = cached.Citations
+	response.Cached = true
+	recordStep(response, prog, AgentStep{
+		Type:        "cache",
+		Description: "Look up answer cache",
+		Result:      fmt.Sprintf("Reused answer of similar query %q, similarity %.2f", similarTo, similarity),
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+		Cache:       cacheHit,
+	})
+	if sink := prog.tokenSink(1); sink != nil {
+		sink(response.Answer)
+	}
+	return ctx, true
+}
+
+// cacheAnswer stores a complete, confident answer, tagged with the
+// collections and documents of its sources.
+func cacheAnswer(ctx context.Context, req AgentRequest, response AgentResponse) {
+	if response.Answer == "" || response.BudgetExceeded || response.NeedMoreInfo || response.Degraded || response.Confidence < CONFIDENCE_THRESHOLD {
+		return
+	}
+	embedding, err := embedQuery(ctx, req.Query)
+	if err != nil {
+		return
+	}
+
+	var tags []string
+	for _, source := range response.Sources {
+		if source.Collection != "" && !hasTag(tags, "collection:"+source.Collection) {
+			tags = append(tags, "collection:"+source.Collection)
+		}
+		if source.DocumentID != "" && !hasTag(tags, "document:"+source.DocumentID) {
+			tags = append(tags, "document:"+source.DocumentID)
+		}
+	}
+	answers.Put(tenant.FromContext(ctx), answerScope(req), req.Query, embedding, AgentResponse{
+		Answer:     response.Answer,
+		Confidence: response.Confidence,
+		ToolsUsed:  response.ToolsUsed,
+		Sources:    response.Sources,
+		Citations:  response.Citations,
+	}, tags)
+}
+
+// invalidateAnswers drops the tenant's answers drawn from a collection or
+// document, or all of them when both are empty.
+func invalidateAnswers(tenantID, collection, documentID string) int {
+	dropped := 0
+	switch {
+	case collection != "":
+		dropped += answers.Invalidate(tenantID, "collection:"+collection)
+		if documentID != "" {
+			dropped += answers.Invalidate(tenantID, "document:"+documentID)
+		}
+	case documentID != "":
+		dropped += answers.Invalidate(tenantID, "document:"+documentID)
+	default:
+		dropped += answers.Invalidate(tenantID, "")
+	}
+	answerCacheInvalidations.Add(float64(dropped))
+	return dropped
+}
+
+// subscribeAnswerInvalidation drops cached answers when the documents they
+// were drawn from are re-ingested or deleted.
+func subscribeAnswerInvalidation(bus events.Bus) error {
+	handler := func(e events.Event) {
+		tenantID := e.Tenant
+		if tenantID == "" {
+			tenantID = tenant.Default
+		}
+		collection, _ := e.Data["collection"].(string)
+		documentID, _ := e.Data["document_id"].(string)
+		if collection == "" && documentID == "" {
+			return
+		}
+		if n := invalidateAnswers(tenantID, collection, documentID); n > 0 {
+			log.Printf("♻️  Dropped %d cached answers after %s", n, e.Subject)
+		}
+	}
+	for _, subject := range []string{events.IngestCompleted, events.DocumentDeleted} {
+		if err := bus.Subscribe(subject, handler); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+	}
+	return nil
+}
+
+// Drop cached answers drawn from a collection or document, or all of the
+// tenant's answers when the body names neither
+func invalidateAnswerCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Collection string `json:"collection"`
+		DocumentID string `json:"document_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	dropped := invalidateAnswers(tenant.FromContext(r.Context()), body.Collection, body.DocumentID)
+	log.Printf("♻️  Dropped %d cached answers on request", dropped)
+	respondJSON(w, map[string]int{"invalidated": dropped}, http.StatusOK)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genai"
+	"shared/tenant"
+)
+
+// ============================================================================
+// AUDIT LOG
+// ============================================================================
+// Regulated deployments, merchant onboarding among them, must be able to
+// review why the agent decided what it did. With an audit store configured,
+// every run, dry runs and cached answers included, appends one AuditRecord
+// holding the request and response as returned, every plan made, every
+// action with its parameters and the result the model saw, and every
+// Gemini output:
+//
+//	AGENT_AUDIT_STORE      memory or sqlite; empty (default) keeps no audit log
+//	AGENT_AUDIT_DB_PATH    SQLite file (default ./data/audit.db)
+//	AGENT_AUDIT_RETENTION  how long records are kept (default 2160h, 0 keeps forever)
+//
+// Records are never updated; the SQLite table refuses updates, and only the
+// retention sweep deletes. GET /agent/audit lists the caller's tenant's
+// records, newest first, and GET /agent/audit/{id} returns one. A record
+// that can't be written is logged and counted but doesn't fail the run.
+
+var auditFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "agent_audit_failures_total",
+	Help: "Agent runs whose audit record could not be written.",
+})
+
+// audits is nil when no audit store is configured.
+var audits auditStore
+
+// AuditRecord - One agent run as it happened
+type AuditRecord struct {
+	ID             string          `json:"id"`
+	TenantID       string          `json:"tenant_id"`
+	ConversationID string          `json:"conversation_id"`
+	UserID         string          `json:"user_id,omitempty"`
+	TraceID        string          `json:"trace_id,omitempty"`
+	Mode           string          `json:"mode"`
+	Request        json.RawMessage `json:"request"`
+	Response       json.RawMessage `json:"response"`
+	Plans          []ExecutionPlan `json:"plans"`
+	Actions        []AuditAction   `json:"actions"`
+	ModelOutputs   []ModelOutput   `json:"model_outputs"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// AuditAction - An executed action and what it returned
+type AuditAction struct {
+	Action   Action          `json:"action"`
+	Result   json.RawMessage `json:"result"`
+	Duration float64         `json:"duration_ms"`
+}
+
+// ModelOutput - What one Gemini call returned
+type ModelOutput struct {
+	Model         string                `json:"model"`
+	Text          string                `json:"text,omitempty"`
+	FunctionCalls []*genai.FunctionCall `json:"function_calls,omitempty"`
+}
+
+// auditFilter narrows a listing of audit records.
+type auditFilter struct {
+	ConversationID string
+	UserID         string
+	Since          time.Time
+	Limit          int
+}
+
+func (f auditFilter) matches(record AuditRecord) bool {
+	return (f.ConversationID == "" || record.ConversationID == f.ConversationID) &&
+		(f.UserID == "" || record.UserID == f.UserID) &&
+		!record.CreatedAt.Before(f.Since)
+}
+
+// auditStore is an append-only log of agent runs, scoped by tenant.
+type auditStore interface {
+	Append(ctx context.Context, record AuditRecord) error
+	// Get returns a record, or nil if it does not exist or has expired.
+	Get(ctx context.Context, tenantID, id string) (*AuditRecord, error)
+	// List returns up to filter.Limit matching records, newest first.
+	List(ctx context.Context, tenantID string, filter auditFilter) ([]AuditRecord, error)
+	Close() error
+}
+
+// newAuditStore builds the backend selected by AGENT_AUDIT_STORE, or nil
+// when none is.
+func newAuditStore() (auditStore, error) {
+	retention, err := time.ParseDuration(getEnv("AGENT_AUDIT_RETENTION", "2160h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AGENT_AUDIT_RETENTION: %w", err)
+	}
+
+	switch backend := getEnv("AGENT_AUDIT_STORE", ""); backend {
+	case "":
+		return nil, nil
+	case "memory":
+		return newMemoryAuditStore(retention), nil
+	case "sqlite":
+		return newSQLiteAuditStore(getEnv("AGENT_AUDIT_DB_PATH", "./data/audit.db"), retention)
+	default:
+		return nil, fmt.Errorf("unknown AGENT_AUDIT_STORE %q (want memory or sqlite)", backend)
+	}
+}
+
+// auditTrail collects what a run planned, did and generated for its audit
+// record. Researchers in multi_agent mode add to it concurrently. A nil
+// trail, for a run that isn't audited, ignores everything.
+type auditTrail struct {
+	mu      sync.Mutex
+	plans   []ExecutionPlan
+	actions []AuditAction
+	outputs []ModelOutput
+}
+
+type auditTrailKey struct{}
+
+// withAuditTrail starts collecting the run's audit trail when an audit store
+// is configured.
+func withAuditTrail(ctx context.Context) context.Context {
+	if audits == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, auditTrailKey{}, &auditTrail{})
+}
+
+func auditTrailFrom(ctx context.Context) *auditTrail {
+	trail, _ := ctx.Value(auditTrailKey{}).(*auditTrail)
+	return trail
+}
+
+func (t *auditTrail) plan(plan *ExecutionPlan) {
+	if t == nil || plan == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.plans = append(t.plans, *plan)
+}
+
+// action records an action with its result, copied as it is now since the
+// loop keeps annotating results.
+func (t *auditTrail) action(action Action, result map[string]interface{}, start time.Time) {
+	if t == nil {
+		return
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		raw, _ = json.Marshal(map[string]string{"error": "result not recordable: " + err.Error()})
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.actions = append(t.actions, AuditAction{
+		Action:   action,
+		Result:   raw,
+		Duration: float64(time.Since(start).Milliseconds()),
+	})
+}
+
+func (t *auditTrail) output(model, text string, calls []*genai.FunctionCall) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outputs = append(t.outputs, ModelOutput{Model: model, Text: text, FunctionCalls: calls})
+}
+
+// recordAudit appends the run's audit record.
+func recordAudit(ctx context.Context, req AgentRequest, response AgentResponse) {
+	trail := auditTrailFrom(ctx)
+	if audits == nil || trail == nil {
+		return
+	}
+	record, err := trail.record(ctx, req, response)
+	if err == nil {
+		err = audits.Append(context.WithoutCancel(ctx), record)
+	}
+	if err != nil {
+		auditFailures.Inc()
+		log.Printf("⚠️  Failed to audit run of conversation %s: %v", req.ConversationID, err)
+	}
+}
+
+// record builds the audit record of the run that collected t.
+func (t *auditTrail) record(ctx context.Context, req AgentRequest, response AgentResponse) (AuditRecord, error) {
+	request, err := json.Marshal(req)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return AuditRecord{
+		ID:             uuid.New().String(),
+		TenantID:       tenant.FromContext(ctx),
+		ConversationID: req.ConversationID,
+		UserID:         req.UserID,
+		TraceID:        response.TraceID,
+		Mode:           req.Mode,
+		Request:        request,
+		Response:       body,
+		Plans:          append([]ExecutionPlan{}, t.plans...),
+		Actions:        append([]AuditAction{}, t.actions...),
+		ModelOutputs:   append([]ModelOutput{}, t.outputs...),
+		CreatedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// List the tenant's audit records, or return one
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if audits == nil {
+		respondError(w, "Audit log is not enabled", http.StatusNotFound)
+		return
+	}
+	tenantID := tenant.FromContext(r.Context())
+
+	if id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/agent/audit"), "/"); id != "" {
+		record, err := audits.Get(r.Context(), tenantID, id)
+		if err != nil {
+			log.Printf("Failed to load audit record %s: %v", id, err)
+			respondError(w, "Failed to load audit record", http.StatusInternalServerError)
+			return
+		}
+		if record == nil {
+			respondError(w, "Audit record not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, record, http.StatusOK)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := auditFilter{
+		ConversationID: query.Get("conversation_id"),
+		UserID:         query.Get("user_id"),
+		Limit:          20,
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			respondError(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	if v := query.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+	}
+
+	records, err := audits.List(r.Context(), tenantID, filter)
+	if err != nil {
+		log.Printf("Failed to list audit records: %v", err)
+		respondError(w, "Failed to list audit records", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{
+		"records": records,
+		"count":   len(records),
+	}, http.StatusOK)
+}
+
+// ============================================================================
+// IN-MEMORY BACKEND
+// ============================================================================
+
+// memoryAuditStore keeps audit records in process, for development; they
+// are lost on restart.
+type memoryAuditStore struct {
+	mu        sync.RWMutex
+	records   map[string][]AuditRecord // by tenant, oldest first
+	retention time.Duration
+}
+
+func newMemoryAuditStore(retention time.Duration) *memoryAuditStore {
+	return &memoryAuditStore{records: make(map[string][]AuditRecord), retention: retention}
+}
+
+func (s *memoryAuditStore) Append(_ context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := append(s.records[record.TenantID], record)
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention)
+		expired := 0
+		for expired < len(records) && records[expired].CreatedAt.Before(cutoff) {
+			expired++
+		}
+		records = records[expired:]
+	}
+	s.records[record.TenantID] = records
+	return nil
+}
+
+func (s *memoryAuditStore) Get(_ context.Context, tenantID, id string) (*AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.records[tenantID] {
+		if record.ID == id && !s.expired(record) {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryAuditStore) List(_ context.Context, tenantID string, filter auditFilter) ([]AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []AuditRecord{}
+	records := s.records[tenantID]
+	for i := len(records) - 1; i >= 0 && len(list) < filter.Limit; i-- {
+		if filter.matches(records[i]) && !s.expired(records[i]) {
+			list = append(list, records[i])
+		}
+	}
+	return list, nil
+}
+
+func (s *memoryAuditStore) expired(record AuditRecord) bool {
+	return s.retention > 0 && time.Since(record.CreatedAt) > s.retention
+}
+
+func (s *memoryAuditStore) Close() error { return nil }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteAuditStore keeps audit records in a SQLite file. The table refuses
+// updates, so records can only be appended and, past retention, swept.
+type sqliteAuditStore struct {
+	db        *sql.DB
+	retention time.Duration
+	stop      chan struct{}
+}
+
+func newSQLiteAuditStore(path string, retention time.Duration) (*sqliteAuditStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id TEXT PRIMARY KEY,
+		tenant_id TEXT NOT NULL,
+		conversation_id TEXT NOT NULL,
+		user_id TEXT NOT NULL DEFAULT '',
+		record TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(tenant_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_conversation ON audit_log(tenant_id, conversation_id, created_at);
+	CREATE TRIGGER IF NOT EXISTS audit_log_append_only BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize audit schema: %w", err)
+	}
+
+	s := &sqliteAuditStore{db: db, retention: retention, stop: make(chan struct{})}
+	if retention > 0 {
+		go s.sweep()
+	}
+	return s, nil
+}
+
+func (s *sqliteAuditStore) Append(ctx context.Context, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (id, tenant_id, conversation_id, user_id, record, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		record.ID, record.TenantID, record.ConversationID, record.UserID, string(data), record.CreatedAt.UTC())
+	return err
+}
+
+func (s *sqliteAuditStore) Get(ctx context.Context, tenantID, id string) (*AuditRecord, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT record FROM audit_log WHERE tenant_id = ? AND id = ? AND created_at >= ?`,
+		tenantID, id, s.cutoff()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record AuditRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *sqliteAuditStore) List(ctx context.Context, tenantID string, filter auditFilter) ([]AuditRecord, error) {
+	query := "SELECT record FROM audit_log WHERE tenant_id = ? AND created_at >= ?"
+	since := s.cutoff()
+	if filter.Since.After(since) {
+		since = filter.Since.UTC()
+	}
+	args := []interface{}{tenantID, since}
+	if filter.ConversationID != "" {
+		query += " AND conversation_id = ?"
+		args = append(args, filter.ConversationID)
+	}
+	if filter.UserID != "" {
+		query += " AND user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []AuditRecord{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		list = append(list, record)
+	}
+	return list, rows.Err()
+}
+
+// cutoff is the creation time of the oldest record still retained.
+func (s *sqliteAuditStore) cutoff() time.Time {
+	if s.retention <= 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Add(-s.retention)
+}
+
+// sweep deletes records past retention.
+func (s *sqliteAuditStore) sweep() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if _, err := s.db.ExecContext(ctx, `DELETE FROM audit_log WHERE created_at < ?`, s.cutoff()); err != nil {
+				log.Printf("⚠️  Failed to sweep audit log: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *sqliteAuditStore) Close() error {
+	close(s.stop)
+	return s.db.Close()
+}
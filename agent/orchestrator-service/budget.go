@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// REQUEST BUDGETS
+// ============================================================================
+// max_llm_calls, max_tokens and deadline_ms bound what one request may
+// spend. Every Gemini call, fallbacks included, is charged to the budget
+// carried in the request context; once it is spent the loop stops before
+// its next LLM step and returns the best answer it has with
+// budget_exceeded set. Tokens are only known after a call returns, so a
+// call may take a request past max_tokens, but no further call is made.
+
+var errBudgetExceeded = errors.New("request budget exceeded")
+
+// budget tracks one request's spending. A nil *budget is unlimited.
+type budget struct {
+	maxCalls  int
+	maxTokens int64
+
+	mu     sync.Mutex
+	calls  int
+	tokens int64
+}
+
+type budgetKey struct{}
+
+// withBudget attaches req's budget to ctx and applies its deadline. Zero
+// limits are unlimited but usage is still counted. The caller must call
+// cancel.
+func withBudget(ctx context.Context, req AgentRequest) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, budgetKey{}, &budget{maxCalls: req.MaxLLMCalls, maxTokens: int64(req.MaxTokens)})
+	if req.DeadlineMs > 0 {
+		return context.WithTimeout(ctx, time.Duration(req.DeadlineMs)*time.Millisecond)
+	}
+	return context.WithCancel(ctx)
+}
+
+func budgetFrom(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+// reserve charges one LLM call, failing if none are left.
+func (b *budget) reserve() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spentLocked() {
+		return errBudgetExceeded
+	}
+	b.calls++
+	return nil
+}
+
+// charge records the tokens a call used.
+func (b *budget) charge(usage *genai.GenerateContentResponseUsageMetadata) {
+	if b == nil || usage == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens += usage.TotalTokenCount
+	b.mu.Unlock()
+}
+
+// usage returns the calls and tokens spent so far.
+func (b *budget) usage() (int, int64) {
+	if b == nil {
+		return 0, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls, b.tokens
+}
+
+func (b *budget) spentLocked() bool {
+	return (b.maxCalls > 0 && b.calls >= b.maxCalls) || (b.maxTokens > 0 && b.tokens >= b.maxTokens)
+}
+
+// budgetExceeded reports whether ctx has no budget left for another LLM
+// call, counting a passed deadline.
+func budgetExceeded(ctx context.Context) bool {
+	if ctx.Err() == context.DeadlineExceeded {
+		return true
+	}
+	b := budgetFrom(ctx)
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spentLocked()
+}
+
+// isBudgetError reports whether err came from running out of budget
+// rather than from Gemini itself.
+func isBudgetError(ctx context.Context, err error) bool {
+	return errors.Is(err, errBudgetExceeded) || ctx.Err() == context.DeadlineExceeded
+}
+
+// callsLeft returns how many LLM calls the budget still allows, or -1 when
+// calls are not limited.
+func callsLeft(ctx context.Context) int {
+	b := budgetFrom(ctx)
+	if b == nil || b.maxCalls == 0 {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.maxCalls-b.calls, 0)
+}
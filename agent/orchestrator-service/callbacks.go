@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ============================================================================
+// COMPLETION CALLBACKS
+// ============================================================================
+// A request with "callback_url" gets the outcome POSTed there once the agent
+// finishes, from /agent/query, /agent/query/stream and /agent/jobs alike, so
+// workflows that cannot poll still hear about it. Bodies are signed with
+// HMAC-SHA256 over "<timestamp>.<body>" using AGENT_CALLBACK_SECRET:
+//
+//	X-Agent-Timestamp: 1700000000
+//	X-Agent-Signature: sha256=<hex>
+//
+// Callbacks are refused unless the secret is set. AGENT_CALLBACK_ALLOWED_HOSTS,
+// a comma separated list, restricts which hosts may be called back. Like
+// pages fetched by the ingest service, callbacks never connect to loopback,
+// private or link-local addresses unless AGENT_CALLBACK_ALLOW_PRIVATE=true,
+// and redirects aren't followed: a 3xx counts as a failed delivery.
+//
+// The event is "agent.failed" when the run returned an error, timed out or
+// lost its caller, and also when its response shows it failed without
+// one, such as a synthesis failure or a budget spent before any answer
+// (see runFailure).
+
+const (
+	callbackCompleted = "agent.completed"
+	callbackFailed    = "agent.failed"
+
+	callbackAttempts = 3
+)
+
+var (
+	AGENT_CALLBACK_SECRET        = getEnv("AGENT_CALLBACK_SECRET", "")
+	AGENT_CALLBACK_ALLOWED_HOSTS = splitList(getEnv("AGENT_CALLBACK_ALLOWED_HOSTS", ""))
+	AGENT_CALLBACK_ALLOW_PRIVATE = getEnv("AGENT_CALLBACK_ALLOW_PRIVATE", "false") == "true"
+
+	// callbackClient calls hosts outside the deployment, so it has its own
+	// transport: none of the service auth or tenant headers that
+	// http.DefaultTransport adds are sent.
+	callbackClient = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: guardCallbackDial}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	callbackDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_callbacks_total",
+		Help: "Completion callbacks sent, by outcome (delivered, failed).",
+	}, []string{"outcome"})
+)
+
+// CallbackPayload - Body POSTed to a request's callback_url
+type CallbackPayload struct {
+	Event          string         `json:"event"` // "agent.completed" or "agent.failed"
+	ConversationID string         `json:"conversation_id"`
+	JobID          string         `json:"job_id,omitempty"`
+	Response       *AgentResponse `json:"response,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Timestamp      time.Time      `json:"timestamp"`
+}
+
+// validateCallbackURL checks a request's callback_url before any work is
+// done, so a bad one is a 400 rather than a silently lost callback.
+func validateCallbackURL(raw string) error {
+	if AGENT_CALLBACK_SECRET == "" {
+		return fmt.Errorf("callback_url is not supported: AGENT_CALLBACK_SECRET is not set")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http(s) URL")
+	}
+	if len(AGENT_CALLBACK_ALLOWED_HOSTS) > 0 && !contains(AGENT_CALLBACK_ALLOWED_HOSTS, u.Hostname()) {
+		return fmt.Errorf("callback_url host %q is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// guardCallbackDial refuses connections to loopback, private and link-local
+// addresses. It checks the address actually dialed, after DNS resolution,
+// so a public name pointing inside the network is caught too.
+func guardCallbackDial(network, address string, _ syscall.RawConn) error {
+	if AGENT_CALLBACK_ALLOW_PRIVATE {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to call back %s", host)
+	}
+	return nil
+}
+
+// notifyCallback reports the outcome of req to its callback_url, if it has
+// one, in the background. runErr, or a failure runFailure finds in the
+// response, marks the run as failed.
+func notifyCallback(ctx context.Context, req AgentRequest, jobID string, response AgentResponse, runErr error) {
+	if req.CallbackURL == "" {
+		return
+	}
+	if runErr == nil {
+		runErr = runFailure(response)
+	}
+
+	payload := CallbackPayload{
+		Event:          callbackCompleted,
+		ConversationID: req.ConversationID,
+		JobID:          jobID,
+		Response:       &response,
+		Timestamp:      time.Now().UTC(),
+	}
+	if runErr != nil {
+		payload.Event = callbackFailed
+		payload.Error = runErr.Error()
+	}
+
+	go deliverCallback(context.WithoutCancel(ctx), req.CallbackURL, payload)
+}
+
+// answerSteps are the step types that produce a run's answer.
+var answerSteps = map[string]bool{"synthesize": true, "answer": true, "writer": true}
+
+// runFailure tells from a finished run's response whether it failed
+// without returning an error: planning, choosing the next action or
+// writing the answer failed, the budget ran out before an answer, or every
+// action failed. Cached answers, dry runs, refusals and clarifying
+// questions are complete, and so is a degraded answer from partial
+// results, which the request asked for.
+func runFailure(response AgentResponse) error {
+	if response.Cached || response.DryRun != nil {
+		return nil
+	}
+
+	var answer, failed *AgentStep
+	for i := range response.Steps {
+		step := &response.Steps[i]
+		switch {
+		case step.Type == "guardrail" && !step.Success && strings.HasPrefix(step.Result, "blocked"):
+			return nil // the query was refused, see guardrails.go
+		case answerSteps[step.Type]:
+			answer = step
+		case !step.Success:
+			failed = step
+		}
+	}
+
+	switch {
+	case answer == nil && response.NeedMoreInfo:
+		return nil
+	case answer == nil && response.BudgetExceeded:
+		return errors.New("the budget ran out before an answer was produced")
+	case answer == nil && failed != nil:
+		reason := failed.Result
+		if reason == "" {
+			reason = failed.Description
+		}
+		return fmt.Errorf("%s step failed: %s", failed.Type, reason)
+	case answer == nil:
+		return errors.New("no answer was produced")
+	case response.Degraded:
+		return nil
+	case !answer.Success:
+		return errors.New("no answer could be generated")
+	case response.actionsRun > 0 && response.actionsFailed == response.actionsRun:
+		return fmt.Errorf("all %d actions failed", response.actionsRun)
+	}
+	return nil
+}
+
+// deliverCallback POSTs payload to callbackURL, retrying network errors,
+// 429s and 5xx responses with backoff.
+func deliverCallback(ctx context.Context, callbackURL string, payload CallbackPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode callback for %s: %v", payload.ConversationID, err)
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = postCallback(ctx, callbackURL, body)
+		if err == nil {
+			callbackDeliveries.WithLabelValues("delivered").Inc()
+			log.Printf("📨 Callback %s delivered for %s", payload.Event, payload.ConversationID)
+			return
+		}
+		if attempt == callbackAttempts || !retryableCallback(err) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 4
+	}
+
+	callbackDeliveries.WithLabelValues("failed").Inc()
+	log.Printf("❌ Callback for %s to %s failed: %v", payload.ConversationID, callbackURL, err)
+}
+
+// callbackStatusError is a non-2xx answer from a callback URL.
+type callbackStatusError struct{ status int }
+
+func (e callbackStatusError) Error() string {
+	return fmt.Sprintf("callback returned status %d", e.status)
+}
+
+func retryableCallback(err error) bool {
+	if statusErr, ok := err.(callbackStatusError); ok {
+		return statusErr.status == http.StatusTooManyRequests || statusErr.status >= 500
+	}
+	return true
+}
+
+func postCallback(ctx context.Context, callbackURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "agent-orchestrator")
+	req.Header.Set("X-Agent-Timestamp", timestamp)
+	req.Header.Set("X-Agent-Signature", "sha256="+signCallback(timestamp, body))
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return callbackStatusError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// signCallback returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func signCallback(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(AGENT_CALLBACK_SECRET))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// callbackReceiver serves a callback_url, handing each payload POSTed to
+// it to the returned channel.
+func callbackReceiver(t *testing.T) (string, <-chan CallbackPayload) {
+	t.Helper()
+	received := make(chan CallbackPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload CallbackPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decoding callback: %v", err)
+		}
+		received <- payload
+	}))
+	t.Cleanup(srv.Close)
+	callbackSettings(t, true)
+	return srv.URL, received
+}
+
+// callbackSettings sets a callback secret for the test and whether
+// callbacks may reach private addresses, such as httptest servers.
+func callbackSettings(t *testing.T, allowPrivate bool) {
+	t.Helper()
+	secret, previous := AGENT_CALLBACK_SECRET, AGENT_CALLBACK_ALLOW_PRIVATE
+	AGENT_CALLBACK_SECRET, AGENT_CALLBACK_ALLOW_PRIVATE = "test-secret", allowPrivate
+	t.Cleanup(func() { AGENT_CALLBACK_SECRET, AGENT_CALLBACK_ALLOW_PRIVATE = secret, previous })
+}
+
+func TestRunFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		response AgentResponse
+		failed   bool
+	}{
+		{
+			name: "answered",
+			response: AgentResponse{Answer: "42", Steps: []AgentStep{
+				{Type: "plan", Success: true}, {Type: "execute", Success: true}, {Type: "synthesize", Success: true},
+			}, actionsRun: 1},
+		},
+		{
+			name: "synthesis failed",
+			response: AgentResponse{Answer: "Unable to synthesize answer from available information.", Steps: []AgentStep{
+				{Type: "plan", Success: true}, {Type: "execute", Success: true}, {Type: "synthesize", Success: false},
+			}},
+			failed: true,
+		},
+		{
+			name: "synthesis failed, degraded",
+			response: AgentResponse{Answer: "[1] quoted passage", Degraded: true, Steps: []AgentStep{
+				{Type: "plan", Success: true}, {Type: "execute", Success: true}, {Type: "synthesize", Success: false},
+			}},
+		},
+		{
+			name: "plan failed",
+			response: AgentResponse{Answer: "Failed to create plan: quota", Steps: []AgentStep{
+				{Type: "plan", Result: "quota", Success: false},
+			}},
+			failed: true,
+		},
+		{
+			name:     "budget exhausted",
+			response: AgentResponse{BudgetExceeded: true, Steps: []AgentStep{{Type: "plan", Success: true}}},
+			failed:   true,
+		},
+		{
+			name: "every action failed",
+			response: AgentResponse{Answer: "I found nothing.", Steps: []AgentStep{
+				{Type: "plan", Success: true}, {Type: "execute", Success: false}, {Type: "synthesize", Success: true},
+			}, actionsRun: 2, actionsFailed: 2},
+			failed: true,
+		},
+		{
+			name: "some actions failed",
+			response: AgentResponse{Answer: "42", Steps: []AgentStep{
+				{Type: "plan", Success: true}, {Type: "execute", Success: false}, {Type: "synthesize", Success: true},
+			}, actionsRun: 2, actionsFailed: 1},
+		},
+		{
+			name:     "refused",
+			response: AgentResponse{Answer: "I can't help with that.", Steps: []AgentStep{{Type: "guardrail", Result: "blocked: pii", Success: false}}},
+		},
+		{
+			name:     "clarifying question",
+			response: AgentResponse{Answer: "Which circular?", NeedMoreInfo: true, Steps: []AgentStep{{Type: "clarify", Success: true}}},
+		},
+		{
+			name:     "cached",
+			response: AgentResponse{Answer: "42", Cached: true, Steps: []AgentStep{{Type: "cache", Success: true}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runFailure(tt.response)
+			if (err != nil) != tt.failed {
+				t.Errorf("runFailure = %v, want failed = %t", err, tt.failed)
+			}
+		})
+	}
+}
+
+func TestNotifyCallbackOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		response AgentResponse
+		runErr   error
+		event    string
+	}{
+		{
+			name:     "completed",
+			response: AgentResponse{Answer: "42", Steps: []AgentStep{{Type: "synthesize", Success: true}}},
+			event:    callbackCompleted,
+		},
+		{
+			name:     "failed synthesis",
+			response: AgentResponse{Answer: "Unable to synthesize answer from available information.", Steps: []AgentStep{{Type: "synthesize", Success: false}}},
+			event:    callbackFailed,
+		},
+		{
+			name:     "caller gone",
+			response: AgentResponse{Answer: "42", Steps: []AgentStep{{Type: "synthesize", Success: true}}},
+			runErr:   context.Canceled,
+			event:    callbackFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callbackURL, received := callbackReceiver(t)
+			notifyCallback(context.Background(), AgentRequest{ConversationID: "conv-1", CallbackURL: callbackURL}, "", tt.response, tt.runErr)
+
+			select {
+			case payload := <-received:
+				if payload.Event != tt.event {
+					t.Errorf("event = %q, want %q", payload.Event, tt.event)
+				}
+				if failed := payload.Event == callbackFailed; failed != (payload.Error != "") {
+					t.Errorf("event %q with error %q", payload.Event, payload.Error)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no callback delivered")
+			}
+		})
+	}
+}
+
+func TestGuardCallbackDial(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"127.0.0.1:443", true},
+		{"10.1.2.3:443", true},
+		{"192.168.0.10:80", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:443", true},
+		{"[fe80::1]:443", true},
+		{"0.0.0.0:80", true},
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := guardCallbackDial("tcp", tt.address, nil)
+			if (err != nil) != tt.refused {
+				t.Errorf("guardCallbackDial(%s) = %v, want refused = %t", tt.address, err, tt.refused)
+			}
+		})
+	}
+}
+
+func TestCallbackRefusesLoopback(t *testing.T) {
+	delivered := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = true
+	}))
+	defer srv.Close()
+	callbackSettings(t, false)
+
+	if err := postCallback(context.Background(), srv.URL, []byte(`{}`)); err == nil {
+		t.Fatal("callback to a loopback address was sent")
+	}
+	if delivered {
+		t.Error("loopback server received the callback")
+	}
+}
+
+func TestCallbackRefusesRedirect(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+	callbackSettings(t, true)
+
+	err := postCallback(context.Background(), srv.URL, []byte(`{}`))
+	statusErr, ok := err.(callbackStatusError)
+	if !ok || statusErr.status != http.StatusTemporaryRedirect {
+		t.Fatalf("postCallback = %v, want a %d status error", err, http.StatusTemporaryRedirect)
+	}
+	if retryableCallback(err) {
+		t.Error("a redirect is retried")
+	}
+	if redirected {
+		t.Error("the redirect was followed")
+	}
+}
+
+func TestCallbackSignature(t *testing.T) {
+	type request struct {
+		timestamp, signature string
+		body                 []byte
+	}
+	received := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{r.Header.Get("X-Agent-Timestamp"), r.Header.Get("X-Agent-Signature"), body}
+	}))
+	defer srv.Close()
+	callbackSettings(t, true)
+
+	body := []byte(`{"event":"agent.completed","conversation_id":"conv-1"}`)
+	if err := postCallback(context.Background(), srv.URL, body); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if !bytes.Equal(got.body, body) {
+		t.Fatalf("body = %s, want %s", got.body, body)
+	}
+
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte(got.timestamp + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got.signature != want {
+		t.Errorf("X-Agent-Signature = %s, want %s", got.signature, want)
+	}
+
+	mac = hmac.New(sha256.New, []byte("wrong-secret"))
+	mac.Write([]byte(got.timestamp + "." + string(body)))
+	if forged := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got.signature == forged {
+		t.Error("signature doesn't depend on the secret")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// CITATIONS
+// ============================================================================
+// Every retrieved chunk the answer may draw on is numbered, the model is
+// asked to cite the ones it uses inline as [1], [2], and the response's
+// citations map each marker that appears in the answer back to the chunk,
+// its document and its retrieval score, so an answer can be audited.
+
+// Citation - Maps an inline [n] marker in the answer to a retrieved chunk
+type Citation struct {
+	Marker int `json:"marker"`
+	Source
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// maxPassageChars bounds each passage quoted in the synthesis prompt;
+// maxSnippetChars bounds the excerpt returned with a citation.
+const (
+	maxPassageChars = 1500
+	maxSnippetChars = 300
+)
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// passage is a numbered retrieved chunk, quoted up to limit characters.
+type passage struct {
+	Citation
+	text  string
+	limit int
+}
+
+// passageSet numbers chunks in the order they are first seen; a chunk
+// retrieved twice keeps its first number.
+type passageSet struct {
+	passages []passage
+	byChunk  map[string]int
+}
+
+// addSearchResult numbers the chunks in a search_rag result and tags each
+// with its "citation" marker so a model reading the raw result can cite it.
+// Results of other actions are ignored.
+func (s *passageSet) addSearchResult(result map[string]interface{}) {
+	if result["action_type"] != "search_rag" || result["status"] == "failed" {
+		return
+	}
+	collection, _ := result["collection"].(string)
+	chunks, _ := result["results"].([]interface{})
+	limit := passageLimit(len(chunks)) // see summarize.go
+	for _, raw := range chunks {
+		chunk, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		chunk["citation"] = s.add(chunk, collection, limit)
+	}
+}
+
+func (s *passageSet) add(chunk map[string]interface{}, collection string, limit int) int {
+	id, _ := chunk["id"].(string)
+	if marker, ok := s.byChunk[id]; ok && id != "" {
+		return marker
+	}
+	if s.byChunk == nil {
+		s.byChunk = map[string]int{}
+	}
+
+	p := passage{Citation: Citation{Marker: len(s.passages) + 1, Source: sourceFromChunk(chunk, collection)}, limit: limit}
+	p.text, _ = chunk["text"].(string)
+	p.Snippet = truncate(strings.TrimSpace(p.text), maxSnippetChars)
+
+	s.passages = append(s.passages, p)
+	s.byChunk[id] = p.Marker
+	return p.Marker
+}
+
+// prompt lists the passages with their markers for the synthesis prompt.
+func (s *passageSet) prompt() string {
+	var b strings.Builder
+	for _, p := range s.passages {
+		name := p.DocumentName
+		if name == "" {
+			name = p.DocumentID
+		}
+		fmt.Fprintf(&b, "[%d] (%s) %s\n\n", p.Marker, name, truncate(strings.TrimSpace(p.text), p.limit))
+	}
+	return b.String()
+}
+
+// ============================================================================
+// SOURCES
+// ============================================================================
+
+// sourceFromChunk reads a chunk of a retrieval-service result.
+func sourceFromChunk(chunk map[string]interface{}, collection string) Source {
+	source := Source{Collection: collection}
+	source.ChunkID, _ = chunk["id"].(string)
+	source.DocumentID, _ = chunk["document_id"].(string)
+	source.DocumentName, _ = chunk["source"].(string)
+	source.Score, _ = chunk["score"].(float64)
+	return source
+}
+
+// addSources appends the chunks of a search_rag result to sources. A chunk
+// already present keeps its place with the higher of its scores.
+func addSources(sources []Source, result map[string]interface{}) []Source {
+	collection, _ := result["collection"].(string)
+	chunks, _ := result["results"].([]interface{})
+	for _, raw := range chunks {
+		chunk, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source := sourceFromChunk(chunk, collection)
+		seen := false
+		for i := range sources {
+			if sources[i].ChunkID == source.ChunkID && source.ChunkID != "" {
+				sources[i].Score = max(sources[i].Score, source.Score)
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// citationsFor returns the citations for the markers used in answer, in
+// marker order. Markers that match no passage are dropped.
+func (s *passageSet) citationsFor(answer string) []Citation {
+	used := map[int]bool{}
+	for _, m := range citationMarker.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= len(s.passages) {
+			used[n] = true
+		}
+	}
+
+	citations := make([]Citation, 0, len(used))
+	for n := range used {
+		citations = append(citations, s.passages[n-1].Citation)
+	}
+	sort.Slice(citations, func(i, j int) bool { return citations[i].Marker < citations[j].Marker })
+	return citations
+}
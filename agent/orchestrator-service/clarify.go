@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genai"
+	"shared/tenant"
+)
+
+// ============================================================================
+// PROACTIVE CLARIFICATION
+// ============================================================================
+// A query that leaves out what its answer depends on, "what documents do I
+// need for KYC?" without the kind of business or the jurisdiction, sends the
+// loop guessing for every iteration and ends with a follow-up question
+// anyway. With "clarify": true (or AGENT_CLARIFY=true) analysis_model first
+// judges whether the query is ambiguous about the merchant or entity type,
+// the jurisdiction or regulator, or the kind of document, reading it with
+// the conversation so far, the request's context and what user memory
+// holds. An ambiguous query is answered at once with need_more_info and a
+// clarifying question, before any plan or search; the run is suspended like
+// any that asks, so a follow_up_answer resumes it with the clarified query,
+// in the request's mode (see resume.go). Resumed runs, supplied plans and
+// dry runs are not checked, and a check that fails lets the query through.
+
+// AGENT_CLARIFY is the default of a request's clarify; nil is off.
+var AGENT_CLARIFY = envBool("AGENT_CLARIFY")
+
+var clarifications = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_clarifications_total",
+	Help: "Queries checked for ambiguity before planning, by outcome (asked, clear, failed).",
+}, []string{"outcome"})
+
+// clarifying reports whether req is checked for ambiguity.
+func clarifying(req AgentRequest) bool {
+	return req.Clarify != nil && *req.Clarify
+}
+
+// judgeQueryConfig forces the model to respond with its judgement.
+var judgeQueryConfig = &genai.GenerateContentConfig{
+	Tools: []*genai.Tool{{
+		FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name:        "judge_query",
+			Description: "Report whether the query can be answered as asked, and if not, the question to ask the user.",
+			Parameters: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"ambiguous": {Type: genai.TypeBoolean, Description: "Whether the answer depends on something the query leaves out"},
+					"missing": {
+						Type:        genai.TypeArray,
+						Description: "What the query leaves out, e.g. merchant type, jurisdiction, document type",
+						Items:       &genai.Schema{Type: genai.TypeString},
+					},
+					"question": {Type: genai.TypeString, Description: "One short question asking the user for everything missing"},
+				},
+				Required: []string{"ambiguous"},
+			},
+		}},
+	}},
+	ToolConfig: &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+	},
+}
+
+// clarifyQuery checks req's query for ambiguity and records the clarify
+// step. When the query is ambiguous it fills response with the clarifying
+// question and returns true.
+func clarifyQuery(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) bool {
+	start := time.Now()
+	prompt := fmt.Sprintf(`You screen questions for a compliance assistant that searches
+regulatory, KYC and merchant documents.
+
+Query: "%s"
+
+Decide whether the right answer depends on something the query leaves out
+and can't be inferred: the type of merchant or entity (bank, payment
+aggregator, NBFC, marketplace, individual...), the jurisdiction or
+regulator, or the kind of document (circular, master direction, policy,
+form...). A query that is answerable as asked, or whose answer is the same
+whatever the missing detail, is not ambiguous. Don't ask about details
+that only refine an answer. When it is ambiguous, write one short question
+asking for everything that is missing.`, req.Query)
+	if len(req.Context) > 0 {
+		prompt += fmt.Sprintf("\n\nAdditional context: %v", req.Context)
+	}
+	if facts := userMemoryFrom(ctx); len(facts) > 0 {
+		prompt += "\n\nWhat you know about the user from earlier conversations:"
+		for _, fact := range facts {
+			prompt += "\n- " + fact.Fact
+		}
+	}
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+	prompt = withHistory(prompt, history,
+		"The query may be a follow-up: what the conversation already settled is not missing.")
+
+	resp, model, err := generateContent(ctx, req.AnalysisModel, genai.Text(prompt), judgeQueryConfig)
+	var calls []*genai.FunctionCall
+	if err == nil {
+		if calls = resp.FunctionCalls(); len(calls) == 0 {
+			err = fmt.Errorf("no judgement returned")
+		}
+	}
+	if err != nil {
+		log.Printf("  ❓ Clarification check failed: %v", err)
+		clarifications.WithLabelValues("failed").Inc()
+		recordStep(response, prog, AgentStep{
+			Type:        "clarify",
+			Description: "Check the query for ambiguity",
+			Result:      err.Error(),
+			Success:     false,
+			Duration:    float64(time.Since(start).Milliseconds()),
+			Model:       model,
+		})
+		return false
+	}
+
+	ambiguous, _ := calls[0].Args["ambiguous"].(bool)
+	question, _ := calls[0].Args["question"].(string)
+	var missing []string
+	raw, _ := calls[0].Args["missing"].([]interface{})
+	for _, r := range raw {
+		if m, _ := r.(string); strings.TrimSpace(m) != "" {
+			missing = append(missing, strings.TrimSpace(m))
+		}
+	}
+	question = strings.TrimSpace(question)
+	if question == "" && len(missing) > 0 {
+		question = "Could you tell me the " + strings.Join(missing, ", ") + " your question is about?"
+	}
+
+	step := AgentStep{
+		Type:        "clarify",
+		Description: "Check the query for ambiguity",
+		Result:      "Specific enough to answer",
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+		Model:       model,
+	}
+	if !ambiguous || question == "" {
+		clarifications.WithLabelValues("clear").Inc()
+		recordStep(response, prog, step)
+		return false
+	}
+
+	log.Printf("  ❓ Ambiguous query, asking: %s", question)
+	clarifications.WithLabelValues("asked").Inc()
+	step.Result = "Ambiguous, asked: " + question
+	if len(missing) > 0 {
+		step.Result = fmt.Sprintf("Ambiguous (missing %s), asked: %s", strings.Join(missing, ", "), question)
+	}
+	recordStep(response, prog, step)
+	response.Answer = question
+	response.NeedMoreInfo = true
+	response.FollowUpQ = question
+	response.missingInfo = strings.Join(missing, ", ")
+	return true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// SELF-CONSISTENCY
+// ============================================================================
+// With "candidates": N (2 to 5) the answer is written N times from the same
+// evidence, at temperatures spread from 0.2 to 1.0, and a judging pass by
+// verifier_model picks the best candidate, or merges them when that beats
+// any one of them, and rates how far the candidates agree in substance. An
+// answer the model gives the same way every time is more likely right, so
+// the agreement is returned in the response and averaged into the
+// confidence. It applies wherever an answer is synthesized: the pipeline,
+// /agent/execute and the multi_agent writer. ReAct mode answers from its
+// own loop and ignores it. A streaming client gets the judged answer as one
+// token.
+
+// maxCandidates bounds the candidates a request may ask for.
+const maxCandidates = 5
+
+// Temperatures the candidates are spread over.
+const (
+	minCandidateTemperature = 0.2
+	maxCandidateTemperature = 1.0
+)
+
+// judgeConfig forces the judge to respond with its verdict.
+var judgeConfig = &genai.GenerateContentConfig{
+	Tools: []*genai.Tool{{
+		FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name:        "judge_answers",
+			Description: "Pick the best candidate answer, or merge them, and rate how far they agree.",
+			Parameters: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"reasoning":     {Type: genai.TypeString, Description: "Why the chosen or merged answer is best"},
+					"best":          {Type: genai.TypeInteger, Description: "Number of the best candidate"},
+					"agreement":     {Type: genai.TypeNumber, Description: "How far the candidates agree in substance, from 0 (contradict each other) to 1 (say the same)"},
+					"merged_answer": {Type: genai.TypeString, Description: "Only when combining candidates beats every one of them: the merged answer, keeping their citation markers"},
+				},
+				Required: []string{"reasoning", "best", "agreement"},
+			},
+		}},
+	}},
+	ToolConfig: &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+	},
+}
+
+// consensus is the judge's verdict on the candidate answers.
+type consensus struct {
+	Candidates int
+	Chosen     int // 1-based; 0 when the candidates were merged
+	Agreement  float64
+	Reasoning  string
+	Model      string
+	Duration   time.Duration
+
+	merged string // the merged answer when Chosen is 0
+}
+
+// blend averages the agreement into a confidence score. A nil consensus
+// leaves it as is.
+func (c *consensus) blend(confidence float64) float64 {
+	if c == nil {
+		return confidence
+	}
+	return (confidence + c.Agreement) / 2
+}
+
+// step is the judge step for the trace.
+func (c *consensus) step() AgentStep {
+	result := fmt.Sprintf("Chose candidate %d, agreement %.2f: %s", c.Chosen, c.Agreement, c.Reasoning)
+	if c.Chosen == 0 {
+		result = fmt.Sprintf("Merged the candidates, agreement %.2f: %s", c.Agreement, c.Reasoning)
+	}
+	return AgentStep{
+		Type:        "judge",
+		Description: fmt.Sprintf("Judge %d candidate answers", c.Candidates),
+		Result:      result,
+		Success:     true,
+		Duration:    float64(c.Duration.Milliseconds()),
+		Model:       c.Model,
+	}
+}
+
+// synthesize writes req's answer from results, by self-consistency when req
+// asks for candidates and as JSON when it has a response_schema (see
+// structured.go), and returns it with the model that wrote it and its
+// citations. The consensus is nil when a single answer was written or the
+// candidates could not be judged.
+func synthesize(ctx context.Context, req AgentRequest, history string, results []map[string]interface{}, onToken func(string)) (string, string, []Citation, *consensus) {
+	if req.Candidates < 2 && req.schema == nil {
+		answer, model, citations := synthesizeAnswer(ctx, req.SynthesisModel, req.Query, history, results, onToken)
+		return answer, model, citations, nil
+	}
+
+	contextStr, passages := gatheredContext(results)
+	prompt := synthesisPrompt(ctx, req.Query, history, contextStr)
+	if req.schema != nil {
+		prompt += "\n\nAnswer with JSON matching the response schema, with the citation markers inside its string values."
+	}
+	candidates, model := writeCandidates(ctx, req.SynthesisModel, prompt, max(req.Candidates, 1), req.schema)
+	if len(candidates) == 0 {
+		return "Unable to synthesize answer from available information.", model, []Citation{}, nil
+	}
+
+	answer := candidates[0]
+	var verdict *consensus
+	if len(candidates) > 1 && !budgetExceeded(ctx) {
+		var err error
+		verdict, err = judgeCandidates(ctx, req.VerifierModel, req.Query, contextStr, candidates, req.schema != nil)
+		if err != nil {
+			log.Printf("Judging candidates failed: %v", err)
+			verdict = nil
+		} else if verdict.Chosen == 0 {
+			answer = verdict.merged
+		} else {
+			answer = candidates[verdict.Chosen-1]
+		}
+	}
+	if req.schema != nil {
+		answer = repairOutput(ctx, req, answer)
+	}
+
+	if onToken != nil {
+		streamed := answer
+		if guardrailsFlag.Enabled() {
+			streamed, _ = redactPII(answer)
+		}
+		onToken(streamed)
+	}
+	return answer, model, passages.citationsFor(answer), verdict
+}
+
+// writeCandidates runs the synthesis prompt n times at once, each at its own
+// temperature when n > 1 and constrained to schema when it is non-nil, and
+// returns the answers that came back, in temperature order, with the model
+// that wrote the first.
+func writeCandidates(ctx context.Context, modelName, prompt string, n int, schema *genai.Schema) ([]string, string) {
+	answers := make([]string, n)
+	models := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		config := &genai.GenerateContentConfig{}
+		if schema != nil {
+			config = structuredConfig(schema)
+		}
+		if n > 1 {
+			temperature := minCandidateTemperature + (maxCandidateTemperature-minCandidateTemperature)*float64(i)/float64(n-1)
+			config.Temperature = &temperature
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), config)
+			if err != nil {
+				log.Printf("Candidate %d failed: %v", i+1, err)
+				return
+			}
+			answers[i] = responseText(resp)
+			models[i] = model
+		}()
+	}
+	wg.Wait()
+
+	var written []string
+	model := ""
+	for i, answer := range answers {
+		if answer == "" {
+			continue
+		}
+		if model == "" {
+			model = models[i]
+		}
+		written = append(written, answer)
+	}
+	if n > 1 {
+		log.Printf("    ✓ Wrote %d of %d candidate answers", len(written), n)
+	}
+	return written, model
+}
+
+// judgeCandidates asks the judge to choose among or merge candidates, which
+// are JSON when structured is set.
+func judgeCandidates(ctx context.Context, modelName, query, contextStr string, candidates []string, structured bool) (*consensus, error) {
+	start := time.Now()
+	prompt := fmt.Sprintf(`Several candidate answers were written independently to the same question
+from the same information. Judge them.
+
+Question: "%s"
+
+%s
+`, query, contextStr)
+	for i, candidate := range candidates {
+		prompt += fmt.Sprintf("Candidate %d:\n%s\n\n", i+1, candidate)
+	}
+	prompt += `Prefer the candidate that answers the question most completely and
+accurately, with every claim supported by a cited passage. Rate how far the
+candidates agree on the facts and conclusions, ignoring wording.`
+	if structured {
+		prompt += " The candidates are JSON: a merged answer must be JSON of the same shape."
+	}
+
+	resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), judgeConfig)
+	if err != nil {
+		return nil, err
+	}
+	calls := resp.FunctionCalls()
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("judge returned no verdict")
+	}
+
+	args := calls[0].Args
+	verdict := &consensus{Candidates: len(candidates), Model: model}
+	verdict.Reasoning, _ = args["reasoning"].(string)
+	agreement, _ := args["agreement"].(float64)
+	verdict.Agreement = min(max(agreement, 0), 1)
+	best, _ := args["best"].(float64)
+	verdict.Chosen = int(best)
+	if merged, _ := args["merged_answer"].(string); merged != "" {
+		verdict.Chosen, verdict.merged = 0, merged
+	} else if verdict.Chosen < 1 || verdict.Chosen > len(candidates) {
+		verdict.Chosen = 1
+	}
+	verdict.Duration = time.Since(start)
+	return verdict, nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// CONVERSATION STORE
+// ============================================================================
+// Conversation history lives behind conversationStore so it can outlive the
+// process and be shared by several orchestrator replicas:
+//
+//	CONVERSATION_STORE      memory (default), sqlite or redis
+//	CONVERSATION_DB_PATH    SQLite file (default ./data/conversations.db)
+//	CONVERSATION_REDIS_URL  Redis URL (default REDIS_URL)
+//	CONVERSATION_TTL        idle time before a conversation expires (default 168h, 0 keeps forever)
+//
+// Expiry is sliding: every new turn restarts the TTL.
+
+// conversationStore persists conversation turns, scoped by tenant.
+type conversationStore interface {
+	// Append adds messages to a conversation, creating it if needed.
+	Append(ctx context.Context, tenantID, conversationID string, messages ...Message) error
+	// Get returns a conversation, or nil if it does not exist or has expired.
+	Get(ctx context.Context, tenantID, conversationID string) (*Conversation, error)
+	// List returns up to limit conversations, most recently updated first.
+	List(ctx context.Context, tenantID string, limit int) ([]ConversationSummary, error)
+	// SetPersona replaces a conversation's persona, creating it if needed;
+	// an empty persona clears it.
+	SetPersona(ctx context.Context, tenantID, conversationID, persona string) error
+
+	// User memory, kept with the conversations but never expired; see
+	// usermemory.go. Remember adds facts to a user's memory, dropping the
+	// oldest beyond limit; Recall returns them oldest first; Forget clears
+	// them.
+	Remember(ctx context.Context, tenantID, userID string, limit int, facts ...MemoryFact) error
+	Recall(ctx context.Context, tenantID, userID string) ([]MemoryFact, error)
+	Forget(ctx context.Context, tenantID, userID string) error
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// newConversationStore builds the backend selected by CONVERSATION_STORE.
+func newConversationStore() (conversationStore, error) {
+	ttl, err := time.ParseDuration(getEnv("CONVERSATION_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONVERSATION_TTL: %w", err)
+	}
+
+	switch backend := getEnv("CONVERSATION_STORE", "memory"); backend {
+	case "memory":
+		return newMemoryConversationStore(ttl), nil
+	case "sqlite":
+		return newSQLiteConversationStore(getEnv("CONVERSATION_DB_PATH", "./data/conversations.db"), ttl)
+	case "redis":
+		url := getEnv("CONVERSATION_REDIS_URL", getEnv("REDIS_URL", ""))
+		if url == "" {
+			return nil, fmt.Errorf("CONVERSATION_STORE=redis needs CONVERSATION_REDIS_URL or REDIS_URL")
+		}
+		return newRedisConversationStore(url, ttl)
+	default:
+		return nil, fmt.Errorf("unknown CONVERSATION_STORE %q (want memory, sqlite or redis)", backend)
+	}
+}
+
+// storeConversation records one query/answer turn.
+func storeConversation(ctx context.Context, tenantID, conversationID, query string, response AgentResponse) error {
+	now := time.Now()
+	return conversations.Append(ctx, tenantID, conversationID,
+		Message{Role: "user", Content: query, Timestamp: now},
+		Message{
+			Role:      "assistant",
+			Content:   response.Answer,
+			Timestamp: now,
+			Steps:     response.Steps,
+			ToolsUsed: response.ToolsUsed,
+			Citations: response.Citations,
+		},
+	)
+}
+
+// AGENT_HISTORY_TURNS is how many earlier turns of the conversation are
+// included in prompts so follow-up questions keep their context; 0 disables.
+var AGENT_HISTORY_TURNS = envInt("AGENT_HISTORY_TURNS", 5)
+
+// maxHistoryMessageChars bounds each message quoted from the history.
+const maxHistoryMessageChars = 1000
+
+// recentHistory formats the last AGENT_HISTORY_TURNS turns of a conversation
+// for a prompt. It is empty for a new conversation or when the store fails;
+// a missing history only costs context, so it never fails the query.
+func recentHistory(ctx context.Context, tenantID, conversationID string) string {
+	if AGENT_HISTORY_TURNS <= 0 || conversationID == "" {
+		return ""
+	}
+	conv, err := conversations.Get(ctx, tenantID, conversationID)
+	if err != nil {
+		log.Printf("Failed to load history for %s: %v", conversationID, err)
+		return ""
+	}
+	if conv == nil || len(conv.Messages) == 0 {
+		return ""
+	}
+
+	messages := conv.Messages
+	if n := AGENT_HISTORY_TURNS * 2; len(messages) > n {
+		messages = messages[len(messages)-n:]
+	}
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n")
+	for _, msg := range messages {
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, truncate(msg.Content, maxHistoryMessageChars))
+	}
+	return b.String()
+}
+
+// withHistory appends history and an instruction on how to use it to
+// prompt; it returns prompt unchanged when there is no history.
+func withHistory(prompt, history, instruction string) string {
+	if history == "" {
+		return prompt
+	}
+	return prompt + "\n\n" + history + "\n" + instruction
+}
+
+// summarize builds the listing entry for conv.
+func summarize(conv *Conversation) ConversationSummary {
+	summary := ConversationSummary{
+		ID:        conv.ID,
+		StartTime: conv.StartTime,
+		UpdatedAt: conv.StartTime,
+		Turns:     len(conv.Messages) / 2,
+	}
+	for _, msg := range conv.Messages {
+		if msg.Role == "user" {
+			summary.LastQuery = msg.Content
+		}
+		summary.UpdatedAt = msg.Timestamp
+	}
+	return summary
+}
+
+// ============================================================================
+// IN-MEMORY BACKEND
+// ============================================================================
+
+// memoryConversationStore keeps conversations in process; they are lost on
+// restart and not shared between replicas. Expired conversations are
+// dropped by a background sweep.
+type memoryConversationStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*Conversation // keyed by conversationKey
+	memories      map[string][]MemoryFact  // keyed by conversationKey of the user ID
+	ttl           time.Duration
+	stop          chan struct{}
+}
+
+func newMemoryConversationStore(ttl time.Duration) *memoryConversationStore {
+	s := &memoryConversationStore{
+		conversations: make(map[string]*Conversation),
+		memories:      make(map[string][]MemoryFact),
+		ttl:           ttl,
+		stop:          make(chan struct{}),
+	}
+	if ttl > 0 {
+		go s.sweep()
+	}
+	return s
+}
+
+// conversationKey scopes conversation IDs to a tenant so one tenant can
+// neither read nor append to another's history.
+func conversationKey(tenantID, conversationID string) string {
+	return tenantID + "/" + conversationID
+}
+
+func (s *memoryConversationStore) Append(_ context.Context, tenantID, conversationID string, messages ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversationLocked(tenantID, conversationID)
+	conv.Messages = append(conv.Messages, messages...)
+	return nil
+}
+
+func (s *memoryConversationStore) SetPersona(_ context.Context, tenantID, conversationID, persona string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversationLocked(tenantID, conversationID).Persona = persona
+	return nil
+}
+
+// conversationLocked returns a conversation, starting it over if it is
+// missing or expired. s.mu must be held.
+func (s *memoryConversationStore) conversationLocked(tenantID, conversationID string) *Conversation {
+	key := conversationKey(tenantID, conversationID)
+	conv, exists := s.conversations[key]
+	if !exists || s.expired(conv, time.Now()) {
+		conv = &Conversation{
+			ID:        conversationID,
+			TenantID:  tenantID,
+			Messages:  []Message{},
+			StartTime: time.Now(),
+		}
+		s.conversations[key] = conv
+	}
+	return conv
+}
+
+func (s *memoryConversationStore) Get(_ context.Context, tenantID, conversationID string) (*Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, exists := s.conversations[conversationKey(tenantID, conversationID)]
+	if !exists || s.expired(conv, time.Now()) {
+		return nil, nil
+	}
+	copied := *conv
+	copied.Messages = append([]Message(nil), conv.Messages...)
+	return &copied, nil
+}
+
+func (s *memoryConversationStore) List(_ context.Context, tenantID string, limit int) ([]ConversationSummary, error) {
+	s.mu.RLock()
+	now := time.Now()
+	summaries := make([]ConversationSummary, 0)
+	for _, conv := range s.conversations {
+		if conv.TenantID != tenantID || s.expired(conv, now) {
+			continue
+		}
+		summaries = append(summaries, summarize(conv))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
+
+func (s *memoryConversationStore) Remember(_ context.Context, tenantID, userID string, limit int, facts ...MemoryFact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := conversationKey(tenantID, userID)
+	memory := append(s.memories[key], facts...)
+	if len(memory) > limit {
+		memory = append([]MemoryFact(nil), memory[len(memory)-limit:]...)
+	}
+	s.memories[key] = memory
+	return nil
+}
+
+func (s *memoryConversationStore) Recall(_ context.Context, tenantID, userID string) ([]MemoryFact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]MemoryFact(nil), s.memories[conversationKey(tenantID, userID)]...), nil
+}
+
+func (s *memoryConversationStore) Forget(_ context.Context, tenantID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.memories, conversationKey(tenantID, userID))
+	return nil
+}
+
+func (s *memoryConversationStore) expired(conv *Conversation, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(summarize(conv).UpdatedAt) > s.ttl
+}
+
+func (s *memoryConversationStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, conv := range s.conversations {
+				if s.expired(conv, now) {
+					delete(s.conversations, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *memoryConversationStore) Ping(context.Context) error { return nil }
+
+func (s *memoryConversationStore) Close() error {
+	close(s.stop)
+	return nil
+}
+
+// lastUserQuery returns the content of the last user message, if any.
+func lastUserQuery(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// countTurns counts user messages, one per query.
+func countTurns(messages []Message) int {
+	turns := 0
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			turns++
+		}
+	}
+	return turns
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisConversationStore shares conversations between replicas. Each
+// conversation is a hash of summary fields plus a list of JSON messages;
+// a per-tenant sorted set scored by update time backs the listing. All
+// keys carry the TTL, refreshed on every append.
+type redisConversationStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisConversationStore(url string, ttl time.Duration) (*redisConversationStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisConversationStore{client: redis.NewClient(opts), ttl: ttl}, nil
+}
+
+func (s *redisConversationStore) keys(tenantID, conversationID string) (meta, messages string) {
+	base := "conversation:" + conversationKey(tenantID, conversationID)
+	return base, base + ":messages"
+}
+
+func (s *redisConversationStore) indexKey(tenantID string) string {
+	return "conversations:" + tenantID
+}
+
+func (s *redisConversationStore) Append(ctx context.Context, tenantID, conversationID string, messages ...Message) error {
+	metaKey, messagesKey := s.keys(tenantID, conversationID)
+	indexKey := s.indexKey(tenantID)
+
+	encoded := make([]interface{}, len(messages))
+	for i, msg := range messages {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		encoded[i] = b
+	}
+
+	now := time.Now()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, metaKey, "start_time", now.UTC().Format(time.RFC3339Nano))
+		pipe.HSet(ctx, metaKey, "updated_at", now.UTC().Format(time.RFC3339Nano))
+		if query := lastUserQuery(messages); query != "" {
+			pipe.HSet(ctx, metaKey, "last_query", query)
+			pipe.HIncrBy(ctx, metaKey, "turns", int64(countTurns(messages)))
+		}
+		pipe.RPush(ctx, messagesKey, encoded...)
+		pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(now.UnixMilli()), Member: conversationID})
+		if s.ttl > 0 {
+			pipe.Expire(ctx, metaKey, s.ttl)
+			pipe.Expire(ctx, messagesKey, s.ttl)
+			pipe.Expire(ctx, indexKey, s.ttl)
+			pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(now.Add(-s.ttl).UnixMilli(), 10))
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisConversationStore) SetPersona(ctx context.Context, tenantID, conversationID, persona string) error {
+	metaKey, messagesKey := s.keys(tenantID, conversationID)
+	indexKey := s.indexKey(tenantID)
+
+	now := time.Now()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, metaKey, "start_time", now.UTC().Format(time.RFC3339Nano))
+		pipe.HSetNX(ctx, metaKey, "updated_at", now.UTC().Format(time.RFC3339Nano))
+		pipe.HSet(ctx, metaKey, "persona", persona)
+		pipe.ZAddNX(ctx, indexKey, redis.Z{Score: float64(now.UnixMilli()), Member: conversationID})
+		if s.ttl > 0 {
+			pipe.Expire(ctx, metaKey, s.ttl)
+			pipe.Expire(ctx, messagesKey, s.ttl)
+			pipe.Expire(ctx, indexKey, s.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisConversationStore) Get(ctx context.Context, tenantID, conversationID string) (*Conversation, error) {
+	metaKey, messagesKey := s.keys(tenantID, conversationID)
+
+	pipe := s.client.Pipeline()
+	startCmd := pipe.HGet(ctx, metaKey, "start_time")
+	personaCmd := pipe.HGet(ctx, metaKey, "persona")
+	messagesCmd := pipe.LRange(ctx, messagesKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	start, err := startCmd.Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conv := &Conversation{ID: conversationID, TenantID: tenantID, Messages: []Message{}}
+	conv.StartTime, _ = time.Parse(time.RFC3339Nano, start)
+	conv.Persona = personaCmd.Val()
+	for _, raw := range messagesCmd.Val() {
+		var msg Message
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			return nil, err
+		}
+		conv.Messages = append(conv.Messages, msg)
+	}
+	return conv, nil
+}
+
+func (s *redisConversationStore) List(ctx context.Context, tenantID string, limit int) ([]ConversationSummary, error) {
+	min := "-inf"
+	if s.ttl > 0 {
+		min = strconv.FormatInt(time.Now().Add(-s.ttl).UnixMilli(), 10)
+	}
+	ids, err := s.client.ZRevRangeByScore(ctx, s.indexKey(tenantID), &redis.ZRangeBy{
+		Min: min, Max: "+inf", Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		metaKey, _ := s.keys(tenantID, id)
+		cmds[i] = pipe.HGetAll(ctx, metaKey)
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	summaries := make([]ConversationSummary, 0, len(ids))
+	for i, id := range ids {
+		fields := cmds[i].Val()
+		if len(fields) == 0 {
+			continue // expired between the two reads
+		}
+		summary := ConversationSummary{ID: id, LastQuery: fields["last_query"]}
+		summary.StartTime, _ = time.Parse(time.RFC3339Nano, fields["start_time"])
+		summary.UpdatedAt, _ = time.Parse(time.RFC3339Nano, fields["updated_at"])
+		summary.Turns, _ = strconv.Atoi(fields["turns"])
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// memoryKey is the list of a user's remembered facts, as JSON. It has no
+// TTL: the memory is kept until it is forgotten.
+func (s *redisConversationStore) memoryKey(tenantID, userID string) string {
+	return "user_memory:" + conversationKey(tenantID, userID)
+}
+
+func (s *redisConversationStore) Remember(ctx context.Context, tenantID, userID string, limit int, facts ...MemoryFact) error {
+	encoded := make([]interface{}, len(facts))
+	for i, fact := range facts {
+		b, err := json.Marshal(fact)
+		if err != nil {
+			return err
+		}
+		encoded[i] = b
+	}
+
+	key := s.memoryKey(tenantID, userID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, encoded...)
+		pipe.LTrim(ctx, key, int64(-limit), -1)
+		return nil
+	})
+	return err
+}
+
+func (s *redisConversationStore) Recall(ctx context.Context, tenantID, userID string) ([]MemoryFact, error) {
+	raws, err := s.client.LRange(ctx, s.memoryKey(tenantID, userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	facts := make([]MemoryFact, 0, len(raws))
+	for _, raw := range raws {
+		var fact MemoryFact
+		if err := json.Unmarshal([]byte(raw), &fact); err != nil {
+			return nil, err
+		}
+		facts = append(facts, fact)
+	}
+	return facts, nil
+}
+
+func (s *redisConversationStore) Forget(ctx context.Context, tenantID, userID string) error {
+	return s.client.Del(ctx, s.memoryKey(tenantID, userID)).Err()
+}
+
+func (s *redisConversationStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *redisConversationStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteConversationStore keeps conversations in a SQLite file so they
+// survive restarts. Replicas can share it only through a shared volume;
+// use the Redis backend for a horizontally scaled deployment.
+type sqliteConversationStore struct {
+	db   *sql.DB
+	ttl  time.Duration
+	stop chan struct{}
+}
+
+func newSQLiteConversationStore(path string, ttl time.Duration) (*sqliteConversationStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS conversations (
+		tenant_id TEXT NOT NULL,
+		id TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		turns INTEGER NOT NULL DEFAULT 0,
+		last_query TEXT,
+		persona TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant_id, id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(tenant_id, updated_at);
+	CREATE TABLE IF NOT EXISTS conversation_messages (
+		tenant_id TEXT NOT NULL,
+		conversation_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL,
+		timestamp DATETIME NOT NULL,
+		steps TEXT,
+		tools_used TEXT,
+		citations TEXT,
+		PRIMARY KEY (tenant_id, conversation_id, seq)
+	);
+	CREATE TABLE IF NOT EXISTS user_memories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		fact TEXT NOT NULL,
+		conversation_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_memories_user ON user_memories(tenant_id, user_id, id);`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize conversation schema: %w", err)
+	}
+	// Databases created before personas lack the column
+	if err := addColumnIfMissing(db, "conversations", "persona", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate conversation schema: %w", err)
+	}
+	// and before exports, the tools and citations of each answer
+	for _, column := range []string{"tools_used", "citations"} {
+		if err := addColumnIfMissing(db, "conversation_messages", column, "TEXT"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate conversation schema: %w", err)
+		}
+	}
+
+	s := &sqliteConversationStore{db: db, ttl: ttl, stop: make(chan struct{})}
+	if ttl > 0 {
+		go s.sweep()
+	}
+	return s, nil
+}
+
+func (s *sqliteConversationStore) Append(ctx context.Context, tenantID, conversationID string, messages ...Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if s.ttl > 0 {
+		// An expired conversation that has not been swept yet starts over.
+		if err := deleteConversations(ctx, tx,
+			"tenant_id = ? AND id = ? AND updated_at < ?", tenantID, conversationID, now.Add(-s.ttl)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO conversations (tenant_id, id, start_time, updated_at, turns, last_query)
+		VALUES (?, ?, ?, ?, 0, '')
+		ON CONFLICT (tenant_id, id) DO NOTHING`,
+		tenantID, conversationID, now, now); err != nil {
+		return err
+	}
+
+	var seq int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(seq), 0) FROM conversation_messages
+		WHERE tenant_id = ? AND conversation_id = ?`,
+		tenantID, conversationID).Scan(&seq); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		seq++
+		steps, err := json.Marshal(msg.Steps)
+		if err != nil {
+			return err
+		}
+		tools, err := json.Marshal(msg.ToolsUsed)
+		if err != nil {
+			return err
+		}
+		citations, err := json.Marshal(msg.Citations)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO conversation_messages (tenant_id, conversation_id, seq, role, content, timestamp, steps, tools_used, citations)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tenantID, conversationID, seq, msg.Role, msg.Content, msg.Timestamp.UTC(), string(steps), string(tools), string(citations)); err != nil {
+			return err
+		}
+	}
+
+	if query := lastUserQuery(messages); query != "" {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE conversations SET updated_at = ?, turns = turns + ?, last_query = ?
+			WHERE tenant_id = ? AND id = ?`,
+			now, countTurns(messages), query, tenantID, conversationID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE conversations SET updated_at = ? WHERE tenant_id = ? AND id = ?`,
+			now, tenantID, conversationID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteConversationStore) SetPersona(ctx context.Context, tenantID, conversationID, persona string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if s.ttl > 0 {
+		if err := deleteConversations(ctx, tx,
+			"tenant_id = ? AND id = ? AND updated_at < ?", tenantID, conversationID, now.Add(-s.ttl)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO conversations (tenant_id, id, start_time, updated_at, turns, last_query, persona)
+		VALUES (?, ?, ?, ?, 0, '', ?)
+		ON CONFLICT (tenant_id, id) DO UPDATE SET persona = excluded.persona`,
+		tenantID, conversationID, now, now, persona); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteConversationStore) Get(ctx context.Context, tenantID, conversationID string) (*Conversation, error) {
+	conv := &Conversation{ID: conversationID, TenantID: tenantID, Messages: []Message{}}
+	var updatedAt time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT start_time, updated_at, persona FROM conversations WHERE tenant_id = ? AND id = ?`,
+		tenantID, conversationID).Scan(&conv.StartTime, &updatedAt, &conv.Persona)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.ttl > 0 && time.Since(updatedAt) > s.ttl {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT role, content, timestamp, steps, tools_used, citations FROM conversation_messages
+		WHERE tenant_id = ? AND conversation_id = ? ORDER BY seq`,
+		tenantID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg Message
+		var steps, tools, citations sql.NullString
+		if err := rows.Scan(&msg.Role, &msg.Content, &msg.Timestamp, &steps, &tools, &citations); err != nil {
+			return nil, err
+		}
+		for _, column := range []struct {
+			value sql.NullString
+			into  interface{}
+		}{{steps, &msg.Steps}, {tools, &msg.ToolsUsed}, {citations, &msg.Citations}} {
+			if column.value.Valid && column.value.String != "" {
+				if err := json.Unmarshal([]byte(column.value.String), column.into); err != nil {
+					return nil, err
+				}
+			}
+		}
+		conv.Messages = append(conv.Messages, msg)
+	}
+	return conv, rows.Err()
+}
+
+func (s *sqliteConversationStore) List(ctx context.Context, tenantID string, limit int) ([]ConversationSummary, error) {
+	cutoff := time.Time{}
+	if s.ttl > 0 {
+		cutoff = time.Now().UTC().Add(-s.ttl)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, start_time, updated_at, turns, COALESCE(last_query, '') FROM conversations
+		WHERE tenant_id = ? AND updated_at >= ?
+		ORDER BY updated_at DESC LIMIT ?`,
+		tenantID, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make([]ConversationSummary, 0)
+	for rows.Next() {
+		var summary ConversationSummary
+		if err := rows.Scan(&summary.ID, &summary.StartTime, &summary.UpdatedAt, &summary.Turns, &summary.LastQuery); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+func (s *sqliteConversationStore) Remember(ctx context.Context, tenantID, userID string, limit int, facts ...MemoryFact) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, fact := range facts {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_memories (tenant_id, user_id, fact, conversation_id, created_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			tenantID, userID, fact.Fact, fact.ConversationID, fact.CreatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM user_memories WHERE tenant_id = ? AND user_id = ? AND id NOT IN (
+			SELECT id FROM user_memories WHERE tenant_id = ? AND user_id = ? ORDER BY id DESC LIMIT ?)`,
+		tenantID, userID, tenantID, userID, limit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteConversationStore) Recall(ctx context.Context, tenantID, userID string) ([]MemoryFact, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT fact, conversation_id, created_at FROM user_memories
+		WHERE tenant_id = ? AND user_id = ? ORDER BY id`,
+		tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facts []MemoryFact
+	for rows.Next() {
+		var fact MemoryFact
+		if err := rows.Scan(&fact.Fact, &fact.ConversationID, &fact.CreatedAt); err != nil {
+			return nil, err
+		}
+		facts = append(facts, fact)
+	}
+	return facts, rows.Err()
+}
+
+func (s *sqliteConversationStore) Forget(ctx context.Context, tenantID, userID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM user_memories WHERE tenant_id = ? AND user_id = ?`,
+		tenantID, userID)
+	return err
+}
+
+func (s *sqliteConversationStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := deleteConversations(ctx, s.db, "updated_at < ?", now.UTC().Add(-s.ttl)); err != nil {
+				log.Printf("⚠️  Failed to expire conversations: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// deleteConversations removes the conversations matching where, together
+// with their messages.
+func deleteConversations(ctx context.Context, db execer, where string, args ...interface{}) error {
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM conversation_messages WHERE (tenant_id, conversation_id) IN (
+			SELECT tenant_id, id FROM conversations WHERE `+where+`)`, args...); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM conversations WHERE `+where, args...)
+	return err
+}
+
+// addColumnIfMissing runs ALTER TABLE ADD COLUMN unless column already exists.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dflt      sql.NullString
+			isPrimary int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &isPrimary); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+func (s *sqliteConversationStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqliteConversationStore) Close() error {
+	close(s.stop)
+	return s.db.Close()
+}
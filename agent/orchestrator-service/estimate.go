@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"shared/tenant"
+)
+
+// ============================================================================
+// DRY RUN
+// ============================================================================
+// A request with dry_run set is planned but not executed: the response
+// carries the plan, the searches and MCP tools it would run, and an estimate
+// of the LLM calls, tokens, cost and latency of running it, so a caller can
+// gate expensive queries. Planning is the only Gemini call a dry run makes,
+// none when the plan comes from the plan cache or /agent/execute.
+//
+// Estimates are rough. Tokens are counted at four characters each, with
+// every search assumed to return top_k passages quoted in full up to the
+// AGENT_RESULT_TOKENS budget. Latencies are moving
+// averages of this process's recent calls. The low end is one iteration
+// that passes verification; the high end runs every iteration, capped by
+// the request's budget. Cost is only given when AGENT_TOKEN_PRICE (USD per
+// million tokens) is set.
+
+var AGENT_TOKEN_PRICE = envFloat("AGENT_TOKEN_PRICE", 0)
+
+// DryRun - What a request would do, returned instead of an answer
+type DryRun struct {
+	Plan     *ExecutionPlan `json:"plan"`
+	Searches int            `json:"searches"`
+	Tools    []string       `json:"tools"` // MCP tools the plan calls
+	Estimate Estimate       `json:"estimate"`
+}
+
+// Estimate - Expected cost of running a plan, from one iteration to all
+type Estimate struct {
+	LLMCalls     int     `json:"llm_calls"`
+	MaxLLMCalls  int     `json:"max_llm_calls"`
+	Tokens       int64   `json:"tokens"`
+	MaxTokens    int64   `json:"max_tokens"`
+	LatencyMs    float64 `json:"latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+	CostUSD      float64 `json:"cost_usd,omitempty"`
+	MaxCostUSD   float64 `json:"max_cost_usd,omitempty"`
+}
+
+// Assumptions behind the token estimate.
+const (
+	charsPerToken       = 4
+	promptOverhead      = 300 // instructions around the query in each prompt
+	toolResultTokens    = 500
+	shortOutputTokens   = 200 // analysis, verification
+	planOutputTokens    = 300
+	answerOutputTokens  = 800
+	defaultSearchResult = 5 // top_k when an action doesn't set it
+)
+
+// latencyStats keeps moving averages of call latencies by kind: "llm" for a
+// Gemini call, or an action type.
+type latencyStats struct {
+	mu  sync.Mutex
+	avg map[string]time.Duration
+}
+
+var callLatencies = &latencyStats{avg: map[string]time.Duration{
+	// Priors until real calls have been seen
+	"llm":        3 * time.Second,
+	"search_rag": 500 * time.Millisecond,
+	"call_tool":  time.Second,
+}}
+
+// Observe folds a call's latency into the average for its kind.
+func (s *latencyStats) Observe(kind string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if avg, ok := s.avg[kind]; ok {
+		s.avg[kind] = avg + (d-avg)/5
+	} else {
+		s.avg[kind] = d
+	}
+}
+
+func (s *latencyStats) Average(kind string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.avg[kind]
+}
+
+// dryRun plans req, or takes its supplied plan, and fills response.DryRun.
+func dryRun(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+
+	plan := req.plan
+	if plan == nil {
+		start := time.Now()
+		var planCache string
+		var err error
+		plan, planCache, err = planWithCache(ctx, req, history)
+		if err != nil {
+			recordStep(response, prog, AgentStep{
+				Type:        "plan",
+				Description: "Create execution plan",
+				Success:     false,
+				Duration:    float64(time.Since(start).Milliseconds()),
+				Cache:       planCache,
+			})
+			response.Answer = fmt.Sprintf("Failed to create plan: %v", err)
+			return
+		}
+		recordStep(response, prog, AgentStep{
+			Type:        "plan",
+			Description: "Create execution plan",
+			Result:      plan.Reasoning,
+			Success:     true,
+			Duration:    float64(time.Since(start).Milliseconds()),
+			Model:       plan.Model,
+			Cache:       planCache,
+		})
+	}
+
+	auditTrailFrom(ctx).plan(plan)
+	run := &DryRun{Plan: plan, Tools: []string{}}
+	for _, action := range plan.Actions {
+		switch action.Type {
+		case "search_rag":
+			run.Searches++
+		case "call_tool":
+			if tool, ok := action.Parameters["tool"].(string); ok {
+				run.Tools = append(run.Tools, tool)
+			}
+		}
+	}
+	run.Estimate = estimateRun(req, plan, history)
+	response.DryRun = run
+	log.Printf("🧮 Dry run: %d searches, %d tools, %d-%d LLM calls", run.Searches, len(run.Tools), run.Estimate.LLMCalls, run.Estimate.MaxLLMCalls)
+}
+
+// estimateRun estimates running plan for req.
+func estimateRun(req AgentRequest, plan *ExecutionPlan, history string) Estimate {
+	contextChars := len(history)
+	for k, v := range req.Context {
+		contextChars += len(k) + len(v)
+	}
+	prompt := int64(promptOverhead + (len(req.Query)+contextChars)/charsPerToken)
+
+	// Evidence every synthesis and verification prompt quotes
+	var evidence int64
+	var searches, tools int
+	for _, action := range plan.Actions {
+		switch action.Type {
+		case "search_rag":
+			searches++
+			topK := defaultSearchResult
+			if k, ok := action.Parameters["top_k"].(float64); ok && k > 0 {
+				topK = int(k)
+			} else if k, ok := action.Parameters["top_k"].(int); ok && k > 0 {
+				topK = k
+			}
+			evidence += int64(topK * passageLimit(topK) / charsPerToken)
+		case "call_tool":
+			tools++
+			tokens := toolResultTokens
+			if AGENT_RESULT_TOKENS > 0 {
+				tokens = min(tokens, AGENT_RESULT_TOKENS)
+			}
+			evidence += int64(tokens)
+		}
+	}
+
+	verify := reflectionFlag.Enabled()
+	synthesisTokens := prompt + evidence + answerOutputTokens
+	verifyTokens := prompt + evidence + answerOutputTokens + shortOutputTokens
+	actionLatency := time.Duration(searches)*callLatencies.Average("search_rag") + time.Duration(tools)*callLatencies.Average("call_tool")
+	llmLatency := callLatencies.Average("llm")
+
+	// One pass over the plan: the LLM calls, tokens and latency it costs
+	var calls int
+	var tokens int64
+	switch {
+	case req.plan != nil:
+		// /agent/execute: synthesize and verify, never re-planned
+		calls, tokens = 1, synthesisTokens
+	case req.Mode == modeReAct:
+		// A turn per action and one to answer, each re-reading what came before
+		calls = len(plan.Actions) + 1
+		tokens = int64(calls)*(prompt+shortOutputTokens) + evidence*int64(calls)/2 + answerOutputTokens
+	case req.Mode == modeMultiAgent:
+		// Planner and writer; later rounds swap the planner for the researcher
+		calls = 2
+		tokens = prompt + planOutputTokens + synthesisTokens
+	default:
+		calls = 3 // analyze, plan, synthesize
+		tokens = 2*prompt + shortOutputTokens + planOutputTokens + synthesisTokens
+	}
+	if verify && req.Mode != modeReAct {
+		calls++
+		tokens += verifyTokens
+	}
+	if req.Candidates > 1 && req.Mode != modeReAct {
+		// Every candidate is a synthesis, and the judge reads them all
+		calls += req.Candidates
+		tokens += int64(req.Candidates-1)*synthesisTokens + prompt + evidence + int64(req.Candidates)*answerOutputTokens + shortOutputTokens
+	}
+
+	iterations := max(req.MaxIterations, 1)
+	if req.plan != nil {
+		iterations = 1
+	}
+	est := Estimate{
+		LLMCalls:     calls,
+		MaxLLMCalls:  calls * iterations,
+		Tokens:       tokens,
+		MaxTokens:    tokens * int64(iterations),
+		LatencyMs:    float64((time.Duration(calls)*llmLatency + actionLatency).Milliseconds()),
+		MaxLatencyMs: float64((time.Duration(calls*iterations)*llmLatency + time.Duration(iterations)*actionLatency).Milliseconds()),
+	}
+	if req.Mode == modeReAct && req.plan == nil {
+		// ReAct stops on its turn limit rather than repeating the plan
+		est.MaxLLMCalls = max(req.MaxIterations+1, calls)
+		est.MaxTokens = tokens * int64(est.MaxLLMCalls) / int64(calls)
+		est.MaxLatencyMs = float64((time.Duration(est.MaxLLMCalls)*llmLatency + actionLatency).Milliseconds())
+	}
+
+	// The budget stops a run before the high end would
+	if req.MaxLLMCalls > 0 {
+		est.MaxLLMCalls = min(est.MaxLLMCalls, req.MaxLLMCalls)
+		est.LLMCalls = min(est.LLMCalls, req.MaxLLMCalls)
+	}
+	if req.MaxTokens > 0 {
+		est.MaxTokens = min(est.MaxTokens, int64(req.MaxTokens))
+		est.Tokens = min(est.Tokens, int64(req.MaxTokens))
+	}
+	if req.DeadlineMs > 0 {
+		est.MaxLatencyMs = min(est.MaxLatencyMs, float64(req.DeadlineMs))
+		est.LatencyMs = min(est.LatencyMs, float64(req.DeadlineMs))
+	}
+
+	if AGENT_TOKEN_PRICE > 0 {
+		est.CostUSD = float64(est.Tokens) * AGENT_TOKEN_PRICE / 1e6
+		est.MaxCostUSD = float64(est.MaxTokens) * AGENT_TOKEN_PRICE / 1e6
+	}
+	return est
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"shared/tenant"
+	"shared/tracing"
+)
+
+// ============================================================================
+// EXECUTE A SUPPLIED PLAN
+// ============================================================================
+// POST /agent/execute runs a plan the caller supplies, typically one from
+// /agent/plan that a reviewer has edited, through the execute, synthesize
+// and verify phases once. There is no analysis, no planning and no second
+// iteration: an incomplete answer comes back with need_more_info set rather
+// than being re-planned. Guardrails, budgets, conversation storage and
+// callbacks apply as for /agent/query.
+
+// maxPlanActions bounds how many actions a supplied plan may run.
+const maxPlanActions = 10
+
+// ExecuteRequest - A plan to run, with the same options as an AgentRequest
+type ExecuteRequest struct {
+	AgentRequest
+	Plan *ExecutionPlan `json:"plan"`
+}
+
+// validatePlan checks that every action of a supplied plan can run.
+func validatePlan(plan *ExecutionPlan) error {
+	if plan == nil || len(plan.Actions) == 0 {
+		return fmt.Errorf("plan must have at least one action")
+	}
+	if len(plan.Actions) > maxPlanActions {
+		return fmt.Errorf("plan may have at most %d actions", maxPlanActions)
+	}
+	for i, action := range plan.Actions {
+		switch action.Type {
+		case "search_rag":
+			if query, _ := action.Parameters["query"].(string); query == "" {
+				return fmt.Errorf("action %d: search_rag needs a query parameter", i+1)
+			}
+		case "call_tool":
+			if tool, _ := action.Parameters["tool"].(string); tool == "" {
+				return fmt.Errorf("action %d: call_tool needs a tool parameter", i+1)
+			}
+		case "synthesize":
+		default:
+			return fmt.Errorf("action %d: unknown action type %q", i+1, action.Type)
+		}
+	}
+	return nil
+}
+
+// executeSuppliedPlan runs req's plan through the execute, synthesize and
+// verify phases.
+func executeSuppliedPlan(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	plan := req.plan
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+	response.beginIteration(1)
+
+	result := plan.Reasoning
+	if result == "" {
+		result = fmt.Sprintf("%d actions supplied by the caller", len(plan.Actions))
+	}
+	recordStep(response, prog, AgentStep{
+		Type:        "plan",
+		Description: "Use supplied execution plan",
+		Result:      result,
+		Success:     true,
+	})
+
+	executionResults := executeStep(ctx, 1, plan, response, prog)
+	degradeOnFailedActions(req, response, executionResults)
+
+	if budgetExceeded(ctx) {
+		log.Printf("  💸 Budget exhausted before synthesis")
+		response.BudgetExceeded = true
+		return
+	}
+	synthesizeStart := time.Now()
+	stepCtx, span := tracing.Start(ctx, "agent.synthesize", attribute.Int("iteration", 1))
+	answer, synthesisModel, citations, verdict := synthesize(stepCtx, req, history, executionResults, prog.tokenSink(response.iteration))
+	span.End()
+	response.Answer = answer
+	response.Citations = citations
+	recordStep(response, prog, AgentStep{
+		Type:        "synthesize",
+		Description: "Synthesize final answer",
+		Result:      fmt.Sprintf("Generated answer (%d chars, %d citations)", len(answer), len(citations)),
+		Success:     synthesisModel != "",
+		Duration:    float64(time.Since(synthesizeStart).Milliseconds()),
+		Model:       synthesisModel,
+	})
+	if synthesisModel == "" && partialResults(req) {
+		degrade(response, "synthesis failed")
+		response.Answer, response.Citations = quotedAnswer(executionResults)
+		if sink := prog.tokenSink(response.iteration); sink != nil {
+			sink(response.Answer)
+		}
+		return
+	}
+	if verdict != nil {
+		recordStep(response, prog, verdict.step())
+		response.Agreement = verdict.Agreement
+		response.Confidence = verdict.Agreement
+	}
+
+	if !reflectionFlag.Enabled() {
+		return
+	}
+	if budgetExceeded(ctx) {
+		log.Printf("  💸 Budget exhausted before verification")
+		response.BudgetExceeded = true
+		return
+	}
+	verifyStart := time.Now()
+	stepCtx, span = tracing.Start(ctx, "agent.verify", attribute.Int("iteration", 1))
+	verification := verifyAnswer(stepCtx, req.VerifierModel, req.Query, answer, executionResults)
+	span.SetAttributes(attribute.Float64("confidence", verification.Confidence))
+	span.End()
+	response.Confidence = verdict.blend(verification.Confidence)
+	recordStep(response, prog, verification.step(verifyStart))
+	verification.report(response)
+
+	// The caller owns the plan, so an incomplete answer is reported, not re-planned
+	if !verification.IsComplete || response.Confidence < req.ConfidenceThreshold {
+		response.NeedMoreInfo = true
+		response.FollowUpQ = "The plan did not gather enough information to answer completely. Consider adding actions for: " + verification.MissingInfo
+		response.evidence, response.missingInfo = executionResults, verification.MissingInfo
+	}
+}
+
+// Run a supplied execution plan
+func executePlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePlan(body.Plan); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := body.AgentRequest
+	if req.FollowUpAnswer != "" {
+		respondError(w, "follow_up_answer resumes a run through /agent/query", http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		req.Query = body.Plan.OriginalQuery
+	}
+	if !prepareAgentRequest(w, &req) {
+		return
+	}
+	req.plan = body.Plan
+
+	log.Printf("📋 Executing supplied plan with %d actions", len(req.plan.Actions))
+	response := runAgentQuery(r.Context(), req, nil)
+	notifyCallback(r.Context(), req, "", response, r.Context().Err())
+	respondJSON(w, response, http.StatusOK)
+}
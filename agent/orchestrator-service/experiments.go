@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ============================================================================
+// EXPERIMENTS
+// ============================================================================
+// An A/B experiment splits queries between variants that differ in their
+// models or prompt templates, so a change can be measured before it is
+// rolled out. The experiment is read at startup from the JSON file at
+// AGENT_EXPERIMENT_FILE:
+//
+//	{
+//	  "name": "concise-synthesis",
+//	  "variants": [
+//	    {"name": "control", "weight": 1},
+//	    {"name": "concise", "weight": 1, "synthesis_model": "gemini-2.0-flash",
+//	     "prompts": {"synthesize": "/etc/agent/concise.tmpl"}}
+//	  ]
+//	}
+//
+// A conversation is assigned a variant at random by weight, from a hash of
+// its ID, so every turn of it and a resumed run stay in the same variant.
+// Requests that choose a model themselves are left out. The response names
+// the variant under "experiment", and POST /agent/feedback rates an answer
+// of the conversation. Per variant, GET /agent/experiments and the
+// agent_experiment_* metrics report the runs, their confidence and latency,
+// and the feedback given.
+
+var AGENT_EXPERIMENT_FILE = getEnv("AGENT_EXPERIMENT_FILE", "")
+
+// Experiment - Variants queries are split between
+type Experiment struct {
+	Name     string               `json:"name"`
+	Variants []*ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant - The models and prompt templates of one arm of an
+// experiment; empty fields keep the server's
+type ExperimentVariant struct {
+	Name           string            `json:"name"`
+	Weight         float64           `json:"weight"`
+	Model          string            `json:"model,omitempty"`
+	AnalysisModel  string            `json:"analysis_model,omitempty"`
+	PlannerModel   string            `json:"planner_model,omitempty"`
+	SynthesisModel string            `json:"synthesis_model,omitempty"`
+	VerifierModel  string            `json:"verifier_model,omitempty"`
+	Prompts        map[string]string `json:"prompts,omitempty"` // template file by prompt name, see prompts.go
+
+	experiment string
+	templates  map[string]*template.Template
+	stats      variantStats
+}
+
+// VariantTag - The experiment variant a response was produced by
+type VariantTag struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+// variantStats sums one variant's runs and the feedback on them.
+type variantStats struct {
+	mu         sync.Mutex
+	runs       int
+	confidence float64
+	latencyMs  float64
+	up, down   int
+}
+
+var (
+	experimentRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_experiment_runs_total",
+		Help: "Agent runs by experiment variant",
+	}, []string{"experiment", "variant"})
+	experimentConfidence = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agent_experiment_confidence",
+		Help:    "Confidence of the answers of each experiment variant",
+		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	}, []string{"experiment", "variant"})
+	experimentLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agent_experiment_latency_seconds",
+		Help:    "Run latency of each experiment variant",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 8),
+	}, []string{"experiment", "variant"})
+	feedbackRatings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_feedback_total",
+		Help: "Answer ratings by experiment variant (empty outside experiments) and rating",
+	}, []string{"experiment", "variant", "rating"})
+)
+
+// experiment is the running experiment, nil when there is none.
+var experiment *Experiment
+
+// loadExperiment reads the experiment at path, checking its variants'
+// models and parsing their templates.
+func loadExperiment(path string) (*Experiment, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exp Experiment
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if exp.Name == "" || len(exp.Variants) < 2 {
+		return nil, fmt.Errorf("%s: an experiment needs a name and at least two variants", path)
+	}
+
+	seen := map[string]bool{}
+	for _, v := range exp.Variants {
+		if v.Name == "" || seen[v.Name] {
+			return nil, fmt.Errorf("variant names must be set and unique, got %q", v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight <= 0 {
+			return nil, fmt.Errorf("variant %s: weight must be positive", v.Name)
+		}
+		probe := AgentRequest{}
+		v.apply(&probe)
+		if err := resolveModels(&probe); err != nil {
+			return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+		v.experiment = exp.Name
+		v.templates = map[string]*template.Template{}
+		for name, file := range v.Prompts {
+			if _, ok := builtinPrompts[name]; !ok {
+				return nil, fmt.Errorf("variant %s: unknown prompt %q", v.Name, name)
+			}
+			text, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+			}
+			if v.templates[name], err = parsePrompt(name, string(text)); err != nil {
+				return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+			}
+		}
+	}
+	return &exp, nil
+}
+
+// assign picks the conversation's variant.
+func (e *Experiment) assign(conversationID string) *ExperimentVariant {
+	var total float64
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	h := fnv.New64a()
+	h.Write([]byte(e.Name + "\x00" + conversationID))
+	point := float64(h.Sum64()%1_000_000) / 1_000_000 * total
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v
+		}
+		point -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// enroll assigns req to a variant of the running experiment and applies
+// it, unless req chose a model itself. It runs before resolveModels.
+func enroll(req *AgentRequest) {
+	if experiment == nil || req.Model != "" || req.AnalysisModel != "" || req.PlannerModel != "" ||
+		req.SynthesisModel != "" || req.VerifierModel != "" {
+		return
+	}
+	req.variant = experiment.assign(req.ConversationID)
+	req.variant.apply(req)
+}
+
+// apply sets the variant's models on req.
+func (v *ExperimentVariant) apply(req *AgentRequest) {
+	req.Model = v.Model
+	req.AnalysisModel = v.AnalysisModel
+	req.PlannerModel = v.PlannerModel
+	req.SynthesisModel = v.SynthesisModel
+	req.VerifierModel = v.VerifierModel
+}
+
+func (v *ExperimentVariant) tag() *VariantTag {
+	if v == nil {
+		return nil
+	}
+	return &VariantTag{Experiment: v.experiment, Variant: v.Name}
+}
+
+type variantKey struct{}
+
+// withVariant attaches the request's variant to ctx for renderPrompt.
+func withVariant(ctx context.Context, v *ExperimentVariant) context.Context {
+	if v == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, variantKey{}, v)
+}
+
+func variantFrom(ctx context.Context) *ExperimentVariant {
+	v, _ := ctx.Value(variantKey{}).(*ExperimentVariant)
+	return v
+}
+
+// record counts a finished run of the variant.
+func (v *ExperimentVariant) record(response AgentResponse, elapsed time.Duration) {
+	if v == nil {
+		return
+	}
+	experimentRuns.WithLabelValues(v.experiment, v.Name).Inc()
+	experimentConfidence.WithLabelValues(v.experiment, v.Name).Observe(response.Confidence)
+	experimentLatency.WithLabelValues(v.experiment, v.Name).Observe(elapsed.Seconds())
+
+	v.stats.mu.Lock()
+	defer v.stats.mu.Unlock()
+	v.stats.runs++
+	v.stats.confidence += response.Confidence
+	v.stats.latencyMs += float64(elapsed.Milliseconds())
+}
+
+// variantReport is a variant's entry in GET /agent/experiments.
+type variantReport struct {
+	*ExperimentVariant
+	Runs          int     `json:"runs"`
+	AvgConfidence float64 `json:"avg_confidence"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	FeedbackUp    int     `json:"feedback_up"`
+	FeedbackDown  int     `json:"feedback_down"`
+	PositiveRate  float64 `json:"positive_rate"` // share of ratings that were up
+}
+
+func (v *ExperimentVariant) report() variantReport {
+	v.stats.mu.Lock()
+	defer v.stats.mu.Unlock()
+	return variantReport{
+		ExperimentVariant: v,
+		Runs:              v.stats.runs,
+		AvgConfidence:     ratio(v.stats.confidence, v.stats.runs),
+		AvgLatencyMs:      ratio(v.stats.latencyMs, v.stats.runs),
+		FeedbackUp:        v.stats.up,
+		FeedbackDown:      v.stats.down,
+		PositiveRate:      ratio(float64(v.stats.up), v.stats.up+v.stats.down),
+	}
+}
+
+// Report the running experiment and how its variants are doing
+func experimentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if experiment == nil {
+		respondJSON(w, map[string]interface{}{"experiment": nil}, http.StatusOK)
+		return
+	}
+
+	variants := make([]variantReport, 0, len(experiment.Variants))
+	for _, v := range experiment.Variants {
+		variants = append(variants, v.report())
+	}
+	respondJSON(w, map[string]interface{}{
+		"experiment": experiment.Name,
+		"variants":   variants,
+	}, http.StatusOK)
+}
+
+// FeedbackRequest - A user's rating of an answer
+type FeedbackRequest struct {
+	ConversationID string `json:"conversation_id"`
+	Rating         string `json:"rating"` // "up" or "down"
+}
+
+// Rate an answer, crediting the conversation's experiment variant
+func feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ConversationID == "" || (req.Rating != "up" && req.Rating != "down") {
+		respondError(w, `conversation_id is required and rating must be "up" or "down"`, http.StatusBadRequest)
+		return
+	}
+
+	var v *ExperimentVariant
+	if experiment != nil {
+		v = experiment.assign(req.ConversationID)
+		v.stats.mu.Lock()
+		if req.Rating == "up" {
+			v.stats.up++
+		} else {
+			v.stats.down++
+		}
+		v.stats.mu.Unlock()
+		feedbackRatings.WithLabelValues(v.experiment, v.Name, req.Rating).Inc()
+	} else {
+		feedbackRatings.WithLabelValues("", "", req.Rating).Inc()
+	}
+	log.Printf("👍 Feedback on %s: %s", req.ConversationID, req.Rating)
+
+	respondJSON(w, map[string]interface{}{
+		"conversation_id": req.ConversationID,
+		"rating":          req.Rating,
+		"experiment":      v.tag(),
+	}, http.StatusOK)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/tenant"
+)
+
+// ============================================================================
+// CONVERSATION EXPORT
+// ============================================================================
+// GET /agent/history/{id}/export?format=json|md returns a conversation as a
+// complete transcript to attach to a compliance case file: every question
+// and answer with its time, the tools the agent used, the citations behind
+// the answer and every step of its reasoning. json (the default) is a
+// Transcript; md is the same transcript as Markdown. Both are sent as a
+// download named after the conversation. Turns stored before exports
+// existed have no tools or citations.
+
+// Transcript - A conversation as exported
+type Transcript struct {
+	ConversationID string           `json:"conversation_id"`
+	TenantID       string           `json:"tenant_id"`
+	Persona        string           `json:"persona,omitempty"`
+	StartTime      time.Time        `json:"start_time"`
+	ExportedAt     time.Time        `json:"exported_at"`
+	Turns          []TranscriptTurn `json:"turns"`
+}
+
+// TranscriptTurn - A question and the agent's answer to it
+type TranscriptTurn struct {
+	Number     int         `json:"number"`
+	Query      string      `json:"query"`
+	AskedAt    time.Time   `json:"asked_at"`
+	Answer     string      `json:"answer"`
+	AnsweredAt time.Time   `json:"answered_at"`
+	ToolsUsed  []string    `json:"tools_used"`
+	Citations  []Citation  `json:"citations"`
+	Steps      []AgentStep `json:"steps"`
+}
+
+// transcriptOf pairs conv's messages into turns. A question without an
+// answer, or an answer without a question, is a turn of its own.
+func transcriptOf(conv *Conversation) Transcript {
+	transcript := Transcript{
+		ConversationID: conv.ID,
+		TenantID:       conv.TenantID,
+		Persona:        conv.Persona,
+		StartTime:      conv.StartTime,
+		ExportedAt:     time.Now().UTC(),
+		Turns:          []TranscriptTurn{},
+	}
+	var turn *TranscriptTurn
+	for _, msg := range conv.Messages {
+		if msg.Role == "user" || turn == nil || !turn.AnsweredAt.IsZero() {
+			transcript.Turns = append(transcript.Turns, TranscriptTurn{
+				Number:    len(transcript.Turns) + 1,
+				ToolsUsed: []string{},
+				Citations: []Citation{},
+				Steps:     []AgentStep{},
+			})
+			turn = &transcript.Turns[len(transcript.Turns)-1]
+		}
+		if msg.Role == "user" {
+			turn.Query, turn.AskedAt = msg.Content, msg.Timestamp
+			continue
+		}
+		turn.Answer, turn.AnsweredAt = msg.Content, msg.Timestamp
+		if msg.ToolsUsed != nil {
+			turn.ToolsUsed = msg.ToolsUsed
+		}
+		if msg.Citations != nil {
+			turn.Citations = msg.Citations
+		}
+		if msg.Steps != nil {
+			turn.Steps = msg.Steps
+		}
+	}
+	return transcript
+}
+
+// Export a conversation as a transcript
+func exportConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "md" {
+		respondError(w, `format must be "json" or "md"`, http.StatusBadRequest)
+		return
+	}
+
+	conv, err := conversations.Get(r.Context(), tenant.FromContext(r.Context()), conversationID)
+	if err != nil {
+		log.Printf("Failed to load conversation %s: %v", conversationID, err)
+		respondError(w, "Failed to load conversation", http.StatusInternalServerError)
+		return
+	}
+	if conv == nil {
+		respondError(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	transcript := transcriptOf(conv)
+
+	filename := "conversation-" + conversationID + "." + format
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if format == "md" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(transcript.markdown()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(transcript)
+}
+
+// markdown renders the transcript as a Markdown document.
+func (t Transcript) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation %s\n\n", t.ConversationID)
+	fmt.Fprintf(&b, "- Tenant: %s\n", t.TenantID)
+	fmt.Fprintf(&b, "- Started: %s\n", mdTime(t.StartTime))
+	fmt.Fprintf(&b, "- Exported: %s\n", mdTime(t.ExportedAt))
+	fmt.Fprintf(&b, "- Turns: %d\n", len(t.Turns))
+	if t.Persona != "" {
+		fmt.Fprintf(&b, "- Persona: %s\n", oneLine(t.Persona))
+	}
+
+	for _, turn := range t.Turns {
+		fmt.Fprintf(&b, "\n## Turn %d\n\n", turn.Number)
+		fmt.Fprintf(&b, "**User** · %s\n\n%s\n\n", mdTime(turn.AskedAt), quote(turn.Query))
+		fmt.Fprintf(&b, "**Assistant** · %s\n\n%s\n\n", mdTime(turn.AnsweredAt), quote(turn.Answer))
+
+		tools := "none"
+		if len(turn.ToolsUsed) > 0 {
+			tools = strings.Join(turn.ToolsUsed, ", ")
+		}
+		fmt.Fprintf(&b, "**Tools used:** %s\n", tools)
+
+		if len(turn.Citations) > 0 {
+			b.WriteString("\n**Citations**\n\n")
+			for _, c := range turn.Citations {
+				fmt.Fprintf(&b, "- [%d] %s (document %s, chunk %s", c.Marker, oneLine(c.DocumentName), c.DocumentID, c.ChunkID)
+				if c.Collection != "" {
+					fmt.Fprintf(&b, ", %s", c.Collection)
+				}
+				fmt.Fprintf(&b, ", score %.2f)", c.Score)
+				if c.Snippet != "" {
+					fmt.Fprintf(&b, ": \"%s\"", oneLine(c.Snippet))
+				}
+				b.WriteString("\n")
+			}
+		}
+
+		if len(turn.Steps) > 0 {
+			b.WriteString("\n**Steps**\n\n")
+			b.WriteString("| # | Iteration | Type | Description | Action | Result | Success | Duration (ms) | Model |\n")
+			b.WriteString("|---|-----------|------|-------------|--------|--------|---------|---------------|-------|\n")
+			for _, step := range turn.Steps {
+				fmt.Fprintf(&b, "| %d | %d | %s | %s | %s | %s | %t | %.0f | %s |\n",
+					step.StepNumber, step.Iteration, step.Type, mdCell(step.Description), mdCell(step.Action),
+					mdCell(step.Result), step.Success, step.Duration, step.Model)
+			}
+		}
+	}
+	return b.String()
+}
+
+// mdTime formats a time for the transcript, or "-" when it is unknown.
+func mdTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// quote renders text as a Markdown blockquote.
+func quote(text string) string {
+	if text == "" {
+		return "> _(none)_"
+	}
+	return "> " + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n> ")
+}
+
+// oneLine collapses text onto a single line.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// mdCell makes text safe for a Markdown table cell.
+func mdCell(text string) string {
+	return strings.ReplaceAll(oneLine(text), "|", `\|`)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// FACT-CHECKING
+// ============================================================================
+// The verify step doesn't ask the model whether the answer looks complete;
+// it checks the answer's claims. verifier_model breaks the answer into the
+// factual claims it makes and checks each against the passages and tool
+// results the answer was written from, the same numbered passages synthesis
+// saw. The share of claims the evidence supports is the grounding score,
+// which becomes the verification confidence; an answer making no factual
+// claims has nothing ungrounded and scores 1. The response reports the
+// final answer's grounding and its unsupported claims, and the verify step
+// lists them. Whether the answer is complete is still judged. An incomplete
+// or poorly grounded answer triggers another iteration as before, which
+// searches for evidence of the unsupported claims.
+
+var unsupportedClaims = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "agent_unsupported_claims_total",
+	Help: "Claims in verified answers that the gathered evidence did not support.",
+})
+
+// maxClaims bounds the claims checked per answer.
+const maxClaims = 20
+
+// ClaimCheck - A factual claim of the answer and whether the evidence supports it
+type ClaimCheck struct {
+	Claim     string `json:"claim"`
+	Supported bool   `json:"supported"`
+	Passage   int    `json:"passage,omitempty"` // number of the supporting passage
+	Reason    string `json:"reason,omitempty"`
+}
+
+// checkClaimsConfig forces the verifier to respond with its claim checks.
+var checkClaimsConfig = &genai.GenerateContentConfig{
+	Tools: []*genai.Tool{{
+		FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name:        "report_claims",
+			Description: "Report each factual claim of the answer, whether the evidence supports it, and whether the answer is complete.",
+			Parameters: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"claims": {
+						Type:        genai.TypeArray,
+						Description: fmt.Sprintf("The answer's factual claims, at most %d, each a short self-contained sentence", maxClaims),
+						Items: &genai.Schema{
+							Type: genai.TypeObject,
+							Properties: map[string]*genai.Schema{
+								"claim":     {Type: genai.TypeString},
+								"supported": {Type: genai.TypeBoolean, Description: "Whether the evidence states or directly implies the claim"},
+								"passage":   {Type: genai.TypeInteger, Description: "Number of the passage that supports it, 0 when none or a tool result does"},
+								"reason":    {Type: genai.TypeString, Description: "For an unsupported claim, what the evidence lacks or says instead"},
+							},
+							Required: []string{"claim", "supported"},
+						},
+					},
+					"is_complete":  {Type: genai.TypeBoolean, Description: "Whether the answer addresses the whole question"},
+					"missing_info": {Type: genai.TypeString, Description: "What the answer is missing, when it is not complete"},
+				},
+				Required: []string{"claims", "is_complete"},
+			},
+		}},
+	}},
+	ToolConfig: &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+	},
+}
+
+// verifyAnswer checks answer's claims against the results it was written
+// from. A verifier that fails or can't be understood leaves the answer
+// unchecked, with a middling confidence, rather than failing the query.
+func verifyAnswer(ctx context.Context, modelName, query string, answer string, results []map[string]interface{}) Verification {
+	contextStr, _ := gatheredContext(results)
+	prompt := renderPrompt(ctx, promptVerify, promptData{Query: query, Context: contextStr, Answer: answer})
+
+	resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), checkClaimsConfig)
+	if err != nil {
+		log.Printf("Verification failed: %v", err)
+		return Verification{IsComplete: true, Confidence: 0.5, MissingInfo: ""}
+	}
+	calls := resp.FunctionCalls()
+	if len(calls) == 0 {
+		log.Printf("Verifier reported no claims")
+		return Verification{IsComplete: true, Confidence: 0.7, MissingInfo: "", Model: model}
+	}
+
+	v := Verification{Model: model, Checked: true}
+	v.IsComplete, _ = calls[0].Args["is_complete"].(bool)
+	v.MissingInfo, _ = calls[0].Args["missing_info"].(string)
+	raw, _ := calls[0].Args["claims"].([]interface{})
+	for _, r := range raw {
+		fields, _ := r.(map[string]interface{})
+		claim, _ := fields["claim"].(string)
+		if claim = strings.TrimSpace(claim); claim == "" {
+			continue
+		}
+		check := ClaimCheck{Claim: claim}
+		check.Supported, _ = fields["supported"].(bool)
+		if passage, ok := fields["passage"].(float64); ok && passage > 0 {
+			check.Passage = int(passage)
+		}
+		check.Reason, _ = fields["reason"].(string)
+		v.Claims = append(v.Claims, check)
+		if len(v.Claims) == maxClaims {
+			break
+		}
+	}
+
+	v.Grounding = 1
+	if len(v.Claims) > 0 {
+		supported := 0
+		for _, check := range v.Claims {
+			if check.Supported {
+				supported++
+			} else {
+				v.Unsupported = append(v.Unsupported, check.Claim)
+			}
+		}
+		v.Grounding = float64(supported) / float64(len(v.Claims))
+	}
+	v.Confidence = v.Grounding
+	// The next iteration looks for what would back the unsupported claims
+	if v.MissingInfo == "" && len(v.Unsupported) > 0 {
+		v.MissingInfo = "evidence for " + truncate(strings.Join(v.Unsupported, "; "), 500)
+	}
+	unsupportedClaims.Add(float64(len(v.Unsupported)))
+	return v
+}
+
+// step is the verify step for the trace.
+func (v Verification) step(start time.Time) AgentStep {
+	result := fmt.Sprintf("Confidence: %.2f, Complete: %v", v.Confidence, v.IsComplete)
+	if v.Checked {
+		result = fmt.Sprintf("Grounding: %.2f (%d of %d claims supported), Complete: %v",
+			v.Grounding, len(v.Claims)-len(v.Unsupported), len(v.Claims), v.IsComplete)
+		if len(v.Unsupported) > 0 {
+			result += "; unsupported: " + truncate(strings.Join(v.Unsupported, "; "), 500)
+		}
+	}
+	return AgentStep{
+		Type:        "verify",
+		Description: "Fact-check answer against the evidence",
+		Result:      result,
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+		Model:       v.Model,
+	}
+}
+
+// report sets the response's grounding from the verification of its final
+// answer; an unchecked answer reports none.
+func (v Verification) report(response *AgentResponse) {
+	response.Grounding, response.UnsupportedClaims = nil, nil
+	if !v.Checked {
+		return
+	}
+	grounding := v.Grounding
+	response.Grounding = &grounding
+	response.UnsupportedClaims = v.Unsupported
+}
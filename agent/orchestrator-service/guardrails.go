@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"shared/flags"
+)
+
+// ============================================================================
+// GUARDRAILS
+// ============================================================================
+// Queries are screened before the agent runs: ones asking for help with
+// financial crime, or containing a term from GUARDRAILS_BLOCKED_TERMS (comma
+// separated), are refused without calling Gemini. Answers and citation
+// snippets are scrubbed of card numbers, PANs, Aadhaar numbers and bank
+// account numbers before they are returned, streamed or stored. Both
+// decisions are recorded as "guardrail" steps. Turn the whole layer off with
+// the agent_guardrails flag.
+
+var guardrailsFlag = flags.Define("agent_guardrails", true,
+	"Screen queries for disallowed content and redact card, PAN, Aadhaar and account numbers from answers")
+
+var (
+	guardrailBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_guardrail_blocks_total",
+		Help: "Queries refused by the input guardrail, by category.",
+	}, []string{"category"})
+
+	guardrailRedactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_guardrail_redactions_total",
+		Help: "Values redacted from answers by the output guardrail, by kind.",
+	}, []string{"kind"})
+)
+
+// disallowedPatterns are the categories of request the agent refuses.
+var disallowedPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{"money laundering", regexp.MustCompile(`(?i)\b(launder(ing)?|wash(ing)?|layer(ing)?)\s+(the\s+)?(money|funds|cash|proceeds)\b`)},
+	{"evading compliance controls", regexp.MustCompile(`(?i)\b(evade|evading|bypass(ing)?|circumvent(ing)?|get(ting)?\s+around|trick(ing)?)\b.{0,40}\b(kyc|aml|sanctions?|screening|due\s+diligence|reporting\s+thresholds?|transaction\s+monitoring)\b`)},
+	{"identity fraud", regexp.MustCompile(`(?i)\b(fake|forged?|forging|counterfeit|synthetic|doctored)\b.{0,30}\b(ids?|identity|identities|documents?|passports?|aadhaar|pan\s+cards?|kyc)\b`)},
+	{"card fraud", regexp.MustCompile(`(?i)\b(stolen|cloned|skimm(ed|ing)|dumped)\b.{0,30}\b(cards?|card\s+numbers|cvvs?)\b|\bcarding\b`)},
+}
+
+var blockedTerms = splitList(strings.ToLower(getEnv("GUARDRAILS_BLOCKED_TERMS", "")))
+
+// screenQuery returns the category a query is refused for, or "" if it may
+// run.
+func screenQuery(query string) string {
+	for _, d := range disallowedPatterns {
+		if d.pattern.MatchString(query) {
+			return d.category
+		}
+	}
+	lower := strings.ToLower(query)
+	for _, term := range blockedTerms {
+		if strings.Contains(lower, term) {
+			return "blocked term"
+		}
+	}
+	return ""
+}
+
+// refusalAnswer is returned in place of an answer to a refused query.
+func refusalAnswer(category string) string {
+	return fmt.Sprintf("I can't help with this request because it appears to involve %s. "+
+		"I can answer questions about regulatory, KYC and risk requirements.", category)
+}
+
+// inputGuardrailStep records the screening decision for a query.
+func inputGuardrailStep(category string, start time.Time) AgentStep {
+	step := AgentStep{
+		Type:        "guardrail",
+		Description: "Screen query for disallowed content",
+		Result:      "allowed",
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	}
+	if category != "" {
+		step.Result = "blocked: " + category
+		step.Success = false
+	}
+	return step
+}
+
+// outputGuardrailStep records what was redacted from an answer.
+func outputGuardrailStep(counts map[string]int, start time.Time) AgentStep {
+	step := AgentStep{
+		Type:        "guardrail",
+		Description: "Redact sensitive data from answer",
+		Result:      "no sensitive data found",
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	}
+	if len(counts) > 0 {
+		step.Result = "redacted " + describeCounts(counts)
+	}
+	return step
+}
+
+// describeCounts lists counts by kind, e.g. "1 PAN, 2 card number".
+func describeCounts(counts map[string]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind, n := range counts {
+		kinds = append(kinds, fmt.Sprintf("%d %s", n, kind))
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
+
+// ============================================================================
+// PII REDACTION
+// ============================================================================
+
+var (
+	// Card numbers: 13-19 digits, optionally grouped by spaces or dashes,
+	// confirmed with the Luhn checksum.
+	cardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// Aadhaar: 12 digits not starting with 0 or 1, optionally grouped 4-4-4,
+	// confirmed with the Verhoeff checksum.
+	aadhaarPattern = regexp.MustCompile(`\b[2-9]\d{3}[ -]?\d{4}[ -]?\d{4}\b`)
+	// PAN: five letters, the fourth being the holder type, four digits and
+	// a check letter.
+	panPattern = regexp.MustCompile(`\b[A-Z]{3}[ABCFGHJLPT][A-Z]\d{4}[A-Z]\b`)
+	// Bank account numbers are only recognisable by their label.
+	accountPattern = regexp.MustCompile(`(?i)\b(?:account|acct|a/c)(?:\s{0,3}(?:no\.?|number|num|#))?\s{0,3}[:\-]?\s{0,3}(\d{9,18})\b`)
+	accountLabel   = regexp.MustCompile(`(?i)\b(?:account|acct|a/c)\b`)
+)
+
+// redactPII replaces sensitive numbers in text with placeholders and
+// returns how many of each kind it replaced.
+func redactPII(text string) (string, map[string]int) {
+	counts := map[string]int{}
+
+	text = cardPattern.ReplaceAllStringFunc(text, func(match string) string {
+		digits := onlyDigits(match)
+		if len(digits) < 13 || !luhnValid(digits) {
+			return match
+		}
+		counts["card number"]++
+		return "[REDACTED CARD]"
+	})
+	text = aadhaarPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if !verhoeffValid(onlyDigits(match)) {
+			return match
+		}
+		counts["Aadhaar number"]++
+		return "[REDACTED AADHAAR]"
+	})
+	text = panPattern.ReplaceAllStringFunc(text, func(string) string {
+		counts["PAN"]++
+		return "[REDACTED PAN]"
+	})
+	text = accountPattern.ReplaceAllStringFunc(text, func(match string) string {
+		digits := accountPattern.FindStringSubmatch(match)[1]
+		counts["account number"]++
+		return strings.TrimSuffix(match, digits) + "[REDACTED ACCOUNT]"
+	})
+
+	return text, counts
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+var (
+	verhoeffMul = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPerm = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// verhoeffValid checks the Verhoeff checksum Aadhaar numbers carry.
+func verhoeffValid(digits string) bool {
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffMul[c][verhoeffPerm[i%8][d]]
+	}
+	return c == 0
+}
+
+// ============================================================================
+// STREAM REDACTION
+// ============================================================================
+
+// streamHoldback is how far back from a cut point no digits or account
+// label may appear, so a number is never split between two emitted chunks.
+const streamHoldback = 32
+
+// streamRedactor redacts a streamed answer. It holds text back until it can
+// cut at a point no sensitive value can straddle, then passes the redacted
+// text before the cut on to emit.
+type streamRedactor struct {
+	emit    func(string)
+	pending string
+}
+
+func newStreamRedactor(emit func(string)) *streamRedactor {
+	return &streamRedactor{emit: emit}
+}
+
+func (s *streamRedactor) Write(text string) {
+	s.pending += text
+	if cut := safeCut(s.pending); cut > 0 {
+		redacted, _ := redactPII(s.pending[:cut])
+		s.pending = s.pending[cut:]
+		s.emit(redacted)
+	}
+}
+
+// Flush emits whatever is still held back; call it when the stream ends.
+func (s *streamRedactor) Flush() {
+	if s.pending != "" {
+		redacted, _ := redactPII(s.pending)
+		s.pending = ""
+		s.emit(redacted)
+	}
+}
+
+// safeCut returns the last position in s that follows whitespace and has
+// neither digits nor an account label in the streamHoldback bytes before
+// it, or 0 if there is none.
+func safeCut(s string) int {
+	for cut := len(s); cut > 0; cut-- {
+		if !unicode.IsSpace(rune(s[cut-1])) {
+			continue
+		}
+		window := s[max(0, cut-streamHoldback):cut]
+		if strings.ContainsAny(window, "0123456789") || accountLabel.MatchString(window) {
+			continue
+		}
+		return cut
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"shared/httpclient"
+)
+
+// registerCircuitMetrics exposes each dependency client's breaker as 1
+// while it is open or half-open.
+func registerCircuitMetrics() {
+	for dependency, client := range map[string]*httpclient.Client{"retrieval-service": ragClient, "mcp-gateway": mcpClient} {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "agent_dependency_circuit_open",
+			Help:        "Whether the circuit breaker in front of a dependency is open (1) or closed (0).",
+			ConstLabels: prometheus.Labels{"dependency": dependency},
+		}, func() float64 {
+			if client.CircuitState() == httpclient.CircuitClosed {
+				return 0
+			}
+			return 1
+		})
+	}
+}
+
+// DependencyHealth - Result of probing one downstream service
+type DependencyHealth struct {
+	Status    string                 `json:"status"` // "healthy", "degraded" or "unhealthy"
+	LatencyMs float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// deepHealthTimeout bounds each dependency probe.
+const deepHealthTimeout = 3 * time.Second
+
+// deepHealthHandler probes every dependency's /health endpoint concurrently
+// and reports per-dependency status and latency. Vector-service reports
+// "degraded" when Qdrant is unreachable and the MCP gateway probes its tools,
+// so monitoring can tell which layer is down. Returns 503 unless every
+// dependency is healthy.
+func deepHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targets := map[string]string{
+		"retrieval-service": RAG_SERVICE_URL + "/health",
+		"embed-service":     EMBED_SERVICE_URL + "/health",
+		"vector-service":    VECTOR_SERVICE_URL + "/health",
+		"metadata-service":  METADATA_SERVICE_URL + "/health",
+		"mcp-gateway":       MCP_GATEWAY_URL + "/health",
+		"mcp-tools":         MCP_GATEWAY_URL + "/tools/health",
+	}
+
+	results := make(map[string]DependencyHealth, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, url := range targets {
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+			result := probeDependency(r.Context(), url)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, url)
+	}
+	wg.Wait()
+
+	// Report the circuit the agent's own calls go through
+	for name, client := range map[string]*httpclient.Client{"retrieval-service": ragClient, "mcp-gateway": mcpClient} {
+		result := results[name]
+		if result.Details == nil {
+			result.Details = map[string]interface{}{}
+		}
+		result.Details["circuit"] = client.CircuitState()
+		results[name] = result
+	}
+
+	// Report the tool catalog the agent plans with
+	tools := results["mcp-tools"]
+	if tools.Details == nil {
+		tools.Details = map[string]interface{}{}
+	}
+	tools.Details["catalog"] = mcpTools.Names()
+	if updated := mcpTools.Updated(); !updated.IsZero() {
+		tools.Details["catalog_refreshed_at"] = updated.UTC()
+	}
+	results["mcp-tools"] = tools
+
+	overall := "healthy"
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status != "healthy" {
+			overall = "degraded"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"status":       overall,
+		"service":      "agent-orchestrator",
+		"dependencies": results,
+		"checked_at":   time.Now().UTC(),
+	}, status)
+}
+
+// probeDependency calls a /health endpoint. A 2xx response is healthy unless
+// its body reports a different "status" (e.g. vector-service's "degraded").
+func probeDependency(ctx context.Context, url string) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, deepHealthTimeout)
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return DependencyHealth{Status: "unhealthy", Error: err.Error()}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	latency := float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		return DependencyHealth{Status: "unhealthy", LatencyMs: latency, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	result := DependencyHealth{Status: "healthy", LatencyMs: latency}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(body, &result.Details) == nil {
+		if s, ok := result.Details["status"].(string); ok && s != "healthy" {
+			result.Status = "degraded"
+		}
+	}
+	if resp.StatusCode >= 300 {
+		result.Status = "unhealthy"
+		result.Error = http.StatusText(resp.StatusCode)
+	}
+	return result
+}
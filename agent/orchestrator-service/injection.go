@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"shared/flags"
+)
+
+// ============================================================================
+// PROMPT-INJECTION SCREENING
+// ============================================================================
+// Retrieved chunks and tool results, web-search snippets among them, are
+// quoted into the synthesis prompt and fed back to the model in react mode.
+// A document saying "ignore previous instructions and ..." could steer the
+// agent, so every action's result is screened as it comes back: instruction
+// overrides, attempts to give the model a new role, chat-template markup and
+// requests for the system prompt are replaced with a placeholder before any
+// model sees them. Each result with findings is recorded as a "guardrail"
+// step. The synthesis prompt also tells the model to treat what was gathered
+// as reference material only. Turn screening off with the
+// agent_injection_screening flag.
+
+var injectionFlag = flags.Define("agent_injection_screening", true,
+	"Neutralize instruction-like content in retrieved chunks and tool results")
+
+var injectionFindings = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_injection_findings_total",
+	Help: "Instruction-like passages neutralized in action results, by kind.",
+}, []string{"kind"})
+
+// injectionPlaceholder replaces the instruction-like text.
+const injectionPlaceholder = "[removed: possible prompt injection]"
+
+// injectionPatterns are the kinds of instruction-like content neutralized.
+var injectionPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"instruction override", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,30}?\b(all|any|the|your|previous|prior|above|earlier|preceding|system)\b.{0,30}?\b(instructions?|prompts?|rules|directions|guidelines)\b`)},
+	{"role change", regexp.MustCompile(`(?i)\byou\s+are\s+now\b|\bfrom\s+now\s+on,?\s+you\b|\bpretend\s+(to\s+be|you\s+are)\b|\b(act|behave|respond)\s+as\s+(an?\s+)?(unrestricted|unfiltered|jailbroken|different)\b`)},
+	{"prompt markup", regexp.MustCompile(`(?im)^\s*(system|assistant)\s*:|<\|?(system|im_start|im_end)\|?>|\[/?(INST|SYS)\]|<</?SYS>>`)},
+	{"prompt extraction", regexp.MustCompile(`(?i)\b(reveal|print|repeat|show|output|leak)\b.{0,20}?\b(system\s+prompt|hidden\s+instructions|your\s+instructions|your\s+prompt)\b`)},
+}
+
+// neutralizeInjections replaces instruction-like content in text, adding
+// what it replaced to counts.
+func neutralizeInjections(text string, counts map[string]int) string {
+	for _, p := range injectionPatterns {
+		text = p.pattern.ReplaceAllStringFunc(text, func(string) string {
+			counts[p.kind]++
+			return injectionPlaceholder
+		})
+	}
+	return text
+}
+
+// screenResult neutralizes instruction-like content in every string of an
+// action's result, in place, and returns what it replaced by kind.
+func screenResult(result map[string]interface{}) map[string]int {
+	counts := map[string]int{}
+	for key, value := range result {
+		result[key] = screenValue(value, counts)
+	}
+	for kind, n := range counts {
+		injectionFindings.WithLabelValues(kind).Add(float64(n))
+	}
+	return counts
+}
+
+func screenValue(value interface{}, counts map[string]int) interface{} {
+	switch v := value.(type) {
+	case string:
+		return neutralizeInjections(v, counts)
+	case map[string]interface{}:
+		for key, nested := range v {
+			v[key] = screenValue(nested, counts)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = screenValue(nested, counts)
+		}
+	}
+	return value
+}
+
+// injectionStep records what was neutralized in an action's result.
+func injectionStep(action Action, counts map[string]int, start time.Time) AgentStep {
+	return AgentStep{
+		Type:        "guardrail",
+		Description: fmt.Sprintf("Screen %s result for prompt injection", action.Type),
+		Action:      describeActions([]Action{action}),
+		Result:      "neutralized " + describeCounts(counts),
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"shared/tenant"
+)
+
+// ============================================================================
+// ASYNC JOBS
+// ============================================================================
+// POST /agent/jobs accepts the same body as /agent/query but answers 202 at
+// once with a job ID; GET /agent/jobs/{id} reports the job until it has a
+// result, so a long multi-iteration query needs no held connection. A job
+// moves queued → running → completed, or failed when it times out. Jobs run
+// on their own worker pool and live in process memory; a job stays queued
+// until the cap on running agent loops, AGENT_QUERY_WORKERS, leaves it room:
+//
+//	AGENT_JOB_WORKERS      jobs run at once (default 4)
+//	AGENT_JOB_QUEUE_DEPTH  jobs waiting for a worker before 503 (default 100)
+//	AGENT_JOB_TIMEOUT      time a job may wait and run (default 10m)
+//	AGENT_JOB_TTL          how long a finished job stays readable (default 1h)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+// Job - An agent query run in the background
+type Job struct {
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	ConversationID string         `json:"conversation_id"`
+	Query          string         `json:"query"`
+	CreatedAt      time.Time      `json:"created_at"`
+	StartedAt      *time.Time     `json:"started_at,omitempty"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	Steps          []AgentStep    `json:"steps"` // progress so far while running
+	Result         *AgentResponse `json:"result,omitempty"`
+	Error          string         `json:"error,omitempty"`
+
+	tenantID string
+	ctx      context.Context
+	req      AgentRequest
+}
+
+// jobQueue runs jobs on a fixed pool of workers and keeps them readable
+// until AGENT_JOB_TTL after they finish.
+type jobQueue struct {
+	mu      sync.RWMutex
+	jobs    map[string]*Job // keyed by conversationKey(tenant, job ID)
+	pending chan *Job
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+var agentJobs *jobQueue
+
+func newJobQueue() *jobQueue {
+	q := &jobQueue{
+		jobs:    make(map[string]*Job),
+		pending: make(chan *Job, max(envInt("AGENT_JOB_QUEUE_DEPTH", 100), 0)),
+		timeout: envDuration("AGENT_JOB_TIMEOUT", 10*time.Minute),
+		ttl:     envDuration("AGENT_JOB_TTL", time.Hour),
+	}
+	for i := 0; i < max(envInt("AGENT_JOB_WORKERS", 4), 1); i++ {
+		go q.work()
+	}
+	go q.sweep()
+	return q
+}
+
+// Submit queues req to run in the background. The job keeps ctx's values
+// (tenant, trace) but not its cancellation, so it outlives the request. It
+// returns false when the queue is full.
+func (q *jobQueue) Submit(ctx context.Context, req AgentRequest) (*Job, bool) {
+	job := &Job{
+		ID:             uuid.New().String(),
+		Status:         jobQueued,
+		ConversationID: req.ConversationID,
+		Query:          req.Query,
+		CreatedAt:      time.Now(),
+		Steps:          []AgentStep{},
+		tenantID:       tenant.FromContext(ctx),
+		ctx:            context.WithoutCancel(ctx),
+		req:            req,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- job:
+	default:
+		return nil, false
+	}
+	q.jobs[conversationKey(job.tenantID, job.ID)] = job
+	copied := job.snapshot()
+	return &copied, true
+}
+
+// Get returns a copy of the tenant's job, or nil if there is none.
+func (q *jobQueue) Get(tenantID, jobID string) *Job {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	job, ok := q.jobs[conversationKey(tenantID, jobID)]
+	if !ok {
+		return nil
+	}
+	copied := job.snapshot()
+	return &copied
+}
+
+// snapshot copies the job so it can be encoded without holding the lock.
+func (j *Job) snapshot() Job {
+	copied := *j
+	copied.Steps = append([]AgentStep{}, j.Steps...)
+	return copied
+}
+
+func (q *jobQueue) work() {
+	for job := range q.pending {
+		q.run(job)
+	}
+}
+
+func (q *jobQueue) run(job *Job) {
+	ctx, cancel := context.WithTimeout(job.ctx, q.timeout)
+	defer cancel()
+
+	// A job waits, still queued, for a free agent slot
+	started := time.Now()
+	var response AgentResponse
+	release, err := agentGate.Wait(ctx)
+	if err != nil {
+		err = fmt.Errorf("job timed out after %s waiting for a free agent slot", q.timeout)
+	} else {
+		started = time.Now()
+		q.update(job, func() {
+			job.Status = jobRunning
+			job.StartedAt = &started
+		})
+		response, err = q.execute(ctx, job)
+		release()
+	}
+
+	completed := time.Now()
+	q.update(job, func() {
+		job.CompletedAt = &completed
+		if err != nil {
+			job.Status = jobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = jobCompleted
+		job.Result = &response
+	})
+	log.Printf("📋 Job %s %s in %s", job.ID, job.Status, completed.Sub(started).Round(time.Millisecond))
+	notifyCallback(job.ctx, job.req, job.ID, response, err)
+}
+
+// execute runs the job's query, turning a panic or timeout into an error.
+func (q *jobQueue) execute(ctx context.Context, job *Job) (response AgentResponse, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("❌ Job %s panicked: %v", job.ID, p)
+			err = fmt.Errorf("internal error")
+		}
+	}()
+	prog := &progress{step: func(step AgentStep) {
+		q.update(job, func() { job.Steps = append(job.Steps, step) })
+	}}
+	response = runAgentQuery(ctx, job.req, prog)
+	if ctx.Err() == context.DeadlineExceeded {
+		return response, fmt.Errorf("job timed out after %s", q.timeout)
+	}
+	return response, nil
+}
+
+func (q *jobQueue) update(job *Job, fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fn()
+}
+
+// sweep forgets finished jobs once their TTL has passed.
+func (q *jobQueue) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		q.mu.Lock()
+		for key, job := range q.jobs {
+			if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > q.ttl {
+				delete(q.jobs, key)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Submit an agent query to run in the background
+func submitJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeAgentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	job, ok := agentJobs.Submit(r.Context(), req)
+	if !ok {
+		w.Header().Set("Retry-After", "30")
+		respondError(w, "Job queue is full, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Printf("📋 Job %s queued: '%s'", job.ID, job.Query)
+	w.Header().Set("Location", "/agent/jobs/"+job.ID)
+	respondJSON(w, job, http.StatusAccepted)
+}
+
+// Get an async job's status and, once completed, its result
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := strings.TrimPrefix(r.URL.Path, "/agent/jobs/")
+	if jobID == "" {
+		respondError(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	job := agentJobs.Get(tenant.FromContext(r.Context()), jobID)
+	if job == nil {
+		respondError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	respondJSON(w, job, http.StatusOK)
+}
@@ -2,20 +2,40 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/genai"
+	"shared/admission"
+	"shared/auth"
+	"shared/events"
+	"shared/faults"
+	"shared/flags"
+	"shared/httpclient"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/ratelimit"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 // ============================================================================
 // DATA MODELS
 // ============================================================================
@@ -26,6 +46,41 @@ type AgentRequest struct {
 	ConversationID string            `json:"conversation_id,omitempty"`
 	MaxIterations  int               `json:"max_iterations,omitempty"`
 	Context        map[string]string `json:"context,omitempty"`
+	Mode           string            `json:"mode,omitempty"`             // "pipeline" (default), "react" or "multi_agent", see react.go, multiagent.go
+	CallbackURL    string            `json:"callback_url,omitempty"`     // POSTed the outcome when done, see callbacks.go
+	DryRun         bool              `json:"dry_run,omitempty"`          // plan and estimate without executing, see estimate.go
+	Candidates     int               `json:"candidates,omitempty"`       // answers to write and judge, see consistency.go
+	FollowUpAnswer string            `json:"follow_up_answer,omitempty"` // resumes the run that asked, see resume.go
+	Persona        string            `json:"persona,omitempty"`          // stored with the conversation, see persona.go
+	UserID         string            `json:"user_id,omitempty"`          // remembered across conversations, see usermemory.go
+
+	// Overrides of the server's settings, see settings.go
+	ConfidenceThreshold float64 `json:"confidence_threshold,omitempty"`
+	Rerank              *bool   `json:"rerank,omitempty"`
+	PartialResults      *bool   `json:"partial_results,omitempty"` // see partial.go
+	Route               string  `json:"route,omitempty"`           // "auto", "fast" or "full", see router.go
+	Clarify             *bool   `json:"clarify,omitempty"`         // ask about ambiguous queries first, see clarify.go
+
+	// JSON Schema the answer must match, see structured.go
+	ResponseSchema map[string]interface{} `json:"response_schema,omitempty"`
+
+	plan   *ExecutionPlan // supplied to /agent/execute, see execute.go
+	schema *genai.Schema  // ResponseSchema for Gemini
+	resume *suspendedRun  // the run FollowUpAnswer resumes
+
+	variant *ExperimentVariant // see experiments.go
+
+	// Budget, see budget.go; 0 is unlimited
+	MaxLLMCalls int `json:"max_llm_calls,omitempty"`
+	MaxTokens   int `json:"max_tokens,omitempty"`
+	DeadlineMs  int `json:"deadline_ms,omitempty"`
+
+	// Gemini models, see models.go
+	Model          string `json:"model,omitempty"`
+	AnalysisModel  string `json:"analysis_model,omitempty"`
+	PlannerModel   string `json:"planner_model,omitempty"`
+	SynthesisModel string `json:"synthesis_model,omitempty"`
+	VerifierModel  string `json:"verifier_model,omitempty"`
 }
 
 // AgentResponse - Final response from agent
@@ -36,22 +91,77 @@ type AgentResponse struct {
 	Confidence     float64     `json:"confidence"`
 	Iterations     int         `json:"iterations"`
 	ToolsUsed      []string    `json:"tools_used"`
-	Sources        []string    `json:"sources"`
+	Sources        []Source    `json:"sources"`   // every chunk retrieved, see citations.go
+	Citations      []Citation  `json:"citations"` // sources of the answer's [n] markers
 	ProcessTime    float64     `json:"process_time_ms"`
 	Steps          []AgentStep `json:"steps"`
+	StepGroups     []StepGroup `json:"step_groups"` // Steps by iteration
 	NeedMoreInfo   bool        `json:"need_more_info"`
 	FollowUpQ      string      `json:"follow_up_question,omitempty"`
+	BudgetExceeded bool        `json:"budget_exceeded"` // stopped early by max_llm_calls, max_tokens or deadline_ms
+	LLMCalls       int         `json:"llm_calls"`
+	TokensUsed     int64       `json:"tokens_used"`
+	Cached         bool        `json:"cached"`              // answered from the answer cache, see answercache.go
+	Degraded       bool        `json:"degraded"`            // answered from partial results after a failure, see partial.go
+	Agreement      float64     `json:"agreement,omitempty"` // between candidate answers, see consistency.go
+	Route          string      `json:"route,omitempty"`     // "fast" or "full", see router.go
+	DryRun         *DryRun     `json:"dry_run,omitempty"`
+	TraceID        string      `json:"trace_id,omitempty"`   // OpenTelemetry trace of the request
+	Experiment     *VariantTag `json:"experiment,omitempty"` // see experiments.go
+
+	// The share of the answer's claims the evidence supports and the claims
+	// it doesn't, when the answer was fact-checked; see factcheck.go
+	Grounding         *float64 `json:"grounding,omitempty"`
+	UnsupportedClaims []string `json:"unsupported_claims,omitempty"`
+
+	// The answer as JSON when response_schema was given and it validated,
+	// otherwise why not; see structured.go
+	Output      json.RawMessage `json:"output,omitempty"`
+	OutputError string          `json:"output_error,omitempty"`
+
+	// What a run that asks a follow-up question had gathered, see resume.go
+	evidence    []map[string]interface{}
+	missingInfo string
+
+	// The iteration steps are recorded in, see beginIteration
+	iteration     int
+	iterationBase int
+
+	// The actions the run executed and how many of them failed, see
+	// runFailure
+	actionsRun    int
+	actionsFailed int
+}
+
+// Source - A retrieved chunk the agent read
+type Source struct {
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	ChunkID      string  `json:"chunk_id"`
+	Collection   string  `json:"collection,omitempty"`
+	Score        float64 `json:"score"` // relevance score from retrieval
 }
 
 // AgentStep - Individual step in agent's reasoning
 type AgentStep struct {
 	StepNumber  int     `json:"step_number"`
-	Type        string  `json:"type"` // "analyze", "plan", "execute", "verify"
+	Type        string  `json:"type"` // "analyze", "plan", "execute", "synthesize", "verify"; "act", "answer" in react mode; "planner", "researcher", "writer", "critic" in multi_agent mode; "guardrail"
 	Description string  `json:"description"`
 	Action      string  `json:"action,omitempty"`
 	Result      string  `json:"result,omitempty"`
 	Success     bool    `json:"success"`
 	Duration    float64 `json:"duration_ms"`
+	Model       string  `json:"model,omitempty"`     // Gemini model that produced the step, after any fallback
+	Cache       string  `json:"cache,omitempty"`     // "hit" or "miss" when the step consulted a cache
+	Iteration   int     `json:"iteration,omitempty"` // iteration, ReAct turn or multi_agent round; 0 outside them
+}
+
+// StepGroup - The steps of one iteration, for UIs to render together
+type StepGroup struct {
+	Iteration int     `json:"iteration"` // 0 for the steps outside any iteration
+	Steps     []int   `json:"steps"`     // step numbers, in order
+	Success   bool    `json:"success"`   // every step succeeded
+	Duration  float64 `json:"duration_ms"`
 }
 
 // ExecutionPlan - Agent's plan of action
@@ -60,6 +170,7 @@ type ExecutionPlan struct {
 	RewrittenQueries []string `json:"rewritten_queries"`
 	Actions          []Action `json:"actions"`
 	Reasoning        string   `json:"reasoning"`
+	Model            string   `json:"model,omitempty"`
 }
 
 // Action - Individual action in the plan
@@ -72,15 +183,29 @@ type Action struct {
 // Conversation - Stores conversation history
 type Conversation struct {
 	ID        string
+	TenantID  string
 	Messages  []Message
 	StartTime time.Time
+	Persona   string // system prompt for every turn, see persona.go
 }
 
 // Message - Single message in conversation
 type Message struct {
-	Role      string    `json:"role"` // "user" or "assistant"
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
+	Role      string      `json:"role"` // "user" or "assistant"
+	Content   string      `json:"content"`
+	Timestamp time.Time   `json:"timestamp"`
+	Steps     []AgentStep `json:"steps,omitempty"` // assistant turns: the reasoning trace
+	ToolsUsed []string    `json:"tools_used,omitempty"`
+	Citations []Citation  `json:"citations,omitempty"`
+}
+
+// ConversationSummary - Listing entry for /agent/conversations
+type ConversationSummary struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Turns     int       `json:"turns"`
+	LastQuery string    `json:"last_query"`
 }
 
 // ============================================================================
@@ -89,16 +214,53 @@ type Message struct {
 
 var (
 	geminiClient  *genai.Client
-	conversations = make(map[string]*Conversation)
+	conversations conversationStore
+
+	eventBus events.Bus
 
 	// Service URLs
 	RAG_SERVICE_URL    = getEnv("RAG_SERVICE_URL", "http://localhost:8084")
 	MCP_GATEWAY_URL    = getEnv("MCP_GATEWAY_URL", "http://localhost:9100")
 	QUERY_REWRITER_URL = getEnv("QUERY_REWRITER_URL", "http://localhost:9001")
 
-	// Agent settings
-	MAX_ITERATIONS       = 5
-	CONFIDENCE_THRESHOLD = 0.7
+	// Clients for the retrieval service and MCP gateway. Each attempt is
+	// bounded by its own timeout as well as by the request's context, and
+	// each client has its own circuit breaker so a downed dependency fails
+	// its actions at once instead of hanging every one of them.
+	ragClient = httpclient.New(httpclient.Options{
+		Timeout:          envDuration("AGENT_RAG_TIMEOUT", 30*time.Second),
+		BreakerThreshold: envInt("AGENT_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  envDuration("AGENT_BREAKER_COOLDOWN", 30*time.Second),
+	})
+	mcpClient = httpclient.New(httpclient.Options{
+		Timeout:          envDuration("AGENT_MCP_TIMEOUT", 60*time.Second),
+		BreakerThreshold: envInt("AGENT_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  envDuration("AGENT_BREAKER_COOLDOWN", 30*time.Second),
+	})
+
+	// Caps the agent loops running at once, across /agent/query, its stream,
+	// /agent/execute and background jobs, since each spends many model calls
+	// of the provider's quota. A request over the cap queues for a while and
+	// is then turned away with 429 and Retry-After.
+	agentGate = admission.New("agent-orchestrator", "agent_query", admission.ConfigFromEnv("AGENT_QUERY", admission.Config{
+		Workers:    8,
+		QueueDepth: 32,
+		MaxWait:    15 * time.Second,
+		Status:     http.StatusTooManyRequests,
+	}))
+
+	// Only probed by /health/deep
+	EMBED_SERVICE_URL    = getEnv("EMBED_SERVICE_URL", "http://localhost:8081")
+	VECTOR_SERVICE_URL   = getEnv("VECTOR_SERVICE_URL", "http://localhost:8082")
+	METADATA_SERVICE_URL = getEnv("METADATA_SERVICE_URL", "http://localhost:8083")
+
+	// Agent settings, overridable per request; see GET /agent/config
+	MAX_ITERATIONS       = envInt("AGENT_MAX_ITERATIONS", 5)
+	CONFIDENCE_THRESHOLD = envFloat("AGENT_CONFIDENCE_THRESHOLD", 0.7)
+
+	// Feature flags, see GET /admin/flags
+	reflectionFlag = flags.Define("agent_reflection", true,
+		"Verify each synthesized answer and iterate until it is complete and confident")
 )
 
 // ============================================================================
@@ -106,32 +268,127 @@ var (
 // ============================================================================
 
 func main() {
-	// Initialize Gemini client
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable not set")
+	shutdownTracing, err := tracing.Init("agent-orchestrator")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	var err error
+	// Initialize Gemini client; without a key only other providers' models work
 	ctx := context.Background()
-	geminiClient, err = genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: apiKey,
-	})
+	if apiKey := os.Getenv("GEMINI_API_KEY"); apiKey != "" {
+		geminiClient, err = genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey: apiKey,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create Gemini client: %v", err)
+		}
+		log.Println("✅ Gemini client initialized")
+	} else {
+		log.Println("⚠️  GEMINI_API_KEY not set; only models of other providers can be used")
+	}
+	if _, _, err := providerFor(AGENT_MODEL); err != nil {
+		log.Fatalf("Invalid AGENT_MODEL: %v", err)
+	}
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	httpclient.ConfigureDefaultTransport()
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("agent-orchestrator", RAG_SERVICE_URL, MCP_GATEWAY_URL, QUERY_REWRITER_URL)
+
+	if err := flags.Load(ctx); err != nil {
+		log.Fatalf("Failed to load feature flags: %v", err)
+	}
+
+	eventBus, err = events.Connect("agent-orchestrator")
+	if err != nil {
+		log.Fatalf("Failed to connect to event bus: %v", err)
+	}
+	defer eventBus.Close()
+
+	conversations, err = newConversationStore()
 	if err != nil {
-		log.Fatalf("Failed to create Gemini client: %v", err)
+		log.Fatalf("Failed to open conversation store: %v", err)
 	}
+	defer conversations.Close()
 
-	log.Println("✅ Gemini client initialized")
+	audits, err = newAuditStore()
+	if err != nil {
+		log.Fatalf("Failed to open audit store: %v", err)
+	}
+	if audits != nil {
+		defer audits.Close()
+	}
+
+	if err := subscribeAnswerInvalidation(eventBus); err != nil {
+		log.Fatalf("Failed to subscribe to document events: %v", err)
+	}
+
+	if experiment, err = loadExperiment(AGENT_EXPERIMENT_FILE); err != nil {
+		log.Fatalf("Failed to load experiment: %v", err)
+	}
+
+	agentJobs = newJobQueue()
+	searchResults = newSearchCache()
+	registerCircuitMetrics()
+	go mcpTools.watch(envDuration("AGENT_TOOL_REFRESH_INTERVAL", 5*time.Minute))
+	go prompts.watch(AGENT_PROMPTS_DIR, envDuration("AGENT_PROMPTS_REFRESH", 30*time.Second))
+
+	limiter, err := ratelimit.New()
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+	defer limiter.Close()
 
 	// Setup routes
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/agent/query", agentQueryHandler)
+	http.HandleFunc("/health/deep", deepHealthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("agent-orchestrator"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("agent-orchestrator", map[string]server.Check{
+		"retrieval-service": server.HTTPCheck(RAG_SERVICE_URL + "/healthz"),
+		"mcp-gateway":       server.HTTPCheck(MCP_GATEWAY_URL + "/healthz"),
+		"conversations":     conversations.Ping,
+	}))
+	http.HandleFunc("/agent/query", agentGate.Wrap(agentQueryHandler))
+	http.HandleFunc("/agent/query/stream", agentGate.Wrap(agentStreamHandler))
+	http.HandleFunc("/agent/jobs", submitJobHandler)
+	http.HandleFunc("/agent/jobs/", jobHandler)
 	http.HandleFunc("/agent/plan", planHandler)
+	http.HandleFunc("/agent/execute", agentGate.Wrap(executePlanHandler))
 	http.HandleFunc("/agent/history/", historyHandler)
+	http.HandleFunc("/agent/conversations", conversationsHandler)
+	http.HandleFunc("/agent/conversations/", personaHandler)
+	http.HandleFunc("/agent/users/", userMemoryHandler)
+	http.HandleFunc("/agent/config", configHandler)
+	http.HandleFunc("/agent/stats", statsHandler)
+	http.HandleFunc("/agent/experiments", experimentsHandler)
+	http.HandleFunc("/agent/feedback", feedbackHandler)
+	http.HandleFunc("/agent/audit", auditHandler)
+	http.HandleFunc("/agent/audit/", auditHandler)
+	http.HandleFunc("/admin/flags", flags.Handler("agent-orchestrator"))
+	http.HandleFunc("/admin/answer-cache/invalidate", invalidateAnswerCacheHandler)
 
 	port := getEnv("PORT", "9000")
 	log.Printf("🤖 Agent Orchestrator Service starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("agent-orchestrator", handler)
+	handler = ratelimit.Wrap("agent-orchestrator", limiter, ratelimit.ConfigFromEnv(), handler)
+	handler = metrics.Wrap("agent-orchestrator", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/admin/", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // ============================================================================
@@ -152,21 +409,66 @@ func agentQueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startTime := time.Now()
+	req, ok := decodeAgentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	response := runAgentQuery(r.Context(), req, nil)
+	notifyCallback(r.Context(), req, "", response, r.Context().Err())
+	respondJSON(w, response, http.StatusOK)
+}
 
+// decodeAgentRequest reads and defaults an AgentRequest, answering 400 and
+// returning false when it is unusable.
+func decodeAgentRequest(w http.ResponseWriter, r *http.Request) (AgentRequest, bool) {
 	var req AgentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, "Invalid request body", http.StatusBadRequest)
-		return
+		return req, false
 	}
+	if req.FollowUpAnswer != "" && !resumeRequest(w, r.Context(), &req) {
+		return req, false
+	}
+	return req, prepareAgentRequest(w, &req)
+}
 
+// prepareAgentRequest validates req and fills in its defaults, responding
+// with 400 and returning false when it is invalid.
+func prepareAgentRequest(w http.ResponseWriter, req *AgentRequest) bool {
 	if req.Query == "" {
 		respondError(w, "Query cannot be empty", http.StatusBadRequest)
-		return
+		return false
+	}
+
+	if req.MaxIterations < 0 || req.ConfidenceThreshold < 0 || req.ConfidenceThreshold > 1 {
+		respondError(w, "max_iterations cannot be negative and confidence_threshold must be between 0 and 1", http.StatusBadRequest)
+		return false
+	}
+	applySettings(req)
+
+	if req.MaxLLMCalls < 0 || req.MaxTokens < 0 || req.DeadlineMs < 0 {
+		respondError(w, "max_llm_calls, max_tokens and deadline_ms cannot be negative", http.StatusBadRequest)
+		return false
+	}
+
+	if err := validateRoute(req.Route); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	if err := validatePersona(req.Persona); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := validateUserID(req.UserID); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
 
-	if req.MaxIterations == 0 {
-		req.MaxIterations = MAX_ITERATIONS
+	if req.Candidates < 0 || req.Candidates > maxCandidates {
+		respondError(w, fmt.Sprintf("candidates must be between 0 and %d", maxCandidates), http.StatusBadRequest)
+		return false
 	}
 
 	// Create or get conversation
@@ -174,15 +476,207 @@ func agentQueryHandler(w http.ResponseWriter, r *http.Request) {
 		req.ConversationID = uuid.New().String()
 	}
 
+	if req.Mode == "" {
+		req.Mode = AGENT_MODE
+	}
+	if req.Mode != modePipeline && req.Mode != modeReAct && req.Mode != modeMultiAgent {
+		respondError(w, fmt.Sprintf("mode must be %q, %q or %q", modePipeline, modeReAct, modeMultiAgent), http.StatusBadRequest)
+		return false
+	}
+
+	enroll(req)
+	if err := resolveModels(req); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	if req.ResponseSchema != nil {
+		if req.Mode == modeReAct {
+			respondError(w, "response_schema is not supported in react mode", http.StatusBadRequest)
+			return false
+		}
+		schema, err := convertSchema(req.ResponseSchema)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		req.schema = schema
+	}
+
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// runAgentQuery runs the agentic loop for req and announces the result on
+// the event bus. It backs both the blocking and the streaming endpoint.
+func runAgentQuery(ctx context.Context, req AgentRequest, prog *progress) (response AgentResponse) {
+	startTime := time.Now()
 	log.Printf("🤖 Agent processing query: '%s' (conversation: %s)", req.Query, req.ConversationID)
 
+	ctx, span := tracing.Start(ctx, "agent.run",
+		attribute.String("agent.mode", req.Mode),
+		attribute.String("agent.conversation_id", req.ConversationID),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("agent.iterations", response.Iterations),
+			attribute.Float64("agent.confidence", response.Confidence),
+			attribute.Int("agent.llm_calls", response.LLMCalls),
+			attribute.Int64("agent.tokens_used", response.TokensUsed),
+			attribute.Bool("agent.budget_exceeded", response.BudgetExceeded),
+			attribute.Bool("agent.cached", response.Cached),
+			attribute.Bool("agent.degraded", response.Degraded),
+		)
+		span.End()
+	}()
+
+	response = AgentResponse{
+		ConversationID: req.ConversationID,
+		Query:          req.Query,
+		Steps:          []AgentStep{},
+		ToolsUsed:      []string{},
+		Sources:        []Source{},
+		Citations:      []Citation{},
+		TraceID:        tracing.TraceID(ctx),
+		Experiment:     req.variant.tag(),
+	}
+
+	// Every Gemini call of the turn speaks with the conversation's persona
+	ctx = applyPersona(ctx, &req)
+	ctx = withRerank(ctx, req.Rerank)
+	ctx = withVariant(ctx, req.variant)
+	ctx = recallUserMemory(ctx, req, &response, prog)
+	ctx = withAuditTrail(ctx)
+
+	// Screen the query before spending any Gemini calls on it
+	guarded := guardrailsFlag.Enabled()
+	blocked := ""
+	if guarded {
+		blocked = screenQuery(req.Query)
+		recordStep(&response, prog, inputGuardrailStep(blocked, startTime))
+	}
+
+	// Answer a near-duplicate of an earlier question from the cache
+	cacheable := blocked == "" && req.plan == nil && req.resume == nil && !req.DryRun && answerCacheable(ctx, req)
+	cached := false
+	if cacheable {
+		ctx, cached = answerFromCache(ctx, req, &response, prog)
+	}
+
 	// Execute agentic loop
-	response := executeAgenticLoop(req)
+	loopCtx, cancel := withBudget(ctx, req)
+	switch {
+	case blocked != "":
+		log.Printf("🛡️  Query refused: %s", blocked)
+		guardrailBlocks.WithLabelValues(blocked).Inc()
+		response.Answer = refusalAnswer(blocked)
+	case req.DryRun:
+		dryRun(loopCtx, req, &response, prog)
+	case cached:
+		// Filled in by answerFromCache
+	case req.plan != nil:
+		executeSuppliedPlan(loopCtx, req, &response, prog)
+	case req.resume != nil:
+		executeLoop(loopCtx, req, &response, prog)
+	default:
+		// An ambiguous query is asked about before any planning, see clarify.go
+		if clarifying(req) && clarifyQuery(loopCtx, req, &response, prog) {
+			break
+		}
+		// Simple questions skip the loop, see router.go
+		response.Route = routeQuery(loopCtx, req, &response, prog)
+		if response.Route == routeFast {
+			executeFastPath(loopCtx, req, &response, prog)
+		} else {
+			executeLoop(loopCtx, req, &response, prog)
+		}
+	}
+	response.LLMCalls, response.TokensUsed = budgetFrom(loopCtx).usage()
+	response.iteration = 0 // the steps from here on follow the loop
+	cancel()
+	if response.BudgetExceeded {
+		log.Printf("💸 Budget exceeded after %d LLM calls, %d tokens", response.LLMCalls, response.TokensUsed)
+		if response.Answer == "" {
+			response.Answer = "The request's budget ran out before an answer could be produced."
+		}
+	}
+
+	// Redact sensitive numbers before the answer is returned or stored
+	if guarded && blocked == "" && !req.DryRun {
+		redactStart := time.Now()
+		var redacted map[string]int
+		response.Answer, redacted = redactPII(response.Answer)
+		for i := range response.Citations {
+			var more map[string]int
+			response.Citations[i].Snippet, more = redactPII(response.Citations[i].Snippet)
+			for kind, n := range more {
+				redacted[kind] += n
+			}
+		}
+		for kind, n := range redacted {
+			guardrailRedactions.WithLabelValues(kind).Add(float64(n))
+		}
+		recordStep(&response, prog, outputGuardrailStep(redacted, redactStart))
+	}
+
+	// Hand programmatic callers the answer only once it matches their schema
+	if req.schema != nil && blocked == "" && !req.DryRun {
+		var err error
+		if response.Output, err = checkOutput(response.Answer, req.ResponseSchema); err != nil {
+			response.OutputError = err.Error()
+		}
+	}
+
+	if cacheable && !cached {
+		cacheAnswer(ctx, req, response)
+	}
+
+	response.StepGroups = groupSteps(response.Steps)
+
+	// A dry run has no answer to record
+	if req.DryRun {
+		response.ProcessTime = float64(time.Since(startTime).Milliseconds())
+		recordAudit(ctx, req, response)
+		return response
+	}
+
+	suspendRun(ctx, req, response)
+	usage.recordRun(tenant.FromContext(ctx), req, response)
+
+	// A resumed run's turn is the user's clarification
+	message := req.Query
+	if req.resume != nil {
+		message = req.FollowUpAnswer
+	}
+	if err := storeConversation(ctx, tenant.FromContext(ctx), req.ConversationID, message, response); err != nil {
+		log.Printf("Failed to store conversation %s: %v", req.ConversationID, err)
+	}
+	if req.UserID != "" && blocked == "" && userMemoryFlag.Enabled() {
+		go rememberTurn(context.WithoutCancel(ctx), req, message, response.Answer)
+	}
+
 	response.ProcessTime = float64(time.Since(startTime).Milliseconds())
+	req.variant.record(response, time.Since(startTime))
 
 	log.Printf("✅ Agent completed in %.2fms (%d iterations)", response.ProcessTime, response.Iterations)
+	recordAudit(ctx, req, response)
+
+	if err := eventBus.Publish(ctx, events.AgentCompleted, map[string]interface{}{
+		"conversation_id": response.ConversationID,
+		"query":           response.Query,
+		"confidence":      response.Confidence,
+		"iterations":      response.Iterations,
+		"tools_used":      response.ToolsUsed,
+	}); err != nil {
+		log.Printf("Failed to publish %s: %v", events.AgentCompleted, err)
+	}
 
-	respondJSON(w, response, http.StatusOK)
+	return response
 }
 
 // Get execution plan without executing
@@ -197,8 +691,35 @@ func planHandler(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.Query == "" {
+		respondError(w, "Query cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if err := resolveModels(&req); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	plan, err := createExecutionPlan(req.Query, req.Context)
+	if err := validatePersona(req.Persona); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateUserID(req.UserID); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Plan as the conversation's persona, without storing a new one, and
+	// with what is known about the user
+	ctx := r.Context()
+	if req.Persona == "" {
+		req.Persona = conversationPersona(ctx, tenant.FromContext(ctx), req.ConversationID)
+	}
+	ctx = withPersona(ctx, req.Persona)
+	ctx = recallUserMemory(ctx, req, &AgentResponse{}, nil)
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+	plan, err := createExecutionPlan(ctx, req.PlannerModel, req.Query, req.Context, history)
 	if err != nil {
 		respondError(w, fmt.Sprintf("Failed to create plan: %v", err), http.StatusInternalServerError)
 		return
@@ -219,9 +740,18 @@ func historyHandler(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "Conversation ID required", http.StatusBadRequest)
 		return
 	}
+	if id, ok := strings.CutSuffix(conversationID, "/export"); ok {
+		exportConversation(w, r, id) // see export.go
+		return
+	}
 
-	conv, exists := conversations[conversationID]
-	if !exists {
+	conv, err := conversations.Get(r.Context(), tenant.FromContext(r.Context()), conversationID)
+	if err != nil {
+		log.Printf("Failed to load conversation %s: %v", conversationID, err)
+		respondError(w, "Failed to load conversation", http.StatusInternalServerError)
+		return
+	}
+	if conv == nil {
 		respondError(w, "Conversation not found", http.StatusNotFound)
 		return
 	}
@@ -229,105 +759,215 @@ func historyHandler(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, conv, http.StatusOK)
 }
 
+// List recent conversations, most recently updated first
+func conversationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 20
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	summaries, err := conversations.List(r.Context(), tenant.FromContext(r.Context()), limit)
+	if err != nil {
+		log.Printf("Failed to list conversations: %v", err)
+		respondError(w, "Failed to list conversations", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"conversations": summaries,
+		"count":         len(summaries),
+	}, http.StatusOK)
+}
+
 // ============================================================================
 // AGENTIC LOOP - THE CORE LOGIC
 // ============================================================================
 
-func executeAgenticLoop(req AgentRequest) AgentResponse {
-	response := AgentResponse{
-		ConversationID: req.ConversationID,
-		Query:          req.Query,
-		Steps:          []AgentStep{},
-		ToolsUsed:      []string{},
-		Sources:        []string{},
-	}
+// executeAgenticLoop runs analyze → plan → execute → synthesize → verify
+// until the answer is good enough, filling in response. prog, when non-nil,
+// is told about each step and answer token as it happens.
+func executeAgenticLoop(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	// Load the earlier turns before this one is stored
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
 
 	var finalAnswer string
+	citations := []Citation{}
 	var confidence float64
+	var agreement *consensus
+
+	// A resumed run answers from what it had gathered too
+	var carried []map[string]interface{}
+	if req.resume != nil {
+		carried = resumeStep(req, response, prog)
+	}
+
+	// outOfBudget stops the loop before an LLM step the budget can't pay for
+	outOfBudget := func(stage string) bool {
+		if !budgetExceeded(ctx) {
+			return false
+		}
+		log.Printf("  💸 Budget exhausted before %s", stage)
+		response.BudgetExceeded = true
+		return true
+	}
 
 	// Agentic loop with max iterations
 	for iteration := 1; iteration <= req.MaxIterations; iteration++ {
+		if ctx.Err() == context.Canceled {
+			log.Printf("  🛑 Request cancelled, stopping")
+			break
+		}
+		if outOfBudget("the next iteration") {
+			break
+		}
 		log.Printf("  🔄 Iteration %d/%d", iteration, req.MaxIterations)
+		response.beginIteration(iteration)
 
 		// STEP 1: ANALYZE QUERY
-		step1Start := time.Now()
-		analysis := analyzeQuery(req.Query, req.Context)
-		response.Steps = append(response.Steps, AgentStep{
-			StepNumber:  len(response.Steps) + 1,
-			Type:        "analyze",
-			Description: "Analyze user query and intent",
-			Result:      analysis,
-			Success:     true,
-			Duration:    float64(time.Since(step1Start).Milliseconds()),
-		})
-		log.Printf("    ✓ Analysis: %s", analysis)
+		// The analysis only informs the trace, so it is dropped first when
+		// the budget can't also pay for planning and synthesis
+		if left := callsLeft(ctx); left < 0 || left >= 3 {
+			step1Start := time.Now()
+			stepCtx, span := tracing.Start(ctx, "agent.analyze", attribute.Int("iteration", iteration))
+			analysis, analysisModel := analyzeQuery(stepCtx, req.AnalysisModel, req.Query, req.Context, history)
+			span.End()
+			recordStep(response, prog, AgentStep{
+				Type:        "analyze",
+				Description: "Analyze user query and intent",
+				Result:      analysis,
+				Success:     true,
+				Duration:    float64(time.Since(step1Start).Milliseconds()),
+				Model:       analysisModel,
+			})
+			log.Printf("    ✓ Analysis: %s", analysis)
+		}
 
 		// STEP 2: CREATE EXECUTION PLAN
+		if outOfBudget("planning") {
+			break
+		}
 		step2Start := time.Now()
-		plan, err := createExecutionPlan(req.Query, req.Context)
+		stepCtx, span := tracing.Start(ctx, "agent.plan", attribute.Int("iteration", iteration))
+		var plan *ExecutionPlan
+		var planCache string
+		var err error
+		if iteration == 1 {
+			plan, planCache, err = planWithCache(stepCtx, req, history)
+		} else {
+			plan, err = createExecutionPlan(stepCtx, req.PlannerModel, req.Query, req.Context, history)
+		}
+		tracing.End(span, err)
 		if err != nil {
-			response.Steps = append(response.Steps, AgentStep{
-				StepNumber:  len(response.Steps) + 1,
+			recordStep(response, prog, AgentStep{
 				Type:        "plan",
 				Description: "Create execution plan",
+				Result:      err.Error(),
 				Success:     false,
 				Duration:    float64(time.Since(step2Start).Milliseconds()),
+				Cache:       planCache,
 			})
+			// Keep the previous iteration's answer if the budget ran out
+			if finalAnswer != "" && isBudgetError(ctx, err) {
+				response.BudgetExceeded = true
+				break
+			}
+			if partialResults(req) {
+				if finalAnswer != "" {
+					degrade(response, err.Error())
+					break
+				}
+				answerFromPartial(ctx, req, history, carried, err, response, prog)
+				return
+			}
 			response.Answer = fmt.Sprintf("Failed to create plan: %v", err)
-			return response
+			return
 		}
-		response.Steps = append(response.Steps, AgentStep{
-			StepNumber:  len(response.Steps) + 1,
+		recordStep(response, prog, AgentStep{
 			Type:        "plan",
 			Description: "Create execution plan",
 			Result:      plan.Reasoning,
 			Success:     true,
 			Duration:    float64(time.Since(step2Start).Milliseconds()),
+			Model:       plan.Model,
+			Cache:       planCache,
 		})
 		log.Printf("    ✓ Plan created with %d actions", len(plan.Actions))
 
 		// STEP 3: EXECUTE ACTIONS
-		step3Start := time.Now()
-		executionResults := executeActions(plan.Actions, &response)
-		response.Steps = append(response.Steps, AgentStep{
-			StepNumber:  len(response.Steps) + 1,
-			Type:        "execute",
-			Description: fmt.Sprintf("Execute %d actions", len(plan.Actions)),
-			Result:      fmt.Sprintf("Executed %d actions", len(executionResults)),
-			Success:     true,
-			Duration:    float64(time.Since(step3Start).Milliseconds()),
-		})
-		log.Printf("    ✓ Executed %d actions", len(executionResults))
+		executionResults := slices.Concat(carried, executeStep(ctx, iteration, plan, response, prog))
+		degradeOnFailedActions(req, response, executionResults)
 
 		// STEP 4: SYNTHESIZE ANSWER
+		if outOfBudget("synthesis") {
+			break
+		}
 		step4Start := time.Now()
-		finalAnswer = synthesizeAnswer(req.Query, executionResults)
-		response.Steps = append(response.Steps, AgentStep{
-			StepNumber:  len(response.Steps) + 1,
+		stepCtx, span = tracing.Start(ctx, "agent.synthesize", attribute.Int("iteration", iteration))
+		answer, synthesisModel, answerCitations, verdict := synthesize(stepCtx, req, history, executionResults, prog.tokenSink(response.iteration))
+		span.End()
+		// A synthesis cut off by the deadline is worse than the last answer
+		if finalAnswer != "" && ctx.Err() != nil && outOfBudget("the end of synthesis") {
+			break
+		}
+		recordStep(response, prog, AgentStep{
 			Type:        "synthesize",
 			Description: "Synthesize final answer",
-			Result:      fmt.Sprintf("Generated answer (%d chars)", len(finalAnswer)),
-			Success:     true,
+			Result:      fmt.Sprintf("Generated answer (%d chars, %d citations)", len(answer), len(answerCitations)),
+			Success:     synthesisModel != "",
 			Duration:    float64(time.Since(step4Start).Milliseconds()),
+			Model:       synthesisModel,
 		})
+		// Without a model's answer the previous one, or the passages found, is the best there is
+		if synthesisModel == "" && partialResults(req) {
+			degrade(response, "synthesis failed")
+			if finalAnswer == "" {
+				finalAnswer, citations = quotedAnswer(executionResults)
+				if sink := prog.tokenSink(response.iteration); sink != nil {
+					sink(finalAnswer)
+				}
+			}
+			break
+		}
+		finalAnswer = answer
+		citations = answerCitations
+		agreement = verdict
+		Verification{}.report(response) // not verified yet
+		if verdict != nil {
+			recordStep(response, prog, verdict.step())
+		}
 		log.Printf("    ✓ Answer synthesized")
 
+		// Without reflection the first synthesized answer is final
+		if !reflectionFlag.Enabled() {
+			log.Printf("  ✅ Reflection disabled, returning unverified answer")
+			if verdict != nil {
+				confidence = verdict.Agreement
+			}
+			response.NeedMoreInfo = false
+			break
+		}
+
 		// STEP 5: VERIFY ANSWER
+		if outOfBudget("verification") {
+			break
+		}
 		step5Start := time.Now()
-		verification := verifyAnswer(req.Query, finalAnswer, executionResults)
-		confidence = verification.Confidence
-		response.Steps = append(response.Steps, AgentStep{
-			StepNumber:  len(response.Steps) + 1,
-			Type:        "verify",
-			Description: "Verify answer quality",
-			Result:      fmt.Sprintf("Confidence: %.2f, Complete: %v", verification.Confidence, verification.IsComplete),
-			Success:     true,
-			Duration:    float64(time.Since(step5Start).Milliseconds()),
-		})
+		stepCtx, span = tracing.Start(ctx, "agent.verify", attribute.Int("iteration", iteration))
+		verification := verifyAnswer(stepCtx, req.VerifierModel, req.Query, finalAnswer, executionResults)
+		span.SetAttributes(attribute.Float64("confidence", verification.Confidence))
+		span.End()
+		confidence = agreement.blend(verification.Confidence)
+		recordStep(response, prog, verification.step(step5Start))
+		verification.report(response)
 		log.Printf("    ✓ Verification: confidence=%.2f, complete=%v", verification.Confidence, verification.IsComplete)
 
 		// STEP 6: DECIDE IF DONE
-		if verification.IsComplete && verification.Confidence >= CONFIDENCE_THRESHOLD {
+		if verification.IsComplete && confidence >= req.ConfidenceThreshold {
 			log.Printf("  ✅ Answer is satisfactory (confidence: %.2f)", confidence)
 			response.NeedMoreInfo = false
 			break
@@ -340,6 +980,7 @@ func executeAgenticLoop(req AgentRequest) AgentResponse {
 			log.Printf("  ⚠️  Max iterations reached")
 			response.NeedMoreInfo = true
 			response.FollowUpQ = "I need more information to answer completely. Can you provide more context about: " + verification.MissingInfo
+			response.evidence, response.missingInfo = executionResults, verification.MissingInfo
 			break
 		}
 
@@ -348,117 +989,83 @@ func executeAgenticLoop(req AgentRequest) AgentResponse {
 	}
 
 	response.Answer = finalAnswer
+	response.Citations = citations
 	response.Confidence = confidence
-	response.Iterations = len(response.Steps) / 5 // Roughly 5 steps per iteration
-
-	// Store conversation
-	storeConversation(req.ConversationID, req.Query, finalAnswer)
+	if agreement != nil {
+		response.Agreement = agreement.Agreement
+	}
+}
 
-	return response
+// executeLoop runs the loop of req's mode.
+func executeLoop(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	switch req.Mode {
+	case modeReAct:
+		executeReActLoop(ctx, req, response, prog)
+	case modeMultiAgent:
+		executeMultiAgentLoop(ctx, req, response, prog)
+	default:
+		executeAgenticLoop(ctx, req, response, prog)
+	}
 }
 
 // ============================================================================
 // STEP 1: ANALYZE QUERY
 // ============================================================================
 
-func analyzeQuery(query string, ctxMap map[string]string) string {
-	ctx := context.Background()
-	modelName := "gemini-2.5-pro"
-
-	prompt := fmt.Sprintf(`Analyze this user query and provide a brief analysis:
-
-Query: "%s"
-
-Provide:
-1. Query type (question, request, command)
-2. Domain (compliance, kyc, risk, general)
-3. Intent (what user wants)
-4. Complexity (simple, medium, complex)
-
-Answer in 2-3 sentences.`, query)
+// analyzeQuery returns the analysis and the model that wrote it.
+func analyzeQuery(ctx context.Context, modelName, query string, ctxMap map[string]string, history string) (string, string) {
+	prompt := renderPrompt(ctx, promptAnalyze, promptData{Query: query})
 
 	if len(ctxMap) > 0 {
 		prompt += fmt.Sprintf("\n\nAdditional context: %v", ctxMap)
 	}
+	prompt = withHistory(prompt, history,
+		"The query may be a follow-up: interpret it in light of the conversation so far.")
 
-	resp, err := geminiClient.Models.GenerateContent(ctx, modelName, genai.Text(prompt), nil)
+	resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), nil)
 	if err != nil {
 		log.Printf("Analysis failed: %v", err)
-		return "Unable to analyze query"
+		return "Unable to analyze query", ""
 	}
 
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		parts := resp.Candidates[0].Content.Parts
-		if len(parts) > 0 {
-			return fmt.Sprintf("%v", parts[0])
-		}
+	if analysis := responseText(resp); analysis != "" {
+		return analysis, model
 	}
-
-	return "Query analysis completed"
+	return "Query analysis completed", model
 }
 
 // ============================================================================
 // STEP 2: CREATE EXECUTION PLAN
 // ============================================================================
 
-func createExecutionPlan(query string, ctxMap map[string]string) (*ExecutionPlan, error) {
-	ctx := context.Background()
-	modelName := "gemini-2.5-pro"
-
-	prompt := fmt.Sprintf(`You are an AI agent planning how to answer a user query.
-
-Query: "%s"
-
-Available actions:
-1. search_rag - Search knowledge base (collections: regulatory_docs, merchant_docs, kyc_docs)
-2. call_tool - Call MCP tools (tools: verify-docs, risk-score, web-search, data-extractor)
-3. synthesize - Combine information
+func createExecutionPlan(ctx context.Context, modelName, query string, ctxMap map[string]string, history string) (*ExecutionPlan, error) {
+	prompt := renderPrompt(ctx, promptPlan, promptData{Query: query, Tools: mcpTools.Prompt()})
+	prompt = withHistory(prompt, history,
+		"The query may be a follow-up: resolve references to earlier turns and make every search query self-contained.")
+	prompt = withMemory(prompt, userMemoryFrom(ctx))
 
-Create a plan with 2-4 actions. For each action specify:
-- type (one of above)
-- description (what this action does)
-- parameters (what parameters to pass)
-
-Respond ONLY in JSON format:
-{
-  "rewritten_queries": ["query1", "query2"],
-  "actions": [
-    {"type": "search_rag", "description": "...", "parameters": {"query": "...", "collection": "..."}}
-  ],
-  "reasoning": "Why this plan will work"
-}`, query)
-
-	resp, err := geminiClient.Models.GenerateContent(ctx, modelName, genai.Text(prompt), nil)
+	resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), planningConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return nil, fmt.Errorf("no response from model")
+	plan, ok := planFromFunctionCalls(query, resp.FunctionCalls())
+	if !ok {
+		// The model answered without usable function calls
+		log.Printf("Planner returned no actions, using default plan")
+		plan = defaultPlan(query)
 	}
+	plan.Model = model
 
-	// Extract JSON from response
-	parts := resp.Candidates[0].Content.Parts
-	if len(parts) == 0 {
-		return nil, fmt.Errorf("empty response")
-	}
-
-	responseText := fmt.Sprintf("%v", parts[0])
-
-	// Clean JSON (remove markdown if present)
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimPrefix(responseText, "```")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
-
-	var plan ExecutionPlan
-	plan.OriginalQuery = query
+	return plan, nil
+}
 
-	if err := json.Unmarshal([]byte(responseText), &plan); err != nil {
-		// If JSON parsing fails, create a simple default plan
-		log.Printf("Failed to parse plan JSON, using default: %v", err)
-		plan.RewrittenQueries = []string{query}
-		plan.Actions = []Action{
+// defaultPlan searches the knowledge base for the query as asked.
+func defaultPlan(query string) *ExecutionPlan {
+	return &ExecutionPlan{
+		OriginalQuery:    query,
+		RewrittenQueries: []string{query},
+		Actions: []Action{
 			{
 				Type:        "search_rag",
 				Description: "Search knowledge base",
@@ -468,39 +1075,75 @@ Respond ONLY in JSON format:
 					"top_k":      5,
 				},
 			},
-		}
-		plan.Reasoning = "Default plan: search knowledge base"
+		},
+		Reasoning: "Default plan: search knowledge base",
 	}
-
-	return &plan, nil
 }
 
 // ============================================================================
 // STEP 3: EXECUTE ACTIONS
 // ============================================================================
 
-func executeActions(actions []Action, response *AgentResponse) []map[string]interface{} {
+// executeStep runs the plan's actions and records the execute step.
+func executeStep(ctx context.Context, iteration int, plan *ExecutionPlan, response *AgentResponse, prog *progress) []map[string]interface{} {
+	start := time.Now()
+	auditTrailFrom(ctx).plan(plan)
+	stepCtx, span := tracing.Start(ctx, "agent.execute", attribute.Int("iteration", iteration))
+	results := executeActions(stepCtx, plan.Actions, response, prog)
+	span.End()
+
+	failed := 0
+	for _, result := range results {
+		if result["status"] == "failed" {
+			failed++
+		}
+	}
+	summary := fmt.Sprintf("Executed %d actions", len(results))
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	recordStep(response, prog, AgentStep{
+		Type:        "execute",
+		Description: fmt.Sprintf("Execute %d actions", len(plan.Actions)),
+		Result:      summary,
+		Success:     failed == 0,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	})
+	log.Printf("    ✓ %s", summary)
+	return results
+}
+
+// executeActions runs actions in order and returns their results, with
+// instruction-like content in them neutralized.
+func executeActions(ctx context.Context, actions []Action, response *AgentResponse, prog *progress) []map[string]interface{} {
 	results := []map[string]interface{}{}
+	prefetched := prefetchSearches(ctx, response.ConversationID, actions)
 
 	for i, action := range actions {
 		log.Printf("      Action %d/%d: %s", i+1, len(actions), action.Type)
 
+		actionStart := time.Now()
+		actionCtx, span := tracing.Start(ctx, "agent.action", attribute.String("agent.action_type", action.Type))
 		var result map[string]interface{}
 		var err error
 
 		switch action.Type {
 		case "search_rag":
-			result, err = executeSearchRAG(action.Parameters)
+			collection, _ := action.Parameters["collection"].(string)
+			span.SetAttributes(attribute.String("rag.collection", collection))
+			result, err = executeSearchRAG(actionCtx, response.ConversationID, action.Parameters, prefetched[i])
 			if err == nil {
-				response.Sources = append(response.Sources, "RAG Knowledge Base")
+				response.Sources = addSources(response.Sources, result)
+				chunks, _ := result["results"].([]interface{})
+				span.SetAttributes(attribute.Int("rag.results", len(chunks)), attribute.Bool("rag.cached", result["cached"] == true))
 			}
 
 		case "call_tool":
-			result, err = executeCallTool(action.Parameters)
-			if err == nil {
-				if toolName, ok := action.Parameters["tool"].(string); ok {
-					response.ToolsUsed = append(response.ToolsUsed, toolName)
-				}
+			toolName, _ := action.Parameters["tool"].(string)
+			span.SetAttributes(attribute.String("mcp.tool", toolName))
+			result, err = executeCallTool(actionCtx, action.Parameters)
+			if err == nil && toolName != "" {
+				response.ToolsUsed = append(response.ToolsUsed, toolName)
 			}
 
 		case "synthesize":
@@ -511,7 +1154,15 @@ func executeActions(actions []Action, response *AgentResponse) []map[string]inte
 			err = fmt.Errorf("unknown action type: %s", action.Type)
 		}
 
-		if err != nil {
+		if errors.Is(err, httpclient.ErrCircuitOpen) {
+			// The dependency is known to be down: skip and let the loop go on
+			log.Printf("        ⏭️  Action skipped: %v", err)
+			result = map[string]interface{}{
+				"error":   err.Error(),
+				"status":  "failed",
+				"skipped": true,
+			}
+		} else if err != nil {
 			log.Printf("        ✗ Action failed: %v", err)
 			result = map[string]interface{}{
 				"error":  err.Error(),
@@ -519,16 +1170,51 @@ func executeActions(actions []Action, response *AgentResponse) []map[string]inte
 			}
 		} else {
 			log.Printf("        ✓ Action completed")
+			if result["cached"] != true && prefetched[i] == nil {
+				callLatencies.Observe(action.Type, time.Since(actionStart))
+			}
+		}
+
+		// Screen what came back before any model reads it
+		if err == nil && injectionFlag.Enabled() {
+			screenStart := time.Now()
+			if counts := screenResult(result); len(counts) > 0 {
+				log.Printf("        🛡️  Neutralized %s", describeCounts(counts))
+				span.SetAttributes(attribute.String("agent.injections", describeCounts(counts)))
+				recordStep(response, prog, injectionStep(action, counts, screenStart))
+			}
 		}
 
+		tracing.End(span, err)
+		name := action.Type
+		if tool, _ := action.Parameters["tool"].(string); action.Type == "call_tool" && tool != "" {
+			name = tool
+		}
+		usage.recordAction(tenant.FromContext(ctx), name, err != nil)
+		auditTrailFrom(ctx).action(action, result, actionStart)
 		result["action_type"] = action.Type
 		results = append(results, result)
+		if action.Type != "synthesize" {
+			response.actionsRun++
+			if result["status"] == "failed" {
+				response.actionsFailed++
+			}
+		}
 	}
 
 	return results
 }
 
-func executeSearchRAG(params map[string]interface{}) (map[string]interface{}, error) {
+// ragSearch is a search_rag action's request to the retrieval service and
+// its key in the conversation's search cache.
+type ragSearch struct {
+	body         map[string]interface{}
+	collection   string
+	conversation string
+	key          string
+}
+
+func newRAGSearch(ctx context.Context, conversationID string, params map[string]interface{}) ragSearch {
 	query, _ := params["query"].(string)
 	collection, _ := params["collection"].(string)
 	topK, _ := params["top_k"].(float64)
@@ -543,55 +1229,68 @@ func executeSearchRAG(params map[string]interface{}) (map[string]interface{}, er
 		topK = 5
 	}
 
-	requestBody, _ := json.Marshal(map[string]interface{}{
-		"query":      query,
-		"collection": collection,
-		"top_k":      int(topK),
-	})
+	rerank := rerankFrom(ctx)
+	search := ragSearch{
+		body: map[string]interface{}{
+			"query":      query,
+			"collection": collection,
+			"top_k":      int(topK),
+		},
+		collection:   collection,
+		conversation: conversationKey(tenant.FromContext(ctx), conversationID),
+		key:          searchKey(query, collection, int(topK)),
+	}
+	if rerank != nil {
+		search.body["rerank"] = *rerank
+		search.key += fmt.Sprintf("\x00rerank=%t", *rerank)
+	}
+	return search
+}
 
-	resp, err := http.Post(
-		RAG_SERVICE_URL+"/retrieve",
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return nil, err
+// executeSearchRAG retrieves chunks for a search_rag action, reusing the
+// conversation's earlier result for the same search when there is one.
+// prefetched, when not nil, is the result a batch already fetched (see
+// searchbatch.go).
+func executeSearchRAG(ctx context.Context, conversationID string, params map[string]interface{}, prefetched map[string]interface{}) (map[string]interface{}, error) {
+	search := newRAGSearch(ctx, conversationID, params)
+	if cached := searchResults.Get(search.conversation, search.key); cached != nil {
+		log.Printf("        ♻️  Reusing earlier results for '%s'", search.body["query"])
+		searchCacheLookups.WithLabelValues("hit").Inc()
+		cached["cached"] = true
+		return cached, nil
+	}
+	if searchResults != nil {
+		searchCacheLookups.WithLabelValues("miss").Inc()
 	}
-	defer resp.Body.Close()
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	result := prefetched
+	if result == nil {
+		err := ragClient.PostJSON(ctx, RAG_SERVICE_URL+"/retrieve", search.body, &result, httpclient.Idempotent)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	result["collection"] = search.collection
+	searchResults.Put(search.conversation, search.key, result)
 	return result, nil
 }
 
-func executeCallTool(params map[string]interface{}) (map[string]interface{}, error) {
+func executeCallTool(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
 	toolName, _ := params["tool"].(string)
 	if toolName == "" {
 		return nil, fmt.Errorf("tool name required")
 	}
 
-	requestBody, _ := json.Marshal(map[string]interface{}{
+	// Tools may have side effects, so tool calls are never retried.
+	var result map[string]interface{}
+	err := mcpClient.PostJSON(ctx, MCP_GATEWAY_URL+"/tools/call", map[string]interface{}{
 		"tool":   toolName,
 		"params": params,
-	})
-
-	resp, err := http.Post(
-		MCP_GATEWAY_URL+"/tools/call",
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
+	}, &result)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
 
 	return result, nil
 }
@@ -600,96 +1299,92 @@ func executeCallTool(params map[string]interface{}) (map[string]interface{}, err
 // STEP 4: SYNTHESIZE ANSWER
 // ============================================================================
 
-func synthesizeAnswer(query string, results []map[string]interface{}) string {
-	ctx := context.Background()
-	modelName := "gemini-2.5-pro"
+// synthesizeAnswer writes the answer from the gathered results and returns
+// it with the model that wrote it and the citations for the passages it
+// cites. When onToken is non-nil the answer is streamed from Gemini and each
+// chunk is passed to it as it arrives.
+func synthesizeAnswer(ctx context.Context, modelName, query, history string, results []map[string]interface{}, onToken func(string)) (string, string, []Citation) {
+	contextStr, passages := gatheredContext(results)
+	prompt := synthesisPrompt(ctx, query, history, contextStr)
+	answer, model := generateAnswer(ctx, modelName, prompt, onToken)
+	return answer, model, passages.citationsFor(answer)
+}
 
-	// Prepare context from results
+// synthesisPrompt asks for the answer to query from the gathered context.
+func synthesisPrompt(ctx context.Context, query, history, contextStr string) string {
+	prompt := renderPrompt(ctx, promptSynthesize, promptData{Query: query, Context: contextStr})
+	return withHistory(prompt, history,
+		"The question may be a follow-up: answer it in the context of the conversation so far.")
+}
+
+// gatheredContext quotes results for a prompt: retrieved chunks as numbered
+// passages, everything else summarized, see summarize.go.
+func gatheredContext(results []map[string]interface{}) (string, *passageSet) {
+	passages := &passageSet{}
+	var others []map[string]interface{}
+	for _, result := range results {
+		if result["action_type"] == "search_rag" && result["status"] != "failed" {
+			passages.addSearchResult(result)
+		} else {
+			others = append(others, result)
+		}
+	}
 	contextStr := "Information gathered:\n\n"
-	for i, result := range results {
-		contextStr += fmt.Sprintf("%d. %v\n\n", i+1, result)
+	if len(passages.passages) > 0 {
+		contextStr += "Passages from the knowledge base:\n\n" + passages.prompt()
 	}
+	for i, result := range others {
+		contextStr += fmt.Sprintf("%d. %s result:\n%s\n\n", i+1, result["action_type"], summarizeResult(result))
+	}
+	return contextStr, passages
+}
 
-	prompt := fmt.Sprintf(`Based on the information below, answer this question:
-
-Question: "%s"
-
-%s
-
-Provide a clear, concise answer. If information is insufficient, say so.`, query, contextStr)
+// generateAnswer runs the synthesis prompt, streaming it to onToken when
+// that is non-nil.
+func generateAnswer(ctx context.Context, modelName, prompt string, onToken func(string)) (string, string) {
+	if onToken != nil {
+		if !guardrailsFlag.Enabled() {
+			return streamAnswer(ctx, modelName, prompt, onToken)
+		}
+		redactor := newStreamRedactor(onToken)
+		answer, model := streamAnswer(ctx, modelName, prompt, redactor.Write)
+		redactor.Flush()
+		return answer, model
+	}
 
-	resp, err := geminiClient.Models.GenerateContent(ctx, modelName, genai.Text(prompt), nil)
+	resp, model, err := generateContent(ctx, modelName, genai.Text(prompt), nil)
+	var blocked *blockedError
+	if errors.As(err, &blocked) {
+		log.Printf("Synthesis blocked: %v", err)
+		return declinedAnswer(blocked), model
+	}
 	if err != nil {
 		log.Printf("Synthesis failed: %v", err)
-		return "Unable to synthesize answer from available information."
+		return "Unable to synthesize answer from available information.", ""
 	}
 
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		parts := resp.Candidates[0].Content.Parts
-		if len(parts) > 0 {
-			return fmt.Sprintf("%v", parts[0])
-		}
+	if answer := responseText(resp); answer != "" {
+		return answer, model
 	}
-
-	return "No answer could be generated."
+	return "No answer could be generated.", model
 }
 
 // ============================================================================
 // STEP 5: VERIFY ANSWER
 // ============================================================================
 
+// Verification - What the verify step found, see factcheck.go
 type Verification struct {
 	IsComplete  bool
 	Confidence  float64
 	MissingInfo string
-}
-
-func verifyAnswer(query string, answer string, results []map[string]interface{}) Verification {
-	ctx := context.Background()
-	modelName := "gemini-2.5-pro"
-
-	prompt := fmt.Sprintf(`Evaluate this answer:
-
-Question: "%s"
-Answer: "%s"
+	Model       string `json:"-"`
 
-Is the answer:
-1. Complete (addresses the question fully)
-2. Accurate (based on the information)
-3. Relevant (stays on topic)
-
-Respond in JSON:
-{
-  "is_complete": true/false,
-  "confidence": 0.0-1.0,
-  "missing_info": "what's missing (if not complete)"
-}`, query, answer)
-
-	resp, err := geminiClient.Models.GenerateContent(ctx, modelName, genai.Text(prompt), nil)
-	if err != nil {
-		log.Printf("Verification failed: %v", err)
-		return Verification{IsComplete: true, Confidence: 0.5, MissingInfo: ""}
-	}
-
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		parts := resp.Candidates[0].Content.Parts
-		if len(parts) > 0 {
-			responseText := fmt.Sprintf("%v", parts[0])
-			responseText = strings.TrimPrefix(responseText, "```json")
-			responseText = strings.TrimPrefix(responseText, "```")
-			responseText = strings.TrimSuffix(responseText, "```")
-			responseText = strings.TrimSpace(responseText)
-
-			var v Verification
-			if err := json.Unmarshal([]byte(responseText), &v); err != nil {
-				log.Printf("Failed to parse verification: %v", err)
-				return Verification{IsComplete: true, Confidence: 0.7, MissingInfo: ""}
-			}
-			return v
-		}
-	}
-
-	return Verification{IsComplete: true, Confidence: 0.7, MissingInfo: ""}
+	// The claim checks, when the verifier reported them
+	Checked     bool
+	Claims      []ClaimCheck
+	Unsupported []string
+	Grounding   float64 // share of Claims supported
 }
 
 // ============================================================================
@@ -703,23 +1398,6 @@ func enhanceQueryForIteration(originalQuery, missingInfo string) string {
 	return fmt.Sprintf("%s (specifically about: %s)", originalQuery, missingInfo)
 }
 
-func storeConversation(conversationID, query, answer string) {
-	conv, exists := conversations[conversationID]
-	if !exists {
-		conv = &Conversation{
-			ID:        conversationID,
-			Messages:  []Message{},
-			StartTime: time.Now(),
-		}
-		conversations[conversationID] = conv
-	}
-
-	conv.Messages = append(conv.Messages,
-		Message{Role: "user", Content: query, Timestamp: time.Now()},
-		Message{Role: "assistant", Content: answer, Timestamp: time.Now()},
-	)
-}
-
 func respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -736,3 +1414,24 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(getEnv(key, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
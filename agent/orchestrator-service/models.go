@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genai"
+	"shared/tracing"
+)
+
+// ============================================================================
+// MODEL SELECTION
+// ============================================================================
+// Callers pick the model per request with "model", and per step with
+// analysis_model, planner_model, synthesis_model and verifier_model, e.g. a
+// flash model for the cheap steps and pro for synthesis. A step override
+// beats "model", which beats AGENT_MODEL; verification defaults to
+// AGENT_VERIFIER_MODEL when that is set. A name may name its provider, as in
+// "ollama/llama3.1:8b" (see providers.go). AGENT_ALLOWED_MODELS, a comma
+// separated list, restricts what callers may ask for.
+
+var (
+	AGENT_MODEL          = getEnv("AGENT_MODEL", "gemini-2.5-pro")
+	AGENT_VERIFIER_MODEL = getEnv("AGENT_VERIFIER_MODEL", "")
+	AGENT_ALLOWED_MODELS = splitList(getEnv("AGENT_ALLOWED_MODELS", ""))
+)
+
+var modelNamePattern = regexp.MustCompile(`^([a-z]+/)?[a-zA-Z0-9][a-zA-Z0-9._:-]*$`)
+
+// resolveModels fills every per-step model field of req from its overrides
+// and the defaults, rejecting malformed or disallowed names.
+func resolveModels(req *AgentRequest) error {
+	if req.Model == "" {
+		if req.VerifierModel == "" {
+			req.VerifierModel = AGENT_VERIFIER_MODEL
+		}
+		req.Model = AGENT_MODEL
+	}
+	for _, field := range []*string{&req.AnalysisModel, &req.PlannerModel, &req.SynthesisModel, &req.VerifierModel} {
+		if *field == "" {
+			*field = req.Model
+		}
+	}
+
+	for _, model := range []string{req.Model, req.AnalysisModel, req.PlannerModel, req.SynthesisModel, req.VerifierModel} {
+		if !modelNamePattern.MatchString(model) {
+			return fmt.Errorf("invalid model name %q", model)
+		}
+		if _, _, err := providerFor(model); err != nil {
+			return err
+		}
+		if len(AGENT_ALLOWED_MODELS) > 0 && !contains(AGENT_ALLOWED_MODELS, model) {
+			return fmt.Errorf("model %q is not allowed (allowed: %s)", model, strings.Join(AGENT_ALLOWED_MODELS, ", "))
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// MODEL FALLBACK
+// ============================================================================
+// When a call fails with a quota error (429 / RESOURCE_EXHAUSTED), a 5xx or a
+// network error, it is retried with the next model in AGENT_MODEL_FALLBACKS
+// after the one that failed. Models missing from the chain do not fall back.
+// Set AGENT_MODEL_FALLBACKS to an empty string to disable fallback. Each
+// call is bounded by AGENT_GEMINI_TIMEOUT (default 60s); one that times out
+// falls back like a network error.
+
+var (
+	AGENT_MODEL_FALLBACKS = fallbackChain()
+	AGENT_GEMINI_TIMEOUT  = envDuration("AGENT_GEMINI_TIMEOUT", 60*time.Second)
+)
+
+var modelFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_model_fallbacks_total",
+	Help: "Gemini calls retried with a fallback model, by failed and fallback model.",
+}, []string{"from", "to"})
+
+func fallbackChain() []string {
+	if v, ok := os.LookupEnv("AGENT_MODEL_FALLBACKS"); ok {
+		return splitList(v)
+	}
+	return []string{"gemini-2.5-pro", "gemini-2.5-flash", "gemini-2.5-flash-lite"}
+}
+
+// modelsToTry returns model followed by its fallbacks.
+func modelsToTry(model string) []string {
+	for i, m := range AGENT_MODEL_FALLBACKS {
+		if m == model {
+			return AGENT_MODEL_FALLBACKS[i:]
+		}
+	}
+	return []string{model}
+}
+
+// withCallTimeout bounds a single Gemini call. A call that times out can
+// still fall back to the next model while the request itself has time left;
+// a timeout of zero or less leaves only the request's deadline.
+func withCallTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// geminiFor returns a Gemini client whose requests are cancelled with ctx.
+// genai v0.1.0 sends requests without the caller's context, so a call made
+// through the shared client keeps running after its request is gone.
+func geminiFor(ctx context.Context) *genai.Client {
+	cc := geminiClient.ClientConfig()
+	if cc.Backend == genai.BackendGeminiAPI {
+		// Read from the environment by NewClient but not used with an API key
+		cc.Project, cc.Location = "", ""
+	}
+	base := http.DefaultTransport
+	if cc.HTTPClient != nil && cc.HTTPClient.Transport != nil {
+		base = cc.HTTPClient.Transport
+	}
+	cc.HTTPClient = &http.Client{Transport: contextTransport{ctx: ctx, base: base}}
+
+	client, err := genai.NewClient(ctx, &cc)
+	if err != nil {
+		log.Printf("Failed to bind Gemini client to request context: %v", err)
+		return geminiClient
+	}
+	return client
+}
+
+// contextTransport sends every request with ctx.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// shouldFallBack reports whether err is worth retrying with another model.
+func shouldFallBack(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var clientErr genai.ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.Code == http.StatusTooManyRequests || clientErr.Status == "RESOURCE_EXHAUSTED"
+	}
+	var serverErr genai.ServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+	var providerErr *providerError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode == http.StatusTooManyRequests || providerErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// generateContent calls model through its provider, falling back along the
+// chain, and returns the response with the model that produced it.
+func generateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, string, error) {
+	if persona := personaFrom(ctx); persona != "" {
+		config = withSystemInstruction(config, persona)
+	}
+	models := modelsToTry(model)
+	for i, m := range models {
+		provider, name, err := providerFor(m)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := budgetFrom(ctx).reserve(); err != nil {
+			return nil, "", err
+		}
+		start := time.Now()
+		callCtx, cancel := withCallTimeout(ctx, AGENT_GEMINI_TIMEOUT)
+		callCtx, span := startGeminiSpan(callCtx, m, i)
+		resp, err := provider.generate(callCtx, name, contents, config)
+		var usage *genai.GenerateContentResponseUsageMetadata
+		var blocked error
+		if err == nil {
+			usage = resp.UsageMetadata
+			blocked = checkResponse(resp)
+		}
+		endGeminiSpan(span, usage, errors.Join(err, blocked))
+		cancel()
+		if err == nil {
+			callLatencies.Observe("llm", time.Since(start))
+			budgetFrom(ctx).charge(resp.UsageMetadata)
+			auditTrailFrom(ctx).output(m, responseText(resp), resp.FunctionCalls())
+			if blocked != nil {
+				modelBlocks.WithLabelValues(blocked.(*blockedError).Reason).Inc()
+				return nil, m, blocked
+			}
+			return resp, m, nil
+		}
+		if i == len(models)-1 || !shouldFallBack(ctx, err) {
+			return nil, "", err
+		}
+		log.Printf("⚠️  %s failed (%v), falling back to %s", m, err, models[i+1])
+		modelFallbacks.WithLabelValues(m, models[i+1]).Inc()
+	}
+	return nil, "", errors.New("no model to call")
+}
+
+// startGeminiSpan opens the span of one model call; attempt counts the
+// fallbacks before it.
+func startGeminiSpan(ctx context.Context, model string, attempt int) (context.Context, trace.Span) {
+	provider, name := splitModel(model)
+	return tracing.Start(ctx, "gemini.generate_content",
+		attribute.String("gen_ai.system", provider),
+		attribute.String("gen_ai.request.model", name),
+		attribute.Int("gemini.fallback_attempt", attempt),
+	)
+}
+
+// endGeminiSpan records what the call used, when it is known, and ends span.
+func endGeminiSpan(span trace.Span, usage *genai.GenerateContentResponseUsageMetadata, err error) {
+	if usage != nil {
+		span.SetAttributes(attribute.Int64("gen_ai.usage.total_tokens", usage.TotalTokenCount))
+		if usage.PromptTokenCount != nil {
+			span.SetAttributes(attribute.Int64("gen_ai.usage.input_tokens", *usage.PromptTokenCount))
+		}
+		if usage.CandidatesTokenCount != nil {
+			span.SetAttributes(attribute.Int64("gen_ai.usage.output_tokens", *usage.CandidatesTokenCount))
+		}
+	}
+	tracing.End(span, err)
+}
+
+// ============================================================================
+// RESPONSE PARTS
+// ============================================================================
+// A response is read part by part: its text is the text parts of the first
+// candidate joined in order, leaving out thoughts, function calls and other
+// non-text parts, never the printed form of a part. A response the model
+// was stopped from giving, a blocked prompt or a candidate that finished
+// for safety, recitation, a blocklist, prohibited content, personal data or
+// a malformed function call, is a *blockedError rather than an empty
+// answer. Blocked responses don't fall back, are counted by reason in
+// agent_model_blocked_total and fail the call's span. A candidate cut off
+// at MAX_TOKENS is kept, with a warning.
+
+var modelBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_model_blocked_total",
+	Help: "Model responses blocked or stopped before completion, by reason.",
+}, []string{"reason"})
+
+// blockedError is a response the model was stopped from giving.
+type blockedError struct {
+	Reason     string   // the genai BlockedReason or FinishReason, e.g. SAFETY
+	Message    string   // the provider's explanation, if any
+	Categories []string // harm categories that blocked it
+}
+
+func (e *blockedError) Error() string {
+	msg := "response blocked: " + e.Reason
+	if len(e.Categories) > 0 {
+		msg += " (" + strings.Join(e.Categories, ", ") + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// blockedFinishReasons are the finish reasons that leave no usable answer.
+var blockedFinishReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:                true,
+	genai.FinishReasonRecitation:            true,
+	genai.FinishReasonBlocklist:             true,
+	genai.FinishReasonProhibitedContent:     true,
+	genai.FinishReasonSPII:                  true,
+	genai.FinishReasonMalformedFunctionCall: true,
+}
+
+// checkResponse returns a *blockedError when resp was blocked, or nil.
+func checkResponse(resp *genai.GenerateContentResponse) error {
+	if resp == nil {
+		return nil
+	}
+	if feedback := resp.PromptFeedback; feedback != nil && feedback.BlockReason != "" && feedback.BlockReason != genai.BlockedReasonUnspecified {
+		return &blockedError{
+			Reason:     string(feedback.BlockReason),
+			Message:    feedback.BlockReasonMessage,
+			Categories: blockedCategories(feedback.SafetyRatings),
+		}
+	}
+	if len(resp.Candidates) == 0 {
+		return nil
+	}
+	candidate := resp.Candidates[0]
+	if blockedFinishReasons[candidate.FinishReason] {
+		return &blockedError{
+			Reason:     string(candidate.FinishReason),
+			Message:    candidate.FinishMessage,
+			Categories: blockedCategories(candidate.SafetyRatings),
+		}
+	}
+	if candidate.FinishReason == genai.FinishReasonMaxTokens {
+		log.Printf("⚠️  Response cut off at the output token limit")
+	}
+	return nil
+}
+
+// declinedAnswer is the answer given in place of a blocked one.
+func declinedAnswer(blocked *blockedError) string {
+	return fmt.Sprintf("No answer could be given: the model's response was blocked (%s).", blocked.Reason)
+}
+
+func blockedCategories(ratings []*genai.SafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked {
+			categories = append(categories, string(rating.Category))
+		}
+	}
+	return categories
+}
+
+// responseText joins the text parts of the response's first candidate.
+func responseText(resp *genai.GenerateContentResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Text != "" && !part.Thought {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
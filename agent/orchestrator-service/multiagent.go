@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genai"
+	"shared/tenant"
+	"shared/tracing"
+)
+
+// ============================================================================
+// MULTI-AGENT MODE
+// ============================================================================
+// With "mode": "multi_agent" a query is worked by a team of agents, each with
+// its own role prompt:
+//
+//	planner     decides what evidence to gather (planner_model)
+//	researcher  gathers it; when sent back, decides what else to search (planner_model)
+//	writer      answers from all the evidence so far (synthesis_model)
+//	critic      checks the answer against the evidence (verifier_model)
+//
+// The critic approves the answer or sends the researcher back with the gaps
+// it found, after which the writer answers again from the wider evidence.
+// max_iterations bounds the research rounds. Each agent's turn is a step
+// typed by its role. The planner, researcher and critic role prompts can be
+// replaced with AGENT_PLANNER_PROMPT, AGENT_RESEARCHER_PROMPT and
+// AGENT_CRITIC_PROMPT; the writer uses the pipeline's synthesis prompt.
+// With the agent_reflection flag off there is no critic and the first answer
+// is final.
+
+var (
+	AGENT_PLANNER_PROMPT = getEnv("AGENT_PLANNER_PROMPT", `You are the planner in a team of agents answering questions about
+compliance, KYC and merchant risk. You do not answer the question yourself:
+you decide what evidence the researcher must gather so that the writer can
+answer it fully.`)
+
+	AGENT_RESEARCHER_PROMPT = getEnv("AGENT_RESEARCHER_PROMPT", `You are the researcher in a team of agents answering questions about
+compliance, KYC and merchant risk. You gather evidence by searching the
+knowledge base and calling tools. Write precise, self-contained search
+queries and never repeat a search that was already made.`)
+
+	AGENT_CRITIC_PROMPT = getEnv("AGENT_CRITIC_PROMPT", `You are the critic in a team of agents answering questions about compliance,
+KYC and merchant risk. You check the writer's answer against the evidence:
+the question must be answered in full and every claim must be supported by
+a cited passage. Approve only an answer you would stand behind.`)
+)
+
+// researchConfig forces the researcher to respond with searches or tool calls.
+func researchConfig() *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
+		Tools: []*genai.Tool{{
+			FunctionDeclarations: []*genai.FunctionDeclaration{searchRAGDecl, callToolDecl()},
+		}},
+		ToolConfig: &genai.ToolConfig{
+			FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+		},
+	}
+}
+
+// critiqueConfig forces the critic to respond with a verdict.
+var critiqueConfig = &genai.GenerateContentConfig{
+	Tools: []*genai.Tool{{
+		FunctionDeclarations: []*genai.FunctionDeclaration{
+			{
+				Name:        "approve",
+				Description: "Approve the answer: it answers the question in full and the evidence supports every claim.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"assessment": {Type: genai.TypeString, Description: "Why the answer holds up"},
+						"confidence": {Type: genai.TypeNumber, Description: "Confidence in the answer from 0 to 1"},
+					},
+					Required: []string{"assessment", "confidence"},
+				},
+			},
+			{
+				Name:        "request_research",
+				Description: "Send the researcher back for the evidence the answer is missing.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"assessment": {Type: genai.TypeString, Description: "What is wrong with or missing from the answer"},
+						"confidence": {Type: genai.TypeNumber, Description: "Confidence in the answer as it stands from 0 to 1"},
+						"gaps": {
+							Type:        genai.TypeArray,
+							Items:       &genai.Schema{Type: genai.TypeString},
+							Description: "What the researcher should find out, one item per gap",
+						},
+					},
+					Required: []string{"assessment", "confidence", "gaps"},
+				},
+			},
+		},
+	}},
+	ToolConfig: &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+	},
+}
+
+// critique is the critic's verdict on an answer.
+type critique struct {
+	Approved   bool
+	Assessment string
+	Confidence float64
+	Gaps       []string
+	Model      string
+}
+
+// executeMultiAgentLoop answers req with the planner, researcher, writer and
+// critic agents, filling in response.
+func executeMultiAgentLoop(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+
+	outOfBudget := func(stage string) bool {
+		if !budgetExceeded(ctx) {
+			return false
+		}
+		log.Printf("  💸 Budget exhausted before %s", stage)
+		response.BudgetExceeded = true
+		return true
+	}
+
+	var evidence []map[string]interface{}
+	var done []Action // everything researched so far, so it isn't repeated
+	var actions []Action
+	var verdict critique
+	var stepCtx context.Context
+	var span trace.Span
+	var err error
+
+	resumed := req.resume != nil && req.resume.Evidence != nil
+	if resumed {
+		// A resumed run goes straight back to research, for what it asked
+		// the user about
+		evidence = resumeStep(req, response, prog)
+		verdict = critique{
+			Assessment: fmt.Sprintf("the user was asked about %s and answered: %s", req.resume.asked(), req.FollowUpAnswer),
+			Gaps:       []string{req.resume.asked()},
+		}
+	} else {
+		// PLANNER
+		if outOfBudget("planning") {
+			return
+		}
+		response.beginIteration(1) // the plan is round 1's
+		planStart := time.Now()
+		stepCtx, span = tracing.Start(ctx, "agent.planner")
+		var plan *ExecutionPlan
+		plan, err = planAsPlanner(stepCtx, req, history)
+		tracing.End(span, err)
+		if err != nil {
+			recordStep(response, prog, AgentStep{
+				Type:        "planner",
+				Description: "Plan the research",
+				Result:      err.Error(),
+				Success:     false,
+				Duration:    float64(time.Since(planStart).Milliseconds()),
+			})
+			if isBudgetError(ctx, err) {
+				response.BudgetExceeded = true
+				return
+			}
+			if partialResults(req) {
+				answerFromPartial(ctx, req, history, nil, err, response, prog)
+				return
+			}
+			response.Answer = fmt.Sprintf("Failed to create plan: %v", err)
+			return
+		}
+		recordStep(response, prog, AgentStep{
+			Type:        "planner",
+			Description: "Plan the research",
+			Action:      describeActions(plan.Actions),
+			Result:      plan.Reasoning,
+			Success:     true,
+			Duration:    float64(time.Since(planStart).Milliseconds()),
+			Model:       plan.Model,
+		})
+		log.Printf("    ✓ Planner: %d actions", len(plan.Actions))
+		auditTrailFrom(ctx).plan(plan)
+		actions = plan.Actions
+	}
+
+	for round := 1; round <= req.MaxIterations; round++ {
+		if ctx.Err() == context.Canceled {
+			log.Printf("  🛑 Request cancelled, stopping")
+			break
+		}
+		log.Printf("  🔄 Research round %d/%d", round, req.MaxIterations)
+		response.beginIteration(round)
+
+		// RESEARCHER: the planner's actions first, then what the critic asked for
+		researchStart := time.Now()
+		description := "Gather the evidence the planner asked for"
+		researchModel := ""
+		if round > 1 || resumed {
+			if outOfBudget("research") {
+				break
+			}
+			description = "Gather the evidence the critic asked for"
+			stepCtx, span = tracing.Start(ctx, "agent.researcher", attribute.Int("round", round))
+			actions, researchModel, err = researchGaps(stepCtx, req, verdict, done)
+			tracing.End(span, err)
+			if err != nil {
+				recordStep(response, prog, AgentStep{
+					Type:        "researcher",
+					Description: description,
+					Result:      err.Error(),
+					Success:     false,
+					Duration:    float64(time.Since(researchStart).Milliseconds()),
+				})
+				if isBudgetError(ctx, err) {
+					response.BudgetExceeded = true
+				} else if partialResults(req) {
+					if response.Answer == "" {
+						answerFromPartial(ctx, req, history, evidence, err, response, prog)
+						return
+					}
+					degrade(response, err.Error())
+				}
+				break
+			}
+		}
+		stepCtx, span = tracing.Start(ctx, "agent.execute", attribute.Int("iteration", round))
+		results := executeActions(stepCtx, actions, response, prog)
+		span.End()
+		evidence = append(evidence, results...)
+		done = append(done, actions...)
+		degradeOnFailedActions(req, response, results)
+
+		failed := 0
+		for _, result := range results {
+			if result["status"] == "failed" {
+				failed++
+			}
+		}
+		summary := fmt.Sprintf("Executed %d actions", len(results))
+		if failed > 0 {
+			summary += fmt.Sprintf(", %d failed", failed)
+		}
+		recordStep(response, prog, AgentStep{
+			Type:        "researcher",
+			Description: description,
+			Action:      describeActions(actions),
+			Result:      summary,
+			Success:     failed == 0,
+			Duration:    float64(time.Since(researchStart).Milliseconds()),
+			Model:       researchModel,
+		})
+		log.Printf("    ✓ Researcher: %s", summary)
+
+		// WRITER
+		if outOfBudget("writing") {
+			break
+		}
+		writeStart := time.Now()
+		stepCtx, span = tracing.Start(ctx, "agent.writer", attribute.Int("round", round))
+		answer, writerModel, citations, agreement := synthesize(stepCtx, req, history, evidence, prog.tokenSink(response.iteration))
+		span.End()
+		// A rewrite cut off by the deadline is worse than the last answer
+		if response.Answer != "" && ctx.Err() != nil && outOfBudget("the end of writing") {
+			break
+		}
+		recordStep(response, prog, AgentStep{
+			Type:        "writer",
+			Description: "Write the answer from the evidence",
+			Result:      fmt.Sprintf("Generated answer (%d chars, %d citations)", len(answer), len(citations)),
+			Success:     writerModel != "",
+			Duration:    float64(time.Since(writeStart).Milliseconds()),
+			Model:       writerModel,
+		})
+		// Without a model's answer the last round's, or the passages found, is the best there is
+		if writerModel == "" && partialResults(req) {
+			degrade(response, "writing failed")
+			if response.Answer == "" {
+				response.Answer, response.Citations = quotedAnswer(evidence)
+				if sink := prog.tokenSink(response.iteration); sink != nil {
+					sink(response.Answer)
+				}
+			}
+			break
+		}
+		response.Answer = answer
+		response.Citations = citations
+		response.Agreement = 0
+		if agreement != nil {
+			recordStep(response, prog, agreement.step())
+			response.Agreement = agreement.Agreement
+			response.Confidence = agreement.Agreement
+		}
+		log.Printf("    ✓ Writer: answer written")
+
+		if !reflectionFlag.Enabled() {
+			log.Printf("  ✅ Reflection disabled, returning unreviewed answer")
+			response.NeedMoreInfo = false
+			break
+		}
+
+		// CRITIC
+		if outOfBudget("critique") {
+			break
+		}
+		critiqueStart := time.Now()
+		stepCtx, span = tracing.Start(ctx, "agent.critic", attribute.Int("round", round))
+		verdict, err = critiqueAnswer(stepCtx, req, answer, evidence)
+		span.SetAttributes(attribute.Float64("confidence", verdict.Confidence))
+		tracing.End(span, err)
+		response.Confidence = agreement.blend(verdict.Confidence)
+		result := fmt.Sprintf("Approved (confidence %.2f): %s", verdict.Confidence, verdict.Assessment)
+		if !verdict.Approved {
+			result = fmt.Sprintf("Sent the researcher back (confidence %.2f): %s", verdict.Confidence, strings.Join(verdict.Gaps, "; "))
+		}
+		if err != nil {
+			result = err.Error()
+		}
+		recordStep(response, prog, AgentStep{
+			Type:        "critic",
+			Description: "Review the answer against the evidence",
+			Result:      result,
+			Success:     err == nil,
+			Duration:    float64(time.Since(critiqueStart).Milliseconds()),
+			Model:       verdict.Model,
+		})
+		if err != nil {
+			// Without a review the answer stands as written
+			log.Printf("Critique failed: %v", err)
+			if isBudgetError(ctx, err) {
+				response.BudgetExceeded = true
+			} else {
+				response.Confidence = agreement.blend(0.5)
+			}
+			break
+		}
+		log.Printf("    ✓ Critic: approved=%v, confidence=%.2f", verdict.Approved, verdict.Confidence)
+
+		if verdict.Approved && response.Confidence >= req.ConfidenceThreshold {
+			log.Printf("  ✅ Critic approved the answer")
+			response.NeedMoreInfo = false
+			break
+		}
+		if round >= req.MaxIterations {
+			log.Printf("  ⚠️  Max research rounds reached")
+			response.NeedMoreInfo = true
+			response.FollowUpQ = "I need more information to answer completely. Can you provide more context about: " + strings.Join(verdict.gapsOrAssessment(), "; ")
+			response.evidence, response.missingInfo = evidence, strings.Join(verdict.gapsOrAssessment(), "; ")
+		}
+	}
+}
+
+// planAsPlanner asks the planner agent for the research plan.
+func planAsPlanner(ctx context.Context, req AgentRequest, history string) (*ExecutionPlan, error) {
+	prompt := fmt.Sprintf(`%s
+
+Query: "%s"
+
+Plan 2-4 actions for the researcher by calling the available functions in
+the order they should run: search the knowledge base and call tools to
+gather the evidence, then call synthesize once to hand it to the writer.
+
+%s`, AGENT_PLANNER_PROMPT, req.Query, mcpTools.Prompt())
+	if len(req.Context) > 0 {
+		prompt += fmt.Sprintf("\n\nAdditional context: %v", req.Context)
+	}
+	prompt = withHistory(prompt, history,
+		"The query may be a follow-up: resolve references to earlier turns and make every search query self-contained.")
+	prompt = withMemory(prompt, userMemoryFrom(ctx))
+
+	resp, model, err := generateContent(ctx, req.PlannerModel, genai.Text(prompt), planningConfig())
+	if err != nil {
+		return nil, err
+	}
+	plan, ok := planFromFunctionCalls(req.Query, resp.FunctionCalls())
+	if !ok {
+		log.Printf("Planner agent returned no actions, using default plan")
+		plan = defaultPlan(req.Query)
+	}
+	plan.Model = model
+	return plan, nil
+}
+
+// researchGaps asks the researcher agent what to search or call to fill the
+// gaps the critic found, and returns those actions with the model that chose
+// them.
+func researchGaps(ctx context.Context, req AgentRequest, verdict critique, done []Action) ([]Action, string, error) {
+	gaps := verdict.gapsOrAssessment()
+	prompt := fmt.Sprintf(`%s
+
+Question: "%s"
+
+The critic reviewed the answer and sent you back: %s
+
+Find out:
+- %s
+
+Already researched: %s
+
+Gather the missing evidence with 1-3 calls to the available functions.
+
+%s`,
+		AGENT_RESEARCHER_PROMPT, req.Query, verdict.Assessment, strings.Join(gaps, "\n- "), describeActions(done), mcpTools.Prompt())
+	prompt = withMemory(prompt, userMemoryFrom(ctx))
+
+	resp, model, err := generateContent(ctx, req.PlannerModel, genai.Text(prompt), researchConfig())
+	if err != nil {
+		return nil, model, err
+	}
+	plan, ok := planFromFunctionCalls(req.Query, resp.FunctionCalls())
+	if !ok {
+		log.Printf("Researcher returned no actions, searching for the gaps")
+		plan = defaultPlan(enhanceQueryForIteration(req.Query, strings.Join(gaps, "; ")))
+	}
+	return plan.Actions, model, nil
+}
+
+// critiqueAnswer asks the critic agent for its verdict on answer.
+func critiqueAnswer(ctx context.Context, req AgentRequest, answer string, evidence []map[string]interface{}) (critique, error) {
+	contextStr, _ := gatheredContext(evidence)
+	prompt := fmt.Sprintf(`%s
+
+Question: "%s"
+
+%s
+Answer under review:
+%s
+
+Call approve if the answer holds up, or request_research with what is
+missing to send the researcher back.`, AGENT_CRITIC_PROMPT, req.Query, contextStr, answer)
+
+	resp, model, err := generateContent(ctx, req.VerifierModel, genai.Text(prompt), critiqueConfig)
+	if err != nil {
+		return critique{Model: model}, err
+	}
+	calls := resp.FunctionCalls()
+	if len(calls) == 0 {
+		return critique{Model: model}, fmt.Errorf("critic returned no verdict")
+	}
+
+	verdict := critique{Approved: calls[0].Name == "approve", Model: model}
+	verdict.Assessment, _ = calls[0].Args["assessment"].(string)
+	verdict.Confidence, _ = calls[0].Args["confidence"].(float64)
+	gaps, _ := calls[0].Args["gaps"].([]interface{})
+	for _, gap := range gaps {
+		if s, ok := gap.(string); ok && s != "" {
+			verdict.Gaps = append(verdict.Gaps, s)
+		}
+	}
+	return verdict, nil
+}
+
+// gapsOrAssessment is what the researcher should look into: the gaps the
+// critic listed, or its assessment when it listed none.
+func (c critique) gapsOrAssessment() []string {
+	if len(c.Gaps) > 0 {
+		return c.Gaps
+	}
+	return []string{c.Assessment}
+}
+
+// describeActions summarizes actions for a step, e.g.
+// `search_rag("KYC thresholds" in kyc_docs); call_tool(risk-score)`.
+func describeActions(actions []Action) string {
+	var described []string
+	for _, action := range actions {
+		switch action.Type {
+		case "search_rag":
+			query, _ := action.Parameters["query"].(string)
+			collection, _ := action.Parameters["collection"].(string)
+			if collection == "" {
+				collection = "regulatory_docs"
+			}
+			described = append(described, fmt.Sprintf("search_rag(%q in %s)", query, collection))
+		case "call_tool":
+			tool, _ := action.Parameters["tool"].(string)
+			described = append(described, fmt.Sprintf("call_tool(%s)", tool))
+		}
+	}
+	if len(described) == 0 {
+		return "nothing"
+	}
+	return strings.Join(described, "; ")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"shared/openapi"
+)
+
+// TestSpecMatchesTypes holds openapi.json to the types the handlers decode
+// and encode, so neither changes without the other.
+func TestSpecMatchesTypes(t *testing.T) {
+	spec := openapi.MustLoad(openAPISpec)
+	for name, v := range map[string]interface{}{
+		"AgentRequest":        AgentRequest{},
+		"AgentStep":           AgentStep{},
+		"StepGroup":           StepGroup{},
+		"Source":              Source{},
+		"Citation":            Citation{},
+		"AgentResponse":       AgentResponse{},
+		"Job":                 Job{},
+		"Action":              Action{},
+		"ExecutionPlan":       ExecutionPlan{},
+		"Message":             Message{},
+		"Conversation":        Conversation{},
+		"ConversationSummary": ConversationSummary{},
+		"MemoryFact":          MemoryFact{},
+		"DependencyHealth":    DependencyHealth{},
+		"DryRun":              DryRun{},
+		"Estimate":            Estimate{},
+		"AuditRecord":         AuditRecord{},
+		"AuditAction":         AuditAction{},
+		"ModelOutput":         ModelOutput{},
+		"Transcript":          Transcript{},
+		"TranscriptTurn":      TranscriptTurn{},
+		"VariantTag":          VariantTag{},
+		"FeedbackRequest":     FeedbackRequest{},
+	} {
+		if err := spec.CheckType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A supplied plan is run once as given, and can't resume a run
+	if err := spec.CheckType("ExecuteRequest", ExecuteRequest{}, "clarify", "follow_up_answer", "max_iterations", "mode", "route"); err != nil {
+		t.Error(err)
+	}
+}
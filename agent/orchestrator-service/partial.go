@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"shared/tracing"
+)
+
+// ============================================================================
+// PARTIAL RESULTS
+// ============================================================================
+// By default a run that can't plan or decide its next action, because Gemini
+// or a downstream service failed, answers with the error. With
+// "partial_results": true (or AGENT_PARTIAL_RESULTS=true) it answers with
+// the best it has instead and sets "degraded": true:
+//
+//   - an answer written in an earlier iteration or round is kept;
+//   - otherwise the answer is synthesized from the actions that succeeded,
+//     searching the knowledge base for the query directly when none has;
+//   - when synthesis fails too, the answer quotes the passages found.
+//
+// Failed actions stay marked failed in the steps, and a run that answered
+// without some of its actions is degraded too.
+
+// AGENT_PARTIAL_RESULTS is the default of a request's partial_results; nil
+// is off.
+var AGENT_PARTIAL_RESULTS = envBool("AGENT_PARTIAL_RESULTS")
+
+// partialResults reports whether req answers with what it has on failure.
+func partialResults(req AgentRequest) bool {
+	return req.PartialResults != nil && *req.PartialResults
+}
+
+// degrade marks response as answered from partial results.
+func degrade(response *AgentResponse, reason string) {
+	if !response.Degraded {
+		log.Printf("  ⚠️  Degraded: %s", reason)
+	}
+	response.Degraded = true
+}
+
+// succeeded returns the results of the actions that didn't fail.
+func succeeded(results []map[string]interface{}) []map[string]interface{} {
+	var ok []map[string]interface{}
+	for _, result := range results {
+		if result["status"] != "failed" {
+			ok = append(ok, result)
+		}
+	}
+	return ok
+}
+
+// degradeOnFailedActions marks response degraded when partial results are
+// on and some of results failed.
+func degradeOnFailedActions(req AgentRequest, response *AgentResponse, results []map[string]interface{}) {
+	if failed := len(results) - len(succeeded(results)); failed > 0 && partialResults(req) {
+		degrade(response, fmt.Sprintf("%d actions failed", failed))
+	}
+}
+
+// answerFromPartial answers req from the results gathered before cause ended
+// the run, searching the knowledge base for the query first when none of
+// them succeeded.
+func answerFromPartial(ctx context.Context, req AgentRequest, history string, results []map[string]interface{}, cause error, response *AgentResponse, prog *progress) {
+	degrade(response, cause.Error())
+	usable := succeeded(results)
+	if len(usable) == 0 && !budgetExceeded(ctx) {
+		usable = succeeded(executeStep(ctx, max(response.Iterations, 1), defaultPlan(req.Query), response, prog))
+	}
+	if len(usable) == 0 {
+		response.Answer = fmt.Sprintf("No information could be gathered to answer the query: %v", cause)
+		return
+	}
+
+	start := time.Now()
+	answer, model, citations := "", "", []Citation{}
+	if !budgetExceeded(ctx) {
+		stepCtx, span := tracing.Start(ctx, "agent.synthesize", attribute.Bool("partial", true))
+		answer, model, citations = synthesizeAnswer(stepCtx, req.SynthesisModel, req.Query, history, usable, prog.tokenSink(max(response.iteration, 1)))
+		span.End()
+	}
+	if model == "" {
+		answer, citations = quotedAnswer(usable)
+		if sink := prog.tokenSink(max(response.iteration, 1)); sink != nil {
+			sink(answer)
+		}
+	}
+	response.Answer = answer
+	response.Citations = citations
+	recordStep(response, prog, AgentStep{
+		Type:        "synthesize",
+		Description: "Synthesize answer from partial results",
+		Result:      fmt.Sprintf("Generated answer (%d chars, %d citations) from %d of %d results", len(answer), len(citations), len(usable), max(len(results), len(usable))),
+		Success:     model != "",
+		Duration:    float64(time.Since(start).Milliseconds()),
+		Model:       model,
+	})
+}
+
+// quotedAnswer stands in for an answer that couldn't be synthesized, quoting
+// the passages results retrieved with their citations.
+func quotedAnswer(results []map[string]interface{}) (string, []Citation) {
+	_, passages := gatheredContext(results)
+	if len(passages.passages) == 0 {
+		return "The answer could not be written from the information gathered.", []Citation{}
+	}
+	answer := "The answer could not be written, but these passages were found:\n\n" + strings.TrimSpace(passages.prompt())
+	return answer, passages.citationsFor(answer)
+}
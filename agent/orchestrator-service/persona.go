@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+	"shared/tenant"
+)
+
+// ============================================================================
+// CONVERSATION PERSONAS
+// ============================================================================
+// A conversation can carry a persona, a system prompt such as "RBI compliance
+// officer tone, always cite circular numbers". It is stored with the
+// conversation, either by a request's "persona" field or with
+// PUT /agent/conversations/{id}/persona, and applies to every later turn
+// until it is replaced or cleared. Every Gemini call a turn makes, from
+// analysis to verification, is sent with the persona as its system
+// instruction, so the planner, writer and critic all follow it.
+
+// maxPersonaChars bounds a persona.
+const maxPersonaChars = 2000
+
+type personaKey struct{}
+
+// withPersona attaches persona to ctx for generateContent.
+func withPersona(ctx context.Context, persona string) context.Context {
+	if persona == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, personaKey{}, persona)
+}
+
+func personaFrom(ctx context.Context) string {
+	persona, _ := ctx.Value(personaKey{}).(string)
+	return persona
+}
+
+// withSystemInstruction returns config with persona as its system
+// instruction. config itself is left alone, as it may be shared.
+func withSystemInstruction(config *genai.GenerateContentConfig, persona string) *genai.GenerateContentConfig {
+	copied := genai.GenerateContentConfig{}
+	if config != nil {
+		copied = *config
+	}
+	copied.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: persona}}}
+	return &copied
+}
+
+// validatePersona checks a persona supplied by a caller.
+func validatePersona(persona string) error {
+	if len(persona) > maxPersonaChars {
+		return fmt.Errorf("persona cannot be longer than %d characters", maxPersonaChars)
+	}
+	return nil
+}
+
+// conversationPersona returns the persona stored with a conversation. A
+// persona that can't be loaded only costs the turn its tone, so it never
+// fails the query.
+func conversationPersona(ctx context.Context, tenantID, conversationID string) string {
+	if conversationID == "" {
+		return ""
+	}
+	conv, err := conversations.Get(ctx, tenantID, conversationID)
+	if err != nil {
+		log.Printf("Failed to load persona for %s: %v", conversationID, err)
+		return ""
+	}
+	if conv == nil {
+		return ""
+	}
+	return conv.Persona
+}
+
+// applyPersona stores req's persona with its conversation, or loads the one
+// stored before, and attaches it to ctx. A dry run uses the persona without
+// storing it.
+func applyPersona(ctx context.Context, req *AgentRequest) context.Context {
+	tenantID := tenant.FromContext(ctx)
+	if req.Persona == "" {
+		req.Persona = conversationPersona(ctx, tenantID, req.ConversationID)
+	} else if !req.DryRun {
+		if err := conversations.SetPersona(ctx, tenantID, req.ConversationID, req.Persona); err != nil {
+			log.Printf("Failed to store persona for %s: %v", req.ConversationID, err)
+		}
+	}
+	return withPersona(ctx, req.Persona)
+}
+
+// Set or clear a conversation's persona
+func personaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversationID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/agent/conversations/"), "/persona")
+	if !ok || conversationID == "" || strings.Contains(conversationID, "/") {
+		respondError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Persona string `json:"persona"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePersona(body.Persona); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := conversations.SetPersona(r.Context(), tenant.FromContext(r.Context()), conversationID, body.Persona); err != nil {
+		log.Printf("Failed to store persona for %s: %v", conversationID, err)
+		respondError(w, "Failed to store persona", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"conversation_id": conversationID,
+		"persona":         body.Persona,
+	}, http.StatusOK)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"shared/flags"
+	"shared/httpclient"
+	"shared/tenant"
+)
+
+// ============================================================================
+// PLAN CACHE
+// ============================================================================
+// Execution plans are cached under the embedding of the query they were made
+// for. When a new query's cosine similarity to a cached one for the same
+// tenant and planner model is at least AGENT_PLAN_CACHE_SIMILARITY (default
+// 0.95), the cached plan is reused and the planner call is skipped; the plan
+// step is marked "cache": "hit". Only the first iteration of a query without
+// conversation history uses the cache, since follow-ups are planned from
+// their history and later iterations re-plan to find more evidence. Entries
+// live in process memory:
+//
+//	AGENT_PLAN_CACHE_SIZE  plans kept, least recently used evicted first (default 500)
+//	AGENT_PLAN_CACHE_TTL   how long a plan is reused (default 1h)
+//
+// Turn the cache off with the agent_plan_cache flag.
+
+var planCacheFlag = flags.Define("agent_plan_cache", true,
+	"Reuse the execution plan of a semantically similar earlier query instead of calling the planner")
+
+var planCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_plan_cache_total",
+	Help: "Plan lookups answered from the semantic plan cache (hit) or the planner (miss).",
+}, []string{"result"})
+
+// Cache outcomes reported in AgentStep.Cache.
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+var (
+	AGENT_PLAN_CACHE_SIMILARITY = envFloat("AGENT_PLAN_CACHE_SIMILARITY", 0.95)
+
+	// embedClient fetches query embeddings for the plan and answer caches
+	embedClient = httpclient.New(httpclient.Options{Timeout: 10 * time.Second})
+)
+
+var plans = newSemanticCache(envInt("AGENT_PLAN_CACHE_SIZE", 500), envDuration("AGENT_PLAN_CACHE_TTL", time.Hour))
+
+// planWithCache returns a plan for the query, from the cache when a similar
+// query was planned before, with the cache outcome for the plan step ("" when
+// the cache was not consulted).
+func planWithCache(ctx context.Context, req AgentRequest, history string) (*ExecutionPlan, string, error) {
+	if !planCacheFlag.Enabled() || history != "" || len(userMemoryFrom(ctx)) > 0 {
+		plan, err := createExecutionPlan(ctx, req.PlannerModel, req.Query, req.Context, history)
+		return plan, "", err
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	embedding, err := embedQuery(ctx, req.Query)
+	if err != nil {
+		// The cache only saves a planner call; plan without it
+		log.Printf("Plan cache skipped: %v", err)
+		plan, err := createExecutionPlan(ctx, req.PlannerModel, req.Query, req.Context, history)
+		return plan, "", err
+	}
+
+	var plan ExecutionPlan
+	if similarTo, similarity := plans.Get(tenantID, req.PlannerModel, embedding, AGENT_PLAN_CACHE_SIMILARITY, &plan); similarTo != "" {
+		log.Printf("    ♻️  Reusing plan for '%s' (similarity %.3f)", similarTo, similarity)
+		planCacheLookups.WithLabelValues(cacheHit).Inc()
+		plan.OriginalQuery = req.Query
+		reused := fmt.Sprintf("Reused plan of similar query %q, similarity %.2f", similarTo, similarity)
+		if plan.Reasoning != "" {
+			reused = plan.Reasoning + " (" + reused + ")"
+		}
+		plan.Reasoning = reused
+		return &plan, cacheHit, nil
+	}
+
+	planCacheLookups.WithLabelValues(cacheMiss).Inc()
+	created, err := createExecutionPlan(ctx, req.PlannerModel, req.Query, req.Context, history)
+	if err != nil {
+		return nil, cacheMiss, err
+	}
+	plans.Put(tenantID, req.PlannerModel, req.Query, embedding, created, nil)
+	return created, cacheMiss, nil
+}
+
+// embedQuery gets the query's embedding from the embed service, or from
+// ctx when the request already fetched it.
+func embedQuery(ctx context.Context, query string) ([]float32, error) {
+	if memo, ok := ctx.Value(embeddingKey{}).(queryEmbedding); ok && memo.query == query {
+		return memo.embedding, nil
+	}
+
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	err := embedClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed", map[string]string{
+		"text": query,
+	}, &result, httpclient.Idempotent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("embed service returned no embedding")
+	}
+	return result.Embedding, nil
+}
+
+type embeddingKey struct{}
+
+type queryEmbedding struct {
+	query     string
+	embedding []float32
+}
+
+// withQueryEmbedding keeps a fetched embedding so later caches consulted for
+// the same query don't fetch it again.
+func withQueryEmbedding(ctx context.Context, query string, embedding []float32) context.Context {
+	return context.WithValue(ctx, embeddingKey{}, queryEmbedding{query: query, embedding: embedding})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// PLANNING TOOLS (Gemini function calling)
+// ============================================================================
+// The planner declares each action type as a function and forces the model
+// to answer with function calls, so plans arrive as structured, schema-checked
+// arguments instead of free text that has to be parsed. The tools call_tool
+// accepts come from the gateway's catalog, see toolcatalog.go.
+
+var planCollections = []string{"regulatory_docs", "merchant_docs", "kyc_docs"}
+
+var (
+	searchRAGDecl = &genai.FunctionDeclaration{
+		Name:        "search_rag",
+		Description: "Search the knowledge base for passages relevant to a query.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"description": {Type: genai.TypeString, Description: "What this search is for"},
+				"query":       {Type: genai.TypeString, Description: "Search query, rewritten for retrieval if that helps"},
+				"collection":  {Type: genai.TypeString, Enum: planCollections, Description: "Collection to search"},
+				"top_k":       {Type: genai.TypeInteger, Description: "Number of passages to return (default 5)"},
+			},
+			Required: []string{"description", "query", "collection"},
+		},
+	}
+	synthesizeDecl = &genai.FunctionDeclaration{
+		Name:        "synthesize",
+		Description: "Combine the gathered information into the final answer. Call it once, last.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"description": {Type: genai.TypeString, Description: "What the answer should cover"},
+				"reasoning":   {Type: genai.TypeString, Description: "Why this plan will answer the query"},
+			},
+			Required: []string{"description", "reasoning"},
+		},
+	}
+)
+
+// callToolDecl declares call_tool for the tools currently in the catalog.
+func callToolDecl() *genai.FunctionDeclaration {
+	return &genai.FunctionDeclaration{
+		Name:        "call_tool",
+		Description: "Call one of the MCP tools listed in the prompt.",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"description":    {Type: genai.TypeString, Description: "What this tool call is for"},
+				"tool":           {Type: genai.TypeString, Enum: mcpTools.Names(), Description: "Tool to call"},
+				"query":          {Type: genai.TypeString, Description: "Free-text input for the tool, e.g. a web search query"},
+				"arguments_json": {Type: genai.TypeString, Description: `Other tool arguments, as listed for the tool, as a JSON object, e.g. {"merchant_data": {...}}`},
+			},
+			Required: []string{"description", "tool"},
+		},
+	}
+}
+
+// planningConfig forces the model to respond with function calls.
+func planningConfig() *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
+		Tools: []*genai.Tool{{
+			FunctionDeclarations: []*genai.FunctionDeclaration{searchRAGDecl, callToolDecl(), synthesizeDecl},
+		}},
+		ToolConfig: &genai.ToolConfig{
+			FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+		},
+	}
+}
+
+// planFromFunctionCalls turns the model's function calls into a plan. Calls
+// to unknown functions are dropped. It returns false when no usable action
+// was produced.
+func planFromFunctionCalls(query string, calls []*genai.FunctionCall) (*ExecutionPlan, bool) {
+	plan := &ExecutionPlan{OriginalQuery: query, RewrittenQueries: []string{}, Actions: []Action{}}
+	seen := map[string]bool{}
+
+	for _, call := range calls {
+		args := call.Args
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		description, _ := args["description"].(string)
+		delete(args, "description")
+
+		switch call.Name {
+		case "search_rag":
+			q, _ := args["query"].(string)
+			if q == "" {
+				q = query
+				args["query"] = q
+			}
+			if !seen[q] {
+				seen[q] = true
+				plan.RewrittenQueries = append(plan.RewrittenQueries, q)
+			}
+
+		case "call_tool":
+			if raw, ok := args["arguments_json"].(string); ok {
+				delete(args, "arguments_json")
+				var extra map[string]interface{}
+				if err := json.Unmarshal([]byte(raw), &extra); err != nil {
+					log.Printf("Ignoring malformed arguments_json for %v: %v", args["tool"], err)
+				}
+				for k, v := range extra {
+					if _, exists := args[k]; !exists {
+						args[k] = v
+					}
+				}
+			}
+
+		case "synthesize":
+			if reasoning, ok := args["reasoning"].(string); ok {
+				plan.Reasoning = reasoning
+			}
+
+		default:
+			log.Printf("Ignoring unknown planner function %q", call.Name)
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, Action{
+			Type:        call.Name,
+			Description: description,
+			Parameters:  args,
+		})
+	}
+
+	if len(plan.RewrittenQueries) == 0 {
+		plan.RewrittenQueries = []string{query}
+	}
+	return plan, len(plan.Actions) > 0
+}
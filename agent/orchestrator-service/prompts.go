@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ============================================================================
+// PROMPT TEMPLATES
+// ============================================================================
+// The analyze, plan, synthesize and verify prompts are Go text/templates.
+// The built-in ones are compiled in from prompts/; a file of the same name
+// (analyze.tmpl, plan.tmpl, synthesize.tmpl, verify.tmpl) in
+// AGENT_PROMPTS_DIR replaces one. The directory is re-read every
+// AGENT_PROMPTS_REFRESH (default 30s, 0 reads it only at startup), so a
+// prompt can be changed without a rebuild or restart. A template that
+// doesn't parse or render keeps the one in use before it.
+//
+// Every template is rendered with promptData; the conversation history and
+// user memory are appended after it as before. An experiment variant can
+// bring its own templates, see experiments.go.
+
+//go:embed prompts/*.tmpl
+var builtinPromptFiles embed.FS
+
+// Prompt templates
+const (
+	promptAnalyze    = "analyze"
+	promptPlan       = "plan"
+	promptSynthesize = "synthesize"
+	promptVerify     = "verify"
+)
+
+var promptNames = []string{promptAnalyze, promptPlan, promptSynthesize, promptVerify}
+
+var AGENT_PROMPTS_DIR = getEnv("AGENT_PROMPTS_DIR", "")
+
+// promptData - The variables a prompt template can use
+type promptData struct {
+	Query   string // the user's query
+	Tools   string // the MCP tools that can be called, see toolcatalog.go
+	Context string // the information gathered, see gatheredContext
+	Answer  string // the answer being verified
+}
+
+// promptTemplate is a parsed template and where it came from.
+type promptTemplate struct {
+	tmpl    *template.Template
+	source  string // "builtin" or the file it was read from
+	modTime time.Time
+}
+
+// promptSet holds the templates in use, by name.
+type promptSet struct {
+	mu        sync.RWMutex
+	templates map[string]promptTemplate
+	rejected  map[string]time.Time // files that didn't parse, by path, not retried until they change
+}
+
+var (
+	builtinPrompts = loadBuiltinPrompts()
+	prompts        = &promptSet{templates: maps.Clone(builtinPrompts), rejected: map[string]time.Time{}}
+)
+
+// loadBuiltinPrompts parses the compiled-in templates. They are part of the
+// binary, so one that doesn't parse is a build mistake.
+func loadBuiltinPrompts() map[string]promptTemplate {
+	templates := map[string]promptTemplate{}
+	for _, name := range promptNames {
+		text, err := builtinPromptFiles.ReadFile("prompts/" + name + ".tmpl")
+		if err != nil {
+			panic(fmt.Sprintf("prompt %s: %v", name, err))
+		}
+		tmpl, err := parsePrompt(name, string(text))
+		if err != nil {
+			panic(err)
+		}
+		templates[name] = promptTemplate{tmpl: tmpl, source: "builtin"}
+	}
+	return templates
+}
+
+// parsePrompt parses a template and checks it renders, so a template that
+// refers to an unknown variable is rejected when it is loaded.
+func parsePrompt(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", name, err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, promptData{}); err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Render fills in the named template.
+func (s *promptSet) Render(name string, data promptData) string {
+	s.mu.RLock()
+	t := s.templates[name]
+	s.mu.RUnlock()
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		// Checked when loaded, so this is a template misbehaving on real data
+		log.Printf("Prompt %s from %s failed to render, using the built-in one: %v", name, t.source, err)
+		b.Reset()
+		builtinPrompts[name].tmpl.Execute(&b, data)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// renderPrompt fills in the named template of the request's experiment
+// variant, or the one in use when the variant has none.
+func renderPrompt(ctx context.Context, name string, data promptData) string {
+	v := variantFrom(ctx)
+	if v == nil || v.templates[name] == nil {
+		return prompts.Render(name, data)
+	}
+	var b strings.Builder
+	if err := v.templates[name].Execute(&b, data); err != nil {
+		log.Printf("Prompt %s of variant %s failed to render: %v", name, v.Name, err)
+		return prompts.Render(name, data)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Sources returns where each template in use came from.
+func (s *promptSet) Sources() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sources := make(map[string]string, len(s.templates))
+	for name, t := range s.templates {
+		sources[name] = t.source
+	}
+	return sources
+}
+
+// reload reads the templates in dir that changed since they were loaded. A
+// file that was removed goes back to the built-in template.
+func (s *promptSet) reload(dir string) {
+	for _, name := range promptNames {
+		path := filepath.Join(dir, name+".tmpl")
+		info, err := os.Stat(path)
+
+		s.mu.RLock()
+		current := s.templates[name]
+		s.mu.RUnlock()
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if current.source != "builtin" {
+				log.Printf("📝 Prompt %s: %s removed, using the built-in one", name, current.source)
+				s.set(name, builtinPrompts[name])
+			}
+			continue
+		case err != nil:
+			log.Printf("Prompt %s not reloaded: %v", name, err)
+			continue
+		case current.source == path && info.ModTime().Equal(current.modTime),
+			s.rejected[path].Equal(info.ModTime()):
+			continue
+		}
+
+		text, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Prompt %s not reloaded: %v", name, err)
+			continue
+		}
+		tmpl, err := parsePrompt(name, string(text))
+		if err != nil {
+			log.Printf("Prompt %s not reloaded, keeping %s: %v", name, current.source, err)
+			s.rejected[path] = info.ModTime()
+			continue
+		}
+		log.Printf("📝 Prompt %s loaded from %s", name, path)
+		s.set(name, promptTemplate{tmpl: tmpl, source: path, modTime: info.ModTime()})
+	}
+}
+
+func (s *promptSet) set(name string, t promptTemplate) {
+	s.mu.Lock()
+	s.templates[name] = t
+	s.mu.Unlock()
+}
+
+// watch loads the templates in dir now and then every interval.
+func (s *promptSet) watch(dir string, interval time.Duration) {
+	if dir == "" {
+		return
+	}
+	s.reload(dir)
+	var custom []string
+	for name, source := range s.Sources() {
+		if source != "builtin" {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	log.Printf("📝 Prompts from %s: %s", dir, strings.Join(custom, ", "))
+
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.reload(dir)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// LLM PROVIDERS
+// ============================================================================
+// Every model call goes through an llmProvider. A model name may start with
+// the provider, "openai/gpt-4o-mini", "ollama/llama3.1:8b" or
+// "anthropic/claude-3-5-haiku-latest"; a bare name is a Gemini model. Any
+// model setting takes a prefixed name, so a provider is picked for the
+// whole service with AGENT_MODEL, per request with "model" and per step
+// with the step overrides:
+//
+//	GEMINI_API_KEY      Gemini; without it only other providers' models are usable
+//	OPENAI_API_KEY      OpenAI, or any OpenAI-compatible endpoint
+//	OPENAI_BASE_URL     (default https://api.openai.com/v1)
+//	OLLAMA_BASE_URL     a local Ollama's OpenAI-compatible API (default http://localhost:11434/v1)
+//	ANTHROPIC_API_KEY   Anthropic
+//	ANTHROPIC_BASE_URL  (default https://api.anthropic.com/v1)
+//	ANTHROPIC_MAX_TOKENS output bound of calls that set none (default 4096)
+//
+// The rest of the orchestrator speaks genai's types, so the other providers
+// translate prompts, function declarations, forced function calling,
+// response schemas and system instructions into their APIs and translate
+// text, function calls, stop reasons and token usage back. Fallback chains,
+// timeouts and budgets apply across providers alike; a 429 or 5xx from any
+// of them falls back.
+
+// llmProvider generates content with one provider's models.
+type llmProvider interface {
+	// configured reports why the provider can't be used, or nil.
+	configured() error
+	generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error)
+	stream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error]
+}
+
+const geminiProviderName = "gemini"
+
+var providers = map[string]llmProvider{
+	geminiProviderName: geminiProvider{},
+	"openai": &openAIProvider{
+		name:    "openai",
+		baseURL: strings.TrimSuffix(getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+		apiKey:  getEnv("OPENAI_API_KEY", ""),
+		keyEnv:  "OPENAI_API_KEY",
+	},
+	"ollama": &openAIProvider{
+		name:    "ollama",
+		baseURL: strings.TrimSuffix(getEnv("OLLAMA_BASE_URL", "http://localhost:11434/v1"), "/"),
+	},
+	"anthropic": &anthropicProvider{
+		baseURL:   strings.TrimSuffix(getEnv("ANTHROPIC_BASE_URL", "https://api.anthropic.com/v1"), "/"),
+		apiKey:    getEnv("ANTHROPIC_API_KEY", ""),
+		maxTokens: int64(max(envInt("ANTHROPIC_MAX_TOKENS", 4096), 1)),
+	},
+}
+
+// splitModel splits a model name into its provider and the provider's name
+// for the model.
+func splitModel(model string) (string, string) {
+	if provider, name, ok := strings.Cut(model, "/"); ok {
+		return provider, name
+	}
+	return geminiProviderName, model
+}
+
+// providerFor returns the provider of model and the provider's name for it.
+func providerFor(model string) (llmProvider, string, error) {
+	providerName, name := splitModel(model)
+	provider, ok := providers[providerName]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown model provider %q", providerName)
+	}
+	if err := provider.configured(); err != nil {
+		return nil, "", fmt.Errorf("model %q: %w", model, err)
+	}
+	return provider, name, nil
+}
+
+// geminiProvider calls Gemini through the genai client.
+type geminiProvider struct{}
+
+func (geminiProvider) configured() error {
+	if geminiClient == nil {
+		return errNotSet("GEMINI_API_KEY")
+	}
+	return nil
+}
+
+func (geminiProvider) generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	return geminiFor(ctx).Models.GenerateContent(ctx, model, contents, config)
+}
+
+func (geminiProvider) stream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return geminiFor(ctx).Models.GenerateContentStream(ctx, model, contents, config)
+}
+
+func errNotSet(variable string) error {
+	return fmt.Errorf("%s is not set", variable)
+}
+
+// providerError is a provider's HTTP error response.
+type providerError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *providerError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// llmHTTPClient calls the providers other than Gemini; each call is bounded
+// by its context.
+var llmHTTPClient = &http.Client{}
+
+// postLLM posts body as JSON to a provider and returns the response when it
+// succeeded. The caller closes its body.
+func postLLM(ctx context.Context, provider, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &providerError{Provider: provider, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
+
+// readSSE hands each data line of a server-sent event stream to fn with the
+// event's name, until fn returns false or the stream ends.
+func readSSE(r io.Reader, fn func(event, data string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if !fn(event, strings.TrimSpace(strings.TrimPrefix(line, "data:"))) {
+				return nil
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// ============================================================================
+// TRANSLATION HELPERS
+// ============================================================================
+
+// systemText is the system instruction of config, or "".
+func systemText(config *genai.GenerateContentConfig) string {
+	if config == nil || config.SystemInstruction == nil {
+		return ""
+	}
+	text, _, _ := splitParts(config.SystemInstruction)
+	return text
+}
+
+// splitParts separates the text, function calls and function responses of
+// content.
+func splitParts(content *genai.Content) (string, []*genai.FunctionCall, []*genai.FunctionResponse) {
+	var text []string
+	var calls []*genai.FunctionCall
+	var responses []*genai.FunctionResponse
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			calls = append(calls, part.FunctionCall)
+		case part.FunctionResponse != nil:
+			responses = append(responses, part.FunctionResponse)
+		case part.Text != "" && !part.Thought:
+			text = append(text, part.Text)
+		}
+	}
+	return strings.Join(text, "\n"), calls, responses
+}
+
+// functionDecls returns the function declarations of config's tools.
+func functionDecls(config *genai.GenerateContentConfig) []*genai.FunctionDeclaration {
+	if config == nil {
+		return nil
+	}
+	var decls []*genai.FunctionDeclaration
+	for _, tool := range config.Tools {
+		decls = append(decls, tool.FunctionDeclarations...)
+	}
+	return decls
+}
+
+// functionCalling returns config's function calling mode and the one
+// function it forces, if any.
+func functionCalling(config *genai.GenerateContentConfig) (genai.FunctionCallingConfigMode, string) {
+	if config == nil || config.ToolConfig == nil || config.ToolConfig.FunctionCallingConfig == nil {
+		return "", ""
+	}
+	calling := config.ToolConfig.FunctionCallingConfig
+	forced := ""
+	if calling.Mode == genai.FunctionCallingConfigModeAny && len(calling.AllowedFunctionNames) == 1 {
+		forced = calling.AllowedFunctionNames[0]
+	}
+	return calling.Mode, forced
+}
+
+// callIDs hands out IDs for function calls that came without one and finds
+// the call a function response answers, matching by name as genai does.
+type callIDs map[string]string
+
+func (ids callIDs) call(call *genai.FunctionCall) string {
+	id := call.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d", len(ids)+1)
+	}
+	ids[call.Name] = id
+	return id
+}
+
+func (ids callIDs) response(response *genai.FunctionResponse) string {
+	if response.ID != "" {
+		return response.ID
+	}
+	return ids[response.Name]
+}
+
+// jsonSchema converts a genai schema to JSON Schema.
+func jsonSchema(s *genai.Schema) map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	out := map[string]interface{}{}
+	if s.Type != "" && s.Type != genai.TypeUnspecified {
+		out["type"] = strings.ToLower(string(s.Type))
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Minimum != nil {
+		out["minimum"] = *s.Minimum
+	}
+	if s.Maximum != nil {
+		out["maximum"] = *s.Maximum
+	}
+	if s.MinItems != nil {
+		out["minItems"] = *s.MinItems
+	}
+	if s.MaxItems != nil {
+		out["maxItems"] = *s.MaxItems
+	}
+	if s.Items != nil {
+		out["items"] = jsonSchema(s.Items)
+	}
+	if len(s.AnyOf) > 0 {
+		anyOf := make([]interface{}, len(s.AnyOf))
+		for i, option := range s.AnyOf {
+			anyOf[i] = jsonSchema(option)
+		}
+		out["anyOf"] = anyOf
+	}
+	if s.Type == genai.TypeObject || len(s.Properties) > 0 {
+		properties := map[string]interface{}{}
+		for name, property := range s.Properties {
+			properties[name] = jsonSchema(property)
+		}
+		out["properties"] = properties
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
+
+// usageMetadata is token usage in genai's terms.
+func usageMetadata(prompt, completion int64) *genai.GenerateContentResponseUsageMetadata {
+	return &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     &prompt,
+		CandidatesTokenCount: &completion,
+		TotalTokenCount:      prompt + completion,
+	}
+}
+
+// modelResponse wraps parts as a single-candidate genai response.
+func modelResponse(parts []*genai.Part, usage *genai.GenerateContentResponseUsageMetadata) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{
+		Candidates:    []*genai.Candidate{{Content: &genai.Content{Role: "model", Parts: parts}}},
+		UsageMetadata: usage,
+	}
+}
+
+// finishReasons maps the other providers' stop reasons to genai's; the
+// ones missing need no attention.
+var finishReasons = map[string]genai.FinishReason{
+	"length":         genai.FinishReasonMaxTokens, // OpenAI
+	"content_filter": genai.FinishReasonSafety,
+	"max_tokens":     genai.FinishReasonMaxTokens, // Anthropic
+	"refusal":        genai.FinishReasonSafety,
+}
+
+// finished sets the finish reason of resp's candidate from a provider's.
+func finished(resp *genai.GenerateContentResponse, reason string) *genai.GenerateContentResponse {
+	resp.Candidates[0].FinishReason = finishReasons[reason]
+	return resp
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// anthropicVersion is the Messages API version requested.
+const anthropicVersion = "2023-06-01"
+
+// anthropicProvider calls Anthropic's Messages API. It has no response
+// schema parameter, so a schema is given to the model as an instruction.
+type anthropicProvider struct {
+	baseURL   string
+	apiKey    string
+	maxTokens int64 // the API requires a bound; ANTHROPIC_MAX_TOKENS
+}
+
+type anthropicBlock struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text,omitempty"`
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Input     map[string]interface{} `json:"input,omitempty"`
+	ToolUseID string                 `json:"tool_use_id,omitempty"`
+	Content   string                 `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model         string                   `json:"model"`
+	MaxTokens     int64                    `json:"max_tokens"`
+	System        string                   `json:"system,omitempty"`
+	Messages      []anthropicMessage       `json:"messages"`
+	Tools         []map[string]interface{} `json:"tools,omitempty"`
+	ToolChoice    map[string]string        `json:"tool_choice,omitempty"`
+	Temperature   *float64                 `json:"temperature,omitempty"`
+	StopSequences []string                 `json:"stop_sequences,omitempty"`
+	Stream        bool                     `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicEvent is one event of a streamed response.
+type anthropicEvent struct {
+	Message anthropicResponse `json:"message"` // message_start
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"` // message_delta
+	} `json:"delta"` // content_block_delta
+	Usage anthropicUsage `json:"usage"` // message_delta
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"` // error
+}
+
+func (p *anthropicProvider) configured() error {
+	if p.apiKey == "" {
+		return errNotSet("ANTHROPIC_API_KEY")
+	}
+	return nil
+}
+
+func (p *anthropicProvider) headers() map[string]string {
+	return map[string]string{"x-api-key": p.apiKey, "anthropic-version": anthropicVersion}
+}
+
+func (p *anthropicProvider) generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	resp, err := postLLM(ctx, "anthropic", p.baseURL+"/messages", p.headers(), p.request(model, contents, config))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	var parts []*genai.Part
+	for _, block := range out.Content {
+		switch block.Type {
+		case "text":
+			parts = append(parts, &genai.Part{Text: block.Text})
+		case "tool_use":
+			parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{ID: block.ID, Name: block.Name, Args: block.Input}})
+		}
+	}
+	return finished(modelResponse(parts, usageMetadata(out.Usage.InputTokens, out.Usage.OutputTokens)), out.StopReason), nil
+}
+
+func (p *anthropicProvider) stream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		body := p.request(model, contents, config)
+		body.Stream = true
+		resp, err := postLLM(ctx, "anthropic", p.baseURL+"/messages", p.headers(), body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var input int64
+		var streamErr error
+		err = readSSE(resp.Body, func(event, data string) bool {
+			var e anthropicEvent
+			if json.Unmarshal([]byte(data), &e) != nil {
+				return true
+			}
+			switch event {
+			case "message_start":
+				input = e.Message.Usage.InputTokens
+			case "content_block_delta":
+				if e.Delta.Type == "text_delta" && e.Delta.Text != "" {
+					return yield(modelResponse([]*genai.Part{{Text: e.Delta.Text}}, nil), nil)
+				}
+			case "message_delta":
+				// The output tokens so far, which are all of them at the end
+				return yield(finished(modelResponse(nil, usageMetadata(input, e.Usage.OutputTokens)), e.Delta.StopReason), nil)
+			case "message_stop":
+				return false
+			case "error":
+				streamErr = fmt.Errorf("anthropic stream failed: %s: %s", e.Error.Type, e.Error.Message)
+				return false
+			}
+			return true
+		})
+		if streamErr != nil {
+			err = streamErr
+		}
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// request translates a genai call into a Messages API request.
+func (p *anthropicProvider) request(model string, contents []*genai.Content, config *genai.GenerateContentConfig) anthropicRequest {
+	req := anthropicRequest{Model: model, MaxTokens: p.maxTokens, System: systemText(config)}
+	ids := callIDs{}
+	for _, content := range contents {
+		text, calls, responses := splitParts(content)
+		message := anthropicMessage{Role: "user"}
+		if content.Role == "model" {
+			message.Role = "assistant"
+		}
+		if text != "" {
+			message.Content = append(message.Content, anthropicBlock{Type: "text", Text: text})
+		}
+		for _, call := range calls {
+			message.Content = append(message.Content, anthropicBlock{Type: "tool_use", ID: ids.call(call), Name: call.Name, Input: call.Args})
+		}
+		for _, response := range responses {
+			result, _ := json.Marshal(response.Response)
+			message.Content = append(message.Content, anthropicBlock{Type: "tool_result", ToolUseID: ids.response(response), Content: string(result)})
+		}
+		req.Messages = append(req.Messages, message)
+	}
+	if config == nil {
+		return req
+	}
+
+	for _, decl := range functionDecls(config) {
+		req.Tools = append(req.Tools, map[string]interface{}{
+			"name":         decl.Name,
+			"description":  decl.Description,
+			"input_schema": jsonSchema(decl.Parameters),
+		})
+	}
+	switch mode, forced := functionCalling(config); {
+	case forced != "":
+		req.ToolChoice = map[string]string{"type": "tool", "name": forced}
+	case mode == genai.FunctionCallingConfigModeAny:
+		req.ToolChoice = map[string]string{"type": "any"}
+	case mode == genai.FunctionCallingConfigModeNone:
+		req.ToolChoice = map[string]string{"type": "none"}
+	}
+
+	switch {
+	case config.ResponseSchema != nil:
+		schema, _ := json.Marshal(jsonSchema(config.ResponseSchema))
+		req.System += "\n\nRespond with only a JSON value matching this JSON Schema, without code fences:\n" + string(schema)
+	case config.ResponseMIMEType == "application/json":
+		req.System += "\n\nRespond with only a JSON value, without code fences."
+	}
+	req.System = strings.TrimSpace(req.System)
+	if config.MaxOutputTokens != nil {
+		req.MaxTokens = *config.MaxOutputTokens
+	}
+	req.Temperature = config.Temperature
+	req.StopSequences = config.StopSequences
+	return req
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+
+	"google.golang.org/genai"
+)
+
+// openAIProvider calls an OpenAI-compatible chat completions API: OpenAI
+// itself, or a local Ollama, which needs no key.
+type openAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	keyEnv  string // the variable holding apiKey; empty when none is needed
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model          string                   `json:"model"`
+	Messages       []openAIMessage          `json:"messages"`
+	Tools          []map[string]interface{} `json:"tools,omitempty"`
+	ToolChoice     interface{}              `json:"tool_choice,omitempty"`
+	Temperature    *float64                 `json:"temperature,omitempty"`
+	MaxTokens      *int64                   `json:"max_tokens,omitempty"`
+	Stop           []string                 `json:"stop,omitempty"`
+	ResponseFormat map[string]interface{}   `json:"response_format,omitempty"`
+	Stream         bool                     `json:"stream,omitempty"`
+	StreamOptions  map[string]bool          `json:"stream_options,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (p *openAIProvider) configured() error {
+	if p.keyEnv != "" && p.apiKey == "" {
+		return errNotSet(p.keyEnv)
+	}
+	return nil
+}
+
+func (p *openAIProvider) headers() map[string]string {
+	if p.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
+
+func (p *openAIProvider) generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	resp, err := postLLM(ctx, p.name, p.baseURL+"/chat/completions", p.headers(), openAIRequestFor(model, contents, config))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	var parts []*genai.Part
+	reason := ""
+	if len(out.Choices) > 0 {
+		reason = out.Choices[0].FinishReason
+		message := out.Choices[0].Message
+		if message.Content != "" {
+			parts = append(parts, &genai.Part{Text: message.Content})
+		}
+		for _, call := range message.ToolCalls {
+			args := map[string]interface{}{}
+			json.Unmarshal([]byte(call.Function.Arguments), &args)
+			parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{ID: call.ID, Name: call.Function.Name, Args: args}})
+		}
+	}
+	var usage *genai.GenerateContentResponseUsageMetadata
+	if out.Usage != nil {
+		usage = usageMetadata(out.Usage.PromptTokens, out.Usage.CompletionTokens)
+	}
+	return finished(modelResponse(parts, usage), reason), nil
+}
+
+func (p *openAIProvider) stream(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		body := openAIRequestFor(model, contents, config)
+		body.Stream = true
+		body.StreamOptions = map[string]bool{"include_usage": true}
+		resp, err := postLLM(ctx, p.name, p.baseURL+"/chat/completions", p.headers(), body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		err = readSSE(resp.Body, func(_, data string) bool {
+			if data == "[DONE]" {
+				return false
+			}
+			var chunk openAIResponse
+			if json.Unmarshal([]byte(data), &chunk) != nil {
+				return true
+			}
+			var parts []*genai.Part
+			reason := ""
+			if len(chunk.Choices) > 0 {
+				if chunk.Choices[0].Delta.Content != "" {
+					parts = []*genai.Part{{Text: chunk.Choices[0].Delta.Content}}
+				}
+				reason = chunk.Choices[0].FinishReason
+			}
+			var usage *genai.GenerateContentResponseUsageMetadata
+			if chunk.Usage != nil {
+				usage = usageMetadata(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+			}
+			return yield(finished(modelResponse(parts, usage), reason), nil)
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// openAIRequestFor translates a genai call into a chat completions request.
+func openAIRequestFor(model string, contents []*genai.Content, config *genai.GenerateContentConfig) openAIRequest {
+	req := openAIRequest{Model: model}
+	if system := systemText(config); system != "" {
+		req.Messages = append(req.Messages, openAIMessage{Role: "system", Content: system})
+	}
+	ids := callIDs{}
+	for _, content := range contents {
+		text, calls, responses := splitParts(content)
+		switch {
+		case len(responses) > 0:
+			for _, response := range responses {
+				result, _ := json.Marshal(response.Response)
+				req.Messages = append(req.Messages, openAIMessage{Role: "tool", ToolCallID: ids.response(response), Content: string(result)})
+			}
+		case content.Role == "model":
+			message := openAIMessage{Role: "assistant", Content: text}
+			for _, call := range calls {
+				args, _ := json.Marshal(call.Args)
+				toolCall := openAIToolCall{ID: ids.call(call), Type: "function"}
+				toolCall.Function.Name = call.Name
+				toolCall.Function.Arguments = string(args)
+				message.ToolCalls = append(message.ToolCalls, toolCall)
+			}
+			req.Messages = append(req.Messages, message)
+		default:
+			req.Messages = append(req.Messages, openAIMessage{Role: "user", Content: text})
+		}
+	}
+	if config == nil {
+		return req
+	}
+
+	for _, decl := range functionDecls(config) {
+		req.Tools = append(req.Tools, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        decl.Name,
+				"description": decl.Description,
+				"parameters":  jsonSchema(decl.Parameters),
+			},
+		})
+	}
+	switch mode, forced := functionCalling(config); {
+	case forced != "":
+		req.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]string{"name": forced}}
+	case mode == genai.FunctionCallingConfigModeAny:
+		req.ToolChoice = "required"
+	case mode == genai.FunctionCallingConfigModeNone:
+		req.ToolChoice = "none"
+	}
+
+	switch {
+	case config.ResponseSchema != nil:
+		req.ResponseFormat = map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "response", "schema": jsonSchema(config.ResponseSchema)},
+		}
+	case config.ResponseMIMEType == "application/json":
+		req.ResponseFormat = map[string]interface{}{"type": "json_object"}
+	}
+	req.Temperature = config.Temperature
+	req.MaxTokens = config.MaxOutputTokens
+	req.Stop = config.StopSequences
+	return req
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"shared/tenant"
+)
+
+// ============================================================================
+// RESUMING AFTER A FOLLOW-UP QUESTION
+// ============================================================================
+// A run that ends with need_more_info is suspended rather than forgotten: its
+// query, follow-up question and the evidence it gathered are kept. A request
+// on the same conversation_id with follow_up_answer set resumes it. The query
+// becomes the original one with the user's clarification, and the loop runs
+// again in the mode the run was suspended in, answering from the evidence it
+// had gathered as well as what the clarified query finds. A multi_agent run
+// skips its planner and sends the researcher after what it had asked about.
+// A run that asked before gathering anything, see clarify.go, just runs
+// again with the clarified query.
+// Suspended runs live in process memory for AGENT_RESUME_TTL (default 1h);
+// the conversation's next run, resumed or not, replaces its suspended run,
+// and a follow_up_answer with none pending is answered with 404.
+
+// suspendedRun is what a run that asked a follow-up question had gathered.
+type suspendedRun struct {
+	Query       string
+	Mode        string
+	FollowUpQ   string
+	MissingInfo string
+	Evidence    []map[string]interface{}
+	suspendedAt time.Time
+}
+
+// asked is what the run asked the user about.
+func (r *suspendedRun) asked() string {
+	if r.MissingInfo != "" {
+		return r.MissingInfo
+	}
+	return r.FollowUpQ
+}
+
+// suspendedRuns holds suspended runs by conversationKey.
+type suspendedRuns struct {
+	mu   sync.Mutex
+	runs map[string]*suspendedRun
+	ttl  time.Duration
+}
+
+var suspended = &suspendedRuns{
+	runs: make(map[string]*suspendedRun),
+	ttl:  envDuration("AGENT_RESUME_TTL", time.Hour),
+}
+
+// Put suspends run for the conversation, replacing any earlier one and
+// dropping runs that have expired.
+func (s *suspendedRuns) Put(key string, run *suspendedRun) {
+	now := time.Now()
+	run.suspendedAt = now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, r := range s.runs {
+		if now.Sub(r.suspendedAt) > s.ttl {
+			delete(s.runs, k)
+		}
+	}
+	s.runs[key] = run
+}
+
+// Get returns the conversation's suspended run, or nil. It stays suspended
+// until the resumed run ends and replaces it.
+func (s *suspendedRuns) Get(key string) *suspendedRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[key]
+	if !ok || time.Since(run.suspendedAt) > s.ttl {
+		return nil
+	}
+	return run
+}
+
+// Drop forgets the conversation's suspended run.
+func (s *suspendedRuns) Drop(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, key)
+}
+
+// resumeRequest turns req into the resumption of its conversation's
+// suspended run, responding with an error and returning false when there is
+// none.
+func resumeRequest(w http.ResponseWriter, ctx context.Context, req *AgentRequest) bool {
+	if req.ConversationID == "" {
+		respondError(w, "follow_up_answer needs the conversation_id of the run that asked", http.StatusBadRequest)
+		return false
+	}
+	if req.DryRun {
+		respondError(w, "follow_up_answer cannot be combined with dry_run", http.StatusBadRequest)
+		return false
+	}
+	run := suspended.Get(conversationKey(tenant.FromContext(ctx), req.ConversationID))
+	if run == nil {
+		respondError(w, "No follow-up question is pending for this conversation", http.StatusNotFound)
+		return false
+	}
+
+	req.Query = fmt.Sprintf("%s (asked about %s, the user answered: %s)", run.Query, run.asked(), req.FollowUpAnswer)
+	if req.Mode == "" {
+		req.Mode = run.Mode
+	}
+	req.resume = run
+	return true
+}
+
+// resumeStep carries the suspended run's evidence into response and records
+// the resume step, returning the evidence.
+func resumeStep(req AgentRequest, response *AgentResponse, prog *progress) []map[string]interface{} {
+	run := req.resume
+	for _, result := range run.Evidence {
+		if result["action_type"] == "search_rag" && result["status"] != "failed" {
+			response.Sources = addSources(response.Sources, result)
+		}
+	}
+	result := fmt.Sprintf("Carried over %d results gathered before asking about %s", len(run.Evidence), run.asked())
+	if run.Evidence == nil {
+		result = "Asked about " + run.asked() + " before gathering anything"
+	}
+	recordStep(response, prog, AgentStep{
+		Type:        "resume",
+		Description: "Resume with the user's clarification",
+		Result:      result,
+		Success:     true,
+	})
+	return run.Evidence
+}
+
+// suspendRun keeps what req's run gathered when it ended asking a follow-up
+// question, and otherwise forgets the conversation's suspended run.
+func suspendRun(ctx context.Context, req AgentRequest, response AgentResponse) {
+	key := conversationKey(tenant.FromContext(ctx), req.ConversationID)
+	if !response.NeedMoreInfo || response.FollowUpQ == "" {
+		suspended.Drop(key)
+		return
+	}
+
+	// A resumed run's query already carries its clarification, so a second
+	// follow-up adds to it. A run that asked before gathering anything, see
+	// clarify.go, resumes in any mode.
+	mode := modePipeline
+	if req.plan == nil && (req.Mode == modeMultiAgent || response.evidence == nil) {
+		mode = req.Mode
+	}
+	suspended.Put(key, &suspendedRun{
+		Query:       req.Query,
+		Mode:        mode,
+		FollowUpQ:   response.FollowUpQ,
+		MissingInfo: response.missingInfo,
+		Evidence:    response.evidence,
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"shared/tenant"
+	"shared/tracing"
+)
+
+// ============================================================================
+// QUERY ROUTER
+// ============================================================================
+// Most questions are lookups one search answers, and the full loop spends an
+// analysis, a plan and often more iterations on them. The router sends each
+// query down one of two paths before any model is called:
+//
+//	fast  one knowledge base search and synthesis, verified once when
+//	      reflection is on
+//	full  the loop of the request's mode: pipeline, react or multi_agent
+//
+// It judges the query's shape, without a model call. A follow-up in a
+// conversation, a request with context, several questions, a query longer
+// than AGENT_ROUTER_MAX_WORDS (default 20) words, or one that compares,
+// chains facts or needs a tool or fresh data takes the full path; anything
+// else is a simple factual question. A fast answer that fails verification,
+// or that synthesis couldn't write, escalates to the full path.
+//
+// AGENT_ROUTE, or "route" per request, is auto (default), fast or full. The
+// response's "route" reports the path that produced the answer and the route
+// step why it was taken.
+
+const (
+	routeAuto = "auto"
+	routeFast = "fast"
+	routeFull = "full"
+)
+
+var (
+	AGENT_ROUTE            = getEnv("AGENT_ROUTE", routeAuto)
+	AGENT_ROUTER_MAX_WORDS = envInt("AGENT_ROUTER_MAX_WORDS", 20)
+)
+
+var routes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_routes_total",
+	Help: "Agent runs by the path the router chose; escalated counts fast answers handed on to the full loop.",
+}, []string{"route"})
+
+// complexQueries are the phrasings that take the full path, with why.
+var complexQueries = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`\b(compare|comparison|versus|vs|difference|differences|differ|contrast)\b`), "compares"},
+	{regexp.MustCompile(`\b(why|and then|after that|based on|given that|depends on|impact|affect|affects)\b`), "chains facts"},
+	{regexp.MustCompile(`\b(risk score|score|verify|latest|current|today|recent|news)\b`), "needs a tool or fresh data"},
+}
+
+func validateRoute(route string) error {
+	if route != routeAuto && route != routeFast && route != routeFull {
+		return fmt.Errorf("route must be %q, %q or %q", routeAuto, routeFast, routeFull)
+	}
+	return nil
+}
+
+// routeQuery picks the path of req, records the route step and returns the
+// path.
+func routeQuery(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) string {
+	start := time.Now()
+	route, reason := req.Route, "requested"
+	if route == routeAuto {
+		history := recentHistory(ctx, tenant.FromContext(ctx), req.ConversationID)
+		route, reason = classifyQuery(req, history)
+	}
+	routes.WithLabelValues(route).Inc()
+	recordStep(response, prog, AgentStep{
+		Type:        "route",
+		Description: "Route query",
+		Result:      fmt.Sprintf("%s: %s", route, reason),
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	})
+	log.Printf("  🔀 Route: %s (%s)", route, reason)
+	return route
+}
+
+// classifyQuery routes a query by its shape.
+func classifyQuery(req AgentRequest, history string) (string, string) {
+	query := strings.ToLower(req.Query)
+	switch words := len(strings.Fields(query)); {
+	case history != "":
+		return routeFull, "follow-up in a conversation"
+	case len(req.Context) > 0:
+		return routeFull, "request has context"
+	case strings.Count(query, "?") > 1:
+		return routeFull, "several questions"
+	case words > AGENT_ROUTER_MAX_WORDS:
+		return routeFull, fmt.Sprintf("long query (%d words)", words)
+	}
+	for _, c := range complexQueries {
+		if match := c.pattern.FindString(query); match != "" {
+			return routeFull, fmt.Sprintf("%s (%q)", c.reason, match)
+		}
+	}
+	return routeFast, "simple factual question"
+}
+
+// fastCollection picks the collection the fast path searches.
+func fastCollection(query string) string {
+	switch query = strings.ToLower(query); {
+	case strings.Contains(query, "kyc"):
+		return "kyc_docs"
+	case strings.Contains(query, "merchant"):
+		return "merchant_docs"
+	}
+	return "regulatory_docs"
+}
+
+// executeFastPath answers with one search and one synthesis, and hands the
+// query on to the full loop when that answer falls short.
+func executeFastPath(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) {
+	response.beginIteration(1)
+	plan := defaultPlan(req.Query)
+	plan.Actions[0].Parameters["collection"] = fastCollection(req.Query)
+	plan.Reasoning = "Fast path: one search, then synthesis"
+	results := executeStep(ctx, 1, plan, response, prog)
+
+	start := time.Now()
+	stepCtx, span := tracing.Start(ctx, "agent.synthesize", attribute.Int("iteration", 1))
+	answer, model, citations, verdict := synthesize(stepCtx, req, "", results, prog.tokenSink(response.iteration))
+	span.End()
+	recordStep(response, prog, AgentStep{
+		Type:        "synthesize",
+		Description: "Synthesize final answer",
+		Result:      fmt.Sprintf("Generated answer (%d chars, %d citations)", len(answer), len(citations)),
+		Success:     model != "",
+		Duration:    float64(time.Since(start).Milliseconds()),
+		Model:       model,
+	})
+	if verdict != nil {
+		recordStep(response, prog, verdict.step())
+	}
+	response.Answer, response.Citations = answer, citations
+	Verification{}.report(response)
+	if verdict != nil {
+		response.Confidence, response.Agreement = verdict.Agreement, verdict.Agreement
+	}
+	if budgetExceeded(ctx) {
+		response.BudgetExceeded = true
+		return
+	}
+	if model == "" {
+		escalate(ctx, req, response, prog, "synthesis failed")
+		return
+	}
+	if !reflectionFlag.Enabled() {
+		return
+	}
+
+	start = time.Now()
+	stepCtx, span = tracing.Start(ctx, "agent.verify", attribute.Int("iteration", 1))
+	verification := verifyAnswer(stepCtx, req.VerifierModel, req.Query, answer, results)
+	span.SetAttributes(attribute.Float64("confidence", verification.Confidence))
+	span.End()
+	recordStep(response, prog, verification.step(start))
+	verification.report(response)
+	response.Confidence = verdict.blend(verification.Confidence)
+	if verification.IsComplete && response.Confidence >= req.ConfidenceThreshold {
+		return
+	}
+	if budgetExceeded(ctx) {
+		response.BudgetExceeded = true
+		return
+	}
+	escalate(ctx, req, response, prog, fmt.Sprintf("answer not verified (confidence %.2f)", response.Confidence))
+}
+
+// escalate hands a query the fast path couldn't answer to the full loop,
+// which starts over at the next iteration; the fast path's steps stay in the
+// trace, and a streaming client discards the answer tokens it was sent.
+func escalate(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress, reason string) {
+	log.Printf("  🔀 Escalating to the full loop: %s", reason)
+	routes.WithLabelValues("escalated").Inc()
+	response.iteration, response.iterationBase = 0, response.Iterations
+	recordStep(response, prog, AgentStep{
+		Type:        "route",
+		Description: "Escalate to the full loop",
+		Result:      reason,
+		Success:     true,
+	})
+	response.Route = routeFull
+	response.Answer, response.Citations, response.Confidence, response.Agreement = "", []Citation{}, 0, 0
+	Verification{}.report(response)
+	executeLoop(ctx, req, response, prog)
+}
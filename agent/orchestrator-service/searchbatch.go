@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel/attribute"
+	"shared/httpclient"
+	"shared/tracing"
+)
+
+// ============================================================================
+// SEARCH BATCHING
+// ============================================================================
+// A plan with several search_rag actions fetches them in one call to the
+// retrieval service's POST /retrieve/batch before its actions run, rather
+// than a round trip per search. Searches the conversation already cached
+// aren't sent. A search the batch couldn't answer, or every search when
+// the batch call fails, is made on its own as before.
+
+// prefetchSearches returns the batch-fetched result of each search_rag
+// action by its index in actions, or nil when there is nothing to batch.
+func prefetchSearches(ctx context.Context, conversationID string, actions []Action) map[int]map[string]interface{} {
+	var (
+		indexes []int
+		queries []map[string]interface{}
+	)
+	for i, action := range actions {
+		if action.Type != "search_rag" {
+			continue
+		}
+		search := newRAGSearch(ctx, conversationID, action.Parameters)
+		if searchResults.Get(search.conversation, search.key) != nil {
+			continue
+		}
+		indexes = append(indexes, i)
+		queries = append(queries, search.body)
+	}
+	if len(queries) < 2 {
+		return nil
+	}
+
+	stepCtx, span := tracing.Start(ctx, "agent.search_batch", attribute.Int("rag.searches", len(queries)))
+	var batch struct {
+		Responses []map[string]interface{} `json:"responses"`
+	}
+	err := ragClient.PostJSON(stepCtx, RAG_SERVICE_URL+"/retrieve/batch", map[string]interface{}{
+		"queries": queries,
+	}, &batch, httpclient.Idempotent)
+	tracing.End(span, err)
+	if err != nil {
+		log.Printf("      ⚠️  Batch search failed, searching one by one: %v", err)
+		return nil
+	}
+
+	prefetched := make(map[int]map[string]interface{}, len(indexes))
+	for n, response := range batch.Responses {
+		if n >= len(indexes) {
+			break
+		}
+		if _, failed := response["error"]; failed {
+			continue
+		}
+		prefetched[indexes[n]] = response
+	}
+	log.Printf("      📦 Fetched %d of %d searches in one batch", len(prefetched), len(queries))
+	return prefetched
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ============================================================================
+// SEARCH CACHE
+// ============================================================================
+// Iterations of the loop often re-issue the search an earlier one already
+// ran. Retrieval results are remembered per conversation, keyed by the
+// normalized query, collection and top_k, so a repeated search reuses the
+// evidence instead of calling the retrieval service again. Reused results
+// carry "cached": true. Entries live in process memory:
+//
+//	AGENT_SEARCH_CACHE_TTL   idle time before a conversation's searches are forgotten (default 30m, 0 disables)
+//	AGENT_SEARCH_CACHE_SIZE  searches kept per conversation (default 50)
+
+var searchCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_search_cache_total",
+	Help: "search_rag actions answered from the per-conversation cache (hit) or the retrieval service (miss).",
+}, []string{"result"})
+
+// searchCache holds retrieval results by conversation. A nil *searchCache
+// caches nothing.
+type searchCache struct {
+	mu            sync.Mutex
+	conversations map[string]*cachedSearches // keyed by conversationKey(tenant, conversation ID)
+	ttl           time.Duration
+	size          int
+}
+
+// cachedSearches are one conversation's results, kept encoded so every hit
+// gets its own copy to annotate.
+type cachedSearches struct {
+	results  map[string][]byte
+	order    []string // oldest first, for eviction
+	lastUsed time.Time
+}
+
+var searchResults *searchCache
+
+func newSearchCache() *searchCache {
+	ttl := envDuration("AGENT_SEARCH_CACHE_TTL", 30*time.Minute)
+	if ttl <= 0 {
+		return nil
+	}
+	c := &searchCache{
+		conversations: make(map[string]*cachedSearches),
+		ttl:           ttl,
+		size:          max(envInt("AGENT_SEARCH_CACHE_SIZE", 50), 1),
+	}
+	go c.sweep()
+	return c
+}
+
+// searchKey identifies a search regardless of case, spacing and trailing
+// punctuation in its query.
+func searchKey(query, collection string, topK int) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	normalized = strings.TrimRight(normalized, "?.!")
+	return fmt.Sprintf("%s\x00%s\x00%d", collection, normalized, topK)
+}
+
+// Get returns a copy of a cached result, or nil.
+func (c *searchCache) Get(conversation, key string) map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	entry, ok := c.conversations[conversation]
+	var encoded []byte
+	if ok {
+		encoded = entry.results[key]
+		entry.lastUsed = time.Now()
+	}
+	c.mu.Unlock()
+	if encoded == nil {
+		return nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// Put remembers result, evicting the conversation's oldest search when it
+// already holds AGENT_SEARCH_CACHE_SIZE.
+func (c *searchCache) Put(conversation, key string, result map[string]interface{}) {
+	if c == nil {
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.conversations[conversation]
+	if !ok {
+		entry = &cachedSearches{results: make(map[string][]byte)}
+		c.conversations[conversation] = entry
+	}
+	entry.lastUsed = time.Now()
+	if _, ok := entry.results[key]; !ok {
+		entry.order = append(entry.order, key)
+	}
+	entry.results[key] = encoded
+	for len(entry.order) > c.size {
+		delete(entry.results, entry.order[0])
+		entry.order = entry.order[1:]
+	}
+}
+
+// sweep forgets conversations idle for longer than the TTL.
+func (c *searchCache) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		c.mu.Lock()
+		for key, entry := range c.conversations {
+			if now.Sub(entry.lastUsed) > c.ttl {
+				delete(c.conversations, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
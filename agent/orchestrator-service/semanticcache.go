@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// SEMANTIC CACHE
+// ============================================================================
+// The plan and answer caches look entries up by query embedding rather than
+// by exact text, so near-duplicate questions share them. Both keep their
+// entries here.
+
+// semanticCache holds values by query embedding, scoped to a tenant and a
+// scope string the caller derives from whatever else the value depends on.
+type semanticCache struct {
+	mu      sync.Mutex
+	entries []*semanticEntry
+	size    int
+	ttl     time.Duration
+}
+
+type semanticEntry struct {
+	tenantID  string
+	scope     string
+	query     string
+	embedding []float32
+	value     []byte   // encoded, so every hit gets its own copy
+	tags      []string // what the value was derived from, for invalidation
+	createdAt time.Time
+	lastUsed  time.Time
+}
+
+func newSemanticCache(size int, ttl time.Duration) *semanticCache {
+	return &semanticCache{size: max(size, 1), ttl: ttl}
+}
+
+// Get decodes into out the value cached for the query most similar to
+// embedding, at least minSimilarity, and returns that query and its
+// similarity. The query is "" when nothing is close enough.
+func (c *semanticCache) Get(tenantID, scope string, embedding []float32, minSimilarity float64, out interface{}) (string, float64) {
+	c.mu.Lock()
+	var best *semanticEntry
+	bestSimilarity := minSimilarity
+	now := time.Now()
+	for _, entry := range c.entries {
+		if entry.tenantID != tenantID || entry.scope != scope || now.Sub(entry.createdAt) > c.ttl {
+			continue
+		}
+		if similarity := cosineSimilarity(entry.embedding, embedding); similarity >= bestSimilarity {
+			best, bestSimilarity = entry, similarity
+		}
+	}
+	var encoded []byte
+	var query string
+	if best != nil {
+		best.lastUsed = now
+		encoded, query = best.value, best.query
+	}
+	c.mu.Unlock()
+	if best == nil {
+		return "", 0
+	}
+
+	if err := json.Unmarshal(encoded, out); err != nil {
+		return "", 0
+	}
+	return query, bestSimilarity
+}
+
+// Put caches value, evicting expired entries and then the least recently
+// used one when the cache is full.
+func (c *semanticCache) Put(tenantID, scope, query string, embedding []float32, value interface{}, tags []string) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	live := c.entries[:0]
+	for _, entry := range c.entries {
+		if now.Sub(entry.createdAt) <= c.ttl {
+			live = append(live, entry)
+		}
+	}
+	c.entries = live
+	if len(c.entries) >= c.size {
+		oldest := 0
+		for i, entry := range c.entries {
+			if entry.lastUsed.Before(c.entries[oldest].lastUsed) {
+				oldest = i
+			}
+		}
+		c.entries = append(c.entries[:oldest], c.entries[oldest+1:]...)
+	}
+	c.entries = append(c.entries, &semanticEntry{
+		tenantID:  tenantID,
+		scope:     scope,
+		query:     query,
+		embedding: embedding,
+		value:     encoded,
+		tags:      tags,
+		createdAt: now,
+		lastUsed:  now,
+	})
+}
+
+// Invalidate drops the tenant's entries tagged with tag, or all of them when
+// tag is "", and returns how many it dropped.
+func (c *semanticCache) Invalidate(tenantID, tag string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.entries[:0]
+	dropped := 0
+	for _, entry := range c.entries {
+		if entry.tenantID == tenantID && (tag == "" || hasTag(entry.tags, tag)) {
+			dropped++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(c.entries); i++ {
+		c.entries[i] = nil
+	}
+	c.entries = kept
+	return dropped
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// cosineSimilarity of two embeddings; 0 when their lengths differ.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
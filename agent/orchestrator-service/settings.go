@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ============================================================================
+// AGENT SETTINGS
+// ============================================================================
+// The loop's tuning is read from the environment at startup and can be
+// overridden per request:
+//
+//	AGENT_MAX_ITERATIONS        max_iterations        iterations or rounds before asking a follow-up (default 5)
+//	AGENT_CONFIDENCE_THRESHOLD  confidence_threshold  confidence an answer needs to be final (default 0.7)
+//	AGENT_VERIFIER_MODEL        verifier_model        model that verifies answers (default AGENT_MODEL)
+//	AGENT_RERANK                rerank                keyword reranking of search results (default: the
+//	                                                  retrieval service's retrieval_rerank flag)
+//	AGENT_PARTIAL_RESULTS       partial_results       answer with what was gathered when a step fails (default false,
+//	                                                  see partial.go)
+//	AGENT_ROUTE                 route                 auto, fast or full: which queries skip the loop (default auto,
+//	                                                  see router.go)
+//	AGENT_CLARIFY               clarify               ask about ambiguous queries before planning (default false,
+//	                                                  see clarify.go)
+//
+// GET /agent/config reports the settings in effect for a request that
+// overrides none of them.
+
+// AGENT_RERANK is nil when unset, leaving reranking to the retrieval service.
+var AGENT_RERANK = envBool("AGENT_RERANK")
+
+// envBool reads a boolean setting, nil when it is unset or malformed.
+func envBool(key string) *bool {
+	v, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// applySettings fills in the settings req doesn't override.
+func applySettings(req *AgentRequest) {
+	if req.MaxIterations == 0 {
+		req.MaxIterations = MAX_ITERATIONS
+	}
+	if req.ConfidenceThreshold == 0 {
+		req.ConfidenceThreshold = CONFIDENCE_THRESHOLD
+	}
+	if req.Rerank == nil {
+		req.Rerank = AGENT_RERANK
+	}
+	if req.PartialResults == nil {
+		req.PartialResults = AGENT_PARTIAL_RESULTS
+	}
+	if req.Route == "" {
+		req.Route = AGENT_ROUTE
+	}
+	if req.Clarify == nil {
+		req.Clarify = AGENT_CLARIFY
+	}
+}
+
+type rerankKey struct{}
+
+// withRerank attaches the request's reranking choice to ctx for
+// executeSearchRAG. nil leaves it to the retrieval service.
+func withRerank(ctx context.Context, rerank *bool) context.Context {
+	if rerank == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rerankKey{}, rerank)
+}
+
+func rerankFrom(ctx context.Context) *bool {
+	rerank, _ := ctx.Value(rerankKey{}).(*bool)
+	return rerank
+}
+
+// Report the settings in effect
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AgentRequest
+	applySettings(&req)
+	if err := resolveModels(&req); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"mode":                 AGENT_MODE,
+		"max_iterations":       req.MaxIterations,
+		"confidence_threshold": req.ConfidenceThreshold,
+		"rerank":               req.Rerank,
+		"partial_results":      partialResults(req),
+		"route":                req.Route,
+		"clarify":              clarifying(req),
+		"reflection":           reflectionFlag.Enabled(),
+		"history_turns":        AGENT_HISTORY_TURNS,
+		"result_tokens":        AGENT_RESULT_TOKENS,
+		"prompts":              prompts.Sources(),
+		"models": map[string]interface{}{
+			"model":           req.Model,
+			"analysis_model":  req.AnalysisModel,
+			"planner_model":   req.PlannerModel,
+			"synthesis_model": req.SynthesisModel,
+			"verifier_model":  req.VerifierModel,
+			"fallbacks":       AGENT_MODEL_FALLBACKS,
+			"allowed":         AGENT_ALLOWED_MODELS,
+		},
+	}, http.StatusOK)
+}
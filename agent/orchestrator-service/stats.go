@@ -0,0 +1,245 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"shared/tenant"
+)
+
+// ============================================================================
+// USAGE STATISTICS
+// ============================================================================
+// GET /agent/stats?days=N reports how the agent has been used by the
+// caller's tenant over the last N days (default 7): queries per day, the
+// average iterations and confidence, LLM calls and tokens, how often each
+// action and tool was called and failed, and how often runs failed, ran
+// out of budget, asked a follow-up question or were answered from the
+// cache. A run counts as failed when any of its steps did or it produced
+// no answer. Dry runs are not counted.
+//
+// The counts are kept per tenant and UTC day in process memory for
+// AGENT_STATS_DAYS (default 30), so they start over on restart and each
+// replica reports its own traffic; use the Prometheus metrics for
+// fleet-wide numbers.
+
+// dayStats is one tenant's usage on one UTC day.
+type dayStats struct {
+	Queries        int
+	Failed         int
+	BudgetExceeded int
+	NeedMoreInfo   int
+	Cached         int
+	Iterations     int
+	Confidence     float64
+	LLMCalls       int
+	Tokens         int64
+	Modes          map[string]int
+	Actions        map[string]*actionStats // by action type, or tool name for call_tool
+}
+
+// actionStats counts the calls of one action or tool.
+type actionStats struct {
+	Calls     int     `json:"calls"`
+	Failures  int     `json:"failures"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// usageStats holds dayStats by tenant and date.
+type usageStats struct {
+	mu   sync.Mutex
+	days map[string]map[string]*dayStats // tenant → "2006-01-02" → stats
+	keep int
+}
+
+var usage = &usageStats{
+	days: make(map[string]map[string]*dayStats),
+	keep: max(envInt("AGENT_STATS_DAYS", 30), 1),
+}
+
+// dayLocked returns the tenant's stats for today, dropping days past
+// retention. u.mu must be held.
+func (u *usageStats) dayLocked(tenantID string, now time.Time) *dayStats {
+	byDate, ok := u.days[tenantID]
+	if !ok {
+		byDate = make(map[string]*dayStats)
+		u.days[tenantID] = byDate
+	}
+	date := now.UTC().Format(time.DateOnly)
+	day, ok := byDate[date]
+	if !ok {
+		day = &dayStats{Modes: map[string]int{}, Actions: map[string]*actionStats{}}
+		byDate[date] = day
+		oldest := now.UTC().AddDate(0, 0, -u.keep).Format(time.DateOnly)
+		for d := range byDate {
+			if d <= oldest {
+				delete(byDate, d)
+			}
+		}
+	}
+	return day
+}
+
+// recordRun counts a finished run.
+func (u *usageStats) recordRun(tenantID string, req AgentRequest, response AgentResponse) {
+	failed := response.Answer == ""
+	for _, step := range response.Steps {
+		if !step.Success {
+			failed = true
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	day := u.dayLocked(tenantID, time.Now())
+	day.Queries++
+	day.Iterations += response.Iterations
+	day.Confidence += response.Confidence
+	day.LLMCalls += response.LLMCalls
+	day.Tokens += response.TokensUsed
+	day.Modes[req.Mode]++
+	if failed {
+		day.Failed++
+	}
+	if response.BudgetExceeded {
+		day.BudgetExceeded++
+	}
+	if response.NeedMoreInfo {
+		day.NeedMoreInfo++
+	}
+	if response.Cached {
+		day.Cached++
+	}
+}
+
+// recordAction counts one executed action; name is the tool for call_tool.
+func (u *usageStats) recordAction(tenantID, name string, failed bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	day := u.dayLocked(tenantID, time.Now())
+	action, ok := day.Actions[name]
+	if !ok {
+		action = &actionStats{}
+		day.Actions[name] = action
+	}
+	action.Calls++
+	if failed {
+		action.Failures++
+	}
+}
+
+// statsReport is the body of GET /agent/stats.
+type statsReport struct {
+	Days               int                     `json:"days"`
+	From               string                  `json:"from"`
+	To                 string                  `json:"to"`
+	Queries            int                     `json:"queries"`
+	QueriesPerDay      []dailyQueries          `json:"queries_per_day"`
+	AvgIterations      float64                 `json:"avg_iterations"`
+	AvgConfidence      float64                 `json:"avg_confidence"`
+	LLMCalls           int                     `json:"llm_calls"`
+	TokensUsed         int64                   `json:"tokens_used"`
+	AvgTokensPerQuery  float64                 `json:"avg_tokens_per_query"`
+	ErrorRate          float64                 `json:"error_rate"`
+	BudgetExceededRate float64                 `json:"budget_exceeded_rate"`
+	FollowUpRate       float64                 `json:"follow_up_rate"`
+	CacheHitRate       float64                 `json:"cache_hit_rate"`
+	Modes              map[string]int          `json:"modes"`
+	Actions            map[string]*actionStats `json:"actions"`
+}
+
+type dailyQueries struct {
+	Date    string `json:"date"`
+	Queries int    `json:"queries"`
+}
+
+// report sums the tenant's last days days, today included.
+func (u *usageStats) report(tenantID string, days int, now time.Time) statsReport {
+	report := statsReport{
+		Days:          days,
+		From:          now.UTC().AddDate(0, 0, 1-days).Format(time.DateOnly),
+		To:            now.UTC().Format(time.DateOnly),
+		QueriesPerDay: make([]dailyQueries, 0, days),
+		Modes:         map[string]int{},
+		Actions:       map[string]*actionStats{},
+	}
+	var total dayStats
+
+	u.mu.Lock()
+	for i := days - 1; i >= 0; i-- {
+		date := now.UTC().AddDate(0, 0, -i).Format(time.DateOnly)
+		day := u.days[tenantID][date]
+		if day == nil {
+			report.QueriesPerDay = append(report.QueriesPerDay, dailyQueries{Date: date})
+			continue
+		}
+		report.QueriesPerDay = append(report.QueriesPerDay, dailyQueries{Date: date, Queries: day.Queries})
+		total.Queries += day.Queries
+		total.Failed += day.Failed
+		total.BudgetExceeded += day.BudgetExceeded
+		total.NeedMoreInfo += day.NeedMoreInfo
+		total.Cached += day.Cached
+		total.Iterations += day.Iterations
+		total.Confidence += day.Confidence
+		total.LLMCalls += day.LLMCalls
+		total.Tokens += day.Tokens
+		for mode, n := range day.Modes {
+			report.Modes[mode] += n
+		}
+		for name, action := range day.Actions {
+			sum, ok := report.Actions[name]
+			if !ok {
+				sum = &actionStats{}
+				report.Actions[name] = sum
+			}
+			sum.Calls += action.Calls
+			sum.Failures += action.Failures
+		}
+	}
+	u.mu.Unlock()
+
+	for _, action := range report.Actions {
+		action.ErrorRate = ratio(float64(action.Failures), action.Calls)
+	}
+	report.Queries = total.Queries
+	report.AvgIterations = ratio(float64(total.Iterations), total.Queries)
+	report.AvgConfidence = ratio(total.Confidence, total.Queries)
+	report.LLMCalls = total.LLMCalls
+	report.TokensUsed = total.Tokens
+	report.AvgTokensPerQuery = ratio(float64(total.Tokens), total.Queries)
+	report.ErrorRate = ratio(float64(total.Failed), total.Queries)
+	report.BudgetExceededRate = ratio(float64(total.BudgetExceeded), total.Queries)
+	report.FollowUpRate = ratio(float64(total.NeedMoreInfo), total.Queries)
+	report.CacheHitRate = ratio(float64(total.Cached), total.Queries)
+	return report
+}
+
+// ratio is n/d, or 0 when d is 0.
+func ratio(n float64, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return n / float64(d)
+}
+
+// Report the tenant's agent usage
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > usage.keep {
+			respondError(w, "days must be between 1 and "+strconv.Itoa(usage.keep), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	respondJSON(w, usage.report(tenant.FromContext(r.Context()), days, time.Now()), http.StatusOK)
+}
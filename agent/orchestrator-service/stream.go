@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genai"
+	"shared/tracing"
+)
+
+// ============================================================================
+// STREAMING (Server-Sent Events)
+// ============================================================================
+
+// progress forwards agent steps and answer tokens to a streaming client as
+// they happen. A nil *progress ignores everything; the blocking endpoint
+// passes nil.
+type progress struct {
+	step  func(AgentStep)
+	token func(iteration int, text string)
+}
+
+// tokenSink returns the callback synthesizeAnswer streams into for the given
+// iteration, or nil when nobody is listening.
+func (p *progress) tokenSink(iteration int) func(string) {
+	if p == nil || p.token == nil {
+		return nil
+	}
+	return func(text string) { p.token(iteration, text) }
+}
+
+// recordStep numbers step, tags it with the current iteration, appends it to
+// the response trace and reports it.
+func recordStep(response *AgentResponse, prog *progress, step AgentStep) {
+	step.StepNumber = len(response.Steps) + 1
+	if step.Iteration == 0 {
+		step.Iteration = response.iteration
+	}
+	response.Steps = append(response.Steps, step)
+	if prog != nil && prog.step != nil {
+		prog.step(step)
+	}
+}
+
+// beginIteration starts the loop's iteration n: the steps recorded from now
+// on belong to it, and it is counted in Iterations. A loop the fast path
+// escalated to numbers its iterations on from the fast path's.
+func (r *AgentResponse) beginIteration(n int) {
+	r.iteration = r.iterationBase + n
+	r.Iterations = r.iteration
+}
+
+// groupSteps groups steps by iteration, in the order the iterations began.
+func groupSteps(steps []AgentStep) []StepGroup {
+	groups := []StepGroup{}
+	index := map[int]int{}
+	for _, step := range steps {
+		i, ok := index[step.Iteration]
+		if !ok {
+			i = len(groups)
+			index[step.Iteration] = i
+			groups = append(groups, StepGroup{Iteration: step.Iteration, Success: true})
+		}
+		groups[i].Steps = append(groups[i].Steps, step.StepNumber)
+		groups[i].Success = groups[i].Success && step.Success
+		groups[i].Duration += step.Duration
+	}
+	return groups
+}
+
+// streamAnswer generates the answer with Gemini's streaming API, handing
+// each text chunk to onToken, and returns the full answer with the model
+// that wrote it. It falls back to the next model only while nothing has
+// been streamed yet, so a client never sees two answers spliced together.
+func streamAnswer(ctx context.Context, modelName, prompt string, onToken func(string)) (string, string) {
+	var config *genai.GenerateContentConfig
+	if persona := personaFrom(ctx); persona != "" {
+		config = withSystemInstruction(nil, persona)
+	}
+	models := modelsToTry(modelName)
+	for i, model := range models {
+		provider, name, err := providerFor(model)
+		if err != nil {
+			log.Printf("Synthesis skipped: %v", err)
+			break
+		}
+		if err := budgetFrom(ctx).reserve(); err != nil {
+			log.Printf("Synthesis skipped: %v", err)
+			break
+		}
+		var answer strings.Builder
+		var streamErr error
+		var usage *genai.GenerateContentResponseUsageMetadata
+		start := time.Now()
+		callCtx, cancel := withCallTimeout(ctx, AGENT_GEMINI_TIMEOUT)
+		callCtx, span := startGeminiSpan(callCtx, model, i)
+		span.SetAttributes(attribute.Bool("gemini.stream", true))
+		for chunk, err := range provider.stream(callCtx, name, genai.Text(prompt), config) {
+			if err != nil {
+				streamErr = err
+				break
+			}
+			if chunk.UsageMetadata != nil {
+				usage = chunk.UsageMetadata // cumulative; the last chunk has the total
+			}
+			if err := checkResponse(chunk); err != nil {
+				streamErr = err
+				break
+			}
+			text := responseText(chunk)
+			if text == "" {
+				continue
+			}
+			answer.WriteString(text)
+			onToken(text)
+		}
+		endGeminiSpan(span, usage, streamErr)
+		cancel()
+		budgetFrom(ctx).charge(usage)
+		if streamErr == nil {
+			callLatencies.Observe("llm", time.Since(start))
+		}
+
+		var blocked *blockedError
+		if errors.As(streamErr, &blocked) {
+			modelBlocks.WithLabelValues(blocked.Reason).Inc()
+			if answer.Len() == 0 {
+				log.Printf("Synthesis blocked: %v", streamErr)
+				auditTrailFrom(ctx).output(model, "", nil)
+				return declinedAnswer(blocked), model
+			}
+		}
+		if streamErr != nil {
+			log.Printf("Synthesis stream failed: %v", streamErr)
+			if answer.Len() == 0 {
+				if i < len(models)-1 && shouldFallBack(ctx, streamErr) {
+					log.Printf("⚠️  %s failed, falling back to %s", model, models[i+1])
+					modelFallbacks.WithLabelValues(model, models[i+1]).Inc()
+					continue
+				}
+				return "Unable to synthesize answer from available information.", ""
+			}
+		}
+
+		auditTrailFrom(ctx).output(model, answer.String(), nil)
+		if answer.Len() == 0 {
+			return "No answer could be generated.", model
+		}
+		return answer.String(), model
+	}
+	return "Unable to synthesize answer from available information.", ""
+}
+
+// sseWriter serialises events onto the response. The heartbeat runs on
+// another goroutine, hence the mutex.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseWriter) send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.rc.Flush()
+}
+
+func (s *sseWriter) ping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprint(s.w, ": ping\n\n")
+	s.rc.Flush()
+}
+
+// agentStreamHandler runs the same loop as /agent/query but reports progress
+// as Server-Sent Events instead of one response at the end:
+//
+//	event: start  {"conversation_id": "...", "trace_id": "..."}, the trace ID when traced
+//	event: step   an AgentStep, as soon as it completes
+//	event: token  {"iteration": 1, "text": "..."} chunks of the synthesized answer
+//	event: done   the final AgentResponse
+//
+// Every iteration re-synthesizes the answer, so clients should start a fresh
+// answer when the token iteration changes.
+func agentStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, ok := decodeAgentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Streaming not supported by response writer: %v", err)
+		return
+	}
+
+	stream := &sseWriter{w: w, rc: rc}
+	start := map[string]string{"conversation_id": req.ConversationID}
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		start["trace_id"] = traceID
+	}
+	stream.send("start", start)
+
+	// Keep idle proxies from closing the connection during long steps.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				stream.ping()
+			}
+		}
+	}()
+
+	response := runAgentQuery(r.Context(), req, &progress{
+		step: func(step AgentStep) { stream.send("step", step) },
+		token: func(iteration int, text string) {
+			stream.send("token", map[string]interface{}{"iteration": iteration, "text": text})
+		},
+	})
+	notifyCallback(r.Context(), req, "", response, r.Context().Err())
+	stream.send("done", response)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// STRUCTURED OUTPUT
+// ============================================================================
+// A request with a response_schema gets its answer as JSON matching the
+// schema, for callers that consume answers programmatically. The schema is
+// a subset of JSON Schema: type, properties, required, items, enum, format,
+// description, nullable, minimum/maximum and minItems/maxItems. Synthesis is
+// constrained with Gemini's responseSchema, and the result is validated
+// against the caller's schema before it is returned. An answer that fails
+// validation gets one repair attempt with the errors fed back; if that fails
+// too, output_error says why and output is left out. The answer field still
+// carries the JSON text, so citations work as usual for [n] markers inside
+// string values. ReAct mode writes free text and can't take a schema.
+
+// maxSchemaDepth bounds how deeply a response_schema may nest.
+const maxSchemaDepth = 10
+
+// schemaTypes maps JSON Schema types to Gemini's.
+var schemaTypes = map[string]genai.Type{
+	"string":  genai.TypeString,
+	"number":  genai.TypeNumber,
+	"integer": genai.TypeInteger,
+	"boolean": genai.TypeBoolean,
+	"array":   genai.TypeArray,
+	"object":  genai.TypeObject,
+}
+
+// convertSchema translates a caller's JSON Schema into Gemini's, rejecting
+// keywords Gemini can't enforce.
+func convertSchema(schema map[string]interface{}) (*genai.Schema, error) {
+	return convertSchemaAt(schema, "response_schema", 0)
+}
+
+func convertSchemaAt(schema map[string]interface{}, path string, depth int) (*genai.Schema, error) {
+	if depth > maxSchemaDepth {
+		return nil, fmt.Errorf("%s: schema nests deeper than %d levels", path, maxSchemaDepth)
+	}
+	for _, keyword := range []string{"$ref", "oneOf", "anyOf", "allOf", "not"} {
+		if _, ok := schema[keyword]; ok {
+			return nil, fmt.Errorf("%s: %s is not supported", path, keyword)
+		}
+	}
+
+	name, _ := schema["type"].(string)
+	typ, ok := schemaTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: type must be one of string, number, integer, boolean, array or object", path)
+	}
+	out := &genai.Schema{Type: typ}
+	out.Description, _ = schema["description"].(string)
+	out.Format, _ = schema["format"].(string)
+	out.Nullable, _ = schema["nullable"].(bool)
+	if v, ok := schema["minimum"].(float64); ok {
+		out.Minimum = &v
+	}
+	if v, ok := schema["maximum"].(float64); ok {
+		out.Maximum = &v
+	}
+	if v, ok := schema["minItems"].(float64); ok {
+		n := int64(v)
+		out.MinItems = &n
+	}
+	if v, ok := schema["maxItems"].(float64); ok {
+		n := int64(v)
+		out.MaxItems = &n
+	}
+
+	if raw, ok := schema["enum"]; ok {
+		values, _ := raw.([]interface{})
+		for _, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: enum values must be strings", path)
+			}
+			out.Enum = append(out.Enum, s)
+		}
+	}
+
+	switch typ {
+	case genai.TypeObject:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if len(properties) == 0 {
+			return nil, fmt.Errorf("%s: an object needs properties", path)
+		}
+		out.Properties = make(map[string]*genai.Schema, len(properties))
+		for name, raw := range properties {
+			property, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s.properties.%s: must be a schema", path, name)
+			}
+			converted, err := convertSchemaAt(property, path+".properties."+name, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out.Properties[name] = converted
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, raw := range required {
+			name, _ := raw.(string)
+			if _, ok := properties[name]; !ok {
+				return nil, fmt.Errorf("%s: required property %q is not defined", path, name)
+			}
+			out.Required = append(out.Required, name)
+		}
+
+	case genai.TypeArray:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: an array needs items", path)
+		}
+		converted, err := convertSchemaAt(items, path+".items", depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = converted
+	}
+	return out, nil
+}
+
+// validateOutput checks value, decoded JSON, against the caller's schema and
+// returns every mismatch.
+func validateOutput(value interface{}, schema map[string]interface{}) []string {
+	var problems []string
+	validateAt(value, schema, "$", &problems)
+	return problems
+}
+
+func validateAt(value interface{}, schema map[string]interface{}, path string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, s) {
+			fail("must be one of %v", enum)
+		}
+
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be a %s", schema["type"])
+			return
+		}
+		if schema["type"] == "integer" && n != math.Trunc(n) {
+			fail("must be an integer")
+		}
+		if lo, ok := schema["minimum"].(float64); ok && n < lo {
+			fail("must be at least %v", lo)
+		}
+		if hi, ok := schema["maximum"].(float64); ok && n > hi {
+			fail("must be at most %v", hi)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if lo, ok := schema["minItems"].(float64); ok && float64(len(items)) < lo {
+			fail("must have at least %v items", lo)
+		}
+		if hi, ok := schema["maxItems"].(float64); ok && float64(len(items)) > hi {
+			fail("must have at most %v items", hi)
+		}
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		for i, item := range items {
+			validateAt(item, itemSchema, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, raw := range required {
+			name, _ := raw.(string)
+			if _, ok := object[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, raw := range properties {
+			property, _ := raw.(map[string]interface{})
+			if v, ok := object[name]; ok {
+				validateAt(v, property, path+"."+name, problems)
+			}
+		}
+	}
+}
+
+func containsValue(values []interface{}, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOutput parses answer and validates it against schema.
+func checkOutput(answer string, schema map[string]interface{}) (json.RawMessage, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(answer), &value); err != nil {
+		return nil, fmt.Errorf("answer is not valid JSON: %w", err)
+	}
+	if problems := validateOutput(value, schema); len(problems) > 0 {
+		return nil, fmt.Errorf("answer does not match response_schema: %s", strings.Join(problems, "; "))
+	}
+	return json.RawMessage(answer), nil
+}
+
+// structuredConfig constrains a synthesis call to JSON matching schema.
+func structuredConfig(schema *genai.Schema) *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   schema,
+	}
+}
+
+// repairOutput gives the model one chance to fix an answer that failed
+// validation, returning the original when the repair fails too.
+func repairOutput(ctx context.Context, req AgentRequest, answer string) string {
+	_, err := checkOutput(answer, req.ResponseSchema)
+	if err == nil || budgetExceeded(ctx) {
+		return answer
+	}
+	log.Printf("Structured answer invalid, repairing: %v", err)
+
+	prompt := fmt.Sprintf(`This JSON was written to answer the question %q but is invalid:
+
+%s
+
+Problem: %v
+
+Return the corrected JSON, keeping its content and citation markers.`, req.Query, answer, err)
+	resp, _, genErr := generateContent(ctx, req.SynthesisModel, genai.Text(prompt), structuredConfig(req.schema))
+	if genErr != nil {
+		log.Printf("Repair failed: %v", genErr)
+		return answer
+	}
+	repaired := responseText(resp)
+	if _, err := checkOutput(repaired, req.ResponseSchema); err != nil {
+		return answer
+	}
+	return repaired
+}
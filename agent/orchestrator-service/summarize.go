@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// RESULT SUMMARIZATION
+// ============================================================================
+// Action results are compressed before a model reads them. A search_rag
+// result is quoted as its passages, which share the result's budget; any
+// other result is flattened to the text fields it carries, one "path: value"
+// line each, leaving out bookkeeping such as action_type or cached, and
+// trimmed to the budget. AGENT_RESULT_TOKENS is that budget per result
+// (default 500, the estimate.go assumption for a tool result); 0 disables
+// trimming.
+
+var AGENT_RESULT_TOKENS = envInt("AGENT_RESULT_TOKENS", toolResultTokens)
+
+// resultNoise are fields of a result that say nothing about its content.
+var resultNoise = map[string]bool{
+	"action_type": true,
+	"cached":      true,
+	"citation":    true,
+	"collection":  true,
+	"duration_ms": true,
+	"embedding":   true,
+	"latency_ms":  true,
+	"timestamp":   true,
+	"vector":      true,
+}
+
+// resultBudgetChars is the per-result budget in characters, 0 when
+// unbounded.
+func resultBudgetChars() int {
+	if AGENT_RESULT_TOKENS <= 0 {
+		return 0
+	}
+	return AGENT_RESULT_TOKENS * charsPerToken
+}
+
+// summarizeResult flattens a non-search result to its text fields and trims
+// it to the budget.
+func summarizeResult(result map[string]interface{}) string {
+	var lines []string
+	flattenResult("", result, &lines)
+	summary := strings.Join(lines, "\n")
+	if limit := resultBudgetChars(); limit > 0 {
+		summary = truncate(summary, limit)
+	}
+	return summary
+}
+
+// flattenResult appends a "path: value" line for every scalar under v.
+func flattenResult(path string, v interface{}, lines *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			if !resultNoise[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenResult(joinPath(path, k), v[k], lines)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenResult(fmt.Sprintf("%s[%d]", path, i), item, lines)
+		}
+	case string:
+		if s := strings.Join(strings.Fields(v), " "); s != "" {
+			*lines = append(*lines, path+": "+s)
+		}
+	case nil:
+	default:
+		*lines = append(*lines, fmt.Sprintf("%s: %v", path, v))
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// passageLimit splits the result budget between n passages of one search,
+// never above maxPassageChars.
+func passageLimit(n int) int {
+	limit := resultBudgetChars()
+	if limit == 0 || n == 0 {
+		return maxPassageChars
+	}
+	return min(maxPassageChars, max(limit/n, maxSnippetChars))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// TOOL CATALOG
+// ============================================================================
+// The MCP tools the agent may call are whatever the gateway has registered.
+// The catalog is fetched from the gateway's /tools/list at startup and every
+// AGENT_TOOL_REFRESH_INTERVAL (default 5m, 0 fetches only at startup). The
+// call_tool function only accepts tools in it, and every prompt that can
+// call tools lists their names, descriptions and parameters, so a tool
+// registered with the gateway is used from the next refresh on. When the
+// gateway can't be reached the last catalog is kept, starting from the
+// built-in tools below.
+
+// catalogTool - An MCP tool as the gateway lists it
+type catalogTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// builtinTools are planned with until the gateway has been reached.
+var builtinTools = []catalogTool{
+	{Name: "data-extractor", Description: "Extract structured fields from text"},
+	{Name: "risk-score", Description: "Calculate merchant risk score", Parameters: map[string]interface{}{"merchant_data": "object"}},
+	{Name: "verify-docs", Description: "Verify and extract information from KYC documents", Parameters: map[string]interface{}{"document_type": "string", "file_path": "string (optional)"}},
+	{Name: "web-search", Description: "Search web for latest information", Parameters: map[string]interface{}{"query": "string"}},
+}
+
+// toolCatalog holds the tools last listed by the gateway, sorted by name.
+type toolCatalog struct {
+	mu      sync.RWMutex
+	tools   []catalogTool
+	updated time.Time // zero until the gateway has been reached
+}
+
+var mcpTools = &toolCatalog{tools: builtinTools}
+
+// List returns the tools in the catalog.
+func (c *toolCatalog) List() []catalogTool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tools
+}
+
+// Updated returns when the catalog was last listed by the gateway, zero if
+// never.
+func (c *toolCatalog) Updated() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.updated
+}
+
+// Names returns the names of the tools in the catalog.
+func (c *toolCatalog) Names() []string {
+	tools := c.List()
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	return names
+}
+
+// Prompt describes the catalog for a prompt that lets the model call tools.
+func (c *toolCatalog) Prompt() string {
+	var b strings.Builder
+	b.WriteString("Tools you can call with call_tool:\n")
+	for _, tool := range c.List() {
+		fmt.Fprintf(&b, "- %s: %s", tool.Name, tool.Description)
+		if len(tool.Parameters) > 0 {
+			params, _ := json.Marshal(tool.Parameters)
+			fmt.Fprintf(&b, ". Parameters: %s", params)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Refresh replaces the catalog with the gateway's tool list.
+func (c *toolCatalog) Refresh(ctx context.Context) error {
+	var out struct {
+		Tools []catalogTool `json:"tools"`
+	}
+	if err := mcpClient.GetJSON(ctx, MCP_GATEWAY_URL+"/tools/list", &out); err != nil {
+		return fmt.Errorf("failed to list tools: %w", err)
+	}
+	if len(out.Tools) == 0 {
+		return fmt.Errorf("gateway lists no tools")
+	}
+	sort.Slice(out.Tools, func(i, j int) bool { return out.Tools[i].Name < out.Tools[j].Name })
+
+	c.mu.Lock()
+	c.tools = out.Tools
+	c.updated = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// watch refreshes the catalog now and then every interval.
+func (c *toolCatalog) watch(interval time.Duration) {
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := c.Refresh(ctx); err != nil {
+			log.Printf("Tool catalog not refreshed, keeping %d tools: %v", len(c.List()), err)
+			return
+		}
+		log.Printf("🔧 Tool catalog: %s", strings.Join(c.Names(), ", "))
+	}
+
+	refresh()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		refresh()
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genai"
+	"shared/flags"
+	"shared/tenant"
+)
+
+// ============================================================================
+// USER MEMORY
+// ============================================================================
+// A request with a user_id gets a memory that outlives its conversations.
+// After each turn the analysis model picks out durable facts about the user
+// or their business, such as "our merchants are all in gaming", and they are
+// remembered. Later requests with the same user_id, in any conversation,
+// recall them into every planning prompt, so the planner searches for what
+// applies to the user, and record a "memory" step. The memory is kept with
+// the conversations, in the CONVERSATION_STORE backend, but never expires:
+//
+//	AGENT_USER_MEMORY_FACTS  facts kept per user, oldest dropped first (default 50)
+//
+// GET /agent/users/{user_id}/memory lists what is remembered and DELETE
+// forgets it. As with conversation history, requests with a memory bypass
+// the plan and answer caches. Turn memory off with the agent_user_memory
+// flag.
+
+var userMemoryFlag = flags.Define("agent_user_memory", true,
+	"Remember facts about a request's user_id and recall them into planning prompts")
+
+var userMemoryFacts = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "agent_user_memory_facts_total",
+	Help: "Facts about users remembered after their turns.",
+})
+
+var AGENT_USER_MEMORY_FACTS = max(envInt("AGENT_USER_MEMORY_FACTS", 50), 1)
+
+// maxUserIDChars bounds a user_id; maxFactChars bounds a remembered fact.
+const (
+	maxUserIDChars = 128
+	maxFactChars   = 300
+)
+
+// MemoryFact - Something remembered about a user
+type MemoryFact struct {
+	Fact           string    `json:"fact"`
+	ConversationID string    `json:"conversation_id,omitempty"` // where it was learned
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// validateUserID checks a user_id supplied by a caller.
+func validateUserID(userID string) error {
+	if len(userID) > maxUserIDChars || strings.Contains(userID, "/") {
+		return fmt.Errorf("user_id must be at most %d characters and contain no '/'", maxUserIDChars)
+	}
+	return nil
+}
+
+type userMemoryKey struct{}
+
+// withUserMemory attaches the user's facts to ctx for the planning prompts.
+func withUserMemory(ctx context.Context, facts []MemoryFact) context.Context {
+	if len(facts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, userMemoryKey{}, facts)
+}
+
+func userMemoryFrom(ctx context.Context) []MemoryFact {
+	facts, _ := ctx.Value(userMemoryKey{}).([]MemoryFact)
+	return facts
+}
+
+// withMemory appends the user's facts to a planning prompt; it returns
+// prompt unchanged when there are none.
+func withMemory(prompt string, facts []MemoryFact) string {
+	if len(facts) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nWhat you know about the user from earlier conversations:\n")
+	for _, fact := range facts {
+		fmt.Fprintf(&b, "- %s\n", fact.Fact)
+	}
+	b.WriteString("Plan for what applies to this user.")
+	return b.String()
+}
+
+// recallUserMemory loads the memory of req's user into ctx and records the
+// memory step. A memory that can't be loaded only costs context, so it
+// never fails the query.
+func recallUserMemory(ctx context.Context, req AgentRequest, response *AgentResponse, prog *progress) context.Context {
+	if req.UserID == "" || !userMemoryFlag.Enabled() {
+		return ctx
+	}
+	start := time.Now()
+	facts, err := conversations.Recall(ctx, tenant.FromContext(ctx), req.UserID)
+	if err != nil {
+		log.Printf("Failed to recall memory of %s: %v", req.UserID, err)
+		return ctx
+	}
+	if len(facts) == 0 {
+		return ctx
+	}
+	recordStep(response, prog, AgentStep{
+		Type:        "memory",
+		Description: "Recall what is known about the user",
+		Result:      fmt.Sprintf("Recalled %d facts", len(facts)),
+		Success:     true,
+		Duration:    float64(time.Since(start).Milliseconds()),
+	})
+	return withUserMemory(ctx, facts)
+}
+
+// rememberDecl is how the analysis model hands back the facts to remember.
+var rememberDecl = &genai.FunctionDeclaration{
+	Name:        "remember",
+	Description: "Remember durable facts about the user for future conversations. Call it with an empty list when there are none.",
+	Parameters: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"facts": {
+				Type:        genai.TypeArray,
+				Items:       &genai.Schema{Type: genai.TypeString},
+				Description: "New facts, one short self-contained sentence each",
+			},
+		},
+		Required: []string{"facts"},
+	},
+}
+
+var rememberConfig = &genai.GenerateContentConfig{
+	Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{rememberDecl}}},
+	ToolConfig: &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAny},
+	},
+}
+
+// rememberTurn extracts what a turn revealed about req's user and adds it
+// to their memory. It runs after the response, so failures are only logged.
+func rememberTurn(ctx context.Context, req AgentRequest, message, answer string) {
+	tenantID := tenant.FromContext(ctx)
+	known := userMemoryFrom(ctx)
+	prompt := fmt.Sprintf(`A user asked an AI agent about regulatory, KYC and risk requirements.
+
+User: %s
+Agent: %s
+
+List durable facts the user revealed about themselves or their business
+that would help answer their future questions: their industry, customers,
+merchants, products, jurisdictions, role or preferences. Leave out facts
+about regulations from the agent's answer, one-off details of this question,
+and personal data such as names, card, account, PAN or Aadhaar numbers.`,
+		truncate(message, maxHistoryMessageChars), truncate(answer, maxHistoryMessageChars))
+	prompt = withMemory(prompt, known) + "\nOnly list facts not already known."
+
+	resp, _, err := generateContent(ctx, req.AnalysisModel, genai.Text(prompt), rememberConfig)
+	if err != nil {
+		log.Printf("Failed to extract memory of %s: %v", req.UserID, err)
+		return
+	}
+	calls := resp.FunctionCalls()
+	if len(calls) == 0 {
+		return
+	}
+	raw, _ := calls[0].Args["facts"].([]interface{})
+
+	seen := map[string]bool{}
+	for _, fact := range known {
+		seen[strings.ToLower(fact.Fact)] = true
+	}
+	var facts []MemoryFact
+	now := time.Now()
+	for _, r := range raw {
+		fact, _ := r.(string)
+		fact = strings.TrimSpace(fact)
+		if fact == "" || len(fact) > maxFactChars || seen[strings.ToLower(fact)] {
+			continue
+		}
+		// Facts carrying sensitive numbers are not worth the risk of keeping
+		if _, redacted := redactPII(fact); len(redacted) > 0 {
+			continue
+		}
+		seen[strings.ToLower(fact)] = true
+		facts = append(facts, MemoryFact{Fact: fact, ConversationID: req.ConversationID, CreatedAt: now})
+	}
+	if len(facts) == 0 {
+		return
+	}
+	if err := conversations.Remember(ctx, tenantID, req.UserID, AGENT_USER_MEMORY_FACTS, facts...); err != nil {
+		log.Printf("Failed to remember facts about %s: %v", req.UserID, err)
+		return
+	}
+	userMemoryFacts.Add(float64(len(facts)))
+	log.Printf("🧠 Remembered %d facts about %s", len(facts), req.UserID)
+}
+
+// List or forget what is remembered about a user
+func userMemoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/agent/users/"), "/memory")
+	if !ok || userID == "" || strings.Contains(userID, "/") {
+		respondError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	tenantID := tenant.FromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		facts, err := conversations.Recall(r.Context(), tenantID, userID)
+		if err != nil {
+			log.Printf("Failed to recall memory of %s: %v", userID, err)
+			respondError(w, "Failed to load memory", http.StatusInternalServerError)
+			return
+		}
+		if facts == nil {
+			facts = []MemoryFact{}
+		}
+		respondJSON(w, map[string]interface{}{
+			"user_id": userID,
+			"facts":   facts,
+		}, http.StatusOK)
+	case http.MethodDelete:
+		if err := conversations.Forget(r.Context(), tenantID, userID); err != nil {
+			log.Printf("Failed to forget %s: %v", userID, err)
+			respondError(w, "Failed to forget memory", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]string{"status": "forgotten", "user_id": userID}, http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -1,5 +1,3 @@
 module GoRilla-Rag
 
 go 1.24.3
-
-require github.com/qdrant/go-client v1.15.2 // indirect
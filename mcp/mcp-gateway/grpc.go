@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"protos/gorillapb"
+)
+
+// toolServer exposes the registry over the gorilla.v1.ToolService contract.
+type toolServer struct {
+	gorillapb.UnimplementedToolServiceServer
+}
+
+func (toolServer) ListTools(ctx context.Context, req *gorillapb.ListToolsRequest) (*gorillapb.ListToolsResponse, error) {
+	tools := listTools()
+
+	resp := &gorillapb.ListToolsResponse{
+		Tools: make([]*gorillapb.Tool, len(tools)),
+		Count: int32(len(tools)),
+	}
+	for i, tool := range tools {
+		params, err := structpb.NewStruct(tool.Parameters)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid parameters for %s: %v", tool.Name, err)
+		}
+		resp.Tools[i] = &gorillapb.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Endpoint:    tool.Endpoint,
+			Parameters:  params,
+		}
+	}
+	return resp, nil
+}
+
+func (toolServer) CallTool(ctx context.Context, req *gorillapb.CallToolRequest) (*gorillapb.CallToolResponse, error) {
+	registryMutex.RLock()
+	tool, exists := toolRegistry[req.GetTool()]
+	registryMutex.RUnlock()
+
+	if !exists {
+		return nil, status.Error(codes.NotFound, "tool not found")
+	}
+
+	result, err := invokeTool(ctx, tool, req.GetParams().AsMap())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	out, err := structpb.NewStruct(result)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid tool response: %v", err)
+	}
+	return &gorillapb.CallToolResponse{Result: out}, nil
+}
@@ -2,14 +2,35 @@ package main
 
 import (
 	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"sync"
+	"time"
+
+	"protos/gorillapb"
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/ratelimit"
+	"shared/rpc"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 // Tool definition
 type Tool struct {
 	Name        string                 `json:"name"`
@@ -18,6 +39,15 @@ type Tool struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+// ToolHealth - Result of probing a tool's /healthz
+type ToolHealth struct {
+	Name      string  `json:"name"`
+	Endpoint  string  `json:"endpoint"`
+	Healthy   bool    `json:"healthy"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
 // Tool registry
 var (
 	toolRegistry  = make(map[string]Tool)
@@ -25,17 +55,64 @@ var (
 )
 
 func main() {
+	shutdownTracing, err := tracing.Init("mcp-gateway")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("mcp-gateway")
+
 	// Register default tools
 	registerDefaultTools()
 
+	limiter, err := ratelimit.New()
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+	defer limiter.Close()
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("mcp-gateway"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("mcp-gateway", nil))
 	http.HandleFunc("/tools/list", listToolsHandler)
 	http.HandleFunc("/tools/call", callToolHandler)
 	http.HandleFunc("/tools/register", registerToolHandler)
+	http.HandleFunc("/tools/health", toolHealthHandler)
+
+	grpcServer, err := rpc.NewServer()
+	if err != nil {
+		log.Fatalf("Failed to create gRPC server: %v", err)
+	}
+	gorillapb.RegisterToolServiceServer(grpcServer, toolServer{})
+	rpc.Serve(grpcServer, ":"+getEnv("GRPC_PORT", "50055"))
 
 	port := getEnv("PORT", "9100")
 	log.Printf("🔧 MCP Gateway starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("mcp-gateway", handler)
+	handler = ratelimit.Wrap("mcp-gateway", limiter, ratelimit.ConfigFromEnv(), handler)
+	handler = metrics.Wrap("mcp-gateway", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Method: http.MethodPost, Path: "/tools/call", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/tools/register", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer.GracefulStop()
 }
 
 func registerDefaultTools() {
@@ -43,7 +120,7 @@ func registerDefaultTools() {
 		{
 			Name:        "verify-docs",
 			Description: "Verify and extract information from KYC documents",
-			Endpoint:    "http://localhost:9101/verify",
+			Endpoint:    getEnv("VERIFY_DOCS_URL", "http://localhost:9101") + "/verify",
 			Parameters: map[string]interface{}{
 				"document_type": "string",
 				"file_path":     "string (optional)",
@@ -52,7 +129,7 @@ func registerDefaultTools() {
 		{
 			Name:        "risk-score",
 			Description: "Calculate merchant risk score",
-			Endpoint:    "http://localhost:9102/calculate",
+			Endpoint:    getEnv("RISK_SCORE_URL", "http://localhost:9102") + "/calculate",
 			Parameters: map[string]interface{}{
 				"merchant_data": "object",
 			},
@@ -60,7 +137,7 @@ func registerDefaultTools() {
 		{
 			Name:        "web-search",
 			Description: "Search web for latest information",
-			Endpoint:    "http://localhost:9103/search",
+			Endpoint:    getEnv("WEB_SEARCH_URL", "http://localhost:9103") + "/search",
 			Parameters: map[string]interface{}{
 				"query": "string",
 			},
@@ -82,13 +159,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listToolsHandler(w http.ResponseWriter, r *http.Request) {
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
-
-	tools := make([]Tool, 0, len(toolRegistry))
-	for _, tool := range toolRegistry {
-		tools = append(tools, tool)
-	}
+	tools := listTools()
 
 	respondJSON(w, map[string]interface{}{
 		"tools": tools,
@@ -121,24 +192,110 @@ func callToolHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	result, err := invokeTool(r.Context(), tool, req.Params)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, result, http.StatusOK)
+}
+
+// invokeTool forwards params to the tool's endpoint and decodes its JSON reply.
+func invokeTool(ctx context.Context, tool Tool, params map[string]interface{}) (map[string]interface{}, error) {
 	log.Printf("🔧 Calling tool: %s", tool.Name)
 
-	// Forward request to tool
-	requestBody, _ := json.Marshal(req.Params)
-	resp, err := http.Post(tool.Endpoint, "application/json", bytes.NewBuffer(requestBody))
+	requestBody, _ := json.Marshal(params)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.Endpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
-		respondError(w, fmt.Sprintf("Tool call failed: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("Tool call failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Tools are registered at runtime, so the service token is attached here
+	// rather than through the default transport's upstream list.
+	if auth.Enabled() {
+		token, err := auth.ServiceToken()
+		if err != nil {
+			return nil, fmt.Errorf("Tool call failed: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Tool call failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		respondError(w, "Failed to decode tool response", http.StatusInternalServerError)
+		return nil, errors.New("Failed to decode tool response")
+	}
+	return result, nil
+}
+
+// listTools returns a snapshot of the registry.
+func listTools() []Tool {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	tools := make([]Tool, 0, len(toolRegistry))
+	for _, tool := range toolRegistry {
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// toolHealthHandler probes every registered tool's /healthz concurrently.
+func toolHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	respondJSON(w, result, http.StatusOK)
+	tools := listTools()
+	results := make([]ToolHealth, len(tools))
+
+	var wg sync.WaitGroup
+	for i, tool := range tools {
+		wg.Add(1)
+		go func(i int, tool Tool) {
+			defer wg.Done()
+			results[i] = probeTool(r.Context(), tool)
+		}(i, tool)
+	}
+	wg.Wait()
+
+	status := "healthy"
+	for _, result := range results {
+		if !result.Healthy {
+			status = "degraded"
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	respondJSON(w, map[string]interface{}{"status": status, "tools": results, "count": len(results)}, http.StatusOK)
+}
+
+func probeTool(ctx context.Context, tool Tool) ToolHealth {
+	health := ToolHealth{Name: tool.Name, Endpoint: tool.Endpoint}
+
+	u, err := url.Parse(tool.Endpoint)
+	if err != nil || u.Host == "" {
+		health.Error = "invalid endpoint"
+		return health
+	}
+
+	start := time.Now()
+	err = server.HTTPCheck(u.Scheme + "://" + u.Host + "/healthz")(ctx)
+	health.LatencyMs = float64(time.Since(start).Milliseconds())
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Healthy = true
+	return health
 }
 
 func registerToolHandler(w http.ResponseWriter, r *http.Request) {
@@ -1,20 +1,55 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"log"
 	"math"
 	"net/http"
 	"os"
+
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tenant"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 func main() {
+	shutdownTracing, err := tracing.Init("risk-score")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("risk-score"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("risk-score", nil))
 	http.HandleFunc("/calculate", calculateHandler)
 
 	port := getEnv("PORT", "9102")
 	log.Printf("⚠️  risk-score tool starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("risk-score", handler)
+	handler = metrics.Wrap("risk-score", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/", Roles: auth.Writers},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
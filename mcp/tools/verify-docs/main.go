@@ -1,20 +1,55 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tenant"
+	"shared/tracing"
 	"strings"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 func main() {
+	shutdownTracing, err := tracing.Init("verify-docs")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("verify-docs"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("verify-docs", nil))
 	http.HandleFunc("/verify", verifyHandler)
 
 	port := getEnv("PORT", "9101")
 	log.Printf("🔍 verify-docs tool starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("verify-docs", handler)
+	handler = metrics.Wrap("verify-docs", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/", Roles: auth.Writers},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
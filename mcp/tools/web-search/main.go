@@ -1,20 +1,55 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tenant"
+	"shared/tracing"
 	"time"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 func main() {
+	shutdownTracing, err := tracing.Init("web-search")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("web-search"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("web-search", nil))
 	http.HandleFunc("/search", searchHandler)
 
 	port := getEnv("PORT", "9103")
 	log.Printf("🌐 web-search tool starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("web-search", handler)
+	handler = metrics.Wrap("web-search", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/", Roles: auth.Writers},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
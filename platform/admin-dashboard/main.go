@@ -0,0 +1,282 @@
+// admin-dashboard is a small web UI that gives the compliance team a view of
+// documents, collections, ingest progress, conversations and tool health,
+// with re-ingest and delete actions, without calling the APIs directly.
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"shared/auth"
+	"shared/client"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+var (
+	AGENT_SERVICE_URL    = getEnv("AGENT_SERVICE_URL", "http://localhost:9000")
+	INGEST_SERVICE_URL   = getEnv("INGEST_SERVICE_URL", "http://localhost:8080")
+	METADATA_SERVICE_URL = getEnv("METADATA_SERVICE_URL", "http://localhost:8083")
+	VECTOR_SERVICE_URL   = getEnv("VECTOR_SERVICE_URL", "http://localhost:8082")
+	MCP_GATEWAY_URL      = getEnv("MCP_GATEWAY_URL", "http://localhost:9100")
+
+	api       *client.Client
+	templates *template.Template
+)
+
+// ============================================================================
+// MAIN
+// ============================================================================
+
+func main() {
+	shutdownTracing, err := tracing.Init("admin-dashboard")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("admin-dashboard", AGENT_SERVICE_URL, INGEST_SERVICE_URL, METADATA_SERVICE_URL, VECTOR_SERVICE_URL, MCP_GATEWAY_URL)
+
+	api = client.New(client.Config{
+		AgentURL:    AGENT_SERVICE_URL,
+		IngestURL:   INGEST_SERVICE_URL,
+		MetadataURL: METADATA_SERVICE_URL,
+		VectorURL:   VECTOR_SERVICE_URL,
+		GatewayURL:  MCP_GATEWAY_URL,
+		MaxRetries:  -1,
+	})
+
+	templates = template.Must(template.New("").Funcs(template.FuncMap{
+		"ago":      ago,
+		"statusOf": statusClass,
+	}).ParseFS(templateFS, "templates/*.html"))
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
+	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("admin-dashboard"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("admin-dashboard", map[string]server.Check{
+		"metadata-service": server.HTTPCheck(METADATA_SERVICE_URL + "/healthz"),
+	}))
+	http.HandleFunc("/", indexHandler)
+	http.HandleFunc("/partials/documents", documentsPartial)
+	http.HandleFunc("/partials/collections", collectionsPartial)
+	http.HandleFunc("/partials/jobs", jobsPartial)
+	http.HandleFunc("/partials/conversations", conversationsPartial)
+	http.HandleFunc("/partials/conversations/", conversationPartial)
+	http.HandleFunc("/partials/tools", toolsPartial)
+	http.HandleFunc("/actions/documents/", documentActionHandler)
+
+	port := getEnv("PORT", "8090")
+	log.Printf("📊 Admin Dashboard starting on port %s", port)
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("admin-dashboard", handler)
+	handler = metrics.Wrap("admin-dashboard", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/actions/", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ============================================================================
+// PAGES
+// ============================================================================
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"healthy","service":"admin-dashboard"}`))
+}
+
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, "index.html", nil)
+}
+
+func documentsPartial(w http.ResponseWriter, r *http.Request) {
+	docs, err := api.Documents.List(r.Context())
+	render(w, "documents.html", map[string]interface{}{"Documents": docs, "Error": err})
+}
+
+func collectionsPartial(w http.ResponseWriter, r *http.Request) {
+	stats, err := api.Vectors.Collections(r.Context())
+	render(w, "collections.html", map[string]interface{}{"Collections": stats, "Error": err})
+}
+
+// jobsPartial shows documents still being processed alongside the most
+// recent ingest events from the metadata audit log.
+func jobsPartial(w http.ResponseWriter, r *http.Request) {
+	docs, err := api.Documents.List(r.Context())
+	processing := []client.Document{}
+	for _, d := range docs {
+		if d.Status == "processing" || d.Status == "pending" {
+			processing = append(processing, d)
+		}
+	}
+
+	recent, eventsErr := api.Documents.Events(r.Context(), "", 50)
+	ingestEvents := []client.AuditEvent{}
+	for _, e := range recent {
+		if strings.HasPrefix(e.Subject, "ingest.") && len(ingestEvents) < 15 {
+			ingestEvents = append(ingestEvents, e)
+		}
+	}
+	if err == nil {
+		err = eventsErr
+	}
+
+	render(w, "jobs.html", map[string]interface{}{
+		"Processing": processing,
+		"Events":     ingestEvents,
+		"Error":      err,
+	})
+}
+
+func conversationsPartial(w http.ResponseWriter, r *http.Request) {
+	convs, err := api.Agent.Conversations(r.Context(), 20)
+	render(w, "conversations.html", map[string]interface{}{"Conversations": convs, "Error": err})
+}
+
+func conversationPartial(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/partials/conversations/")
+	conv, err := api.Agent.History(r.Context(), id)
+	render(w, "conversation.html", map[string]interface{}{"Conversation": conv, "Error": err})
+}
+
+func toolsPartial(w http.ResponseWriter, r *http.Request) {
+	tools, err := api.Tools.Health(r.Context())
+	render(w, "tools.html", map[string]interface{}{"Tools": tools, "Error": err})
+}
+
+// ============================================================================
+// ACTIONS
+// ============================================================================
+
+// documentActionHandler handles POST /actions/documents/{id}/reingest and
+// DELETE /actions/documents/{id}, then re-renders the documents table.
+func documentActionHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/actions/documents/")
+	id, action, _ := strings.Cut(path, "/")
+	if id == "" {
+		http.Error(w, "Document ID required", http.StatusBadRequest)
+		return
+	}
+
+	var actionErr error
+	var notice string
+	switch {
+	case r.Method == http.MethodPost && action == "reingest":
+		notice, actionErr = reingestDocument(r.Context(), id)
+	case r.Method == http.MethodDelete && action == "":
+		notice, actionErr = deleteDocument(r.Context(), id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	docs, err := api.Documents.List(r.Context())
+	if actionErr != nil {
+		err = actionErr
+		notice = ""
+	}
+	render(w, "documents.html", map[string]interface{}{"Documents": docs, "Error": err, "Notice": notice})
+}
+
+// reingestDocument runs the ingest pipeline again on the document's stored
+// file, replacing its chunks under the same ID.
+func reingestDocument(ctx context.Context, id string) (string, error) {
+	resp, err := api.Ingest.Reingest(ctx, id, client.ReingestRequest{})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Re-ingested %s: %d chunks", resp.DocumentID, resp.Chunks), nil
+}
+
+// deleteDocument removes the document's vectors, uploaded file and
+// metadata through the ingest service.
+func deleteDocument(ctx context.Context, id string) (string, error) {
+	resp, err := api.Ingest.DeleteDocument(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Deleted %s: %d vectors", resp.DocumentID, resp.VectorsDeleted), nil
+}
+
+// ============================================================================
+// HELPERS
+// ============================================================================
+
+func render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render %s: %v", name, err)
+	}
+}
+
+func ago(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := time.Since(t).Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return d.String() + " ago"
+	case d < time.Hour:
+		return (d / time.Minute * time.Minute).String() + " ago"
+	case d < 48*time.Hour:
+		return (d / time.Hour * time.Hour).String() + " ago"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+func statusClass(status string) string {
+	switch status {
+	case "completed", "green", "ready":
+		return "ok"
+	case "failed", "red", "unavailable":
+		return "bad"
+	}
+	return "warn"
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,236 @@
+// auth-service issues short-lived JWTs to registered clients. Each client
+// has a role (admin, analyst, read-only or service) and optionally a tenant;
+// every other service verifies the tokens with the shared JWT_SECRET and
+// enforces role-based access through shared/auth.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tracing"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// ============================================================================
+// DATA MODELS
+// ============================================================================
+
+// Client - A registered API client allowed to request tokens
+type Client struct {
+	ID     string
+	Secret string
+	Role   auth.Role
+	Tenant string
+}
+
+// TokenRequest - Body of POST /token
+type TokenRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenResponse - An issued access token
+type TokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Role        auth.Role `json:"role"`
+	Tenant      string    `json:"tenant,omitempty"`
+}
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+var (
+	// AUTH_CLIENTS is a comma-separated list of id:secret:role[:tenant].
+	clients = parseClients(os.Getenv("AUTH_CLIENTS"))
+
+	TOKEN_TTL = parseDuration(getEnv("TOKEN_TTL", "15m"))
+)
+
+// ============================================================================
+// MAIN
+// ============================================================================
+
+func main() {
+	shutdownTracing, err := tracing.Init("auth-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if !auth.Enabled() {
+		log.Fatal("JWT_SECRET must be set")
+	}
+	if len(clients) == 0 {
+		log.Println("⚠️  AUTH_CLIENTS is empty, no tokens can be issued")
+	}
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
+	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("auth-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("auth-service", nil))
+	http.HandleFunc("/token", tokenHandler)
+	http.HandleFunc("/verify", verifyHandler)
+
+	port := getEnv("PORT", "8096")
+	log.Printf("🔐 Auth Service starting on port %s (%d clients, token TTL %s)", port, len(clients), TOKEN_TTL)
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("auth-service", handler)
+	handler = metrics.Wrap("auth-service", http.DefaultServeMux, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ============================================================================
+// HTTP HANDLERS
+// ============================================================================
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, map[string]interface{}{
+		"status":  "healthy",
+		"service": "auth-service",
+		"clients": len(clients),
+	}, http.StatusOK)
+}
+
+// tokenHandler exchanges client credentials for an access token.
+func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	client, ok := clients[req.ClientID]
+	if !ok || subtle.ConstantTimeCompare([]byte(client.Secret), []byte(req.ClientSecret)) != 1 {
+		log.Printf("Rejected token request for client %q", req.ClientID)
+		respondError(w, "Invalid client credentials", http.StatusUnauthorized)
+		return
+	}
+
+	token, expires, err := auth.Issue(client.ID, client.Role, client.Tenant, TOKEN_TTL)
+	if err != nil {
+		respondError(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Issued %s token for %s", client.Role, client.ID)
+	respondJSON(w, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(TOKEN_TTL.Seconds()),
+		ExpiresAt:   expires,
+		Role:        client.Role,
+		Tenant:      client.Tenant,
+	}, http.StatusOK)
+}
+
+// verifyHandler returns the claims of the bearer token, for debugging and
+// for proxies that want to check tokens without the shared secret.
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		respondError(w, auth.ErrMissingToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	claims, err := auth.Verify(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		respondError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"subject":    claims.Subject,
+		"role":       claims.Role,
+		"tenant":     claims.Tenant,
+		"expires_at": claims.ExpiresAt.Time,
+	}, http.StatusOK)
+}
+
+// ============================================================================
+// HELPER FUNCTIONS
+// ============================================================================
+
+func parseClients(raw string) map[string]Client {
+	result := make(map[string]Client)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			log.Fatalf("Invalid AUTH_CLIENTS entry %q (want id:secret:role[:tenant])", entry)
+		}
+
+		client := Client{ID: parts[0], Secret: parts[1], Role: auth.Role(parts[2])}
+		switch client.Role {
+		case auth.Admin, auth.Analyst, auth.ReadOnly, auth.Service:
+		default:
+			log.Fatalf("Invalid role %q for client %s", parts[2], client.ID)
+		}
+		if len(parts) == 4 {
+			client.Tenant = parts[3]
+		}
+		result[client.ID] = client
+	}
+	return result
+}
+
+func parseDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid TOKEN_TTL %q", s)
+	}
+	return d
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func respondError(w http.ResponseWriter, message string, status int) {
+	respondJSON(w, map[string]string{"error": message}, status)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ============================================================================
+// EMBEDDING CANARY
+// ============================================================================
+// retrieval-service replays a sample of live queries against a candidate
+// embedding model and a shadow collection, then reports how closely the
+// candidate's ranking agrees with production here. The summary answers
+// "is the new model safe to reindex with?" before anything is reindexed.
+
+// CanaryObservation - One live query compared across baseline and candidate models
+type CanaryObservation struct {
+	ID               string  `json:"id"`
+	BaselineModel    string  `json:"baseline_model"`
+	CandidateModel   string  `json:"candidate_model"`
+	Collection       string  `json:"collection"`
+	ShadowCollection string  `json:"shadow_collection"`
+	Query            string  `json:"query"`
+	TopK             int     `json:"top_k"`
+	Overlap          float64 `json:"overlap"`    // shared chunk IDs / top_k
+	RBO              float64 `json:"rbo"`        // rank-biased overlap (p=0.9)
+	Top1Match        bool    `json:"top1_match"` // same best chunk
+	BaselineMs       float64 `json:"baseline_ms"`
+	CandidateMs      float64 `json:"candidate_ms"`
+	Error            string  `json:"error,omitempty"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+// CanarySummary - Aggregate agreement for one baseline/candidate pair
+type CanarySummary struct {
+	BaselineModel      string  `json:"baseline_model"`
+	CandidateModel     string  `json:"candidate_model"`
+	Observations       int     `json:"observations"`
+	Errors             int     `json:"errors"`
+	MeanOverlap        float64 `json:"mean_overlap"`
+	MeanRBO            float64 `json:"mean_rbo"`
+	Top1MatchRate      float64 `json:"top1_match_rate"`
+	MeanLatencyDeltaMs float64 `json:"mean_latency_delta_ms"` // candidate - baseline
+	LastSeen           string  `json:"last_seen"`
+}
+
+func canaryObservationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		observations, err := listCanaryObservations(r.URL.Query().Get("candidate_model"), limit)
+		if err != nil {
+			respondError(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"observations": observations, "count": len(observations)}, http.StatusOK)
+
+	case http.MethodPost:
+		var o CanaryObservation
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if o.CandidateModel == "" || o.Collection == "" {
+			respondError(w, "candidate_model and collection are required", http.StatusBadRequest)
+			return
+		}
+		if err := insertCanaryObservation(&o); err != nil {
+			respondError(w, "Failed to store observation", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, o, http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func canarySummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summaries, err := summarizeCanary()
+	if err != nil {
+		respondError(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"models": summaries, "count": len(summaries)}, http.StatusOK)
+}
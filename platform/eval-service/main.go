@@ -0,0 +1,383 @@
+// eval-service stores golden question/answer/document sets, runs them through
+// the retrieval endpoint and the full agent loop on demand, scores each run
+// with deterministic and LLM-judge metrics, and keeps run history so prompt
+// and chunking changes can be compared for regressions.
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/genai"
+	"shared/auth"
+	"shared/client"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// ============================================================================
+// DATA MODELS
+// ============================================================================
+
+// Dataset - A named set of golden cases
+type Dataset struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Cases       []Case `json:"cases"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Case - One golden question with its expected answer and source documents
+type Case struct {
+	ID                  string   `json:"id"`
+	Question            string   `json:"question"`
+	ExpectedAnswer      string   `json:"expected_answer"`
+	ExpectedDocumentIDs []string `json:"expected_document_ids"`
+	Collection          string   `json:"collection,omitempty"`
+}
+
+// RunRequest - Body of POST /runs
+type RunRequest struct {
+	DatasetID  string `json:"dataset_id"`
+	Label      string `json:"label"`      // e.g. "chunk-800" or "prompt-v2"
+	Mode       string `json:"mode"`       // "retrieval", "agent" or "both"
+	TopK       int    `json:"top_k"`      // retrieval depth (default 5)
+	Collection string `json:"collection"` // default collection for cases without one
+	Judge      bool   `json:"judge"`      // score with the LLM judge
+}
+
+// Run - One execution of a dataset with aggregate metrics
+type Run struct {
+	ID         string             `json:"id"`
+	DatasetID  string             `json:"dataset_id"`
+	Label      string             `json:"label"`
+	Config     RunRequest         `json:"config"`
+	Status     string             `json:"status"` // "running", "completed", "failed"
+	Metrics    map[string]float64 `json:"metrics"`
+	Error      string             `json:"error,omitempty"`
+	StartedAt  string             `json:"started_at"`
+	FinishedAt string             `json:"finished_at,omitempty"`
+	Results    []CaseResult       `json:"results,omitempty"`
+}
+
+// CaseResult - Per-case outputs and scores
+type CaseResult struct {
+	CaseID       string             `json:"case_id"`
+	Question     string             `json:"question"`
+	RetrievedIDs []string           `json:"retrieved_document_ids,omitempty"`
+	Answer       string             `json:"answer,omitempty"`
+	Metrics      map[string]float64 `json:"metrics"`
+	LatencyMs    float64            `json:"latency_ms"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+var (
+	RAG_SERVICE_URL   = getEnv("RAG_SERVICE_URL", "http://localhost:8084")
+	AGENT_SERVICE_URL = getEnv("AGENT_SERVICE_URL", "http://localhost:9000")
+	JUDGE_MODEL       = getEnv("JUDGE_MODEL", "gemini-2.5-flash")
+
+	db           *sql.DB
+	api          *client.Client
+	geminiClient *genai.Client // nil when GEMINI_API_KEY is unset; judge metrics are skipped
+)
+
+// ============================================================================
+// MAIN
+// ============================================================================
+
+func main() {
+	shutdownTracing, err := tracing.Init("eval-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("eval-service", RAG_SERVICE_URL, AGENT_SERVICE_URL)
+
+	db, err = sql.Open("sqlite3", getEnv("DB_PATH", "./data/eval.db"))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := initializeDatabase(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	if apiKey := os.Getenv("GEMINI_API_KEY"); apiKey != "" {
+		geminiClient, err = genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: apiKey})
+		if err != nil {
+			log.Fatalf("Failed to create Gemini client: %v", err)
+		}
+	} else {
+		log.Println("⚠️  GEMINI_API_KEY not set, LLM-judge metrics disabled")
+	}
+
+	api = client.New(client.Config{
+		RetrievalURL: RAG_SERVICE_URL,
+		AgentURL:     AGENT_SERVICE_URL,
+		MaxRetries:   1,
+	})
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
+	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("eval-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("eval-service", map[string]server.Check{
+		"sqlite": func(ctx context.Context) error { return db.PingContext(ctx) },
+	}))
+	http.HandleFunc("/datasets", datasetsHandler)
+	http.HandleFunc("/datasets/", datasetByIDHandler)
+	http.HandleFunc("/runs", runsHandler)
+	http.HandleFunc("/runs/compare", compareHandler)
+	http.HandleFunc("/runs/", runByIDHandler)
+	http.HandleFunc("/canary/observations", canaryObservationsHandler)
+	http.HandleFunc("/canary/summary", canarySummaryHandler)
+
+	port := getEnv("PORT", "8095")
+	log.Printf("🧪 Eval Service starting on port %s", port)
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("eval-service", handler)
+	handler = metrics.Wrap("eval-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Method: http.MethodPost, Path: "/datasets", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/runs", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/canary/observations", Roles: auth.Writers},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ============================================================================
+// HTTP HANDLERS
+// ============================================================================
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, map[string]interface{}{
+		"status":  "healthy",
+		"service": "eval-service",
+		"judge":   geminiClient != nil,
+	}, http.StatusOK)
+}
+
+func datasetsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		datasets, err := listDatasets()
+		if err != nil {
+			respondError(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"datasets": datasets, "count": len(datasets)}, http.StatusOK)
+
+	case http.MethodPost:
+		var ds Dataset
+		if err := json.NewDecoder(r.Body).Decode(&ds); err != nil {
+			respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if ds.Name == "" || len(ds.Cases) == 0 {
+			respondError(w, "Dataset needs a name and at least one case", http.StatusBadRequest)
+			return
+		}
+		for _, c := range ds.Cases {
+			if c.Question == "" {
+				respondError(w, "Every case needs a question", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := insertDataset(&ds); err != nil {
+			respondError(w, "Failed to store dataset", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, ds, http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func datasetByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/datasets/")
+	ds, err := fetchDataset(id)
+	if err == sql.ErrNoRows {
+		respondError(w, "Dataset not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondError(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, ds, http.StatusOK)
+}
+
+func runsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		runs, err := listRuns(r.URL.Query().Get("dataset_id"))
+		if err != nil {
+			respondError(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"runs": runs, "count": len(runs)}, http.StatusOK)
+
+	case http.MethodPost:
+		var req RunRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Mode == "" {
+			req.Mode = "both"
+		}
+		if req.Mode != "retrieval" && req.Mode != "agent" && req.Mode != "both" {
+			respondError(w, "mode must be retrieval, agent or both", http.StatusBadRequest)
+			return
+		}
+		if req.TopK == 0 {
+			req.TopK = 5
+		}
+		if req.Judge && geminiClient == nil {
+			respondError(w, "LLM judge requested but GEMINI_API_KEY is not set", http.StatusBadRequest)
+			return
+		}
+
+		ds, err := fetchDataset(req.DatasetID)
+		if err == sql.ErrNoRows {
+			respondError(w, "Dataset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respondError(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+
+		run, err := startRun(r.Context(), ds, req)
+		if err != nil {
+			respondError(w, "Failed to start run", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, run, http.StatusAccepted)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func runByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/runs/")
+	run, err := fetchRun(id, true)
+	if err == sql.ErrNoRows {
+		respondError(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondError(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, run, http.StatusOK)
+}
+
+// compareHandler reports per-metric deltas between two runs (head - base).
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base, err := fetchRun(r.URL.Query().Get("base"), false)
+	if err != nil {
+		respondError(w, "Base run not found", http.StatusNotFound)
+		return
+	}
+	head, err := fetchRun(r.URL.Query().Get("head"), false)
+	if err != nil {
+		respondError(w, "Head run not found", http.StatusNotFound)
+		return
+	}
+
+	deltas := make(map[string]float64)
+	regressions := []string{}
+	for name, headValue := range head.Metrics {
+		baseValue, ok := base.Metrics[name]
+		if !ok {
+			continue
+		}
+		deltas[name] = headValue - baseValue
+		if lowerIsBetter(name) {
+			if headValue > baseValue*1.1 {
+				regressions = append(regressions, name)
+			}
+		} else if headValue < baseValue-0.02 {
+			regressions = append(regressions, name)
+		}
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"base":        base,
+		"head":        head,
+		"deltas":      deltas,
+		"regressions": regressions,
+	}, http.StatusOK)
+}
+
+// ============================================================================
+// HELPER FUNCTIONS
+// ============================================================================
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func respondError(w http.ResponseWriter, message string, status int) {
+	respondJSON(w, map[string]string{"error": message}, status)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"shared/openapi"
+)
+
+// TestSpecMatchesTypes holds openapi.json to the types the handlers decode
+// and encode, so neither changes without the other.
+func TestSpecMatchesTypes(t *testing.T) {
+	spec := openapi.MustLoad(openAPISpec)
+	for name, v := range map[string]interface{}{
+		"Case":              Case{},
+		"Dataset":           Dataset{},
+		"RunRequest":        RunRequest{},
+		"CaseResult":        CaseResult{},
+		"Run":               Run{},
+		"CanaryObservation": CanaryObservation{},
+		"CanarySummary":     CanarySummary{},
+	} {
+		if err := spec.CheckType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+}
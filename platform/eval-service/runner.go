@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"shared/client"
+	"shared/tenant"
+)
+
+// startRun records a new run and executes it in the background on behalf of
+// the tenant on ctx.
+func startRun(ctx context.Context, ds Dataset, req RunRequest) (*Run, error) {
+	run := &Run{
+		ID:        uuid.New().String(),
+		DatasetID: ds.ID,
+		Label:     req.Label,
+		Config:    req,
+		Status:    "running",
+		Metrics:   map[string]float64{},
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := insertRun(run); err != nil {
+		return nil, err
+	}
+
+	go executeRun(tenant.WithTenant(context.Background(), tenant.FromContext(ctx)), ds, *run)
+	return run, nil
+}
+
+func executeRun(ctx context.Context, ds Dataset, run Run) {
+	log.Printf("🧪 Run %s started (%s, %d cases, mode=%s)", run.ID, ds.Name, len(ds.Cases), run.Config.Mode)
+
+	results := make([]CaseResult, 0, len(ds.Cases))
+	for _, c := range ds.Cases {
+		result := evaluateCase(ctx, c, run.Config)
+		if err := insertCaseResult(run.ID, result); err != nil {
+			log.Printf("Failed to store result for case %s: %v", c.ID, err)
+		}
+		results = append(results, result)
+	}
+
+	run.Metrics = aggregate(results)
+	run.Status = "completed"
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := finishRun(&run); err != nil {
+		log.Printf("Failed to finish run %s: %v", run.ID, err)
+	}
+	log.Printf("✅ Run %s completed: %v", run.ID, run.Metrics)
+}
+
+// evaluateCase runs one golden case through retrieval and/or the agent and
+// scores it. Failures are recorded on the result rather than aborting the run.
+func evaluateCase(ctx context.Context, c Case, cfg RunRequest) CaseResult {
+	result := CaseResult{CaseID: c.ID, Question: c.Question, Metrics: map[string]float64{}}
+	start := time.Now()
+
+	collection := c.Collection
+	if collection == "" {
+		collection = cfg.Collection
+	}
+
+	var contexts []string
+	if cfg.Mode == "retrieval" || cfg.Mode == "both" || cfg.Judge {
+		t := time.Now()
+		resp, err := api.Retrieval.Retrieve(ctx, client.RetrievalRequest{
+			Query:      c.Question,
+			TopK:       cfg.TopK,
+			Collection: collection,
+		})
+		if err != nil {
+			result.Error = fmt.Sprintf("retrieval: %v", err)
+		} else {
+			result.Metrics["retrieval_latency_ms"] = float64(time.Since(t).Milliseconds())
+			for _, r := range resp.Results {
+				contexts = append(contexts, r.Text)
+				result.RetrievedIDs = appendUnique(result.RetrievedIDs, r.DocumentID)
+			}
+			if len(c.ExpectedDocumentIDs) > 0 && cfg.Mode != "agent" {
+				result.Metrics["recall_at_k"] = recall(c.ExpectedDocumentIDs, result.RetrievedIDs)
+				result.Metrics["mrr"] = reciprocalRank(c.ExpectedDocumentIDs, result.RetrievedIDs)
+				result.Metrics["hit_rate"] = boolScore(result.Metrics["recall_at_k"] > 0)
+			}
+		}
+	}
+
+	if cfg.Mode == "agent" || cfg.Mode == "both" {
+		t := time.Now()
+		resp, err := api.Agent.Query(ctx, client.AgentRequest{Query: c.Question})
+		if err != nil {
+			result.Error = strings.TrimPrefix(result.Error+"; ", "; ") + fmt.Sprintf("agent: %v", err)
+		} else {
+			result.Answer = resp.Answer
+			result.Metrics["agent_latency_ms"] = float64(time.Since(t).Milliseconds())
+			result.Metrics["agent_confidence"] = resp.Confidence
+			if c.ExpectedAnswer != "" {
+				result.Metrics["answer_f1"] = tokenF1(c.ExpectedAnswer, resp.Answer)
+			}
+		}
+	}
+
+	if cfg.Judge && result.Answer != "" {
+		scores, err := judgeAnswer(ctx, c, result.Answer, contexts)
+		if err != nil {
+			log.Printf("Judge failed for case %s: %v", c.ID, err)
+		} else {
+			for name, v := range scores {
+				result.Metrics[name] = v
+			}
+		}
+	}
+
+	result.Metrics["error"] = boolScore(result.Error != "")
+	result.LatencyMs = float64(time.Since(start).Milliseconds())
+	return result
+}
+
+// aggregate averages each metric over the cases that reported it.
+func aggregate(results []CaseResult) map[string]float64 {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, r := range results {
+		for name, v := range r.Metrics {
+			sums[name] += v
+			counts[name]++
+		}
+	}
+
+	out := make(map[string]float64, len(sums))
+	for name, sum := range sums {
+		out[name] = sum / float64(counts[name])
+	}
+	if v, ok := out["error"]; ok {
+		out["error_rate"] = v
+		delete(out, "error")
+	}
+	return out
+}
+
+func appendUnique(list []string, v string) []string {
+	if v == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genai"
+)
+
+// ============================================================================
+// DETERMINISTIC METRICS
+// ============================================================================
+
+// recall is the fraction of expected documents present in retrieved.
+func recall(expected, retrieved []string) float64 {
+	if len(expected) == 0 {
+		return 0
+	}
+	found := 0
+	for _, id := range expected {
+		for _, r := range retrieved {
+			if r == id {
+				found++
+				break
+			}
+		}
+	}
+	return float64(found) / float64(len(expected))
+}
+
+// reciprocalRank is 1/rank of the first expected document in retrieved.
+func reciprocalRank(expected, retrieved []string) float64 {
+	for i, r := range retrieved {
+		for _, id := range expected {
+			if r == id {
+				return 1 / float64(i+1)
+			}
+		}
+	}
+	return 0
+}
+
+// tokenF1 is the SQuAD-style token overlap F1 between two answers.
+func tokenF1(expected, actual string) float64 {
+	exp := tokenize(expected)
+	act := tokenize(actual)
+	if len(exp) == 0 || len(act) == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int)
+	for _, t := range exp {
+		counts[t]++
+	}
+	common := 0
+	for _, t := range act {
+		if counts[t] > 0 {
+			common++
+			counts[t]--
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+
+	precision := float64(common) / float64(len(act))
+	rec := float64(common) / float64(len(exp))
+	return 2 * precision * rec / (precision + rec)
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func boolScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// lowerIsBetter reports whether a drop in the metric is an improvement.
+func lowerIsBetter(name string) bool {
+	return strings.HasSuffix(name, "_ms") || name == "error_rate"
+}
+
+// ============================================================================
+// LLM JUDGE
+// ============================================================================
+
+// judgeAnswer asks Gemini to score faithfulness (is the answer supported by
+// the retrieved contexts), answer relevance (does it address the question)
+// and, when a golden answer exists, correctness against it. Scores are 0-1.
+func judgeAnswer(ctx context.Context, c Case, answer string, contexts []string) (map[string]float64, error) {
+	contextText := "(no retrieved context)"
+	if len(contexts) > 0 {
+		var b strings.Builder
+		for i, text := range contexts {
+			fmt.Fprintf(&b, "[%d] %s\n\n", i+1, text)
+		}
+		contextText = b.String()
+	}
+
+	expected := c.ExpectedAnswer
+	if expected == "" {
+		expected = "(none provided)"
+	}
+
+	prompt := fmt.Sprintf(`You are grading a retrieval-augmented answer. Score each criterion from 0.0 to 1.0.
+
+Question: %s
+
+Retrieved context:
+%s
+Reference answer: %s
+
+Answer to grade: %s
+
+Criteria:
+- faithfulness: every claim in the answer is supported by the retrieved context
+- answer_relevance: the answer directly addresses the question
+- answer_correctness: the answer agrees with the reference answer (use -1 if no reference)
+
+Respond ONLY in JSON: {"faithfulness": 0.0, "answer_relevance": 0.0, "answer_correctness": 0.0}`,
+		c.Question, contextText, expected, answer)
+
+	resp, err := geminiClient.Models.GenerateContent(ctx, JUDGE_MODEL, genai.Text(prompt), &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+	text, err := resp.Text()
+	if err != nil {
+		return nil, err
+	}
+
+	var scores map[string]float64
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &scores); err != nil {
+		return nil, fmt.Errorf("invalid judge response: %w", err)
+	}
+	if v, ok := scores["answer_correctness"]; ok && v < 0 {
+		delete(scores, "answer_correctness")
+	}
+	return scores, nil
+}
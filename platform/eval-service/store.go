@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func initializeDatabase() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS datasets (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS cases (
+		id TEXT PRIMARY KEY,
+		dataset_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		question TEXT NOT NULL,
+		expected_answer TEXT,
+		expected_document_ids TEXT NOT NULL,
+		collection TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_cases_dataset ON cases(dataset_id);
+	CREATE TABLE IF NOT EXISTS runs (
+		id TEXT PRIMARY KEY,
+		dataset_id TEXT NOT NULL,
+		label TEXT,
+		config TEXT NOT NULL,
+		status TEXT NOT NULL,
+		metrics TEXT,
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_runs_dataset ON runs(dataset_id);
+	CREATE TABLE IF NOT EXISTS run_results (
+		run_id TEXT NOT NULL,
+		case_id TEXT NOT NULL,
+		result TEXT NOT NULL,
+		PRIMARY KEY (run_id, case_id)
+	);
+	CREATE TABLE IF NOT EXISTS canary_observations (
+		id TEXT PRIMARY KEY,
+		baseline_model TEXT NOT NULL,
+		candidate_model TEXT NOT NULL,
+		collection TEXT NOT NULL,
+		shadow_collection TEXT NOT NULL,
+		query TEXT NOT NULL,
+		top_k INTEGER NOT NULL,
+		overlap REAL NOT NULL,
+		rbo REAL NOT NULL,
+		top1_match INTEGER NOT NULL,
+		baseline_ms REAL NOT NULL,
+		candidate_ms REAL NOT NULL,
+		error TEXT,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_canary_model ON canary_observations(candidate_model, created_at);`
+	_, err := db.Exec(schema)
+	return err
+}
+
+// ============================================================================
+// DATASETS
+// ============================================================================
+
+func insertDataset(ds *Dataset) error {
+	ds.ID = uuid.New().String()
+	ds.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO datasets (id, name, description, created_at) VALUES (?, ?, ?, ?)`,
+		ds.ID, ds.Name, ds.Description, ds.CreatedAt); err != nil {
+		return err
+	}
+	for i := range ds.Cases {
+		c := &ds.Cases[i]
+		c.ID = uuid.New().String()
+		if c.ExpectedDocumentIDs == nil {
+			c.ExpectedDocumentIDs = []string{}
+		}
+		ids, _ := json.Marshal(c.ExpectedDocumentIDs)
+		if _, err := tx.Exec(`INSERT INTO cases (id, dataset_id, position, question, expected_answer, expected_document_ids, collection) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			c.ID, ds.ID, i, c.Question, c.ExpectedAnswer, string(ids), c.Collection); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func listDatasets() ([]Dataset, error) {
+	rows, err := db.Query(`SELECT id, name, COALESCE(description, ''), created_at FROM datasets ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := []Dataset{}
+	for rows.Next() {
+		var ds Dataset
+		rows.Scan(&ds.ID, &ds.Name, &ds.Description, &ds.CreatedAt)
+		datasets = append(datasets, ds)
+	}
+	return datasets, rows.Err()
+}
+
+func fetchDataset(id string) (Dataset, error) {
+	var ds Dataset
+	err := db.QueryRow(`SELECT id, name, COALESCE(description, ''), created_at FROM datasets WHERE id = ?`, id).
+		Scan(&ds.ID, &ds.Name, &ds.Description, &ds.CreatedAt)
+	if err != nil {
+		return ds, err
+	}
+
+	rows, err := db.Query(`SELECT id, question, COALESCE(expected_answer, ''), expected_document_ids, COALESCE(collection, '')
+		FROM cases WHERE dataset_id = ? ORDER BY position`, id)
+	if err != nil {
+		return ds, err
+	}
+	defer rows.Close()
+
+	ds.Cases = []Case{}
+	for rows.Next() {
+		var c Case
+		var ids string
+		rows.Scan(&c.ID, &c.Question, &c.ExpectedAnswer, &ids, &c.Collection)
+		json.Unmarshal([]byte(ids), &c.ExpectedDocumentIDs)
+		ds.Cases = append(ds.Cases, c)
+	}
+	return ds, rows.Err()
+}
+
+// ============================================================================
+// RUNS
+// ============================================================================
+
+func insertRun(run *Run) error {
+	config, _ := json.Marshal(run.Config)
+	_, err := db.Exec(`INSERT INTO runs (id, dataset_id, label, config, status, started_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.DatasetID, run.Label, string(config), run.Status, run.StartedAt)
+	return err
+}
+
+func insertCaseResult(runID string, result CaseResult) error {
+	data, _ := json.Marshal(result)
+	_, err := db.Exec(`INSERT OR REPLACE INTO run_results (run_id, case_id, result) VALUES (?, ?, ?)`,
+		runID, result.CaseID, string(data))
+	return err
+}
+
+func finishRun(run *Run) error {
+	m, _ := json.Marshal(run.Metrics)
+	_, err := db.Exec(`UPDATE runs SET status = ?, metrics = ?, error = ?, finished_at = ? WHERE id = ?`,
+		run.Status, string(m), run.Error, run.FinishedAt, run.ID)
+	return err
+}
+
+const runColumns = `id, dataset_id, COALESCE(label, ''), config, status, COALESCE(metrics, '{}'), COALESCE(error, ''), started_at, COALESCE(finished_at, '')`
+
+func scanRun(row interface{ Scan(...interface{}) error }) (Run, error) {
+	var run Run
+	var config, m string
+	if err := row.Scan(&run.ID, &run.DatasetID, &run.Label, &config, &run.Status, &m, &run.Error, &run.StartedAt, &run.FinishedAt); err != nil {
+		return run, err
+	}
+	json.Unmarshal([]byte(config), &run.Config)
+	json.Unmarshal([]byte(m), &run.Metrics)
+	return run, nil
+}
+
+func listRuns(datasetID string) ([]Run, error) {
+	query := `SELECT ` + runColumns + ` FROM runs`
+	args := []interface{}{}
+	if datasetID != "" {
+		query += ` WHERE dataset_id = ?`
+		args = append(args, datasetID)
+	}
+	query += ` ORDER BY started_at DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func fetchRun(id string, withResults bool) (Run, error) {
+	run, err := scanRun(db.QueryRow(`SELECT `+runColumns+` FROM runs WHERE id = ?`, id))
+	if err != nil || !withResults {
+		return run, err
+	}
+
+	rows, err := db.Query(`SELECT r.result FROM run_results r JOIN cases c ON c.id = r.case_id
+		WHERE r.run_id = ? ORDER BY c.position`, id)
+	if err != nil {
+		return run, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		var result CaseResult
+		rows.Scan(&data)
+		if json.Unmarshal([]byte(data), &result) == nil {
+			run.Results = append(run.Results, result)
+		}
+	}
+	return run, rows.Err()
+}
+
+// ============================================================================
+// CANARY
+// ============================================================================
+
+func insertCanaryObservation(o *CanaryObservation) error {
+	o.ID = uuid.New().String()
+	o.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	_, err := db.Exec(`INSERT INTO canary_observations (id, baseline_model, candidate_model, collection, shadow_collection,
+		query, top_k, overlap, rbo, top1_match, baseline_ms, candidate_ms, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.ID, o.BaselineModel, o.CandidateModel, o.Collection, o.ShadowCollection,
+		o.Query, o.TopK, o.Overlap, o.RBO, o.Top1Match, o.BaselineMs, o.CandidateMs, o.Error, o.CreatedAt)
+	return err
+}
+
+func listCanaryObservations(candidateModel string, limit int) ([]CanaryObservation, error) {
+	query := `SELECT id, baseline_model, candidate_model, collection, shadow_collection, query, top_k,
+		overlap, rbo, top1_match, baseline_ms, candidate_ms, COALESCE(error, ''), created_at FROM canary_observations`
+	args := []interface{}{}
+	if candidateModel != "" {
+		query += ` WHERE candidate_model = ?`
+		args = append(args, candidateModel)
+	}
+	query += ` ORDER BY created_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := []CanaryObservation{}
+	for rows.Next() {
+		var o CanaryObservation
+		if err := rows.Scan(&o.ID, &o.BaselineModel, &o.CandidateModel, &o.Collection, &o.ShadowCollection, &o.Query, &o.TopK,
+			&o.Overlap, &o.RBO, &o.Top1Match, &o.BaselineMs, &o.CandidateMs, &o.Error, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+	return observations, rows.Err()
+}
+
+// summarizeCanary aggregates observations per baseline/candidate pair.
+// Failed comparisons count toward errors but not the averages.
+func summarizeCanary() ([]CanarySummary, error) {
+	rows, err := db.Query(`SELECT baseline_model, candidate_model, COUNT(*),
+		SUM(CASE WHEN COALESCE(error, '') != '' THEN 1 ELSE 0 END),
+		COALESCE(AVG(CASE WHEN COALESCE(error, '') = '' THEN overlap END), 0),
+		COALESCE(AVG(CASE WHEN COALESCE(error, '') = '' THEN rbo END), 0),
+		COALESCE(AVG(CASE WHEN COALESCE(error, '') = '' THEN top1_match END), 0),
+		COALESCE(AVG(CASE WHEN COALESCE(error, '') = '' THEN candidate_ms - baseline_ms END), 0),
+		MAX(created_at)
+		FROM canary_observations GROUP BY baseline_model, candidate_model ORDER BY MAX(created_at) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []CanarySummary{}
+	for rows.Next() {
+		var s CanarySummary
+		if err := rows.Scan(&s.BaselineModel, &s.CandidateModel, &s.Observations, &s.Errors,
+			&s.MeanOverlap, &s.MeanRBO, &s.Top1MatchRate, &s.MeanLatencyDeltaMs, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,5 @@
+// Package protos holds the protobuf contracts for internal service-to-service
+// calls. Generated Go code lives in protos/gorillapb.
+package protos
+
+//go:generate protoc -I . --go_out=. --go_opt=module=protos --go-grpc_out=. --go-grpc_opt=module=protos gorilla/v1/common.proto gorilla/v1/embedding.proto gorilla/v1/vector.proto gorilla/v1/metadata.proto gorilla/v1/retrieval.proto gorilla/v1/tools.proto
@@ -0,0 +1,305 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: gorilla/v1/embedding.proto
+
+package gorillapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EmbedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedRequest) ProtoMessage() {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_embedding_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EmbedRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type EmbedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Embedding     *Vector                `protobuf:"bytes,1,opt,name=embedding,proto3" json:"embedding,omitempty"`
+	Dimension     int32                  `protobuf:"varint,2,opt,name=dimension,proto3" json:"dimension,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedResponse) ProtoMessage() {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_embedding_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EmbedResponse) GetEmbedding() *Vector {
+	if x != nil {
+		return x.Embedding
+	}
+	return nil
+}
+
+func (x *EmbedResponse) GetDimension() int32 {
+	if x != nil {
+		return x.Dimension
+	}
+	return 0
+}
+
+type EmbedBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedBatchRequest) Reset() {
+	*x = EmbedBatchRequest{}
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedBatchRequest) ProtoMessage() {}
+
+func (x *EmbedBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedBatchRequest.ProtoReflect.Descriptor instead.
+func (*EmbedBatchRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_embedding_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EmbedBatchRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+type EmbedBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Embeddings    []*Vector              `protobuf:"bytes,1,rep,name=embeddings,proto3" json:"embeddings,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Dimension     int32                  `protobuf:"varint,3,opt,name=dimension,proto3" json:"dimension,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedBatchResponse) Reset() {
+	*x = EmbedBatchResponse{}
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedBatchResponse) ProtoMessage() {}
+
+func (x *EmbedBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_embedding_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedBatchResponse.ProtoReflect.Descriptor instead.
+func (*EmbedBatchResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_embedding_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EmbedBatchResponse) GetEmbeddings() []*Vector {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+func (x *EmbedBatchResponse) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *EmbedBatchResponse) GetDimension() int32 {
+	if x != nil {
+		return x.Dimension
+	}
+	return 0
+}
+
+var File_gorilla_v1_embedding_proto protoreflect.FileDescriptor
+
+const file_gorilla_v1_embedding_proto_rawDesc = "" +
+	"\n" +
+	"\x1agorilla/v1/embedding.proto\x12\n" +
+	"gorilla.v1\x1a\x17gorilla/v1/common.proto\"\"\n" +
+	"\fEmbedRequest\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"_\n" +
+	"\rEmbedResponse\x120\n" +
+	"\tembedding\x18\x01 \x01(\v2\x12.gorilla.v1.VectorR\tembedding\x12\x1c\n" +
+	"\tdimension\x18\x02 \x01(\x05R\tdimension\")\n" +
+	"\x11EmbedBatchRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\"|\n" +
+	"\x12EmbedBatchResponse\x122\n" +
+	"\n" +
+	"embeddings\x18\x01 \x03(\v2\x12.gorilla.v1.VectorR\n" +
+	"embeddings\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x1c\n" +
+	"\tdimension\x18\x03 \x01(\x05R\tdimension2\x9d\x01\n" +
+	"\x10EmbeddingService\x12<\n" +
+	"\x05Embed\x12\x18.gorilla.v1.EmbedRequest\x1a\x19.gorilla.v1.EmbedResponse\x12K\n" +
+	"\n" +
+	"EmbedBatch\x12\x1d.gorilla.v1.EmbedBatchRequest\x1a\x1e.gorilla.v1.EmbedBatchResponseB\x12Z\x10protos/gorillapbb\x06proto3"
+
+var (
+	file_gorilla_v1_embedding_proto_rawDescOnce sync.Once
+	file_gorilla_v1_embedding_proto_rawDescData []byte
+)
+
+func file_gorilla_v1_embedding_proto_rawDescGZIP() []byte {
+	file_gorilla_v1_embedding_proto_rawDescOnce.Do(func() {
+		file_gorilla_v1_embedding_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gorilla_v1_embedding_proto_rawDesc), len(file_gorilla_v1_embedding_proto_rawDesc)))
+	})
+	return file_gorilla_v1_embedding_proto_rawDescData
+}
+
+var file_gorilla_v1_embedding_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_gorilla_v1_embedding_proto_goTypes = []any{
+	(*EmbedRequest)(nil),       // 0: gorilla.v1.EmbedRequest
+	(*EmbedResponse)(nil),      // 1: gorilla.v1.EmbedResponse
+	(*EmbedBatchRequest)(nil),  // 2: gorilla.v1.EmbedBatchRequest
+	(*EmbedBatchResponse)(nil), // 3: gorilla.v1.EmbedBatchResponse
+	(*Vector)(nil),             // 4: gorilla.v1.Vector
+}
+var file_gorilla_v1_embedding_proto_depIdxs = []int32{
+	4, // 0: gorilla.v1.EmbedResponse.embedding:type_name -> gorilla.v1.Vector
+	4, // 1: gorilla.v1.EmbedBatchResponse.embeddings:type_name -> gorilla.v1.Vector
+	0, // 2: gorilla.v1.EmbeddingService.Embed:input_type -> gorilla.v1.EmbedRequest
+	2, // 3: gorilla.v1.EmbeddingService.EmbedBatch:input_type -> gorilla.v1.EmbedBatchRequest
+	1, // 4: gorilla.v1.EmbeddingService.Embed:output_type -> gorilla.v1.EmbedResponse
+	3, // 5: gorilla.v1.EmbeddingService.EmbedBatch:output_type -> gorilla.v1.EmbedBatchResponse
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_gorilla_v1_embedding_proto_init() }
+func file_gorilla_v1_embedding_proto_init() {
+	if File_gorilla_v1_embedding_proto != nil {
+		return
+	}
+	file_gorilla_v1_common_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gorilla_v1_embedding_proto_rawDesc), len(file_gorilla_v1_embedding_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gorilla_v1_embedding_proto_goTypes,
+		DependencyIndexes: file_gorilla_v1_embedding_proto_depIdxs,
+		MessageInfos:      file_gorilla_v1_embedding_proto_msgTypes,
+	}.Build()
+	File_gorilla_v1_embedding_proto = out.File
+	file_gorilla_v1_embedding_proto_goTypes = nil
+	file_gorilla_v1_embedding_proto_depIdxs = nil
+}
@@ -0,0 +1,384 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: gorilla/v1/metadata.proto
+
+package gorillapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      *Document              `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDocumentRequest) Reset() {
+	*x = CreateDocumentRequest{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDocumentRequest) ProtoMessage() {}
+
+func (x *CreateDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDocumentRequest.ProtoReflect.Descriptor instead.
+func (*CreateDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateDocumentRequest) GetDocument() *Document {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type GetDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDocumentRequest) Reset() {
+	*x = GetDocumentRequest{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDocumentRequest) ProtoMessage() {}
+
+func (x *GetDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDocumentRequest.ProtoReflect.Descriptor instead.
+func (*GetDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetDocumentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListDocumentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDocumentsRequest) Reset() {
+	*x = ListDocumentsRequest{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDocumentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDocumentsRequest) ProtoMessage() {}
+
+func (x *ListDocumentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDocumentsRequest.ProtoReflect.Descriptor instead.
+func (*ListDocumentsRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{2}
+}
+
+type ListDocumentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Documents     []*Document            `protobuf:"bytes,1,rep,name=documents,proto3" json:"documents,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDocumentsResponse) Reset() {
+	*x = ListDocumentsResponse{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDocumentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDocumentsResponse) ProtoMessage() {}
+
+func (x *ListDocumentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDocumentsResponse.ProtoReflect.Descriptor instead.
+func (*ListDocumentsResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListDocumentsResponse) GetDocuments() []*Document {
+	if x != nil {
+		return x.Documents
+	}
+	return nil
+}
+
+func (x *ListDocumentsResponse) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type UpdateDocumentStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDocumentStatusRequest) Reset() {
+	*x = UpdateDocumentStatusRequest{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDocumentStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDocumentStatusRequest) ProtoMessage() {}
+
+func (x *UpdateDocumentStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDocumentStatusRequest.ProtoReflect.Descriptor instead.
+func (*UpdateDocumentStatusRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateDocumentStatusRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateDocumentStatusRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type UpdateDocumentStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDocumentStatusResponse) Reset() {
+	*x = UpdateDocumentStatusResponse{}
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDocumentStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDocumentStatusResponse) ProtoMessage() {}
+
+func (x *UpdateDocumentStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_metadata_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDocumentStatusResponse.ProtoReflect.Descriptor instead.
+func (*UpdateDocumentStatusResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_metadata_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateDocumentStatusResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+var File_gorilla_v1_metadata_proto protoreflect.FileDescriptor
+
+const file_gorilla_v1_metadata_proto_rawDesc = "" +
+	"\n" +
+	"\x19gorilla/v1/metadata.proto\x12\n" +
+	"gorilla.v1\x1a\x17gorilla/v1/common.proto\"I\n" +
+	"\x15CreateDocumentRequest\x120\n" +
+	"\bdocument\x18\x01 \x01(\v2\x14.gorilla.v1.DocumentR\bdocument\"$\n" +
+	"\x12GetDocumentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x16\n" +
+	"\x14ListDocumentsRequest\"a\n" +
+	"\x15ListDocumentsResponse\x122\n" +
+	"\tdocuments\x18\x01 \x03(\v2\x14.gorilla.v1.DocumentR\tdocuments\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\"E\n" +
+	"\x1bUpdateDocumentStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"6\n" +
+	"\x1cUpdateDocumentStatusResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status2\xe2\x02\n" +
+	"\x0fMetadataService\x12I\n" +
+	"\x0eCreateDocument\x12!.gorilla.v1.CreateDocumentRequest\x1a\x14.gorilla.v1.Document\x12C\n" +
+	"\vGetDocument\x12\x1e.gorilla.v1.GetDocumentRequest\x1a\x14.gorilla.v1.Document\x12T\n" +
+	"\rListDocuments\x12 .gorilla.v1.ListDocumentsRequest\x1a!.gorilla.v1.ListDocumentsResponse\x12i\n" +
+	"\x14UpdateDocumentStatus\x12'.gorilla.v1.UpdateDocumentStatusRequest\x1a(.gorilla.v1.UpdateDocumentStatusResponseB\x12Z\x10protos/gorillapbb\x06proto3"
+
+var (
+	file_gorilla_v1_metadata_proto_rawDescOnce sync.Once
+	file_gorilla_v1_metadata_proto_rawDescData []byte
+)
+
+func file_gorilla_v1_metadata_proto_rawDescGZIP() []byte {
+	file_gorilla_v1_metadata_proto_rawDescOnce.Do(func() {
+		file_gorilla_v1_metadata_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gorilla_v1_metadata_proto_rawDesc), len(file_gorilla_v1_metadata_proto_rawDesc)))
+	})
+	return file_gorilla_v1_metadata_proto_rawDescData
+}
+
+var file_gorilla_v1_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_gorilla_v1_metadata_proto_goTypes = []any{
+	(*CreateDocumentRequest)(nil),        // 0: gorilla.v1.CreateDocumentRequest
+	(*GetDocumentRequest)(nil),           // 1: gorilla.v1.GetDocumentRequest
+	(*ListDocumentsRequest)(nil),         // 2: gorilla.v1.ListDocumentsRequest
+	(*ListDocumentsResponse)(nil),        // 3: gorilla.v1.ListDocumentsResponse
+	(*UpdateDocumentStatusRequest)(nil),  // 4: gorilla.v1.UpdateDocumentStatusRequest
+	(*UpdateDocumentStatusResponse)(nil), // 5: gorilla.v1.UpdateDocumentStatusResponse
+	(*Document)(nil),                     // 6: gorilla.v1.Document
+}
+var file_gorilla_v1_metadata_proto_depIdxs = []int32{
+	6, // 0: gorilla.v1.CreateDocumentRequest.document:type_name -> gorilla.v1.Document
+	6, // 1: gorilla.v1.ListDocumentsResponse.documents:type_name -> gorilla.v1.Document
+	0, // 2: gorilla.v1.MetadataService.CreateDocument:input_type -> gorilla.v1.CreateDocumentRequest
+	1, // 3: gorilla.v1.MetadataService.GetDocument:input_type -> gorilla.v1.GetDocumentRequest
+	2, // 4: gorilla.v1.MetadataService.ListDocuments:input_type -> gorilla.v1.ListDocumentsRequest
+	4, // 5: gorilla.v1.MetadataService.UpdateDocumentStatus:input_type -> gorilla.v1.UpdateDocumentStatusRequest
+	6, // 6: gorilla.v1.MetadataService.CreateDocument:output_type -> gorilla.v1.Document
+	6, // 7: gorilla.v1.MetadataService.GetDocument:output_type -> gorilla.v1.Document
+	3, // 8: gorilla.v1.MetadataService.ListDocuments:output_type -> gorilla.v1.ListDocumentsResponse
+	5, // 9: gorilla.v1.MetadataService.UpdateDocumentStatus:output_type -> gorilla.v1.UpdateDocumentStatusResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_gorilla_v1_metadata_proto_init() }
+func file_gorilla_v1_metadata_proto_init() {
+	if File_gorilla_v1_metadata_proto != nil {
+		return
+	}
+	file_gorilla_v1_common_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gorilla_v1_metadata_proto_rawDesc), len(file_gorilla_v1_metadata_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gorilla_v1_metadata_proto_goTypes,
+		DependencyIndexes: file_gorilla_v1_metadata_proto_depIdxs,
+		MessageInfos:      file_gorilla_v1_metadata_proto_msgTypes,
+	}.Build()
+	File_gorilla_v1_metadata_proto = out.File
+	file_gorilla_v1_metadata_proto_goTypes = nil
+	file_gorilla_v1_metadata_proto_depIdxs = nil
+}
@@ -0,0 +1,332 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: gorilla/v1/retrieval.proto
+
+package gorillapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RetrieveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	TopK          int32                  `protobuf:"varint,2,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	Collection    string                 `protobuf:"bytes,3,opt,name=collection,proto3" json:"collection,omitempty"`
+	Filters       map[string]string      `protobuf:"bytes,4,rep,name=filters,proto3" json:"filters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveRequest) Reset() {
+	*x = RetrieveRequest{}
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveRequest) ProtoMessage() {}
+
+func (x *RetrieveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveRequest.ProtoReflect.Descriptor instead.
+func (*RetrieveRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_retrieval_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RetrieveRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *RetrieveRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *RetrieveRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *RetrieveRequest) GetFilters() map[string]string {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+type RetrievalResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Score         float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	DocumentId    string                 `protobuf:"bytes,4,opt,name=document_id,json=documentId,proto3" json:"document_id,omitempty"`
+	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	Metadata      *structpb.Struct       `protobuf:"bytes,6,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrievalResult) Reset() {
+	*x = RetrievalResult{}
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrievalResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrievalResult) ProtoMessage() {}
+
+func (x *RetrievalResult) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrievalResult.ProtoReflect.Descriptor instead.
+func (*RetrievalResult) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_retrieval_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RetrievalResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RetrievalResult) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *RetrievalResult) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *RetrievalResult) GetDocumentId() string {
+	if x != nil {
+		return x.DocumentId
+	}
+	return ""
+}
+
+func (x *RetrievalResult) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *RetrievalResult) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type RetrieveResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Results       []*RetrievalResult     `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	Count         int32                  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	ProcessTimeMs float64                `protobuf:"fixed64,4,opt,name=process_time_ms,json=processTimeMs,proto3" json:"process_time_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveResponse) Reset() {
+	*x = RetrieveResponse{}
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveResponse) ProtoMessage() {}
+
+func (x *RetrieveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_retrieval_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveResponse.ProtoReflect.Descriptor instead.
+func (*RetrieveResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_retrieval_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RetrieveResponse) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *RetrieveResponse) GetResults() []*RetrievalResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *RetrieveResponse) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *RetrieveResponse) GetProcessTimeMs() float64 {
+	if x != nil {
+		return x.ProcessTimeMs
+	}
+	return 0
+}
+
+var File_gorilla_v1_retrieval_proto protoreflect.FileDescriptor
+
+const file_gorilla_v1_retrieval_proto_rawDesc = "" +
+	"\n" +
+	"\x1agorilla/v1/retrieval.proto\x12\n" +
+	"gorilla.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xdc\x01\n" +
+	"\x0fRetrieveRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x13\n" +
+	"\x05top_k\x18\x02 \x01(\x05R\x04topK\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x03 \x01(\tR\n" +
+	"collection\x12B\n" +
+	"\afilters\x18\x04 \x03(\v2(.gorilla.v1.RetrieveRequest.FiltersEntryR\afilters\x1a:\n" +
+	"\fFiltersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb9\x01\n" +
+	"\x0fRetrievalResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\x12\x1f\n" +
+	"\vdocument_id\x18\x04 \x01(\tR\n" +
+	"documentId\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x123\n" +
+	"\bmetadata\x18\x06 \x01(\v2\x17.google.protobuf.StructR\bmetadata\"\x9d\x01\n" +
+	"\x10RetrieveResponse\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x125\n" +
+	"\aresults\x18\x02 \x03(\v2\x1b.gorilla.v1.RetrievalResultR\aresults\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\x12&\n" +
+	"\x0fprocess_time_ms\x18\x04 \x01(\x01R\rprocessTimeMs2Y\n" +
+	"\x10RetrievalService\x12E\n" +
+	"\bRetrieve\x12\x1b.gorilla.v1.RetrieveRequest\x1a\x1c.gorilla.v1.RetrieveResponseB\x12Z\x10protos/gorillapbb\x06proto3"
+
+var (
+	file_gorilla_v1_retrieval_proto_rawDescOnce sync.Once
+	file_gorilla_v1_retrieval_proto_rawDescData []byte
+)
+
+func file_gorilla_v1_retrieval_proto_rawDescGZIP() []byte {
+	file_gorilla_v1_retrieval_proto_rawDescOnce.Do(func() {
+		file_gorilla_v1_retrieval_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gorilla_v1_retrieval_proto_rawDesc), len(file_gorilla_v1_retrieval_proto_rawDesc)))
+	})
+	return file_gorilla_v1_retrieval_proto_rawDescData
+}
+
+var file_gorilla_v1_retrieval_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_gorilla_v1_retrieval_proto_goTypes = []any{
+	(*RetrieveRequest)(nil),  // 0: gorilla.v1.RetrieveRequest
+	(*RetrievalResult)(nil),  // 1: gorilla.v1.RetrievalResult
+	(*RetrieveResponse)(nil), // 2: gorilla.v1.RetrieveResponse
+	nil,                      // 3: gorilla.v1.RetrieveRequest.FiltersEntry
+	(*structpb.Struct)(nil),  // 4: google.protobuf.Struct
+}
+var file_gorilla_v1_retrieval_proto_depIdxs = []int32{
+	3, // 0: gorilla.v1.RetrieveRequest.filters:type_name -> gorilla.v1.RetrieveRequest.FiltersEntry
+	4, // 1: gorilla.v1.RetrievalResult.metadata:type_name -> google.protobuf.Struct
+	1, // 2: gorilla.v1.RetrieveResponse.results:type_name -> gorilla.v1.RetrievalResult
+	0, // 3: gorilla.v1.RetrievalService.Retrieve:input_type -> gorilla.v1.RetrieveRequest
+	2, // 4: gorilla.v1.RetrievalService.Retrieve:output_type -> gorilla.v1.RetrieveResponse
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_gorilla_v1_retrieval_proto_init() }
+func file_gorilla_v1_retrieval_proto_init() {
+	if File_gorilla_v1_retrieval_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gorilla_v1_retrieval_proto_rawDesc), len(file_gorilla_v1_retrieval_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gorilla_v1_retrieval_proto_goTypes,
+		DependencyIndexes: file_gorilla_v1_retrieval_proto_depIdxs,
+		MessageInfos:      file_gorilla_v1_retrieval_proto_msgTypes,
+	}.Build()
+	File_gorilla_v1_retrieval_proto = out.File
+	file_gorilla_v1_retrieval_proto_goTypes = nil
+	file_gorilla_v1_retrieval_proto_depIdxs = nil
+}
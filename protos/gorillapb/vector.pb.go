@@ -0,0 +1,569 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: gorilla/v1/vector.proto
+
+package gorillapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Point struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Vector        *Vector                `protobuf:"bytes,2,opt,name=vector,proto3" json:"vector,omitempty"`
+	Payload       *structpb.Struct       `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Point) Reset() {
+	*x = Point{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Point) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Point) ProtoMessage() {}
+
+func (x *Point) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Point.ProtoReflect.Descriptor instead.
+func (*Point) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Point) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Point) GetVector() *Vector {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *Point) GetPayload() *structpb.Struct {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type UpsertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collection    string                 `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Points        []*Point               `protobuf:"bytes,2,rep,name=points,proto3" json:"points,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertRequest) Reset() {
+	*x = UpsertRequest{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertRequest) ProtoMessage() {}
+
+func (x *UpsertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertRequest.ProtoReflect.Descriptor instead.
+func (*UpsertRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UpsertRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *UpsertRequest) GetPoints() []*Point {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+type UpsertResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Collection    string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Points        int32                  `protobuf:"varint,3,opt,name=points,proto3" json:"points,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertResponse) Reset() {
+	*x = UpsertResponse{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertResponse) ProtoMessage() {}
+
+func (x *UpsertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertResponse.ProtoReflect.Descriptor instead.
+func (*UpsertResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UpsertResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UpsertResponse) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *UpsertResponse) GetPoints() int32 {
+	if x != nil {
+		return x.Points
+	}
+	return 0
+}
+
+type SearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collection    string                 `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Query         *Vector                `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	TopK          int32                  `protobuf:"varint,3,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	Filter        *structpb.Struct       `protobuf:"bytes,4,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SearchRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetQuery() *Vector {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetFilter() *structpb.Struct {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type ScoredPoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Score         float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	Payload       *structpb.Struct       `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScoredPoint) Reset() {
+	*x = ScoredPoint{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScoredPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScoredPoint) ProtoMessage() {}
+
+func (x *ScoredPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScoredPoint.ProtoReflect.Descriptor instead.
+func (*ScoredPoint) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ScoredPoint) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ScoredPoint) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *ScoredPoint) GetPayload() *structpb.Struct {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*ScoredPoint         `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SearchResponse) GetResults() []*ScoredPoint {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type ListCollectionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCollectionsRequest) Reset() {
+	*x = ListCollectionsRequest{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCollectionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollectionsRequest) ProtoMessage() {}
+
+func (x *ListCollectionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollectionsRequest.ProtoReflect.Descriptor instead.
+func (*ListCollectionsRequest) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{6}
+}
+
+type ListCollectionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collections   []string               `protobuf:"bytes,1,rep,name=collections,proto3" json:"collections,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCollectionsResponse) Reset() {
+	*x = ListCollectionsResponse{}
+	mi := &file_gorilla_v1_vector_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCollectionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollectionsResponse) ProtoMessage() {}
+
+func (x *ListCollectionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gorilla_v1_vector_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollectionsResponse.ProtoReflect.Descriptor instead.
+func (*ListCollectionsResponse) Descriptor() ([]byte, []int) {
+	return file_gorilla_v1_vector_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListCollectionsResponse) GetCollections() []string {
+	if x != nil {
+		return x.Collections
+	}
+	return nil
+}
+
+var File_gorilla_v1_vector_proto protoreflect.FileDescriptor
+
+const file_gorilla_v1_vector_proto_rawDesc = "" +
+	"\n" +
+	"\x17gorilla/v1/vector.proto\x12\n" +
+	"gorilla.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17gorilla/v1/common.proto\"v\n" +
+	"\x05Point\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x06vector\x18\x02 \x01(\v2\x12.gorilla.v1.VectorR\x06vector\x121\n" +
+	"\apayload\x18\x03 \x01(\v2\x17.google.protobuf.StructR\apayload\"Z\n" +
+	"\rUpsertRequest\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x01 \x01(\tR\n" +
+	"collection\x12)\n" +
+	"\x06points\x18\x02 \x03(\v2\x11.gorilla.v1.PointR\x06points\"`\n" +
+	"\x0eUpsertResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12\x16\n" +
+	"\x06points\x18\x03 \x01(\x05R\x06points\"\x9f\x01\n" +
+	"\rSearchRequest\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x01 \x01(\tR\n" +
+	"collection\x12(\n" +
+	"\x05query\x18\x02 \x01(\v2\x12.gorilla.v1.VectorR\x05query\x12\x13\n" +
+	"\x05top_k\x18\x03 \x01(\x05R\x04topK\x12/\n" +
+	"\x06filter\x18\x04 \x01(\v2\x17.google.protobuf.StructR\x06filter\"f\n" +
+	"\vScoredPoint\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x121\n" +
+	"\apayload\x18\x03 \x01(\v2\x17.google.protobuf.StructR\apayload\"Y\n" +
+	"\x0eSearchResponse\x121\n" +
+	"\aresults\x18\x01 \x03(\v2\x17.gorilla.v1.ScoredPointR\aresults\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\"\x18\n" +
+	"\x16ListCollectionsRequest\";\n" +
+	"\x17ListCollectionsResponse\x12 \n" +
+	"\vcollections\x18\x01 \x03(\tR\vcollections2\xed\x01\n" +
+	"\rVectorService\x12?\n" +
+	"\x06Upsert\x12\x19.gorilla.v1.UpsertRequest\x1a\x1a.gorilla.v1.UpsertResponse\x12?\n" +
+	"\x06Search\x12\x19.gorilla.v1.SearchRequest\x1a\x1a.gorilla.v1.SearchResponse\x12Z\n" +
+	"\x0fListCollections\x12\".gorilla.v1.ListCollectionsRequest\x1a#.gorilla.v1.ListCollectionsResponseB\x12Z\x10protos/gorillapbb\x06proto3"
+
+var (
+	file_gorilla_v1_vector_proto_rawDescOnce sync.Once
+	file_gorilla_v1_vector_proto_rawDescData []byte
+)
+
+func file_gorilla_v1_vector_proto_rawDescGZIP() []byte {
+	file_gorilla_v1_vector_proto_rawDescOnce.Do(func() {
+		file_gorilla_v1_vector_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gorilla_v1_vector_proto_rawDesc), len(file_gorilla_v1_vector_proto_rawDesc)))
+	})
+	return file_gorilla_v1_vector_proto_rawDescData
+}
+
+var file_gorilla_v1_vector_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_gorilla_v1_vector_proto_goTypes = []any{
+	(*Point)(nil),                   // 0: gorilla.v1.Point
+	(*UpsertRequest)(nil),           // 1: gorilla.v1.UpsertRequest
+	(*UpsertResponse)(nil),          // 2: gorilla.v1.UpsertResponse
+	(*SearchRequest)(nil),           // 3: gorilla.v1.SearchRequest
+	(*ScoredPoint)(nil),             // 4: gorilla.v1.ScoredPoint
+	(*SearchResponse)(nil),          // 5: gorilla.v1.SearchResponse
+	(*ListCollectionsRequest)(nil),  // 6: gorilla.v1.ListCollectionsRequest
+	(*ListCollectionsResponse)(nil), // 7: gorilla.v1.ListCollectionsResponse
+	(*Vector)(nil),                  // 8: gorilla.v1.Vector
+	(*structpb.Struct)(nil),         // 9: google.protobuf.Struct
+}
+var file_gorilla_v1_vector_proto_depIdxs = []int32{
+	8,  // 0: gorilla.v1.Point.vector:type_name -> gorilla.v1.Vector
+	9,  // 1: gorilla.v1.Point.payload:type_name -> google.protobuf.Struct
+	0,  // 2: gorilla.v1.UpsertRequest.points:type_name -> gorilla.v1.Point
+	8,  // 3: gorilla.v1.SearchRequest.query:type_name -> gorilla.v1.Vector
+	9,  // 4: gorilla.v1.SearchRequest.filter:type_name -> google.protobuf.Struct
+	9,  // 5: gorilla.v1.ScoredPoint.payload:type_name -> google.protobuf.Struct
+	4,  // 6: gorilla.v1.SearchResponse.results:type_name -> gorilla.v1.ScoredPoint
+	1,  // 7: gorilla.v1.VectorService.Upsert:input_type -> gorilla.v1.UpsertRequest
+	3,  // 8: gorilla.v1.VectorService.Search:input_type -> gorilla.v1.SearchRequest
+	6,  // 9: gorilla.v1.VectorService.ListCollections:input_type -> gorilla.v1.ListCollectionsRequest
+	2,  // 10: gorilla.v1.VectorService.Upsert:output_type -> gorilla.v1.UpsertResponse
+	5,  // 11: gorilla.v1.VectorService.Search:output_type -> gorilla.v1.SearchResponse
+	7,  // 12: gorilla.v1.VectorService.ListCollections:output_type -> gorilla.v1.ListCollectionsResponse
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_gorilla_v1_vector_proto_init() }
+func file_gorilla_v1_vector_proto_init() {
+	if File_gorilla_v1_vector_proto != nil {
+		return
+	}
+	file_gorilla_v1_common_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gorilla_v1_vector_proto_rawDesc), len(file_gorilla_v1_vector_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gorilla_v1_vector_proto_goTypes,
+		DependencyIndexes: file_gorilla_v1_vector_proto_depIdxs,
+		MessageInfos:      file_gorilla_v1_vector_proto_msgTypes,
+	}.Build()
+	File_gorilla_v1_vector_proto = out.File
+	file_gorilla_v1_vector_proto_goTypes = nil
+	file_gorilla_v1_vector_proto_depIdxs = nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"protos/gorillapb"
+)
+
+// embeddingServer exposes generateEmbedding/generateBatchEmbeddings over the
+// gorilla.v1.EmbeddingService contract.
+type embeddingServer struct {
+	gorillapb.UnimplementedEmbeddingServiceServer
+}
+
+func (embeddingServer) Embed(ctx context.Context, req *gorillapb.EmbedRequest) (*gorillapb.EmbedResponse, error) {
+	if req.GetText() == "" {
+		return nil, status.Error(codes.InvalidArgument, "text cannot be empty")
+	}
+
+	embedding, err := generateEmbedding(ctx, embedModel, req.GetText(), 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate embedding: %v", err)
+	}
+
+	return &gorillapb.EmbedResponse{
+		Embedding: &gorillapb.Vector{Values: embedding},
+		Dimension: int32(len(embedding)),
+	}, nil
+}
+
+func (embeddingServer) EmbedBatch(ctx context.Context, req *gorillapb.EmbedBatchRequest) (*gorillapb.EmbedBatchResponse, error) {
+	if len(req.GetTexts()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "texts array cannot be empty")
+	}
+
+	embeddings, err := generateBatchEmbeddings(ctx, embedModel, req.GetTexts(), 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate embeddings: %v", err)
+	}
+
+	resp := &gorillapb.EmbedBatchResponse{
+		Embeddings: make([]*gorillapb.Vector, len(embeddings)),
+		Count:      int32(len(embeddings)),
+	}
+	for i, emb := range embeddings {
+		resp.Embeddings[i] = &gorillapb.Vector{Values: emb}
+	}
+	if len(embeddings) > 0 {
+		resp.Dimension = int32(len(embeddings[0]))
+	}
+	return resp, nil
+}
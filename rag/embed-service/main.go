@@ -4,39 +4,82 @@ package main
 import (
 	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"time"
+
+	"protos/gorillapb"
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/rpc"
+	"shared/server"
+	"shared/tenant"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 const (
 	embedModel        = "text-embedding-004"
-	embedModelPath    = "models/" + embedModel
 	geminiAPIBasePath = "https://generativelanguage.googleapis.com/v1beta"
 	maxBatchSize      = 100
+	maxDimensions     = 3072
 )
 
+// modelNamePattern guards the model name before it is spliced into the
+// Gemini API path.
+var modelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
 type EmbedRequest struct {
-	Text string `json:"text"`
+	Text       string `json:"text"`
+	Model      string `json:"model,omitempty"`      // defaults to text-embedding-004
+	Dimensions int    `json:"dimensions,omitempty"` // output dimensionality; defaults to the model's own
 }
 
 type EmbedBatchRequest struct {
-	Texts []string `json:"texts"`
+	Texts      []string `json:"texts"`
+	Model      string   `json:"model,omitempty"`
+	Dimensions int      `json:"dimensions,omitempty"`
 }
 
 type EmbedResponse struct {
 	Embedding []float32 `json:"embedding"`
 	Dimension int       `json:"dimension"`
+	Model     string    `json:"model"`
 }
 
 type EmbedBatchResponse struct {
 	Embeddings [][]float32 `json:"embeddings"`
 	Count      int         `json:"count"`
 	Dimension  int         `json:"dimension"`
+	Model      string      `json:"model"`
+}
+
+// resolveModel returns the model to embed with, defaulting to embedModel.
+func resolveModel(model string) (string, error) {
+	if model == "" {
+		return embedModel, nil
+	}
+	if !modelNamePattern.MatchString(model) {
+		return "", fmt.Errorf("invalid model name %q", model)
+	}
+	return model, nil
+}
+
+// validDimensions reports whether dimensions is unset or an output size the
+// model can truncate its embeddings to.
+func validDimensions(dimensions int) bool {
+	return dimensions >= 0 && dimensions <= maxDimensions
 }
 
 type geminiAPIError struct {
@@ -47,7 +90,7 @@ type geminiAPIError struct {
 	} `json:"error"`
 }
 
-func callGeminiAPI(endpoint string, payload interface{}, out interface{}) error {
+func callGeminiAPI(ctx context.Context, endpoint string, payload interface{}, out interface{}) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
@@ -84,23 +127,32 @@ func callGeminiAPI(endpoint string, payload interface{}, out interface{}) error
 	return nil
 }
 
-func buildContentPayload(text string) map[string]interface{} {
-	return map[string]interface{}{
+func buildContentPayload(text string, dimensions int) map[string]interface{} {
+	payload := map[string]interface{}{
 		"content": map[string]interface{}{
 			"parts": []map[string]string{
 				{"text": text},
 			},
 		},
 	}
+	if dimensions > 0 {
+		payload["outputDimensionality"] = dimensions
+	}
+	return payload
 }
 
 var (
-	ctx        = context.Background()
 	httpClient = &http.Client{Timeout: 30 * time.Second}
 	apiKey     string
 )
 
 func main() {
+	shutdownTracing, err := tracing.Init("embed-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	apiKey = os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
 		log.Fatal("GEMINI_API_KEY environment variable not set")
@@ -108,13 +160,40 @@ func main() {
 
 	log.Println("Gemini API key loaded successfully")
 
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("embed-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("embed-service", nil))
 	http.HandleFunc("/embed", embedHandler)
 	http.HandleFunc("/embed-batch", embedBatchHandler)
+	http.HandleFunc("/count-tokens", countTokensHandler)
+
+	grpcServer, err := rpc.NewServer()
+	if err != nil {
+		log.Fatalf("Failed to create gRPC server: %v", err)
+	}
+	gorillapb.RegisterEmbeddingServiceServer(grpcServer, embeddingServer{})
+	rpc.Serve(grpcServer, ":"+getEnv("GRPC_PORT", "50051"))
 
 	port := getEnv("PORT", "8081")
 	log.Printf("Embed Service starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("embed-service", handler)
+	handler = metrics.Wrap("embed-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap(nil, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer.GracefulStop()
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -122,7 +201,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":  "healthy",
 		"service": "embed-service",
-		"model":   "text-embedding-004",
+		"model":   embedModel,
 	})
 }
 
@@ -143,7 +222,17 @@ func embedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	embedding, err := generateEmbedding(req.Text)
+	model, err := resolveModel(req.Model)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validDimensions(req.Dimensions) {
+		respondError(w, fmt.Sprintf("dimensions must be between 1 and %d", maxDimensions), http.StatusBadRequest)
+		return
+	}
+
+	embedding, err := generateEmbedding(r.Context(), model, req.Text, req.Dimensions)
 	if err != nil {
 		respondError(w, "Failed to generate embedding: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -152,6 +241,7 @@ func embedHandler(w http.ResponseWriter, r *http.Request) {
 	response := EmbedResponse{
 		Embedding: embedding,
 		Dimension: len(embedding),
+		Model:     model,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -175,9 +265,19 @@ func embedBatchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Generating embeddings for %d texts", len(req.Texts))
+	model, err := resolveModel(req.Model)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validDimensions(req.Dimensions) {
+		respondError(w, fmt.Sprintf("dimensions must be between 1 and %d", maxDimensions), http.StatusBadRequest)
+		return
+	}
 
-	embeddings, err := generateBatchEmbeddings(req.Texts)
+	log.Printf("Generating embeddings for %d texts with %s", len(req.Texts), model)
+
+	embeddings, err := generateBatchEmbeddings(r.Context(), model, req.Texts, req.Dimensions)
 	if err != nil {
 		respondError(w, "Failed to generate embeddings: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -187,20 +287,21 @@ func embedBatchHandler(w http.ResponseWriter, r *http.Request) {
 		Embeddings: embeddings,
 		Count:      len(embeddings),
 		Dimension:  len(embeddings[0]),
+		Model:      model,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func generateEmbedding(text string) ([]float32, error) {
+func generateEmbedding(ctx context.Context, model, text string, dimensions int) ([]float32, error) {
 	var response struct {
 		Embedding struct {
 			Values []float32 `json:"values"`
 		} `json:"embedding"`
 	}
 
-	if err := callGeminiAPI(fmt.Sprintf("%s:embedContent", embedModelPath), buildContentPayload(text), &response); err != nil {
+	if err := callGeminiAPI(ctx, fmt.Sprintf("models/%s:embedContent", model), buildContentPayload(text, dimensions), &response); err != nil {
 		return nil, err
 	}
 
@@ -211,7 +312,8 @@ func generateEmbedding(text string) ([]float32, error) {
 	return response.Embedding.Values, nil
 }
 
-func generateBatchEmbeddings(texts []string) ([][]float32, error) {
+func generateBatchEmbeddings(ctx context.Context, model string, texts []string, dimensions int) ([][]float32, error) {
+	modelPath := "models/" + model
 	result := make([][]float32, 0, len(texts))
 
 	for start := 0; start < len(texts); start += maxBatchSize {
@@ -222,8 +324,8 @@ func generateBatchEmbeddings(texts []string) ([][]float32, error) {
 
 		requests := make([]map[string]interface{}, end-start)
 		for i, text := range texts[start:end] {
-			req := buildContentPayload(text)
-			req["model"] = embedModelPath
+			req := buildContentPayload(text, dimensions)
+			req["model"] = modelPath
 			requests[i] = req
 		}
 
@@ -234,11 +336,11 @@ func generateBatchEmbeddings(texts []string) ([][]float32, error) {
 		}
 
 		payload := map[string]interface{}{
-			"model":    embedModelPath,
+			"model":    modelPath,
 			"requests": requests,
 		}
 
-		if err := callGeminiAPI(fmt.Sprintf("%s:batchEmbedContents", embedModelPath), payload, &response); err != nil {
+		if err := callGeminiAPI(ctx, fmt.Sprintf("%s:batchEmbedContents", modelPath), payload, &response); err != nil {
 			return nil, err
 		}
 
@@ -266,3 +368,10 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
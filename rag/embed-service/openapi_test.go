@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"shared/openapi"
+)
+
+// TestSpecMatchesTypes holds openapi.json to the types the handlers decode
+// and encode, so neither changes without the other.
+func TestSpecMatchesTypes(t *testing.T) {
+	spec := openapi.MustLoad(openAPISpec)
+	for name, v := range map[string]interface{}{
+		"EmbedRequest":        EmbedRequest{},
+		"EmbedBatchRequest":   EmbedBatchRequest{},
+		"EmbedResponse":       EmbedResponse{},
+		"EmbedBatchResponse":  EmbedBatchResponse{},
+		"CountTokensRequest":  CountTokensRequest{},
+		"CountTokensResponse": CountTokensResponse{},
+	} {
+		if err := spec.CheckType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+}
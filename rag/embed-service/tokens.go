@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// ============================================================================
+// TOKEN COUNTING
+// ============================================================================
+// POST /count-tokens counts the tokens of each text with the Gemini API's
+// own tokenizer, so a caller can size its texts to the embedding model's
+// input limit (2048 tokens for text-embedding-004) instead of guessing from
+// their length. Gemini truncates a longer input without saying so.
+//
+// countTokens counts a request's contents together, so each text is a
+// call of its own, COUNT_TOKENS_CONCURRENCY (default 8) at a time, and
+// counts against the Gemini API quota like any other call: callers should
+// count whole chunks rather than their sentences. The
+// embedding models don't serve countTokens; texts are counted with
+// COUNT_TOKENS_MODEL (default gemini-2.0-flash), whose tokenizer they
+// share, unless the request names another model.
+
+const maxCountTexts = 1000
+
+var (
+	countTokensModel       = getEnv("COUNT_TOKENS_MODEL", "gemini-2.0-flash")
+	countTokensConcurrency = envInt("COUNT_TOKENS_CONCURRENCY", 8)
+)
+
+type CountTokensRequest struct {
+	Texts []string `json:"texts"`
+	Model string   `json:"model,omitempty"` // defaults to COUNT_TOKENS_MODEL
+}
+
+type CountTokensResponse struct {
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+	Model  string `json:"model"`
+}
+
+func countTokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CountTokensRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Texts) == 0 {
+		respondError(w, "Texts array cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(req.Texts) > maxCountTexts {
+		respondError(w, fmt.Sprintf("At most %d texts can be counted at once", maxCountTexts), http.StatusBadRequest)
+		return
+	}
+
+	model := countTokensModel
+	if req.Model != "" {
+		var err error
+		if model, err = resolveModel(req.Model); err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	log.Printf("Counting tokens of %d texts with %s", len(req.Texts), model)
+
+	counts, err := countTokens(r.Context(), model, req.Texts)
+	if err != nil {
+		respondError(w, "Failed to count tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := CountTokensResponse{Counts: counts, Model: model}
+	for _, n := range counts {
+		response.Total += n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// countTokens counts the tokens of each text, stopping at the first error.
+func countTokens(ctx context.Context, model string, texts []string) ([]int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	counts := make([]int, len(texts))
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, max(countTokensConcurrency, 1))
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		// Once a call has failed, the rest aren't made
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var response struct {
+				TotalTokens int `json:"totalTokens"`
+			}
+			payload := map[string]interface{}{
+				"contents": []map[string]interface{}{
+					{"parts": []map[string]string{{"text": text}}},
+				},
+			}
+			if err := callGeminiAPI(ctx, fmt.Sprintf("models/%s:countTokens", model), payload, &response); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			counts[i] = response.TotalTokens
+		}(i, text)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ============================================================================
+// BATCH INGESTION
+// ============================================================================
+// POST /ingest-batch ingests many files in one request: every file of
+// "directory" whose type can be extracted (.pdf, .docx, .doc, .txt), with
+// "recursive" to include its subdirectories, and/or the "file_paths"
+// listed. Each file becomes its own document, named after the file, with
+// the type, tags, metadata, effective date and chunking options given for
+// the batch.
+//
+// Files are ingested INGEST_BATCH_CONCURRENCY at a time (default 4), or
+// "concurrency" if lower, and the response reports each file: its
+// document_id and chunks, or why it failed. One file failing doesn't stop
+// the others, so the response is 200 whenever the batch itself is valid.
+// A batch holds at most INGEST_BATCH_MAX_FILES files (default 500); a
+// directory scan stops as soon as it finds more. Subdirectories that can't
+// be read are skipped. If the caller goes away, the files not yet started
+// are reported failed.
+//
+// The directory and every file path must be inside DATA_DIR, where
+// uploads are stored, so a batch can't read files elsewhere on the server.
+// Paths are checked with their symlinks resolved, and a directory scan
+// skips symlinks, so a link can't lead a batch out of DATA_DIR either.
+//
+// With "async": true each file is queued as an ingestion job of its own
+// instead (see jobs.go), and the 202 response lists the job_id to poll for
+// each.
+
+var (
+	INGEST_BATCH_CONCURRENCY = max(envInt("INGEST_BATCH_CONCURRENCY", 4), 1)
+	INGEST_BATCH_MAX_FILES   = max(envInt("INGEST_BATCH_MAX_FILES", 500), 1)
+)
+
+// batchExtensions are the file types a directory is scanned for.
+var batchExtensions = map[string]bool{".pdf": true, ".docx": true, ".doc": true, ".txt": true}
+
+// errBatchTooLarge stops a directory scan that finds too many files.
+var errBatchTooLarge = errors.New("too many files")
+
+// BatchIngestRequest - Files to ingest, and the options they share
+type BatchIngestRequest struct {
+	Directory   string   `json:"directory"`
+	Recursive   bool     `json:"recursive"`
+	FilePaths   []string `json:"file_paths"`
+	Concurrency int      `json:"concurrency"` // at most INGEST_BATCH_CONCURRENCY
+	IngestRequest
+}
+
+// BatchIngestResponse - The outcome of every file of a batch
+type BatchIngestResponse struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Queued    int               `json:"queued,omitempty"`
+	Failed    int               `json:"failed"`
+	Chunks    int               `json:"chunks"`
+	Files     []BatchFileResult `json:"files"`
+}
+
+// BatchFileResult - The outcome of one file of a batch
+type BatchFileResult struct {
+	FilePath   string `json:"file_path"`
+	Status     string `json:"status"` // completed, queued or failed
+	DocumentID string `json:"document_id,omitempty"`
+	JobID      string `json:"job_id,omitempty"`
+	Chunks     int    `json:"chunks"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Ingest every file of a directory or list
+func ingestBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchIngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.DocumentName != "" || req.FilePath != "" {
+		respondError(w, "document_name and file_path are set per file; use directory or file_paths", http.StatusBadRequest)
+		return
+	}
+
+	paths, err := batchFiles(req)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	effectiveAt, err := prepareIngest(&req.IngestRequest)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	concurrency := INGEST_BATCH_CONCURRENCY
+	if req.Concurrency > 0 {
+		concurrency = min(req.Concurrency, concurrency)
+	}
+	log.Printf("Ingesting a batch of %d files, %d at a time", len(paths), concurrency)
+
+	results := make([]BatchFileResult, len(paths))
+	if req.Async {
+		for i, path := range paths {
+			results[i] = submitBatchFile(r.Context(), fileRequest(req.IngestRequest, path), effectiveAt)
+		}
+	} else {
+		ingestFiles(r.Context(), req.IngestRequest, paths, effectiveAt, concurrency, results)
+	}
+
+	resp := BatchIngestResponse{Total: len(results), Files: results}
+	for _, result := range results {
+		switch result.Status {
+		case jobCompleted:
+			resp.Succeeded++
+		case jobQueued:
+			resp.Queued++
+		default:
+			resp.Failed++
+		}
+		resp.Chunks += result.Chunks
+	}
+	log.Printf("Batch finished: %d succeeded, %d queued, %d failed", resp.Succeeded, resp.Queued, resp.Failed)
+	if req.Async {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+	}
+	jsonResponse(w, resp)
+}
+
+// ingestFiles ingests paths concurrently, concurrency at a time, writing
+// each file's outcome to the same index of results.
+func ingestFiles(ctx context.Context, req IngestRequest, paths []string, effectiveAt int64, concurrency int, results []BatchFileResult) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, path := range paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(paths); j++ {
+				results[j] = BatchFileResult{FilePath: paths[j], Status: jobFailed, Error: err.Error()}
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := BatchFileResult{FilePath: path}
+			resp, err := ingestFile(ctx, fileRequest(req, path), effectiveAt, nil)
+			if err != nil {
+				result.Status, result.Error = jobFailed, err.Error()
+				log.Printf("⚠️  Batch file %s failed: %v", path, err)
+			} else {
+				result.Status, result.DocumentID, result.Chunks = jobCompleted, resp.DocumentID, resp.Chunks
+			}
+			results[i] = result
+		}(i, path)
+	}
+	wg.Wait()
+}
+
+// submitBatchFile queues one file of an async batch as its own job.
+func submitBatchFile(ctx context.Context, req IngestRequest, effectiveAt int64) BatchFileResult {
+	result := BatchFileResult{FilePath: req.FilePath}
+	job, ok := ingestJobs.Submit(ctx, &checkpoint{Request: req, EffectiveAt: effectiveAt})
+	if !ok {
+		result.Status, result.Error = jobFailed, "Job queue is full, try again later"
+		return result
+	}
+	result.Status, result.JobID = jobQueued, job.ID
+	return result
+}
+
+// fileRequest is the batch's request for the file at path.
+func fileRequest(req IngestRequest, path string) IngestRequest {
+	req.FilePath = path
+	req.DocumentName = documentNameFor(path)
+	return req
+}
+
+// documentNameFor names a document after its file, without the ID /upload
+// prefixes stored files with.
+func documentNameFor(path string) string {
+	name := filepath.Base(path)
+	if prefix, rest, ok := strings.Cut(name, "_"); ok && rest != "" {
+		if _, err := uuid.Parse(prefix); err == nil {
+			return rest
+		}
+	}
+	return name
+}
+
+// batchFiles lists the files of req's directory and file_paths, without
+// duplicates.
+func batchFiles(req BatchIngestRequest) ([]string, error) {
+	if req.Directory == "" && len(req.FilePaths) == 0 {
+		return nil, fmt.Errorf("directory or file_paths required")
+	}
+
+	var paths []string
+	if req.Directory != "" {
+		if !inDataDir(req.Directory) && !isDataDir(req.Directory) {
+			return nil, fmt.Errorf("directory %s is outside DATA_DIR", req.Directory)
+		}
+		found, err := scanDirectory(req.Directory, req.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, found...)
+	}
+	for _, path := range req.FilePaths {
+		if path == "" {
+			return nil, fmt.Errorf("file_paths can't hold an empty path")
+		}
+		if !inDataDir(path) {
+			return nil, fmt.Errorf("file %s is outside DATA_DIR", path)
+		}
+		paths = append(paths, path)
+	}
+
+	seen := make(map[string]bool, len(paths))
+	unique := paths[:0]
+	for _, path := range paths {
+		if key := filepath.Clean(path); !seen[key] {
+			seen[key] = true
+			unique = append(unique, path)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("no .pdf, .docx, .doc or .txt files in %s", req.Directory)
+	}
+	if len(unique) > INGEST_BATCH_MAX_FILES {
+		return nil, fmt.Errorf("batch has %d files, more than the %d allowed", len(unique), INGEST_BATCH_MAX_FILES)
+	}
+	return unique, nil
+}
+
+// isDataDir reports whether path names DATA_DIR itself, see inDataDir.
+func isDataDir(path string) bool {
+	rel, ok := dataDirRel(path)
+	return ok && rel == "."
+}
+
+// scanDirectory lists the files of dir that can be extracted, in name
+// order, descending into subdirectories when recursive. It stops as soon
+// as it finds more than INGEST_BATCH_MAX_FILES.
+func scanDirectory(dir string, recursive bool) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("⚠️  Skipping %s: %v", path, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && batchExtensions[strings.ToLower(filepath.Ext(path))] {
+			if len(paths) == INGEST_BATCH_MAX_FILES {
+				return errBatchTooLarge
+			}
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchTooLarge) {
+		return nil, fmt.Errorf("%s holds more than the %d files a batch allows", dir, INGEST_BATCH_MAX_FILES)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read directory: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// symlinkedDataDir makes a DATA_DIR holding a.txt and sub/b.txt, and links
+// to a file, a directory and a file that doesn't exist outside it. It
+// returns DATA_DIR and the directory outside.
+func symlinkedDataDir(t *testing.T) (dir, outside string) {
+	t.Helper()
+	dir, outside = useDataDir(t), t.TempDir()
+	for _, path := range []string{
+		filepath.Join(dir, "a.txt"),
+		filepath.Join(dir, "sub", "b.txt"),
+		filepath.Join(outside, "secret.txt"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("text"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"link.txt":     filepath.Join(outside, "secret.txt"),
+		"linkdir":      outside,
+		"dangling.txt": filepath.Join(outside, "missing.txt"),
+		"inside.txt":   filepath.Join(dir, "a.txt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+	return dir, outside
+}
+
+func TestInDataDir(t *testing.T) {
+	dir, outside := symlinkedDataDir(t)
+	tests := []struct {
+		name   string
+		path   string
+		inside bool
+	}{
+		{"file", filepath.Join(dir, "a.txt"), true},
+		{"subdirectory", filepath.Join(dir, "sub", "b.txt"), true},
+		{"dot-dot staying inside", filepath.Join(dir, "sub") + "/../a.txt", true},
+		{"not yet created", filepath.Join(dir, "sub", "new.txt"), true},
+		{"link within DATA_DIR", filepath.Join(dir, "inside.txt"), true},
+		{"DATA_DIR itself", dir, false},
+		{"empty", "", false},
+		{"elsewhere", filepath.Join(outside, "secret.txt"), false},
+		{"dot-dot escape", dir + "/../" + filepath.Base(outside) + "/secret.txt", false},
+		{"dot-dot to the parent", dir + "/sub/../..", false},
+		{"link to a file outside", filepath.Join(dir, "link.txt"), false},
+		{"link to a directory outside", filepath.Join(dir, "linkdir"), false},
+		{"file through a linked directory", filepath.Join(dir, "linkdir", "secret.txt"), false},
+		{"new file through a linked directory", filepath.Join(dir, "linkdir", "new.txt"), false},
+		{"broken link", filepath.Join(dir, "dangling.txt"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inDataDir(tt.path); got != tt.inside {
+				t.Errorf("inDataDir(%s) = %t, want %t", tt.path, got, tt.inside)
+			}
+		})
+	}
+}
+
+func TestInDataDirThroughLinkedDataDir(t *testing.T) {
+	real := t.TempDir()
+	if err := os.WriteFile(filepath.Join(real, "a.txt"), []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "data")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+	previous := DATA_DIR
+	DATA_DIR = link
+	defer func() { DATA_DIR = previous }()
+
+	for _, path := range []string{filepath.Join(link, "a.txt"), filepath.Join(real, "a.txt")} {
+		if !inDataDir(path) {
+			t.Errorf("inDataDir(%s) = false with DATA_DIR linked to %s", path, real)
+		}
+	}
+	if !isDataDir(real) || !isDataDir(link) {
+		t.Error("isDataDir doesn't see through the DATA_DIR link")
+	}
+}
+
+func TestBatchFilesStaysInDataDir(t *testing.T) {
+	dir, outside := symlinkedDataDir(t)
+	tests := []struct {
+		name string
+		req  BatchIngestRequest
+		ok   bool
+	}{
+		{"DATA_DIR", BatchIngestRequest{Directory: dir, Recursive: true}, true},
+		{"file", BatchIngestRequest{FilePaths: []string{filepath.Join(dir, "a.txt")}}, true},
+		{"directory outside", BatchIngestRequest{Directory: outside}, false},
+		{"dot-dot directory", BatchIngestRequest{Directory: dir + "/.."}, false},
+		{"linked directory", BatchIngestRequest{Directory: filepath.Join(dir, "linkdir")}, false},
+		{"linked file", BatchIngestRequest{FilePaths: []string{filepath.Join(dir, "link.txt")}}, false},
+		{"dot-dot file", BatchIngestRequest{FilePaths: []string{dir + "/../" + filepath.Base(outside) + "/secret.txt"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := batchFiles(tt.req)
+			if (err == nil) != tt.ok {
+				t.Fatalf("batchFiles = %v, %v, want ok = %t", paths, err, tt.ok)
+			}
+			for _, path := range paths {
+				if !inDataDir(path) {
+					t.Errorf("batch includes %s, outside DATA_DIR", path)
+				}
+			}
+		})
+	}
+}
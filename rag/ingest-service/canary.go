@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// EMBEDDING CANARY
+// ============================================================================
+// When CANARY_EMBED_MODEL is set, every ingested document is also embedded
+// with the candidate model and written to a shadow collection
+// (<collection>CANARY_SHADOW_SUFFIX). retrieval-service searches those shadow
+// collections for its canary comparisons. Shadow indexing is best-effort and
+// never fails the ingest.
+
+var (
+	CANARY_EMBED_MODEL   = getEnv("CANARY_EMBED_MODEL", "")
+	CANARY_SHADOW_SUFFIX = getEnv("CANARY_SHADOW_SUFFIX", "_canary")
+)
+
+func indexShadow(ctx context.Context, chunks []Chunk, docType string) {
+	if CANARY_EMBED_MODEL == "" || len(chunks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+	go func() {
+		defer cancel()
+
+		texts := make([]string, len(chunks))
+		for i, c := range chunks {
+			texts[i] = c.Text
+		}
+
+		var out struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed-batch", map[string]interface{}{
+			"texts": texts,
+			"model": CANARY_EMBED_MODEL,
+		}, &out, httpclient.Idempotent)
+		if err != nil {
+			log.Printf("⚠️  Shadow embedding with %s failed: %v", CANARY_EMBED_MODEL, err)
+			return
+		}
+
+		shadow := collectionForType(docType) + CANARY_SHADOW_SUFFIX
+		if err := upsertVectors(ctx, shadow, chunks, out.Embeddings); err != nil {
+			log.Printf("⚠️  Shadow indexing into %s failed: %v", shadow, err)
+			return
+		}
+		log.Printf("Shadow-indexed %d chunks into %s with %s", len(chunks), shadow, CANARY_EMBED_MODEL)
+	}()
+}
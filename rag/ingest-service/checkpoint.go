@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shared/tenant"
+)
+
+// ============================================================================
+// JOB CHECKPOINTS
+// ============================================================================
+// Chunks are embedded and stored INGEST_BATCH_SIZE at a time (default 100),
+// and an ingestion job checkpoints to INGEST_JOB_DIR (default ./data/jobs)
+// as it goes: its request when it is queued, the document and its chunks
+// once they are cut, and the chunks embedded and stored after each batch.
+// A job picks up from its checkpoint instead of starting over, so a large
+// document isn't embedded (and billed) twice:
+//
+//   - POST /jobs/{id}/resume requeues a failed job, as long as it is still
+//     readable (INGEST_JOB_TTL).
+//   - On startup, jobs a restart cut short are queued again, and failed
+//     ones become readable and resumable again.
+//
+// A job checkpointed before its chunks were cut starts over from its
+// request. A resumed job keeps its document ID and chunk IDs, so the
+// batches it stores again overwrite themselves. A job's checkpoint is
+// removed once it completes or is forgotten.
+
+var INGEST_JOB_DIR = getEnv("INGEST_JOB_DIR", "./data/jobs")
+
+// checkpoint - What an ingestion job has done so far
+type checkpoint struct {
+	JobID       string        `json:"job_id"`
+	TenantID    string        `json:"tenant_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Request     IngestRequest `json:"request"`
+	URL         string        `json:"url,omitempty"`      // the page of an /ingest-url job
+	Reingest    *Document     `json:"reingest,omitempty"` // the document a re-ingestion job replaces the chunks of
+	EffectiveAt int64         `json:"effective_at,omitempty"`
+
+	Document   *Document `json:"document,omitempty"` // set once the chunks are cut
+	Characters int       `json:"characters,omitempty"`
+	Chunks     []Chunk   `json:"chunks,omitempty"`
+	Stored     int       `json:"stored"` // chunks embedded and stored, in order
+
+	Failed   bool      `json:"failed,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// run ingests the checkpoint's document from where it left off.
+func (cp *checkpoint) run(ctx context.Context, prog *ingestProgress) (IngestResponse, error) {
+	if cp.Document == nil {
+		if cp.Reingest != nil {
+			return reingestDocument(ctx, *cp.Reingest, cp.Request, cp.EffectiveAt, prog)
+		}
+		if cp.URL != "" {
+			pageURL, err := url.Parse(cp.URL)
+			if err != nil {
+				return IngestResponse{}, err
+			}
+			return ingestPage(ctx, cp.Request, pageURL, cp.EffectiveAt, prog)
+		}
+		return ingestFile(ctx, cp.Request, cp.EffectiveAt, prog)
+	}
+
+	log.Printf("Resuming document %s at chunk %d of %d", cp.Document.ID, cp.Stored, len(cp.Chunks))
+	updateDocumentStatus(ctx, cp.Document.ID, "processing")
+	return storeChunks(ctx, *cp.Document, cp.Chunks, cp.Stored, prog)
+}
+
+func (cp *checkpoint) path() string {
+	return filepath.Join(INGEST_JOB_DIR, cp.JobID+".json")
+}
+
+// save writes the checkpoint, replacing the last one whole. A checkpoint
+// that can't be written only costs the job its resumability.
+func (cp *checkpoint) save() {
+	b, err := json.Marshal(cp)
+	if err == nil {
+		tmp := cp.path() + ".tmp"
+		if err = os.WriteFile(tmp, b, 0600); err == nil {
+			err = os.Rename(tmp, cp.path())
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️  Failed to checkpoint job %s: %v", cp.JobID, err)
+	}
+}
+
+func (cp *checkpoint) remove() {
+	if err := os.Remove(cp.path()); err != nil && !os.IsNotExist(err) {
+		log.Printf("⚠️  Failed to remove checkpoint of job %s: %v", cp.JobID, err)
+	}
+}
+
+// restore reads the checkpoints a previous run of the service left,
+// queueing the jobs it cut short and keeping the failed ones readable.
+func (q *ingestJobQueue) restore() {
+	paths, err := filepath.Glob(filepath.Join(INGEST_JOB_DIR, "*.json"))
+	if err != nil {
+		log.Printf("⚠️  Failed to list checkpoints: %v", err)
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	resumed := 0
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		var cp checkpoint
+		if err == nil {
+			err = json.Unmarshal(b, &cp)
+		}
+		if err == nil && cp.JobID != strings.TrimSuffix(filepath.Base(path), ".json") {
+			err = fmt.Errorf("checkpoint is for job %q", cp.JobID)
+		}
+		if err != nil {
+			log.Printf("⚠️  Skipping unreadable checkpoint %s: %v", path, err)
+			continue
+		}
+
+		job := newIngestJob(tenant.WithTenant(context.Background(), cp.TenantID), &cp)
+		if !cp.Failed {
+			select {
+			case q.pending <- job:
+				q.jobs[jobKey(job.tenantID, job.ID)] = job
+				resumed++
+				continue
+			default:
+				cp.Failed, cp.Error, cp.FailedAt = true, "interrupted by a restart", time.Now()
+				cp.save()
+			}
+		}
+		job.Status = jobFailed
+		job.Error = cp.Error
+		job.CompletedAt = &cp.FailedAt
+		q.jobs[jobKey(job.tenantID, job.ID)] = job
+	}
+	if len(paths) > 0 {
+		log.Printf("📋 Restored %d ingest jobs from checkpoints, %d resumed", len(q.jobs), resumed)
+	}
+}
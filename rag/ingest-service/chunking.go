@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ============================================================================
+// CHUNKING
+// ============================================================================
+// Text is cut into chunks of whole sentences, so no chunk starts or ends
+// mid-sentence or mid-word. Paragraphs (text between blank lines, which
+// extraction keeps for PDF pages and Word paragraphs) are split into
+// sentences, and sentences are packed into a chunk while it stays within
+// chunk_size characters. A chunk at least half full ends at a paragraph
+// break rather than take only the start of the next paragraph. Each chunk
+// after the first begins with the last whole sentences of the one before,
+// up to chunk_overlap characters, when they leave room for a new sentence.
+//
+// Both limits are soft: a chunk is usually shorter than chunk_size, since
+// it ends at the last sentence that fits, and its overlap is shorter than
+// chunk_overlap, or empty, since only whole sentences are repeated. Only a
+// sentence longer than chunk_size is cut, between words. Whitespace inside
+// a paragraph is collapsed to single spaces.
+
+// sentence - A sentence of the text, with the paragraph it belongs to
+type sentence struct {
+	text      string
+	paragraph int
+	length    int // in characters, or estimated tokens (see tokens.go)
+}
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// abbreviations end in a period without ending the sentence, as in
+// "Rs. 25 crore" or "Sec. 35A".
+var abbreviations = map[string]bool{
+	"rs": true, "no": true, "nos": true, "sec": true, "secs": true, "cl": true,
+	"para": true, "paras": true, "art": true, "ch": true, "vol": true,
+	"pg": true, "pp": true, "fig": true, "ref": true, "viz": true, "etc": true,
+	"e.g": true, "i.e": true, "cf": true, "vs": true, "approx": true,
+	"mr": true, "mrs": true, "ms": true, "dr": true, "shri": true, "smt": true,
+	"ltd": true, "pvt": true, "co": true, "inc": true, "corp": true, "govt": true,
+	"dept": true, "jan": true, "feb": true, "mar": true, "apr": true, "jun": true,
+	"jul": true, "aug": true, "sep": true, "sept": true, "oct": true, "nov": true,
+	"dec": true, "st": true, "u.s": true,
+}
+
+// Chunking strategies, chosen by an ingest's "chunking_strategy"
+const (
+	chunkingSentence = "sentence"
+	chunkingSemantic = "semantic" // see semantic.go
+)
+
+// chunkDocument cuts text into chunks by req's chunking strategy, sized in
+// characters or, with chunk_size_tokens, in tokens (see tokens.go).
+func chunkDocument(ctx context.Context, req IngestRequest, text, docID string) ([]Chunk, error) {
+	sentences := splitSentences(text)
+	size, overlap := req.ChunkSize, req.ChunkOverlap
+	if req.ChunkTokens > 0 {
+		estimateTokens(sentences)
+		size, overlap = req.ChunkTokens, tokenOverlap(req)
+	}
+
+	var parts []string
+	if req.ChunkStrategy == chunkingSemantic {
+		var err error
+		if parts, err = chunkSemantic(ctx, sentences, size); err != nil {
+			return nil, err
+		}
+		overlap = 0
+	} else {
+		parts = packSentences(sentences, size, overlap)
+	}
+
+	if req.ChunkTokens > 0 {
+		var err error
+		if parts, err = fitTokens(ctx, parts, size, overlap); err != nil {
+			return nil, err
+		}
+	}
+	return newChunks(parts, docID), nil
+}
+
+// newChunks numbers the document's chunk texts in order.
+func newChunks(parts []string, docID string) []Chunk {
+	var chunks []Chunk
+	for pos, part := range parts {
+		chunks = append(chunks, Chunk{
+			ID:         uuid.New().String(),
+			DocumentID: docID,
+			Text:       part,
+			Position:   pos,
+		})
+	}
+	return chunks
+}
+
+// splitSentences splits text into paragraphs and those into sentences.
+func splitSentences(text string) []sentence {
+	var sentences []sentence
+	for p, paragraph := range paragraphBreak.Split(text, -1) {
+		words := strings.Fields(paragraph)
+		start := 0
+		for i, word := range words {
+			next := ""
+			if i+1 < len(words) {
+				next = words[i+1]
+			}
+			if i+1 == len(words) || endsSentence(word, next, i == start) {
+				s := strings.Join(words[start:i+1], " ")
+				sentences = append(sentences, sentence{text: s, paragraph: p, length: utf8.RuneCountInString(s)})
+				start = i + 1
+			}
+		}
+	}
+	return sentences
+}
+
+// endsSentence reports whether word ends its sentence, given the word
+// after it. A list marker such as "1." or "(a)" opening a sentence doesn't.
+func endsSentence(word, next string, first bool) bool {
+	trimmed := strings.TrimRight(word, `"')]”’`)
+	if trimmed == "" {
+		return false
+	}
+	switch last, _ := utf8.DecodeLastRuneInString(trimmed); last {
+	case '!', '?', '…':
+	case '.':
+		stem := strings.ToLower(strings.TrimLeft(strings.TrimSuffix(trimmed, "."), `"'([“‘`))
+		if abbreviations[stem] || utf8.RuneCountInString(stem) == 1 || first {
+			return false
+		}
+	default:
+		return false
+	}
+	// The next sentence opens with a capital, a digit or punctuation, not
+	// a lowercase word
+	r, _ := utf8.DecodeRuneInString(next)
+	return !unicode.IsLower(r)
+}
+
+// packSentences joins sentences into chunks of about size, each repeating
+// up to overlap of whole sentences from the chunk before, both in the unit
+// the sentences are measured in.
+func packSentences(sentences []sentence, size, overlap int) []string {
+	var (
+		parts   []string
+		current []sentence
+		length  int
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		parts = append(parts, joinSentences(current))
+
+		// Carry the trailing sentences that fit in the overlap
+		carried := 0
+		keep := len(current)
+		for keep > 0 && carried+current[keep-1].length+1 <= overlap {
+			keep--
+			carried += current[keep].length + 1
+		}
+		current = append([]sentence(nil), current[keep:]...)
+		length = carried
+	}
+
+	fresh := 0 // sentences in current that no earlier chunk holds
+	for i, s := range sentences {
+		for _, piece := range splitLong(s, size) {
+			newParagraph := len(current) > 0 && piece.paragraph != current[len(current)-1].paragraph
+			if fresh > 0 && newParagraph && length >= size/2 && length+1+paragraphLength(sentences[i:]) > size {
+				flush()
+				fresh = 0
+			}
+			if fresh > 0 && length+1+piece.length > size {
+				flush()
+				fresh = 0
+			}
+			if len(current) > 0 && length+1+piece.length > size {
+				// The overlap leaves no room for the sentence
+				current, length = nil, 0
+			}
+			if len(current) > 0 {
+				length++
+			}
+			current = append(current, piece)
+			length += piece.length
+			fresh++
+		}
+	}
+	if fresh > 0 {
+		flush()
+	}
+	return parts
+}
+
+// paragraphLength is the length of the paragraph sentences start with.
+func paragraphLength(sentences []sentence) int {
+	length := -1
+	for _, s := range sentences {
+		if s.paragraph != sentences[0].paragraph {
+			break
+		}
+		length += s.length + 1
+	}
+	return length
+}
+
+// splitLong cuts a sentence longer than size between words, into pieces
+// of at most size (or one word, if a word is longer). A sentence measured
+// in tokens is taken to spread them evenly over its characters.
+func splitLong(s sentence, size int) []sentence {
+	if s.length <= size {
+		return []sentence{s}
+	}
+	runes := utf8.RuneCountInString(s.text)
+	var pieces []sentence
+	var words []string
+	length := 0
+	for _, word := range strings.Fields(s.text) {
+		// The word's share of the sentence's length, rounded up; its
+		// characters when the sentence is measured in characters
+		n := (utf8.RuneCountInString(word)*s.length + runes - 1) / runes
+		if len(words) > 0 && length+1+n > size {
+			pieces = append(pieces, sentence{text: strings.Join(words, " "), paragraph: s.paragraph, length: length})
+			words, length = nil, 0
+		}
+		if len(words) > 0 {
+			length++
+		}
+		words = append(words, word)
+		length += n
+	}
+	return append(pieces, sentence{text: strings.Join(words, " "), paragraph: s.paragraph, length: length})
+}
+
+// joinSentences joins sentences with a space, or a blank line between
+// paragraphs.
+func joinSentences(sentences []sentence) string {
+	var b strings.Builder
+	for i, s := range sentences {
+		if i > 0 {
+			if s.paragraph != sentences[i-1].paragraph {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(s.text)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// DOCUMENT DELETION
+// ============================================================================
+// DELETE /documents/{id} removes a document from the whole pipeline: its
+// vectors are deleted by document_id (from the canary's shadow collection
+// too), then its uploaded file, and last its metadata row, whose deletion
+// the metadata service announces so the retrieval and agent caches drop
+// it. The row goes last so that a delete that fails part way can simply
+// be retried; each step tolerates what an earlier attempt removed.
+//
+// Only files in DATA_DIR are removed, and only when no other document of
+// the tenant was ingested from the same file; a file_path elsewhere on
+// the server, or a web page, is left alone. Deleting needs the admin or
+// service role.
+
+// DeleteResponse - What deleting a document removed
+type DeleteResponse struct {
+	Status         string `json:"status"`
+	DocumentID     string `json:"document_id"`
+	VectorsDeleted int    `json:"vectors_deleted"`
+	FileRemoved    bool   `json:"file_removed"`
+}
+
+// Delete a document's vectors, metadata and file
+func deleteDocumentHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "" || strings.Contains(id, "/") {
+		respondError(w, "Document ID required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	doc, err := fetchDocument(ctx, id)
+	if httpclient.IsStatus(err, http.StatusNotFound) {
+		respondError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondError(w, "Failed to read document: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	deleted, err := deleteDocumentVectors(ctx, doc)
+	if err != nil {
+		respondError(w, "Failed to delete vectors: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	removed := removeDocumentFile(ctx, doc)
+
+	err = httpClient.DoJSON(ctx, http.MethodDelete, METADATA_SERVICE_URL+"/documents/"+url.PathEscape(doc.ID), nil, nil, httpclient.Idempotent)
+	if err != nil && !httpclient.IsStatus(err, http.StatusNotFound) {
+		respondError(w, "Failed to delete metadata: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("🗑️  Deleted document %s (%s): %d vectors, file removed: %t", doc.ID, doc.Name, deleted, removed)
+
+	jsonResponse(w, DeleteResponse{
+		Status:         "deleted",
+		DocumentID:     doc.ID,
+		VectorsDeleted: deleted,
+		FileRemoved:    removed,
+	})
+}
+
+// removeDocumentFile removes doc's file when it is an upload in DATA_DIR
+// that no other document uses, reporting whether it did.
+func removeDocumentFile(ctx context.Context, doc Document) bool {
+	if !inDataDir(doc.FilePath) {
+		return false
+	}
+
+	var others struct {
+		Documents []Document `json:"documents"`
+	}
+	if err := httpClient.GetJSON(ctx, METADATA_SERVICE_URL+"/documents", &others); err != nil {
+		log.Printf("⚠️  Keeping %s, can't tell whether other documents use it: %v", doc.FilePath, err)
+		return false
+	}
+	for _, other := range others.Documents {
+		if other.ID != doc.ID && filepath.Clean(other.FilePath) == filepath.Clean(doc.FilePath) {
+			return false
+		}
+	}
+
+	if err := os.Remove(doc.FilePath); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove %s: %v", doc.FilePath, err)
+		}
+		return false
+	}
+	return true
+}
+
+// inDataDir reports whether path names a file inside DATA_DIR. Both are
+// resolved through symlinks first, so neither "../" nor a link in DATA_DIR
+// to somewhere else gets out of it.
+func inDataDir(path string) bool {
+	rel, ok := dataDirRel(path)
+	return ok && rel != "."
+}
+
+// dataDirRel is path relative to DATA_DIR, both resolved through symlinks;
+// ok is false when path is outside DATA_DIR or can't be resolved.
+func dataDirRel(path string) (rel string, ok bool) {
+	if path == "" {
+		return "", false
+	}
+	dir, err := resolvePath(DATA_DIR)
+	if err != nil {
+		return "", false
+	}
+	file, err := resolvePath(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err = filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// resolvePath is the absolute path of path with its symlinks evaluated. A
+// path that doesn't exist yet is resolved as far as it does, the rest
+// joined on; a broken symlink is an error, as where it leads is unknown.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, statErr := os.Lstat(abs); statErr == nil {
+			return "", err // a broken symlink
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(abs), rest)
+		abs = parent
+	}
+}
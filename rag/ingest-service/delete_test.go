@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveDocuments fakes the metadata service's GET /documents.
+func serveDocuments(t *testing.T, docs []Document) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"documents": docs, "count": len(docs)})
+	}))
+	t.Cleanup(srv.Close)
+
+	previous := METADATA_SERVICE_URL
+	METADATA_SERVICE_URL = srv.URL
+	t.Cleanup(func() { METADATA_SERVICE_URL = previous })
+}
+
+// useDataDir points DATA_DIR at a fresh directory for the test.
+func useDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous := DATA_DIR
+	DATA_DIR = dir
+	t.Cleanup(func() { DATA_DIR = previous })
+	return dir
+}
+
+func TestRemoveDocumentFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		others  []Document
+		removed bool
+	}{
+		{name: "unused", others: nil, removed: true},
+		{name: "shared", others: []Document{{ID: "doc-2"}}, removed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(useDataDir(t), "circular.txt")
+			if err := os.WriteFile(path, []byte("text"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc := Document{ID: "doc-1", FilePath: path}
+			docs := []Document{doc}
+			for _, other := range tt.others {
+				other.FilePath = path
+				docs = append(docs, other)
+			}
+			serveDocuments(t, docs)
+
+			if got := removeDocumentFile(context.Background(), doc); got != tt.removed {
+				t.Fatalf("removeDocumentFile = %t, want %t", got, tt.removed)
+			}
+			_, err := os.Stat(path)
+			if exists := err == nil; exists == tt.removed {
+				t.Errorf("file exists = %t after removeDocumentFile returned %t", exists, tt.removed)
+			}
+		})
+	}
+}
+
+func TestRemoveDocumentFileOutsideDataDir(t *testing.T) {
+	useDataDir(t)
+	path := filepath.Join(t.TempDir(), "elsewhere.txt")
+	if err := os.WriteFile(path, []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	serveDocuments(t, nil)
+
+	if removeDocumentFile(context.Background(), Document{ID: "doc-1", FilePath: path}) {
+		t.Fatal("removed a file outside DATA_DIR")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file outside DATA_DIR is gone: %v", err)
+	}
+}
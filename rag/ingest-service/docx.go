@@ -0,0 +1,165 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// WORD DOCUMENTS
+// ============================================================================
+// Merchant agreements and board resolutions mostly arrive as Word files.
+// A .docx is read directly: the text of each paragraph in word/document.xml
+// (table cells included) becomes one paragraph of the extracted text, and
+// paragraphs are separated by a blank line so chunking sees the same
+// boundaries a PDF's pages give it. Tabs and line breaks inside a paragraph
+// are kept; headers, footers, comments and deleted revisions are not.
+//
+// A legacy .doc is first converted to .docx with LibreOffice
+// (DOC_CONVERTER, "soffice" by default) in a temporary directory. Without
+// LibreOffice installed, .doc files are rejected with an error saying so.
+
+var (
+	DOC_CONVERTER = getEnv("DOC_CONVERTER", "soffice")
+
+	docConvertTimeout = 2 * time.Minute
+)
+
+// wordNS is the namespace of WordprocessingML's elements.
+const wordNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+func extractTextFromDOCX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open DOCX: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", fmt.Errorf("cannot read DOCX body: %w", err)
+		}
+		defer rc.Close()
+
+		paragraphs, err := docxParagraphs(rc)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse DOCX body: %w", err)
+		}
+		if len(paragraphs) == 0 {
+			return "", fmt.Errorf("no extractable text found")
+		}
+		return strings.Join(paragraphs, "\n\n") + "\n", nil
+	}
+	return "", fmt.Errorf("not a Word document: word/document.xml is missing")
+}
+
+// docxParagraphs reads the text of each non-empty paragraph of a
+// word/document.xml.
+func docxParagraphs(r io.Reader) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	var (
+		paragraphs []string
+		current    strings.Builder
+		inText     bool
+		skip       int // depth inside elements whose text isn't the document's
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return paragraphs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			if skip > 0 || t.Name.Local == "del" || t.Name.Local == "instrText" {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "p":
+				current.Reset()
+			case "t":
+				inText = true
+			case "tab":
+				current.WriteByte('\t')
+			case "br", "cr":
+				current.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if p := cleanText(current.String()); p != "" {
+					paragraphs = append(paragraphs, p)
+				}
+				current.Reset()
+			}
+		case xml.CharData:
+			if inText && skip == 0 {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// extractTextFromDOC converts a legacy .doc to .docx with LibreOffice and
+// extracts that.
+func extractTextFromDOC(path string) (string, error) {
+	converter, err := exec.LookPath(DOC_CONVERTER)
+	if err != nil {
+		return "", fmt.Errorf(".doc files need LibreOffice to convert them (%s not found); save the document as .docx instead", DOC_CONVERTER)
+	}
+
+	outDir, err := os.MkdirTemp("", "ingest-doc-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(outDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), docConvertTimeout)
+	defer cancel()
+
+	// A private profile keeps concurrent conversions from contending for
+	// LibreOffice's lock on the user's default one.
+	cmd := exec.CommandContext(ctx, converter,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(outDir, "profile")),
+		"--headless", "--convert-to", "docx", "--outdir", outDir, path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("converting .doc failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	converted := filepath.Join(outDir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".docx")
+	if _, err := os.Stat(converted); err != nil {
+		return "", fmt.Errorf("converting .doc produced no .docx")
+	}
+	return extractTextFromDOCX(converted)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"protos/gorillapb"
+	"shared/rpc"
+)
+
+// gRPC clients for the embedding and vector hops, enabled by
+// EMBED_GRPC_ADDR / VECTOR_GRPC_ADDR. Without them ingestion uses HTTP/JSON.
+var (
+	embedClient  gorillapb.EmbeddingServiceClient
+	vectorClient gorillapb.VectorServiceClient
+
+	grpcCallTimeout = 2 * time.Minute
+)
+
+func initGRPCClients() {
+	if addr := getEnv("EMBED_GRPC_ADDR", ""); addr != "" {
+		conn, err := rpc.Dial(addr)
+		if err != nil {
+			log.Fatalf("Failed to dial embed-service gRPC: %v", err)
+		}
+		embedClient = gorillapb.NewEmbeddingServiceClient(conn)
+		log.Printf("Using embed-service gRPC at %s", addr)
+	}
+	if addr := getEnv("VECTOR_GRPC_ADDR", ""); addr != "" {
+		conn, err := rpc.Dial(addr)
+		if err != nil {
+			log.Fatalf("Failed to dial vector-service gRPC: %v", err)
+		}
+		vectorClient = gorillapb.NewVectorServiceClient(conn)
+		log.Printf("Using vector-service gRPC at %s", addr)
+	}
+}
+
+func getEmbeddingsGRPC(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcCallTimeout)
+	defer cancel()
+
+	resp, err := embedClient.EmbedBatch(ctx, &gorillapb.EmbedBatchRequest{Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(resp.GetEmbeddings()))
+	for i, emb := range resp.GetEmbeddings() {
+		embeddings[i] = emb.GetValues()
+	}
+	return embeddings, nil
+}
+
+func storeVectorsGRPC(ctx context.Context, collection string, chunks []Chunk, embeddings [][]float32) error {
+	ctx, cancel := context.WithTimeout(ctx, grpcCallTimeout)
+	defer cancel()
+
+	points := make([]*gorillapb.Point, len(chunks))
+	for i, c := range chunks {
+		payload, err := structpb.NewStruct(c.payload())
+		if err != nil {
+			return fmt.Errorf("invalid payload for chunk %s: %w", c.ID, err)
+		}
+		points[i] = &gorillapb.Point{
+			Id:      c.ID,
+			Vector:  &gorillapb.Vector{Values: embeddings[i]},
+			Payload: payload,
+		}
+	}
+
+	_, err := vectorClient.Upsert(ctx, &gorillapb.UpsertRequest{
+		Collection: collection,
+		Points:     points,
+	})
+	return err
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"shared/tenant"
+)
+
+// ============================================================================
+// INGESTION JOBS
+// ============================================================================
+// A large PDF can take longer to chunk, embed and store than a client or
+// proxy will hold a connection open. With "async": true, /ingest and
+// /ingest-url answer 202 at once with a job and run the pipeline in the
+// background; GET /jobs/{id} reports it. A job moves queued → running →
+// completed, or failed, and lists each stage of the pipeline with its own
+// status, count and error:
+//
+//	extract / fetch  characters of text extracted from the file or page
+//	metadata         the document's record in the metadata service
+//	chunk            chunks cut from the text
+//	embed            chunks embedded so far
+//	store            chunks stored in the vector service so far
+//
+// The job carries the document_id once the document is recorded, and the
+// ingest response once it completes. A job checkpoints its progress as it
+// goes, so one that fails or is cut short by a restart can resume (see
+// checkpoint.go). Jobs run on their own worker pool:
+//
+//	INGEST_JOB_WORKERS      jobs run at once (default 2)
+//	INGEST_JOB_QUEUE_DEPTH  jobs waiting for a worker before 503 (default 100)
+//	INGEST_JOB_TIMEOUT      time a job may wait and run (default 30m)
+//	INGEST_JOB_TTL          how long a finished job stays readable (default 1h)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+
+	stagePending = "pending"
+)
+
+const (
+	stageExtract  = "extract"
+	stageFetch    = "fetch"
+	stageMetadata = "metadata"
+	stageChunk    = "chunk"
+	stageEmbed    = "embed"
+	stageStore    = "store"
+)
+
+var (
+	fileStages = []string{stageExtract, stageMetadata, stageChunk, stageEmbed, stageStore}
+	pageStages = []string{stageFetch, stageMetadata, stageChunk, stageEmbed, stageStore}
+)
+
+// IngestJob - An ingestion run in the background
+type IngestJob struct {
+	ID           string          `json:"id"`
+	Status       string          `json:"status"`
+	DocumentID   string          `json:"document_id,omitempty"`
+	DocumentName string          `json:"document_name,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+	Stages       []JobStage      `json:"stages"`
+	Result       *IngestResponse `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+
+	tenantID string
+	ctx      context.Context
+	cp       *checkpoint
+}
+
+// JobStage - One stage of a job's pipeline
+type JobStage struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Count       int        `json:"count,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ingestProgress reports a running ingestion's stages to its job. A nil
+// progress, for a synchronous ingest, reports nothing.
+type ingestProgress struct {
+	queue *ingestJobQueue
+	job   *IngestJob
+}
+
+func (p *ingestProgress) start(name string) {
+	p.stage(name, func(s *JobStage) {
+		now := time.Now()
+		s.Status = jobRunning
+		s.StartedAt = &now
+	})
+}
+
+// advance records the stage's count so far.
+func (p *ingestProgress) advance(name string, count int) {
+	p.stage(name, func(s *JobStage) { s.Count = count })
+}
+
+func (p *ingestProgress) finish(name string, count int) {
+	p.stage(name, func(s *JobStage) {
+		now := time.Now()
+		s.Status = jobCompleted
+		s.Count = count
+		s.CompletedAt = &now
+	})
+}
+
+// fail records err on the stage and returns it.
+func (p *ingestProgress) fail(name string, err error) error {
+	p.stage(name, func(s *JobStage) {
+		now := time.Now()
+		s.Status = jobFailed
+		s.Error = err.Error()
+		s.CompletedAt = &now
+	})
+	return err
+}
+
+// document records the document the job is ingesting.
+func (p *ingestProgress) document(doc Document) {
+	if p == nil {
+		return
+	}
+	p.queue.update(func() {
+		p.job.DocumentID = doc.ID
+		p.job.DocumentName = doc.Name
+	})
+}
+
+// chunked checkpoints the document's chunks, before any is embedded.
+func (p *ingestProgress) chunked(doc Document, chunks []Chunk) {
+	if p == nil {
+		return
+	}
+	p.queue.mu.RLock()
+	p.job.cp.Characters = p.job.Stages[0].Count
+	p.queue.mu.RUnlock()
+	p.job.cp.Document = &doc
+	p.job.cp.Chunks = chunks
+	p.job.cp.Stored = 0
+	p.job.cp.save()
+}
+
+// stored checkpoints the chunks embedded and stored so far.
+func (p *ingestProgress) stored(count int) {
+	if p == nil {
+		return
+	}
+	p.job.cp.Stored = count
+	p.job.cp.save()
+}
+
+func (p *ingestProgress) stage(name string, fn func(*JobStage)) {
+	if p == nil {
+		return
+	}
+	p.queue.update(func() {
+		for i := range p.job.Stages {
+			if p.job.Stages[i].Name == name {
+				fn(&p.job.Stages[i])
+				return
+			}
+		}
+	})
+}
+
+// ingestJobQueue runs jobs on a fixed pool of workers and keeps them
+// readable until INGEST_JOB_TTL after they finish.
+type ingestJobQueue struct {
+	mu      sync.RWMutex
+	jobs    map[string]*IngestJob // keyed by jobKey(tenant, job ID)
+	pending chan *IngestJob
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+var ingestJobs *ingestJobQueue
+
+func newIngestJobQueue() *ingestJobQueue {
+	q := &ingestJobQueue{
+		jobs:    make(map[string]*IngestJob),
+		pending: make(chan *IngestJob, max(envInt("INGEST_JOB_QUEUE_DEPTH", 100), 0)),
+		timeout: envDuration("INGEST_JOB_TIMEOUT", 30*time.Minute),
+		ttl:     envDuration("INGEST_JOB_TTL", time.Hour),
+	}
+	for i := 0; i < max(envInt("INGEST_JOB_WORKERS", 2), 1); i++ {
+		go q.work()
+	}
+	go q.sweep()
+	return q
+}
+
+func jobKey(tenantID, jobID string) string {
+	return tenantID + "/" + jobID
+}
+
+// Submit queues cp's ingestion to run in the background. The job keeps
+// ctx's values (tenant, trace) but not its cancellation, so it outlives the
+// request. It returns false when the queue is full.
+func (q *ingestJobQueue) Submit(ctx context.Context, cp *checkpoint) (*IngestJob, bool) {
+	cp.JobID = uuid.New().String()
+	cp.TenantID = tenant.FromContext(ctx)
+	cp.CreatedAt = time.Now()
+	job := newIngestJob(context.WithoutCancel(ctx), cp)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- job:
+	default:
+		return nil, false
+	}
+	q.jobs[jobKey(job.tenantID, job.ID)] = job
+	cp.save()
+	copied := job.snapshot()
+	return &copied, true
+}
+
+// newIngestJob is the queued job for cp, with the stages its checkpoint
+// already covers completed.
+func newIngestJob(ctx context.Context, cp *checkpoint) *IngestJob {
+	job := &IngestJob{
+		ID:           cp.JobID,
+		Status:       jobQueued,
+		DocumentName: cp.Request.DocumentName,
+		CreatedAt:    cp.CreatedAt,
+		tenantID:     cp.TenantID,
+		ctx:          ctx,
+		cp:           cp,
+	}
+	if cp.Reingest != nil {
+		job.DocumentID = cp.Reingest.ID
+	}
+	stages := fileStages
+	if cp.URL != "" {
+		stages = pageStages
+	}
+	job.Stages = make([]JobStage, len(stages))
+	for i, name := range stages {
+		job.Stages[i] = JobStage{Name: name, Status: stagePending}
+	}
+	if cp.Document != nil {
+		job.DocumentID = cp.Document.ID
+		job.DocumentName = cp.Document.Name
+		for i := range job.Stages[:3] {
+			job.Stages[i].Status = jobCompleted
+		}
+		job.Stages[0].Count = cp.Characters
+		job.Stages[2].Count = len(cp.Chunks)
+		job.Stages[3].Count = cp.Stored
+		job.Stages[4].Count = cp.Stored
+	}
+	return job
+}
+
+// Resume requeues the tenant's failed job from its checkpoint. It returns
+// nil when there is no such job and false when the job isn't failed or the
+// queue is full.
+func (q *ingestJobQueue) Resume(tenantID, jobID string) (*IngestJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	failed, ok := q.jobs[jobKey(tenantID, jobID)]
+	if !ok {
+		return nil, false
+	}
+	if failed.Status != jobFailed {
+		copied := failed.snapshot()
+		return &copied, false
+	}
+
+	failed.cp.Failed, failed.cp.Error = false, ""
+	job := newIngestJob(failed.ctx, failed.cp)
+	select {
+	case q.pending <- job:
+	default:
+		copied := failed.snapshot()
+		return &copied, false
+	}
+	q.jobs[jobKey(tenantID, jobID)] = job
+	failed.cp.save()
+	copied := job.snapshot()
+	return &copied, true
+}
+
+// Get returns a copy of the tenant's job, or nil if there is none.
+func (q *ingestJobQueue) Get(tenantID, jobID string) *IngestJob {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	job, ok := q.jobs[jobKey(tenantID, jobID)]
+	if !ok {
+		return nil
+	}
+	copied := job.snapshot()
+	return &copied
+}
+
+// snapshot copies the job so it can be encoded without holding the lock.
+func (j *IngestJob) snapshot() IngestJob {
+	copied := *j
+	copied.Stages = append([]JobStage{}, j.Stages...)
+	return copied
+}
+
+func (q *ingestJobQueue) work() {
+	for job := range q.pending {
+		q.runJob(job)
+	}
+}
+
+func (q *ingestJobQueue) runJob(job *IngestJob) {
+	ctx, cancel := context.WithTimeout(job.ctx, q.timeout)
+	defer cancel()
+
+	started := time.Now()
+	q.update(func() {
+		job.Status = jobRunning
+		job.StartedAt = &started
+	})
+	response, err := q.execute(ctx, job)
+
+	completed := time.Now()
+	q.update(func() {
+		job.CompletedAt = &completed
+		if err != nil {
+			job.Status = jobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = jobCompleted
+		job.Result = &response
+	})
+	if err != nil {
+		job.cp.Failed, job.cp.Error = true, err.Error()
+		job.cp.FailedAt = completed
+		job.cp.save()
+	} else {
+		job.cp.remove()
+	}
+	log.Printf("📋 Ingest job %s %s in %s", job.ID, job.Status, completed.Sub(started).Round(time.Millisecond))
+}
+
+// execute runs the job's ingestion, turning a panic or timeout into an
+// error.
+func (q *ingestJobQueue) execute(ctx context.Context, job *IngestJob) (response IngestResponse, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("❌ Ingest job %s panicked: %v", job.ID, p)
+			err = fmt.Errorf("internal error")
+		}
+	}()
+	response, err = job.cp.run(ctx, &ingestProgress{queue: q, job: job})
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return response, fmt.Errorf("job timed out after %s: %w", q.timeout, err)
+	}
+	return response, err
+}
+
+func (q *ingestJobQueue) update(fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fn()
+}
+
+// sweep forgets finished jobs once their TTL has passed.
+func (q *ingestJobQueue) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		q.mu.Lock()
+		for key, job := range q.jobs {
+			if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > q.ttl {
+				delete(q.jobs, key)
+				job.cp.remove()
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// submitIngestJob answers an async ingest with its queued job.
+func submitIngestJob(w http.ResponseWriter, r *http.Request, cp *checkpoint) {
+	job, ok := ingestJobs.Submit(r.Context(), cp)
+	if !ok {
+		w.Header().Set("Retry-After", "30")
+		respondError(w, "Job queue is full, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Printf("📋 Ingest job %s queued: %s", job.ID, job.DocumentName)
+	respondJob(w, job)
+}
+
+func respondJob(w http.ResponseWriter, job *IngestJob) {
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	jsonResponse(w, job)
+}
+
+// Get an ingestion job's stages and, once completed, its result
+func ingestJobHandler(w http.ResponseWriter, r *http.Request) {
+	if jobID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/resume"); ok {
+		resumeJobHandler(w, r, jobID)
+		return
+	}
+	if r.Method != http.MethodGet {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if jobID == "" {
+		respondError(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	job := ingestJobs.Get(tenant.FromContext(r.Context()), jobID)
+	if job == nil {
+		respondError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	jsonResponse(w, job)
+}
+
+// Resume a failed ingestion job from its last checkpoint
+func resumeJobHandler(w http.ResponseWriter, r *http.Request, jobID string) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := ingestJobs.Resume(tenant.FromContext(r.Context()), jobID)
+	switch {
+	case job == nil:
+		respondError(w, "Job not found", http.StatusNotFound)
+		return
+	case !ok && job.Status == jobFailed:
+		w.Header().Set("Retry-After", "30")
+		respondError(w, "Job queue is full, try again later", http.StatusServiceUnavailable)
+		return
+	case !ok:
+		respondError(w, "Only a failed job can be resumed", http.StatusConflict)
+		return
+	}
+
+	log.Printf("📋 Ingest job %s resumed", job.ID)
+	respondJob(w, job)
+}
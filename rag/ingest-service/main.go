@@ -1,21 +1,39 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/ledongthuc/pdf"
+	"shared/admission"
+	"shared/auth"
+	"shared/events"
+	"shared/faults"
+	"shared/httpclient"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/ratelimit"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
 
 type Document struct {
 	ID         string    `json:"id"`
@@ -23,22 +41,36 @@ type Document struct {
 	Type       string    `json:"type"`
 	FilePath   string    `json:"file_path"`
 	Status     string    `json:"status"`
+	TenantID   string    `json:"tenant_id"`
 	UploadedAt time.Time `json:"uploaded_at"`
 }
 
 type Chunk struct {
-	ID         string `json:"id"`
-	DocumentID string `json:"document_id"`
-	Text       string `json:"text"`
-	Position   int    `json:"position"`
+	ID           string                 `json:"id"`
+	DocumentID   string                 `json:"document_id"`
+	TenantID     string                 `json:"tenant_id"`
+	Text         string                 `json:"text"`
+	Position     int                    `json:"position"`
+	DocumentType string                 `json:"document_type"`
+	UploadedAt   int64                  `json:"uploaded_at"` // Unix seconds, so retrieval can filter on a date range
+	Tags         []string               `json:"tags,omitempty"`
+	EffectiveAt  int64                  `json:"effective_date,omitempty"` // Unix seconds; retrieval prefers it to uploaded_at for recency
+	SourceURL    string                 `json:"source_url,omitempty"`     // the web page the document was fetched from, for /ingest-url
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`     // the request's metadata, stored in the payload beside the fields above
 }
 
 type IngestRequest struct {
-	DocumentName string `json:"document_name"`
-	DocumentType string `json:"document_type"`
-	FilePath     string `json:"file_path"`
-	ChunkSize    int    `json:"chunk_size"`
-	ChunkOverlap int    `json:"chunk_overlap"`
+	DocumentName  string                 `json:"document_name"`
+	DocumentType  string                 `json:"document_type"`
+	Tags          []string               `json:"tags"`           // stored on every chunk for retrieval filters
+	EffectiveDate string                 `json:"effective_date"` // when the document takes effect, YYYY-MM-DD or RFC 3339
+	Metadata      map[string]interface{} `json:"metadata"`       // stored on every chunk, for retrieval's payload filters and field matches
+	FilePath      string                 `json:"file_path"`
+	ChunkSize     int                    `json:"chunk_size"`
+	ChunkOverlap  int                    `json:"chunk_overlap"`
+	ChunkTokens   int                    `json:"chunk_size_tokens"` // sizes chunks in tokens instead (see tokens.go)
+	ChunkStrategy string                 `json:"chunking_strategy"` // "sentence" (default) or "semantic"
+	Async         bool                   `json:"async"`             // answer 202 with a job to poll at /jobs/{id} instead of waiting
 }
 
 type IngestResponse struct {
@@ -57,6 +89,15 @@ var (
 	VECTOR_SERVICE_URL   = getEnv("VECTOR_SERVICE_URL", "http://localhost:8082")
 	METADATA_SERVICE_URL = getEnv("METADATA_SERVICE_URL", "http://localhost:8083")
 	DATA_DIR             = getEnv("DATA_DIR", "./data/docs")
+
+	// INGEST_BATCH_SIZE chunks are embedded and stored at a time.
+	INGEST_BATCH_SIZE = max(envInt("INGEST_BATCH_SIZE", 100), 1)
+
+	eventBus events.Bus
+
+	// httpClient carries every HTTP call to the services above; batch
+	// embedding of large documents needs a generous per-attempt timeout.
+	httpClient = httpclient.New(httpclient.Options{Timeout: 2 * time.Minute})
 )
 
 // ============================================================================
@@ -64,17 +105,86 @@ var (
 // ============================================================================
 
 func main() {
+	shutdownTracing, err := tracing.Init("ingest-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	httpclient.ConfigureDefaultTransport()
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("ingest-service", EMBED_SERVICE_URL, VECTOR_SERVICE_URL, METADATA_SERVICE_URL)
+
 	if err := os.MkdirAll(DATA_DIR, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
+	if err := os.MkdirAll(INGEST_JOB_DIR, 0755); err != nil {
+		log.Fatalf("Failed to create job directory: %v", err)
+	}
+
+	eventBus, err = events.Connect("ingest-service")
+	if err != nil {
+		log.Fatalf("Failed to connect to event bus: %v", err)
+	}
+	defer eventBus.Close()
+
+	limiter, err := ratelimit.New()
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+	defer limiter.Close()
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
+	initGRPCClients()
+	ingestJobs = newIngestJobQueue()
+	ingestJobs.restore()
 
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("ingest-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("ingest-service", map[string]server.Check{
+		"embed-service":    server.HTTPCheck(EMBED_SERVICE_URL + "/healthz"),
+		"vector-service":   server.HTTPCheck(VECTOR_SERVICE_URL + "/healthz"),
+		"metadata-service": server.HTTPCheck(METADATA_SERVICE_URL + "/healthz"),
+	}))
 	http.HandleFunc("/upload", uploadHandler)
-	http.HandleFunc("/ingest", ingestHandler)
+	ingestGate := admission.New("ingest-service", "ingest", admission.ConfigFromEnv("INGEST", admission.Config{
+		Workers:    4,
+		QueueDepth: 16,
+		MaxWait:    30 * time.Second,
+	}))
+	http.HandleFunc("/ingest", ingestGate.Wrap(ingestHandler))
+	http.HandleFunc("/ingest-url", ingestGate.Wrap(ingestURLHandler))
+	http.HandleFunc("/ingest-batch", ingestGate.Wrap(ingestBatchHandler))
+	http.HandleFunc("/jobs/", ingestJobHandler)
+	http.HandleFunc("/documents/", ingestGate.Wrap(documentHandler))
 
 	port := getEnv("PORT", "8080")
 	log.Printf("Ingest Service running on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("ingest-service", handler)
+	handler = ratelimit.Wrap("ingest-service", limiter, ratelimit.ConfigFromEnv(), handler)
+	handler = metrics.Wrap("ingest-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Method: http.MethodPost, Path: "/upload", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/ingest", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/ingest-url", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/ingest-batch", Roles: auth.Writers},
+		{Method: http.MethodPost, Path: "/jobs/", Roles: auth.Writers},
+		{Method: http.MethodPut, Path: "/documents/", Roles: auth.Writers},
+		{Method: http.MethodDelete, Path: "/documents/", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // ============================================================================
@@ -145,27 +255,86 @@ func ingestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ChunkSize == 0 {
-		req.ChunkSize = 500
+	effectiveAt, err := prepareIngest(&req)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if req.ChunkOverlap == 0 {
-		req.ChunkOverlap = 50
+
+	if req.Async {
+		submitIngestJob(w, r, &checkpoint{Request: req, EffectiveAt: effectiveAt})
+		return
 	}
 
+	resp, err := ingestFile(r.Context(), req, effectiveAt, nil)
+	if err != nil {
+		respondIngestError(w, err)
+		return
+	}
+	jsonResponse(w, resp)
+}
+
+// ingestFile extracts the text of req's file and ingests it.
+func ingestFile(ctx context.Context, req IngestRequest, effectiveAt int64, prog *ingestProgress) (IngestResponse, error) {
 	log.Printf("Ingesting document: %s", req.DocumentName)
 
-	// --- PDF/TXT extraction
-	text, err := extractText(req.FilePath)
+	text, err := extractFile(req.FilePath, prog)
 	if err != nil {
-		respondError(w, "Failed to extract text: "+err.Error(), http.StatusBadRequest)
-		return
+		return IngestResponse{}, err
+	}
+
+	return ingestText(ctx, req, text, effectiveAt, "", prog)
+}
+
+// extractFile is the extract stage: the text of the file at path.
+func extractFile(path string, prog *ingestProgress) (string, error) {
+	// --- PDF/DOCX/TXT extraction
+	prog.start(stageExtract)
+	text, err := extractText(path)
+	if err != nil {
+		return "", prog.fail(stageExtract, &ingestError{http.StatusBadRequest, "Failed to extract text: " + err.Error()})
 	}
 
 	if len(strings.TrimSpace(text)) < 10 {
-		respondError(w, "No readable text found in the document", http.StatusBadRequest)
-		return
+		return "", prog.fail(stageExtract, &ingestError{http.StatusBadRequest, "No readable text found in the document"})
 	}
+	prog.finish(stageExtract, utf8.RuneCountInString(text))
+	return text, nil
+}
 
+// prepareIngest fills in req's chunking defaults and checks the rest,
+// returning its effective date as Unix seconds.
+func prepareIngest(req *IngestRequest) (int64, error) {
+	if req.ChunkSize == 0 {
+		req.ChunkSize = 500
+	}
+	if req.ChunkOverlap == 0 {
+		req.ChunkOverlap = 50
+	}
+	if req.ChunkTokens < 0 || req.ChunkTokens > INGEST_MAX_CHUNK_TOKENS {
+		return 0, fmt.Errorf("chunk_size_tokens must be between 1 and %d", INGEST_MAX_CHUNK_TOKENS)
+	}
+	switch req.ChunkStrategy {
+	case "", chunkingSentence, chunkingSemantic:
+	default:
+		return 0, fmt.Errorf("chunking_strategy must be %q or %q", chunkingSentence, chunkingSemantic)
+	}
+	effectiveAt, err := parseEffectiveDate(req.EffectiveDate)
+	if err != nil {
+		return 0, err
+	}
+	for field := range req.Metadata {
+		if reservedPayloadFields[field] {
+			return 0, fmt.Errorf("metadata cannot set the %q payload field", field)
+		}
+	}
+	return effectiveAt, nil
+}
+
+// ingestText records, chunks, embeds and stores the text extracted for
+// req, reporting each stage to prog. sourceURL is the page the text came
+// from, if any.
+func ingestText(ctx context.Context, req IngestRequest, text string, effectiveAt int64, sourceURL string, prog *ingestProgress) (IngestResponse, error) {
 	// --- Create metadata
 	doc := Document{
 		ID:         uuid.New().String(),
@@ -173,42 +342,103 @@ func ingestHandler(w http.ResponseWriter, r *http.Request) {
 		Type:       req.DocumentType,
 		FilePath:   req.FilePath,
 		Status:     "processing",
+		TenantID:   tenant.FromContext(ctx),
 		UploadedAt: time.Now(),
 	}
 
-	if err := saveDocumentMetadata(doc); err != nil {
-		respondError(w, "Failed to save metadata: "+err.Error(), http.StatusInternalServerError)
-		return
+	prog.start(stageMetadata)
+	if err := saveDocumentMetadata(ctx, doc); err != nil {
+		return IngestResponse{}, prog.fail(stageMetadata, fmt.Errorf("Failed to save metadata: %w", err))
 	}
+	prog.document(doc)
+	prog.finish(stageMetadata, 0)
 
-	// --- Chunk
-	chunks := chunkText(text, doc.ID, req.ChunkSize, req.ChunkOverlap)
-	log.Printf("Chunks created: %d", len(chunks))
+	publishEvent(ctx, events.IngestStarted, map[string]interface{}{
+		"document_id":   doc.ID,
+		"document_name": doc.Name,
+		"document_type": doc.Type,
+		"file_path":     doc.FilePath,
+	})
 
-	// --- Embed using embed-service
-	embeddings, err := getEmbeddings(chunks)
+	return chunkAndStore(ctx, req, doc, text, effectiveAt, sourceURL, prog)
+}
+
+// chunkAndStore cuts the document's text into chunks, then embeds and
+// stores them.
+func chunkAndStore(ctx context.Context, req IngestRequest, doc Document, text string, effectiveAt int64, sourceURL string, prog *ingestProgress) (IngestResponse, error) {
+	// --- Chunk
+	prog.start(stageChunk)
+	chunks, err := chunkDocument(ctx, req, text, doc.ID)
 	if err != nil {
-		updateDocumentStatus(doc.ID, "failed")
-		respondError(w, "Embedding failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		updateDocumentStatus(ctx, doc.ID, "failed")
+		publishIngestFailed(ctx, doc.ID, "chunk", err)
+		return IngestResponse{}, prog.fail(stageChunk, fmt.Errorf("Chunking failed: %w", err))
+	}
+	for i := range chunks {
+		chunks[i].TenantID = doc.TenantID
+		chunks[i].DocumentType = doc.Type
+		chunks[i].UploadedAt = doc.UploadedAt.Unix()
+		chunks[i].Tags = req.Tags
+		chunks[i].EffectiveAt = effectiveAt
+		chunks[i].SourceURL = sourceURL
+		chunks[i].Attributes = req.Metadata
 	}
+	log.Printf("Chunks created: %d", len(chunks))
+	prog.finish(stageChunk, len(chunks))
+	prog.chunked(doc, chunks)
 
-	// --- Store vectors
-	if err := storeVectors(chunks, embeddings, req.DocumentType); err != nil {
-		updateDocumentStatus(doc.ID, "failed")
-		respondError(w, "Vector storage failed: "+err.Error(), http.StatusInternalServerError)
-		return
+	return storeChunks(ctx, doc, chunks, 0, prog)
+}
+
+// storeChunks embeds and stores the document's chunks from done on, a
+// batch at a time, checkpointing after each batch (see checkpoint.go).
+func storeChunks(ctx context.Context, doc Document, chunks []Chunk, done int, prog *ingestProgress) (IngestResponse, error) {
+	prog.start(stageEmbed)
+	for start := done; start < len(chunks); start += INGEST_BATCH_SIZE {
+		batch := chunks[start:min(start+INGEST_BATCH_SIZE, len(chunks))]
+		end := start + len(batch)
+
+		// --- Embed using embed-service
+		embeddings, err := getEmbeddings(ctx, batch)
+		if err != nil {
+			updateDocumentStatus(ctx, doc.ID, "failed")
+			publishIngestFailed(ctx, doc.ID, "embed", err)
+			return IngestResponse{}, prog.fail(stageEmbed, fmt.Errorf("Embedding failed: %w", err))
+		}
+		prog.advance(stageEmbed, end)
+
+		// --- Store vectors
+		if start == done {
+			prog.start(stageStore)
+		}
+		if err := storeVectors(ctx, batch, embeddings, doc.Type); err != nil {
+			updateDocumentStatus(ctx, doc.ID, "failed")
+			publishIngestFailed(ctx, doc.ID, "store", err)
+			return IngestResponse{}, prog.fail(stageStore, fmt.Errorf("Vector storage failed: %w", err))
+		}
+		prog.advance(stageStore, end)
+		prog.stored(end)
 	}
+	prog.finish(stageEmbed, len(chunks))
+	prog.finish(stageStore, len(chunks))
 
-	updateDocumentStatus(doc.ID, "completed")
+	indexShadow(ctx, chunks, doc.Type)
+
+	updateDocumentStatus(ctx, doc.ID, "completed")
+	publishEvent(ctx, events.IngestCompleted, map[string]interface{}{
+		"document_id":   doc.ID,
+		"document_type": doc.Type,
+		"collection":    collectionForType(doc.Type),
+		"chunks":        len(chunks),
+	})
 
 	// --- Final response
-	jsonResponse(w, IngestResponse{
+	return IngestResponse{
 		DocumentID: doc.ID,
 		Status:     "completed",
 		Chunks:     len(chunks),
 		Message:    "Ingestion finished successfully",
-	})
+	}, nil
 }
 
 // ============================================================================
@@ -223,6 +453,10 @@ func extractText(filePath string) (string, error) {
 		return extractTextFromTXT(filePath)
 	case ".pdf":
 		return extractTextFromPDF(filePath)
+	case ".docx":
+		return extractTextFromDOCX(filePath)
+	case ".doc":
+		return extractTextFromDOC(filePath)
 	default:
 		return "", fmt.Errorf("unsupported file type: %s", ext)
 	}
@@ -286,66 +520,30 @@ func cleanText(s string) string {
 	return strings.Join(cleaned, "\n")
 }
 
-// ============================================================================
-// CHUNKING
-// ============================================================================
-
-func chunkText(text, docID string, size, overlap int) []Chunk {
-	var chunks []Chunk
-	runes := []rune(text)
-	pos := 0
-
-	for i := 0; i < len(runes); i += size - overlap {
-		end := i + size
-		if end > len(runes) {
-			end = len(runes)
-		}
-
-		part := strings.TrimSpace(string(runes[i:end]))
-		if len(part) == 0 {
-			continue
-		}
-
-		chunks = append(chunks, Chunk{
-			ID:         uuid.New().String(),
-			DocumentID: docID,
-			Text:       part,
-			Position:   pos,
-		})
-
-		pos++
-		if end >= len(runes) {
-			break
-		}
-	}
-
-	return chunks
-}
-
 // ============================================================================
 // EMBEDDING SERVICE CALL
 // ============================================================================
 
-func getEmbeddings(chunks []Chunk) ([][]float32, error) {
+func getEmbeddings(ctx context.Context, chunks []Chunk) ([][]float32, error) {
 	texts := make([]string, len(chunks))
 	for i, c := range chunks {
 		texts[i] = c.Text
 	}
+	return embedTexts(ctx, texts)
+}
 
-	body, _ := json.Marshal(map[string]interface{}{
-		"texts": texts,
-	})
-
-	resp, err := http.Post(EMBED_SERVICE_URL+"/embed-batch", "application/json", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+func embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if embedClient != nil {
+		return getEmbeddingsGRPC(ctx, texts)
 	}
-	defer resp.Body.Close()
 
 	var out struct {
 		Embeddings [][]float32 `json:"embeddings"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed-batch", map[string]interface{}{
+		"texts": texts,
+	}, &out, httpclient.Idempotent)
+	if err != nil {
 		return nil, err
 	}
 
@@ -356,70 +554,147 @@ func getEmbeddings(chunks []Chunk) ([][]float32, error) {
 // VECTOR SERVICE CALL
 // ============================================================================
 
-func storeVectors(chunks []Chunk, embeddings [][]float32, docType string) error {
+func collectionForType(docType string) string {
+	switch docType {
+	case "merchant":
+		return "merchant_docs"
+	case "kyc":
+		return "kyc_docs"
+	default:
+		return "regulatory_docs"
+	}
+}
+
+func storeVectors(ctx context.Context, chunks []Chunk, embeddings [][]float32, docType string) error {
+	return upsertVectors(ctx, collectionForType(docType), chunks, embeddings)
+}
+
+func upsertVectors(ctx context.Context, collection string, chunks []Chunk, embeddings [][]float32) error {
+	if vectorClient != nil {
+		return storeVectorsGRPC(ctx, collection, chunks, embeddings)
+	}
+
 	points := make([]map[string]interface{}, len(chunks))
 
 	for i, c := range chunks {
 		points[i] = map[string]interface{}{
-			"id":     c.ID,
-			"vector": embeddings[i],
-			"payload": map[string]interface{}{
-				"text":        c.Text,
-				"document_id": c.DocumentID,
-				"position":    c.Position,
-			},
+			"id":      c.ID,
+			"vector":  embeddings[i],
+			"payload": c.payload(),
 		}
 	}
 
-	collection := "regulatory_docs"
-	if docType == "merchant" {
-		collection = "merchant_docs"
-	} else if docType == "kyc" {
-		collection = "kyc_docs"
-	}
-
-	body, _ := json.Marshal(map[string]interface{}{
+	// Upserts are keyed by chunk ID, so a retried batch overwrites itself.
+	return httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/upsert", map[string]interface{}{
 		"collection": collection,
 		"points":     points,
-	})
+	}, nil, httpclient.Idempotent)
+}
 
-	resp, err := http.Post(VECTOR_SERVICE_URL+"/upsert", "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
+// payload is what the vector service stores with a chunk's vector.
+func (c Chunk) payload() map[string]interface{} {
+	tags := make([]interface{}, len(c.Tags))
+	for i, tag := range c.Tags {
+		tags[i] = tag
 	}
-	defer resp.Body.Close()
+	payload := map[string]interface{}{
+		"text":          c.Text,
+		"document_id":   c.DocumentID,
+		"tenant_id":     c.TenantID,
+		"position":      c.Position,
+		"document_type": c.DocumentType,
+		"uploaded_at":   c.UploadedAt,
+		"tags":          tags,
+	}
+	if c.EffectiveAt != 0 {
+		payload["effective_date"] = c.EffectiveAt
+	}
+	if c.SourceURL != "" {
+		payload["source_url"] = c.SourceURL
+	}
+	for field, value := range c.Attributes {
+		payload[field] = value
+	}
+	return payload
+}
 
-	return nil
+// reservedPayloadFields are written by ingestion, so a request's metadata
+// can't set them.
+var reservedPayloadFields = map[string]bool{
+	"text": true, "document_id": true, "tenant_id": true, "position": true,
+	"document_type": true, "uploaded_at": true, "tags": true, "effective_date": true, "source_url": true,
+}
+
+// parseEffectiveDate reads an effective date as Unix seconds, 0 when empty.
+func parseEffectiveDate(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("effective_date must be a date (YYYY-MM-DD) or an RFC 3339 time")
+	}
+	return t.Unix(), nil
 }
 
 // ============================================================================
 // METADATA SERVICE CALL
 // ============================================================================
 
-func saveDocumentMetadata(doc Document) error {
-	body, _ := json.Marshal(doc)
-	resp, err := http.Post(METADATA_SERVICE_URL+"/documents", "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	return nil
+func saveDocumentMetadata(ctx context.Context, doc Document) error {
+	return httpClient.PostJSON(ctx, METADATA_SERVICE_URL+"/documents", doc, nil)
 }
 
-func updateDocumentStatus(id, status string) error {
-	body, _ := json.Marshal(map[string]string{"status": status})
+// updateDocumentStatus ignores cancellation of ctx so a failed ingest is
+// still recorded after the client disconnects.
+func updateDocumentStatus(ctx context.Context, id, status string) error {
+	return httpClient.PutJSON(context.WithoutCancel(ctx), METADATA_SERVICE_URL+"/documents/"+id+"/status", map[string]string{"status": status}, nil)
+}
 
-	req, _ := http.NewRequest(http.MethodPut, METADATA_SERVICE_URL+"/documents/"+id+"/status", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+// ============================================================================
+// EVENTS
+// ============================================================================
 
-	_, err := http.DefaultClient.Do(req)
-	return err
+// publishEvent ignores cancellation of ctx; it only carries the tenant.
+func publishEvent(ctx context.Context, subject string, data map[string]interface{}) {
+	if err := eventBus.Publish(context.WithoutCancel(ctx), subject, data); err != nil {
+		log.Printf("Failed to publish %s: %v", subject, err)
+	}
+}
+
+func publishIngestFailed(ctx context.Context, docID, stage string, err error) {
+	publishEvent(ctx, events.IngestFailed, map[string]interface{}{
+		"document_id": docID,
+		"stage":       stage,
+		"error":       err.Error(),
+	})
 }
 
 // ============================================================================
 // HELPERS
 // ============================================================================
 
+// ingestError is an ingestion failure the request caused, with the status
+// it's answered with; any other failure is a 500.
+type ingestError struct {
+	status int
+	msg    string
+}
+
+func (e *ingestError) Error() string { return e.msg }
+
+func respondIngestError(w http.ResponseWriter, err error) {
+	var ie *ingestError
+	if errors.As(err, &ie) {
+		respondError(w, ie.msg, ie.status)
+		return
+	}
+	respondError(w, err.Error(), http.StatusInternalServerError)
+}
+
 func respondError(w http.ResponseWriter, msg string, code int) {
 	w.WriteHeader(code)
 	jsonResponse(w, map[string]string{"error": msg})
@@ -437,6 +712,20 @@ func getEnv(key, def string) string {
 	return def
 }
 
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
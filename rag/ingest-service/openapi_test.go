@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"shared/openapi"
+)
+
+// TestSpecMatchesTypes holds openapi.json to the types the handlers decode
+// and encode, so neither changes without the other.
+func TestSpecMatchesTypes(t *testing.T) {
+	spec := openapi.MustLoad(openAPISpec)
+	for name, v := range map[string]interface{}{
+		"IngestRequest":       IngestRequest{},
+		"IngestResponse":      IngestResponse{},
+		"IngestJob":           IngestJob{},
+		"JobStage":            JobStage{},
+		"DeleteResponse":      DeleteResponse{},
+		"BatchFileResult":     BatchFileResult{},
+		"BatchIngestResponse": BatchIngestResponse{},
+	} {
+		if err := spec.CheckType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A page's file_path is its URL, and a batch names each of its files
+	if err := spec.CheckType("IngestURLRequest", IngestURLRequest{}, "file_path"); err != nil {
+		t.Error(err)
+	}
+	if err := spec.CheckType("BatchIngestRequest", BatchIngestRequest{}, "document_name", "file_path"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"shared/events"
+	"shared/httpclient"
+)
+
+// ============================================================================
+// RE-INGESTION
+// ============================================================================
+// PUT /documents/{id}/reingest ingests a document again under the same ID,
+// after its file has changed or to chunk it differently, so citations,
+// bookmarks and anything else holding the ID stay valid. The document's
+// file (or, for one from /ingest-url, its page) is read again, its vectors
+// are deleted by document_id, and the new text is chunked, embedded and
+// stored as usual. Like the services it calls, it only finds documents of
+// the caller's tenant.
+//
+// The body is optional and takes the chunking options of /ingest, plus
+// tags, metadata and effective_date. Any of those three left out is carried
+// over from the document's current chunks. The name, type and file stay
+// the document's own. With "async": true it runs as a job like an ingest
+// (see jobs.go), with the old vectors deleted in its metadata stage.
+//
+// The text is read before anything is deleted, so a document whose file
+// has gone missing keeps its chunks. Between the delete and the last batch
+// stored, searches only find the part of the document stored so far.
+
+// documentHandler routes PUT /documents/{id}/reingest and, in delete.go,
+// DELETE /documents/{id}.
+func documentHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/documents/")
+	if id, ok := strings.CutSuffix(path, "/reingest"); ok {
+		reingestHandler(w, r, id)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	deleteDocumentHandler(w, r, path)
+}
+
+// Ingest a document again under its ID
+func reingestHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		respondError(w, "Document ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req IngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.DocumentName != "" || req.DocumentType != "" || req.FilePath != "" {
+		respondError(w, "document_name, document_type and file_path can't be changed by re-ingesting", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := fetchDocument(r.Context(), id)
+	if httpclient.IsStatus(err, http.StatusNotFound) {
+		respondError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondError(w, "Failed to read document: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	req.DocumentName, req.DocumentType, req.FilePath = doc.Name, doc.Type, doc.FilePath
+
+	effectiveAt, err := prepareIngest(&req)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	previous, err := currentPayload(r.Context(), doc)
+	if err != nil {
+		respondError(w, "Failed to read the document's chunks: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	effectiveAt = inheritPayload(&req, effectiveAt, previous)
+
+	if req.Async {
+		cp := &checkpoint{Request: req, EffectiveAt: effectiveAt, Reingest: &doc}
+		if pageURL := documentPage(doc); pageURL != nil {
+			cp.URL = pageURL.String()
+		}
+		submitIngestJob(w, r, cp)
+		return
+	}
+
+	resp, err := reingestDocument(r.Context(), doc, req, effectiveAt, nil)
+	if err != nil {
+		respondIngestError(w, err)
+		return
+	}
+	jsonResponse(w, resp)
+}
+
+// reingestDocument reads doc's file or page again and replaces its chunks
+// with those of the new text, keeping its ID.
+func reingestDocument(ctx context.Context, doc Document, req IngestRequest, effectiveAt int64, prog *ingestProgress) (IngestResponse, error) {
+	log.Printf("Re-ingesting document %s: %s", doc.ID, doc.Name)
+
+	var text, sourceURL string
+	var err error
+	if pageURL := documentPage(doc); pageURL != nil {
+		_, text, err = fetchPage(ctx, pageURL, prog)
+		sourceURL = pageURL.String()
+	} else {
+		text, err = extractFile(doc.FilePath, prog)
+	}
+	if err != nil {
+		return IngestResponse{}, err
+	}
+
+	// --- Remove the old vectors, keeping the metadata
+	prog.start(stageMetadata)
+	prog.document(doc)
+	deleted, err := deleteDocumentVectors(ctx, doc)
+	if err != nil {
+		return IngestResponse{}, prog.fail(stageMetadata, fmt.Errorf("Failed to delete the old vectors: %w", err))
+	}
+	log.Printf("Deleted %d old vectors of document %s", deleted, doc.ID)
+	updateDocumentStatus(ctx, doc.ID, "processing")
+	prog.finish(stageMetadata, 0)
+
+	publishEvent(ctx, events.IngestStarted, map[string]interface{}{
+		"document_id":   doc.ID,
+		"document_name": doc.Name,
+		"document_type": doc.Type,
+		"file_path":     doc.FilePath,
+		"reingest":      true,
+	})
+
+	return chunkAndStore(ctx, req, doc, text, effectiveAt, sourceURL, prog)
+}
+
+// documentPage is the page a document was fetched from by /ingest-url,
+// which records its URL as the file path, or nil for an uploaded file.
+func documentPage(doc Document) *url.URL {
+	u, err := url.Parse(doc.FilePath)
+	if err != nil || checkPageURL(u) != nil {
+		return nil
+	}
+	return u
+}
+
+// fetchDocument reads the caller's document from the metadata service.
+func fetchDocument(ctx context.Context, id string) (Document, error) {
+	var doc Document
+	err := httpClient.GetJSON(ctx, METADATA_SERVICE_URL+"/documents/"+url.PathEscape(id), &doc)
+	return doc, err
+}
+
+// currentPayload is the payload of one of doc's stored chunks, or nil when
+// it has none.
+func currentPayload(ctx context.Context, doc Document) (map[string]interface{}, error) {
+	var out struct {
+		Points []struct {
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"points"`
+	}
+	err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/scroll", map[string]interface{}{
+		"collection": collectionForType(doc.Type),
+		"limit":      1,
+		"filter":     map[string]interface{}{"document_id": doc.ID},
+	}, &out, httpclient.Idempotent)
+	if err != nil || len(out.Points) == 0 {
+		return nil, err
+	}
+	return out.Points[0].Payload, nil
+}
+
+// inheritPayload fills the tags, metadata and effective date req leaves
+// out from a chunk's payload, returning the effective date.
+func inheritPayload(req *IngestRequest, effectiveAt int64, payload map[string]interface{}) int64 {
+	if payload == nil {
+		return effectiveAt
+	}
+	if req.Tags == nil {
+		if tags, ok := payload["tags"].([]interface{}); ok {
+			req.Tags = make([]string, 0, len(tags))
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					req.Tags = append(req.Tags, s)
+				}
+			}
+		}
+	}
+	if req.Metadata == nil {
+		for field, value := range payload {
+			if reservedPayloadFields[field] {
+				continue
+			}
+			if req.Metadata == nil {
+				req.Metadata = make(map[string]interface{})
+			}
+			req.Metadata[field] = value
+		}
+	}
+	if req.EffectiveDate == "" {
+		if at, ok := payload["effective_date"].(float64); ok {
+			effectiveAt = int64(at)
+		}
+	}
+	return effectiveAt
+}
+
+// deleteDocumentVectors deletes doc's chunks from its collection, and
+// from the canary's shadow collection when there is one, returning how
+// many were deleted.
+func deleteDocumentVectors(ctx context.Context, doc Document) (int, error) {
+	filter := map[string]interface{}{"document_id": doc.ID}
+
+	var out struct {
+		Deleted int `json:"deleted"`
+	}
+	err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/delete", map[string]interface{}{
+		"collection": collectionForType(doc.Type),
+		"filter":     filter,
+	}, &out, httpclient.Idempotent)
+	if err != nil {
+		return 0, err
+	}
+
+	if CANARY_EMBED_MODEL != "" {
+		shadow := collectionForType(doc.Type) + CANARY_SHADOW_SUFFIX
+		err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/delete", map[string]interface{}{
+			"collection": shadow,
+			"filter":     filter,
+		}, nil, httpclient.Idempotent)
+		if err != nil {
+			log.Printf("⚠️  Failed to delete document %s from %s: %v", doc.ID, shadow, err)
+		}
+	}
+	return out.Deleted, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// SEMANTIC CHUNKING
+// ============================================================================
+// "chunking_strategy": "semantic" cuts chunks where the text changes
+// topic instead of where they fill up. Each sentence is embedded together
+// with its neighbours, and the text is cut between two sentences whose
+// embeddings are further apart than INGEST_SEMANTIC_PERCENTILE percent
+// (default 90) of the gaps between adjacent sentences in the document.
+// A section longer than chunk_size (or chunk_size_tokens) is then packed
+// into chunks of whole sentences as usual (see chunking.go). chunk_overlap
+// doesn't apply, since the chunks already end where the topic does.
+//
+// Every sentence is embedded, so a semantically chunked document costs
+// several times the embedding calls of one chunked by sentence. A document
+// of fewer than three sentences is chunked by sentence.
+
+var INGEST_SEMANTIC_PERCENTILE = min(max(envInt("INGEST_SEMANTIC_PERCENTILE", 90), 1), 99)
+
+// chunkSemantic cuts sentences into chunk texts where the topic shifts,
+// packing longer sections into chunks of about size.
+func chunkSemantic(ctx context.Context, sentences []sentence, size int) ([]string, error) {
+	if len(sentences) < 3 {
+		return packSentences(sentences, size, 0), nil
+	}
+
+	// Each sentence with the one either side of it, so a short sentence
+	// isn't judged on its own
+	windows := make([]string, len(sentences))
+	for i := range sentences {
+		from, to := max(i-1, 0), min(i+2, len(sentences))
+		parts := make([]string, 0, to-from)
+		for _, s := range sentences[from:to] {
+			parts = append(parts, s.text)
+		}
+		windows[i] = strings.Join(parts, " ")
+	}
+
+	embeddings := make([][]float32, 0, len(windows))
+	for start := 0; start < len(windows); start += INGEST_BATCH_SIZE {
+		batch, err := embedTexts(ctx, windows[start:min(start+INGEST_BATCH_SIZE, len(windows))])
+		if err != nil {
+			return nil, fmt.Errorf("embedding sentences: %w", err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	if len(embeddings) != len(windows) {
+		return nil, fmt.Errorf("embedding sentences: got %d embeddings for %d sentences", len(embeddings), len(windows))
+	}
+
+	gaps := make([]float64, len(sentences)-1)
+	for i := range gaps {
+		gaps[i] = 1 - cosineSimilarity(embeddings[i], embeddings[i+1])
+	}
+	threshold := percentile(gaps, INGEST_SEMANTIC_PERCENTILE)
+
+	var parts []string
+	start := 0
+	for i, gap := range gaps {
+		if gap > threshold {
+			parts = append(parts, packSentences(sentences[start:i+1], size, 0)...)
+			start = i + 1
+		}
+	}
+	parts = append(parts, packSentences(sentences[start:], size, 0)...)
+
+	log.Printf("Semantic chunking: %d sentences, break above %.3f", len(sentences), threshold)
+	return parts, nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile is the pth percentile of values, interpolating between the
+// two nearest.
+func percentile(values []float64, p int) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := float64(p) / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// TOKEN-SIZED CHUNKS
+// ============================================================================
+// "chunk_size_tokens" sizes chunks in the embedding model's tokens instead
+// of characters. text-embedding-004 reads at most 2048 tokens of a text and
+// silently drops the rest, and how many characters that is depends on the
+// language and the text: a chunk_size in characters that is safe for
+// English prose can overflow on tables, numbers or Hindi.
+//
+// Sentences are first packed by an estimate of their tokens, one per
+// charsPerToken characters (see chunking.go). Each chunk is then counted by
+// the embed service's /count-tokens, which asks the Gemini tokenizer, and a
+// chunk over chunk_size_tokens is packed again at the density measured for
+// it and its pieces counted, for up to fitRounds rounds. Only whole chunks
+// are counted, and each costs the embed service a Gemini API call, so a
+// document takes about as many counting calls as embeddings. The overlap
+// becomes the same share of chunk_size_tokens as chunk_overlap is of
+// chunk_size (10% by default). chunk_size_tokens can't exceed
+// INGEST_MAX_CHUNK_TOKENS (default 2048, text-embedding-004's limit).
+//
+// If counting fails, say on the Gemini quota, the chunks packed by the
+// estimate are kept and a warning logged rather than failing the ingest.
+
+var INGEST_MAX_CHUNK_TOKENS = envInt("INGEST_MAX_CHUNK_TOKENS", 2048)
+
+const (
+	// countBatchSize is how many texts one /count-tokens call counts.
+	countBatchSize = 500
+
+	// charsPerToken is the estimate chunks are first packed by. English
+	// averages about four characters a token; three leaves some room.
+	charsPerToken = 3
+
+	// fitRounds is how many times oversized chunks are packed again.
+	fitRounds = 3
+)
+
+// estimateTokens sets the length of each sentence, in characters, to an
+// estimate of its tokens.
+func estimateTokens(sentences []sentence) {
+	for i := range sentences {
+		sentences[i].length = max((sentences[i].length+charsPerToken-1)/charsPerToken, 1)
+	}
+}
+
+// fitTokens counts the tokens of each chunk text and packs those over size
+// again, by the tokens counted for them, until all fit or fitRounds runs
+// out. Chunks that already fit aren't counted again.
+func fitTokens(ctx context.Context, parts []string, size, overlap int) ([]string, error) {
+	type counted struct {
+		text   string
+		tokens int // 0 until counted
+	}
+	chunks := make([]counted, len(parts))
+	for i, part := range parts {
+		chunks[i].text = part
+	}
+
+	for round := 0; ; round++ {
+		var pending []int
+		var texts []string
+		for i, c := range chunks {
+			if c.tokens == 0 {
+				pending = append(pending, i)
+				texts = append(texts, c.text)
+			}
+		}
+		counts, err := countTokens(ctx, texts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("⚠️  Keeping chunks sized by estimate: %v", err)
+			return parts, nil
+		}
+		for j, i := range pending {
+			chunks[i].tokens = max(counts[j], 1)
+		}
+
+		var next []counted
+		over := 0
+		for _, c := range chunks {
+			if c.tokens <= size || round == fitRounds {
+				next = append(next, c)
+				continue
+			}
+			over++
+			for _, piece := range repackChunk(c.text, c.tokens, size, overlap) {
+				next = append(next, counted{text: piece})
+			}
+		}
+		chunks = next
+		if over == 0 {
+			break
+		}
+	}
+
+	parts = make([]string, len(chunks))
+	for i, c := range chunks {
+		parts[i] = c.text
+		if c.tokens > size {
+			log.Printf("⚠️  Chunk %d has %d tokens, more than the %d asked for", i, c.tokens, size)
+		}
+	}
+	return parts, nil
+}
+
+// repackChunk packs the sentences of a chunk text of tokens into chunks of
+// about size, taking the tokens to spread evenly over its characters.
+func repackChunk(text string, tokens, size, overlap int) []string {
+	sentences := splitSentences(text)
+	runes := utf8.RuneCountInString(text)
+	for i := range sentences {
+		// The sentence's share of the chunk's tokens, rounded up
+		sentences[i].length = max((sentences[i].length*tokens+runes-1)/runes, 1)
+	}
+	return packSentences(sentences, size, overlap)
+}
+
+// countTokens counts the tokens of each text with the embed service.
+func countTokens(ctx context.Context, texts []string) ([]int, error) {
+	counts := make([]int, 0, len(texts))
+	for start := 0; start < len(texts); start += countBatchSize {
+		batch := texts[start:min(start+countBatchSize, len(texts))]
+		var out struct {
+			Counts []int `json:"counts"`
+		}
+		err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/count-tokens", map[string]interface{}{
+			"texts": batch,
+		}, &out, httpclient.Idempotent)
+		if err != nil {
+			return nil, fmt.Errorf("counting tokens: %w", err)
+		}
+		if len(out.Counts) != len(batch) {
+			return nil, fmt.Errorf("counting tokens: got %d counts for %d texts", len(out.Counts), len(batch))
+		}
+		counts = append(counts, out.Counts...)
+	}
+	return counts, nil
+}
+
+// tokenOverlap is req's chunk overlap in tokens, the same share of
+// chunk_size_tokens as chunk_overlap is of chunk_size.
+func tokenOverlap(req IngestRequest) int {
+	return req.ChunkOverlap * req.ChunkTokens / req.ChunkSize
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// ============================================================================
+// URL INGESTION
+// ============================================================================
+// POST /ingest-url takes the fields of /ingest with a "url" in place of the
+// file_path, fetches the page and ingests its text as usual. An HTML page
+// goes through a readability pass first: scripts, navigation, headers,
+// footers, sidebars and anything else whose tag, class or id marks it as
+// boilerplate are dropped, and the text of the element holding most of the
+// remaining prose (an <article> or <main> when the page has one) is kept,
+// one paragraph per block element. A PDF, Word or text response (many RBI
+// circulars link straight to a PDF) is saved under DATA_DIR and extracted
+// like an upload. document_name defaults to the page's <title>.
+//
+// The URL is the document's file_path in the metadata service, and every
+// chunk's payload carries it as "source_url". Pages are fetched without the
+// service's credentials, within INGEST_URL_TIMEOUT (default 30s) and up to
+// INGEST_URL_MAX_BYTES (default 20 MiB). Hosts that resolve to loopback,
+// private or link-local addresses are refused unless
+// INGEST_URL_ALLOW_PRIVATE is true.
+
+var (
+	INGEST_URL_TIMEOUT       = envDuration("INGEST_URL_TIMEOUT", 30*time.Second)
+	INGEST_URL_MAX_BYTES     = envInt("INGEST_URL_MAX_BYTES", 20<<20)
+	INGEST_URL_ALLOW_PRIVATE = getEnv("INGEST_URL_ALLOW_PRIVATE", "false") == "true"
+
+	// pageClient fetches pages with a transport of its own, so none of the
+	// auth, tenant or tracing headers meant for the platform's services
+	// leave it.
+	pageClient = &http.Client{
+		Timeout: INGEST_URL_TIMEOUT,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: guardPageDial}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return checkPageURL(req.URL)
+		},
+	}
+)
+
+type IngestURLRequest struct {
+	URL string `json:"url"`
+	IngestRequest
+}
+
+func ingestURLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req IngestURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	pageURL, err := url.Parse(req.URL)
+	if err != nil {
+		respondError(w, "Invalid url", http.StatusBadRequest)
+		return
+	}
+	if err := checkPageURL(pageURL); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	effectiveAt, err := prepareIngest(&req.IngestRequest)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Async {
+		submitIngestJob(w, r, &checkpoint{Request: req.IngestRequest, URL: pageURL.String(), EffectiveAt: effectiveAt})
+		return
+	}
+
+	resp, err := ingestPage(r.Context(), req.IngestRequest, pageURL, effectiveAt, nil)
+	if err != nil {
+		respondIngestError(w, err)
+		return
+	}
+	jsonResponse(w, resp)
+}
+
+// ingestPage fetches pageURL and ingests its text for req.
+func ingestPage(ctx context.Context, req IngestRequest, pageURL *url.URL, effectiveAt int64, prog *ingestProgress) (IngestResponse, error) {
+	title, text, err := fetchPage(ctx, pageURL, prog)
+	if err != nil {
+		return IngestResponse{}, err
+	}
+
+	if req.DocumentName == "" {
+		req.DocumentName = title
+	}
+	if req.DocumentName == "" {
+		req.DocumentName = pageURL.String()
+	}
+	req.FilePath = pageURL.String()
+
+	log.Printf("Ingesting document: %s", req.DocumentName)
+
+	return ingestText(ctx, req, text, effectiveAt, pageURL.String(), prog)
+}
+
+// fetchPage is the fetch stage: the title and readable text of the page.
+func fetchPage(ctx context.Context, pageURL *url.URL, prog *ingestProgress) (string, string, error) {
+	log.Printf("Fetching %s", pageURL.Redacted())
+
+	prog.start(stageFetch)
+	title, text, err := fetchPageText(ctx, pageURL.String())
+	if err != nil {
+		return "", "", prog.fail(stageFetch, &ingestError{http.StatusBadGateway, "Failed to fetch page: " + err.Error()})
+	}
+	if len(strings.TrimSpace(text)) < 10 {
+		return "", "", prog.fail(stageFetch, &ingestError{http.StatusBadRequest, "No readable text found on the page"})
+	}
+	prog.finish(stageFetch, utf8.RuneCountInString(text))
+	return title, text, nil
+}
+
+// checkPageURL only lets http(s) URLs with a host through.
+func checkPageURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url must be http or https")
+	}
+	if u.Hostname() == "" {
+		return errors.New("url has no host")
+	}
+	return nil
+}
+
+// guardPageDial refuses connections to addresses inside the deployment,
+// whatever name resolved to them.
+func guardPageDial(network, address string, _ syscall.RawConn) error {
+	if INGEST_URL_ALLOW_PRIVATE {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to fetch from %s", host)
+	}
+	return nil
+}
+
+// fetchPageText fetches pageURL and returns its title, if it has one, and
+// its text.
+func fetchPageText(ctx context.Context, pageURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", "gorilla-rag-ingest/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf,text/plain;q=0.9,*/*;q=0.5")
+
+	resp, err := pageClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%s returned status %d", pageURL, resp.StatusCode)
+	}
+
+	body := io.LimitReader(resp.Body, int64(INGEST_URL_MAX_BYTES)+1)
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "":
+		utf8, err := charset.NewReader(body, contentType)
+		if err != nil {
+			return "", "", err
+		}
+		doc, err := html.Parse(utf8)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot parse HTML: %w", err)
+		}
+		title, text := readability(doc)
+		return title, text, nil
+	}
+
+	ext, ok := pageFileTypes[mediaType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+	path, err := savePage(body, ext)
+	if err != nil {
+		return "", "", err
+	}
+	text, err := extractText(path)
+	return "", text, err
+}
+
+// pageFileTypes maps the non-HTML responses /ingest-url reads to the
+// extension extractText knows them by.
+var pageFileTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/msword": ".doc",
+	"text/plain":         ".txt",
+}
+
+// savePage stores a fetched file under DATA_DIR, as an upload would be.
+func savePage(body io.Reader, ext string) (string, error) {
+	path := fmt.Sprintf("%s/%s_page%s", DATA_DIR, uuid.New().String(), ext)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to save page: %w", err)
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to save page: %w", err)
+	}
+	if n > int64(INGEST_URL_MAX_BYTES) {
+		os.Remove(path)
+		return "", fmt.Errorf("page is larger than %d bytes", INGEST_URL_MAX_BYTES)
+	}
+	return path, nil
+}
+
+// ============================================================================
+// READABILITY
+// ============================================================================
+
+// boilerplateTags never hold a page's content.
+var boilerplateTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "nav": true, "header": true, "footer": true, "aside": true,
+	"form": true, "button": true, "select": true, "head": true,
+}
+
+// boilerplateNames mark an element as boilerplate by its class or id.
+var boilerplateNames = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|menu|footer|header|masthead|sidebar|breadcrumbs?|cookie|banner|social|share|comments?|advert|ads|popup|modal|skip|related|subscribe|newsletter)($|[\s_-])`)
+
+// blockTags end a paragraph of the extracted text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"li": true, "ul": true, "ol": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "tr": true, "blockquote": true, "pre": true, "br": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// readability returns the page's title and the text of its main content.
+func readability(doc *html.Node) (string, string) {
+	title := strings.TrimSpace(nodeText(findElement(doc, "title")))
+
+	content := findElement(doc, "article")
+	if content == nil {
+		content = findElement(doc, "main")
+	}
+	if content == nil {
+		content = bestContainer(doc)
+	}
+	if content == nil {
+		content = doc
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if p := strings.Join(strings.Fields(current.String()), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			current.WriteString(n.Data)
+			current.WriteByte(' ')
+			return
+		case html.ElementNode:
+			if boilerplate(n) {
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && blockTags[n.Data]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(content)
+	flush()
+
+	return title, strings.Join(paragraphs, "\n\n")
+}
+
+// bestContainer scores each element by the prose directly inside its
+// paragraphs, crediting a paragraph's parent with its score and the
+// grandparent with half, and returns the element scoring highest.
+func bestContainer(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if boilerplate(n) {
+				return
+			}
+			switch n.Data {
+			case "p", "pre", "td", "li", "blockquote":
+				text := nodeText(n)
+				if len(text) >= 25 {
+					score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+					score *= 1 - linkDensity(n, len(text))
+					if parent := n.Parent; parent != nil {
+						scores[parent] += score
+						if grand := parent.Parent; grand != nil {
+							scores[grand] += score / 2
+						}
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	for n, score := range scores {
+		if best == nil || score > scores[best] {
+			best = n
+		}
+	}
+	return best
+}
+
+// boilerplate reports whether element n is navigation, chrome or markup
+// rather than content.
+func boilerplate(n *html.Node) bool {
+	if boilerplateTags[n.Data] {
+		return true
+	}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "class", "id":
+			if boilerplateNames.MatchString(a.Val) {
+				return true
+			}
+		case "role":
+			if a.Val == "navigation" || a.Val == "banner" || a.Val == "contentinfo" {
+				return true
+			}
+		case "hidden", "aria-hidden":
+			if a.Key == "hidden" || a.Val == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// linkDensity is the share of n's text, textLen bytes long, inside links.
+func linkDensity(n *html.Node, textLen int) float64 {
+	if textLen == 0 {
+		return 0
+	}
+	linked := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += len(nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return math.Min(float64(linked)/float64(textLen), 1)
+}
+
+// findElement returns the first element named tag under n, depth first.
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// nodeText is the text under n with its whitespace collapsed, skipping
+// boilerplate.
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+			return
+		}
+		if n.Type == html.ElementNode && n.Data != "title" && boilerplate(n) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
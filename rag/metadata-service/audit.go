@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shared/events"
+	"shared/tenant"
+)
+
+// AuditEvent is a pipeline event recorded from the event bus.
+type AuditEvent struct {
+	ID         string                 `json:"id"`
+	Subject    string                 `json:"subject"`
+	Source     string                 `json:"source"`
+	DocumentID string                 `json:"document_id,omitempty"`
+	TenantID   string                 `json:"tenant_id"`
+	Data       map[string]interface{} `json:"data"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// auditSubjects are the event families recorded in the events table.
+var auditSubjects = []string{"ingest.>", "document.>", "agent.>"}
+
+func subscribeAuditLog(bus events.Bus) {
+	for _, subject := range auditSubjects {
+		if err := bus.Subscribe(subject, recordEvent); err != nil {
+			log.Fatalf("Failed to subscribe to %s: %v", subject, err)
+		}
+	}
+}
+
+func recordEvent(e events.Event) {
+	data, _ := json.Marshal(e.Data)
+	docID, _ := e.Data["document_id"].(string)
+	tenantID := e.Tenant
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+
+	_, err := db.Exec(
+		`INSERT OR IGNORE INTO events (id, subject, source, document_id, tenant_id, data, occurred_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Subject, e.Source, docID, tenantID, string(data), e.Time,
+	)
+	if err != nil {
+		log.Printf("Failed to record event %s: %v", e.ID, err)
+	}
+}
+
+// eventsHandler lists the caller's tenant's recorded events, newest first,
+// optionally filtered by document_id and limited by limit (default 100).
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+
+	query := "SELECT id, subject, source, document_id, tenant_id, data, occurred_at FROM events WHERE tenant_id = ?"
+	args := []interface{}{tenant.FromContext(r.Context())}
+	if docID := r.URL.Query().Get("document_id"); docID != "" {
+		query += " AND document_id = ?"
+		args = append(args, docID)
+	}
+	query += " ORDER BY occurred_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		respondError(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var data string
+		rows.Scan(&e.ID, &e.Subject, &e.Source, &e.DocumentID, &e.TenantID, &data, &e.OccurredAt)
+		json.Unmarshal([]byte(data), &e.Data)
+		list = append(list, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": list, "count": len(list)})
+}
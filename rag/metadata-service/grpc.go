@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"protos/gorillapb"
+	"shared/tenant"
+)
+
+// metadataServer exposes the documents table over the
+// gorilla.v1.MetadataService contract.
+type metadataServer struct {
+	gorillapb.UnimplementedMetadataServiceServer
+}
+
+func (metadataServer) CreateDocument(ctx context.Context, req *gorillapb.CreateDocumentRequest) (*gorillapb.Document, error) {
+	if req.GetDocument() == nil {
+		return nil, status.Error(codes.InvalidArgument, "document required")
+	}
+
+	doc := fromProtoDocument(req.GetDocument())
+	doc.TenantID = tenant.FromContext(ctx)
+	if err := insertDocument(&doc); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to insert document: %v", err)
+	}
+	return toProtoDocument(doc), nil
+}
+
+func (metadataServer) GetDocument(ctx context.Context, req *gorillapb.GetDocumentRequest) (*gorillapb.Document, error) {
+	doc, err := fetchDocument(tenant.FromContext(ctx), req.GetId())
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "document not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "query failed: %v", err)
+	}
+	return toProtoDocument(doc), nil
+}
+
+func (metadataServer) ListDocuments(ctx context.Context, req *gorillapb.ListDocumentsRequest) (*gorillapb.ListDocumentsResponse, error) {
+	documents, err := listDocuments(tenant.FromContext(ctx))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "query failed: %v", err)
+	}
+
+	resp := &gorillapb.ListDocumentsResponse{
+		Documents: make([]*gorillapb.Document, len(documents)),
+		Count:     int32(len(documents)),
+	}
+	for i, doc := range documents {
+		resp.Documents[i] = toProtoDocument(doc)
+	}
+	return resp, nil
+}
+
+func (metadataServer) UpdateDocumentStatus(ctx context.Context, req *gorillapb.UpdateDocumentStatusRequest) (*gorillapb.UpdateDocumentStatusResponse, error) {
+	if err := setDocumentStatus(tenant.FromContext(ctx), req.GetId(), req.GetStatus()); err != nil {
+		return nil, status.Errorf(codes.Internal, "update failed: %v", err)
+	}
+	return &gorillapb.UpdateDocumentStatusResponse{Status: "success"}, nil
+}
+
+func toProtoDocument(doc Document) *gorillapb.Document {
+	return &gorillapb.Document{
+		Id:         doc.ID,
+		Name:       doc.Name,
+		Type:       doc.Type,
+		FilePath:   doc.FilePath,
+		Status:     doc.Status,
+		UploadedAt: timestamppb.New(doc.UploadedAt),
+	}
+}
+
+func fromProtoDocument(doc *gorillapb.Document) Document {
+	return Document{
+		ID:         doc.GetId(),
+		Name:       doc.GetName(),
+		Type:       doc.GetType(),
+		FilePath:   doc.GetFilePath(),
+		Status:     doc.GetStatus(),
+		UploadedAt: doc.GetUploadedAt().AsTime(),
+	}
+}
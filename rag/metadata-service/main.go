@@ -1,8 +1,10 @@
-//metadata-service is a microservice that manages document metadata using SQLite.
+// metadata-service is a microservice that manages document metadata using SQLite.
 package main
 
 import (
+	"context"
 	"database/sql"
+	_ "embed"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -10,22 +12,44 @@ import (
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"protos/gorillapb"
+	"shared/auth"
+	"shared/events"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/rpc"
+	"shared/server"
+	"shared/tenant"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 type Document struct {
 	ID         string    `json:"id"`
 	Name       string    `json:"name"`
 	Type       string    `json:"type"`
 	FilePath   string    `json:"file_path"`
 	Status     string    `json:"status"`
+	TenantID   string    `json:"tenant_id"`
 	UploadedAt time.Time `json:"uploaded_at"`
 }
 
-var db *sql.DB
+var (
+	db       *sql.DB
+	eventBus events.Bus
+)
 
 func main() {
+	shutdownTracing, err := tracing.Init("metadata-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	dbPath := getEnv("DB_PATH", "./data/metadata.db")
-	var err error
 	db, err = sql.Open("sqlite3", dbPath)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -36,13 +60,50 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	eventBus, err = events.Connect("metadata-service")
+	if err != nil {
+		log.Fatalf("Failed to connect to event bus: %v", err)
+	}
+	defer eventBus.Close()
+	subscribeAuditLog(eventBus)
+
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("metadata-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("metadata-service", map[string]server.Check{
+		"sqlite": func(ctx context.Context) error { return db.PingContext(ctx) },
+	}))
 	http.HandleFunc("/documents", documentsHandler)
 	http.HandleFunc("/documents/", documentByIDHandler)
+	http.HandleFunc("/events", eventsHandler)
+
+	grpcServer, err := rpc.NewServer()
+	if err != nil {
+		log.Fatalf("Failed to create gRPC server: %v", err)
+	}
+	gorillapb.RegisterMetadataServiceServer(grpcServer, metadataServer{})
+	rpc.Serve(grpcServer, ":"+getEnv("GRPC_PORT", "50053"))
 
 	port := getEnv("PORT", "8083")
 	log.Printf("Metadata Service starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("metadata-service", handler)
+	handler = metrics.Wrap("metadata-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Method: http.MethodPost, Path: "/documents", Roles: auth.Privileged},
+		{Method: http.MethodPut, Path: "/documents/", Roles: auth.Privileged},
+		{Method: http.MethodDelete, Path: "/documents/", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer.GracefulStop()
 }
 
 func initializeDatabase() error {
@@ -56,8 +117,61 @@ func initializeDatabase() error {
 		uploaded_at DATETIME NOT NULL
 	);
 	CREATE INDEX IF NOT EXISTS idx_documents_type ON documents(type);
-	CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status);`
-	_, err := db.Exec(schema)
+	CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status);
+	CREATE TABLE IF NOT EXISTS events (
+		id TEXT PRIMARY KEY,
+		subject TEXT NOT NULL,
+		source TEXT NOT NULL,
+		document_id TEXT,
+		data TEXT NOT NULL,
+		occurred_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_events_document ON events(document_id);`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Rows written before multi-tenancy belong to the default tenant.
+	for _, table := range []string{"documents", "events"} {
+		if err := addColumnIfMissing(table, "tenant_id", "TEXT NOT NULL DEFAULT '"+tenant.Default+"'"); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_documents_tenant ON documents(tenant_id);
+	CREATE INDEX IF NOT EXISTS idx_events_tenant ON events(tenant_id);`)
+	return err
+}
+
+// addColumnIfMissing runs ALTER TABLE ADD COLUMN unless column already exists.
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dflt      sql.NullString
+			isPrimary int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &isPrimary); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
 	return err
 }
 
@@ -78,24 +192,11 @@ func documentsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getDocuments(w http.ResponseWriter, r *http.Request) {
-	query := "SELECT id, name, type, file_path, status, uploaded_at FROM documents ORDER BY uploaded_at DESC"
-	rows, err := db.Query(query)
+	documents, err := listDocuments(tenant.FromContext(r.Context()))
 	if err != nil {
 		respondError(w, "Query failed", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var documents []Document
-	for rows.Next() {
-		var doc Document
-		rows.Scan(&doc.ID, &doc.Name, &doc.Type, &doc.FilePath, &doc.Status, &doc.UploadedAt)
-		documents = append(documents, doc)
-	}
-
-	if documents == nil {
-		documents = []Document{}
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"documents": documents, "count": len(documents)})
@@ -108,13 +209,8 @@ func createDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if doc.Status == "" {
-		doc.Status = "pending"
-	}
-
-	query := `INSERT INTO documents (id, name, type, file_path, status, uploaded_at) VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := db.Exec(query, doc.ID, doc.Name, doc.Type, doc.FilePath, doc.Status, doc.UploadedAt)
-	if err != nil {
+	doc.TenantID = tenant.FromContext(r.Context())
+	if err := insertDocument(&doc); err != nil {
 		respondError(w, "Failed to insert document", http.StatusInternalServerError)
 		return
 	}
@@ -140,15 +236,15 @@ func documentByIDHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		getDocumentByID(w, r, id)
+	case http.MethodDelete:
+		deleteDocumentByID(w, r, id)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func getDocumentByID(w http.ResponseWriter, r *http.Request, id string) {
-	var doc Document
-	err := db.QueryRow("SELECT id, name, type, file_path, status, uploaded_at FROM documents WHERE id = ?", id).
-		Scan(&doc.ID, &doc.Name, &doc.Type, &doc.FilePath, &doc.Status, &doc.UploadedAt)
+	doc, err := fetchDocument(tenant.FromContext(r.Context()), id)
 	if err == sql.ErrNoRows {
 		respondError(w, "Document not found", http.StatusNotFound)
 		return
@@ -158,6 +254,31 @@ func getDocumentByID(w http.ResponseWriter, r *http.Request, id string) {
 	json.NewEncoder(w).Encode(doc)
 }
 
+func deleteDocumentByID(w http.ResponseWriter, r *http.Request, id string) {
+	tenantID := tenant.FromContext(r.Context())
+	doc, err := fetchDocument(tenantID, id)
+	if err == sql.ErrNoRows {
+		respondError(w, "Document not found", http.StatusNotFound)
+		return
+	}
+
+	if err := deleteDocument(tenantID, id); err != nil {
+		respondError(w, "Delete failed", http.StatusInternalServerError)
+		return
+	}
+
+	if err := eventBus.Publish(r.Context(), events.DocumentDeleted, map[string]interface{}{
+		"document_id":   doc.ID,
+		"document_name": doc.Name,
+		"document_type": doc.Type,
+	}); err != nil {
+		log.Printf("Failed to publish %s: %v", events.DocumentDeleted, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "id": id})
+}
+
 func updateDocumentStatus(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -169,8 +290,7 @@ func updateDocumentStatus(w http.ResponseWriter, r *http.Request, id string) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	_, err := db.Exec("UPDATE documents SET status = ? WHERE id = ?", req.Status, id)
-	if err != nil {
+	if err := setDocumentStatus(tenant.FromContext(r.Context()), id, req.Status); err != nil {
 		respondError(w, "Update failed", http.StatusInternalServerError)
 		return
 	}
@@ -179,6 +299,60 @@ func updateDocumentStatus(w http.ResponseWriter, r *http.Request, id string) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// ============================================================================
+// STORAGE
+// ============================================================================
+
+// Every query is scoped to a tenant; documents of other tenants behave as if
+// they did not exist.
+
+func listDocuments(tenantID string) ([]Document, error) {
+	query := "SELECT id, name, type, file_path, status, tenant_id, uploaded_at FROM documents WHERE tenant_id = ? ORDER BY uploaded_at DESC"
+	rows, err := db.Query(query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []Document{}
+	for rows.Next() {
+		var doc Document
+		rows.Scan(&doc.ID, &doc.Name, &doc.Type, &doc.FilePath, &doc.Status, &doc.TenantID, &doc.UploadedAt)
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
+
+func insertDocument(doc *Document) error {
+	if doc.Status == "" {
+		doc.Status = "pending"
+	}
+	if doc.TenantID == "" {
+		doc.TenantID = tenant.Default
+	}
+
+	query := `INSERT INTO documents (id, name, type, file_path, status, tenant_id, uploaded_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, doc.ID, doc.Name, doc.Type, doc.FilePath, doc.Status, doc.TenantID, doc.UploadedAt)
+	return err
+}
+
+func fetchDocument(tenantID, id string) (Document, error) {
+	var doc Document
+	err := db.QueryRow("SELECT id, name, type, file_path, status, tenant_id, uploaded_at FROM documents WHERE id = ? AND tenant_id = ?", id, tenantID).
+		Scan(&doc.ID, &doc.Name, &doc.Type, &doc.FilePath, &doc.Status, &doc.TenantID, &doc.UploadedAt)
+	return doc, err
+}
+
+func deleteDocument(tenantID, id string) error {
+	_, err := db.Exec("DELETE FROM documents WHERE id = ? AND tenant_id = ?", id, tenantID)
+	return err
+}
+
+func setDocumentStatus(tenantID, id, status string) error {
+	_, err := db.Exec("UPDATE documents SET status = ? WHERE id = ? AND tenant_id = ?", status, id, tenantID)
+	return err
+}
+
 func respondError(w http.ResponseWriter, message string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
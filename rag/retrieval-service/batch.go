@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"shared/tracing"
+)
+
+// ============================================================================
+// BATCH RETRIEVAL
+// ============================================================================
+// POST /retrieve/batch runs up to RETRIEVAL_BATCH_MAX_QUERIES (default 20)
+// retrieval requests at once, for callers such as the orchestrator that
+// would otherwise make a round trip per query. The standard-strategy
+// queries not in the cache are embedded in one embed-batch call, then every
+// query is searched concurrently. A query that fails gets an "error" in its
+// slot instead of failing the batch.
+
+var RETRIEVAL_BATCH_MAX_QUERIES = envInt("RETRIEVAL_BATCH_MAX_QUERIES", 20)
+
+type BatchRetrievalRequest struct {
+	Queries []RetrievalRequest `json:"queries"`
+}
+
+type BatchRetrievalResponse struct {
+	Responses   []BatchRetrievalItem `json:"responses"`       // One per query, in order
+	Count       int                  `json:"count"`           // Number of queries
+	ProcessTime float64              `json:"process_time_ms"` // How long the batch took (milliseconds)
+}
+
+// BatchRetrievalItem - The response to one query of a batch, or its error
+type BatchRetrievalItem struct {
+	RetrievalResponse
+	Error string `json:"error,omitempty"`
+}
+
+func retrieveBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchRetrievalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Queries) == 0 || len(req.Queries) > RETRIEVAL_BATCH_MAX_QUERIES {
+		respondError(w, fmt.Sprintf("queries must hold 1 to %d requests", RETRIEVAL_BATCH_MAX_QUERIES), http.StatusBadRequest)
+		return
+	}
+	for i, q := range req.Queries {
+		if err := validateRequest(q); err != nil {
+			respondError(w, fmt.Sprintf("queries[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := retrieveBatch(r.Context(), req.Queries)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// retrieveBatch runs reqs concurrently after embedding what it can of them
+// in one call.
+func retrieveBatch(ctx context.Context, reqs []RetrievalRequest) *BatchRetrievalResponse {
+	startTime := time.Now()
+	log.Printf("📦 Batch retrieval started: %d queries", len(reqs))
+
+	vectors := embedBatch(ctx, reqs)
+
+	items := make([]BatchRetrievalItem, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req RetrievalRequest) {
+			defer wg.Done()
+			response, err := retrieveWith(ctx, req, vectors[i])
+			if err != nil {
+				log.Printf("⚠️  Batch query %d failed: %v", i, err)
+				items[i] = BatchRetrievalItem{
+					RetrievalResponse: RetrievalResponse{Query: req.Query, Results: []RetrievalResult{}},
+					Error:             err.Error(),
+				}
+				return
+			}
+			items[i] = BatchRetrievalItem{RetrievalResponse: *response}
+		}(i, req)
+	}
+	wg.Wait()
+
+	processTime := time.Since(startTime).Milliseconds()
+	log.Printf("✅ Batch retrieval completed in %dms (%d queries)", processTime, len(reqs))
+	return &BatchRetrievalResponse{
+		Responses:   items,
+		Count:       len(items),
+		ProcessTime: float64(processTime),
+	}
+}
+
+// embedBatch returns the vectors of each standard-strategy request that
+// isn't translated (see language.go) and uses the default embedding model
+// (see embedmodel.go), from the cache or one embed-batch call. The other
+// slots are nil, left for retrieveWith to embed, as are all of them when
+// the call fails.
+func embedBatch(ctx context.Context, reqs []RetrievalRequest) []*queryVectors {
+	vectors := make([]*queryVectors, len(reqs))
+	var texts []string
+	slots := make(map[string][]int) // text → requests embedding it
+	for i, req := range reqs {
+		if (req.Strategy != "" && req.Strategy != strategyStandard) || req.PageToken != "" || translationTarget(req) != "" || requestModel(req).custom() {
+			continue
+		}
+		if vectors[i] = resultCache.Embeddings(ctx, req); vectors[i] != nil {
+			continue
+		}
+		if _, ok := slots[req.Query]; !ok {
+			texts = append(texts, req.Query)
+		}
+		slots[req.Query] = append(slots[req.Query], i)
+	}
+	if len(texts) == 0 {
+		return vectors
+	}
+
+	stepCtx, span := tracing.Start(ctx, "retrieval.embed_batch")
+	embeddings, err := getQueryEmbeddings(stepCtx, texts, embeddingModel{})
+	tracing.End(span, err)
+	if err != nil {
+		log.Printf("⚠️  Batch embedding failed, embedding each query: %v", err)
+		return vectors
+	}
+	log.Printf("   ✓ Embedded %d queries in one call", len(texts))
+
+	for t, text := range texts {
+		v := &queryVectors{Embeddings: [][]float32{embeddings[t]}}
+		for _, i := range slots[text] {
+			vectors[i] = v
+			resultCache.StoreEmbeddings(ctx, reqs[i], v)
+		}
+	}
+	return vectors
+}
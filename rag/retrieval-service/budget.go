@@ -0,0 +1,48 @@
+package main
+
+// ============================================================================
+// TOKEN BUDGET
+// ============================================================================
+// "max_context_tokens" caps the tokens of the results' texts together, for
+// callers that paste them into a prompt of fixed size. Results are kept in
+// rank order while they fit; the first that doesn't is cut at a word
+// boundary when at least minContextPassageTokens of it would be left (or
+// it is the first result), and the rest are dropped. Tokens are estimated
+// as for context assembly (see context.go). Each kept result carries its
+// "tokens" and the response the "context_tokens" used. A token budget
+// can't be combined with pagination.
+
+// applyTokenBudget keeps results, best first, until their texts hold
+// maxTokens tokens, returning them and the tokens they hold.
+func applyTokenBudget(results []RetrievalResult, maxTokens int) ([]RetrievalResult, int) {
+	kept := make([]RetrievalResult, 0, len(results))
+	used := 0
+	for _, r := range results {
+		tokens := estimateTokens(r.Text)
+		if used+tokens > maxTokens {
+			left := maxTokens - used
+			if left <= 0 || (left < minContextPassageTokens && len(kept) > 0) {
+				return kept, used
+			}
+			r.Text = truncateWords(r.Text, left*charsPerToken)
+			if r.Text == "" {
+				return kept, used
+			}
+			r.Tokens = estimateTokens(r.Text)
+			return append(kept, r), used + r.Tokens
+		}
+		r.Tokens = tokens
+		kept = append(kept, r)
+		used += tokens
+	}
+	return kept, used
+}
+
+// resultTokens is the tokens results hold, as counted by applyTokenBudget.
+func resultTokens(results []RetrievalResult) int {
+	used := 0
+	for _, r := range results {
+		used += r.Tokens
+	}
+	return used
+}
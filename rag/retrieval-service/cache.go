@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"shared/tenant"
+)
+
+// ============================================================================
+// RESULT CACHE
+// ============================================================================
+// With RETRIEVAL_CACHE_REDIS_URL (falling back to REDIS_URL) set, results
+// are cached in Redis for RETRIEVAL_CACHE_TTL (default 10m; 0 turns the
+// cache off), so a repeated question skips the embed service and Qdrant:
+//
+//	retrieval:embeddings:<hash>        what a query and strategy search with
+//	retrieval:results:<tenant>:<collection>:<generations>:<hash>
+//	                                   results for those embeddings, filters
+//	                                   and the rest of the request
+//	retrieval:gen:<tenant>[:<collection>]
+//	                                   counted up when a document of the
+//	                                   collection (or any, for deletions) is
+//	                                   ingested or deleted
+//
+// Bumping a generation strands the result entries keyed under the old one
+// until they expire. Responses widened to other collections and strategies
+// that fell back to the query aren't cached. When Redis can't be reached
+// the request goes on uncached.
+
+var (
+	RETRIEVAL_CACHE_REDIS_URL = getEnv("RETRIEVAL_CACHE_REDIS_URL", getEnv("REDIS_URL", ""))
+	RETRIEVAL_CACHE_TTL       = envDuration("RETRIEVAL_CACHE_TTL", 10*time.Minute)
+)
+
+// resultCache is nil when caching is off. A nil cache misses and stores
+// nothing.
+var resultCache *redisResultCache
+
+type redisResultCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisResultCache(url string, ttl time.Duration) (*redisResultCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisResultCache{client: redis.NewClient(opts), ttl: ttl}, nil
+}
+
+func initResultCache() {
+	if RETRIEVAL_CACHE_REDIS_URL == "" || RETRIEVAL_CACHE_TTL <= 0 {
+		return
+	}
+	cache, err := newRedisResultCache(RETRIEVAL_CACHE_REDIS_URL, RETRIEVAL_CACHE_TTL)
+	if err != nil {
+		log.Fatalf("Invalid RETRIEVAL_CACHE_REDIS_URL: %v", err)
+	}
+	resultCache = cache
+}
+
+func hashKey(v interface{}) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *redisResultCache) embeddingsKey(req RetrievalRequest) string {
+	return "retrieval:embeddings:" + hashKey([]string{requestModel(req).key(), req.Strategy, req.Query, translationTarget(req)})
+}
+
+// Embeddings returns the cached vectors of req's query and strategy.
+func (c *redisResultCache) Embeddings(ctx context.Context, req RetrievalRequest) *queryVectors {
+	if c == nil {
+		return nil
+	}
+	var vectors queryVectors
+	if !c.get(ctx, c.embeddingsKey(req), &vectors) || len(vectors.Embeddings) == 0 {
+		return nil
+	}
+	return &vectors
+}
+
+func (c *redisResultCache) StoreEmbeddings(ctx context.Context, req RetrievalRequest, vectors *queryVectors) {
+	if c == nil || vectors.Fallback {
+		return
+	}
+	c.set(ctx, c.embeddingsKey(req), vectors)
+}
+
+func generationKey(tenantID, collection string) string {
+	if collection == "" {
+		return "retrieval:gen:" + tenantID
+	}
+	return "retrieval:gen:" + tenantID + ":" + collection
+}
+
+// ResultsKey is the key of req's results when searched with vectors, or ""
+// when they shouldn't be cached.
+func (c *redisResultCache) ResultsKey(ctx context.Context, req RetrievalRequest, vectors *queryVectors) string {
+	if c == nil || vectors.Fallback {
+		return ""
+	}
+	tenantID := tenant.FromContext(ctx)
+	gens, err := c.client.MGet(ctx, generationKey(tenantID, ""), generationKey(tenantID, req.Collection)).Result()
+	if err != nil {
+		log.Printf("⚠️  Result cache unavailable: %v", err)
+		return ""
+	}
+	generation := ""
+	for i, gen := range gens {
+		if i > 0 {
+			generation += "."
+		}
+		if s, ok := gen.(string); ok {
+			generation += s
+		} else {
+			generation += "0"
+		}
+	}
+
+	// Everything the results depend on besides the collection's contents
+	return "retrieval:results:" + tenantID + ":" + req.Collection + ":" + generation + ":" + hashKey(map[string]interface{}{
+		"embeddings":         vectors.Embeddings,
+		"query":              req.Query,
+		"filters":            req.Filters,
+		"top_k":              req.TopK,
+		"rerank":             rerankStrategy(req),
+		"rerank_weights":     rerankWeights(req),
+		"hybrid":             hybridSearch(req),
+		"dedup":              dedupSearch(req),
+		"min_score":          req.MinScore,
+		"min_results":        req.MinResults,
+		"expand_context":     req.ExpandContext,
+		"recency_weight":     req.RecencyWeight,
+		"match":              req.Match,
+		"max_context_tokens": req.MaxContextTokens,
+		"explain":            req.Explain,
+	})
+}
+
+// Results returns the results cached under key.
+func (c *redisResultCache) Results(ctx context.Context, key string) ([]RetrievalResult, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	var results []RetrievalResult
+	if !c.get(ctx, key, &results) {
+		return nil, false
+	}
+	return results, true
+}
+
+func (c *redisResultCache) StoreResults(ctx context.Context, key string, results []RetrievalResult) {
+	if c == nil || key == "" {
+		return
+	}
+	c.set(ctx, key, results)
+}
+
+// Invalidate strands the tenant's cached results of collection, or of all
+// its collections when collection is empty.
+func (c *redisResultCache) Invalidate(ctx context.Context, tenantID, collection string) {
+	if c == nil {
+		return
+	}
+	if err := c.client.Incr(ctx, generationKey(tenantID, collection)).Err(); err != nil {
+		log.Printf("⚠️  Failed to invalidate cached results of %s/%s: %v", tenantID, collection, err)
+	}
+}
+
+func (c *redisResultCache) get(ctx context.Context, key string, v interface{}) bool {
+	b, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("⚠️  Result cache unavailable: %v", err)
+		}
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func (c *redisResultCache) set(ctx context.Context, key string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(ctx, key, b, c.ttl).Err(); err != nil {
+		log.Printf("⚠️  Failed to cache %s: %v", key, err)
+	}
+}
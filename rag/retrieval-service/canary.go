@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// EMBEDDING CANARY
+// ============================================================================
+// A sampled fraction of live queries is also embedded with a candidate model
+// and searched against a shadow collection indexed with that model. Rank
+// agreement and latency against the production path are sent to the eval
+// service, so a model upgrade can be judged before reindexing everything.
+// The replay runs in the background and never delays or changes the response.
+
+var (
+	EVAL_SERVICE_URL = getEnv("EVAL_SERVICE_URL", "http://localhost:8095")
+
+	BASELINE_EMBED_MODEL = getEnv("EMBED_MODEL", "text-embedding-004")
+	CANARY_EMBED_MODEL   = getEnv("CANARY_EMBED_MODEL", "") // empty disables the canary
+	CANARY_FRACTION      = envFloat("CANARY_FRACTION", 0.05)
+	CANARY_SHADOW_SUFFIX = getEnv("CANARY_SHADOW_SUFFIX", "_canary")
+)
+
+const (
+	canaryTimeout  = 30 * time.Second
+	rboPersistence = 0.9 // weight of each rank relative to the one above it
+)
+
+// canaryObservation mirrors eval-service's CanaryObservation.
+type canaryObservation struct {
+	BaselineModel    string  `json:"baseline_model"`
+	CandidateModel   string  `json:"candidate_model"`
+	Collection       string  `json:"collection"`
+	ShadowCollection string  `json:"shadow_collection"`
+	Query            string  `json:"query"`
+	TopK             int     `json:"top_k"`
+	Overlap          float64 `json:"overlap"`
+	RBO              float64 `json:"rbo"`
+	Top1Match        bool    `json:"top1_match"`
+	BaselineMs       float64 `json:"baseline_ms"`
+	CandidateMs      float64 `json:"candidate_ms"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// maybeRunCanary samples req and, if selected, replays it against the
+// candidate model in the background. baseline holds the vector hits of the
+// production path before enrichment and reranking, which is what the shadow
+// search is comparable to.
+func maybeRunCanary(ctx context.Context, req RetrievalRequest, baseline []RetrievalResult, baselineLatency time.Duration) {
+	if CANARY_EMBED_MODEL == "" || requestModel(req).custom() || rand.Float64() >= CANARY_FRACTION {
+		return
+	}
+
+	baselineIDs := make([]string, len(baseline))
+	for i, r := range baseline {
+		baselineIDs[i] = r.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), canaryTimeout)
+	go func() {
+		defer cancel()
+
+		obs := canaryObservation{
+			BaselineModel:    BASELINE_EMBED_MODEL,
+			CandidateModel:   CANARY_EMBED_MODEL,
+			Collection:       req.Collection,
+			ShadowCollection: req.Collection + CANARY_SHADOW_SUFFIX,
+			Query:            req.Query,
+			TopK:             req.TopK,
+			BaselineMs:       float64(baselineLatency.Milliseconds()),
+		}
+
+		start := time.Now()
+		candidateIDs, err := canarySearch(ctx, obs.ShadowCollection, req)
+		obs.CandidateMs = float64(time.Since(start).Milliseconds())
+		if err != nil {
+			obs.Error = err.Error()
+		} else {
+			obs.Overlap = overlapAtK(baselineIDs, candidateIDs, req.TopK)
+			obs.RBO = rankBiasedOverlap(baselineIDs, candidateIDs, rboPersistence)
+			obs.Top1Match = len(baselineIDs) > 0 && len(candidateIDs) > 0 && baselineIDs[0] == candidateIDs[0]
+		}
+
+		if err := httpClient.PostJSON(ctx, EVAL_SERVICE_URL+"/canary/observations", obs, nil); err != nil {
+			log.Printf("⚠️  Failed to report canary observation: %v", err)
+			return
+		}
+		log.Printf("🐤 Canary %s vs %s: overlap=%.2f rbo=%.2f latency Δ=%.0fms",
+			obs.CandidateModel, obs.BaselineModel, obs.Overlap, obs.RBO, obs.CandidateMs-obs.BaselineMs)
+	}()
+}
+
+// canarySearch embeds the query with the candidate model and returns the
+// chunk IDs found in the shadow collection, best first.
+func canarySearch(ctx context.Context, shadow string, req RetrievalRequest) ([]string, error) {
+	var embedded struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed", map[string]string{
+		"text":  req.Query,
+		"model": CANARY_EMBED_MODEL,
+	}, &embedded, httpclient.Idempotent)
+	if err != nil {
+		return nil, fmt.Errorf("candidate embedding failed: %w", err)
+	}
+
+	results, err := searchVectorDB(ctx, shadow, embedded.Embedding, req.TopK, req.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("shadow search failed: %w", err)
+	}
+
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids, nil
+}
+
+// overlapAtK is the fraction of the top k IDs the two rankings share.
+func overlapAtK(a, b []string, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+	seen := make(map[string]bool, len(a))
+	for i := 0; i < len(a) && i < k; i++ {
+		seen[a[i]] = true
+	}
+	shared := 0
+	for i := 0; i < len(b) && i < k; i++ {
+		if seen[b[i]] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(k)
+}
+
+// rankBiasedOverlap is RBO truncated at the shorter list's depth and
+// normalised so identical rankings score 1. Agreement near the top of the
+// list weighs more than agreement further down.
+func rankBiasedOverlap(a, b []string, p float64) float64 {
+	depth := len(a)
+	if len(b) < depth {
+		depth = len(b)
+	}
+	if depth == 0 {
+		return 0
+	}
+
+	inA := make(map[string]bool, depth)
+	inB := make(map[string]bool, depth)
+	shared := 0
+	sum := 0.0
+	for d := 1; d <= depth; d++ {
+		x, y := a[d-1], b[d-1]
+		if x == y {
+			shared++
+		} else {
+			if inB[x] {
+				shared++
+			}
+			if inA[y] {
+				shared++
+			}
+		}
+		inA[x] = true
+		inB[y] = true
+		sum += math.Pow(p, float64(d-1)) * float64(shared) / float64(d)
+	}
+	return (1 - p) * sum / (1 - math.Pow(p, float64(depth)))
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(getEnv(key, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/flags"
+)
+
+// ============================================================================
+// COLLECTION SELECTION
+// ============================================================================
+// A request without a collection (or with "collection": "auto") has its
+// collection picked from the query instead of defaulting to
+// regulatory_docs. The query is classified by the phrasings in
+// collectionCues, without a model call: the collection whose cues it
+// matches most is searched, every collection tied for most is, and all of
+// them are when it matches none. Several collections are searched with the
+// same embedding and their results merged by score; a collection whose
+// search fails is left out. The response lists the "collections"
+// searched. The retrieval_auto_collection flag turns selection off,
+// restoring the regulatory_docs default for requests without a collection.
+
+const collectionAuto = "auto"
+
+var autoCollectionFlag = flags.Define("retrieval_auto_collection", true,
+	"Pick the collection of a request without one from its query")
+
+// collectionCues - The phrasings that mark a query as about each
+// collection, in the order ties are listed
+var collectionCues = []struct {
+	collection string
+	pattern    *regexp.Regexp
+}{
+	{"kyc_docs", regexp.MustCompile(`\b(kyc|know your customer|customer due diligence|cdd|ckyc|v-?cip|video kyc|aadhaar|pan|officially valid documents?|ovd|beneficial owners?|identity verification|re-?kyc|pep)\b`)},
+	{"merchant_docs", regexp.MustCompile(`\b(merchants?|onboarding|settlements?|chargebacks?|refunds?|mdr|payment aggregators?|pa|pg|payment gateways?|escrow|payouts?|sellers?|pos|terminals?)\b`)},
+	{"regulatory_docs", regexp.MustCompile(`\b(rbi|sebi|npci|circulars?|master directions?|regulations?|regulatory|compliance|penalt(y|ies)|notifications?|act|amendments?|guidelines|reporting|audit)\b`)},
+}
+
+// selectCollections returns the collections to search for req and why, or
+// nil when req names its collection or selection is off.
+func selectCollections(req RetrievalRequest) ([]string, string) {
+	if req.Collection != collectionAuto && (req.Collection != "" || !autoCollectionFlag.Enabled()) {
+		return nil, ""
+	}
+
+	query := strings.ToLower(req.Query)
+	best := 0
+	var selected, matched []string
+	for _, c := range collectionCues {
+		seen := make(map[string]bool)
+		for _, m := range c.pattern.FindAllString(query, -1) {
+			seen[m] = true
+		}
+		switch {
+		case len(seen) > best:
+			best, selected = len(seen), []string{c.collection}
+			matched = sortedKeys(seen)
+		case len(seen) == best && best > 0:
+			selected = append(selected, c.collection)
+			matched = append(matched, sortedKeys(seen)...)
+		}
+	}
+	if best == 0 {
+		for _, c := range collectionCues {
+			selected = append(selected, c.collection)
+		}
+		return selected, "no collection cues"
+	}
+	return selected, "matched " + strings.Join(matched, ", ")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// retrieveAcross runs req against each of collections with the same
+// vectors and merges their results by score.
+func retrieveAcross(ctx context.Context, req RetrievalRequest, vectors *queryVectors, collections []string) (*RetrievalResponse, error) {
+	startTime := time.Now()
+	if req.TopK == 0 {
+		req.TopK = 5
+	}
+	if vectors == nil {
+		var err error
+		if vectors, err = embedRequest(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
+	// The token budget and grouping apply to the merged results, not each
+	// collection's
+	budget, group := req.MaxContextTokens, req.GroupByDocument
+	req.MaxContextTokens, req.GroupByDocument = 0, false
+
+	responses := make([]*RetrievalResponse, len(collections))
+	errs := make([]error, len(collections))
+	var wg sync.WaitGroup
+	for i, collection := range collections {
+		wg.Add(1)
+		go func(i int, collection string) {
+			defer wg.Done()
+			pass := req
+			pass.Collection = collection
+			responses[i], errs[i] = runRetrieval(ctx, pass, vectors)
+		}(i, collection)
+	}
+	wg.Wait()
+
+	response := &RetrievalResponse{Query: req.Query, Cached: true}
+	for i, r := range responses {
+		if errs[i] != nil {
+			// The other collections are still an answer
+			log.Printf("⚠️  Searching %s failed: %v", collections[i], errs[i])
+			continue
+		}
+		response.Results = append(response.Results, r.Results...)
+		response.Collections = append(response.Collections, collections[i])
+		response.Queries = r.Queries
+		response.QueryLanguage = r.QueryLanguage
+		response.Cached = response.Cached && r.Cached
+		response.Degraded = response.Degraded || r.Degraded
+		if r.Widened != nil && response.Widened == nil {
+			response.Widened = r.Widened
+		}
+	}
+	if len(response.Collections) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	sort.SliceStable(response.Results, func(a, b int) bool {
+		return response.Results[a].Score > response.Results[b].Score
+	})
+	response.Results = response.Results[:min(max(req.TopK, req.MinResults), len(response.Results))]
+	if budget > 0 {
+		response.Results, response.ContextTokens = applyTokenBudget(response.Results, budget)
+		addHighlights(req.Query, response.Results)
+	}
+	if group {
+		response.Results = groupByDocument(response.Results)
+	}
+	response.Count = len(response.Results)
+	response.ProcessTime = float64(time.Since(startTime).Milliseconds())
+	log.Printf("✅ Retrieval across %s completed in %.0fms (returned %d results)",
+		strings.Join(response.Collections, ", "), response.ProcessTime, response.Count)
+	return response, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// ============================================================================
+// CONTEXT ASSEMBLY
+// ============================================================================
+// POST /retrieve/context takes a retrieval request and returns, besides the
+// results, a "context" string ready to paste into a prompt, so callers
+// don't each pack passages themselves:
+//
+//	[1] PA Guidelines.pdf
+//	<text of the best result>
+//
+//	---
+//
+//	[2] KYC Master Direction.pdf
+//	...
+//
+// Each passage starts with its citation marker and source, and passages
+// are separated by CONTEXT_SEPARATOR ("---" by default, or "separator" in
+// the request). Results go in rank order until max_tokens
+// (CONTEXT_MAX_TOKENS, default 2000) would be exceeded; the first passage
+// that doesn't fit is cut at a word boundary when at least
+// minContextPassageTokens of it would be left, and the rest are dropped.
+// Tokens are estimated at charsPerToken characters each. "citations" maps
+// each marker back to its result.
+
+var (
+	CONTEXT_MAX_TOKENS = envInt("CONTEXT_MAX_TOKENS", 2000)
+	CONTEXT_SEPARATOR  = getEnv("CONTEXT_SEPARATOR", "---")
+)
+
+const (
+	charsPerToken           = 4
+	minContextPassageTokens = 50
+)
+
+// ContextRequest - A retrieval request and how to pack its results
+type ContextRequest struct {
+	RetrievalRequest
+	MaxTokens int    `json:"max_tokens"` // Optional: token budget of the context (default CONTEXT_MAX_TOKENS)
+	Separator string `json:"separator"`  // Optional: line between passages (default CONTEXT_SEPARATOR)
+}
+
+// ContextResponse - The results of a retrieval packed into a prompt context
+type ContextResponse struct {
+	RetrievalResponse
+	Context   string            `json:"context"`   // The passages, ready for a prompt
+	Citations []ContextCitation `json:"citations"` // What each [n] marker in the context quotes
+	Tokens    int               `json:"tokens"`    // Estimated tokens of the context
+	Truncated bool              `json:"truncated"` // Whether results were cut or left out to fit max_tokens
+}
+
+// ContextCitation - Maps a [n] marker in the context to a result
+type ContextCitation struct {
+	Marker     int     `json:"marker"`
+	ID         string  `json:"id"`
+	DocumentID string  `json:"document_id"`
+	Source     string  `json:"source"`
+	Score      float64 `json:"score"`
+}
+
+func retrieveContextHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ContextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateRequest(req.RetrievalRequest); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MaxTokens < 0 {
+		respondError(w, "max_tokens must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = CONTEXT_MAX_TOKENS
+	}
+	if req.Separator == "" {
+		req.Separator = CONTEXT_SEPARATOR
+	}
+
+	response, err := retrieve(r.Context(), req.RetrievalRequest)
+	if errors.Is(err, errPageExpired) {
+		respondError(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	packed := assembleContext(response.Results, req.MaxTokens, req.Separator)
+	packed.RetrievalResponse = *response
+	log.Printf("   ✓ Packed %d of %d results into %d tokens of context",
+		len(packed.Citations), len(response.Results), packed.Tokens)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(packed)
+}
+
+// assembleContext packs results, best first, into at most maxTokens of
+// context.
+func assembleContext(results []RetrievalResult, maxTokens int, separator string) *ContextResponse {
+	packed := &ContextResponse{Citations: []ContextCitation{}}
+	var b strings.Builder
+	joint := "\n\n" + separator + "\n\n"
+
+	for _, r := range results {
+		marker := len(packed.Citations) + 1
+		source := r.Source
+		if source == "" {
+			source = r.DocumentID
+		}
+		head := fmt.Sprintf("[%d] %s\n", marker, source)
+		if b.Len() > 0 {
+			head = joint + head
+		}
+		text := strings.TrimSpace(r.Text)
+
+		left := maxTokens - estimateTokens(b.String()+head)
+		if estimateTokens(text) > left {
+			packed.Truncated = true
+			if left < minContextPassageTokens {
+				break
+			}
+			text = truncateWords(text, left*charsPerToken)
+		}
+
+		b.WriteString(head)
+		b.WriteString(text)
+		packed.Citations = append(packed.Citations, ContextCitation{
+			Marker:     marker,
+			ID:         r.ID,
+			DocumentID: r.DocumentID,
+			Source:     source,
+			Score:      r.Score,
+		})
+		if packed.Truncated {
+			break
+		}
+	}
+
+	packed.Context = b.String()
+	packed.Tokens = estimateTokens(packed.Context)
+	return packed
+}
+
+// estimateTokens estimates the tokens of text at charsPerToken characters
+// each, rounding up.
+func estimateTokens(text string) int {
+	return (len([]rune(text)) + charsPerToken - 1) / charsPerToken
+}
+
+// truncateWords cuts text to at most limit characters, at the last word
+// boundary, and marks the cut with "...".
+func truncateWords(text string, limit int) string {
+	runes := []rune(text)
+	limit -= 3 // room for the "..."
+	if limit <= 0 {
+		return ""
+	}
+	if len(runes) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = limit
+	}
+	return strings.TrimSpace(string(runes[:cut])) + "..."
+}
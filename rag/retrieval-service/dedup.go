@@ -0,0 +1,106 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// ============================================================================
+// DEDUPLICATION
+// ============================================================================
+// Ingesting a circular twice, or two versions of it, puts nearly identical
+// chunks in a collection, which would fill the results with one passage.
+// With the retrieval_dedup flag on, or "dedup": true, results whose texts
+// share at least DEDUP_THRESHOLD (default 0.85) of their word 3-shingles
+// (Jaccard similarity) are collapsed into the best scoring one, whose
+// metadata.duplicates lists the others. The search then fetches twice top_k
+// results, so collapsed slots are refilled.
+
+var DEDUP_THRESHOLD = envFloat("DEDUP_THRESHOLD", 0.85)
+
+const (
+	shingleWords         = 3
+	dedupCandidateFactor = 2
+)
+
+// dedupSearch reports whether req's results are deduplicated.
+func dedupSearch(req RetrievalRequest) bool {
+	if req.Dedup != nil {
+		return *req.Dedup
+	}
+	return dedupFlag.Enabled()
+}
+
+// dedupResults drops each result nearly identical to a better one before it
+// in results, which are best first, and notes it on the one kept.
+func dedupResults(results []RetrievalResult) []RetrievalResult {
+	kept := make([]RetrievalResult, 0, len(results))
+	keptShingles := make([]map[uint64]bool, 0, len(results))
+	var duplicates map[int][]interface{} // by index in kept
+
+	for _, r := range results {
+		s := shingles(r.Text)
+		dup := -1
+		for i, other := range keptShingles {
+			if jaccard(s, other) >= DEDUP_THRESHOLD {
+				dup = i
+				break
+			}
+		}
+		if dup < 0 {
+			kept = append(kept, r)
+			keptShingles = append(keptShingles, s)
+			continue
+		}
+		if duplicates == nil {
+			duplicates = make(map[int][]interface{})
+		}
+		duplicates[dup] = append(duplicates[dup], map[string]interface{}{
+			"id":          r.ID,
+			"document_id": r.DocumentID,
+			"source":      r.Source,
+			"score":       r.Score,
+		})
+	}
+
+	for i, dups := range duplicates {
+		// A copy, as the payload map can be shared with the keyword index
+		metadata := make(map[string]interface{}, len(kept[i].Metadata)+1)
+		for k, v := range kept[i].Metadata {
+			metadata[k] = v
+		}
+		metadata["duplicates"] = dups
+		kept[i].Metadata = metadata
+	}
+	return kept
+}
+
+// shingles hashes the runs of shingleWords consecutive terms of text, or
+// all of them when there are fewer.
+func shingles(text string) map[uint64]bool {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[uint64]bool, len(words))
+	for i := 0; i+shingleWords <= len(words) || (i == 0 && len(words) > 0); i++ {
+		end := min(i+shingleWords, len(words))
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		set[h.Sum64()] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
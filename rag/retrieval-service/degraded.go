@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"shared/flags"
+	"shared/httpclient"
+)
+
+// ============================================================================
+// DEGRADED MODE (KEYWORD ONLY)
+// ============================================================================
+// When the query can't be embedded, because the embed service or the
+// Gemini API behind it is down, the request is answered by keyword search
+// alone instead of failing. The collection's BM25 index (see lexical.go)
+// ranks its chunks in place of the vector search, each score scaled by the
+// best one to 0-1, and enrichment, reranking, matches, recency, dedup,
+// context and min_score run on them as usual. The response says
+// "degraded": true and isn't cached.
+//
+// The embed service rejecting the request (a 4xx, such as an unknown
+// embedding_model) is still an error, as is a cancelled request. The
+// retrieval_keyword_fallback flag turns the fallback off.
+
+var keywordFallbackFlag = flags.Define("retrieval_keyword_fallback", true,
+	"Answer by BM25 keyword search alone when the query can't be embedded")
+
+// keywordFallback reports whether a query whose embedding failed with err
+// should be searched by keywords alone.
+func keywordFallback(ctx context.Context, err error) bool {
+	if !keywordFallbackFlag.Enabled() || ctx.Err() != nil {
+		return false
+	}
+	var se *httpclient.StatusError
+	return !errors.As(err, &se) || se.StatusCode >= http.StatusInternalServerError
+}
+
+// searchKeywordsOnly ranks collection's chunks by BM25 for a query that
+// couldn't be embedded, scaling the scores by the best one.
+func searchKeywordsOnly(ctx context.Context, collection, query string, topK int, filters map[string]interface{}) ([]RetrievalResult, error) {
+	results, err := searchKeywords(ctx, collection, query, topK, filters)
+	if err != nil || len(results) == 0 {
+		return results, err
+	}
+	best := results[0].Score
+	for i := range results {
+		if best > 0 {
+			results[i].Score /= best
+		}
+	}
+	return results, nil
+}
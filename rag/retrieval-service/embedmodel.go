@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ============================================================================
+// EMBEDDING MODEL
+// ============================================================================
+// A collection can only be searched with vectors from the model, and at the
+// output size, it was indexed with. Queries are embedded with the embed
+// service's default model (EMBED_MODEL, text-embedding-004) unless the
+// request names another:
+//
+//	"embedding_model": "gemini-embedding-001", "embedding_dimensions": 768
+//
+// Both are forwarded to the embed service for every text the query
+// embeds: the query itself, a HyDE passage, multi_query variants and a
+// translation. embedding_dimensions truncates the vectors to that size and
+// must match the collection's. Requests naming either are embedded over
+// HTTP, as the gRPC embed call takes neither, and aren't replayed by the
+// canary (see canary.go), which compares against the default model.
+
+var embeddingModelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+const maxEmbeddingDimensions = 3072
+
+// embeddingModel - The model and output size a query is embedded with;
+// zero values leave them to the embed service
+type embeddingModel struct {
+	Name       string
+	Dimensions int
+}
+
+// requestModel is the embedding model req asks for.
+func requestModel(req RetrievalRequest) embeddingModel {
+	return embeddingModel{Name: req.EmbeddingModel, Dimensions: req.EmbeddingDimensions}
+}
+
+// custom reports whether m differs from the embed service's default.
+func (m embeddingModel) custom() bool {
+	return m.Name != "" || m.Dimensions > 0
+}
+
+// validate checks m can be forwarded to the embed service.
+func (m embeddingModel) validate() error {
+	if m.Name != "" && !embeddingModelPattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid embedding_model %q", m.Name)
+	}
+	if m.Dimensions < 0 || m.Dimensions > maxEmbeddingDimensions {
+		return fmt.Errorf("embedding_dimensions must be between 1 and %d", maxEmbeddingDimensions)
+	}
+	return nil
+}
+
+// key identifies the vectors m produces, for the embeddings cache.
+func (m embeddingModel) key() string {
+	name := m.Name
+	if name == "" {
+		name = BASELINE_EMBED_MODEL
+	}
+	if m.Dimensions > 0 {
+		return name + "/" + strconv.Itoa(m.Dimensions)
+	}
+	return name
+}
+
+// addTo sets m's fields on an embed service request body.
+func (m embeddingModel) addTo(body map[string]interface{}) map[string]interface{} {
+	if m.Name != "" {
+		body["model"] = m.Name
+	}
+	if m.Dimensions > 0 {
+		body["dimensions"] = m.Dimensions
+	}
+	return body
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// EVALUATION
+// ============================================================================
+// POST /evaluate runs labelled queries through the pipeline as configured
+// and scores the documents each retrieves against the ones it should:
+//
+//	recall_at_k  share of the expected documents among the top_k results
+//	mrr          1 / rank of the first expected document (0 when missing)
+//	ndcg_at_k    DCG of the ranking over that of a perfect one, each
+//	             expected document counting 1
+//	hit_rate     whether any expected document was retrieved
+//
+// Documents are ranked by their best result, so a document with several
+// matching chunks counts once. The request's other fields (top_k, rerank,
+// hybrid, strategy, ...) apply to every case, which makes comparing
+// rerankers or chunking settings one call each. Up to EVALUATE_MAX_CASES
+// cases (default 200) are run in batches, see batch.go; a failed case is
+// reported and left out of the averages. For stored datasets and run
+// history use platform/eval-service.
+
+var EVALUATE_MAX_CASES = envInt("EVALUATE_MAX_CASES", 200)
+
+// EvaluateRequest - Labelled queries and the retrieval settings to run
+// them with
+type EvaluateRequest struct {
+	RetrievalRequest
+	Cases []EvaluationCase `json:"cases"`
+}
+
+// EvaluationCase - A query and the documents it should retrieve
+type EvaluationCase struct {
+	Query               string   `json:"query"`
+	ExpectedDocumentIDs []string `json:"expected_document_ids"`
+	Collection          string   `json:"collection,omitempty"` // Optional: overrides the request's
+}
+
+// EvaluateResponse - Averaged and per-case scores
+type EvaluateResponse struct {
+	K           int                    `json:"k"`               // top_k each case was searched with
+	Config      map[string]interface{} `json:"config"`          // The pipeline settings the cases ran with
+	Metrics     map[string]float64     `json:"metrics"`         // Averages over the cases that succeeded
+	Cases       []EvaluationResult     `json:"cases"`           // One per case, in order
+	Count       int                    `json:"count"`           // Number of cases
+	Failed      int                    `json:"failed"`          // Cases whose retrieval failed
+	ProcessTime float64                `json:"process_time_ms"` // How long the evaluation took (milliseconds)
+}
+
+// EvaluationResult - How one case scored
+type EvaluationResult struct {
+	Query                string             `json:"query"`
+	ExpectedDocumentIDs  []string           `json:"expected_document_ids"`
+	RetrievedDocumentIDs []string           `json:"retrieved_document_ids"` // In rank order, each once
+	Metrics              map[string]float64 `json:"metrics,omitempty"`
+	Error                string             `json:"error,omitempty"`
+}
+
+func evaluateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Cases) == 0 || len(req.Cases) > EVALUATE_MAX_CASES {
+		respondError(w, fmt.Sprintf("cases must hold 1 to %d queries", EVALUATE_MAX_CASES), http.StatusBadRequest)
+		return
+	}
+	if req.TopK == 0 {
+		req.TopK = 5
+	}
+	if req.PageToken != "" || req.Offset != 0 || req.Paginate || req.Stream {
+		respondError(w, "evaluation can't be paginated or streamed", http.StatusBadRequest)
+		return
+	}
+
+	queries := make([]RetrievalRequest, len(req.Cases))
+	for i, c := range req.Cases {
+		if len(c.ExpectedDocumentIDs) == 0 {
+			respondError(w, fmt.Sprintf("cases[%d]: expected_document_ids cannot be empty", i), http.StatusBadRequest)
+			return
+		}
+		queries[i] = req.RetrievalRequest
+		queries[i].Query = c.Query
+		if c.Collection != "" {
+			queries[i].Collection = c.Collection
+		}
+		if err := validateRequest(queries[i]); err != nil {
+			respondError(w, fmt.Sprintf("cases[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := evaluate(r.Context(), req, queries)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// evaluate runs queries, one per case of req, and scores them.
+func evaluate(ctx context.Context, req EvaluateRequest, queries []RetrievalRequest) *EvaluateResponse {
+	startTime := time.Now()
+	log.Printf("📏 Evaluation started: %d cases (TopK=%d)", len(queries), req.TopK)
+
+	response := &EvaluateResponse{
+		K: req.TopK,
+		Config: map[string]interface{}{
+			"collection": req.Collection,
+			"strategy":   req.Strategy,
+			"rerank":     rerankStrategy(req.RetrievalRequest),
+			"hybrid":     hybridSearch(req.RetrievalRequest),
+			"dedup":      dedupSearch(req.RetrievalRequest),
+		},
+		Metrics: map[string]float64{},
+		Cases:   make([]EvaluationResult, len(queries)),
+		Count:   len(queries),
+	}
+
+	for start := 0; start < len(queries); start += max(RETRIEVAL_BATCH_MAX_QUERIES, 1) {
+		end := min(start+max(RETRIEVAL_BATCH_MAX_QUERIES, 1), len(queries))
+		batch := retrieveBatch(ctx, queries[start:end])
+		for j, item := range batch.Responses {
+			c := req.Cases[start+j]
+			result := EvaluationResult{Query: c.Query, ExpectedDocumentIDs: c.ExpectedDocumentIDs, RetrievedDocumentIDs: []string{}}
+			if item.Error != "" {
+				result.Error = item.Error
+				response.Failed++
+			} else {
+				result.RetrievedDocumentIDs = rankedDocuments(item.Results)
+				result.Metrics = scoreRanking(c.ExpectedDocumentIDs, result.RetrievedDocumentIDs, req.TopK)
+				for name, v := range result.Metrics {
+					response.Metrics[name] += v
+				}
+			}
+			response.Cases[start+j] = result
+		}
+	}
+
+	if scored := response.Count - response.Failed; scored > 0 {
+		for name := range response.Metrics {
+			response.Metrics[name] /= float64(scored)
+		}
+	}
+	response.ProcessTime = float64(time.Since(startTime).Milliseconds())
+	log.Printf("✅ Evaluation completed in %.0fms: %v", response.ProcessTime, response.Metrics)
+	return response
+}
+
+// rankedDocuments lists the documents of results in rank order, each once.
+func rankedDocuments(results []RetrievalResult) []string {
+	docs := []string{}
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.DocumentID != "" && !seen[r.DocumentID] {
+			seen[r.DocumentID] = true
+			docs = append(docs, r.DocumentID)
+		}
+	}
+	return docs
+}
+
+// scoreRanking scores retrieved, best first, against the expected
+// documents.
+func scoreRanking(expected, retrieved []string, k int) map[string]float64 {
+	relevant := make(map[string]bool, len(expected))
+	for _, id := range expected {
+		relevant[id] = true
+	}
+
+	found := 0
+	mrr, dcg := 0.0, 0.0
+	for i, id := range retrieved {
+		if !relevant[id] {
+			continue
+		}
+		found++
+		if mrr == 0 {
+			mrr = 1 / float64(i+1)
+		}
+		dcg += 1 / math.Log2(float64(i+2))
+	}
+	idcg := 0.0
+	for i := 0; i < min(len(relevant), k); i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	hit := 0.0
+	if found > 0 {
+		hit = 1
+	}
+	return map[string]float64{
+		"recall_at_k": float64(found) / float64(len(relevant)),
+		"mrr":         mrr,
+		"ndcg_at_k":   dcg / idcg,
+		"hit_rate":    hit,
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"shared/tracing"
+)
+
+// ============================================================================
+// QUERY EXPANSION
+// ============================================================================
+// "strategy" chooses what is embedded for the vector search:
+//
+//	standard  the query itself (the default)
+//	hyde      a hypothetical answer RETRIEVAL_LLM_MODEL writes for the query
+//	          (Hypothetical Document Embeddings). A terse query such as "PA
+//	          net worth" lands far from the passages that answer it; a
+//	          passage written the way those documents are lands near them.
+//	multi_query  the query and MULTI_QUERY_VARIANTS (3-5, default 4) rewordings
+//	          of it RETRIEVAL_LLM_MODEL writes, searched concurrently and fused
+//	          by reciprocal rank (see fusion.go), so a chunk phrased unlike the
+//	          query is still found by the variant phrased like it.
+//
+// Keyword search and reranking still use the query. When the model can't
+// be reached, the query is embedded as in standard.
+
+const (
+	strategyStandard   = "standard"
+	strategyHyDE       = "hyde"
+	strategyMultiQuery = "multi_query"
+)
+
+var strategies = map[string]bool{
+	strategyStandard:   true,
+	strategyHyDE:       true,
+	strategyMultiQuery: true,
+}
+
+var MULTI_QUERY_VARIANTS = min(max(envInt("MULTI_QUERY_VARIANTS", 4), 3), 5)
+
+// validStrategy reports whether strategy is empty or known.
+func validStrategy(strategy string) bool {
+	return strategy == "" || strategies[strategy]
+}
+
+// hydeChars bounds the hypothetical answer, so it stays the size of a chunk.
+const hydeChars = 1500
+
+// queryVectors are what a request searches with.
+type queryVectors struct {
+	Embeddings [][]float32 `json:"embeddings"`        // one, or for multi_query one per query
+	Queries    []string    `json:"queries,omitempty"` // the query and its variants, for multi_query, and its translation, see language.go
+	Fallback   bool        `json:"-"`                 // the strategy or translation failed and the query was embedded instead
+}
+
+// embedForStrategy embeds what req.Strategy says to search with.
+func embedForStrategy(ctx context.Context, req RetrievalRequest) (*queryVectors, error) {
+	model := requestModel(req)
+	switch req.Strategy {
+	case strategyHyDE:
+		stepCtx, span := tracing.Start(ctx, "retrieval.hyde")
+		passage, err := hypotheticalAnswer(stepCtx, req.Query)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("⚠️  HyDE failed, embedding the query: %v", err)
+			return embedQuery(ctx, req.Query, model, true)
+		}
+		log.Printf("   ✓ Wrote a hypothetical answer (%d chars)", len(passage))
+		return embedQuery(ctx, passage, model, false)
+
+	case strategyMultiQuery:
+		stepCtx, span := tracing.Start(ctx, "retrieval.multi_query")
+		variants, err := queryVariants(stepCtx, req.Query)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("⚠️  Multi-query failed, embedding the query: %v", err)
+			return embedQuery(ctx, req.Query, model, true)
+		}
+		log.Printf("   ✓ Wrote %d query variants", len(variants))
+		queries := append([]string{req.Query}, variants...)
+		embeddings, err := embedAll(ctx, queries, model)
+		if err != nil {
+			return nil, err
+		}
+		return &queryVectors{Embeddings: embeddings, Queries: queries}, nil
+	}
+	return embedQuery(ctx, req.Query, model, false)
+}
+
+func embedQuery(ctx context.Context, text string, model embeddingModel, fallback bool) (*queryVectors, error) {
+	embedding, err := getQueryEmbedding(ctx, text, model)
+	if err != nil {
+		return nil, err
+	}
+	return &queryVectors{Embeddings: [][]float32{embedding}, Fallback: fallback}, nil
+}
+
+// embedAll embeds queries concurrently.
+func embedAll(ctx context.Context, queries []string, model embeddingModel) ([][]float32, error) {
+	embeddings := make([][]float32, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			embeddings[i], errs[i] = getQueryEmbedding(ctx, query, model)
+		}(i, query)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return embeddings, nil
+}
+
+// searchEmbeddings runs a vector search per embedding concurrently and fuses
+// the rankings by reciprocal rank. A single embedding's hits are returned as
+// the vector service scored them. Searches that fail are left out of the
+// fusion unless all of them fail.
+func searchEmbeddings(ctx context.Context, collection string, embeddings [][]float32, limit int, filters map[string]interface{}) ([]RetrievalResult, error) {
+	if len(embeddings) == 1 {
+		return searchVectorDB(ctx, collection, embeddings[0], limit, filters)
+	}
+
+	rankings := make([][]RetrievalResult, len(embeddings))
+	errs := make([]error, len(embeddings))
+	var wg sync.WaitGroup
+	for i, embedding := range embeddings {
+		wg.Add(1)
+		go func(i int, embedding []float32) {
+			defer wg.Done()
+			rankings[i], errs[i] = searchVectorDB(ctx, collection, embedding, limit, filters)
+		}(i, embedding)
+	}
+	wg.Wait()
+
+	var found [][]RetrievalResult
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("⚠️  Search for query variant %d failed: %v", i, err)
+			continue
+		}
+		found = append(found, rankings[i])
+	}
+	if len(found) == 0 {
+		return nil, errs[0]
+	}
+	return fuseRRF(limit, found...), nil
+}
+
+// hypotheticalAnswer asks the model for a passage answering query.
+func hypotheticalAnswer(ctx context.Context, query string) (string, error) {
+	prompt := fmt.Sprintf("Write a passage of about 100 words, as it would appear in a regulatory, "+
+		"compliance or merchant policy document, that answers the question below. State the "+
+		"answer directly as the document would, without hedging or mentioning the question.\n\n"+
+		"Question: %s", query)
+	passage, err := generateContent(ctx, RETRIEVAL_LLM_MODEL, prompt, false)
+	if err != nil {
+		return "", err
+	}
+	passage = strings.TrimSpace(passage)
+	if passage == "" {
+		return "", fmt.Errorf("%s wrote an empty passage", RETRIEVAL_LLM_MODEL)
+	}
+	if runes := []rune(passage); len(runes) > hydeChars {
+		passage = string(runes[:hydeChars])
+	}
+	return passage, nil
+}
+
+// queryVariants asks the model for MULTI_QUERY_VARIANTS rewordings of query.
+func queryVariants(ctx context.Context, query string) ([]string, error) {
+	prompt := fmt.Sprintf("Write %d different versions of the search query below, to find passages in "+
+		"regulatory, compliance and merchant policy documents that answer it. Vary the wording and "+
+		"terminology (synonyms, formal and informal terms, expanded abbreviations) but keep the meaning.\n"+
+		"Reply with only a JSON array of %d strings.\n\nQuery: %s", MULTI_QUERY_VARIANTS, MULTI_QUERY_VARIANTS, query)
+	text, err := generateContent(ctx, RETRIEVAL_LLM_MODEL, prompt, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	if err := json.Unmarshal([]byte(text), &written); err != nil {
+		return nil, fmt.Errorf("%s returned unreadable variants: %w", RETRIEVAL_LLM_MODEL, err)
+	}
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	var variants []string
+	for _, v := range written {
+		v = strings.TrimSpace(v)
+		if key := strings.ToLower(v); v != "" && !seen[key] {
+			seen[key] = true
+			variants = append(variants, v)
+		}
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%s wrote no query variants", RETRIEVAL_LLM_MODEL)
+	}
+	if len(variants) > MULTI_QUERY_VARIANTS {
+		variants = variants[:MULTI_QUERY_VARIANTS]
+	}
+	return variants, nil
+}
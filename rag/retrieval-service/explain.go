@@ -0,0 +1,98 @@
+package main
+
+// ============================================================================
+// SCORE EXPLANATION
+// ============================================================================
+// "explain": true adds to each result how its score came about, to debug
+// why one chunk outranked another:
+//
+//	"explanation": {
+//	  "vector_score": 0.82,       cosine similarity to the query
+//	  "keyword_score": 7.4,       BM25 score, for hybrid or keyword-only search
+//	  "search_score": 0.95,       what the search ranked it by, fused for hybrid
+//	  "keyword_match": 0.67,      share of query terms in the text, for the
+//	                              keyword reranker
+//	  "steps": [                  each later change to the score
+//	    {"step": "rerank:keyword", "before": 0.95, "after": 0.87},
+//	    {"step": "match", "before": 0.87, "after": 0.97},
+//	    {"step": "recency", "before": 0.97, "after": 0.79}
+//	  ],
+//	  "final_score": 0.79
+//	}
+//
+// For multi_query and a translated query, vector_score is already fused
+// across the queries (see fusion.go); in keyword-only search keyword_score
+// is scaled as described in degraded.go. Explained results are cached
+// apart from unexplained ones.
+
+// ScoreExplanation - How a result's score came about
+type ScoreExplanation struct {
+	VectorScore  *float64    `json:"vector_score,omitempty"`
+	KeywordScore *float64    `json:"keyword_score,omitempty"`
+	SearchScore  float64     `json:"search_score"`
+	KeywordMatch *float64    `json:"keyword_match,omitempty"`
+	Steps        []ScoreStep `json:"steps,omitempty"`
+	FinalScore   float64     `json:"final_score"`
+}
+
+// ScoreStep - A change to a result's score after the search
+type ScoreStep struct {
+	Step   string  `json:"step"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// explainSearch starts the explanation of each result from the vector and
+// keyword scores of its chunk, by ID.
+func explainSearch(results []RetrievalResult, vectorScores, keywordScores map[string]float64) {
+	for i, r := range results {
+		e := &ScoreExplanation{SearchScore: r.Score}
+		if score, ok := vectorScores[r.ID]; ok {
+			e.VectorScore = &score
+		}
+		if score, ok := keywordScores[r.ID]; ok {
+			e.KeywordScore = &score
+		}
+		results[i].Explanation = e
+	}
+}
+
+// explainScores maps each result's ID to its score, for explainStep, when
+// req explains its results.
+func explainScores(req RetrievalRequest, results []RetrievalResult) map[string]float64 {
+	if !req.Explain {
+		return nil
+	}
+	return scoresByID(results)
+}
+
+// explainStep records on each explained result how step changed its score
+// from before, by ID. Unchanged scores aren't recorded.
+func explainStep(step string, before map[string]float64, results []RetrievalResult) {
+	for _, r := range results {
+		if r.Explanation == nil {
+			continue
+		}
+		if score, ok := before[r.ID]; ok && score != r.Score {
+			r.Explanation.Steps = append(r.Explanation.Steps, ScoreStep{Step: step, Before: score, After: r.Score})
+		}
+	}
+}
+
+// explainFinal records each explained result's final score.
+func explainFinal(results []RetrievalResult) {
+	for _, r := range results {
+		if r.Explanation != nil {
+			r.Explanation.FinalScore = r.Score
+		}
+	}
+}
+
+// scoresByID maps each result's ID to its score.
+func scoresByID(results []RetrievalResult) map[string]float64 {
+	scores := make(map[string]float64, len(results))
+	for _, r := range results {
+		scores[r.ID] = r.Score
+	}
+	return scores
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// FILTERS
+// ============================================================================
+// "filters" restricts retrieval to chunks whose document matches every
+// filter given:
+//
+//	document_id    "doc-1" or ["doc-1", "doc-2"]     one of these documents
+//	document_type  "regulatory" or a list            one of these types ("type" is an alias)
+//	tags           "kyc" or ["kyc", "aml"]           tagged with any of these
+//	uploaded_at    {"gte": "2024-01-01", "lt": …}    uploaded in this range
+//	payload.<field>  "Net Worth", ["a", "b"], true,  any other payload attribute:
+//	               3 or {"gte": 2}                   equal to (any of) these or in range
+//
+// Range bounds are RFC 3339 times, dates (a whole UTC day, so "lte" a date
+// includes it) or Unix seconds, or for payload fields plain numbers. Filters are translated into the vector
+// service's payload conditions, which Qdrant applies during the search, and
+// the keyword index applies the same conditions itself.
+
+// filterFields maps the filter names a request may use to payload fields.
+var filterFields = map[string]string{
+	"document_id":   "document_id",
+	"document_type": "document_type",
+	"type":          "document_type",
+	"tags":          "tags",
+	"uploaded_at":   "uploaded_at",
+}
+
+// payloadFilterPrefix marks a filter on a payload field by its own name.
+const payloadFilterPrefix = "payload."
+
+const dateLayout = "2006-01-02"
+
+// normalizeFilters checks filters and translates them into payload
+// conditions (see vector-service filter.go): lists become []interface{} of
+// strings and ranges maps of Unix seconds.
+func normalizeFilters(filters map[string]interface{}) (map[string]interface{}, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	conditions := make(map[string]interface{}, len(filters))
+	for name, value := range filters {
+		field, ok := filterFields[name]
+		payloadField := false
+		if !ok && strings.HasPrefix(name, payloadFilterPrefix) {
+			field = strings.TrimPrefix(name, payloadFilterPrefix)
+			if field == "" || field == "tenant_id" {
+				return nil, fmt.Errorf("filter %q names no payload field that can be filtered", name)
+			}
+			ok, payloadField = true, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q, expected one of %s or %s<field>",
+				name, strings.Join(filterNames(), ", "), payloadFilterPrefix)
+		}
+		if _, dup := conditions[field]; dup {
+			return nil, fmt.Errorf("filter %q is given twice", field)
+		}
+
+		var (
+			condition interface{}
+			err       error
+		)
+		switch {
+		case payloadField:
+			condition, err = normalizeAttribute(name, value)
+		case field == "uploaded_at":
+			condition, err = normalizeRange(name, value)
+		default:
+			condition, err = normalizeKeywords(name, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions[field] = condition
+	}
+	return conditions, nil
+}
+
+func filterNames() []string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeKeywords accepts a string or a list of strings.
+func normalizeKeywords(name string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("filter %q is an empty list", name)
+		}
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return nil, fmt.Errorf("filter %q must be a string or a list of strings", name)
+			}
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("filter %q must be a string or a list of strings", name)
+}
+
+// normalizeAttribute accepts what a payload field can equal or range over:
+// keywords, a boolean, an integer or an object of bounds.
+func normalizeAttribute(name string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("filter %q must be an integer, or a range for decimals", name)
+		}
+		return v, nil
+	case map[string]interface{}:
+		return normalizeRange(name, v)
+	}
+	return normalizeKeywords(name, value)
+}
+
+// normalizeRange accepts an object of gt, gte, lt and lte bounds.
+func normalizeRange(name string, value interface{}) (interface{}, error) {
+	bounds, ok := value.(map[string]interface{})
+	if !ok || len(bounds) == 0 {
+		return nil, fmt.Errorf("filter %q must be an object of gt, gte, lt or lte bounds", name)
+	}
+	r := make(map[string]interface{}, len(bounds))
+	for op, bound := range bounds {
+		if op != "gt" && op != "gte" && op != "lt" && op != "lte" {
+			return nil, fmt.Errorf("filter %q has unknown bound %q", name, op)
+		}
+		switch b := bound.(type) {
+		case float64:
+			r[op] = b
+		case string:
+			if t, err := time.Parse(time.RFC3339, b); err == nil {
+				r[op] = float64(t.Unix())
+				continue
+			}
+			day, err := time.Parse(dateLayout, b)
+			if err != nil {
+				return nil, fmt.Errorf("filter %q: %s must be a date, an RFC 3339 time or Unix seconds", name, op)
+			}
+			// A date is the whole day: after it starts the next day, up to
+			// and including it ends where the next day starts
+			switch op {
+			case "gt":
+				op, day = "gte", day.AddDate(0, 0, 1)
+			case "lte":
+				op, day = "lt", day.AddDate(0, 0, 1)
+			}
+			r[op] = float64(day.Unix())
+		default:
+			return nil, fmt.Errorf("filter %q: %s must be a date, an RFC 3339 time or Unix seconds", name, op)
+		}
+	}
+	return r, nil
+}
+
+// matchesFilters reports whether payload meets every normalized condition,
+// as Qdrant would decide it.
+func matchesFilters(payload map[string]interface{}, conditions map[string]interface{}) bool {
+	for field, condition := range conditions {
+		if !matchesCondition(payload[field], condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesCondition(value, condition interface{}) bool {
+	// A list field matches when any element does
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if matchesCondition(item, condition) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch c := condition.(type) {
+	case string, bool:
+		return value == c
+	case float64:
+		n, ok := toFloat(value)
+		return ok && n == c
+	case []interface{}:
+		for _, want := range c {
+			if value == want {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		n, ok := toFloat(value)
+		if !ok {
+			return false
+		}
+		for op, bound := range c {
+			b := bound.(float64)
+			if (op == "gt" && n <= b) || (op == "gte" && n < b) || (op == "lt" && n >= b) || (op == "lte" && n > b) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
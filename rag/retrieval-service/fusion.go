@@ -0,0 +1,47 @@
+package main
+
+import "sort"
+
+// ============================================================================
+// RANK FUSION
+// ============================================================================
+// Reciprocal rank fusion merges rankings whose scores aren't comparable,
+// such as cosine similarity and BM25: a result scores 1/(k+rank) in every
+// ranking it appears in, and the sums are sorted. k damps the weight of the
+// top ranks, 60 being the usual choice.
+
+const rrfK = 60
+
+// fuseRRF merges rankings by reciprocal rank and returns the best topK.
+// A result found by several rankings keeps the fields of the first ranking
+// that has it. Scores are scaled so a result ranked first by every ranking
+// scores 1.
+func fuseRRF(topK int, rankings ...[]RetrievalResult) []RetrievalResult {
+	scores := make(map[string]float64)
+	first := make(map[string]RetrievalResult)
+	var order []string
+	for _, ranking := range rankings {
+		for rank, r := range ranking {
+			if _, ok := first[r.ID]; !ok {
+				first[r.ID] = r
+				order = append(order, r.ID)
+			}
+			scores[r.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	best := float64(len(rankings)) / float64(rrfK+1)
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	fused := make([]RetrievalResult, len(order))
+	for i, id := range order {
+		fused[i] = first[id]
+		fused[i].Score = scores[id] / best
+	}
+	return fused
+}
@@ -0,0 +1,33 @@
+package main
+
+// ============================================================================
+// DOCUMENT GROUPING
+// ============================================================================
+// "group_by_document": true returns one result per document instead of one
+// per chunk, so a UI can show "3 documents found" rather than 15 scattered
+// chunks. Each document's best ranked chunk is its result, in rank order,
+// and the document's other hits follow it as "siblings", best first;
+// "count" is then the number of documents. The search, top_k and every
+// other step work on chunks as usual, and grouping is the last step, so
+// results are cached ungrouped. Grouped results can't be streamed.
+
+// groupByDocument folds each result into the best ranked result of its
+// document. Results without a document stay on their own.
+func groupByDocument(results []RetrievalResult) []RetrievalResult {
+	grouped := make([]RetrievalResult, 0, len(results))
+	at := make(map[string]int) // document → index in grouped
+	for _, r := range results {
+		r.Siblings = nil
+		if r.DocumentID == "" {
+			grouped = append(grouped, r)
+			continue
+		}
+		if i, ok := at[r.DocumentID]; ok {
+			grouped[i].Siblings = append(grouped[i].Siblings, r)
+			continue
+		}
+		at[r.DocumentID] = len(grouped)
+		grouped = append(grouped, r)
+	}
+	return grouped
+}
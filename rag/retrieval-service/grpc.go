@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"protos/gorillapb"
+	"shared/rpc"
+)
+
+// gRPC clients for the vector-heavy hops. They stay nil unless
+// EMBED_GRPC_ADDR / VECTOR_GRPC_ADDR are set, in which case the query
+// embedding and search skip JSON entirely.
+var (
+	embedClient  gorillapb.EmbeddingServiceClient
+	vectorClient gorillapb.VectorServiceClient
+
+	grpcCallTimeout = 30 * time.Second
+)
+
+func initGRPCClients() {
+	if addr := getEnv("EMBED_GRPC_ADDR", ""); addr != "" {
+		conn, err := rpc.Dial(addr)
+		if err != nil {
+			log.Fatalf("Failed to dial embed-service gRPC: %v", err)
+		}
+		embedClient = gorillapb.NewEmbeddingServiceClient(conn)
+		log.Printf("   - Embed Service (gRPC):  %s", addr)
+	}
+	if addr := getEnv("VECTOR_GRPC_ADDR", ""); addr != "" {
+		conn, err := rpc.Dial(addr)
+		if err != nil {
+			log.Fatalf("Failed to dial vector-service gRPC: %v", err)
+		}
+		vectorClient = gorillapb.NewVectorServiceClient(conn)
+		log.Printf("   - Vector Service (gRPC): %s", addr)
+	}
+}
+
+func getQueryEmbeddingGRPC(ctx context.Context, query string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcCallTimeout)
+	defer cancel()
+
+	resp, err := embedClient.Embed(ctx, &gorillapb.EmbedRequest{Text: query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embed service: %w", err)
+	}
+	return resp.GetEmbedding().GetValues(), nil
+}
+
+func getQueryEmbeddingsGRPC(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcCallTimeout)
+	defer cancel()
+
+	resp, err := embedClient.EmbedBatch(ctx, &gorillapb.EmbedBatchRequest{Texts: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embed service: %w", err)
+	}
+	if len(resp.GetEmbeddings()) != len(texts) {
+		return nil, fmt.Errorf("embed service returned %d embeddings for %d texts", len(resp.GetEmbeddings()), len(texts))
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, v := range resp.GetEmbeddings() {
+		embeddings[i] = v.GetValues()
+	}
+	return embeddings, nil
+}
+
+func searchVectorDBGRPC(ctx context.Context, collection string, query []float32, topK int, filters map[string]interface{}) ([]RetrievalResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcCallTimeout)
+	defer cancel()
+
+	filterStruct, err := structpb.NewStruct(filters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	resp, err := vectorClient.Search(ctx, &gorillapb.SearchRequest{
+		Collection: collection,
+		Query:      &gorillapb.Vector{Values: query},
+		TopK:       int32(topK),
+		Filter:     filterStruct,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call vector service: %w", err)
+	}
+
+	results := make([]RetrievalResult, len(resp.GetResults()))
+	for i, hit := range resp.GetResults() {
+		results[i] = resultFromPayload(hit.GetId(), hit.GetScore(), hit.GetPayload().AsMap())
+	}
+	return results, nil
+}
+
+// retrievalServer exposes retrieve over the gorilla.v1.RetrievalService
+// contract.
+type retrievalServer struct {
+	gorillapb.UnimplementedRetrievalServiceServer
+}
+
+func (retrievalServer) Retrieve(ctx context.Context, req *gorillapb.RetrieveRequest) (*gorillapb.RetrieveResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query cannot be empty")
+	}
+	filters := make(map[string]interface{}, len(req.GetFilters()))
+	for k, v := range req.GetFilters() {
+		filters[k] = v
+	}
+	if _, err := normalizeFilters(filters); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := retrieve(ctx, RetrievalRequest{
+		Query:      req.GetQuery(),
+		TopK:       int(req.GetTopK()),
+		Collection: req.GetCollection(),
+		Filters:    filters,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	out := &gorillapb.RetrieveResponse{
+		Query:         response.Query,
+		Results:       make([]*gorillapb.RetrievalResult, len(response.Results)),
+		Count:         int32(response.Count),
+		ProcessTimeMs: response.ProcessTime,
+	}
+	for i, r := range response.Results {
+		metadata, err := structpb.NewStruct(r.Metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode metadata: %v", err)
+		}
+		out.Results[i] = &gorillapb.RetrievalResult{
+			Id:         r.ID,
+			Score:      r.Score,
+			Text:       r.Text,
+			DocumentId: r.DocumentID,
+			Source:     r.Source,
+			Metadata:   metadata,
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"unicode"
+)
+
+// ============================================================================
+// HIGHLIGHTS
+// ============================================================================
+// Each result carries "highlights": the passages of its text around words
+// matching a query term (as keyword search tokenizes them, so "KYC?"
+// matches "kyc" and stopwords match nothing), each with the matches in it.
+// A passage runs HIGHLIGHT_CONTEXT_CHARS characters (default 60) either
+// side of its matches, cut back to whole words; passages that would overlap
+// are one. Offsets count characters (Unicode code points) of the result's
+// text, ends exclusive, so front-ends can mark the text without matching
+// terms themselves.
+
+var HIGHLIGHT_CONTEXT_CHARS = envInt("HIGHLIGHT_CONTEXT_CHARS", 60)
+
+// Highlight - A passage of a result's text and the query terms in it
+type Highlight struct {
+	Snippet string      `json:"snippet"` // The passage
+	Start   int         `json:"start"`   // Where it starts in the text
+	End     int         `json:"end"`     // Where it ends in the text
+	Matches []TermMatch `json:"matches"` // Matched words, by their place in the text
+}
+
+// TermMatch - A word of the text matching a query term
+type TermMatch struct {
+	Term  string `json:"term"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// addHighlights sets the highlights of each result for query.
+func addHighlights(query string, results []RetrievalResult) {
+	terms := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		terms[t] = true
+	}
+	if len(terms) == 0 {
+		return
+	}
+	for i := range results {
+		results[i].Highlights = highlight(results[i].Text, terms)
+	}
+}
+
+// highlight finds the words of text in terms and the passages around them.
+func highlight(text string, terms map[string]bool) []Highlight {
+	runes := []rune(text)
+	var matches []TermMatch
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if word := toLowerString(runes[start:end]); terms[word] {
+			matches = append(matches, TermMatch{Term: word, Start: start, End: end})
+		}
+		start = end
+	}
+
+	var highlights []Highlight
+	for _, m := range matches {
+		from := max(m.Start-HIGHLIGHT_CONTEXT_CHARS, 0)
+		to := min(m.End+HIGHLIGHT_CONTEXT_CHARS, len(runes))
+		if n := len(highlights); n > 0 && from <= highlights[n-1].End {
+			last := &highlights[n-1]
+			last.End = max(last.End, to)
+			last.Matches = append(last.Matches, m)
+			continue
+		}
+		highlights = append(highlights, Highlight{Start: from, End: to, Matches: []TermMatch{m}})
+	}
+
+	for i := range highlights {
+		h := &highlights[i]
+		// Don't start or end inside a word
+		first, last := h.Matches[0], h.Matches[len(h.Matches)-1]
+		if h.Start > 0 && isWordRune(runes[h.Start-1]) {
+			for h.Start < first.Start && isWordRune(runes[h.Start]) {
+				h.Start++
+			}
+		}
+		if h.End < len(runes) && isWordRune(runes[h.End]) {
+			for h.End > last.End && isWordRune(runes[h.End-1]) {
+				h.End--
+			}
+		}
+		for h.Start < first.Start && unicode.IsSpace(runes[h.Start]) {
+			h.Start++
+		}
+		for h.End > last.End && unicode.IsSpace(runes[h.End-1]) {
+			h.End--
+		}
+		h.Snippet = string(runes[h.Start:h.End])
+	}
+	return highlights
+}
+
+// isWordRune reports whether r is part of a word, as tokenize splits them.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func toLowerString(runes []rune) string {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return string(lower)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"shared/tracing"
+)
+
+// ============================================================================
+// LANGUAGES
+// ============================================================================
+// Many merchant documents arrive in Hindi or Tamil while queries come in
+// English, and an English query embeds some way from a Hindi passage that
+// answers it. The query's language is detected from its script and
+// returned as "query_language"; each result carries the "language" of its
+// chunk, from the chunk's payload when ingestion set one, else detected
+// the same way.
+//
+// "translate_to": "hi" (or the collection's entry in CORPUS_LANGUAGES, as
+// "collection=language,...") has RETRIEVAL_LLM_MODEL translate a query in
+// another language into that one. Both the query and its translation are
+// then searched and the rankings fused by reciprocal rank (see fusion.go),
+// so a mixed-language collection still matches the original; "queries"
+// lists both. Keyword search and reranking still use the query. When the
+// model can't be reached, the query is searched alone.
+//
+// Detection only tells scripts apart, so "en" means Latin script and "hi"
+// Devanagari.
+
+var CORPUS_LANGUAGES = getEnv("CORPUS_LANGUAGES", "")
+
+// languageNames - The languages detected and translated to, by ISO 639-1
+// code
+var languageNames = map[string]string{
+	"en": "English",
+	"hi": "Hindi",
+	"ta": "Tamil",
+	"te": "Telugu",
+	"bn": "Bengali",
+	"gu": "Gujarati",
+	"kn": "Kannada",
+	"ml": "Malayalam",
+	"pa": "Punjabi",
+}
+
+// languageScripts - The script each language is detected by
+var languageScripts = []struct {
+	language string
+	script   *unicode.RangeTable
+}{
+	{"en", unicode.Latin},
+	{"hi", unicode.Devanagari},
+	{"ta", unicode.Tamil},
+	{"te", unicode.Telugu},
+	{"bn", unicode.Bengali},
+	{"gu", unicode.Gujarati},
+	{"kn", unicode.Kannada},
+	{"ml", unicode.Malayalam},
+	{"pa", unicode.Gurmukhi},
+}
+
+// corpusLanguages - The language of each collection in CORPUS_LANGUAGES
+var corpusLanguages = parseCorpusLanguages(CORPUS_LANGUAGES)
+
+func parseCorpusLanguages(s string) map[string]string {
+	languages := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		collection, language, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		if _, known := languageNames[language]; !known {
+			log.Printf("⚠️  Ignoring CORPUS_LANGUAGES entry %q: unknown language", entry)
+			continue
+		}
+		languages[collection] = language
+	}
+	return languages
+}
+
+// validLanguage reports whether language is empty or one translated to.
+func validLanguage(language string) bool {
+	_, ok := languageNames[language]
+	return language == "" || ok
+}
+
+// detectLanguage is the language of the script most of text's letters are
+// in, or "" when none of them are in a known script.
+func detectLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, ls := range languageScripts {
+			if unicode.Is(ls.script, r) {
+				counts[ls.language]++
+				break
+			}
+		}
+	}
+	best := ""
+	for _, ls := range languageScripts {
+		if counts[ls.language] > counts[best] {
+			best = ls.language
+		}
+	}
+	return best
+}
+
+// translationTarget is the language req's query is translated to, or ""
+// when it isn't translated.
+func translationTarget(req RetrievalRequest) string {
+	target := req.TranslateTo
+	if target == "" {
+		target = corpusLanguages[req.Collection]
+	}
+	if target == "" || detectLanguage(req.Query) == target {
+		return ""
+	}
+	return target
+}
+
+// addTranslation searches vectors' queries and also req's query translated
+// to its target language, when it has one.
+func addTranslation(ctx context.Context, req RetrievalRequest, vectors *queryVectors) (*queryVectors, error) {
+	target := translationTarget(req)
+	if target == "" {
+		return vectors, nil
+	}
+
+	stepCtx, span := tracing.Start(ctx, "retrieval.translate")
+	translation, err := translateQuery(stepCtx, req.Query, target)
+	tracing.End(span, err)
+	if err != nil {
+		log.Printf("⚠️  Translation to %s failed, searching the query alone: %v", target, err)
+		vectors.Fallback = true
+		return vectors, nil
+	}
+	log.Printf("   ✓ Translated the query to %s", languageNames[target])
+
+	embedding, err := getQueryEmbedding(ctx, translation, requestModel(req))
+	if err != nil {
+		return nil, err
+	}
+	queries := vectors.Queries
+	if len(queries) == 0 {
+		queries = []string{req.Query}
+	}
+	return &queryVectors{
+		Embeddings: append(append([][]float32{}, vectors.Embeddings...), embedding),
+		Queries:    append(append([]string{}, queries...), translation),
+		Fallback:   vectors.Fallback,
+	}, nil
+}
+
+// translateQuery asks the model for query in language.
+func translateQuery(ctx context.Context, query, language string) (string, error) {
+	prompt := fmt.Sprintf("Translate the search query below into %s, as it would be worded in %s "+
+		"regulatory, compliance or merchant policy documents. Reply with only the translation.\n\n"+
+		"Query: %s", languageNames[language], languageNames[language], query)
+	translation, err := generateContent(ctx, RETRIEVAL_LLM_MODEL, prompt, false)
+	if err != nil {
+		return "", err
+	}
+	translation = strings.TrimSpace(translation)
+	if translation == "" {
+		return "", fmt.Errorf("%s wrote an empty translation", RETRIEVAL_LLM_MODEL)
+	}
+	return translation, nil
+}
+
+// addLanguages sets the language of each result.
+func addLanguages(results []RetrievalResult) {
+	for i, r := range results {
+		if language, ok := r.Metadata["language"].(string); ok && language != "" {
+			results[i].Language = language
+			continue
+		}
+		results[i].Language = detectLanguage(r.Text)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"shared/events"
+	"shared/httpclient"
+	"shared/tenant"
+)
+
+// ============================================================================
+// KEYWORD INDEX
+// ============================================================================
+// Hybrid search ranks the collection by BM25 as well as by vector
+// similarity and fuses the two rankings (see fusion.go), so a chunk that
+// shares the query's rare terms is found even when its embedding is not
+// among the nearest. The BM25 index lives in process memory, one per tenant
+// and collection. It is built from the vector service's /scroll on first
+// use and rebuilt once it is LEXICAL_INDEX_TTL old (default 10m), or sooner
+// when the event bus reports a document ingested into or deleted from the
+// collection. Collections over LEXICAL_INDEX_MAX_POINTS chunks (default
+// 100000) are only indexed up to that many.
+
+var (
+	LEXICAL_INDEX_TTL        = envDuration("LEXICAL_INDEX_TTL", 10*time.Minute)
+	LEXICAL_INDEX_MAX_POINTS = envInt("LEXICAL_INDEX_MAX_POINTS", 100000)
+)
+
+// BM25 parameters: term frequency saturation and length normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// hybridCandidateFactor widens each ranking fed to the fusion, so a chunk
+// ranked just below topK by both can still make the fused topK.
+const hybridCandidateFactor = 2
+
+// lexicalDoc is an indexed chunk.
+type lexicalDoc struct {
+	result RetrievalResult // as a vector hit would return it, without a score
+	terms  map[string]int
+	length int
+}
+
+// bm25Index is an inverted index over one collection's chunks.
+type bm25Index struct {
+	docs      []lexicalDoc
+	postings  map[string][]int // term → indexes into docs
+	avgLength float64
+	built     time.Time
+}
+
+// stopwords are too common to say anything about relevance.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "how": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true, "was": true, "what": true,
+	"when": true, "which": true, "who": true, "will": true, "with": true,
+}
+
+// tokenize splits text into lowercase word terms, dropping stopwords.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, f := range fields {
+		if !stopwords[f] {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+func newBM25Index(results []RetrievalResult) *bm25Index {
+	idx := &bm25Index{
+		docs:     make([]lexicalDoc, 0, len(results)),
+		postings: make(map[string][]int),
+		built:    time.Now(),
+	}
+	var total int
+	for _, r := range results {
+		terms := tokenize(r.Text)
+		doc := lexicalDoc{result: r, terms: make(map[string]int, len(terms)), length: len(terms)}
+		for _, t := range terms {
+			doc.terms[t]++
+		}
+		for t := range doc.terms {
+			idx.postings[t] = append(idx.postings[t], len(idx.docs))
+		}
+		idx.docs = append(idx.docs, doc)
+		total += len(terms)
+	}
+	if len(idx.docs) > 0 {
+		idx.avgLength = float64(total) / float64(len(idx.docs))
+	}
+	return idx
+}
+
+// Search returns the topK chunks matching filters with the highest BM25
+// score for query, best first. Chunks sharing no term with the query are
+// not returned.
+func (idx *bm25Index) Search(query string, topK int, filters map[string]interface{}) []RetrievalResult {
+	scores := make(map[int]float64)
+	n := float64(len(idx.docs))
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, i := range postings {
+			doc := idx.docs[i]
+			if !matchesFilters(doc.result.Metadata, filters) {
+				continue
+			}
+			tf := float64(doc.terms[term])
+			norm := 1 - bm25B + bm25B*float64(doc.length)/idx.avgLength
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	ranked := make([]int, 0, len(scores))
+	for i := range scores {
+		ranked = append(ranked, i)
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if scores[ranked[a]] != scores[ranked[b]] {
+			return scores[ranked[a]] > scores[ranked[b]]
+		}
+		return ranked[a] < ranked[b]
+	})
+	if len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+
+	results := make([]RetrievalResult, len(ranked))
+	for i, d := range ranked {
+		results[i] = idx.docs[d].result
+		results[i].Score = scores[d]
+	}
+	return results
+}
+
+// lexicalIndexes holds the built indexes by tenant and collection.
+type lexicalIndexes struct {
+	mu      sync.Mutex
+	entries map[string]*lexicalEntry
+}
+
+// lexicalEntry serializes the builds of one index.
+type lexicalEntry struct {
+	mu    sync.Mutex
+	index *bm25Index
+}
+
+var keywordIndexes = &lexicalIndexes{entries: make(map[string]*lexicalEntry)}
+
+func lexicalKey(tenantID, collection string) string {
+	return tenantID + "\x00" + collection
+}
+
+// Get returns the caller's index of collection, building it when it is
+// missing or stale.
+func (l *lexicalIndexes) Get(ctx context.Context, collection string) (*bm25Index, error) {
+	key := lexicalKey(tenant.FromContext(ctx), collection)
+	l.mu.Lock()
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &lexicalEntry{}
+		l.entries[key] = entry
+	}
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.index != nil && time.Since(entry.index.built) < LEXICAL_INDEX_TTL {
+		return entry.index, nil
+	}
+
+	start := time.Now()
+	chunks, err := scrollCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	entry.index = newBM25Index(chunks)
+	log.Printf("   📚 Indexed %d chunks of %s for keyword search in %dms",
+		len(chunks), collection, time.Since(start).Milliseconds())
+	return entry.index, nil
+}
+
+// Invalidate drops the tenant's index of collection, or all of the
+// tenant's indexes when collection is empty.
+func (l *lexicalIndexes) Invalidate(tenantID, collection string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.entries {
+		if key == lexicalKey(tenantID, collection) ||
+			(collection == "" && strings.HasPrefix(key, tenantID+"\x00")) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// scrollCollection reads every chunk of collection visible to the caller.
+func scrollCollection(ctx context.Context, collection string) ([]RetrievalResult, error) {
+	var chunks []RetrievalResult
+	offset := ""
+	for {
+		var page struct {
+			Points []struct {
+				ID      string                 `json:"id"`
+				Payload map[string]interface{} `json:"payload"`
+			} `json:"points"`
+			NextOffset string `json:"next_offset"`
+		}
+		err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/scroll", map[string]interface{}{
+			"collection": collection,
+			"offset":     offset,
+		}, &page, httpclient.Idempotent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll %s: %w", collection, err)
+		}
+
+		for _, p := range page.Points {
+			chunks = append(chunks, resultFromPayload(p.ID, 0, p.Payload))
+		}
+		if page.NextOffset == "" {
+			return chunks, nil
+		}
+		if len(chunks) >= LEXICAL_INDEX_MAX_POINTS {
+			log.Printf("⚠️  Keyword index of %s stopped at %d chunks", collection, len(chunks))
+			return chunks[:LEXICAL_INDEX_MAX_POINTS], nil
+		}
+		offset = page.NextOffset
+	}
+}
+
+// searchKeywords ranks the caller's chunks of collection by BM25.
+func searchKeywords(ctx context.Context, collection, query string, topK int, filters map[string]interface{}) ([]RetrievalResult, error) {
+	idx, err := keywordIndexes.Get(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Search(query, topK, filters), nil
+}
+
+// subscribeIndexInvalidation drops keyword indexes and cached results (see
+// cache.go) when their collection changes.
+func subscribeIndexInvalidation(bus events.Bus) {
+	invalidate := func(e events.Event) {
+		tenantID := e.Tenant
+		if tenantID == "" {
+			tenantID = tenant.Default
+		}
+		// Deletions don't say which collection the document was in, so
+		// they drop all of the tenant's indexes
+		collection, _ := e.Data["collection"].(string)
+		keywordIndexes.Invalidate(tenantID, collection)
+		resultCache.Invalidate(context.Background(), tenantID, collection)
+	}
+	for _, subject := range []string{events.IngestCompleted, events.DocumentDeleted} {
+		if err := bus.Subscribe(subject, invalidate); err != nil {
+			log.Fatalf("Failed to subscribe to %s: %v", subject, err)
+		}
+	}
+}
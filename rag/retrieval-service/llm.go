@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// LLM
+// ============================================================================
+// The llm reranker and the query expansion strategies call Gemini directly,
+// as the embed service does. They need GEMINI_API_KEY; without it they fail
+// and the request falls back to what it would do without them.
+
+var (
+	GEMINI_API_KEY = getEnv("GEMINI_API_KEY", "")
+
+	// RETRIEVAL_LLM_MODEL writes hypothetical answers and query variants,
+	// and is the default RERANK_LLM_MODEL
+	RETRIEVAL_LLM_MODEL = getEnv("RETRIEVAL_LLM_MODEL", "gemini-2.0-flash")
+
+	geminiClient = &http.Client{Timeout: 60 * time.Second}
+)
+
+const geminiAPIBasePath = "https://generativelanguage.googleapis.com/v1beta"
+
+// generateContent sends prompt to model and returns the text of its reply.
+// jsonOut asks for a JSON reply.
+func generateContent(ctx context.Context, model, prompt string, jsonOut bool) (string, error) {
+	if GEMINI_API_KEY == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY is not set")
+	}
+
+	config := map[string]interface{}{"temperature": 0}
+	if jsonOut {
+		config["responseMimeType"] = "application/json"
+	}
+	var response struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	err := callGemini(ctx, "models/"+model+":generateContent", map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"role": "user", "parts": []map[string]string{{"text": prompt}}},
+		},
+		"generationConfig": config,
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Candidates) == 0 || len(response.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("%s returned no content", model)
+	}
+	return response.Candidates[0].Content.Parts[0].Text, nil
+}
+
+// callGemini posts payload to a Gemini API endpoint. The key goes in a
+// header, which httpclient has no option for.
+func callGemini(ctx context.Context, endpoint string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, geminiAPIBasePath+"/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", GEMINI_API_KEY)
+
+	resp, err := geminiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gemini api error: status %d: %s", resp.StatusCode, string(data))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
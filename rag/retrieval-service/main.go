@@ -2,39 +2,98 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"protos/gorillapb"
+	"shared/auth"
+	"shared/events"
+	"shared/faults"
+	"shared/flags"
+	"shared/httpclient"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/rpc"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 type RetrievalRequest struct {
-	Query      string            `json:"query"`      // User's question: "What are KYC requirements?"
-	TopK       int               `json:"top_k"`      // How many results to return (default: 5)
-	Collection string            `json:"collection"` // Which collection to search: "regulatory_docs", "merchant_docs", etc.
-	Filters    map[string]string `json:"filters"`    // Optional filters: {"type": "regulatory"}
+	Query         string                 `json:"query"`          // User's question: "What are KYC requirements?"
+	TopK          int                    `json:"top_k"`          // How many results to return (default: 5)
+	Collection    string                 `json:"collection"`     // Which collection to search: "regulatory_docs", "merchant_docs", etc., or "auto" to pick it from the query
+	Filters       map[string]interface{} `json:"filters"`        // Optional filters: {"document_type": "regulatory"}, see filters.go
+	Rerank        RerankMode             `json:"rerank"`         // Optional: "keyword", "cross_encoder", "llm" or "none"; overrides the retrieval_rerank flag
+	RerankWeights *RerankWeights         `json:"rerank_weights"` // Optional: the keyword reranker's vector/keyword blend, see rerank.go
+	Hybrid        *bool                  `json:"hybrid"`         // Optional: overrides the retrieval_hybrid flag
+	MinScore      float64                `json:"min_score"`      // Optional: drop results scoring below this
+	MinResults    int                    `json:"min_results"`    // Optional: widen the search until this many clear min_score
+	Strategy      string                 `json:"strategy"`       // Optional: "standard", "hyde" or "multi_query", see expansion.go
+	ExpandContext int                    `json:"expand_context"` // Optional: add this many neighbouring chunks either side of each hit, see window.go
+	Offset        int                    `json:"offset"`         // Optional: skip this many results, see pages.go
+	Paginate      bool                   `json:"paginate"`       // Optional: return a next_page_token while results are left
+	PageToken     string                 `json:"page_token"`     // Optional: the next_page_token of an earlier response; the other fields but top_k are ignored
+	Dedup         *bool                  `json:"dedup"`          // Optional: overrides the retrieval_dedup flag, see dedup.go
+	RecencyWeight float64                `json:"recency_weight"` // Optional: 0-1, how much newer documents are favoured, see recency.go
+	Stream        bool                   `json:"stream"`         // Optional: answer /retrieve as NDJSON, a result at a time, see stream.go
+	TranslateTo   string                 `json:"translate_to"`   // Optional: also search the query translated to this language ("hi", "ta", ...), see language.go
+	Match         MatchClauses           `json:"match"`          // Optional: boost or require query terms in payload fields, see match.go
+
+	EmbeddingModel      string `json:"embedding_model"`      // Optional: the model the collection was indexed with, see embedmodel.go
+	EmbeddingDimensions int    `json:"embedding_dimensions"` // Optional: the output size it was indexed at
+	MaxContextTokens    int    `json:"max_context_tokens"`   // Optional: cap on the tokens of the results' texts together, see budget.go
+	GroupByDocument     bool   `json:"group_by_document"`    // Optional: one result per document, its other hits as siblings, see group.go
+	Explain             bool   `json:"explain"`              // Optional: add how each result's score came about, see explain.go
 }
 
 // RetrievalResult - A single search result
 type RetrievalResult struct {
-	ID         string                 `json:"id"`          // Chunk ID
-	Score      float64                `json:"score"`       // Relevance score (0-1, higher is better)
-	Text       string                 `json:"text"`        // The actual text content
-	DocumentID string                 `json:"document_id"` // Which document this came from
-	Source     string                 `json:"source"`      // Document name
-	Metadata   map[string]interface{} `json:"metadata"`    // Additional info
+	ID          string                 `json:"id"`                    // Chunk ID
+	Score       float64                `json:"score"`                 // Relevance score (0-1, higher is better)
+	Text        string                 `json:"text"`                  // The actual text content
+	DocumentID  string                 `json:"document_id"`           // Which document this came from
+	Source      string                 `json:"source"`                // Document name
+	Metadata    map[string]interface{} `json:"metadata"`              // Additional info
+	Highlights  []Highlight            `json:"highlights,omitempty"`  // Where query terms matched the text, see highlight.go
+	Language    string                 `json:"language,omitempty"`    // The chunk's language, see language.go
+	Tokens      int                    `json:"tokens,omitempty"`      // Estimated tokens of the text, under max_context_tokens, see budget.go
+	Siblings    []RetrievalResult      `json:"siblings,omitempty"`    // The document's other hits, under group_by_document, see group.go
+	Explanation *ScoreExplanation      `json:"explanation,omitempty"` // How the score came about, under explain, see explain.go
 }
 
 // RetrievalResponse - Complete response sent back to user
 type RetrievalResponse struct {
-	Query       string            `json:"query"`           // Echo back the query
-	Results     []RetrievalResult `json:"results"`         // Array of matching chunks
-	Count       int               `json:"count"`           // Number of results
-	ProcessTime float64           `json:"process_time_ms"` // How long it took (milliseconds)
+	Query         string            `json:"query"`                     // Echo back the query
+	Results       []RetrievalResult `json:"results"`                   // Array of matching chunks
+	Count         int               `json:"count"`                     // Number of results
+	ProcessTime   float64           `json:"process_time_ms"`           // How long it took (milliseconds)
+	Widened       *Widening         `json:"widened,omitempty"`         // How the search was widened to reach min_results
+	Queries       []string          `json:"queries,omitempty"`         // The queries searched with, for multi_query or a translated query
+	QueryLanguage string            `json:"query_language,omitempty"`  // The language the query is in, see language.go
+	Cached        bool              `json:"cached,omitempty"`          // Answered from the result cache
+	Degraded      bool              `json:"degraded,omitempty"`        // Answered by keyword search alone, the query couldn't be embedded, see degraded.go
+	ContextTokens int               `json:"context_tokens,omitempty"`  // Estimated tokens of the results' texts, under max_context_tokens
+	Collections   []string          `json:"collections,omitempty"`     // The collections picked from the query, for a request without one, see collections.go
+	Offset        int               `json:"offset,omitempty"`          // Where this page starts among the kept results
+	Total         int               `json:"total,omitempty"`           // How many results are kept for paging
+	NextPageToken string            `json:"next_page_token,omitempty"` // Pass as page_token for the next page
 }
 
 // ============================================================================
@@ -46,6 +105,22 @@ var (
 	EMBED_SERVICE_URL    = getEnv("EMBED_SERVICE_URL", "http://localhost:8081")
 	VECTOR_SERVICE_URL   = getEnv("VECTOR_SERVICE_URL", "http://localhost:8082")
 	METADATA_SERVICE_URL = getEnv("METADATA_SERVICE_URL", "http://localhost:8083")
+
+	// httpClient carries every HTTP call to the services above
+	httpClient = httpclient.New(httpclient.Options{Timeout: 30 * time.Second})
+
+	// Metadata enrichment fetches up to ENRICH_CONCURRENCY documents at
+	// once, giving each ENRICH_TIMEOUT
+	ENRICH_CONCURRENCY = envInt("ENRICH_CONCURRENCY", 8)
+	ENRICH_TIMEOUT     = envDuration("ENRICH_TIMEOUT", 5*time.Second)
+
+	// Feature flags, see GET /admin/flags
+	rerankFlag = flags.Define("retrieval_rerank", true,
+		"Re-score vector hits with RERANK_STRATEGY before returning them")
+	hybridFlag = flags.Define("retrieval_hybrid", false,
+		"Fuse BM25 keyword search with vector search by reciprocal rank")
+	dedupFlag = flags.Define("retrieval_dedup", true,
+		"Collapse near-duplicate chunks into the best scoring one")
 )
 
 // ============================================================================
@@ -53,9 +128,54 @@ var (
 // ============================================================================
 
 func main() {
+	shutdownTracing, err := tracing.Init("retrieval-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := tlsconfig.ConfigureDefaultTransport(); err != nil {
+		log.Fatalf("Failed to load TLS client config: %v", err)
+	}
+	httpclient.ConfigureDefaultTransport()
+	tracing.ConfigureDefaultTransport()
+	tenant.ConfigureDefaultTransport()
+	auth.ConfigureDefaultTransport("retrieval-service", EMBED_SERVICE_URL, VECTOR_SERVICE_URL, METADATA_SERVICE_URL, EVAL_SERVICE_URL)
+
+	if err := flags.Load(context.Background()); err != nil {
+		log.Fatalf("Failed to load feature flags: %v", err)
+	}
+	if !validRerankMode(RerankMode(RERANK_STRATEGY)) {
+		log.Fatalf("Unknown RERANK_STRATEGY %q", RERANK_STRATEGY)
+	}
+	initRerankWeights()
+
+	eventBus, err := events.Connect("retrieval-service")
+	if err != nil {
+		log.Fatalf("Failed to connect to event bus: %v", err)
+	}
+	defer eventBus.Close()
+	subscribeIndexInvalidation(eventBus)
+	initResultCache()
+	initPageStore()
+
 	// Setup HTTP routes
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("retrieval-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("retrieval-service", map[string]server.Check{
+		"embed-service":    server.HTTPCheck(EMBED_SERVICE_URL + "/healthz"),
+		"vector-service":   server.HTTPCheck(VECTOR_SERVICE_URL + "/healthz"),
+		"metadata-service": server.HTTPCheck(METADATA_SERVICE_URL + "/healthz"),
+	}))
 	http.HandleFunc("/retrieve", retrieveHandler)
+	http.HandleFunc("/retrieve/batch", retrieveBatchHandler)
+	http.HandleFunc("/retrieve/context", retrieveContextHandler)
+	http.HandleFunc("/evaluate", evaluateHandler)
+	http.HandleFunc("/admin/flags", flags.Handler("retrieval-service"))
 
 	port := getEnv("PORT", "8084")
 	log.Printf("🚀 Retrieval Service starting on port %s", port)
@@ -63,8 +183,31 @@ func main() {
 	log.Printf("   - Embed Service:    %s", EMBED_SERVICE_URL)
 	log.Printf("   - Vector Service:   %s", VECTOR_SERVICE_URL)
 	log.Printf("   - Metadata Service: %s", METADATA_SERVICE_URL)
+	if resultCache != nil {
+		log.Printf("   - Result cache:     Redis, TTL %s", RETRIEVAL_CACHE_TTL)
+	}
+	initGRPCClients()
 
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	grpcServer, err := rpc.NewServer()
+	if err != nil {
+		log.Fatalf("Failed to create gRPC server: %v", err)
+	}
+	gorillapb.RegisterRetrievalServiceServer(grpcServer, retrievalServer{})
+	rpc.Serve(grpcServer, ":"+getEnv("GRPC_PORT", "50054"))
+
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("retrieval-service", handler)
+	handler = metrics.Wrap("retrieval-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Path: "/admin/", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer.GracefulStop()
 }
 
 // ============================================================================
@@ -80,6 +223,57 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// validateRequest rejects malformed requests with a 400 before any retrieval
+// is attempted.
+func validateRequest(req RetrievalRequest) error {
+	if req.PageToken != "" {
+		_, err := parsePageToken(req.PageToken)
+		return err
+	}
+	if req.Query == "" {
+		return errors.New("Query cannot be empty")
+	}
+	if _, err := normalizeFilters(req.Filters); err != nil {
+		return err
+	}
+	if !validStrategy(req.Strategy) {
+		return fmt.Errorf("Unknown strategy %q", req.Strategy)
+	}
+	if !validRerankMode(req.Rerank) {
+		return fmt.Errorf("Unknown rerank strategy %q", req.Rerank)
+	}
+	if req.RerankWeights != nil {
+		if err := req.RerankWeights.validate(); err != nil {
+			return err
+		}
+	}
+	if req.ExpandContext < 0 || req.ExpandContext > RETRIEVAL_MAX_EXPAND_CONTEXT {
+		return fmt.Errorf("expand_context must be between 0 and %d", RETRIEVAL_MAX_EXPAND_CONTEXT)
+	}
+	if err := req.Match.validate(); err != nil {
+		return err
+	}
+	if err := requestModel(req).validate(); err != nil {
+		return err
+	}
+	if !validLanguage(req.TranslateTo) {
+		return fmt.Errorf("Unknown translate_to language %q", req.TranslateTo)
+	}
+	if req.RecencyWeight < 0 || req.RecencyWeight > 1 {
+		return errors.New("recency_weight must be between 0 and 1")
+	}
+	if req.Offset < 0 || req.Offset >= RETRIEVAL_PAGE_DEPTH {
+		return fmt.Errorf("offset must be between 0 and %d", RETRIEVAL_PAGE_DEPTH-1)
+	}
+	if req.MaxContextTokens < 0 {
+		return errors.New("max_context_tokens must not be negative")
+	}
+	if req.MaxContextTokens > 0 && paginates(req) {
+		return errors.New("max_context_tokens can't be combined with pagination")
+	}
+	return nil
+}
+
 // retrieveHandler - Main RAG retrieval endpoint
 // This is where the magic happens! 🪄
 func retrieveHandler(w http.ResponseWriter, r *http.Request) {
@@ -88,8 +282,6 @@ func retrieveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startTime := time.Now()
-
 	// Parse request
 	var req RetrievalRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -98,11 +290,68 @@ func retrieveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate request
-	if req.Query == "" {
-		respondError(w, "Query cannot be empty", http.StatusBadRequest)
+	if err := validateRequest(req); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if req.Stream {
+		if paginates(req) {
+			respondError(w, "stream can't be combined with pagination", http.StatusBadRequest)
+			return
+		}
+		if req.GroupByDocument {
+			respondError(w, "stream can't be combined with group_by_document", http.StatusBadRequest)
+			return
+		}
+		streamRetrieval(w, r, req)
+		return
+	}
+
+	response, err := retrieve(r.Context(), req)
+	if errors.Is(err, errPageExpired) {
+		respondError(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// retrieve runs the full embed → search → enrich → rerank pipeline. It is
+// shared by the HTTP and gRPC entry points.
+func retrieve(ctx context.Context, req RetrievalRequest) (*RetrievalResponse, error) {
+	return retrieveWith(ctx, req, nil)
+}
+
+// retrieveWith runs retrieve, searching with vectors instead of embedding
+// the query when they are given, as by a batch (see batch.go).
+func retrieveWith(ctx context.Context, req RetrievalRequest, vectors *queryVectors) (*RetrievalResponse, error) {
+	if paginates(req) {
+		return retrievePage(ctx, req, vectors)
+	}
+	return runRetrieval(ctx, req, vectors)
+}
+
+// runRetrieval runs the pipeline for one page of req.TopK results.
+func runRetrieval(ctx context.Context, req RetrievalRequest, vectors *queryVectors) (*RetrievalResponse, error) {
+	startTime := time.Now()
+
+	// Pick the collection from the query when none is named, see
+	// collections.go
+	collections, reason := selectCollections(req)
+	if collections != nil {
+		log.Printf("🗂️  Selected %s for '%s' (%s)", strings.Join(collections, ", "), req.Query, reason)
+		if len(collections) > 1 {
+			return retrieveAcross(ctx, req, vectors, collections)
+		}
+		req.Collection = collections[0]
+	}
+
 	// Set defaults
 	if req.TopK == 0 {
 		req.TopK = 5
@@ -110,6 +359,14 @@ func retrieveHandler(w http.ResponseWriter, r *http.Request) {
 	if req.Collection == "" {
 		req.Collection = "regulatory_docs"
 	}
+	if req.MinResults > req.TopK {
+		req.TopK = req.MinResults
+	}
+	filters, err := normalizeFilters(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	req.Filters = filters
 
 	log.Printf("🔍 Retrieval started: '%s' (TopK=%d, Collection=%s)",
 		req.Query, req.TopK, req.Collection)
@@ -119,59 +376,268 @@ func retrieveHandler(w http.ResponseWriter, r *http.Request) {
 	// ========================================================================
 	// Convert user's text query into a vector so we can do semantic search
 	log.Println("   Step 1/4: Generating query embedding...")
-	queryEmbedding, err := getQueryEmbedding(req.Query)
+	if vectors == nil {
+		if vectors, err = embedRequest(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	degraded := len(vectors.Embeddings) == 0
+
+	// Repeat questions are answered from the cache, see cache.go
+	cacheKey := ""
+	if !degraded {
+		cacheKey = resultCache.ResultsKey(ctx, req, vectors)
+	}
+	if results, ok := resultCache.Results(ctx, cacheKey); ok {
+		processTime := time.Since(startTime).Milliseconds()
+		log.Printf("✅ Retrieval answered from cache in %dms (returned %d results)", processTime, len(results))
+		contextTokens := resultTokens(results)
+		if req.GroupByDocument {
+			results = groupByDocument(results)
+		}
+		return &RetrievalResponse{
+			Query:         req.Query,
+			Results:       results,
+			Count:         len(results),
+			ProcessTime:   float64(processTime),
+			Queries:       vectors.Queries,
+			QueryLanguage: detectLanguage(req.Query),
+			Cached:        true,
+			ContextTokens: contextTokens,
+			Collections:   collections,
+		}, nil
+	}
+
+	results, err := searchCollection(ctx, req, vectors.Embeddings, startTime)
 	if err != nil {
-		respondError(w, fmt.Sprintf("Failed to generate embedding: %v", err), http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+
+	// Drop results under min_score, widening the search when fewer than
+	// min_results are left, see threshold.go
+	results, widened, err := applyThreshold(ctx, req, vectors.Embeddings, results)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the results' texts within max_context_tokens, see budget.go
+	contextTokens := 0
+	if req.MaxContextTokens > 0 {
+		results, contextTokens = applyTokenBudget(results, req.MaxContextTokens)
+		log.Printf("   ✓ Kept %d results in %d of %d tokens", len(results), contextTokens, req.MaxContextTokens)
+	}
+	addHighlights(req.Query, results)
+	addLanguages(results)
+	explainFinal(results)
+	if widened == nil && !degraded && !enrichmentDeferred(ctx) {
+		resultCache.StoreResults(ctx, cacheKey, results)
+	}
+	// Fold each document's hits into its best one, see group.go
+	if req.GroupByDocument {
+		results = groupByDocument(results)
 	}
-	log.Printf("   ✓ Generated embedding (dimension: %d)", len(queryEmbedding))
 
+	// Build response
+	processTime := time.Since(startTime).Milliseconds()
+	response := &RetrievalResponse{
+		Query:         req.Query,
+		Results:       results,
+		Count:         len(results),
+		ProcessTime:   float64(processTime),
+		Widened:       widened,
+		Queries:       vectors.Queries,
+		QueryLanguage: detectLanguage(req.Query),
+		Degraded:      degraded,
+		ContextTokens: contextTokens,
+		Collections:   collections,
+	}
+
+	log.Printf("✅ Retrieval completed in %dms (returned %d results)",
+		processTime, len(results))
+
+	return response, nil
+}
+
+// embedRequest embeds req's query as its strategy says, from the cache when
+// it can. The vectors hold no embeddings when the query couldn't be
+// embedded and is searched by keywords alone, see degraded.go.
+func embedRequest(ctx context.Context, req RetrievalRequest) (*queryVectors, error) {
+	if vectors := resultCache.Embeddings(ctx, req); vectors != nil {
+		log.Println("   ✓ Found cached embedding")
+		return vectors, nil
+	}
+
+	stepCtx, span := tracing.Start(ctx, "retrieval.embed")
+	vectors, err := embedForStrategy(stepCtx, req)
+	if err == nil {
+		vectors, err = addTranslation(stepCtx, req, vectors)
+	}
+	tracing.End(span, err)
+	switch {
+	case err == nil:
+		log.Printf("   ✓ Generated %d embedding(s) (dimension: %d)", len(vectors.Embeddings), len(vectors.Embeddings[0]))
+		resultCache.StoreEmbeddings(ctx, req, vectors)
+		return vectors, nil
+	case keywordFallback(ctx, err):
+		// Keyword search alone is still an answer
+		log.Printf("⚠️  Embedding failed, searching by keywords only: %v", err)
+		return &queryVectors{}, nil
+	default:
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+}
+
+// hybridSearch reports whether req adds keyword search to vector search.
+func hybridSearch(req RetrievalRequest) bool {
+	if req.Hybrid != nil {
+		return *req.Hybrid
+	}
+	return hybridFlag.Enabled()
+}
+
+// searchCollection runs the search → enrich → rerank steps for
+// req.Collection and req.TopK. The canary replays the search when
+// startTime, the start of the request, is set; widened searches leave it
+// zero. Without queryEmbeddings the collection is searched by keywords
+// alone, see degraded.go.
+func searchCollection(ctx context.Context, req RetrievalRequest, queryEmbeddings [][]float32, startTime time.Time) ([]RetrievalResult, error) {
 	// ========================================================================
 	// STEP 2: Search Vector Database
 	// ========================================================================
 	// Find the most similar chunks using cosine similarity
+	// (and, for hybrid search, by BM25 keyword score, fusing the two; for
+	// multi_query each variant is searched and the rankings fused)
 	log.Println("   Step 2/4: Searching vector database...")
-	vectorResults, err := searchVectorDB(req.Collection, queryEmbedding, req.TopK, req.Filters)
+	degraded := len(queryEmbeddings) == 0
+	hybrid := hybridSearch(req) && !degraded
+	strategy := rerankStrategy(req)
+	pool := rerankCandidates(strategy, req.TopK) // results handed to the reranker
+	dedup := dedupSearch(req)
+	if dedup {
+		pool = max(pool, req.TopK*dedupCandidateFactor)
+	}
+	if req.RecencyWeight > 0 {
+		pool = max(pool, req.TopK*recencyCandidateFactor)
+	}
+	if len(req.Match) > 0 {
+		pool = max(pool, req.TopK*matchCandidateFactor)
+	}
+	candidates := pool
+	if hybrid {
+		candidates = pool * hybridCandidateFactor
+	}
+	stepCtx, span := tracing.Start(ctx, "retrieval.search", attribute.String("collection", req.Collection), attribute.Bool("degraded", degraded))
+	var vectorResults []RetrievalResult
+	var err error
+	if degraded {
+		// The query couldn't be embedded, see degraded.go
+		if vectorResults, err = searchKeywordsOnly(stepCtx, req.Collection, req.Query, candidates, req.Filters); err != nil {
+			err = fmt.Errorf("keyword search failed: %w", err)
+		}
+	} else if vectorResults, err = searchEmbeddings(stepCtx, req.Collection, queryEmbeddings, candidates, req.Filters); err != nil {
+		err = fmt.Errorf("vector search failed: %w", err)
+	}
+	tracing.End(span, err)
 	if err != nil {
-		respondError(w, fmt.Sprintf("Vector search failed: %v", err), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	log.Printf("   ✓ Found %d results", len(vectorResults))
+	if !startTime.IsZero() && !degraded {
+		maybeRunCanary(ctx, req, vectorResults[:min(req.TopK, len(vectorResults))], time.Since(startTime))
+	}
+	// Scores before fusion, for explain, see explain.go
+	var vectorScores, keywordScores map[string]float64
+	if degraded {
+		keywordScores = explainScores(req, vectorResults)
+	} else {
+		vectorScores = explainScores(req, vectorResults)
+	}
+
+	if hybrid {
+		stepCtx, span = tracing.Start(ctx, "retrieval.keyword", attribute.String("collection", req.Collection))
+		keywordResults, err := searchKeywords(stepCtx, req.Collection, req.Query, candidates, req.Filters)
+		tracing.End(span, err)
+		if err != nil {
+			// Vector hits alone are still an answer
+			log.Printf("⚠️  Keyword search failed, using vector results only: %v", err)
+			vectorResults = vectorResults[:min(pool, len(vectorResults))]
+		} else {
+			log.Printf("   ✓ Found %d keyword results", len(keywordResults))
+			keywordScores = explainScores(req, keywordResults)
+			vectorResults = fuseRRF(pool, vectorResults, keywordResults)
+		}
+	}
+	if req.Explain {
+		explainSearch(vectorResults, vectorScores, keywordScores)
+	}
 
 	// ========================================================================
 	// STEP 3: Enrich with Metadata
 	// ========================================================================
 	// Add document names, types, and other metadata to results
-	log.Println("   Step 3/4: Enriching with metadata...")
-	enrichedResults, err := enrichWithMetadata(vectorResults)
-	if err != nil {
-		respondError(w, fmt.Sprintf("Metadata enrichment failed: %v", err), http.StatusInternalServerError)
-		return
+	// (a streamed response enriches each result as it is sent, see stream.go)
+	enrichedResults := vectorResults
+	if !enrichmentDeferred(ctx) {
+		log.Println("   Step 3/4: Enriching with metadata...")
+		stepCtx, span = tracing.Start(ctx, "retrieval.enrich")
+		enrichedResults, err = enrichWithMetadata(stepCtx, vectorResults)
+		tracing.End(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("metadata enrichment failed: %w", err)
+		}
+		log.Println("   ✓ Enriched results")
 	}
-	log.Println("   ✓ Enriched results")
 
 	// ========================================================================
 	// STEP 4: Rerank Results
 	// ========================================================================
-	// Improve ranking by considering keyword matches
-	log.Println("   Step 4/4: Reranking results...")
-	rerankedResults := rerankResults(req.Query, enrichedResults)
-	log.Println("   ✓ Reranked results")
-
-	// Build response
-	processTime := time.Since(startTime).Milliseconds()
-	response := RetrievalResponse{
-		Query:       req.Query,
-		Results:     rerankedResults,
-		Count:       len(rerankedResults),
-		ProcessTime: float64(processTime),
+	// Improve ranking with keyword matches or a reranking model, see rerank.go
+	// (field matches, recency and near-duplicates are weighed before the cut
+	// to top_k, see match.go, recency.go and dedup.go)
+	keep := req.TopK
+	if dedup || req.RecencyWeight > 0 || len(req.Match) > 0 {
+		keep = len(enrichedResults)
+	}
+	var rerankedResults []RetrievalResult
+	before := explainScores(req, enrichedResults)
+	if strategy != rerankNone {
+		log.Printf("   Step 4/4: Reranking results (%s)...", strategy)
+		stepCtx, span = tracing.Start(ctx, "retrieval.rerank", attribute.String("strategy", strategy))
+		rerankedResults = applyRerank(stepCtx, strategy, req, enrichedResults, keep)
+		span.End()
+		log.Println("   ✓ Reranked results")
+	} else {
+		log.Println("   Step 4/4: Reranking disabled, keeping vector order")
+		rerankedResults = enrichedResults[:min(keep, len(enrichedResults))]
+	}
+	explainStep("rerank:"+strategy, before, rerankedResults)
+	if len(req.Match) > 0 {
+		before = explainScores(req, rerankedResults)
+		rerankedResults = applyMatches(rerankedResults, req.Match)
+		explainStep("match", before, rerankedResults)
+	}
+	if req.RecencyWeight > 0 {
+		before = explainScores(req, rerankedResults)
+		rerankedResults = applyRecency(rerankedResults, req.RecencyWeight, time.Now())
+		explainStep("recency", before, rerankedResults)
+	}
+	if dedup {
+		deduped := dedupResults(rerankedResults)
+		if dropped := len(rerankedResults) - len(deduped); dropped > 0 {
+			log.Printf("   ✓ Collapsed %d near-duplicate results", dropped)
+		}
+		rerankedResults = deduped
+	}
+	rerankedResults = rerankedResults[:min(req.TopK, len(rerankedResults))]
+
+	// Swap each hit for the passage around it, see window.go
+	if req.ExpandContext > 0 {
+		stepCtx, span = tracing.Start(ctx, "retrieval.expand_context")
+		rerankedResults = expandContext(stepCtx, req.Collection, rerankedResults, req.ExpandContext)
+		span.End()
+		log.Printf("   ✓ Expanded context by %d chunks", req.ExpandContext)
 	}
 
-	log.Printf("✅ Retrieval completed in %dms (returned %d results)",
-		processTime, len(rerankedResults))
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return rerankedResults, nil
 }
 
 // ============================================================================
@@ -179,36 +645,45 @@ func retrieveHandler(w http.ResponseWriter, r *http.Request) {
 // ============================================================================
 
 // getQueryEmbedding - Converts text query to vector embedding
-func getQueryEmbedding(query string) ([]float32, error) {
-	// Prepare request to embed service
-	requestBody, _ := json.Marshal(map[string]string{
-		"text": query,
-	})
+func getQueryEmbedding(ctx context.Context, query string, model embeddingModel) ([]float32, error) {
+	if embedClient != nil && !model.custom() {
+		return getQueryEmbeddingGRPC(ctx, query)
+	}
 
 	// Call embed service
-	resp, err := http.Post(
-		EMBED_SERVICE_URL+"/embed",
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed", model.addTo(map[string]interface{}{
+		"text": query,
+	}), &result, httpclient.Idempotent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call embed service: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("embed service returned status: %d", resp.StatusCode)
+	return result.Embedding, nil
+}
+
+// getQueryEmbeddings embeds texts in one call to the embed service.
+func getQueryEmbeddings(ctx context.Context, texts []string, model embeddingModel) ([][]float32, error) {
+	if embedClient != nil && !model.custom() {
+		return getQueryEmbeddingsGRPC(ctx, texts)
 	}
 
-	// Parse response
 	var result struct {
-		Embedding []float32 `json:"embedding"`
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	err := httpClient.PostJSON(ctx, EMBED_SERVICE_URL+"/embed-batch", model.addTo(map[string]interface{}{
+		"texts": texts,
+	}), &result, httpclient.Idempotent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embed service: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embed service returned %d embeddings for %d texts", len(result.Embeddings), len(texts))
 	}
 
-	return result.Embedding, nil
+	return result.Embeddings, nil
 }
 
 // ============================================================================
@@ -216,31 +691,12 @@ func getQueryEmbedding(query string) ([]float32, error) {
 // ============================================================================
 
 // searchVectorDB - Finds similar chunks in Qdrant
-func searchVectorDB(collection string, query []float32, topK int, filters map[string]string) ([]RetrievalResult, error) {
-	// Prepare search request
-	requestBody, _ := json.Marshal(map[string]interface{}{
-		"collection": collection,
-		"query":      query,
-		"top_k":      topK,
-		"filter":     filters,
-	})
-
-	// Call vector service
-	resp, err := http.Post(
-		VECTOR_SERVICE_URL+"/search",
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call vector service: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("vector service returned status: %d", resp.StatusCode)
+func searchVectorDB(ctx context.Context, collection string, query []float32, topK int, filters map[string]interface{}) ([]RetrievalResult, error) {
+	if vectorClient != nil {
+		return searchVectorDBGRPC(ctx, collection, query, topK, filters)
 	}
 
-	// Parse response
+	// Call vector service
 	var vectorResponse struct {
 		Results []struct {
 			ID      string                 `json:"id"`
@@ -248,88 +704,145 @@ func searchVectorDB(collection string, query []float32, topK int, filters map[st
 			Payload map[string]interface{} `json:"payload"`
 		} `json:"results"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&vectorResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode vector response: %w", err)
+	err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/search", map[string]interface{}{
+		"collection": collection,
+		"query":      query,
+		"top_k":      topK,
+		"filter":     filters,
+	}, &vectorResponse, httpclient.Idempotent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call vector service: %w", err)
 	}
 
 	// Convert to retrieval results
 	results := make([]RetrievalResult, len(vectorResponse.Results))
 	for i, r := range vectorResponse.Results {
-		result := RetrievalResult{
-			ID:       r.ID,
-			Score:    r.Score,
-			Metadata: r.Payload,
-		}
-
-		// Extract text and document ID from payload
-		if text, ok := r.Payload["text"].(string); ok {
-			result.Text = text
-		}
-		if docID, ok := r.Payload["document_id"].(string); ok {
-			result.DocumentID = docID
-		}
-
-		results[i] = result
+		results[i] = resultFromPayload(r.ID, r.Score, r.Payload)
 	}
 
 	return results, nil
 }
 
+// resultFromPayload builds a result from a vector point, taking the text
+// and document ID from its payload.
+func resultFromPayload(id string, score float64, payload map[string]interface{}) RetrievalResult {
+	result := RetrievalResult{
+		ID:       id,
+		Score:    score,
+		Metadata: payload,
+	}
+	if text, ok := payload["text"].(string); ok {
+		result.Text = text
+	}
+	if docID, ok := payload["document_id"].(string); ok {
+		result.DocumentID = docID
+	}
+	return result
+}
+
 // ============================================================================
 // STEP 3: METADATA ENRICHMENT
 // ============================================================================
 
 // enrichWithMetadata - Adds document names and metadata to results
-func enrichWithMetadata(results []RetrievalResult) ([]RetrievalResult, error) {
+func enrichWithMetadata(ctx context.Context, results []RetrievalResult) ([]RetrievalResult, error) {
 	// Collect unique document IDs
-	docIDs := make(map[string]bool)
+	var docIDs []string
+	seen := make(map[string]bool)
 	for _, r := range results {
-		if r.DocumentID != "" {
-			docIDs[r.DocumentID] = true
+		if r.DocumentID != "" && !seen[r.DocumentID] {
+			seen[r.DocumentID] = true
+			docIDs = append(docIDs, r.DocumentID)
 		}
 	}
 
-	// Fetch metadata for each document
-	docMetadata := make(map[string]map[string]interface{})
-	for docID := range docIDs {
-		resp, err := http.Get(METADATA_SERVICE_URL + "/documents/" + docID)
-		if err != nil {
-			log.Printf("⚠️  Failed to fetch metadata for %s: %v", docID, err)
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			var doc map[string]interface{}
-			if err := json.NewDecoder(resp.Body).Decode(&doc); err == nil {
-				docMetadata[docID] = doc
-			}
-		}
-	}
+	// Fetch metadata for each document, ENRICH_CONCURRENCY at a time
+	docMetadata := fetchDocumentMetadata(ctx, docIDs)
 
 	// Enrich results with metadata
 	enriched := make([]RetrievalResult, len(results))
 	for i, r := range results {
-		enriched[i] = r
+		enriched[i] = enrichResult(r, docMetadata[r.DocumentID])
+	}
 
-		// Add document metadata if available
-		if meta, ok := docMetadata[r.DocumentID]; ok {
-			// Set source as document name
-			if name, ok := meta["name"].(string); ok {
-				enriched[i].Source = name
-			}
+	return enriched, nil
+}
+
+// enrichResult adds the metadata of r's document, when it was found.
+func enrichResult(r RetrievalResult, meta map[string]interface{}) RetrievalResult {
+	if meta == nil {
+		return r
+	}
 
-			// Add metadata fields
-			if enriched[i].Metadata == nil {
-				enriched[i].Metadata = make(map[string]interface{})
+	// Set source as document name
+	if name, ok := meta["name"].(string); ok {
+		r.Source = name
+	}
+
+	// Add metadata fields to a copy, as the payload map can be shared with
+	// the keyword index
+	metadata := make(map[string]interface{}, len(r.Metadata)+3)
+	for k, v := range r.Metadata {
+		metadata[k] = v
+	}
+	metadata["document_name"] = meta["name"]
+	metadata["document_type"] = meta["type"]
+	metadata["uploaded_at"] = meta["uploaded_at"]
+	r.Metadata = metadata
+	return r
+}
+
+// fetchDocumentMetadata gets the metadata of docIDs from a pool of
+// ENRICH_CONCURRENCY workers. Documents that can't be fetched in
+// ENRICH_TIMEOUT are left out.
+func fetchDocumentMetadata(ctx context.Context, docIDs []string) map[string]map[string]interface{} {
+	docMetadata := make(map[string]map[string]interface{}, len(docIDs))
+	if len(docIDs) == 0 {
+		return docMetadata
+	}
+
+	jobs := make(chan string)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for w := 0; w < min(max(ENRICH_CONCURRENCY, 1), len(docIDs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for docID := range jobs {
+				doc, err := fetchDocument(ctx, docID)
+				if err != nil {
+					if !httpclient.IsStatus(err, http.StatusNotFound) {
+						log.Printf("⚠️  Failed to fetch metadata for %s: %v", docID, err)
+					}
+					continue
+				}
+				mu.Lock()
+				docMetadata[docID] = doc
+				mu.Unlock()
 			}
-			enriched[i].Metadata["document_name"] = meta["name"]
-			enriched[i].Metadata["document_type"] = meta["type"]
-			enriched[i].Metadata["uploaded_at"] = meta["uploaded_at"]
+		}()
+	}
+
+	for _, docID := range docIDs {
+		if ctx.Err() != nil {
+			break
 		}
+		jobs <- docID
 	}
+	close(jobs)
+	wg.Wait()
+	return docMetadata
+}
 
-	return enriched, nil
+func fetchDocument(ctx context.Context, docID string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, ENRICH_TIMEOUT)
+	defer cancel()
+
+	var doc map[string]interface{}
+	err := httpClient.GetJSON(ctx, METADATA_SERVICE_URL+"/documents/"+url.PathEscape(docID), &doc)
+	return doc, err
 }
 
 // ============================================================================
@@ -339,47 +852,22 @@ func enrichWithMetadata(results []RetrievalResult) ([]RetrievalResult, error) {
 // rerankResults - Improves ranking using keyword matching
 // WHY RERANK? Vector search is good at semantic similarity, but might miss
 // exact keyword matches. Reranking combines both approaches.
-func rerankResults(query string, results []RetrievalResult) []RetrievalResult {
+func rerankResults(query string, results []RetrievalResult, weights RerankWeights, topK int) []RetrievalResult {
 	// Split query into terms
 	queryTerms := strings.Fields(strings.ToLower(query))
 
-	// Score each result
-	type scoredResult struct {
-		result  RetrievalResult
-		boosted float64
-	}
-
-	scored := make([]scoredResult, len(results))
-	for i, r := range results {
-		// Calculate keyword match score
-		matchScore := calculateMatchScore(queryTerms, r.Text)
-
-		// Combine vector score (70%) with keyword match (30%)
-		boostedScore := (r.Score * 0.7) + (matchScore * 0.3)
-
-		scored[i] = scoredResult{
-			result:  r,
-			boosted: boostedScore,
+	// Score each result, combining the vector score (70% by default) with
+	// the keyword match (30%), and keep the topK best, see rescore in rerank.go
+	scores := make([]float64, len(results))
+	for i := range results {
+		matchScore := calculateMatchScore(queryTerms, results[i].Text)
+		scores[i] = (results[i].Score * weights.Vector) + (matchScore * weights.Keyword)
+		if e := results[i].Explanation; e != nil {
+			e.KeywordMatch = &matchScore
 		}
 	}
 
-	// Sort by boosted score (simple bubble sort for clarity)
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[j].boosted > scored[i].boosted {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
-		}
-	}
-
-	// Extract reranked results
-	reranked := make([]RetrievalResult, len(scored))
-	for i, s := range scored {
-		reranked[i] = s.result
-		reranked[i].Score = s.boosted // Update with new score
-	}
-
-	return reranked
+	return rescore(results, scores, topK)
 }
 
 // calculateMatchScore - Percentage of query terms found in text
@@ -416,3 +904,17 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(getEnv(key, "")); err == nil {
+		return v
+	}
+	return def
+}
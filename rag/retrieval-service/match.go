@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ============================================================================
+// FIELD MATCHES
+// ============================================================================
+// "match" scopes keyword matching to one payload field of the chunks, such
+// as a section_title or document_name set at ingestion, rather than their
+// whole text:
+//
+//	"match": {"field": "section_title", "value": "net worth"}
+//	"match": [{"field": "section_title", "value": "net worth", "required": true},
+//	          {"field": "document_name", "value": "PA guidelines", "boost": 0.5}]
+//
+// A clause adds boost (default MATCH_BOOST, 0.2) times the share of the
+// value's terms found in the field to each result's score, after
+// reranking; a required clause drops the results whose field lacks any of
+// them. Terms are compared as keyword search tokenizes them; a list field
+// matches on its elements together. The search fetches twice top_k results
+// so dropped slots are refilled. To filter on a payload field's exact
+// value instead, see "payload." filters in filters.go.
+
+var MATCH_BOOST = envFloat("MATCH_BOOST", 0.2)
+
+const matchCandidateFactor = 2
+
+// MatchClause - A field-scoped keyword match
+type MatchClause struct {
+	Field    string   `json:"field"`              // Payload field matched, e.g. "section_title"
+	Value    string   `json:"value"`              // Terms looked for in it
+	Boost    *float64 `json:"boost,omitempty"`    // Optional: score added when every term matches (default MATCH_BOOST)
+	Required bool     `json:"required,omitempty"` // Optional: drop results missing a term
+}
+
+// MatchClauses - The "match" field of a request: one clause or a list
+type MatchClauses []MatchClause
+
+func (m *MatchClauses) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var one MatchClause
+	if err := json.Unmarshal(data, &one); err == nil {
+		*m = MatchClauses{one}
+		return nil
+	}
+	var list []MatchClause
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("match must be a clause or a list of clauses")
+	}
+	*m = list
+	return nil
+}
+
+// validate checks every clause names a field and terms to look for.
+func (m MatchClauses) validate() error {
+	for i, c := range m {
+		if c.Field == "" {
+			return fmt.Errorf("match[%d]: field cannot be empty", i)
+		}
+		if len(tokenize(c.Value)) == 0 {
+			return fmt.Errorf("match[%d]: value has no terms to match", i)
+		}
+		if c.Boost != nil && *c.Boost < 0 {
+			return errors.New("match boost must not be negative")
+		}
+	}
+	return nil
+}
+
+// applyMatches boosts results by the clauses they match and drops those
+// failing a required one, returning the rest best first.
+func applyMatches(results []RetrievalResult, clauses MatchClauses) []RetrievalResult {
+	matched := make([]RetrievalResult, 0, len(results))
+	for _, r := range results {
+		keep := true
+		for _, c := range clauses {
+			share := fieldMatch(r.Metadata[c.Field], c.Value)
+			if c.Required && share < 1 {
+				keep = false
+				break
+			}
+			boost := MATCH_BOOST
+			if c.Boost != nil {
+				boost = *c.Boost
+			}
+			r.Score += boost * share
+		}
+		if keep {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(a, b int) bool {
+		return matched[a].Score > matched[b].Score
+	})
+	return matched
+}
+
+// fieldMatch is the share of value's terms found in a payload field: a
+// string, or a list of them.
+func fieldMatch(field interface{}, value string) float64 {
+	var text string
+	switch f := field.(type) {
+	case string:
+		text = f
+	case []interface{}:
+		for _, item := range f {
+			if s, ok := item.(string); ok {
+				text += " " + s
+			}
+		}
+	default:
+		return 0
+	}
+
+	have := make(map[string]bool)
+	for _, t := range tokenize(text) {
+		have[t] = true
+	}
+	terms := tokenize(value)
+	found := 0
+	for _, t := range terms {
+		if have[t] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
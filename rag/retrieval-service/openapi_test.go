@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"shared/openapi"
+)
+
+// TestSpecMatchesTypes holds openapi.json to the types the handlers decode
+// and encode, so neither changes without the other.
+func TestSpecMatchesTypes(t *testing.T) {
+	spec := openapi.MustLoad(openAPISpec)
+	for name, v := range map[string]interface{}{
+		"RetrievalRequest":       RetrievalRequest{},
+		"RerankWeights":          RerankWeights{},
+		"RetrievalResult":        RetrievalResult{},
+		"Highlight":              Highlight{},
+		"TermMatch":              TermMatch{},
+		"RetrievalResponse":      RetrievalResponse{},
+		"Widening":               Widening{},
+		"BatchRetrievalRequest":  BatchRetrievalRequest{},
+		"BatchRetrievalResponse": BatchRetrievalResponse{},
+		"BatchRetrievalItem":     BatchRetrievalItem{},
+		"ContextRequest":         ContextRequest{},
+		"ContextResponse":        ContextResponse{},
+		"ContextCitation":        ContextCitation{},
+		"StreamLine":             StreamLine{},
+		"EvaluateRequest":        EvaluateRequest{},
+		"EvaluationCase":         EvaluationCase{},
+		"EvaluateResponse":       EvaluateResponse{},
+		"EvaluationResult":       EvaluationResult{},
+		"MatchClause":            MatchClause{},
+		"ScoreExplanation":       ScoreExplanation{},
+	} {
+		if err := spec.CheckType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"shared/tenant"
+)
+
+// ============================================================================
+// PAGINATION
+// ============================================================================
+// A request with "paginate": true or an "offset" retrieves, reranks and
+// keeps the best RETRIEVAL_PAGE_DEPTH results (default 50) once, and returns
+// top_k of them from offset on. While more are left the response carries a
+// "next_page_token"; a request with just that token (and optionally top_k
+// as the page size) returns the next page from the kept results without
+// running the query again. Kept results expire after RETRIEVAL_PAGE_TTL
+// (default 10m), in Redis when the result cache is on (see cache.go) so any
+// replica can serve the next page, else in process memory.
+
+var (
+	RETRIEVAL_PAGE_DEPTH = envInt("RETRIEVAL_PAGE_DEPTH", 50)
+	RETRIEVAL_PAGE_TTL   = envDuration("RETRIEVAL_PAGE_TTL", 10*time.Minute)
+)
+
+var (
+	errInvalidPageToken = errors.New("invalid page_token")
+	errPageExpired      = errors.New("page_token has expired, run the query again")
+)
+
+// pageStore keeps the results being paged through.
+type pageStore interface {
+	Put(ctx context.Context, key string, response *RetrievalResponse) error
+	// Get returns nil when key has expired.
+	Get(ctx context.Context, key string) (*RetrievalResponse, error)
+}
+
+var pages pageStore = newMemoryPageStore(RETRIEVAL_PAGE_TTL)
+
+// initPageStore moves kept results to Redis when the result cache is on.
+func initPageStore() {
+	if resultCache != nil {
+		pages = &redisPageStore{client: resultCache.client, ttl: RETRIEVAL_PAGE_TTL}
+	}
+}
+
+// pageToken is where the next page starts: the kept results of session,
+// from offset on.
+type pageToken struct {
+	session string
+	offset  int
+}
+
+func (t pageToken) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.session + ":" + strconv.Itoa(t.offset)))
+}
+
+func parsePageToken(s string) (pageToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageToken{}, errInvalidPageToken
+	}
+	session, offset, ok := strings.Cut(string(raw), ":")
+	n, err := strconv.Atoi(offset)
+	if !ok || session == "" || err != nil || n < 0 {
+		return pageToken{}, errInvalidPageToken
+	}
+	return pageToken{session: session, offset: n}, nil
+}
+
+// pageKey scopes a session to the caller's tenant.
+func pageKey(ctx context.Context, session string) string {
+	return "retrieval:pages:" + tenant.FromContext(ctx) + ":" + session
+}
+
+// paginates reports whether req pages through its results.
+func paginates(req RetrievalRequest) bool {
+	return req.PageToken != "" || req.Paginate || req.Offset > 0
+}
+
+// retrievePage runs req to RETRIEVAL_PAGE_DEPTH results, keeps them and
+// returns the page at req.Offset, or the page of req.PageToken from kept
+// results.
+func retrievePage(ctx context.Context, req RetrievalRequest, vectors *queryVectors) (*RetrievalResponse, error) {
+	startTime := time.Now()
+	pageSize := req.TopK
+	if pageSize == 0 {
+		pageSize = 5
+	}
+
+	if req.PageToken != "" {
+		token, err := parsePageToken(req.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		kept, err := pages.Get(ctx, pageKey(ctx, token.session))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load page: %w", err)
+		}
+		if kept == nil {
+			return nil, errPageExpired
+		}
+		log.Printf("📄 Page of '%s' at %d (%d kept results)", kept.Query, token.offset, len(kept.Results))
+		return page(kept, token, pageSize, startTime), nil
+	}
+
+	full := req
+	full.TopK = max(RETRIEVAL_PAGE_DEPTH, req.Offset+pageSize)
+	response, err := runRetrieval(ctx, full, vectors)
+	if err != nil {
+		return nil, err
+	}
+
+	token := pageToken{session: newSessionID(), offset: req.Offset}
+	if err := pages.Put(ctx, pageKey(ctx, token.session), response); err != nil {
+		// The first page is still an answer, just the last one
+		log.Printf("⚠️  Failed to keep results for paging: %v", err)
+		token.session = ""
+	}
+	return page(response, token, pageSize, startTime), nil
+}
+
+// page cuts the page at token from kept.
+func page(kept *RetrievalResponse, token pageToken, pageSize int, startTime time.Time) *RetrievalResponse {
+	response := *kept
+	start := min(token.offset, len(kept.Results))
+	end := min(start+pageSize, len(kept.Results))
+	response.Results = kept.Results[start:end]
+	response.Count = len(response.Results)
+	response.Offset = start
+	response.Total = len(kept.Results)
+	response.NextPageToken = ""
+	if end < len(kept.Results) && token.session != "" {
+		response.NextPageToken = pageToken{session: token.session, offset: end}.String()
+	}
+	response.ProcessTime = float64(time.Since(startTime).Milliseconds())
+	return &response
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// memoryPageStore keeps results in process memory, dropping expired ones
+// as new ones arrive.
+type memoryPageStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryPage
+	ttl     time.Duration
+}
+
+type memoryPage struct {
+	response *RetrievalResponse
+	expires  time.Time
+}
+
+func newMemoryPageStore(ttl time.Duration) *memoryPageStore {
+	return &memoryPageStore{entries: make(map[string]memoryPage), ttl: ttl}
+}
+
+func (s *memoryPageStore) Put(_ context.Context, key string, response *RetrievalResponse) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryPage{response: response, expires: now.Add(s.ttl)}
+	return nil
+}
+
+func (s *memoryPageStore) Get(_ context.Context, key string) (*RetrievalResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, nil
+	}
+	return e.response, nil
+}
+
+// redisPageStore keeps results in Redis, shared between replicas.
+type redisPageStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func (s *redisPageStore) Put(ctx context.Context, key string, response *RetrievalResponse) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, b, s.ttl).Err()
+}
+
+func (s *redisPageStore) Get(ctx context.Context, key string) (*RetrievalResponse, error) {
+	b, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var response RetrievalResponse
+	if err := json.Unmarshal(b, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
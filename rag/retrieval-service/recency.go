@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// RECENCY
+// ============================================================================
+// "recency_weight": w (0 to 1) blends each result's score with how recent
+// its document is, so a current circular outranks the one it superseded:
+//
+//	score = (1-w)·score + w·0.5^(age / RECENCY_HALF_LIFE)
+//
+// The age runs from the document's effective_date when it was ingested with
+// one, else from its upload. RECENCY_HALF_LIFE defaults to a year (8760h).
+// Results without a date get no recency share. The search fetches twice
+// top_k results, so newer ones ranked lower can rise into the top_k.
+
+var RECENCY_HALF_LIFE = envDuration("RECENCY_HALF_LIFE", 365*24*time.Hour)
+
+const recencyCandidateFactor = 2
+
+// applyRecency rescores results by recency and sorts them best first.
+func applyRecency(results []RetrievalResult, weight float64, now time.Time) []RetrievalResult {
+	boosted := make([]RetrievalResult, len(results))
+	copy(boosted, results)
+	for i, r := range boosted {
+		freshness := 0.0
+		if date, ok := documentDate(r.Metadata); ok {
+			age := max(now.Sub(date), 0)
+			freshness = math.Pow(0.5, float64(age)/float64(RECENCY_HALF_LIFE))
+		}
+		boosted[i].Score = (1-weight)*r.Score + weight*freshness
+	}
+	sort.SliceStable(boosted, func(a, b int) bool {
+		return boosted[a].Score > boosted[b].Score
+	})
+	return boosted
+}
+
+// documentDate is the effective date of a result's document, or its
+// upload time: Unix seconds in the payload, or the metadata service's
+// RFC 3339 time once enriched.
+func documentDate(metadata map[string]interface{}) (time.Time, bool) {
+	for _, field := range []string{"effective_date", "uploaded_at"} {
+		switch v := metadata[field].(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t, true
+			}
+		default:
+			if secs, ok := toFloat(v); ok && secs > 0 {
+				return time.Unix(int64(secs), 0), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// RERANKERS
+// ============================================================================
+// The rerank step re-scores the vector hits with one of these strategies,
+// chosen per request by "rerank" or else by RERANK_STRATEGY (default
+// "keyword"), when the retrieval_rerank flag is on:
+//
+//	keyword        blend the vector score with the share of query terms found
+//	cross_encoder  score each (query, chunk) pair with the cross-encoder served
+//	               at CROSS_ENCODER_URL (a text-embeddings-inference /rerank API)
+//	llm            ask RERANK_LLM_MODEL to rate each chunk's relevance 0-10
+//	none           keep vector order
+//
+// The model strategies see the best RERANK_CANDIDATES hits (default 20)
+// rather than top_k, so a chunk the vector search ranked too low can still
+// be promoted. When one fails, the request falls back to keyword.
+//
+// keyword blends the scores RERANK_VECTOR_WEIGHT (default 0.7) to
+// RERANK_KEYWORD_WEIGHT (default 0.3). RERANK_COLLECTION_WEIGHTS sets the
+// blend of single collections, as "collection=vector:keyword,..." (e.g.
+// "kyc_docs=0.5:0.5"), and "rerank_weights" that of a request. Weights are
+// scaled to sum to 1, so the blend stays a 0-1 score.
+//
+// A strategy is a Reranker; registerReranker adds one under its name, for
+// "rerank" and RERANK_STRATEGY to pick.
+
+const (
+	rerankKeyword      = "keyword"
+	rerankCrossEncoder = "cross_encoder"
+	rerankLLM          = "llm"
+	rerankNone         = "none"
+)
+
+var (
+	RERANK_STRATEGY           = getEnv("RERANK_STRATEGY", rerankKeyword)
+	RERANK_CANDIDATES         = envInt("RERANK_CANDIDATES", 20)
+	CROSS_ENCODER_URL         = getEnv("CROSS_ENCODER_URL", "")
+	RERANK_LLM_MODEL          = getEnv("RERANK_LLM_MODEL", RETRIEVAL_LLM_MODEL)
+	RERANK_VECTOR_WEIGHT      = envFloat("RERANK_VECTOR_WEIGHT", 0.7)
+	RERANK_KEYWORD_WEIGHT     = envFloat("RERANK_KEYWORD_WEIGHT", 0.3)
+	RERANK_COLLECTION_WEIGHTS = getEnv("RERANK_COLLECTION_WEIGHTS", "")
+
+	// rerankClient calls the cross-encoder, which gets a request's worth of
+	// text and is given longer than the services above
+	rerankClient = httpclient.New(httpclient.Options{Timeout: 60 * time.Second})
+)
+
+// RerankWeights - How the keyword reranker blends a result's vector score
+// with its keyword match
+type RerankWeights struct {
+	Vector  float64 `json:"vector"`
+	Keyword float64 `json:"keyword"`
+}
+
+// validate checks the weights can be scaled to sum to 1.
+func (w RerankWeights) validate() error {
+	if w.Vector < 0 || w.Keyword < 0 || w.Vector+w.Keyword == 0 {
+		return errors.New("rerank weights must not be negative and must not both be 0")
+	}
+	return nil
+}
+
+// normalized scales the weights to sum to 1.
+func (w RerankWeights) normalized() RerankWeights {
+	sum := w.Vector + w.Keyword
+	return RerankWeights{Vector: w.Vector / sum, Keyword: w.Keyword / sum}
+}
+
+// collectionWeights - The blend of each collection in
+// RERANK_COLLECTION_WEIGHTS
+var collectionWeights map[string]RerankWeights
+
+// initRerankWeights reads the configured blends, failing on bad ones.
+func initRerankWeights() {
+	if err := (RerankWeights{RERANK_VECTOR_WEIGHT, RERANK_KEYWORD_WEIGHT}).validate(); err != nil {
+		log.Fatalf("Invalid RERANK_VECTOR_WEIGHT/RERANK_KEYWORD_WEIGHT: %v", err)
+	}
+	weights, err := parseCollectionWeights(RERANK_COLLECTION_WEIGHTS)
+	if err != nil {
+		log.Fatalf("Invalid RERANK_COLLECTION_WEIGHTS: %v", err)
+	}
+	collectionWeights = weights
+}
+
+// parseCollectionWeights reads "collection=vector:keyword,...".
+func parseCollectionWeights(s string) (map[string]RerankWeights, error) {
+	weights := make(map[string]RerankWeights)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		collection, blend, ok := strings.Cut(entry, "=")
+		vector, keyword, ok2 := strings.Cut(blend, ":")
+		if !ok || !ok2 || collection == "" {
+			return nil, fmt.Errorf("%q is not collection=vector:keyword", entry)
+		}
+		var w RerankWeights
+		var err error
+		if w.Vector, err = strconv.ParseFloat(vector, 64); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		if w.Keyword, err = strconv.ParseFloat(keyword, 64); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		if err := w.validate(); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		weights[collection] = w
+	}
+	return weights, nil
+}
+
+// rerankWeights is the blend req asks for, else its collection's, else the
+// default, scaled to sum to 1.
+func rerankWeights(req RetrievalRequest) RerankWeights {
+	if req.RerankWeights != nil {
+		return req.RerankWeights.normalized()
+	}
+	if w, ok := collectionWeights[req.Collection]; ok {
+		return w.normalized()
+	}
+	return RerankWeights{RERANK_VECTOR_WEIGHT, RERANK_KEYWORD_WEIGHT}.normalized()
+}
+
+// RerankQuery - What a reranker scores results against
+type RerankQuery struct {
+	Text       string        // The user's question
+	Collection string        // The collection searched
+	Weights    RerankWeights // The blend asked for, for rerankers blending scores
+	TopK       int           // Results wanted; rerankers may return only these, 0 for all
+}
+
+// Reranker - A rerank strategy: re-scores results for a query, returning
+// them best first
+type Reranker interface {
+	Rerank(ctx context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error)
+	// SeesCandidates reports whether it is given RERANK_CANDIDATES hits
+	// instead of top_k
+	SeesCandidates() bool
+}
+
+// rerankFunc - A Reranker from a function
+type rerankFunc struct {
+	rerank     func(ctx context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error)
+	candidates bool
+}
+
+func (r rerankFunc) Rerank(ctx context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error) {
+	return r.rerank(ctx, query, results)
+}
+
+func (r rerankFunc) SeesCandidates() bool { return r.candidates }
+
+var rerankers = make(map[string]Reranker)
+
+// registerReranker makes r the strategy called name. Registering a name
+// twice is a programming error.
+func registerReranker(name string, r Reranker) {
+	if _, ok := rerankers[name]; ok {
+		panic("reranker registered twice: " + name)
+	}
+	rerankers[name] = r
+}
+
+func init() {
+	registerReranker(rerankKeyword, rerankFunc{rerank: keywordRerank})
+	registerReranker(rerankCrossEncoder, rerankFunc{rerank: crossEncoderRerank, candidates: true})
+	registerReranker(rerankLLM, rerankFunc{rerank: llmRerank, candidates: true})
+	registerReranker(rerankNone, rerankFunc{rerank: func(_ context.Context, _ RerankQuery, results []RetrievalResult) ([]RetrievalResult, error) {
+		return results, nil
+	}})
+}
+
+// RerankMode - The "rerank" field of a request: a strategy name, or true
+// and false as before strategies existed, meaning RERANK_STRATEGY and none
+type RerankMode string
+
+func (m *RerankMode) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var on bool
+	if err := json.Unmarshal(data, &on); err == nil {
+		*m = rerankNone
+		if on {
+			*m = RerankMode(RERANK_STRATEGY)
+		}
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("rerank must be a strategy name or a boolean")
+	}
+	*m = RerankMode(name)
+	return nil
+}
+
+// validRerankMode reports whether mode is empty or a known strategy.
+func validRerankMode(mode RerankMode) bool {
+	_, ok := rerankers[string(mode)]
+	return mode == "" || ok
+}
+
+// rerankStrategy is the strategy req asks for, or the configured one.
+func rerankStrategy(req RetrievalRequest) string {
+	if req.Rerank != "" {
+		return string(req.Rerank)
+	}
+	if !rerankFlag.Enabled() {
+		return rerankNone
+	}
+	return RERANK_STRATEGY
+}
+
+// rerankCandidates is how many vector hits strategy should see.
+func rerankCandidates(strategy string, topK int) int {
+	if r, ok := rerankers[strategy]; ok && r.SeesCandidates() {
+		return max(topK, RERANK_CANDIDATES)
+	}
+	return topK
+}
+
+// applyRerank re-scores results with strategy and keeps the best topK.
+func applyRerank(ctx context.Context, strategy string, req RetrievalRequest, results []RetrievalResult, topK int) []RetrievalResult {
+	query := RerankQuery{Text: req.Query, Collection: req.Collection, Weights: rerankWeights(req), TopK: topK}
+	reranked, err := rerankers[strategy].Rerank(ctx, query, results)
+	if err != nil {
+		log.Printf("⚠️  %s reranking failed, falling back to keyword: %v", strategy, err)
+		reranked, _ = keywordRerank(ctx, query, results)
+	}
+	if len(reranked) > topK {
+		reranked = reranked[:topK]
+	}
+	return reranked
+}
+
+func keywordRerank(_ context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error) {
+	return rerankResults(query.Text, results, query.Weights, query.TopK), nil
+}
+
+// rescore returns copies of the topK best results (all of them when topK
+// is 0) with their new scores, best first. Equal scores keep the order the
+// results came in. Fewer than all are picked with a heap of topK, so a
+// large candidate pool isn't sorted whole; results isn't modified.
+func rescore(results []RetrievalResult, scores []float64, topK int) []RetrievalResult {
+	if topK <= 0 || topK > len(results) {
+		topK = len(results)
+	}
+
+	best := &rankHeap{scores: scores, order: make([]int, 0, topK)}
+	for i := range results {
+		switch {
+		case best.Len() < topK:
+			heap.Push(best, i)
+		case best.ranksBelow(best.order[0], i):
+			best.order[0] = i
+			heap.Fix(best, 0)
+		}
+	}
+	sort.Slice(best.order, func(a, b int) bool {
+		return best.ranksBelow(best.order[b], best.order[a])
+	})
+
+	reranked := make([]RetrievalResult, len(best.order))
+	for n, i := range best.order {
+		reranked[n] = results[i]
+		reranked[n].Score = scores[i]
+	}
+	return reranked
+}
+
+// rankHeap holds the indexes of the best results seen so far, the worst at
+// the root, so a better result can replace it.
+type rankHeap struct {
+	scores []float64
+	order  []int
+}
+
+// ranksBelow reports whether result i ranks below result j: a lower
+// score, or the same score later in the input.
+func (h *rankHeap) ranksBelow(i, j int) bool {
+	if h.scores[i] != h.scores[j] {
+		return h.scores[i] < h.scores[j]
+	}
+	return i > j
+}
+
+func (h *rankHeap) Len() int           { return len(h.order) }
+func (h *rankHeap) Less(a, b int) bool { return h.ranksBelow(h.order[a], h.order[b]) }
+func (h *rankHeap) Swap(a, b int)      { h.order[a], h.order[b] = h.order[b], h.order[a] }
+func (h *rankHeap) Push(x any)         { h.order = append(h.order, x.(int)) }
+func (h *rankHeap) Pop() any {
+	last := h.order[len(h.order)-1]
+	h.order = h.order[:len(h.order)-1]
+	return last
+}
+
+// ============================================================================
+// CROSS-ENCODER
+// ============================================================================
+
+func crossEncoderRerank(ctx context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error) {
+	if CROSS_ENCODER_URL == "" {
+		return nil, fmt.Errorf("CROSS_ENCODER_URL is not set")
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	texts := make([]string, len(results))
+	for i, r := range results {
+		texts[i] = r.Text
+	}
+	var ranked []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	err := rerankClient.PostJSON(ctx, CROSS_ENCODER_URL+"/rerank", map[string]interface{}{
+		"query": query.Text,
+		"texts": texts,
+	}, &ranked, httpclient.Idempotent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call cross-encoder: %w", err)
+	}
+
+	scores := make([]float64, len(results))
+	for _, r := range ranked {
+		if r.Index < 0 || r.Index >= len(results) {
+			return nil, fmt.Errorf("cross-encoder returned index %d for %d texts", r.Index, len(results))
+		}
+		scores[r.Index] = r.Score
+	}
+	return rescore(results, scores, query.TopK), nil
+}
+
+// ============================================================================
+// LLM
+// ============================================================================
+
+// llmPassageChars bounds each chunk quoted into the scoring prompt.
+const llmPassageChars = 1500
+
+func llmRerank(ctx context.Context, query RerankQuery, results []RetrievalResult) ([]RetrievalResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Rate how well each passage answers the query, from 0 (irrelevant) to 10 (answers it fully).\n"+
+		"Reply with only a JSON array of %d numbers, one per passage, in order.\n\nQuery: %s\n", len(results), query.Text)
+	for i, r := range results {
+		text := r.Text
+		if len(text) > llmPassageChars {
+			text = text[:llmPassageChars] + "..."
+		}
+		fmt.Fprintf(&prompt, "\nPassage %d:\n%s\n", i+1, text)
+	}
+
+	text, err := generateContent(ctx, RERANK_LLM_MODEL, prompt.String(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratings []float64
+	if err := json.Unmarshal([]byte(text), &ratings); err != nil {
+		return nil, fmt.Errorf("%s returned unreadable scores: %w", RERANK_LLM_MODEL, err)
+	}
+	if len(ratings) != len(results) {
+		return nil, fmt.Errorf("%s rated %d of %d passages", RERANK_LLM_MODEL, len(ratings), len(results))
+	}
+	scores := make([]float64, len(ratings))
+	for i, rating := range ratings {
+		scores[i] = min(max(rating, 0), 10) / 10
+	}
+	return rescore(results, scores, query.TopK), nil
+}
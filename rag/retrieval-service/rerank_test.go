@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// rankedIDs is the IDs of results, in order.
+func rankedIDs(results []RetrievalResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestRescore(t *testing.T) {
+	results := []RetrievalResult{{ID: "a", Score: 0.1}, {ID: "b", Score: 0.2}, {ID: "c", Score: 0.3}, {ID: "d", Score: 0.4}, {ID: "e", Score: 0.5}}
+	tests := []struct {
+		name   string
+		scores []float64
+		topK   int
+		want   []string
+	}{
+		{"best first", []float64{0.2, 0.9, 0.5, 0.1, 0.7}, 0, []string{"b", "e", "c", "a", "d"}},
+		{"ties keep input order", []float64{0.5, 0.9, 0.5, 0.5, 0.1}, 0, []string{"b", "a", "c", "d", "e"}},
+		{"all tied", []float64{0.5, 0.5, 0.5, 0.5, 0.5}, 0, []string{"a", "b", "c", "d", "e"}},
+		{"top K", []float64{0.2, 0.9, 0.5, 0.1, 0.7}, 2, []string{"b", "e"}},
+		{"top K ties keep input order", []float64{0.5, 0.9, 0.5, 0.5, 0.1}, 3, []string{"b", "a", "c"}},
+		{"top K tied at the cut", []float64{0.1, 0.5, 0.5, 0.9, 0.5}, 2, []string{"d", "b"}},
+		{"top K of one", []float64{0.2, 0.9, 0.5, 0.1, 0.7}, 1, []string{"b"}},
+		{"top K beyond the results", []float64{0.2, 0.9, 0.5, 0.1, 0.7}, 10, []string{"b", "e", "c", "a", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]RetrievalResult(nil), results...)
+			scores := append([]float64(nil), tt.scores...)
+
+			got := rescore(input, scores, tt.topK)
+			if ids := rankedIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("order = %v, want %v", ids, tt.want)
+			}
+			for _, r := range got {
+				if want := tt.scores[r.ID[0]-'a']; r.Score != want {
+					t.Errorf("%s score = %v, want %v", r.ID, r.Score, want)
+				}
+			}
+			if !reflect.DeepEqual(input, results) {
+				t.Errorf("rescore modified its input: %v", input)
+			}
+			if !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("rescore modified the scores: %v", scores)
+			}
+		})
+	}
+	if got := rescore(nil, nil, 5); len(got) != 0 {
+		t.Errorf("rescore of nothing = %v", got)
+	}
+}
+
+// TestRescoreTopKMatchesSort checks the heap selection against sorting
+// every result, on enough results to exercise the heap.
+func TestRescoreTopKMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	results := make([]RetrievalResult, 500)
+	scores := make([]float64, len(results))
+	for i := range results {
+		results[i] = RetrievalResult{ID: fmt.Sprintf("chunk-%d", i)}
+		scores[i] = float64(rng.Intn(50)) / 50 // plenty of ties
+	}
+	all := rescore(results, scores, 0)
+	for _, topK := range []int{1, 10, 100, 499} {
+		if got, want := rankedIDs(rescore(results, scores, topK)), rankedIDs(all[:topK]); !reflect.DeepEqual(got, want) {
+			t.Errorf("top %d = %v, want %v", topK, got, want)
+		}
+	}
+}
+
+func TestRerankResults(t *testing.T) {
+	results := []RetrievalResult{
+		{ID: "vector", Score: 0.9, Text: "merchant onboarding checklist"},
+		{ID: "keyword", Score: 0.7, Text: "net worth requirements for payment aggregators"},
+		{ID: "neither", Score: 0.2, Text: "refund timelines"},
+	}
+	tests := []struct {
+		name    string
+		weights RerankWeights
+		topK    int
+		want    []string
+	}{
+		{"vector only", RerankWeights{Vector: 1}, 0, []string{"vector", "keyword", "neither"}},
+		{"default blend", RerankWeights{Vector: 0.7, Keyword: 0.3}, 0, []string{"keyword", "vector", "neither"}},
+		{"keyword only", RerankWeights{Keyword: 1}, 0, []string{"keyword", "vector", "neither"}},
+		{"truncated", RerankWeights{Vector: 0.7, Keyword: 0.3}, 1, []string{"keyword"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rerankResults("net worth requirements", results, tt.weights, tt.topK)
+			if ids := rankedIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("order = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkResults is n vector hits in descending score order, their texts
+// sharing some of the benchmark query's terms.
+func benchmarkResults(n int) []RetrievalResult {
+	words := []string{"kyc", "merchant", "settlement", "requirements", "payment", "aggregator",
+		"net", "worth", "guidelines", "escrow", "refund", "timelines", "onboarding", "risk"}
+	rng := rand.New(rand.NewSource(1))
+	results := make([]RetrievalResult, n)
+	for i := range results {
+		text := ""
+		for w := 0; w < 80; w++ {
+			text += words[rng.Intn(len(words))] + " "
+		}
+		results[i] = RetrievalResult{
+			ID:         fmt.Sprintf("chunk-%d", i),
+			Score:      1 - float64(i)/float64(n),
+			Text:       text,
+			DocumentID: fmt.Sprintf("doc-%d", i%20),
+		}
+	}
+	return results
+}
+
+func BenchmarkRerankResults(b *testing.B) {
+	weights := RerankWeights{Vector: 0.7, Keyword: 0.3}
+	for _, n := range []int{10, 100, 500, 2000} {
+		results := benchmarkResults(n)
+		for _, topK := range []int{10, 0} {
+			b.Run(fmt.Sprintf("n=%d/topK=%d", n, topK), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					rerankResults("net worth requirements for payment aggregators", results, weights, topK)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"shared/httpclient"
+	"shared/tracing"
+)
+
+// ============================================================================
+// STREAMING (NDJSON)
+// ============================================================================
+// A /retrieve request with "stream": true is answered as newline-delimited
+// JSON instead of one response, so a large top_k doesn't wait on every
+// metadata lookup:
+//
+//	{"result": {...}}   a result, in rank order, as soon as it is enriched
+//	{"done": {...}}     the rest of the response: count, process_time_ms,
+//	                    widened, queries, query_language, cached,
+//	                    degraded, context_tokens, collections
+//
+// Errors before the first line are answered as usual, with a status code.
+// The search, reranking and thresholds run as usual but without metadata;
+// each result's document is then looked up (ENRICH_CONCURRENCY at a time)
+// and the result sent once it and those ranked above it are enriched.
+// Streamed results aren't stored in the result cache, though a cached
+// answer is streamed. Pagination can't be streamed.
+
+// StreamLine - One line of a streamed response
+type StreamLine struct {
+	Result *RetrievalResult `json:"result,omitempty"`
+	Done   *StreamSummary   `json:"done,omitempty"`
+}
+
+// StreamSummary - The response of a stream besides its results
+type StreamSummary struct {
+	Query         string    `json:"query"`
+	Count         int       `json:"count"`
+	ProcessTime   float64   `json:"process_time_ms"`
+	Widened       *Widening `json:"widened,omitempty"`
+	Queries       []string  `json:"queries,omitempty"`
+	QueryLanguage string    `json:"query_language,omitempty"`
+	Cached        bool      `json:"cached,omitempty"`
+	Degraded      bool      `json:"degraded,omitempty"`
+	ContextTokens int       `json:"context_tokens,omitempty"`
+	Collections   []string  `json:"collections,omitempty"`
+}
+
+type deferEnrichmentKey struct{}
+
+// withDeferredEnrichment makes the pipeline leave results unenriched, for
+// the stream to enrich.
+func withDeferredEnrichment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferEnrichmentKey{}, true)
+}
+
+func enrichmentDeferred(ctx context.Context) bool {
+	deferred, _ := ctx.Value(deferEnrichmentKey{}).(bool)
+	return deferred
+}
+
+// streamRetrieval answers req as NDJSON on w.
+func streamRetrieval(w http.ResponseWriter, r *http.Request, req RetrievalRequest) {
+	startTime := time.Now()
+	ctx := withDeferredEnrichment(r.Context())
+
+	response, err := retrieve(ctx, req)
+	if errors.Is(err, errPageExpired) {
+		respondError(w, err.Error(), http.StatusGone)
+		return
+	}
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	send := func(line StreamLine) bool {
+		if err := encoder.Encode(line); err != nil {
+			return false
+		}
+		rc.Flush()
+		return true
+	}
+
+	results := response.Results
+	if !response.Cached {
+		stepCtx, span := tracing.Start(ctx, "retrieval.enrich")
+		enriched := enrichAsReady(stepCtx, results)
+		for i := range results {
+			result := <-enriched[i]
+			if !send(StreamLine{Result: &result}) {
+				log.Printf("⚠️  Stream closed after %d of %d results", i, len(results))
+				span.End()
+				return
+			}
+		}
+		span.End()
+	} else {
+		for i := range results {
+			if !send(StreamLine{Result: &results[i]}) {
+				return
+			}
+		}
+	}
+
+	send(StreamLine{Done: &StreamSummary{
+		Query:         response.Query,
+		Count:         response.Count,
+		ProcessTime:   float64(time.Since(startTime).Milliseconds()),
+		Widened:       response.Widened,
+		Queries:       response.Queries,
+		QueryLanguage: response.QueryLanguage,
+		Cached:        response.Cached,
+		Degraded:      response.Degraded,
+		ContextTokens: response.ContextTokens,
+		Collections:   response.Collections,
+	}})
+}
+
+// enrichAsReady looks up the documents of results from a pool of
+// ENRICH_CONCURRENCY workers, returning a channel per result that yields it
+// enriched once its document has been looked up.
+func enrichAsReady(ctx context.Context, results []RetrievalResult) []chan RetrievalResult {
+	byDocument := make(map[string][]int)
+	var docIDs []string
+	out := make([]chan RetrievalResult, len(results))
+	for i, r := range results {
+		out[i] = make(chan RetrievalResult, 1)
+		if r.DocumentID == "" {
+			out[i] <- r
+			continue
+		}
+		if _, ok := byDocument[r.DocumentID]; !ok {
+			docIDs = append(docIDs, r.DocumentID)
+		}
+		byDocument[r.DocumentID] = append(byDocument[r.DocumentID], i)
+	}
+	if len(docIDs) == 0 {
+		return out
+	}
+
+	jobs := make(chan string, len(docIDs))
+	for _, docID := range docIDs {
+		jobs <- docID
+	}
+	close(jobs)
+
+	for w := 0; w < min(max(ENRICH_CONCURRENCY, 1), len(docIDs)); w++ {
+		go func() {
+			for docID := range jobs {
+				var doc map[string]interface{}
+				if ctx.Err() == nil {
+					var err error
+					doc, err = fetchDocument(ctx, docID)
+					if err != nil {
+						if !httpclient.IsStatus(err, http.StatusNotFound) {
+							log.Printf("⚠️  Failed to fetch metadata for %s: %v", docID, err)
+						}
+						doc = nil
+					}
+				}
+				for _, i := range byDocument[docID] {
+					out[i] <- enrichResult(results[i], doc)
+				}
+			}
+		}()
+	}
+	return out
+}
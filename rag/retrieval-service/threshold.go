@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// SCORE THRESHOLD
+// ============================================================================
+// min_score drops results whose final score (after reranking) is below it.
+// When min_results is set and fewer results are left, the search is
+// widened: first the same collection is searched again for
+// RETRIEVAL_WIDEN_FACTOR times top_k hits (at most RETRIEVAL_WIDEN_TOP_K,
+// default 50), which reranking may lift over the threshold, then every
+// collection in RETRIEVAL_WIDEN_COLLECTIONS is searched in turn until
+// enough clear it. The response says how far it was widened.
+
+var (
+	RETRIEVAL_WIDEN_FACTOR      = envInt("RETRIEVAL_WIDEN_FACTOR", 4)
+	RETRIEVAL_WIDEN_TOP_K       = envInt("RETRIEVAL_WIDEN_TOP_K", 50)
+	RETRIEVAL_WIDEN_COLLECTIONS = strings.Split(getEnv("RETRIEVAL_WIDEN_COLLECTIONS", "regulatory_docs,merchant_docs,kyc_docs"), ",")
+)
+
+// Widening - How a search was widened to find min_results results
+type Widening struct {
+	TopK        int      `json:"top_k"`                 // hits searched per collection
+	Collections []string `json:"collections,omitempty"` // collections searched besides the requested one
+}
+
+// applyThreshold drops results under req.MinScore and widens the search
+// while fewer than req.MinResults are left. It returns at most req.TopK
+// results, best first.
+func applyThreshold(ctx context.Context, req RetrievalRequest, queryEmbeddings [][]float32, results []RetrievalResult) ([]RetrievalResult, *Widening, error) {
+	kept := aboveScore(results, req.MinScore)
+	if len(kept) >= req.MinResults {
+		return kept, nil, nil
+	}
+
+	widened := &Widening{TopK: min(req.TopK*RETRIEVAL_WIDEN_FACTOR, RETRIEVAL_WIDEN_TOP_K)}
+	seen := make(map[string]bool)
+	for _, r := range kept {
+		seen[r.ID] = true
+	}
+	merge := func(more []RetrievalResult) {
+		for _, r := range aboveScore(more, req.MinScore) {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				kept = append(kept, r)
+			}
+		}
+	}
+
+	if widened.TopK > req.TopK {
+		log.Printf("   ↔️  %d of %d results above %.2f, widening to top %d",
+			len(kept), req.MinResults, req.MinScore, widened.TopK)
+		pass := req
+		pass.TopK = widened.TopK
+		more, err := searchCollection(ctx, pass, queryEmbeddings, time.Time{})
+		if err != nil {
+			return nil, nil, err
+		}
+		merge(more)
+	} else {
+		widened.TopK = req.TopK
+	}
+
+	for _, collection := range RETRIEVAL_WIDEN_COLLECTIONS {
+		collection = strings.TrimSpace(collection)
+		if len(kept) >= req.MinResults {
+			break
+		}
+		if collection == "" || collection == req.Collection {
+			continue
+		}
+		log.Printf("   ↔️  %d of %d results above %.2f, widening to %s",
+			len(kept), req.MinResults, req.MinScore, collection)
+		pass := req
+		pass.Collection = collection
+		pass.TopK = widened.TopK
+		more, err := searchCollection(ctx, pass, queryEmbeddings, time.Time{})
+		if err != nil {
+			// The collections searched so far are still an answer
+			log.Printf("⚠️  Widening to %s failed: %v", collection, err)
+			continue
+		}
+		widened.Collections = append(widened.Collections, collection)
+		merge(more)
+	}
+
+	sort.SliceStable(kept, func(a, b int) bool { return kept[a].Score > kept[b].Score })
+	if len(kept) > req.TopK {
+		kept = kept[:req.TopK]
+	}
+	return kept, widened, nil
+}
+
+// aboveScore returns the results scoring at least minScore.
+func aboveScore(results []RetrievalResult, minScore float64) []RetrievalResult {
+	if minScore <= 0 {
+		return results
+	}
+	kept := make([]RetrievalResult, 0, len(results))
+	for _, r := range results {
+		if r.Score >= minScore {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"shared/httpclient"
+)
+
+// ============================================================================
+// CONTEXT WINDOWS
+// ============================================================================
+// "expand_context": n replaces each hit's text with the passage of its
+// document from n chunks before it to n chunks after, fetched from the
+// vector service by document_id and position, so synthesis reads
+// contiguous text rather than isolated chunks. The chunk overlap of ingest
+// is removed where chunks are joined. The hit's own text stays in
+// metadata.text, and metadata.context_positions lists the chunks joined.
+//
+// Hits of one document whose windows overlap or touch are merged into the
+// best ranked of them, so fewer than top_k results can come back. n is at
+// most RETRIEVAL_MAX_EXPAND_CONTEXT (default 5).
+
+var RETRIEVAL_MAX_EXPAND_CONTEXT = envInt("RETRIEVAL_MAX_EXPAND_CONTEXT", 5)
+
+// minJoinOverlap is the shortest text repeated across a chunk boundary
+// that is taken for ingest overlap rather than coincidence.
+const minJoinOverlap = 8
+
+// window is the run of chunk positions lo..hi of a document.
+type window struct {
+	documentID string
+	lo, hi     int
+}
+
+// expandContext widens results, best first, to windows of n chunks either
+// side. A window that can't be fetched leaves its hit as it was.
+func expandContext(ctx context.Context, collection string, results []RetrievalResult, n int) []RetrievalResult {
+	expanded := make([]RetrievalResult, 0, len(results))
+	windows := make([]*window, 0, len(results)) // parallel to expanded, nil where a hit has no position
+	for _, r := range results {
+		position, ok := toFloat(r.Metadata["position"])
+		if r.DocumentID == "" || !ok {
+			expanded = append(expanded, r)
+			windows = append(windows, nil)
+			continue
+		}
+		p := int(position)
+		lo, hi := max(p-n, 0), p+n
+
+		merged := false
+		for _, w := range windows {
+			if w != nil && w.documentID == r.DocumentID && lo <= w.hi+1 && hi >= w.lo-1 {
+				w.lo, w.hi = min(w.lo, lo), max(w.hi, hi)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			expanded = append(expanded, r)
+			windows = append(windows, &window{documentID: r.DocumentID, lo: lo, hi: hi})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, w := range windows {
+		if w == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(r *RetrievalResult, w *window) {
+			defer wg.Done()
+			positions, texts, err := fetchWindow(ctx, collection, w)
+			if err != nil {
+				log.Printf("⚠️  Failed to expand context of %s: %v", r.ID, err)
+				return
+			}
+			if len(texts) == 0 {
+				return
+			}
+			// The payload map can be shared with the keyword index
+			metadata := make(map[string]interface{}, len(r.Metadata)+1)
+			for k, v := range r.Metadata {
+				metadata[k] = v
+			}
+			metadata["context_positions"] = positions
+			r.Metadata = metadata
+			r.Text = joinChunks(texts)
+		}(&expanded[i], w)
+	}
+	wg.Wait()
+	return expanded
+}
+
+// fetchWindow returns the positions and texts of w's chunks, in order.
+func fetchWindow(ctx context.Context, collection string, w *window) ([]int, []string, error) {
+	var page struct {
+		Points []struct {
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"points"`
+	}
+	err := httpClient.PostJSON(ctx, VECTOR_SERVICE_URL+"/scroll", map[string]interface{}{
+		"collection": collection,
+		"limit":      w.hi - w.lo + 1,
+		"filter": map[string]interface{}{
+			"document_id": w.documentID,
+			"position":    map[string]interface{}{"gte": w.lo, "lte": w.hi},
+		},
+	}, &page, httpclient.Idempotent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scroll %s: %w", collection, err)
+	}
+
+	chunks := make(map[int]string, len(page.Points))
+	positions := make([]int, 0, len(page.Points))
+	for _, p := range page.Points {
+		position, ok := toFloat(p.Payload["position"])
+		text, _ := p.Payload["text"].(string)
+		if !ok || text == "" {
+			continue
+		}
+		if _, dup := chunks[int(position)]; !dup {
+			positions = append(positions, int(position))
+		}
+		chunks[int(position)] = text
+	}
+	sort.Ints(positions)
+
+	texts := make([]string, len(positions))
+	for i, position := range positions {
+		texts[i] = chunks[position]
+	}
+	return positions, texts, nil
+}
+
+// joinChunks joins consecutive chunks, dropping the text a chunk repeats
+// from the end of the one before.
+func joinChunks(texts []string) string {
+	var b strings.Builder
+	for i, text := range texts {
+		if i > 0 {
+			if k := overlapLen(texts[i-1], text); k >= minJoinOverlap {
+				text = text[k:]
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// overlapLen is the length of the longest end of a that b starts with,
+// short of either whole string.
+func overlapLen(a, b string) int {
+	for k := min(len(a), len(b)) - 1; k > 0; k-- {
+		if strings.HasSuffix(a, b[:k]) {
+			return k
+		}
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+	"shared/tenant"
+)
+
+// ============================================================================
+// DELETING POINTS
+// ============================================================================
+// POST /delete removes the caller's points of a collection that match a
+// filter, written as for a search (see filter.go), such as
+// {"document_id": "…"} for every chunk of a document. The filter is
+// required, so one request can't empty a collection, and it is scoped to
+// the caller's tenant like every other read and write. The response counts
+// the points that matched.
+
+// DeleteRequest - Points to remove from a collection
+type DeleteRequest struct {
+	Collection string                 `json:"collection"`
+	Filter     map[string]interface{} `json:"filter"`
+}
+
+// DeleteResponse - How many points a delete removed
+type DeleteResponse struct {
+	Status     string `json:"status"`
+	Collection string `json:"collection"`
+	Deleted    uint64 `json:"deleted"`
+}
+
+func deleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Collection == "" {
+		respondError(w, "Collection name required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Filter) == 0 {
+		respondError(w, "A filter is required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := deletePoints(r.Context(), req.Collection, req.Filter)
+	if errors.Is(err, errInvalidFilter) {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		respondError(w, "Delete failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeleteResponse{Status: "deleted", Collection: req.Collection, Deleted: deleted})
+}
+
+// deletePoints removes the caller's points matching filter and waits for
+// the delete to be applied, returning how many matched.
+func deletePoints(ctx context.Context, collection string, filter map[string]interface{}) (uint64, error) {
+	scoped, err := searchFilter(tenant.FromContext(ctx), filter)
+	if err != nil {
+		return 0, err
+	}
+
+	exact := true
+	count, err := pointsClient.Count(ctx, &qdrant.CountPoints{
+		CollectionName: collection,
+		Filter:         scoped,
+		Exact:          &exact,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("counting points: %w", err)
+	}
+	matched := count.GetResult().GetCount()
+	if matched == 0 {
+		return 0, nil
+	}
+
+	wait := true
+	_, err = pointsClient.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collection,
+		Wait:           &wait,
+		Points: &qdrant.PointsSelector{
+			PointsSelectorOneOf: &qdrant.PointsSelector_Filter{Filter: scoped},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("Deleted %d points from collection: %s", matched, collection)
+	return matched, nil
+}
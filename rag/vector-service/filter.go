@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+)
+
+// ============================================================================
+// PAYLOAD FILTERS
+// ============================================================================
+// A search's or scroll's "filter" restricts it to points whose payload
+// matches every field given, besides the caller's tenant:
+//
+//	"document_type": "regulatory"               equals
+//	"position": 3                                equals (integers)
+//	"tags": ["kyc", "aml"]                       equals any; a list field matches when any element does
+//	"uploaded_at": {"gte": 1704067200, "lt": …}  in range (gt, gte, lt, lte)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// indexedFields are the payload fields the retrieval service filters on,
+// indexed so the filters stay cheap. position serves the neighbour lookups
+// of expand_context.
+var indexedFields = map[string]qdrant.FieldType{
+	tenantField:     qdrant.FieldType_FieldTypeKeyword,
+	"document_id":   qdrant.FieldType_FieldTypeKeyword,
+	"document_type": qdrant.FieldType_FieldTypeKeyword,
+	"position":      qdrant.FieldType_FieldTypeInteger,
+	"tags":          qdrant.FieldType_FieldTypeKeyword,
+	"uploaded_at":   qdrant.FieldType_FieldTypeFloat,
+}
+
+// ensurePayloadIndexes adds the payload indexes of indexedFields. Creating
+// an existing index is a no-op in Qdrant.
+func ensurePayloadIndexes(collection string) {
+	for field, fieldType := range indexedFields {
+		fieldType := fieldType
+		_, err := pointsClient.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: collection,
+			FieldName:      field,
+			FieldType:      &fieldType,
+		})
+		if err != nil {
+			log.Printf("Failed to index %s on %s: %v", field, collection, err)
+		}
+	}
+}
+
+// searchFilter combines the tenant filter with the conditions of filter.
+func searchFilter(tenantID string, filter map[string]interface{}) (*qdrant.Filter, error) {
+	scope := tenantFilter(tenantID)
+	if len(filter) == 0 {
+		return scope, nil
+	}
+
+	fields := make([]string, 0, len(filter))
+	for field := range filter {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	must := []*qdrant.Condition{{ConditionOneOf: &qdrant.Condition_Filter{Filter: scope}}}
+	for _, field := range fields {
+		condition, err := fieldCondition(field, filter[field])
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, &qdrant.Condition{ConditionOneOf: &qdrant.Condition_Field{Field: condition}})
+	}
+	return &qdrant.Filter{Must: must}, nil
+}
+
+func fieldCondition(field string, value interface{}) (*qdrant.FieldCondition, error) {
+	condition := &qdrant.FieldCondition{Key: field}
+	switch v := value.(type) {
+	case string:
+		condition.Match = &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: v}}
+	case bool:
+		condition.Match = &qdrant.Match{MatchValue: &qdrant.Match_Boolean{Boolean: v}}
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%w: %s must be an integer, or a range for decimals", errInvalidFilter, field)
+		}
+		condition.Match = &qdrant.Match{MatchValue: &qdrant.Match_Integer{Integer: int64(v)}}
+	case []interface{}:
+		keywords := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s must be a list of strings", errInvalidFilter, field)
+			}
+			keywords[i] = s
+		}
+		condition.Match = &qdrant.Match{MatchValue: &qdrant.Match_Keywords{
+			Keywords: &qdrant.RepeatedStrings{Strings: keywords},
+		}}
+	case map[string]interface{}:
+		r := &qdrant.Range{}
+		for op, bound := range v {
+			n, ok := bound.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s.%s must be a number", errInvalidFilter, field, op)
+			}
+			switch op {
+			case "gt":
+				r.Gt = &n
+			case "gte":
+				r.Gte = &n
+			case "lt":
+				r.Lt = &n
+			case "lte":
+				r.Lte = &n
+			default:
+				return nil, fmt.Errorf("%w: %s has unknown range operator %q", errInvalidFilter, field, op)
+			}
+		}
+		if len(v) == 0 {
+			return nil, fmt.Errorf("%w: %s has an empty range", errInvalidFilter, field)
+		}
+		condition.Range = r
+	default:
+		return nil, fmt.Errorf("%w: unsupported value for %s", errInvalidFilter, field)
+	}
+	return condition, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"protos/gorillapb"
+)
+
+// vectorServer exposes Qdrant upsert/search over the gorilla.v1.VectorService
+// contract, avoiding JSON encoding of large float arrays.
+type vectorServer struct {
+	gorillapb.UnimplementedVectorServiceServer
+}
+
+func (vectorServer) Upsert(ctx context.Context, req *gorillapb.UpsertRequest) (*gorillapb.UpsertResponse, error) {
+	if req.GetCollection() == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name required")
+	}
+
+	points := make([]*qdrant.PointStruct, len(req.GetPoints()))
+	for i, point := range req.GetPoints() {
+		if point.GetId() == "" {
+			return nil, status.Error(codes.InvalidArgument, "point ID must be provided")
+		}
+		if len(point.GetVector().GetValues()) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "point vector must be provided")
+		}
+
+		payload := make(map[string]*qdrant.Value)
+		for key, val := range point.GetPayload().AsMap() {
+			payload[key] = toQdrantValue(val)
+		}
+
+		points[i] = &qdrant.PointStruct{
+			Id: &qdrant.PointId{
+				PointIdOptions: &qdrant.PointId_Uuid{Uuid: point.GetId()},
+			},
+			Vectors: &qdrant.Vectors{
+				VectorsOptions: &qdrant.Vectors_Vector{
+					Vector: &qdrant.Vector{Data: point.GetVector().GetValues()},
+				},
+			},
+			Payload: payload,
+		}
+	}
+
+	if err := upsertPoints(ctx, req.GetCollection(), points); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to upsert: %v", err)
+	}
+
+	return &gorillapb.UpsertResponse{
+		Status:     "success",
+		Collection: req.GetCollection(),
+		Points:     int32(len(points)),
+	}, nil
+}
+
+func (vectorServer) Search(ctx context.Context, req *gorillapb.SearchRequest) (*gorillapb.SearchResponse, error) {
+	results, err := searchPoints(ctx, SearchRequest{
+		Collection: req.GetCollection(),
+		Query:      req.GetQuery().GetValues(),
+		TopK:       int(req.GetTopK()),
+		Filter:     req.GetFilter().AsMap(),
+	})
+	if errors.Is(err, errInvalidFilter) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "search failed: %v", err)
+	}
+
+	resp := &gorillapb.SearchResponse{
+		Results: make([]*gorillapb.ScoredPoint, len(results)),
+		Count:   int32(len(results)),
+	}
+	for i, r := range results {
+		payload, err := structpb.NewStruct(r.Payload)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode payload: %v", err)
+		}
+		resp.Results[i] = &gorillapb.ScoredPoint{
+			Id:      r.ID,
+			Score:   r.Score,
+			Payload: payload,
+		}
+	}
+	return resp, nil
+}
+
+func (vectorServer) ListCollections(ctx context.Context, req *gorillapb.ListCollectionsRequest) (*gorillapb.ListCollectionsResponse, error) {
+	return &gorillapb.ListCollectionsResponse{Collections: knownCollections}, nil
+}
@@ -3,21 +3,39 @@ package main
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 
 	qdrant "github.com/qdrant/go-client/qdrant"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
+	"protos/gorillapb"
+	"shared/auth"
+	"shared/faults"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/rpc"
+	"shared/server"
+	"shared/tenant"
+	"shared/tlsconfig"
+	"shared/tracing"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
+
 type UpsertRequest struct {
 	Collection string                   `json:"collection"`
 	Points     []map[string]interface{} `json:"points"`
@@ -27,7 +45,7 @@ type SearchRequest struct {
 	Collection string                 `json:"collection"`
 	Query      []float32              `json:"query"`
 	TopK       int                    `json:"top_k"`
-	Filter     map[string]interface{} `json:"filter,omitempty"`
+	Filter     map[string]interface{} `json:"filter,omitempty"` // payload conditions, see filter.go
 }
 
 type SearchResult struct {
@@ -41,6 +59,35 @@ type SearchResponse struct {
 	Count   int            `json:"count"`
 }
 
+// ScrollRequest pages through every point of a collection, or those
+// matching filter, for indexes built outside Qdrant such as the retrieval
+// service's keyword index and for fetching a document's chunks by position.
+type ScrollRequest struct {
+	Collection string                 `json:"collection"`
+	Limit      int                    `json:"limit"`
+	Offset     string                 `json:"offset,omitempty"` // next_offset of the previous page
+	Filter     map[string]interface{} `json:"filter,omitempty"` // payload conditions, as for search, see filter.go
+}
+
+type ScrollResponse struct {
+	Points     []SearchResult `json:"points"` // score is always 0
+	NextOffset string         `json:"next_offset,omitempty"`
+}
+
+// CollectionStats reports the size and health of a collection.
+type CollectionStats struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	PointsCount  uint64 `json:"points_count"`
+	VectorsCount uint64 `json:"vectors_count"`
+}
+
+// tenantField is the payload key holding the owning tenant of a point.
+const tenantField = "tenant_id"
+
+// knownCollections are the collections created at startup.
+var knownCollections = []string{"regulatory_docs", "merchant_docs", "kyc_docs"}
+
 var (
 	collectionsClient qdrant.CollectionsClient
 	pointsClient      qdrant.PointsClient
@@ -51,11 +98,29 @@ var (
 )
 
 func main() {
+	shutdownTracing, err := tracing.Init("vector-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	qdrantAddr := getEnv("QDRANT_ADDRESS", "localhost:6334")
 
+	creds := insecure.NewCredentials()
+	if getEnv("QDRANT_TLS", "false") == "true" {
+		tlsCfg, err := tlsconfig.ClientConfig()
+		if err != nil {
+			log.Fatalf("Failed to load TLS client config: %v", err)
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	}
+
 	clientOnce.Do(func() {
 		var err error
-		grpcConn, err = grpc.DialContext(ctx, qdrantAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		grpcConn, err = grpc.DialContext(ctx, qdrantAddr,
+			grpc.WithTransportCredentials(creds),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		)
 		if err != nil {
 			log.Fatalf("Failed to connect to Qdrant: %v", err)
 		}
@@ -67,29 +132,77 @@ func main() {
 	log.Printf("Connected to Qdrant at %s", qdrantAddr)
 	initializeCollections()
 
+	spec := openapi.MustLoad(openAPISpec)
+	spec.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/healthz", server.LivenessHandler("vector-service"))
+	http.HandleFunc("/readyz", server.ReadinessHandler("vector-service", map[string]server.Check{
+		"qdrant": func(ctx context.Context) error {
+			_, err := systemClient.HealthCheck(ctx, &qdrant.HealthCheckRequest{})
+			return err
+		},
+	}))
 	http.HandleFunc("/upsert", upsertHandler)
 	http.HandleFunc("/search", searchHandler)
+	http.HandleFunc("/scroll", scrollHandler)
+	http.HandleFunc("/delete", deleteHandler)
 	http.HandleFunc("/collections", collectionsHandler)
 
+	grpcServer, err := rpc.NewServer()
+	if err != nil {
+		log.Fatalf("Failed to create gRPC server: %v", err)
+	}
+	gorillapb.RegisterVectorServiceServer(grpcServer, vectorServer{})
+	rpc.Serve(grpcServer, ":"+getEnv("GRPC_PORT", "50052"))
+
 	port := getEnv("PORT", "8082")
 	log.Printf("Vector Service starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	handler := spec.Validate(http.DefaultServeMux)
+	handler = faults.Wrap("vector-service", handler)
+	handler = metrics.Wrap("vector-service", http.DefaultServeMux, handler)
+	handler = tenant.Middleware(handler)
+	handler = auth.Wrap([]auth.Rule{
+		{Method: http.MethodPost, Path: "/upsert", Roles: auth.Privileged},
+		{Method: http.MethodPost, Path: "/delete", Roles: auth.Privileged},
+	}, handler)
+	handler = tracing.Wrap(http.DefaultServeMux, handler)
+	if err := server.ListenAndServe(":"+port, handler); err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer.GracefulStop()
+
+	grpcConn.Close()
+}
+
+// collectionSpec is a collection created at startup if missing.
+type collectionSpec struct {
+	name string
+	size uint64
 }
 
 func initializeCollections() {
-	collections := []struct {
-		name string
-		size uint64
-	}{
+	collections := []collectionSpec{
 		{"regulatory_docs", 768},
 		{"merchant_docs", 768},
 		{"kyc_docs", 768},
 	}
 
+	// Shadow collections hold the same chunks embedded with a candidate
+	// model for the retrieval canary; the candidate may use another dimension.
+	if size, err := strconv.ParseUint(os.Getenv("CANARY_VECTOR_SIZE"), 10, 64); err == nil && size > 0 {
+		suffix := getEnv("CANARY_SHADOW_SUFFIX", "_canary")
+		for _, coll := range collections {
+			collections = append(collections, collectionSpec{coll.name + suffix, size})
+		}
+	}
+
 	for _, coll := range collections {
 		_, err := collectionsClient.Get(ctx, &qdrant.GetCollectionInfoRequest{CollectionName: coll.name})
 		if err == nil {
+			ensurePayloadIndexes(coll.name)
 			continue
 		}
 
@@ -114,6 +227,7 @@ func initializeCollections() {
 			log.Printf("Failed to create collection %s: %v", coll.name, err)
 		} else {
 			log.Printf("Collection %s created successfully", coll.name)
+			ensurePayloadIndexes(coll.name)
 		}
 	}
 }
@@ -141,9 +255,24 @@ func collectionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collections := []string{"regulatory_docs", "merchant_docs", "kyc_docs"}
+	stats := make([]CollectionStats, 0, len(knownCollections))
+	for _, name := range knownCollections {
+		info, err := collectionsClient.Get(r.Context(), &qdrant.GetCollectionInfoRequest{CollectionName: name})
+		if err != nil {
+			stats = append(stats, CollectionStats{Name: name, Status: "unavailable"})
+			continue
+		}
+		result := info.GetResult()
+		stats = append(stats, CollectionStats{
+			Name:         name,
+			Status:       strings.ToLower(result.GetStatus().String()),
+			PointsCount:  result.GetPointsCount(),
+			VectorsCount: result.GetVectorsCount(),
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"collections": collections})
+	json.NewEncoder(w).Encode(map[string]interface{}{"collections": knownCollections, "stats": stats})
 }
 
 func upsertHandler(w http.ResponseWriter, r *http.Request) {
@@ -205,13 +334,7 @@ func upsertHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	wait := true
-	_, err := pointsClient.Upsert(ctx, &qdrant.UpsertPoints{
-		CollectionName: req.Collection,
-		Points:         qdrantPoints,
-		Wait:           &wait,
-	})
-	if err != nil {
+	if err := upsertPoints(r.Context(), req.Collection, qdrantPoints); err != nil {
 		respondError(w, "Failed to upsert: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -236,12 +359,86 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	results, err := searchPoints(r.Context(), req)
+	if errors.Is(err, errInvalidFilter) {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		respondError(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := SearchResponse{Results: results, Count: len(results)}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func scrollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ScrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Collection == "" {
+		respondError(w, "Collection name required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := scrollPoints(r.Context(), req)
+	if errors.Is(err, errInvalidFilter) {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		respondError(w, "Scroll failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// upsertPoints writes points to Qdrant and waits for the write to be applied.
+// Every point is tagged with the caller's tenant, overriding any tenant_id
+// the client sent, so one tenant cannot write into another's data.
+func upsertPoints(ctx context.Context, collection string, points []*qdrant.PointStruct) error {
+	tenantValue := toQdrantValue(tenant.FromContext(ctx))
+	for _, p := range points {
+		if p.Payload == nil {
+			p.Payload = make(map[string]*qdrant.Value)
+		}
+		p.Payload[tenantField] = tenantValue
+	}
+
+	wait := true
+	_, err := pointsClient.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: collection,
+		Points:         points,
+		Wait:           &wait,
+	})
+	return err
+}
+
+// searchPoints runs a nearest-neighbour query and converts hits to
+// SearchResults with plain Go payloads.
+func searchPoints(ctx context.Context, req SearchRequest) ([]SearchResult, error) {
 	if req.TopK == 0 {
 		req.TopK = 5
 	}
 
 	log.Printf("Searching in collection: %s, TopK: %d", req.Collection, req.TopK)
 
+	filter, err := searchFilter(tenant.FromContext(ctx), req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
 	withPayload := &qdrant.WithPayloadSelector{
 		SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
 	}
@@ -250,11 +447,11 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		CollectionName: req.Collection,
 		Vector:         req.Query,
 		Limit:          uint64(req.TopK),
+		Filter:         filter,
 		WithPayload:    withPayload,
 	})
 	if err != nil {
-		respondError(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	points := searchResults.GetResult()
@@ -272,9 +469,76 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response := SearchResponse{Results: results, Count: len(results)}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return results, nil
+}
+
+// maxScrollLimit bounds one page of a scroll.
+const maxScrollLimit = 1000
+
+// scrollPoints returns one page of the caller's points in ID order, with
+// their payloads but not their vectors.
+func scrollPoints(ctx context.Context, req ScrollRequest) (*ScrollResponse, error) {
+	if req.Limit <= 0 || req.Limit > maxScrollLimit {
+		req.Limit = maxScrollLimit
+	}
+	limit := uint32(req.Limit)
+	filter, err := searchFilter(tenant.FromContext(ctx), req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	scroll := &qdrant.ScrollPoints{
+		CollectionName: req.Collection,
+		Filter:         filter,
+		Limit:          &limit,
+		WithPayload: &qdrant.WithPayloadSelector{
+			SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
+		},
+	}
+	if req.Offset != "" {
+		scroll.Offset = &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: req.Offset}}
+	}
+
+	reply, err := pointsClient.Scroll(ctx, scroll)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ScrollResponse{Points: make([]SearchResult, len(reply.GetResult()))}
+	for i, point := range reply.GetResult() {
+		payload := make(map[string]interface{})
+		for key, val := range point.GetPayload() {
+			payload[key] = fromQdrantValue(val)
+		}
+		response.Points[i] = SearchResult{ID: pointIDToString(point.GetId()), Payload: payload}
+	}
+	if next := reply.GetNextPageOffset(); next != nil {
+		response.NextOffset = pointIDToString(next)
+	}
+	return response, nil
+}
+
+// tenantFilter restricts a search to points owned by id. Points written
+// before multi-tenancy carry no tenant_id and belong to the default tenant.
+func tenantFilter(id string) *qdrant.Filter {
+	match := &qdrant.Condition{
+		ConditionOneOf: &qdrant.Condition_Field{
+			Field: &qdrant.FieldCondition{
+				Key:   tenantField,
+				Match: &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: id}},
+			},
+		},
+	}
+	if id != tenant.Default {
+		return &qdrant.Filter{Must: []*qdrant.Condition{match}}
+	}
+
+	untagged := &qdrant.Condition{
+		ConditionOneOf: &qdrant.Condition_IsEmpty{
+			IsEmpty: &qdrant.IsEmptyCondition{Key: tenantField},
+		},
+	}
+	return &qdrant.Filter{Should: []*qdrant.Condition{match, untagged}}
 }
 
 func toQdrantValue(val interface{}) *qdrant.Value {
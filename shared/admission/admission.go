@@ -0,0 +1,203 @@
+// Package admission bounds how much expensive work a service accepts at
+// once. A Gate lets a fixed number of requests run a handler concurrently,
+// queues a bounded number behind them for at most MaxWait, and turns the
+// rest away early with 503 (or Config.Status) and Retry-After instead of
+// piling up goroutines against Gemini and Qdrant. Work that doesn't arrive as
+// a request, such as a background job, takes a slot with Wait.
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "admission_queue_depth",
+		Help: "Requests waiting for a worker slot.",
+	}, []string{"service", "gate"})
+
+	running = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "admission_running",
+		Help: "Requests currently holding a worker slot.",
+	}, []string{"service", "gate"})
+
+	waitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "admission_wait_seconds",
+		Help:    "Time admitted requests spent queued before running.",
+		Buckets: []float64{.001, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"service", "gate"})
+
+	rejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "admission_rejections_total",
+		Help: "Requests shed by admission control, by reason (queue_full, timeout).",
+	}, []string{"service", "gate", "reason"})
+)
+
+// Config sizes a Gate.
+type Config struct {
+	// Workers is how many requests may run at once.
+	Workers int
+	// QueueDepth is how many more may wait for a worker. 0 means none.
+	QueueDepth int
+	// MaxWait is how long a queued request waits before being shed.
+	MaxWait time.Duration
+	// Status is the HTTP status shed requests get. 0 means 503.
+	Status int
+}
+
+// ConfigFromEnv overrides def with <prefix>_WORKERS, <prefix>_QUEUE_DEPTH
+// and <prefix>_MAX_WAIT (a Go duration) when they are set.
+func ConfigFromEnv(prefix string, def Config) Config {
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_WORKERS")); err == nil && v > 0 {
+		def.Workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(prefix + "_QUEUE_DEPTH")); err == nil && v >= 0 {
+		def.QueueDepth = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(prefix + "_MAX_WAIT")); err == nil && v > 0 {
+		def.MaxWait = v
+	}
+	return def
+}
+
+// Gate is a bounded worker pool with a bounded wait queue.
+type Gate struct {
+	cfg   Config
+	slots chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+
+	depth   prometheus.Gauge
+	running prometheus.Gauge
+	wait    prometheus.Observer
+	full    prometheus.Counter
+	timeout prometheus.Counter
+}
+
+// New returns a Gate named name for service's metrics.
+func New(service, name string, cfg Config) *Gate {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = 10 * time.Second
+	}
+	if cfg.Status == 0 {
+		cfg.Status = http.StatusServiceUnavailable
+	}
+	return &Gate{
+		cfg:     cfg,
+		slots:   make(chan struct{}, cfg.Workers),
+		depth:   queueDepth.WithLabelValues(service, name),
+		running: running.WithLabelValues(service, name),
+		wait:    waitSeconds.WithLabelValues(service, name),
+		full:    rejections.WithLabelValues(service, name, "queue_full"),
+		timeout: rejections.WithLabelValues(service, name, "timeout"),
+	}
+}
+
+// Wrap admits requests to next through the gate.
+func (g *Gate) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		admitted, reason := g.acquire(r)
+		if !admitted {
+			if reason != "" {
+				g.reject(w, reason)
+			}
+			return
+		}
+		g.wait.Observe(time.Since(start).Seconds())
+		g.running.Inc()
+		defer func() {
+			g.running.Dec()
+			<-g.slots
+		}()
+
+		next(w, r)
+	}
+}
+
+// Wait takes a worker slot for work outside a request, waiting as long as
+// ctx allows; it isn't bounded by QueueDepth or MaxWait, since the caller
+// has its own queue. The returned func gives the slot back.
+func (g *Gate) Wait(ctx context.Context) (func(), error) {
+	start := time.Now()
+	select {
+	case g.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	g.wait.Observe(time.Since(start).Seconds())
+	g.running.Inc()
+	return func() {
+		g.running.Dec()
+		<-g.slots
+	}, nil
+}
+
+// acquire takes a worker slot, queueing if allowed. It returns the reason
+// for shedding when not admitted, or "" if the client went away.
+func (g *Gate) acquire(r *http.Request) (bool, string) {
+	select {
+	case g.slots <- struct{}{}:
+		return true, ""
+	default:
+	}
+
+	g.mu.Lock()
+	if g.waiting >= g.cfg.QueueDepth {
+		g.mu.Unlock()
+		g.full.Inc()
+		return false, "queue_full"
+	}
+	g.waiting++
+	g.depth.Inc()
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		g.waiting--
+		g.depth.Dec()
+		g.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(g.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case g.slots <- struct{}{}:
+		return true, ""
+	case <-timer.C:
+		g.timeout.Inc()
+		return false, "timeout"
+	case <-r.Context().Done():
+		return false, ""
+	}
+}
+
+func (g *Gate) reject(w http.ResponseWriter, reason string) {
+	retry := int(math.Ceil(g.cfg.MaxWait.Seconds()))
+	if retry < 1 {
+		retry = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(g.cfg.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "server busy, try again later",
+		"reason":      reason,
+		"retry_after": retry,
+	})
+}
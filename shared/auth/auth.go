@@ -0,0 +1,209 @@
+// Package auth verifies the short-lived JWTs issued by auth-service and
+// enforces role-based access in every service. Tokens are HS256-signed with
+// the shared JWT_SECRET; when it is unset authentication is disabled and
+// services behave exactly as before.
+//
+// Callers present tokens as "Authorization: Bearer <jwt>" (or an
+// access_token cookie for browser UIs). Service-to-service calls carry a
+// token minted by the calling service with the "service" role.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role is a coarse permission level carried in the token.
+type Role string
+
+const (
+	Admin    Role = "admin"
+	Analyst  Role = "analyst"
+	ReadOnly Role = "read-only"
+	Service  Role = "service"
+)
+
+// Role sets used by service access rules.
+var (
+	// Readers may query agents, search and read documents.
+	Readers = []Role{Admin, Analyst, ReadOnly, Service}
+	// Writers may additionally ingest documents and call tools.
+	Writers = []Role{Admin, Analyst, Service}
+	// Privileged may delete data and write to internal stores directly.
+	Privileged = []Role{Admin, Service}
+)
+
+// Issuer is the iss claim of every token.
+const Issuer = "gorilla-auth"
+
+var (
+	secret = []byte(os.Getenv("JWT_SECRET"))
+
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrForbidden    = errors.New("insufficient role")
+)
+
+// Claims are the custom JWT claims. An empty Tenant means the token may act
+// for any tenant (admin and service tokens).
+type Claims struct {
+	Role   Role   `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Enabled reports whether JWT_SECRET is configured.
+func Enabled() bool {
+	return len(secret) > 0
+}
+
+// Issue signs a token for subject with the given role, tenant and lifetime.
+func Issue(subject string, role Role, tenant string, ttl time.Duration) (string, time.Time, error) {
+	if !Enabled() {
+		return "", time.Time{}, errors.New("JWT_SECRET is not set")
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Role:   role,
+		Tenant: tenant,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	})
+	signed, err := token.SignedString(secret)
+	return signed, expires, err
+}
+
+// Verify parses and validates a signed token.
+func Verify(raw string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Allows reports whether the claims' role is one of roles.
+func (c *Claims) Allows(roles []Role) bool {
+	for _, r := range roles {
+		if c.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// FromContext returns the verified claims of the current request, if any.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(contextKey{}).(*Claims)
+	return c, ok
+}
+
+// Rule restricts a route to a set of roles. Path is an exact path, or a
+// prefix when it ends in "/". An empty Method matches every method.
+type Rule struct {
+	Method string
+	Path   string
+	Roles  []Role
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	if strings.HasSuffix(r.Path, "/") {
+		return strings.HasPrefix(req.URL.Path, r.Path)
+	}
+	return req.URL.Path == r.Path
+}
+
+// Wrap authenticates every request to next and checks it against rules; the
+// first matching rule wins and unmatched routes are open to all Readers.
+// Probe, metrics and docs endpoints are exempt. Tokens bound to a tenant
+// set X-Tenant-ID, and requests for a different tenant are rejected, so Wrap
+// must run outside tenant.Middleware.
+func Wrap(rules []Rule, next http.Handler) http.Handler {
+	if !Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/healthz", "/readyz", "/metrics", "/openapi.json", "/docs":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := bearerToken(r)
+		if raw == "" {
+			respondError(w, ErrMissingToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		claims, err := Verify(raw)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		roles := Readers
+		for _, rule := range rules {
+			if rule.matches(r) {
+				roles = rule.Roles
+				break
+			}
+		}
+		if !claims.Allows(roles) {
+			respondError(w, ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+
+		if claims.Tenant != "" {
+			switch r.Header.Get("X-Tenant-ID") {
+			case "":
+				r.Header.Set("X-Tenant-ID", claims.Tenant)
+			case claims.Tenant:
+			default:
+				respondError(w, "token is not valid for this tenant", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, claims)))
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if c, err := r.Cookie("access_token"); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
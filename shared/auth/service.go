@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// serviceTokenTTL is the lifetime of self-minted service tokens; they are
+// renewed a minute before expiry.
+const serviceTokenTTL = 10 * time.Minute
+
+var (
+	serviceName = "service"
+
+	tokenMu      sync.Mutex
+	cachedToken  string
+	tokenExpires time.Time
+)
+
+// ServiceToken returns a cached "service" role token for this process.
+func ServiceToken() (string, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if cachedToken != "" && time.Until(tokenExpires) > time.Minute {
+		return cachedToken, nil
+	}
+	token, expires, err := Issue(serviceName, Service, "", serviceTokenTTL)
+	if err != nil {
+		return "", err
+	}
+	cachedToken, tokenExpires = token, expires
+	return token, nil
+}
+
+// ConfigureDefaultTransport names this process's service identity and wraps
+// http.DefaultTransport so requests to the given upstream base URLs carry a
+// service token. Other hosts (e.g. the Gemini API) never see it, and
+// requests that already set Authorization are left alone. No-op when
+// authentication is disabled.
+func ConfigureDefaultTransport(service string, upstreams ...string) {
+	serviceName = service
+	if !Enabled() {
+		return
+	}
+
+	hosts := make(map[string]bool)
+	for _, raw := range upstreams {
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			hosts[u.Host] = true
+		}
+	}
+	http.DefaultTransport = &transport{base: http.DefaultTransport, hosts: hosts}
+}
+
+type transport struct {
+	base  http.RoundTripper
+	hosts map[string]bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hosts[req.URL.Host] || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	token, err := ServiceToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// UnaryServerInterceptor authenticates gRPC calls. The gRPC contracts are
+// internal, so only service and admin tokens are accepted.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !Enabled() {
+			return handler(ctx, req)
+		}
+
+		var raw string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				raw = strings.TrimPrefix(values[0], "Bearer ")
+			}
+		}
+		if raw == "" {
+			return nil, status.Error(codes.Unauthenticated, ErrMissingToken.Error())
+		}
+		claims, err := Verify(raw)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if !claims.Allows(Privileged) {
+			return nil, status.Error(codes.PermissionDenied, ErrForbidden.Error())
+		}
+		return handler(context.WithValue(ctx, contextKey{}, claims), req)
+	}
+}
+
+// UnaryClientInterceptor attaches this process's service token to gRPC calls.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if Enabled() {
+			token, err := ServiceToken()
+			if err != nil {
+				return status.Error(codes.Unauthenticated, err.Error())
+			}
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,798 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentRequest is the body of POST /agent/query, /agent/query/stream,
+// /agent/jobs and /agent/plan.
+type AgentRequest struct {
+	Query          string            `json:"query"`
+	ConversationID string            `json:"conversation_id,omitempty"`
+	MaxIterations  int               `json:"max_iterations,omitempty"`
+	Context        map[string]string `json:"context,omitempty"`
+	// Mode is "pipeline" (the default); "react", where the model decides
+	// which action to take next until it gives a final answer; or
+	// "multi_agent", where a critic agent reviews each answer and can send
+	// a researcher agent back for more evidence.
+	Mode string `json:"mode,omitempty"`
+	// CallbackURL, when set, is POSTed a signed CallbackPayload once the
+	// agent finishes; see VerifyCallback.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// DryRun plans the query without executing it; the response carries
+	// DryRun, with an estimate of the run's cost, instead of an answer.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Candidates, from 2 to 5, writes that many answers and has a judge
+	// pick or merge the best; AgentResponse.Agreement reports how far they
+	// agreed.
+	Candidates int `json:"candidates,omitempty"`
+	// ResponseSchema, a JSON Schema, asks for the answer as JSON matching
+	// it; the validated JSON is returned in AgentResponse.Output.
+	ResponseSchema map[string]interface{} `json:"response_schema,omitempty"`
+	// FollowUpAnswer answers the FollowUpQ of the conversation's last run
+	// and resumes that run, keeping the evidence it had gathered. It needs
+	// ConversationID; Query may be left empty.
+	FollowUpAnswer string `json:"follow_up_answer,omitempty"`
+	// Persona, a system prompt such as "RBI compliance officer tone, always
+	// cite circular numbers", is stored with the conversation and applies
+	// to its later turns too; see SetPersona.
+	Persona string `json:"persona,omitempty"`
+	// UserID gives the user a memory across conversations: facts they
+	// reveal are remembered and planned with; see UserMemory.
+	UserID string `json:"user_id,omitempty"`
+	// ConfidenceThreshold, from 0 to 1, is the confidence an answer needs
+	// to be final; Rerank turns keyword reranking of search results on or
+	// off. Unset, the orchestrator's settings apply; see AgentClient.Config.
+	ConfidenceThreshold float64 `json:"confidence_threshold,omitempty"`
+	Rerank              *bool   `json:"rerank,omitempty"`
+	// PartialResults answers with what was gathered, with Degraded set,
+	// when planning, an action or synthesis fails, instead of an error.
+	PartialResults *bool `json:"partial_results,omitempty"`
+	// Route is "fast" to answer with one search and synthesis, "full" to
+	// run the mode's loop, or "auto" to send simple factual questions down
+	// the fast path.
+	Route string `json:"route,omitempty"`
+	// Clarify checks the query for ambiguity before planning; an ambiguous
+	// one is answered at once with NeedMoreInfo and FollowUpQ, which a
+	// FollowUpAnswer resumes.
+	Clarify *bool `json:"clarify,omitempty"`
+
+	// MaxLLMCalls, MaxTokens and DeadlineMs cap what the request may spend;
+	// 0 is unlimited. When one runs out the agent returns its best answer
+	// with BudgetExceeded set.
+	MaxLLMCalls int `json:"max_llm_calls,omitempty"`
+	MaxTokens   int `json:"max_tokens,omitempty"`
+	DeadlineMs  int `json:"deadline_ms,omitempty"`
+
+	// Model selects the Gemini model for every step; the per-step fields
+	// override it. Empty uses the orchestrator's default.
+	Model          string `json:"model,omitempty"`
+	AnalysisModel  string `json:"analysis_model,omitempty"`
+	PlannerModel   string `json:"planner_model,omitempty"`
+	SynthesisModel string `json:"synthesis_model,omitempty"`
+	VerifierModel  string `json:"verifier_model,omitempty"`
+}
+
+// AgentResponse is the agent's final answer and reasoning trace.
+type AgentResponse struct {
+	ConversationID string      `json:"conversation_id"`
+	Query          string      `json:"query"`
+	Answer         string      `json:"answer"`
+	Confidence     float64     `json:"confidence"`
+	Iterations     int         `json:"iterations"`
+	ToolsUsed      []string    `json:"tools_used"`
+	Sources        []Source    `json:"sources"`
+	Citations      []Citation  `json:"citations"`
+	ProcessTime    float64     `json:"process_time_ms"`
+	Steps          []AgentStep `json:"steps"`
+	StepGroups     []StepGroup `json:"step_groups"` // Steps by iteration
+	NeedMoreInfo   bool        `json:"need_more_info"`
+	FollowUpQ      string      `json:"follow_up_question,omitempty"`
+	BudgetExceeded bool        `json:"budget_exceeded"`
+	LLMCalls       int         `json:"llm_calls"`
+	TokensUsed     int64       `json:"tokens_used"`
+	Cached         bool        `json:"cached"`              // answered from the answer cache
+	Degraded       bool        `json:"degraded"`            // answered from partial results after a failure
+	Agreement      float64     `json:"agreement,omitempty"` // between candidate answers
+	Route          string      `json:"route,omitempty"`     // "fast" or "full", the path that answered
+	DryRun         *DryRun     `json:"dry_run,omitempty"`
+	TraceID        string      `json:"trace_id,omitempty"` // OpenTelemetry trace of the request
+	// Experiment names the A/B experiment variant that answered, nil when
+	// the request was in none
+	Experiment *VariantTag `json:"experiment,omitempty"`
+	// Grounding is the share of the answer's claims the gathered evidence
+	// supports; nil when the answer was not fact-checked
+	Grounding         *float64 `json:"grounding,omitempty"`
+	UnsupportedClaims []string `json:"unsupported_claims,omitempty"`
+	// Output is the answer as JSON when ResponseSchema was given and the
+	// answer matched it; otherwise OutputError says why not.
+	Output      json.RawMessage `json:"output,omitempty"`
+	OutputError string          `json:"output_error,omitempty"`
+}
+
+// VariantTag names an experiment and one of its variants.
+type VariantTag struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+}
+
+// DryRun is what a dry_run request would do.
+type DryRun struct {
+	Plan     *ExecutionPlan `json:"plan"`
+	Searches int            `json:"searches"`
+	Tools    []string       `json:"tools"`
+	Estimate Estimate       `json:"estimate"`
+}
+
+// Estimate is the rough cost of running a plan: the plain fields for one
+// iteration, the Max fields for every iteration the request allows. Cost is
+// zero unless the orchestrator has a token price configured.
+type Estimate struct {
+	LLMCalls     int     `json:"llm_calls"`
+	MaxLLMCalls  int     `json:"max_llm_calls"`
+	Tokens       int64   `json:"tokens"`
+	MaxTokens    int64   `json:"max_tokens"`
+	LatencyMs    float64 `json:"latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+	CostUSD      float64 `json:"cost_usd,omitempty"`
+	MaxCostUSD   float64 `json:"max_cost_usd,omitempty"`
+}
+
+// Source is a retrieved chunk the agent read while answering.
+type Source struct {
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	ChunkID      string  `json:"chunk_id"`
+	Collection   string  `json:"collection,omitempty"`
+	Score        float64 `json:"score"`
+}
+
+// Citation maps an inline [n] marker in an answer to the retrieved chunk it
+// cites.
+type Citation struct {
+	Marker int `json:"marker"`
+	Source
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// AgentStep is one step of the agentic loop.
+type AgentStep struct {
+	StepNumber  int     `json:"step_number"`
+	Type        string  `json:"type"`
+	Description string  `json:"description"`
+	Action      string  `json:"action,omitempty"`
+	Result      string  `json:"result,omitempty"`
+	Success     bool    `json:"success"`
+	Duration    float64 `json:"duration_ms"`
+	Model       string  `json:"model,omitempty"`     // Gemini model used, after any fallback
+	Cache       string  `json:"cache,omitempty"`     // "hit" or "miss" when the step consulted a cache
+	Iteration   int     `json:"iteration,omitempty"` // iteration, ReAct turn or multi_agent round; 0 outside them
+}
+
+// StepGroup is the steps of one iteration.
+type StepGroup struct {
+	Iteration int     `json:"iteration"` // 0 for the steps outside any iteration
+	Steps     []int   `json:"steps"`     // step numbers, in order
+	Success   bool    `json:"success"`   // every step succeeded
+	Duration  float64 `json:"duration_ms"`
+}
+
+// ExecutionPlan is the agent's plan returned by /agent/plan.
+type ExecutionPlan struct {
+	OriginalQuery    string   `json:"original_query"`
+	RewrittenQueries []string `json:"rewritten_queries"`
+	Actions          []Action `json:"actions"`
+	Reasoning        string   `json:"reasoning"`
+	Model            string   `json:"model,omitempty"`
+}
+
+// Action is a single planned action.
+type Action struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// Conversation is a stored conversation from /agent/history/{id}.
+type Conversation struct {
+	ID        string
+	Messages  []Message
+	StartTime time.Time
+	Persona   string
+}
+
+// MemoryFact is something remembered about a user.
+type MemoryFact struct {
+	Fact           string    `json:"fact"`
+	ConversationID string    `json:"conversation_id,omitempty"` // where it was learned
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Message is a single conversation turn. Assistant turns carry the steps
+// that produced the answer, the tools used and the citations.
+type Message struct {
+	Role      string      `json:"role"`
+	Content   string      `json:"content"`
+	Timestamp time.Time   `json:"timestamp"`
+	Steps     []AgentStep `json:"steps,omitempty"`
+	ToolsUsed []string    `json:"tools_used,omitempty"`
+	Citations []Citation  `json:"citations,omitempty"`
+}
+
+// Transcript is a conversation as /agent/history/{id}/export returns it.
+type Transcript struct {
+	ConversationID string           `json:"conversation_id"`
+	TenantID       string           `json:"tenant_id"`
+	Persona        string           `json:"persona,omitempty"`
+	StartTime      time.Time        `json:"start_time"`
+	ExportedAt     time.Time        `json:"exported_at"`
+	Turns          []TranscriptTurn `json:"turns"`
+}
+
+// TranscriptTurn is a question and the agent's answer to it.
+type TranscriptTurn struct {
+	Number     int         `json:"number"`
+	Query      string      `json:"query"`
+	AskedAt    time.Time   `json:"asked_at"`
+	Answer     string      `json:"answer"`
+	AnsweredAt time.Time   `json:"answered_at"`
+	ToolsUsed  []string    `json:"tools_used"`
+	Citations  []Citation  `json:"citations"`
+	Steps      []AgentStep `json:"steps"`
+}
+
+// ConversationSummary is a listing entry from /agent/conversations.
+type ConversationSummary struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Turns     int       `json:"turns"`
+	LastQuery string    `json:"last_query"`
+}
+
+// AgentClient talks to the agent orchestrator.
+type AgentClient struct {
+	baseURL string
+	t       *transport
+}
+
+// Query runs the full agentic loop for req.
+func (c *AgentClient) Query(ctx context.Context, req AgentRequest) (*AgentResponse, error) {
+	var out AgentResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/agent/query", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StreamEvent is one Server-Sent Event from /agent/query/stream. Exactly one
+// of Step, Token or Response is set, according to Type ("step", "token" or
+// "done"); "start" events only carry ConversationID and TraceID.
+type StreamEvent struct {
+	Type           string
+	ConversationID string
+	TraceID        string
+	Step           *AgentStep
+	Token          *StreamToken
+	Response       *AgentResponse
+}
+
+// StreamToken is a chunk of the answer being synthesized. Every iteration
+// of the loop writes a fresh answer, so a new Iteration replaces the text
+// received so far.
+type StreamToken struct {
+	Iteration int    `json:"iteration"`
+	Text      string `json:"text"`
+}
+
+// Stream runs the agentic loop like Query, calling onEvent for each step and
+// answer token as the agent produces them, and returns the final response.
+// An error from onEvent stops reading and is returned. Streams are not
+// retried, and Config.HTTPClient's timeout bounds the whole stream.
+func (c *AgentClient) Stream(ctx context.Context, req AgentRequest, onEvent func(StreamEvent) error) (*AgentResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	resp, err := c.t.send(ctx, http.MethodPost, c.baseURL+"/agent/query/stream", "application/json", "text/event-stream", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decode(resp, nil)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20) // done events carry the full trace
+	var event string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "" && data.Len() == 0 {
+				continue
+			}
+			ev, err := parseStreamEvent(event, data.String())
+			if err != nil {
+				return nil, err
+			}
+			if onEvent != nil {
+				if err := onEvent(ev); err != nil {
+					return nil, err
+				}
+			}
+			if ev.Response != nil {
+				return ev.Response, nil
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// heartbeat comment
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil, fmt.Errorf("stream ended before the agent finished")
+}
+
+func parseStreamEvent(event, data string) (StreamEvent, error) {
+	ev := StreamEvent{Type: event}
+	var err error
+	switch event {
+	case "start":
+		var start struct {
+			ConversationID string `json:"conversation_id"`
+			TraceID        string `json:"trace_id"`
+		}
+		err = json.Unmarshal([]byte(data), &start)
+		ev.ConversationID, ev.TraceID = start.ConversationID, start.TraceID
+	case "step":
+		ev.Step = &AgentStep{}
+		err = json.Unmarshal([]byte(data), ev.Step)
+	case "token":
+		ev.Token = &StreamToken{}
+		err = json.Unmarshal([]byte(data), ev.Token)
+	case "done":
+		ev.Response = &AgentResponse{}
+		err = json.Unmarshal([]byte(data), ev.Response)
+		ev.ConversationID, ev.TraceID = ev.Response.ConversationID, ev.Response.TraceID
+	}
+	if err != nil {
+		return ev, fmt.Errorf("failed to decode %s event: %w", event, err)
+	}
+	return ev, nil
+}
+
+// Plan returns the execution plan for req without running it.
+func (c *AgentClient) Plan(ctx context.Context, req AgentRequest) (*ExecutionPlan, error) {
+	var out ExecutionPlan
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/agent/plan", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExecuteRequest runs a plan, e.g. one from Plan after review, with the
+// options of an AgentRequest. Query defaults to the plan's original query.
+type ExecuteRequest struct {
+	AgentRequest
+	Plan *ExecutionPlan `json:"plan"`
+}
+
+// Execute runs req.Plan through the execute, synthesize and verify phases
+// without re-planning.
+func (c *AgentClient) Execute(ctx context.Context, req ExecuteRequest) (*AgentResponse, error) {
+	var out AgentResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/agent/execute", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// History fetches a conversation by ID.
+func (c *AgentClient) History(ctx context.Context, conversationID string) (*Conversation, error) {
+	var out Conversation
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/history/"+url.PathEscape(conversationID), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Export returns a conversation's complete transcript.
+func (c *AgentClient) Export(ctx context.Context, conversationID string) (*Transcript, error) {
+	var out Transcript
+	if err := c.t.doJSON(ctx, http.MethodGet, c.exportURL(conversationID, "json"), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportMarkdown returns a conversation's complete transcript as Markdown.
+func (c *AgentClient) ExportMarkdown(ctx context.Context, conversationID string) (string, error) {
+	resp, err := c.t.send(ctx, http.MethodGet, c.exportURL(conversationID, "md"), "", "text/markdown", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", decode(resp, nil)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (c *AgentClient) exportURL(conversationID, format string) string {
+	return c.baseURL + "/agent/history/" + url.PathEscape(conversationID) + "/export?format=" + format
+}
+
+// AgentConfig is the orchestrator's settings, as GET /agent/config reports
+// them.
+type AgentConfig struct {
+	Mode                string            `json:"mode"`
+	MaxIterations       int               `json:"max_iterations"`
+	ConfidenceThreshold float64           `json:"confidence_threshold"`
+	Rerank              *bool             `json:"rerank"` // nil leaves it to the retrieval service
+	PartialResults      bool              `json:"partial_results"`
+	Route               string            `json:"route"`
+	Clarify             bool              `json:"clarify"`
+	Reflection          bool              `json:"reflection"`
+	HistoryTurns        int               `json:"history_turns"`
+	ResultTokens        int               `json:"result_tokens"`
+	Prompts             map[string]string `json:"prompts"` // "builtin" or the file each prompt template was read from
+	Models              struct {
+		Model          string   `json:"model"`
+		AnalysisModel  string   `json:"analysis_model"`
+		PlannerModel   string   `json:"planner_model"`
+		SynthesisModel string   `json:"synthesis_model"`
+		VerifierModel  string   `json:"verifier_model"`
+		Fallbacks      []string `json:"fallbacks"`
+		Allowed        []string `json:"allowed"`
+	} `json:"models"`
+}
+
+// Config fetches the settings a request gets when it overrides none.
+func (c *AgentClient) Config(ctx context.Context) (*AgentConfig, error) {
+	var out AgentConfig
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/config", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AgentStats is the tenant's agent usage over a number of days, as seen by
+// one orchestrator replica.
+type AgentStats struct {
+	Days          int    `json:"days"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Queries       int    `json:"queries"`
+	QueriesPerDay []struct {
+		Date    string `json:"date"`
+		Queries int    `json:"queries"`
+	} `json:"queries_per_day"`
+	AvgIterations      float64        `json:"avg_iterations"`
+	AvgConfidence      float64        `json:"avg_confidence"`
+	LLMCalls           int            `json:"llm_calls"`
+	TokensUsed         int64          `json:"tokens_used"`
+	AvgTokensPerQuery  float64        `json:"avg_tokens_per_query"`
+	ErrorRate          float64        `json:"error_rate"`
+	BudgetExceededRate float64        `json:"budget_exceeded_rate"`
+	FollowUpRate       float64        `json:"follow_up_rate"`
+	CacheHitRate       float64        `json:"cache_hit_rate"`
+	Modes              map[string]int `json:"modes"`
+	Actions            map[string]struct {
+		Calls     int     `json:"calls"`
+		Failures  int     `json:"failures"`
+		ErrorRate float64 `json:"error_rate"`
+	} `json:"actions"` // by action type, or tool name for call_tool
+}
+
+// Stats fetches the agent's usage over the last days days; days <= 0 uses
+// the server default.
+func (c *AgentClient) Stats(ctx context.Context, days int) (*AgentStats, error) {
+	u := c.baseURL + "/agent/stats"
+	if days > 0 {
+		u += "?days=" + strconv.Itoa(days)
+	}
+
+	var out AgentStats
+	if err := c.t.doJSON(ctx, http.MethodGet, u, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExperimentReport is the running A/B experiment and how each variant has
+// done on this replica.
+type ExperimentReport struct {
+	Experiment string `json:"experiment"` // empty when none is running
+	Variants   []struct {
+		Name           string            `json:"name"`
+		Weight         float64           `json:"weight"`
+		Model          string            `json:"model,omitempty"`
+		AnalysisModel  string            `json:"analysis_model,omitempty"`
+		PlannerModel   string            `json:"planner_model,omitempty"`
+		SynthesisModel string            `json:"synthesis_model,omitempty"`
+		VerifierModel  string            `json:"verifier_model,omitempty"`
+		Prompts        map[string]string `json:"prompts,omitempty"`
+		Runs           int               `json:"runs"`
+		AvgConfidence  float64           `json:"avg_confidence"`
+		AvgLatencyMs   float64           `json:"avg_latency_ms"`
+		FeedbackUp     int               `json:"feedback_up"`
+		FeedbackDown   int               `json:"feedback_down"`
+		PositiveRate   float64           `json:"positive_rate"`
+	} `json:"variants"`
+}
+
+// Experiments fetches the running experiment's per-variant results.
+func (c *AgentClient) Experiments(ctx context.Context) (*ExperimentReport, error) {
+	var out ExperimentReport
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/experiments", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Feedback rates the answers of a conversation, "up" or "down", crediting
+// its experiment variant. It returns the variant, nil when no experiment
+// is running.
+func (c *AgentClient) Feedback(ctx context.Context, conversationID, rating string) (*VariantTag, error) {
+	in := map[string]string{"conversation_id": conversationID, "rating": rating}
+	var out struct {
+		Experiment *VariantTag `json:"experiment"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/agent/feedback", in, &out); err != nil {
+		return nil, err
+	}
+	return out.Experiment, nil
+}
+
+// AuditRecord is one agent run as recorded in the orchestrator's audit log.
+type AuditRecord struct {
+	ID             string          `json:"id"`
+	TenantID       string          `json:"tenant_id"`
+	ConversationID string          `json:"conversation_id"`
+	UserID         string          `json:"user_id,omitempty"`
+	TraceID        string          `json:"trace_id,omitempty"`
+	Mode           string          `json:"mode"`
+	Request        AgentRequest    `json:"request"`
+	Response       AgentResponse   `json:"response"`
+	Plans          []ExecutionPlan `json:"plans"`
+	Actions        []struct {
+		Action   Action                 `json:"action"`
+		Result   map[string]interface{} `json:"result"` // as the model saw it
+		Duration float64                `json:"duration_ms"`
+	} `json:"actions"`
+	ModelOutputs []struct {
+		Model         string `json:"model"`
+		Text          string `json:"text,omitempty"`
+		FunctionCalls []struct {
+			Name string                 `json:"name"`
+			Args map[string]interface{} `json:"args"`
+		} `json:"function_calls,omitempty"`
+	} `json:"model_outputs"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditQuery narrows AuditRecords; zero fields don't filter, and Limit <= 0
+// uses the server default.
+type AuditQuery struct {
+	ConversationID string
+	UserID         string
+	Since          time.Time
+	Limit          int
+}
+
+// AuditRecords lists the tenant's audit records, newest first.
+func (c *AgentClient) AuditRecords(ctx context.Context, query AuditQuery) ([]AuditRecord, error) {
+	q := url.Values{}
+	if query.ConversationID != "" {
+		q.Set("conversation_id", query.ConversationID)
+	}
+	if query.UserID != "" {
+		q.Set("user_id", query.UserID)
+	}
+	if !query.Since.IsZero() {
+		q.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		q.Set("limit", strconv.Itoa(query.Limit))
+	}
+	u := c.baseURL + "/agent/audit"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	var out struct {
+		Records []AuditRecord `json:"records"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, u, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Records, nil
+}
+
+// AuditRecord fetches one audit record.
+func (c *AgentClient) AuditRecord(ctx context.Context, id string) (*AuditRecord, error) {
+	var out AuditRecord
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/audit/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UserMemory returns what is remembered about a user, oldest first.
+func (c *AgentClient) UserMemory(ctx context.Context, userID string) ([]MemoryFact, error) {
+	var out struct {
+		Facts []MemoryFact `json:"facts"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/users/"+url.PathEscape(userID)+"/memory", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Facts, nil
+}
+
+// ForgetUser clears what is remembered about a user.
+func (c *AgentClient) ForgetUser(ctx context.Context, userID string) error {
+	return c.t.doJSON(ctx, http.MethodDelete, c.baseURL+"/agent/users/"+url.PathEscape(userID)+"/memory", nil, nil)
+}
+
+// SetPersona replaces a conversation's persona, creating the conversation
+// if needed; an empty persona clears it.
+func (c *AgentClient) SetPersona(ctx context.Context, conversationID, persona string) error {
+	in := map[string]string{"persona": persona}
+	return c.t.doJSON(ctx, http.MethodPut, c.baseURL+"/agent/conversations/"+url.PathEscape(conversationID)+"/persona", in, nil)
+}
+
+// Conversations lists recent conversations, most recently updated first.
+// limit <= 0 uses the server default.
+func (c *AgentClient) Conversations(ctx context.Context, limit int) ([]ConversationSummary, error) {
+	u := c.baseURL + "/agent/conversations"
+	if limit > 0 {
+		u += "?limit=" + strconv.Itoa(limit)
+	}
+
+	var out struct {
+		Conversations []ConversationSummary `json:"conversations"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, u, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Conversations, nil
+}
+
+// Job states reported by the orchestrator.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job is an agent query running in the background. Steps grows while it
+// runs; Result is set once it has completed and Error once it has failed.
+type Job struct {
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	ConversationID string         `json:"conversation_id"`
+	Query          string         `json:"query"`
+	CreatedAt      time.Time      `json:"created_at"`
+	StartedAt      *time.Time     `json:"started_at,omitempty"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	Steps          []AgentStep    `json:"steps"`
+	Result         *AgentResponse `json:"result,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *Job) Done() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
+
+// SubmitJob queues req to run in the background and returns the queued job.
+func (c *AgentClient) SubmitJob(ctx context.Context, req AgentRequest) (*Job, error) {
+	var out Job
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/agent/jobs", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Job fetches a job's current status.
+func (c *AgentClient) Job(ctx context.Context, jobID string) (*Job, error) {
+	var out Job
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/agent/jobs/"+url.PathEscape(jobID), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitJob polls a job every interval until it is done or ctx ends. A failed
+// job is returned without an error; check its Status.
+func (c *AgentClient) WaitJob(ctx context.Context, jobID string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CallbackPayload is the body the orchestrator POSTs to a callback_url.
+// Event is "agent.completed" or "agent.failed"; JobID is set for jobs.
+type CallbackPayload struct {
+	Event          string         `json:"event"`
+	ConversationID string         `json:"conversation_id"`
+	JobID          string         `json:"job_id,omitempty"`
+	Response       *AgentResponse `json:"response,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Timestamp      time.Time      `json:"timestamp"`
+}
+
+// VerifyCallback checks a callback request's signature against secret, the
+// orchestrator's AGENT_CALLBACK_SECRET, and decodes its payload. Requests
+// signed more than maxAge ago are rejected to stop replays; maxAge <= 0
+// skips that check.
+func VerifyCallback(r *http.Request, secret string, maxAge time.Duration) (*CallbackPayload, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := r.Header.Get("X-Agent-Timestamp")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get("X-Agent-Signature"))) {
+		return nil, fmt.Errorf("invalid callback signature")
+	}
+
+	if maxAge > 0 {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(sec, 0)) > maxAge {
+			return nil, fmt.Errorf("callback timestamp too old")
+		}
+	}
+
+	var payload CallbackPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
@@ -0,0 +1,218 @@
+// Package client is the Go SDK for the GoRilla RAG HTTP APIs. It wraps the
+// agent orchestrator, ingest, retrieval, metadata, vector and MCP gateway
+// services with typed
+// requests and responses, context-aware calls and retries on transient
+// failures, so callers never build JSON bodies by hand.
+//
+//	c := client.New(client.Config{})
+//	resp, err := c.Agent.Query(ctx, client.AgentRequest{Query: "What are KYC requirements?"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Config holds service base URLs and retry behaviour. Zero values fall back
+// to the local development defaults.
+type Config struct {
+	AgentURL     string // default http://localhost:9000
+	IngestURL    string // default http://localhost:8080
+	RetrievalURL string // default http://localhost:8084
+	MetadataURL  string // default http://localhost:8083
+	VectorURL    string // default http://localhost:8082
+	GatewayURL   string // default http://localhost:9100
+
+	// Tenant is sent as X-Tenant-ID on every call. Empty means the
+	// deployment's default tenant.
+	Tenant string
+	// Token is an auth-service JWT sent as a bearer token on every call.
+	Token string
+
+	// HTTPClient is used for every call; defaults to a client with a
+	// 2 minute timeout.
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a request is retried after a network
+	// error or a 429/502/503/504 response. Negative disables retries;
+	// zero means 2.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries, doubled on each
+	// attempt. Defaults to 200ms.
+	RetryBackoff time.Duration
+}
+
+// Client groups the per-service clients.
+type Client struct {
+	Agent     *AgentClient
+	Ingest    *IngestClient
+	Retrieval *RetrievalClient
+	Documents *DocumentsClient
+	Vectors   *VectorsClient
+	Tools     *ToolsClient
+}
+
+// New builds a Client from cfg.
+func New(cfg Config) *Client {
+	if cfg.AgentURL == "" {
+		cfg.AgentURL = "http://localhost:9000"
+	}
+	if cfg.IngestURL == "" {
+		cfg.IngestURL = "http://localhost:8080"
+	}
+	if cfg.RetrievalURL == "" {
+		cfg.RetrievalURL = "http://localhost:8084"
+	}
+	if cfg.MetadataURL == "" {
+		cfg.MetadataURL = "http://localhost:8083"
+	}
+	if cfg.VectorURL == "" {
+		cfg.VectorURL = "http://localhost:8082"
+	}
+	if cfg.GatewayURL == "" {
+		cfg.GatewayURL = "http://localhost:9100"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 2
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = 200 * time.Millisecond
+	}
+
+	t := &transport{
+		http:       cfg.HTTPClient,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+		tenant:     cfg.Tenant,
+		token:      cfg.Token,
+	}
+	return &Client{
+		Agent:     &AgentClient{baseURL: cfg.AgentURL, t: t},
+		Ingest:    &IngestClient{baseURL: cfg.IngestURL, t: t},
+		Retrieval: &RetrievalClient{baseURL: cfg.RetrievalURL, t: t},
+		Documents: &DocumentsClient{baseURL: cfg.MetadataURL, t: t},
+		Vectors:   &VectorsClient{baseURL: cfg.VectorURL, t: t},
+		Tools:     &ToolsClient{baseURL: cfg.GatewayURL, t: t},
+	}
+}
+
+// APIError is returned when a service answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+type transport struct {
+	http       *http.Client
+	maxRetries int
+	backoff    time.Duration
+	tenant     string
+	token      string
+}
+
+// doJSON sends in (if non-nil) as JSON and decodes the response into out (if
+// non-nil).
+func (t *transport) doJSON(ctx context.Context, method, url string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	return t.do(ctx, method, url, "application/json", body, out)
+}
+
+func (t *transport) do(ctx context.Context, method, url, contentType string, body []byte, out interface{}) error {
+	delay := t.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.send(ctx, method, url, contentType, "application/json", body)
+		if err == nil && !retryable(resp.StatusCode) {
+			defer resp.Body.Close()
+			return decode(resp, out)
+		}
+		if attempt >= t.maxRetries {
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return decode(resp, out)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (t *transport) send(ctx context.Context, method, url, contentType, accept string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	if t.tenant != "" {
+		req.Header.Set("X-Tenant-ID", t.tenant)
+	}
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.http.Do(req)
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		msg := string(bytes.TrimSpace(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			msg = apiErr.Error
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Document is a document's metadata record.
+type Document struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	FilePath   string    `json:"file_path"`
+	Status     string    `json:"status"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// AuditEvent is a pipeline event recorded by the metadata service.
+type AuditEvent struct {
+	ID         string                 `json:"id"`
+	Subject    string                 `json:"subject"`
+	Source     string                 `json:"source"`
+	DocumentID string                 `json:"document_id,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// DocumentsClient talks to the metadata service.
+type DocumentsClient struct {
+	baseURL string
+	t       *transport
+}
+
+// List returns all documents.
+func (c *DocumentsClient) List(ctx context.Context) ([]Document, error) {
+	var out struct {
+		Documents []Document `json:"documents"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/documents", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Documents, nil
+}
+
+// Get returns a single document.
+func (c *DocumentsClient) Get(ctx context.Context, id string) (*Document, error) {
+	var out Document
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/documents/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Delete removes a document's metadata record, but not its vectors or
+// file; IngestClient.DeleteDocument removes all three.
+func (c *DocumentsClient) Delete(ctx context.Context, id string) error {
+	return c.t.doJSON(ctx, http.MethodDelete, c.baseURL+"/documents/"+url.PathEscape(id), nil, nil)
+}
+
+// UpdateStatus sets a document's processing status.
+func (c *DocumentsClient) UpdateStatus(ctx context.Context, id, status string) error {
+	body := map[string]string{"status": status}
+	return c.t.doJSON(ctx, http.MethodPut, c.baseURL+"/documents/"+url.PathEscape(id)+"/status", body, nil)
+}
+
+// Events returns the audit log, newest first. documentID may be empty; limit
+// <= 0 uses the server default.
+func (c *DocumentsClient) Events(ctx context.Context, documentID string, limit int) ([]AuditEvent, error) {
+	q := url.Values{}
+	if documentID != "" {
+		q.Set("document_id", documentID)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	u := c.baseURL + "/events"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	var out struct {
+		Events []AuditEvent `json:"events"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, u, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Events, nil
+}
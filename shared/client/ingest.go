@@ -0,0 +1,345 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// IngestRequest is the body of POST /ingest.
+type IngestRequest struct {
+	DocumentName string   `json:"document_name"`
+	DocumentType string   `json:"document_type"`
+	Tags         []string `json:"tags,omitempty"`
+	FilePath     string   `json:"file_path"`
+	ChunkSize    int      `json:"chunk_size,omitempty"`
+	ChunkOverlap int      `json:"chunk_overlap,omitempty"`
+	// ChunkSizeTokens sizes chunks in the embedding model's tokens instead
+	// of characters, up to its input limit (2048 for text-embedding-004).
+	ChunkSizeTokens int `json:"chunk_size_tokens,omitempty"`
+	// ChunkingStrategy is "sentence" (the default) or "semantic", which cuts
+	// chunks where the topic shifts at the cost of embedding every sentence.
+	ChunkingStrategy string `json:"chunking_strategy,omitempty"`
+	// EffectiveDate (YYYY-MM-DD or RFC 3339) is when the document takes
+	// effect, which recency-weighted retrieval ages it from.
+	EffectiveDate string `json:"effective_date,omitempty"`
+	// Metadata is stored in every chunk's payload, for retrieval's payload
+	// filters and match clauses.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// IngestURLRequest is the body of POST /ingest-url. Its IngestRequest's
+// FilePath is ignored, and DocumentName defaults to the page's title.
+type IngestURLRequest struct {
+	URL string `json:"url"`
+	IngestRequest
+}
+
+// ReingestRequest is the optional body of PUT /documents/{id}/reingest.
+// Tags, EffectiveDate and Metadata left empty keep the document's current
+// ones; the chunking options default as for IngestRequest.
+type ReingestRequest struct {
+	ChunkSize        int                    `json:"chunk_size,omitempty"`
+	ChunkOverlap     int                    `json:"chunk_overlap,omitempty"`
+	ChunkSizeTokens  int                    `json:"chunk_size_tokens,omitempty"`
+	ChunkingStrategy string                 `json:"chunking_strategy,omitempty"`
+	Tags             []string               `json:"tags,omitempty"`
+	EffectiveDate    string                 `json:"effective_date,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// IngestResponse reports the outcome of an ingestion.
+type IngestResponse struct {
+	DocumentID string `json:"document_id"`
+	Status     string `json:"status"`
+	Chunks     int    `json:"chunks"`
+	Message    string `json:"message"`
+}
+
+// UploadResponse identifies a file stored by POST /upload.
+type UploadResponse struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	FilePath string `json:"file_path"`
+}
+
+// IngestClient talks to the ingest service.
+type IngestClient struct {
+	baseURL string
+	t       *transport
+}
+
+// Upload stores a file on the ingest service. The returned FilePath is what
+// Ingest expects.
+func (c *IngestClient) Upload(ctx context.Context, fileName string, r io.Reader) (*UploadResponse, error) {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var out UploadResponse
+	if err := c.t.do(ctx, http.MethodPost, c.baseURL+"/upload", form.FormDataContentType(), buf.Bytes(), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Ingest extracts, chunks, embeds and indexes a previously uploaded file.
+func (c *IngestClient) Ingest(ctx context.Context, req IngestRequest) (*IngestResponse, error) {
+	var out IngestResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// IngestURL fetches a web page, or a PDF, Word or text file, and ingests
+// the page's main text, recording URL as its source.
+func (c *IngestClient) IngestURL(ctx context.Context, req IngestURLRequest) (*IngestResponse, error) {
+	var out IngestResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest-url", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BatchIngestRequest is the body of POST /ingest-batch: the files of
+// Directory (and its subdirectories, with Recursive) and FilePaths, each
+// ingested as a document named after its file with the IngestRequest's
+// options. The IngestRequest's DocumentName and FilePath must be empty,
+// and every path must be inside the ingest service's DATA_DIR.
+type BatchIngestRequest struct {
+	Directory   string   `json:"directory,omitempty"`
+	Recursive   bool     `json:"recursive,omitempty"`
+	FilePaths   []string `json:"file_paths,omitempty"`
+	Concurrency int      `json:"concurrency,omitempty"`
+	IngestRequest
+}
+
+// BatchIngestResponse reports every file of a batch. Files that failed are
+// reported here rather than failing the call.
+type BatchIngestResponse struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Queued    int               `json:"queued,omitempty"`
+	Failed    int               `json:"failed"`
+	Chunks    int               `json:"chunks"`
+	Files     []BatchFileResult `json:"files"`
+}
+
+// BatchFileResult is the outcome of one file of a batch: Status is
+// "completed", "queued" (with JobID) or "failed" (with Error).
+type BatchFileResult struct {
+	FilePath   string `json:"file_path"`
+	Status     string `json:"status"`
+	DocumentID string `json:"document_id,omitempty"`
+	JobID      string `json:"job_id,omitempty"`
+	Chunks     int    `json:"chunks"`
+	Error      string `json:"error,omitempty"`
+}
+
+// IngestBatch ingests every file of req, several at a time, and reports
+// each.
+func (c *IngestClient) IngestBatch(ctx context.Context, req BatchIngestRequest) (*BatchIngestResponse, error) {
+	var out BatchIngestResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest-batch", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitIngestBatch queues every file of req as its own ingestion job and
+// reports each file's JobID.
+func (c *IngestClient) SubmitIngestBatch(ctx context.Context, req BatchIngestRequest) (*BatchIngestResponse, error) {
+	body := struct {
+		BatchIngestRequest
+		Async bool `json:"async"`
+	}{req, true}
+	var out BatchIngestResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest-batch", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Reingest reads a document's file or page again and replaces its chunks,
+// keeping its ID.
+func (c *IngestClient) Reingest(ctx context.Context, documentID string, req ReingestRequest) (*IngestResponse, error) {
+	var out IngestResponse
+	if err := c.t.doJSON(ctx, http.MethodPut, c.reingestURL(documentID), req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *IngestClient) reingestURL(documentID string) string {
+	return c.baseURL + "/documents/" + url.PathEscape(documentID) + "/reingest"
+}
+
+// DeleteDocumentResponse reports what deleting a document removed.
+type DeleteDocumentResponse struct {
+	Status         string `json:"status"`
+	DocumentID     string `json:"document_id"`
+	VectorsDeleted int    `json:"vectors_deleted"`
+	FileRemoved    bool   `json:"file_removed"`
+}
+
+// DeleteDocument removes a document's vectors, uploaded file and metadata.
+// DocumentsClient.Delete removes only the metadata.
+func (c *IngestClient) DeleteDocument(ctx context.Context, documentID string) (*DeleteDocumentResponse, error) {
+	var out DeleteDocumentResponse
+	if err := c.t.doJSON(ctx, http.MethodDelete, c.baseURL+"/documents/"+url.PathEscape(documentID), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Ingestion job states reported by the ingest service; a JobStage is also
+// "pending" until the job reaches it.
+const (
+	IngestJobQueued    = "queued"
+	IngestJobRunning   = "running"
+	IngestJobCompleted = "completed"
+	IngestJobFailed    = "failed"
+)
+
+// IngestJob is an ingestion running in the background. DocumentID is set
+// once the document is recorded, Result once the job has completed and
+// Error once it has failed.
+type IngestJob struct {
+	ID           string          `json:"id"`
+	Status       string          `json:"status"`
+	DocumentID   string          `json:"document_id,omitempty"`
+	DocumentName string          `json:"document_name,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+	Stages       []JobStage      `json:"stages"`
+	Result       *IngestResponse `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// JobStage is one stage of an ingestion job: extract or fetch, metadata,
+// chunk, embed and store. Count is the characters extracted or the chunks
+// the stage handled.
+type JobStage struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Count       int        `json:"count,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *IngestJob) Done() bool {
+	return j.Status == IngestJobCompleted || j.Status == IngestJobFailed
+}
+
+// SubmitIngest queues req to be ingested in the background and returns the
+// queued job.
+func (c *IngestClient) SubmitIngest(ctx context.Context, req IngestRequest) (*IngestJob, error) {
+	body := struct {
+		IngestRequest
+		Async bool `json:"async"`
+	}{req, true}
+	var out IngestJob
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitIngestURL queues req's page to be ingested in the background and
+// returns the queued job.
+func (c *IngestClient) SubmitIngestURL(ctx context.Context, req IngestURLRequest) (*IngestJob, error) {
+	body := struct {
+		IngestURLRequest
+		Async bool `json:"async"`
+	}{req, true}
+	var out IngestJob
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/ingest-url", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitReingest queues a document's re-ingestion in the background and
+// returns the queued job.
+func (c *IngestClient) SubmitReingest(ctx context.Context, documentID string, req ReingestRequest) (*IngestJob, error) {
+	body := struct {
+		ReingestRequest
+		Async bool `json:"async"`
+	}{req, true}
+	var out IngestJob
+	if err := c.t.doJSON(ctx, http.MethodPut, c.reingestURL(documentID), body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Job fetches an ingestion job's current status.
+func (c *IngestClient) Job(ctx context.Context, jobID string) (*IngestJob, error) {
+	var out IngestJob
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/jobs/"+url.PathEscape(jobID), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeJob requeues a failed ingestion job from its last checkpoint, so
+// the chunks it already embedded and stored aren't embedded again.
+func (c *IngestClient) ResumeJob(ctx context.Context, jobID string) (*IngestJob, error) {
+	var out IngestJob
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/jobs/"+url.PathEscape(jobID)+"/resume", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitJob polls an ingestion job every interval until it is done or ctx
+// ends. A failed job is returned without an error; check its Status.
+func (c *IngestClient) WaitJob(ctx context.Context, jobID string, interval time.Duration) (*IngestJob, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.Job(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// UploadAndIngest uploads r and ingests it in one call.
+func (c *IngestClient) UploadAndIngest(ctx context.Context, fileName string, r io.Reader, req IngestRequest) (*IngestResponse, error) {
+	uploaded, err := c.Upload(ctx, fileName, r)
+	if err != nil {
+		return nil, err
+	}
+	req.FilePath = uploaded.FilePath
+	if req.DocumentName == "" {
+		req.DocumentName = fileName
+	}
+	return c.Ingest(ctx, req)
+}
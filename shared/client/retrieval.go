@@ -0,0 +1,367 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RetrievalRequest is the body of POST /retrieve.
+type RetrievalRequest struct {
+	Query      string `json:"query"`
+	TopK       int    `json:"top_k,omitempty"`
+	Collection string `json:"collection,omitempty"` // empty or "auto" picks it from the query
+	// Filters restricts results by document_id, document_type, tags or
+	// uploaded_at, e.g. {"tags": []string{"kyc"}}, or by other payload
+	// attributes as "payload.<field>".
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// Rerank picks the reranking strategy, one of the Rerank constants.
+	// Empty leaves it to the service's retrieval_rerank flag.
+	Rerank string `json:"rerank,omitempty"`
+	// RerankWeights sets the keyword strategy's blend of vector score and
+	// keyword match; nil leaves it to the service.
+	RerankWeights *RerankWeights `json:"rerank_weights,omitempty"`
+	// Hybrid, when set, overrides the service's retrieval_hybrid flag.
+	Hybrid *bool `json:"hybrid,omitempty"`
+	// MinScore drops results scoring below it. MinResults widens the
+	// search until that many clear MinScore.
+	MinScore   float64 `json:"min_score,omitempty"`
+	MinResults int     `json:"min_results,omitempty"`
+	// Strategy picks what is searched with, one of the Strategy constants.
+	Strategy string `json:"strategy,omitempty"`
+	// ExpandContext replaces each result's text with the passage from this
+	// many chunks before it to this many after.
+	ExpandContext int `json:"expand_context,omitempty"`
+	// Offset skips this many results. With Offset or Paginate set, the
+	// service keeps the best results and the response carries a
+	// NextPageToken while any are left.
+	Offset   int  `json:"offset,omitempty"`
+	Paginate bool `json:"paginate,omitempty"`
+	// PageToken is an earlier response's NextPageToken. The next TopK kept
+	// results are returned without running the query again; the other
+	// fields are ignored.
+	PageToken string `json:"page_token,omitempty"`
+	// Dedup, when set, overrides the service's retrieval_dedup flag.
+	Dedup *bool `json:"dedup,omitempty"`
+	// RecencyWeight (0 to 1) blends each score with how recent the
+	// result's document is.
+	RecencyWeight float64 `json:"recency_weight,omitempty"`
+	// TranslateTo ("hi", "ta", ...) also searches the query translated to
+	// that language when it is in another.
+	TranslateTo string `json:"translate_to,omitempty"`
+	// Match boosts, or with Required keeps only, results whose payload
+	// fields contain the clauses' terms.
+	Match []MatchClause `json:"match,omitempty"`
+	// EmbeddingModel and EmbeddingDimensions embed the query with the
+	// model and output size the collection was indexed with, when not the
+	// embed service's default.
+	EmbeddingModel      string `json:"embedding_model,omitempty"`
+	EmbeddingDimensions int    `json:"embedding_dimensions,omitempty"`
+	// MaxContextTokens caps the estimated tokens of the results' texts
+	// together, dropping and cutting results to fit. It can't be combined
+	// with pagination.
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
+	// GroupByDocument returns one result per document, its best chunk,
+	// with the document's other hits as Siblings. It can't be streamed.
+	GroupByDocument bool `json:"group_by_document,omitempty"`
+	// Explain adds to each result how its score came about.
+	Explain bool `json:"explain,omitempty"`
+}
+
+// Retrieval strategies for RetrievalRequest.Strategy.
+const (
+	StrategyStandard   = "standard"
+	StrategyHyDE       = "hyde"
+	StrategyMultiQuery = "multi_query"
+)
+
+// MatchClause is a keyword match scoped to a payload field. Boost
+// defaults to the service's MATCH_BOOST.
+type MatchClause struct {
+	Field    string   `json:"field"`
+	Value    string   `json:"value"`
+	Boost    *float64 `json:"boost,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+// RerankWeights is the keyword reranker's blend, scaled by the service to
+// sum to 1.
+type RerankWeights struct {
+	Vector  float64 `json:"vector"`
+	Keyword float64 `json:"keyword"`
+}
+
+// Reranking strategies for RetrievalRequest.Rerank.
+const (
+	RerankKeyword      = "keyword"
+	RerankCrossEncoder = "cross_encoder"
+	RerankLLM          = "llm"
+	RerankNone         = "none"
+)
+
+// RetrievalResult is a single retrieved chunk.
+type RetrievalResult struct {
+	ID         string                 `json:"id"`
+	Score      float64                `json:"score"`
+	Text       string                 `json:"text"`
+	DocumentID string                 `json:"document_id"`
+	Source     string                 `json:"source"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	// Highlights are the passages of Text where query terms matched.
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// Language is the chunk's language, e.g. "hi".
+	Language string `json:"language,omitempty"`
+	// Tokens is the estimated tokens of Text, under MaxContextTokens.
+	Tokens int `json:"tokens,omitempty"`
+	// Siblings are the document's other hits, under GroupByDocument.
+	Siblings []RetrievalResult `json:"siblings,omitempty"`
+	// Explanation is how the score came about, under Explain.
+	Explanation *ScoreExplanation `json:"explanation,omitempty"`
+}
+
+// ScoreExplanation breaks a result's score down into the search scores
+// and each later step's change to it.
+type ScoreExplanation struct {
+	VectorScore  *float64    `json:"vector_score,omitempty"`
+	KeywordScore *float64    `json:"keyword_score,omitempty"`
+	SearchScore  float64     `json:"search_score"`
+	KeywordMatch *float64    `json:"keyword_match,omitempty"`
+	Steps        []ScoreStep `json:"steps,omitempty"`
+	FinalScore   float64     `json:"final_score"`
+}
+
+// ScoreStep is a reranking ("rerank:<strategy>"), "match" or "recency"
+// change to a score.
+type ScoreStep struct {
+	Step   string  `json:"step"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// Highlight is a passage of a result's text and the query terms in it.
+// Offsets count characters (Unicode code points) of the text, ends
+// exclusive.
+type Highlight struct {
+	Snippet string      `json:"snippet"`
+	Start   int         `json:"start"`
+	End     int         `json:"end"`
+	Matches []TermMatch `json:"matches"`
+}
+
+// TermMatch is a word of the text matching a query term.
+type TermMatch struct {
+	Term  string `json:"term"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// RetrievalResponse holds ranked results for a query.
+type RetrievalResponse struct {
+	Query       string            `json:"query"`
+	Results     []RetrievalResult `json:"results"`
+	Count       int               `json:"count"`
+	ProcessTime float64           `json:"process_time_ms"`
+	Widened     *Widening         `json:"widened,omitempty"`
+	// Queries are the query and its variants, for StrategyMultiQuery, and
+	// its translation, for TranslateTo.
+	Queries []string `json:"queries,omitempty"`
+	// QueryLanguage is the language the query is in.
+	QueryLanguage string `json:"query_language,omitempty"`
+	// Cached is set when the results came from the service's cache.
+	Cached bool `json:"cached,omitempty"`
+	// Degraded is set when the query couldn't be embedded and the results
+	// come from keyword search alone.
+	Degraded bool `json:"degraded,omitempty"`
+	// ContextTokens is the estimated tokens of the results' texts, under
+	// MaxContextTokens.
+	ContextTokens int `json:"context_tokens,omitempty"`
+	// Collections are the collections picked from the query and searched,
+	// when the request named none.
+	Collections []string `json:"collections,omitempty"`
+	// Offset and Total place a page among the kept results.
+	Offset        int    `json:"offset,omitempty"`
+	Total         int    `json:"total,omitempty"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// Widening reports how a search was widened to reach MinResults.
+type Widening struct {
+	TopK        int      `json:"top_k"`
+	Collections []string `json:"collections,omitempty"`
+}
+
+// BatchRetrievalResponse holds a response per query of a batch, in order.
+type BatchRetrievalResponse struct {
+	Responses   []BatchRetrievalItem `json:"responses"`
+	Count       int                  `json:"count"`
+	ProcessTime float64              `json:"process_time_ms"`
+}
+
+// BatchRetrievalItem is one query's response, or why it failed.
+type BatchRetrievalItem struct {
+	RetrievalResponse
+	Error string `json:"error,omitempty"`
+}
+
+// ContextRequest is a retrieval request whose results are packed into a
+// prompt context. MaxTokens and Separator default to the service's
+// CONTEXT_MAX_TOKENS and CONTEXT_SEPARATOR.
+type ContextRequest struct {
+	RetrievalRequest
+	MaxTokens int    `json:"max_tokens,omitempty"`
+	Separator string `json:"separator,omitempty"`
+}
+
+// ContextResponse holds the results and the context packed from them.
+type ContextResponse struct {
+	RetrievalResponse
+	// Context is the results in rank order, each headed by its [n] marker
+	// and source, within MaxTokens.
+	Context   string            `json:"context"`
+	Citations []ContextCitation `json:"citations"`
+	Tokens    int               `json:"tokens"`
+	// Truncated is set when results were cut or left out to fit.
+	Truncated bool `json:"truncated"`
+}
+
+// ContextCitation maps a [n] marker in the context to its result.
+type ContextCitation struct {
+	Marker     int     `json:"marker"`
+	ID         string  `json:"id"`
+	DocumentID string  `json:"document_id"`
+	Source     string  `json:"source"`
+	Score      float64 `json:"score"`
+}
+
+// EvaluateRequest scores the retrieval settings of RetrievalRequest on
+// Cases; its Query is taken from each case.
+type EvaluateRequest struct {
+	RetrievalRequest
+	Cases []EvaluationCase `json:"cases"`
+}
+
+// EvaluationCase is a query and the documents it should retrieve.
+type EvaluationCase struct {
+	Query               string   `json:"query"`
+	ExpectedDocumentIDs []string `json:"expected_document_ids"`
+	Collection          string   `json:"collection,omitempty"`
+}
+
+// EvaluateResponse holds the scores averaged over the cases that ran
+// (recall_at_k, mrr, ndcg_at_k, hit_rate) and those of each case.
+type EvaluateResponse struct {
+	K           int                    `json:"k"`
+	Config      map[string]interface{} `json:"config"`
+	Metrics     map[string]float64     `json:"metrics"`
+	Cases       []EvaluationResult     `json:"cases"`
+	Count       int                    `json:"count"`
+	Failed      int                    `json:"failed"`
+	ProcessTime float64                `json:"process_time_ms"`
+}
+
+// EvaluationResult is how one case scored, or why it failed.
+type EvaluationResult struct {
+	Query                string             `json:"query"`
+	ExpectedDocumentIDs  []string           `json:"expected_document_ids"`
+	RetrievedDocumentIDs []string           `json:"retrieved_document_ids"`
+	Metrics              map[string]float64 `json:"metrics,omitempty"`
+	Error                string             `json:"error,omitempty"`
+}
+
+// RetrievalClient talks to the retrieval service.
+type RetrievalClient struct {
+	baseURL string
+	t       *transport
+}
+
+// Retrieve runs semantic search for req.
+func (c *RetrievalClient) Retrieve(ctx context.Context, req RetrievalRequest) (*RetrievalResponse, error) {
+	var out RetrievalResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/retrieve", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RetrieveBatch runs several retrieval requests in one round trip. A
+// request that fails sets Error in its item rather than failing the call.
+func (c *RetrievalClient) RetrieveBatch(ctx context.Context, reqs []RetrievalRequest) (*BatchRetrievalResponse, error) {
+	var out BatchRetrievalResponse
+	body := map[string]interface{}{"queries": reqs}
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/retrieve/batch", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RetrieveContext runs req and packs the results into a prompt-ready
+// context with citation markers.
+func (c *RetrievalClient) RetrieveContext(ctx context.Context, req ContextRequest) (*ContextResponse, error) {
+	var out ContextResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/retrieve/context", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RetrieveStream runs req like Retrieve, with the service streaming the
+// results as NDJSON, and calls onResult with each as it arrives, in rank
+// order. It returns the whole response once the stream ends. An error from
+// onResult stops reading and is returned. Streams are not retried.
+func (c *RetrievalClient) RetrieveStream(ctx context.Context, req RetrievalRequest, onResult func(RetrievalResult) error) (*RetrievalResponse, error) {
+	body, err := json.Marshal(struct {
+		RetrievalRequest
+		Stream bool `json:"stream"`
+	}{req, true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	resp, err := c.t.send(ctx, http.MethodPost, c.baseURL+"/retrieve", "application/json", "application/x-ndjson", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decode(resp, nil)
+	}
+
+	out := &RetrievalResponse{Results: []RetrievalResult{}}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20) // a result carries its chunk's text
+	for scanner.Scan() {
+		var line struct {
+			Result *RetrievalResult   `json:"result"`
+			Done   *RetrievalResponse `json:"done"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to decode stream line: %w", err)
+		}
+		if line.Result != nil {
+			out.Results = append(out.Results, *line.Result)
+			if onResult != nil {
+				if err := onResult(*line.Result); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if line.Done != nil {
+			line.Done.Results = out.Results
+			return line.Done, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil, fmt.Errorf("stream ended before the results were complete")
+}
+
+// Evaluate scores the retrieval pipeline on labelled queries.
+func (c *RetrievalClient) Evaluate(ctx context.Context, req EvaluateRequest) (*EvaluateResponse, error) {
+	var out EvaluateResponse
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/evaluate", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tool is an MCP tool registered with the gateway.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Endpoint    string                 `json:"endpoint"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolHealth is the result of probing a tool's /healthz.
+type ToolHealth struct {
+	Name      string  `json:"name"`
+	Endpoint  string  `json:"endpoint"`
+	Healthy   bool    `json:"healthy"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// ToolsClient talks to the MCP gateway.
+type ToolsClient struct {
+	baseURL string
+	t       *transport
+}
+
+// List returns the registered tools.
+func (c *ToolsClient) List(ctx context.Context) ([]Tool, error) {
+	var out struct {
+		Tools []Tool `json:"tools"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/tools/list", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Tools, nil
+}
+
+// Call invokes a tool through the gateway and returns its JSON reply.
+func (c *ToolsClient) Call(ctx context.Context, tool string, params map[string]interface{}) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	body := map[string]interface{}{"tool": tool, "params": params}
+	if err := c.t.doJSON(ctx, http.MethodPost, c.baseURL+"/tools/call", body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Health probes every registered tool.
+func (c *ToolsClient) Health(ctx context.Context) ([]ToolHealth, error) {
+	var out struct {
+		Tools []ToolHealth `json:"tools"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/tools/health", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Tools, nil
+}
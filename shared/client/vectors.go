@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// CollectionStats reports the size and health of a vector collection.
+type CollectionStats struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	PointsCount  uint64 `json:"points_count"`
+	VectorsCount uint64 `json:"vectors_count"`
+}
+
+// VectorsClient talks to the vector service.
+type VectorsClient struct {
+	baseURL string
+	t       *transport
+}
+
+// Collections returns per-collection point counts.
+func (c *VectorsClient) Collections(ctx context.Context) ([]CollectionStats, error) {
+	var out struct {
+		Stats []CollectionStats `json:"stats"`
+	}
+	if err := c.t.doJSON(ctx, http.MethodGet, c.baseURL+"/collections", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Stats, nil
+}
@@ -0,0 +1,118 @@
+// Package events publishes and consumes pipeline events over NATS so new
+// consumers can react to ingestion, deletion and agent activity without the
+// producers knowing about them. When NATS_URL is unset every service gets a
+// no-op bus and keeps working exactly as before.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+
+	"shared/tenant"
+)
+
+// Subjects published by the pipeline. Subscribers may use NATS wildcards
+// such as "ingest.>" to receive a whole family.
+const (
+	IngestStarted   = "ingest.started"
+	IngestCompleted = "ingest.completed"
+	IngestFailed    = "ingest.failed"
+	DocumentDeleted = "document.deleted"
+	AgentCompleted  = "agent.completed"
+)
+
+// Event is the envelope every message is wrapped in. Tenant is taken from
+// the publishing context.
+type Event struct {
+	ID      string                 `json:"id"`
+	Type    string                 `json:"type"`
+	Source  string                 `json:"source"`
+	Tenant  string                 `json:"tenant"`
+	Time    time.Time              `json:"time"`
+	Data    map[string]interface{} `json:"data"`
+	Subject string                 `json:"-"`
+}
+
+// Bus publishes events and delivers subscribed ones to handlers.
+type Bus interface {
+	Publish(ctx context.Context, subject string, data map[string]interface{}) error
+	Subscribe(subject string, handler func(Event)) error
+	Close()
+}
+
+// Connect returns a NATS-backed bus when NATS_URL is set and a no-op bus
+// otherwise. source identifies the publishing service in each event.
+func Connect(source string) (Bus, error) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		return noopBus{}, nil
+	}
+
+	conn, err := nats.Connect(url,
+		nats.Name(source),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Event bus disconnected: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Event bus reconnected to %s", c.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Event bus connected to %s", conn.ConnectedUrl())
+	return &natsBus{conn: conn, source: source}, nil
+}
+
+type natsBus struct {
+	conn   *nats.Conn
+	source string
+}
+
+func (b *natsBus) Publish(ctx context.Context, subject string, data map[string]interface{}) error {
+	body, err := json.Marshal(Event{
+		ID:     uuid.New().String(),
+		Type:   subject,
+		Source: b.source,
+		Tenant: tenant.FromContext(ctx),
+		Time:   time.Now().UTC(),
+		Data:   data,
+	})
+	if err != nil {
+		return err
+	}
+	return b.conn.Publish(subject, body)
+}
+
+func (b *natsBus) Subscribe(subject string, handler func(Event)) error {
+	_, err := b.conn.Subscribe(subject, func(msg *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			log.Printf("Dropping malformed event on %s: %v", msg.Subject, err)
+			return
+		}
+		e.Subject = msg.Subject
+		handler(e)
+	})
+	return err
+}
+
+func (b *natsBus) Close() {
+	b.conn.Drain()
+}
+
+type noopBus struct{}
+
+func (noopBus) Publish(context.Context, string, map[string]interface{}) error { return nil }
+func (noopBus) Subscribe(string, func(Event)) error                           { return nil }
+func (noopBus) Close()                                                        {}
@@ -0,0 +1,193 @@
+// Package faults injects latency, errors and dropped responses into chosen
+// routes so retries, circuit breakers and degraded modes can be exercised
+// before a real outage does it. It is for test environments only and does
+// nothing unless FAULTS is set to a JSON list of rules, e.g.
+//
+//	FAULTS='[{"method":"POST","path":"/search","error_rate":0.1}]'
+//	FAULTS='[{"path":"/embed","latency":"800ms","jitter":"400ms"},
+//	         {"path":"/gorilla.v1.VectorService/Search","drop_rate":0.05}]'
+//
+// HTTP paths ending in "/" match as prefixes; gRPC rules use the full method
+// name. The first matching rule applies.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rule describes the faults injected into one route.
+type Rule struct {
+	Method      string   `json:"method,omitempty"` // empty matches any method
+	Path        string   `json:"path"`
+	Latency     Duration `json:"latency,omitempty"`
+	Jitter      Duration `json:"jitter,omitempty"`       // extra random delay in [0, jitter)
+	ErrorRate   float64  `json:"error_rate,omitempty"`   // fraction answered with ErrorStatus
+	ErrorStatus int      `json:"error_status,omitempty"` // default 500
+	DropRate    float64  `json:"drop_rate,omitempty"`    // fraction whose response is discarded
+}
+
+// Duration is a time.Duration that unmarshals from strings like "250ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+var injected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "faults_injected_total",
+	Help: "Faults injected by the test-only fault middleware, by kind (latency, error, drop).",
+}, []string{"service", "route", "kind"})
+
+// rules is parsed once; a malformed FAULTS aborts startup rather than
+// silently running without the faults a test expects.
+var rules = mustParse(os.Getenv("FAULTS"))
+
+func mustParse(raw string) []Rule {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var parsed []Rule
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		panic(fmt.Sprintf("faults: invalid FAULTS: %v", err))
+	}
+	for i := range parsed {
+		if parsed[i].ErrorStatus == 0 {
+			parsed[i].ErrorStatus = http.StatusInternalServerError
+		}
+	}
+	return parsed
+}
+
+// Enabled reports whether any fault rules are configured.
+func Enabled() bool {
+	return len(rules) > 0
+}
+
+func match(method, path string) (Rule, bool) {
+	for _, r := range rules {
+		if r.Method != "" && !strings.EqualFold(r.Method, method) {
+			continue
+		}
+		if strings.HasSuffix(r.Path, "/") && strings.HasPrefix(path, r.Path) || r.Path == path {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// delay sleeps for the rule's latency plus jitter, returning early if ctx
+// is cancelled.
+func (r Rule) delay(ctx context.Context) bool {
+	d := time.Duration(r.Latency)
+	if r.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+	if d <= 0 {
+		return false
+	}
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// Wrap injects the configured faults into next. It returns next unchanged
+// when FAULTS is unset. Run it inside metrics.Wrap so injected errors show
+// up in the RED metrics.
+func Wrap(service string, next http.Handler) http.Handler {
+	if !Enabled() {
+		return next
+	}
+	log.Printf("⚠️  FAULT INJECTION ENABLED for %s: %d rule(s)", service, len(rules))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := match(r.Method, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rule.delay(r.Context()) {
+			injected.WithLabelValues(service, rule.Path, "latency").Inc()
+		}
+
+		if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+			injected.WithLabelValues(service, rule.Path, "error").Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Fault-Injected", "error")
+			w.WriteHeader(rule.ErrorStatus)
+			json.NewEncoder(w).Encode(map[string]string{"error": "injected fault"})
+			return
+		}
+
+		if rule.DropRate > 0 && rand.Float64() < rule.DropRate {
+			// The handler still runs, as it would if the connection died on
+			// the way back, so callers see side effects without a response.
+			injected.WithLabelValues(service, rule.Path, "drop").Inc()
+			next.ServeHTTP(&discardWriter{header: http.Header{}}, r)
+			panic(http.ErrAbortHandler)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// discardWriter swallows a response that is about to be dropped.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+// UnaryServerInterceptor applies the same rules to gRPC calls, matching on
+// the full method name. Injected errors and drops surface as Unavailable.
+// Metrics are labeled with the gRPC service, e.g. gorilla.v1.VectorService.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		rule, ok := match("", info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		service, _, _ := strings.Cut(strings.TrimPrefix(info.FullMethod, "/"), "/")
+
+		if rule.delay(ctx) {
+			injected.WithLabelValues(service, rule.Path, "latency").Inc()
+		}
+		if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+			injected.WithLabelValues(service, rule.Path, "error").Inc()
+			return nil, status.Error(codes.Unavailable, "injected fault")
+		}
+		if rule.DropRate > 0 && rand.Float64() < rule.DropRate {
+			injected.WithLabelValues(service, rule.Path, "drop").Inc()
+			handler(ctx, req)
+			return nil, status.Error(codes.Unavailable, "injected fault: response dropped")
+		}
+		return handler(ctx, req)
+	}
+}
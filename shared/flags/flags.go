@@ -0,0 +1,247 @@
+// Package flags is a small feature-flag registry for toggling risky
+// behaviour per environment without a redeploy. Each flag has a compiled-in
+// default that can be overridden, in increasing order of precedence, by the
+// FEATURE_FLAGS environment variable, a JSON file (FEATURE_FLAGS_FILE) and a
+// remote JSON endpoint (FEATURE_FLAGS_URL). The file and endpoint are
+// re-read every FEATURE_FLAGS_REFRESH (default 30s).
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources a flag value can come from, reported by the admin endpoint.
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceRemote  = "remote"
+)
+
+// Flag is a named boolean toggle.
+type Flag struct {
+	name        string
+	description string
+	def         bool
+}
+
+// Name returns the flag's key as used in FEATURE_FLAGS and flag files.
+func (f *Flag) Name() string { return f.name }
+
+// Enabled reports the flag's current value.
+func (f *Flag) Enabled() bool {
+	v, _ := lookup(f)
+	return v
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Flag{}
+	layers   = map[string]map[string]bool{} // source -> overrides
+	updated  time.Time
+)
+
+// Define registers a flag with its default value. It is meant to be called
+// from package-level var declarations and panics on duplicate names.
+func Define(name string, def bool, description string) *Flag {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("flags: %q defined twice", name))
+	}
+	f := &Flag{name: name, description: description, def: def}
+	registry[name] = f
+	return f
+}
+
+// lookup resolves f against the override layers, highest precedence first.
+func lookup(f *Flag) (bool, string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, src := range []string{SourceRemote, SourceFile, SourceEnv} {
+		if v, ok := layers[src][f.name]; ok {
+			return v, src
+		}
+	}
+	return f.def, SourceDefault
+}
+
+func setLayer(src string, values map[string]bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	layers[src] = values
+	updated = time.Now().UTC()
+}
+
+// Load applies FEATURE_FLAGS and FEATURE_FLAGS_FILE, then keeps the file and
+// FEATURE_FLAGS_URL refreshed in the background until ctx is done. A file or
+// endpoint that cannot be read keeps its last good values.
+func Load(ctx context.Context) error {
+	env, err := parseList(os.Getenv("FEATURE_FLAGS"))
+	if err != nil {
+		return fmt.Errorf("FEATURE_FLAGS: %w", err)
+	}
+	setLayer(SourceEnv, env)
+
+	path := os.Getenv("FEATURE_FLAGS_FILE")
+	url := os.Getenv("FEATURE_FLAGS_URL")
+	if path != "" {
+		if err := loadFile(path); err != nil {
+			return err
+		}
+	}
+	if url != "" {
+		if err := loadRemote(ctx, url); err != nil {
+			log.Printf("⚠️  Feature flags: %v", err)
+		}
+	}
+	if path == "" && url == "" {
+		return nil
+	}
+
+	refresh := 30 * time.Second
+	if v := os.Getenv("FEATURE_FLAGS_REFRESH"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			refresh = d
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if path != "" {
+				if err := loadFile(path); err != nil {
+					log.Printf("⚠️  Feature flags: %v", err)
+				}
+			}
+			if url != "" {
+				if err := loadRemote(ctx, url); err != nil {
+					log.Printf("⚠️  Feature flags: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var values map[string]bool
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	setLayer(SourceFile, values)
+	return nil
+}
+
+func loadRemote(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: status %d", url, resp.StatusCode)
+	}
+	var values map[string]bool
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	setLayer(SourceRemote, values)
+	return nil
+}
+
+// parseList parses "name=true,other=false". A bare name means true.
+func parseList(s string) (map[string]bool, error) {
+	values := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, raw, found := strings.Cut(item, "=")
+		v := true
+		if found {
+			b, err := strconv.ParseBool(strings.TrimSpace(raw))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %q: %q", name, raw)
+			}
+			v = b
+		}
+		values[strings.TrimSpace(name)] = v
+	}
+	return values, nil
+}
+
+// State is a flag as reported by the admin endpoint.
+type State struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+// Snapshot returns every registered flag sorted by name.
+func Snapshot() []State {
+	mu.RLock()
+	all := make([]*Flag, 0, len(registry))
+	for _, f := range registry {
+		all = append(all, f)
+	}
+	mu.RUnlock()
+
+	states := make([]State, len(all))
+	for i, f := range all {
+		v, src := lookup(f)
+		states[i] = State{Name: f.name, Enabled: v, Default: f.def, Source: src, Description: f.description}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// Handler serves the current flag state for service as JSON. Mount it on
+// an admin route such as /admin/flags.
+func Handler(service string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mu.RLock()
+		at := updated
+		mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"service":    service,
+			"flags":      Snapshot(),
+			"updated_at": at,
+		})
+	}
+}
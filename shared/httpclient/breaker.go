@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while a client's
+// circuit is open.
+var ErrCircuitOpen = errors.New("circuit open: upstream is failing")
+
+// Circuit states reported by Client.CircuitState.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// breaker opens after threshold consecutive failed calls. Once cooldown has
+// passed it lets one trial call through: success closes it, failure opens
+// it for another cooldown. A nil *breaker never opens.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		// Only the trial call goes through
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record counts the outcome of a call that allow let through. Only errors
+// that retryable treats as transient count as failures: a 4xx means the
+// upstream is up. A call abandoned by its caller counts as neither.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		if b.state == CircuitHalfOpen {
+			// Let the next call make the trial instead
+			b.state = CircuitOpen
+		}
+		return
+	}
+	if err == nil || !retryable(err) {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// CircuitState reports the client's circuit: CircuitClosed, CircuitOpen or
+// CircuitHalfOpen. A client without a breaker is always closed.
+func (c *Client) CircuitState() string {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	if c.breaker.state == "" {
+		return CircuitClosed
+	}
+	return c.breaker.state
+}
@@ -0,0 +1,245 @@
+// Package httpclient is the HTTP client for service-to-service calls. It
+// applies per-attempt timeouts, retries idempotent requests with jittered
+// exponential backoff, and turns non-2xx responses into *StatusError values
+// that keep the upstream status and body instead of swallowing them.
+//
+// Requests go through http.DefaultTransport, so TLS, tracing, tenant and
+// auth settings applied to it by the other shared packages still hold.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// Options configures a Client. Zero values use the defaults.
+type Options struct {
+	// Timeout bounds each attempt, including reading the body. Default 30s.
+	Timeout time.Duration
+	// MaxRetries is how many times an idempotent request is retried after
+	// a network error or a 429/502/503/504. Default 2; negative disables.
+	MaxRetries int
+	// RetryBackoff is the base delay, doubled per attempt with full jitter.
+	// Default 100ms.
+	RetryBackoff time.Duration
+	// BreakerThreshold opens the client's circuit after that many
+	// consecutive failed calls; while it is open calls fail at once with
+	// ErrCircuitOpen. Zero disables the breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit stays open before a single
+	// trial call is let through. Default 30s.
+	BreakerCooldown time.Duration
+}
+
+// Client performs JSON calls to other services.
+type Client struct {
+	http       *http.Client
+	maxRetries int
+	backoff    time.Duration
+	breaker    *breaker
+}
+
+// New returns a Client with opts applied.
+func New(opts Options) *Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 2
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	if opts.BreakerCooldown == 0 {
+		opts.BreakerCooldown = 30 * time.Second
+	}
+	c := &Client{
+		http:       &http.Client{Timeout: opts.Timeout},
+		maxRetries: opts.MaxRetries,
+		backoff:    opts.RetryBackoff,
+	}
+	if opts.BreakerThreshold > 0 {
+		c.breaker = &breaker{threshold: opts.BreakerThreshold, cooldown: opts.BreakerCooldown}
+	}
+	return c
+}
+
+// ConfigureDefaultTransport sizes the connection pool of
+// http.DefaultTransport for fan-out between services. Call it after
+// tlsconfig.ConfigureDefaultTransport and before the tracing, tenant and auth
+// wrappers.
+func ConfigureDefaultTransport() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.MaxIdleConns = 200
+		t.MaxIdleConnsPerHost = 32
+		t.IdleConnTimeout = 90 * time.Second
+	}
+}
+
+// StatusError is returned for non-2xx responses.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// IsStatus reports whether err is a *StatusError with the given code.
+func IsStatus(err error, code int) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == code
+}
+
+// CallOption adjusts a single call.
+type CallOption func(*call)
+
+type call struct {
+	idempotent bool
+}
+
+// Idempotent marks a POST as safe to retry, for endpoints that only read
+// (search, embed, retrieve) or write by ID (upsert).
+func Idempotent(c *call) { c.idempotent = true }
+
+// GetJSON decodes the response of a GET into out.
+func (c *Client) GetJSON(ctx context.Context, url string, out interface{}, opts ...CallOption) error {
+	return c.DoJSON(ctx, http.MethodGet, url, nil, out, opts...)
+}
+
+// PostJSON sends in as JSON and decodes the response into out. POSTs are
+// only retried when called with Idempotent.
+func (c *Client) PostJSON(ctx context.Context, url string, in, out interface{}, opts ...CallOption) error {
+	return c.DoJSON(ctx, http.MethodPost, url, in, out, opts...)
+}
+
+// PutJSON sends in as JSON with PUT and decodes the response into out.
+func (c *Client) PutJSON(ctx context.Context, url string, in, out interface{}, opts ...CallOption) error {
+	return c.DoJSON(ctx, http.MethodPut, url, in, out, opts...)
+}
+
+// DoJSON sends in (if non-nil) as JSON and decodes a 2xx response into out
+// (if non-nil). GET, HEAD, PUT, DELETE and OPTIONS are retried; other
+// methods only with Idempotent.
+func (c *Client) DoJSON(ctx context.Context, method, url string, in, out interface{}, opts ...CallOption) error {
+	if err := c.breaker.allow(); err != nil {
+		return fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	err := c.do(ctx, method, url, in, out, opts...)
+	c.breaker.record(ctx, err)
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method, url string, in, out interface{}, opts ...CallOption) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	cl := call{}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		cl.idempotent = true
+	}
+	for _, opt := range opts {
+		opt(&cl)
+	}
+
+	retries := 0
+	if cl.idempotent {
+		retries = c.maxRetries
+	}
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, method, url, body, out)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(rand.Int63n(int64(delay) + 1))):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &StatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(data)),
+		}
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: failed to decode response: %w", method, url, err)
+	}
+	return nil
+}
+
+// retryable reports whether err is worth another attempt: transport errors
+// and timeouts, or an overloaded/unavailable upstream. Cancellation of the
+// caller's context and undecodable responses are not.
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var ue *neturl.Error
+	return errors.As(err, &ue) && !errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,122 @@
+// Package metrics exposes Prometheus RED metrics (rate, errors, duration)
+// for a service's HTTP API, labeled by the mux route that served each
+// request, per-tenant usage counters, plus the /metrics scrape endpoint.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"shared/tenant"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route, method and status code.",
+	}, []string{"service", "route", "method", "code"})
+
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_request_errors_total",
+		Help: "HTTP requests that ended with a 5xx status.",
+	}, []string{"service", "route", "method"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"service", "route", "method"})
+
+	inFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	}, []string{"service"})
+
+	tenantRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tenant_requests_total",
+		Help: "HTTP requests handled per tenant, by route.",
+	}, []string{"service", "tenant", "route"})
+
+	tenantSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tenant_request_seconds_total",
+		Help: "Total time spent serving each tenant's requests.",
+	}, []string{"service", "tenant"})
+)
+
+// Register mounts the Prometheus scrape endpoint at /metrics on mux.
+func Register(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+}
+
+// Wrap instruments next, which should ultimately dispatch through mux. The
+// route label is the mux pattern that matches the request (e.g.
+// "/documents/") so templated paths don't explode label cardinality. Tenant
+// usage is attributed from the request context, so Wrap must run inside
+// tenant.Middleware.
+func Wrap(service string, mux *http.ServeMux, next http.Handler) http.Handler {
+	gauge := inFlight.WithLabelValues(service)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		if route == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gauge.Inc()
+		defer gauge.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		elapsed := time.Since(start).Seconds()
+		requestDuration.WithLabelValues(service, route, r.Method).Observe(elapsed)
+		requestsTotal.WithLabelValues(service, route, r.Method, strconv.Itoa(rec.status)).Inc()
+		if rec.status >= 500 {
+			requestErrors.WithLabelValues(service, route, r.Method).Inc()
+		}
+
+		id := tenant.FromContext(r.Context())
+		tenantRequests.WithLabelValues(service, id, route).Inc()
+		tenantSeconds.WithLabelValues(service, id).Add(elapsed)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming handlers working behind the middleware.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
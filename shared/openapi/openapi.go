@@ -0,0 +1,439 @@
+// Package openapi serves a service's OpenAPI 3 document at /openapi.json,
+// a Swagger UI at /docs, and validates JSON request bodies against the
+// schemas declared in that document.
+//
+// Bodies are read whole to be validated, so they are limited to
+// MAX_REQUEST_BODY_BYTES (default 10 MiB); a larger one is a 413. The
+// specs are written by hand next to the Go types they describe, and each
+// service's tests hold the two together with CheckType.
+package openapi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBodyBytes bounds the JSON bodies Validate reads.
+var maxBodyBytes = bodyLimit()
+
+func bodyLimit() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("MAX_REQUEST_BODY_BYTES"), 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return 10 << 20
+}
+
+// Spec is the subset of an OpenAPI 3 document needed for routing and
+// request validation.
+type Spec struct {
+	raw   []byte
+	Paths map[string]map[string]Operation `json:"paths"`
+	Comps struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is a single method on a path.
+type Operation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// Schema is the JSON Schema subset the validator understands.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	AllOf      []*Schema          `json:"allOf"`
+	Enum       []interface{}      `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MinLength  *int               `json:"minLength"`
+	MinItems   *int               `json:"minItems"`
+}
+
+// MustLoad parses an OpenAPI document, panicking on malformed JSON since
+// specs are embedded at build time.
+func MustLoad(raw []byte) *Spec {
+	spec := &Spec{raw: raw}
+	if err := json.Unmarshal(raw, spec); err != nil {
+		panic(fmt.Sprintf("openapi: invalid spec: %v", err))
+	}
+	return spec
+}
+
+// Register mounts /openapi.json and /docs on mux.
+func (s *Spec) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.raw)
+	})
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, swaggerUI)
+	})
+}
+
+// Validate wraps next so that JSON request bodies are checked against the
+// operation's requestBody schema before the handler runs. Requests that do
+// not map to a documented operation pass through untouched.
+func (s *Spec) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := s.lookup(r.Method, r.URL.Path)
+		if !ok || op.RequestBody == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok || media.Schema == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tooLarge := fmt.Sprintf("Request body exceeds %d bytes", maxBodyBytes)
+		if r.ContentLength > maxBodyBytes {
+			respondError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		r.Body.Close()
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if len(bytes.TrimSpace(body)) == 0 {
+			if op.RequestBody.Required {
+				respondError(w, http.StatusBadRequest, "Request body is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := s.check(media.Schema, doc, "body"); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// lookup finds the operation for method and path, matching templated
+// segments such as /documents/{id}.
+func (s *Spec) lookup(method, path string) (Operation, bool) {
+	method = strings.ToLower(method)
+	if ops, ok := s.Paths[path]; ok {
+		op, ok := ops[method]
+		return op, ok
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for tmpl, ops := range s.Paths {
+		parts := strings.Split(strings.Trim(tmpl, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				continue
+			}
+			if part != segments[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			op, ok := ops[method]
+			return op, ok
+		}
+	}
+	return Operation{}, false
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		schema = s.Comps.Schemas[name]
+	}
+	return schema
+}
+
+func (s *Spec) check(schema *Schema, value interface{}, at string) error {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	for _, part := range schema.AllOf {
+		if err := s.check(part, value, at); err != nil {
+			return err
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", at)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", at, name)
+			}
+		}
+		for name, prop := range schema.Properties {
+			if v, ok := obj[name]; ok && v != nil {
+				if err := s.check(prop, v, at+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", at)
+		}
+		if schema.MinItems != nil && len(arr) < *schema.MinItems {
+			return fmt.Errorf("%s must contain at least %d items", at, *schema.MinItems)
+		}
+		if schema.Items != nil {
+			for i, item := range arr {
+				if err := s.check(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", at)
+		}
+		if schema.MinLength != nil && len(str) < *schema.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", at, *schema.MinLength)
+		}
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a %s", at, schema.Type)
+		}
+		if schema.Type == "integer" && num != float64(int64(num)) {
+			return fmt.Errorf("%s must be an integer", at)
+		}
+		if schema.Minimum != nil && num < *schema.Minimum {
+			return fmt.Errorf("%s must be >= %v", at, *schema.Minimum)
+		}
+		if schema.Maximum != nil && num > *schema.Maximum {
+			return fmt.Errorf("%s must be <= %v", at, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", at)
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		for _, allowed := range schema.Enum {
+			if allowed == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %v", at, schema.Enum)
+	}
+	return nil
+}
+
+// CheckType reports where the named component schema and v, a struct or
+// a pointer to one, disagree: a property the struct has no field for, a
+// field the schema doesn't declare, or a field whose Go type can't hold
+// the property's JSON type. unlisted names fields the schema leaves out on
+// purpose, such as embedded ones a handler refuses. Services call it from
+// their tests so a spec can't drift from the types its handlers decode and
+// encode.
+func (s *Spec) CheckType(name string, v interface{}, unlisted ...string) error {
+	schema := s.Comps.Schemas[name]
+	if schema = s.resolve(schema); schema == nil {
+		return fmt.Errorf("no schema %s", name)
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: %v is not a struct", name, t)
+	}
+
+	fields := map[string]reflect.Type{}
+	jsonFields(t, fields)
+	for _, field := range unlisted {
+		delete(fields, field)
+	}
+
+	props := s.properties(schema)
+	var problems []string
+	for _, prop := range sortedKeys(props) {
+		field, ok := fields[prop]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s has no field in %s", name, prop, t))
+			continue
+		}
+		if want := s.resolve(props[prop]); want != nil && !s.holds(want, field) {
+			problems = append(problems, fmt.Sprintf("%s.%s is a %s but %s.%s is a %s", name, prop, want.Type, t, prop, field))
+		}
+	}
+	for _, field := range sortedKeys(fields) {
+		if _, ok := props[field]; !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s is not in schema %s", t, field, name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// properties is schema's properties together with those of its allOf
+// parts.
+func (s *Spec) properties(schema *Schema) map[string]*Schema {
+	props := make(map[string]*Schema, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		props[name] = prop
+	}
+	for _, part := range schema.AllOf {
+		if part = s.resolve(part); part != nil {
+			for name, prop := range s.properties(part) {
+				props[name] = prop
+			}
+		}
+	}
+	return props
+}
+
+// jsonFields collects the fields of t as encoding/json names them,
+// including those of embedded structs.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				jsonFields(embedded, fields)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		fields[tag] = field.Type
+	}
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// holds reports whether values of t encode as schema's JSON type.
+func (s *Spec) holds(schema *Schema, t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+		return true
+	}
+	switch schema.Type {
+	case "string":
+		return t.Kind() == reflect.String || t.Implements(textMarshaler)
+	case "integer":
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+		return false
+	case "number":
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint64:
+			return true
+		}
+		return false
+	case "boolean":
+		return t.Kind() == reflect.Bool
+	case "array":
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return false
+		}
+		items := s.resolve(schema.Items)
+		return items == nil || s.holds(items, t.Elem())
+	case "object":
+		return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+  <title>API Docs</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>`
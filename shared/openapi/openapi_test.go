@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSpec = `{
+  "paths": {
+    "/items": {"post": {"requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}}
+  },
+  "components": {"schemas": {
+    "Item": {"type": "object", "required": ["name"], "properties": {
+      "name": {"type": "string"},
+      "count": {"type": "integer", "minimum": 0},
+      "tags": {"type": "array", "items": {"type": "string"}},
+      "created_at": {"type": "string"}
+    }}
+  }}
+}`
+
+func serveItems(t *testing.T) http.Handler {
+	t.Helper()
+	return MustLoad([]byte(testSpec)).Validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"name": "kyc", "count": 2}`, http.StatusNoContent},
+		{"missing required", `{"count": 2}`, http.StatusBadRequest},
+		{"wrong type", `{"name": "kyc", "count": "two"}`, http.StatusBadRequest},
+		{"below minimum", `{"name": "kyc", "count": -1}`, http.StatusBadRequest},
+		{"empty", ``, http.StatusBadRequest},
+		{"malformed", `{"name":`, http.StatusBadRequest},
+	}
+	handler := serveItems(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestValidateBodyLimit(t *testing.T) {
+	previous := maxBodyBytes
+	maxBodyBytes = 64
+	defer func() { maxBodyBytes = previous }()
+
+	large := `{"name": "` + strings.Repeat("x", 100) + `"}`
+	tests := []struct {
+		name          string
+		body          string
+		contentLength bool
+		status        int
+	}{
+		{"within the limit", `{"name": "kyc"}`, true, http.StatusNoContent},
+		{"declared too large", large, true, http.StatusRequestEntityTooLarge},
+		{"streamed too large", large, false, http.StatusRequestEntityTooLarge},
+	}
+	handler := serveItems(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if !tt.contentLength {
+				req.ContentLength = -1 // as for a chunked body
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	type base struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		ok    bool
+	}{
+		{"matching", struct {
+			base
+			Count     int       `json:"count"`
+			Tags      []string  `json:"tags"`
+			CreatedAt time.Time `json:"created_at"`
+			internal  int
+		}{}, true},
+		{"missing field", struct {
+			Name  string   `json:"name"`
+			Count int      `json:"count"`
+			Tags  []string `json:"tags"`
+		}{}, false},
+		{"undocumented field", struct {
+			base
+			Count     int       `json:"count"`
+			Tags      []string  `json:"tags"`
+			CreatedAt time.Time `json:"created_at"`
+			Extra     bool      `json:"extra"`
+		}{}, false},
+		{"wrong type", struct {
+			base
+			Count     string    `json:"count"`
+			Tags      []string  `json:"tags"`
+			CreatedAt time.Time `json:"created_at"`
+		}{}, false},
+		{"wrong item type", struct {
+			base
+			Count     int       `json:"count"`
+			Tags      []int     `json:"tags"`
+			CreatedAt time.Time `json:"created_at"`
+		}{}, false},
+	}
+	spec := MustLoad([]byte(testSpec))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := spec.CheckType("Item", tt.value)
+			if (err == nil) != tt.ok {
+				t.Errorf("CheckType = %v, want ok = %t", err, tt.ok)
+			}
+		})
+	}
+	unlisted := struct {
+		base
+		Count     int       `json:"count"`
+		Tags      []string  `json:"tags"`
+		CreatedAt time.Time `json:"created_at"`
+		Extra     bool      `json:"extra"`
+	}{}
+	if err := spec.CheckType("Item", unlisted, "extra"); err != nil {
+		t.Errorf("CheckType with extra unlisted = %v", err)
+	}
+	if err := spec.CheckType("Missing", struct{}{}); err == nil {
+		t.Error("CheckType accepted an unknown schema")
+	}
+}
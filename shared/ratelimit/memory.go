@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// memoryLimiter keeps buckets in process. Buckets idle for longer than it
+// takes to refill are dropped by a background sweep.
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	stop    chan struct{}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	l := &memoryLimiter{buckets: make(map[string]*bucket), stop: make(chan struct{})}
+	go l.sweep()
+	return l
+}
+
+func (l *memoryLimiter) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return Decision{Allowed: false, RetryAfter: wait}, nil
+	}
+	b.tokens--
+	return Decision{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+func (l *memoryLimiter) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			for key, b := range l.buckets {
+				refill := time.Duration(float64(b.limit.Burst) / b.limit.Rate * float64(time.Second))
+				if now.Sub(b.last) > refill {
+					delete(l.buckets, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *memoryLimiter) Close() error {
+	close(l.stop)
+	return nil
+}
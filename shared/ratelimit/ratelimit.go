@@ -0,0 +1,197 @@
+// Package ratelimit provides token-bucket rate limiting and per-tenant
+// quotas as HTTP middleware. Buckets live in memory by default; setting
+// REDIS_URL shares them across replicas through Redis.
+//
+// Two buckets are checked for every request: one per API key (the token
+// subject, the X-API-Key header, or the client IP) and one per tenant.
+//
+//	RATE_LIMIT_RPS / RATE_LIMIT_BURST               per-key bucket (default 10 / 20)
+//	TENANT_RATE_LIMIT_RPS / TENANT_RATE_LIMIT_BURST per-tenant bucket (default 50 / 100)
+//	TENANT_QUOTAS                                   per-tenant overrides, e.g. "payments=100:200,lending=5:10"
+//
+// A rate of 0 disables that bucket.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"shared/auth"
+	"shared/tenant"
+)
+
+// Limit is a token-bucket configuration: Rate tokens per second refill a
+// bucket holding at most Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of taking one token from a bucket.
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter takes tokens from named buckets.
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+	Close() error
+}
+
+// New returns a Redis-backed limiter when REDIS_URL is set and an in-memory
+// one otherwise.
+func New() (Limiter, error) {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		return newRedisLimiter(url)
+	}
+	return newMemoryLimiter(), nil
+}
+
+var rejections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limit_rejections_total",
+	Help: "Requests rejected with 429, by bucket scope and tenant.",
+}, []string{"service", "scope", "tenant"})
+
+// Config holds the per-key and per-tenant limits.
+type Config struct {
+	PerKey       Limit
+	PerTenant    Limit
+	TenantQuotas map[string]Limit
+}
+
+// ConfigFromEnv reads limits from the environment variables documented on
+// the package.
+func ConfigFromEnv() Config {
+	return Config{
+		PerKey: Limit{
+			Rate:  envFloat("RATE_LIMIT_RPS", 10),
+			Burst: int(envFloat("RATE_LIMIT_BURST", 20)),
+		},
+		PerTenant: Limit{
+			Rate:  envFloat("TENANT_RATE_LIMIT_RPS", 50),
+			Burst: int(envFloat("TENANT_RATE_LIMIT_BURST", 100)),
+		},
+		TenantQuotas: parseQuotas(os.Getenv("TENANT_QUOTAS")),
+	}
+}
+
+// Wrap rejects requests that exceed either bucket with 429 and a
+// Retry-After header. Probe, metrics and docs endpoints are exempt. It reads
+// the tenant and token claims from the context, so it must run inside
+// tenant.Middleware and auth.Wrap. Limiter errors fail open.
+func Wrap(service string, limiter Limiter, cfg Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/healthz", "/readyz", "/metrics", "/openapi.json", "/docs":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		tenantID := tenant.FromContext(ctx)
+
+		tenantLimit := cfg.PerTenant
+		if quota, ok := cfg.TenantQuotas[tenantID]; ok {
+			tenantLimit = quota
+		}
+
+		checks := []struct {
+			scope string
+			key   string
+			limit Limit
+		}{
+			{"tenant", service + ":tenant:" + tenantID, tenantLimit},
+			{"key", service + ":key:" + apiKey(r), cfg.PerKey},
+		}
+
+		for _, c := range checks {
+			if c.limit.Rate <= 0 {
+				continue
+			}
+			d, err := limiter.Take(ctx, c.key, c.limit)
+			if err != nil {
+				log.Printf("Rate limiter unavailable, allowing request: %v", err)
+				continue
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(c.limit.Burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			if !d.Allowed {
+				rejections.WithLabelValues(service, c.scope, tenantID).Inc()
+				retry := int(math.Ceil(d.RetryAfter.Seconds()))
+				if retry < 1 {
+					retry = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":       "rate limit exceeded",
+					"scope":       c.scope,
+					"retry_after": retry,
+				})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKey identifies the caller: the authenticated subject, the X-API-Key
+// header, or the client IP, in that order.
+func apiKey(r *http.Request) string {
+	if claims, ok := auth.FromContext(r.Context()); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return "api:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func parseQuotas(raw string) map[string]Limit {
+	quotas := make(map[string]Limit)
+	for _, entry := range strings.Split(raw, ",") {
+		name, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		rateStr, burstStr, _ := strings.Cut(spec, ":")
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			log.Printf("Ignoring invalid TENANT_QUOTAS entry %q", entry)
+			continue
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil {
+			burst = int(math.Ceil(rate))
+		}
+		quotas[name] = Limit{Rate: rate, Burst: burst}
+	}
+	return quotas
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
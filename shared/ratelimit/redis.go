@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes from a bucket atomically. It returns
+// {allowed, remaining tokens, milliseconds until the next token}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, math.floor(tokens), wait}
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func newRedisLimiter(url string) (*redisLimiter, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisLimiter{client: redis.NewClient(opts)}, nil
+}
+
+func (l *redisLimiter) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{"ratelimit:" + key},
+		limit.Rate, limit.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{
+		Allowed:    res[0] == 1,
+		Remaining:  int(math.Max(0, float64(res[1]))),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+func (l *redisLimiter) Close() error {
+	return l.client.Close()
+}
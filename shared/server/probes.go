@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+var probeClient = &http.Client{Timeout: 2 * time.Second}
+
+// HTTPCheck returns a Check that GETs url and expects a 2xx response.
+func HTTPCheck(url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := probeClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// LivenessHandler answers /healthz. It only proves the process is serving
+// requests and never consults dependencies, so a downstream outage does not
+// get this pod restarted.
+func LivenessHandler(service string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"status":  "alive",
+			"service": service,
+		}, http.StatusOK)
+	}
+}
+
+// ReadinessHandler answers /readyz by running every check concurrently. It
+// fails while the server is draining or when any check returns an error.
+func ReadinessHandler(service string, checks map[string]Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if Draining() {
+			writeJSON(w, map[string]interface{}{
+				"status":  "draining",
+				"service": service,
+			}, http.StatusServiceUnavailable)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		results := make(map[string]string, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check Check) {
+				defer wg.Done()
+				status := "ok"
+				if err := check(ctx); err != nil {
+					status = err.Error()
+				}
+				mu.Lock()
+				results[name] = status
+				mu.Unlock()
+			}(name, check)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		for _, result := range results {
+			if result != "ok" {
+				status, code = "not_ready", http.StatusServiceUnavailable
+				break
+			}
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"status":  status,
+			"service": service,
+			"checks":  results,
+		}, code)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
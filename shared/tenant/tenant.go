@@ -0,0 +1,176 @@
+// Package tenant carries the calling tenant (business unit) through a
+// request: it is read from the X-Tenant-ID header at each service boundary,
+// stored on the context, forwarded on outgoing HTTP and gRPC calls, and used
+// by storage layers to scope reads and writes.
+//
+// Requests without the header belong to the "default" tenant unless
+// TENANT_REQUIRED=true. TENANTS, when set, is a comma-separated allowlist;
+// unknown tenants are rejected with 403.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Header is the HTTP header, and lower-cased the gRPC metadata key, that
+// carries the tenant ID between services.
+const Header = "X-Tenant-ID"
+
+// Default is the tenant used when none is supplied, and the owner of data
+// written before multi-tenancy was introduced.
+const Default = "default"
+
+var (
+	metadataKey = strings.ToLower(Header)
+	validID     = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	required    = os.Getenv("TENANT_REQUIRED") == "true"
+	allowed     = parseAllowlist(os.Getenv("TENANTS"))
+)
+
+// Errors returned by Resolve.
+var (
+	ErrMissing = errors.New(Header + " header is required")
+	ErrInvalid = errors.New("invalid tenant ID")
+	ErrUnknown = errors.New("unknown tenant")
+)
+
+type contextKey struct{}
+
+// WithTenant returns a copy of ctx carrying id.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant on ctx, or Default when there is none.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Resolve validates a raw tenant ID as received on the wire. An empty id
+// resolves to Default unless TENANT_REQUIRED is set.
+func Resolve(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		if required {
+			return "", ErrMissing
+		}
+		return Default, nil
+	}
+	if !validID.MatchString(id) {
+		return "", ErrInvalid
+	}
+	if allowed != nil && !allowed[id] {
+		return "", ErrUnknown
+	}
+	return id, nil
+}
+
+// Middleware resolves the tenant for each request and stores it on the
+// request context. Probe, metrics and docs endpoints are exempt so
+// orchestrators and scrapers don't need to send the header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/healthz", "/readyz", "/metrics", "/openapi.json", "/docs":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := Resolve(r.Header.Get(Header))
+		if err != nil {
+			code := http.StatusBadRequest
+			if err == ErrUnknown {
+				code = http.StatusForbidden
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), id)))
+	})
+}
+
+// ConfigureDefaultTransport wraps http.DefaultTransport so outgoing requests
+// forward the tenant on their context. Requests that already set the header
+// are left alone.
+func ConfigureDefaultTransport() {
+	http.DefaultTransport = &transport{base: http.DefaultTransport}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(Header) != "" {
+		return t.base.RoundTrip(req)
+	}
+	id, ok := req.Context().Value(contextKey{}).(string)
+	if !ok || id == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, id)
+	return t.base.RoundTrip(req)
+}
+
+// UnaryServerInterceptor resolves the tenant from incoming gRPC metadata.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var raw string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(metadataKey); len(values) > 0 {
+				raw = values[0]
+			}
+		}
+
+		id, err := Resolve(raw)
+		if err == ErrUnknown {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return handler(WithTenant(ctx, id), req)
+	}
+}
+
+// UnaryClientInterceptor forwards the tenant on ctx as gRPC metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, metadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func parseAllowlist(raw string) map[string]bool {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, id := range strings.Split(raw, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			set[id] = true
+		}
+	}
+	return set
+}
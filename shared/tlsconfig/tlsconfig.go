@@ -0,0 +1,118 @@
+// Package tlsconfig builds server and client TLS configurations from
+// certificate paths in the environment so services can run mutual TLS on
+// internal traffic. Everything is optional: with no variables set, services
+// keep serving and calling each other over plain HTTP.
+//
+// Server side:
+//
+//	TLS_CERT_FILE, TLS_KEY_FILE   serving certificate and key
+//	TLS_CLIENT_CA_FILE            CA bundle; when set, clients must present a cert signed by it
+//
+// Client side:
+//
+//	TLS_CA_FILE                   CA bundle trusted in addition to the system roots
+//	TLS_CLIENT_CERT_FILE, TLS_CLIENT_KEY_FILE  certificate presented to mTLS servers
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ServerConfig returns the TLS configuration for an HTTP or gRPC server, or
+// nil when TLS_CERT_FILE is not set.
+func ServerConfig() (*tls.Config, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := os.Getenv("TLS_CLIENT_CA_FILE"); caFile != "" {
+		pool, err := loadPool(caFile, false)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// ClientConfig returns the TLS configuration for outbound calls, or nil when
+// neither a CA bundle nor a client certificate is configured. The system root
+// pool is kept so calls to public APIs (Gemini) continue to verify.
+func ClientConfig() (*tls.Config, error) {
+	caFile := os.Getenv("TLS_CA_FILE")
+	certFile, keyFile := os.Getenv("TLS_CLIENT_CERT_FILE"), os.Getenv("TLS_CLIENT_KEY_FILE")
+	if caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pool, err := loadPool(caFile, true)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ConfigureDefaultTransport applies ClientConfig to http.DefaultTransport so
+// every http.Get/http.Post and client without its own transport uses it.
+func ConfigureDefaultTransport() error {
+	cfg, err := ClientConfig()
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("http.DefaultTransport is not an *http.Transport")
+	}
+	transport.TLSClientConfig = cfg
+	return nil
+}
+
+func loadPool(caFile string, withSystem bool) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if withSystem {
+		if system, err := x509.SystemCertPool(); err == nil {
+			pool = system
+		}
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}